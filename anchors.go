@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// anchorsRange is the range of the anchors served without from.
+const anchorsRange = 31 * 24 * time.Hour
+
+// Anchors serves GET /v1/meters/:id/anchors: the saved daily anchors of the
+// meter with from <= at < to, as JSON or, with format=csv, as CSV for the
+// supplier. from and to are dates or RFC 3339 times, by default the last 31
+// days.
+type Anchors struct {
+	Keeper   *anchor.Keeper // no keeper: anchors fail
+	Meter    genai.Meter
+	Location *time.Location // default time.Local
+	Clock    genai.Clock    // default genai.RealClock
+}
+
+// Handler implements the endpoint.
+func (h *Anchors) Handler(c *gin.Context) {
+	if id := c.Param("id"); id != h.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	if h.Keeper == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "anchors need anchor and store.path"})
+		return
+	}
+	loc, clock := h.Location, h.Clock
+	if loc == nil {
+		loc = time.Local
+	}
+	if clock == nil {
+		clock = genai.RealClock
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown format %q, want json or csv", format)})
+		return
+	}
+	from, to, err := anchorsRangeOf(c.Query("from"), c.Query("to"), loc, clock.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	as, err := h.Keeper.Saved(c.Request.Context(), h.Meter.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-anchors.csv"`, h.Meter.ID))
+		c.Status(http.StatusOK)
+		anchor.WriteCSV(c.Writer, as)
+		return
+	}
+	if as == nil {
+		as = []anchor.Anchor{}
+	}
+	c.JSON(http.StatusOK, gin.H{"meter": h.Meter.ID, "anchors": as})
+}
+
+// anchorsRangeOf parses the range of anchor times from the from and to
+// parameters: dates in loc or RFC 3339 times, to defaulting to the day after
+// now and from to anchorsRange before to.
+func anchorsRangeOf(fromArg, toArg string, loc *time.Location, now time.Time) (from, to time.Time, err error) {
+	y, m, d := now.In(loc).Date()
+	to = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	if toArg != "" {
+		if to, err = parseAnchorTime(toArg, loc); err != nil {
+			return from, to, fmt.Errorf("to: %w", err)
+		}
+	}
+	from = to.Add(-anchorsRange)
+	if fromArg != "" {
+		if from, err = parseAnchorTime(fromArg, loc); err != nil {
+			return from, to, fmt.Errorf("from: %w", err)
+		}
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return from, to, nil
+}
+
+func parseAnchorTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// recomputeAnchors recomputes the anchors a reading at at may change, as
+// after it was saved or corrected, logging the days that changed.
+func recomputeAnchors(ctx context.Context, k *anchor.Keeper, meterID string, at time.Time) {
+	if k == nil {
+		return
+	}
+	from, to := k.Config().Affected(at)
+	changed, err := k.Recompute(ctx, meterID, from, to)
+	if err != nil {
+		log.Printf("Error recomputing daily anchors: %v", err)
+		return
+	}
+	if len(changed) > 0 {
+		log.Printf("Updated the daily anchors of %s", strings.Join(changed, ", "))
+	}
+}
+
+// runAnchors implements the `anchors` subcommand: it prints the daily
+// anchors of a range of days as CSV or JSON, recomputing and saving them
+// first with -recompute, as after readings were added to the store by hand.
+func runAnchors(args []string) error {
+	fs := flag.NewFlagSet("anchors", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	fromFlag := fs.String("from", "", "First day (YYYY-MM-DD, default: 31 days before -to)")
+	toFlag := fs.String("to", "", "Day after the last (YYYY-MM-DD, default: tomorrow)")
+	recompute := fs.Bool("recompute", false, "Recompute and save the anchors of the range first")
+	format := fs.String("format", "csv", "Output format: csv or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s anchors [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Anchor == nil || config.Store.Path == "" {
+		return fmt.Errorf("anchors: needs anchor and store.path")
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q, want csv or json", *format)
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	meter.ID = *meterID
+	loc := config.ReportConfig().TimeZone()
+	from, to, err := anchorsRangeOf(*fromFlag, *toFlag, loc, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	k, err := anchor.NewKeeper(s, *config.Anchor, meter, loc)
+	if err != nil {
+		return err
+	}
+	if *recompute {
+		changed, err := k.Recompute(ctx, *meterID, from, to)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Recomputed the anchors from %s to %s: %d changed\n", from.Format(time.DateOnly), to.Format(time.DateOnly), len(changed))
+	}
+	as, err := k.Saved(ctx, *meterID, from, to)
+	if err != nil {
+		return err
+	}
+	return writeAnchors(os.Stdout, as, *format)
+}
+
+func writeAnchors(w io.Writer, as []anchor.Anchor, format string) error {
+	if format == "csv" {
+		return anchor.WriteCSV(w, as)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(as)
+}
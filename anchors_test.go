@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestAnchors(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	day := time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	for _, r := range []struct {
+		read string
+		at   time.Duration
+	}{
+		{"01234.000", -20 * time.Minute},
+		{"01234.500", 10 * time.Minute},
+		{"01236.000", 24*time.Hour + 5*time.Minute},
+	} {
+		if err := s.Save(ctx, "home", &genai.GasMeterReadResult{Read: r.read, ReadAt: day.Add(r.at)}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	k, err := anchor.NewKeeper(s, anchor.Config{}, meter, time.UTC)
+	if err != nil {
+		t.Fatalf("NewKeeper: %v", err)
+	}
+	if _, err := k.Recompute(ctx, "home", day, day.AddDate(0, 0, 2)); err != nil {
+		t.Fatalf("Recompute: %v", err)
+	}
+
+	clock := genaitest.NewClock(day.Add(36 * time.Hour))
+	router := gin.New()
+	router.GET("/v1/meters/:id/anchors", (&Anchors{Keeper: k, Meter: meter, Location: time.UTC, Clock: clock}).Handler)
+	router.GET("/off/:id/anchors", (&Anchors{Meter: meter}).Handler)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v1/meters/home/anchors")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var got struct {
+		Meter   string          `json:"meter"`
+		Anchors []anchor.Anchor `json:"anchors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	if got.Meter != "home" || len(got.Anchors) != 2 || got.Anchors[0].Read != "01234.500" || got.Anchors[1].Read != "01236.000" {
+		t.Fatalf("anchors = %+v", got)
+	}
+
+	w = get("/v1/meters/home/anchors?from=2025-11-08&to=2025-11-09&format=csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "2025-11-08,") {
+		t.Fatalf("csv =\n%s", w.Body)
+	}
+
+	for _, tt := range []struct {
+		path string
+		code int
+	}{
+		{"/v1/meters/garage/anchors", http.StatusNotFound},
+		{"/v1/meters/home/anchors?format=xml", http.StatusBadRequest},
+		{"/v1/meters/home/anchors?from=2025-11-09&to=2025-11-08", http.StatusBadRequest},
+		{"/off/home/anchors", http.StatusNotImplemented},
+	} {
+		if w := get(tt.path); w.Code != tt.code {
+			t.Errorf("%s: status %d, want %d: %s", tt.path, w.Code, tt.code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Scopes of an [APIToken].
+const (
+	scopeRead   = "read"   // the GET endpoints: the dashboard, /sensor, stream, series, anchors, photo, /debug/vars
+	scopeSubmit = "submit" // endpoints taking meter images
+	scopeAdmin  = "admin"  // reading corrections; implies the other scopes
+)
+
+var apiScopes = []string{scopeRead, scopeSubmit, scopeAdmin}
+
+// accessTokenParam is the query parameter of a token in GET requests.
+const accessTokenParam = "access_token"
+
+// tokenNameKey is the key of the name of the token a request was admitted
+// with in its gin context; see [tokenName].
+const tokenNameKey = "mqvision.token"
+
+// tokenHashPrefix starts the hash of an [APIToken].
+const tokenHashPrefix = "sha256:"
+
+// APIToken is a bearer token of the API as configured: only its hash is
+// kept. The tokens are random, so a plain SHA-256 is enough to keep them
+// secret at rest.
+type APIToken struct {
+	// Name identifies the token in logs, which never show the token itself.
+	Name   string   `yaml:"name"`
+	Hash   string   `yaml:"hash"` // "sha256:" and the hex digest, see `mqvision token generate`
+	Scopes []string `yaml:"scopes"`
+	// RateLimit bounds the requests per minute with the token (0: no limit).
+	RateLimit float64 `yaml:"rate_limit"`
+}
+
+// Validate checks the hash and scopes of t.
+func (t APIToken) Validate() error {
+	if t.Name == "" {
+		return errors.New("needs a name")
+	}
+	if _, err := parseTokenHash(t.Hash); err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("%s: needs scopes", t.Name)
+	}
+	for _, s := range t.Scopes {
+		if !slices.Contains(apiScopes, s) {
+			return fmt.Errorf("%s: unknown scope %q, want one of %s", t.Name, s, strings.Join(apiScopes, ", "))
+		}
+	}
+	if t.RateLimit < 0 {
+		return fmt.Errorf("%s: rate_limit must not be negative", t.Name)
+	}
+	return nil
+}
+
+func parseTokenHash(s string) ([sha256.Size]byte, error) {
+	var h [sha256.Size]byte
+	hexHash, ok := strings.CutPrefix(s, tokenHashPrefix)
+	if b, err := hex.DecodeString(hexHash); !ok || err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("hash is not %s and a hex SHA-256 digest", tokenHashPrefix)
+	}
+	hex.Decode(h[:], []byte(hexHash))
+	return h, nil
+}
+
+// hashToken returns the hash of token to configure.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return tokenHashPrefix + hex.EncodeToString(h[:])
+}
+
+// Auth checks the bearer tokens of API requests. Without configured tokens
+// the read endpoints are open, as on a LAN, and the others refused.
+type Auth struct {
+	tokens []*authToken
+	open   bool
+}
+
+type authToken struct {
+	name   string
+	hash   [sha256.Size]byte
+	scopes []string
+	limit  *tokenBucket // nil: no limit
+}
+
+// NewAuth returns the Auth of tokens.
+func NewAuth(tokens []APIToken, clock genai.Clock) (*Auth, error) {
+	a := &Auth{open: len(tokens) == 0}
+	for _, t := range tokens {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		h, _ := parseTokenHash(t.Hash)
+		at := &authToken{name: t.Name, hash: h, scopes: t.Scopes}
+		if t.RateLimit > 0 {
+			at.limit = newTokenBucket(t.RateLimit, clock)
+		}
+		a.tokens = append(a.tokens, at)
+	}
+	return a, nil
+}
+
+// Require returns the middleware admitting requests with a token of scope:
+// 401 without a valid token, 403 for a token without the scope or if no
+// token has it, and 429 for a token over its rate limit. GET requests may
+// pass the token as the access_token query parameter instead, as browsers
+// cannot set headers when opening a page or a stream.
+func (a *Auth) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth, hasAuth := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if q := c.Query(accessTokenParam); !hasAuth && q != "" && c.Request.Method == http.MethodGet {
+			auth, hasAuth = q, true
+		}
+		if scope == scopeRead && a.open {
+			c.Next()
+			return
+		}
+		if !slices.ContainsFunc(a.tokens, func(t *authToken) bool { return t.has(scope) }) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("%s endpoints need api.tokens", scope)})
+			return
+		}
+		t := a.match(auth)
+		if !hasAuth || t == nil {
+			log.Printf("Refused %s %s: invalid token", c.Request.Method, c.FullPath())
+			c.Header("WWW-Authenticate", `Bearer realm="mqvision"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		if !t.has(scope) {
+			log.Printf("Refused %s %s: token %s lacks scope %s", c.Request.Method, c.FullPath(), t.name, scope)
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="mqvision", error="insufficient_scope", scope=%q`, scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token lacks scope " + scope})
+			return
+		}
+		if wait, ok := t.limit.take(); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Set(tokenNameKey, t.name)
+		c.Next()
+	}
+}
+
+// tokenName returns the name of the token c was admitted with by
+// [Auth.Require], "" for an open endpoint.
+func tokenName(c *gin.Context) string {
+	return c.GetString(tokenNameKey)
+}
+
+// match returns the token whose hash is that of s, comparing with every
+// token in constant time.
+func (a *Auth) match(s string) *authToken {
+	h := sha256.Sum256([]byte(s))
+	var found *authToken
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(h[:], t.hash[:]) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+func (t *authToken) has(scope string) bool {
+	return slices.Contains(t.scopes, scope) || slices.Contains(t.scopes, scopeAdmin)
+}
+
+// tokenBucket allows perMinute requests a minute, in bursts of as many.
+type tokenBucket struct {
+	perMinute float64
+	clock     genai.Clock
+
+	mu     sync.Mutex
+	tokens float64
+	at     time.Time
+}
+
+func newTokenBucket(perMinute float64, clock genai.Clock) *tokenBucket {
+	if clock == nil {
+		clock = genai.RealClock
+	}
+	return &tokenBucket{perMinute: perMinute, clock: clock, tokens: max(perMinute, 1), at: clock.Now()}
+}
+
+// take takes a request from b, or returns how long until one is allowed. A
+// nil bucket allows every request.
+func (b *tokenBucket) take() (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	b.tokens = min(b.tokens+now.Sub(b.at).Minutes()*b.perMinute, max(b.perMinute, 1))
+	b.at = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / b.perMinute * float64(time.Minute)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// runToken implements the `token` subcommand; `token generate` prints a
+// new API token and its config entry.
+func runToken(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return fmt.Errorf("usage: %s token generate [flags]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("token generate", flag.ExitOnError)
+	name := fs.String("name", "phone", "Name of the token in logs")
+	scopes := fs.String("scopes", scopeRead, "Comma-separated scopes: "+strings.Join(apiScopes, ", "))
+	rateLimit := fs.Float64("rate-limit", 0, "Requests per minute (0: no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s token generate [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	t := APIToken{Name: *name, Hash: hashToken(token), Scopes: strings.Split(*scopes, ","), RateLimit: *rateLimit}
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	fmt.Printf("# Token, shown only now: %s\n", token)
+	fmt.Println("# Add to api.tokens:")
+	fmt.Printf("- name: %s\n  hash: %s\n  scopes: [%s]\n", t.Name, t.Hash, strings.Join(t.Scopes, ", "))
+	if t.RateLimit > 0 {
+		fmt.Printf("  rate_limit: %g\n", t.RateLimit)
+	}
+	return nil
+}
+
+// generateToken returns a random token of 32 bytes.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return "mqv_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC))
+	auth, err := NewAuth([]APIToken{
+		{Name: "phone", Hash: hashToken("mqv_phone"), Scopes: []string{scopeRead}, RateLimit: 2},
+		{Name: "camera", Hash: hashToken("mqv_camera"), Scopes: []string{scopeSubmit}},
+		{Name: "me", Hash: hashToken("mqv_me"), Scopes: []string{scopeAdmin}},
+	}, clock)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router := gin.New()
+	router.GET("/sensor", auth.Require(scopeRead), ok)
+	router.POST("/correction", auth.Require(scopeAdmin), ok)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" && !strings.Contains(path, "access_token=") {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		code         int
+	}{
+		{"no token", http.MethodGet, "/sensor", "", http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/sensor", "mqv_guess", http.StatusUnauthorized},
+		{"hash as token", http.MethodGet, "/sensor", hashToken("mqv_phone"), http.StatusUnauthorized},
+		{"read", http.MethodGet, "/sensor", "mqv_phone", http.StatusNoContent},
+		{"read without scope", http.MethodGet, "/sensor", "mqv_camera", http.StatusForbidden},
+		{"admin reads", http.MethodGet, "/sensor", "mqv_me", http.StatusNoContent},
+		{"correction without scope", http.MethodPost, "/correction", "mqv_phone", http.StatusForbidden},
+		{"correction", http.MethodPost, "/correction", "mqv_me", http.StatusNoContent},
+		{"query token", http.MethodGet, "/sensor?access_token=mqv_me", "mqv_me", http.StatusNoContent},
+		{"query token on a POST", http.MethodPost, "/correction?access_token=mqv_me", "mqv_me", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := do(tt.method, tt.path, tt.token)
+		if w.Code != tt.code {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body)
+		}
+		if tt.code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+			t.Fatalf("%s: WWW-Authenticate = %q", tt.name, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// The phone has used 1 of its 2 requests a minute.
+	if w := do(http.MethodGet, "/sensor", "mqv_phone"); w.Code != http.StatusNoContent {
+		t.Fatalf("second request: status %d", w.Code)
+	}
+	w := do(http.MethodGet, "/sensor", "mqv_phone")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("over the rate limit: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := do(http.MethodGet, "/sensor", "mqv_me"); w.Code != http.StatusNoContent {
+		t.Fatalf("other token limited: status %d", w.Code)
+	}
+	clock.Advance(30 * time.Second)
+	if w := do(http.MethodGet, "/sensor", "mqv_phone"); w.Code != http.StatusNoContent {
+		t.Fatalf("after waiting: status %d", w.Code)
+	}
+}
+
+func TestAuthWithoutTokens(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	auth, _ := NewAuth(nil, nil)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/sensor", auth.Require(scopeRead), ok)
+	router.POST("/correction", auth.Require(scopeAdmin), ok)
+	for _, tt := range []struct {
+		method, path, token string
+		code                int
+	}{
+		{http.MethodGet, "/sensor", "", http.StatusNoContent},
+		{http.MethodGet, "/sensor", "stray", http.StatusNoContent},
+		{http.MethodPost, "/correction", "", http.StatusForbidden},
+		{http.MethodPost, "/correction", "guess", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Fatalf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestAPITokenValidate(t *testing.T) {
+	t.Parallel()
+
+	good := APIToken{Name: "phone", Hash: hashToken("mqv_phone"), Scopes: []string{scopeRead}}
+	if err := good.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for _, tt := range []APIToken{
+		{Hash: good.Hash, Scopes: good.Scopes},
+		{Name: "plain", Hash: "mqv_phone", Scopes: good.Scopes},
+		{Name: "short", Hash: "sha256:abcd", Scopes: good.Scopes},
+		{Name: "unscoped", Hash: good.Hash},
+		{Name: "root", Hash: good.Hash, Scopes: []string{"root"}},
+		{Name: "negative", Hash: good.Hash, Scopes: good.Scopes, RateLimit: -1},
+	} {
+		if err := tt.Validate(); err == nil {
+			t.Fatalf("%+v.Validate() succeeded", tt)
+		}
+	}
+	if token, err := generateToken(); err != nil || !strings.HasPrefix(token, "mqv_") || len(token) < 40 {
+		t.Fatalf("generateToken = %q, %v", token, err)
+	}
+
+	// The plain admin token of older configs is refused.
+	var c Config
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Config.Validate: %v", err)
+	}
+	c.API.Token = "secret"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "api.tokens") {
+		t.Fatalf("Config.Validate with api.token = %v, want the migration", err)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// sinceBaseline returns the consumption of meterID since b, derived from
+// its history in s, if any, with the correction of t, if any. Without
+// history, or if the history cannot be loaded, it counts from b with the
+// readings to come.
+func sinceBaseline(ctx context.Context, s store.Store, m genai.Meter, t *billing.Tariff, meterID string, b billing.Baseline) *billing.Since {
+	var tariff billing.Tariff // without one consumption is left uncorrected
+	if t != nil {
+		tariff = *t
+	}
+	var rs []*genai.GasMeterReadResult
+	if s != nil {
+		var err error
+		if rs, err = s.ReadingsBetween(ctx, meterID, b.At, time.Now()); err != nil {
+			log.Printf("Error loading the history since the baseline: %v", err)
+		}
+	}
+	return tariff.SinceBaseline(m, b, rs)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/bench"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// runBench implements the `bench models` subcommand: it reads the labeled
+// images of a manifest with each model and reports how well every model
+// did. The answers are cached next to the manifest, so that an interrupted
+// run resumes and a run with one more model only reads with that one. The
+// clients are stateless and share one rate limiter.
+func runBench(args []string) error {
+	if len(args) == 0 || args[0] != "models" {
+		return fmt.Errorf("usage: %s bench models [flags]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("bench models", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	manifestFile := fs.String("manifest", "", "Manifest of the labeled images")
+	modelList := fs.String("models", "", "Comma-separated models to evaluate (default: config)")
+	cacheFile := fs.String("cache", "", "Cache of the answers (default: the manifest with .cache.jsonl); \"off\" disables it")
+	interval := fs.Duration("interval", time.Second, "Minimum interval between API calls across all models")
+	format := fs.String("format", "markdown", "Report format: markdown or json")
+	output := fs.String("o", "", "Write the report to this file (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench models -manifest eval.yaml -models a,b [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if *manifestFile == "" {
+		fs.Usage()
+		return fmt.Errorf("bench models: needs -manifest")
+	}
+	if _, err := (&bench.Report{}).Render(*format); err != nil {
+		return err
+	}
+
+	base, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	m, err := bench.LoadManifest(*manifestFile)
+	if err != nil {
+		return err
+	}
+	// Every model reads with the configured prompts, so they share a hash.
+	prompts, err := genai.NewPrompts(genai.NewOptions(base.GenAIOptions()...), base.SystemPrompt, base.Prompt)
+	if err != nil {
+		return fmt.Errorf("prompts: %w", err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(*modelList, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{base.OpenAICompat.Model}
+	}
+
+	var cache *bench.Cache
+	switch *cacheFile {
+	case "off":
+	case "":
+		*cacheFile = strings.TrimSuffix(*manifestFile, filepath.Ext(*manifestFile)) + ".cache.jsonl"
+		fallthrough
+	default:
+		if cache, err = bench.OpenCache(*cacheFile); err != nil {
+			return err
+		}
+		defer cache.Close()
+		log.Printf("Caching answers in %s (%d so far)", *cacheFile, cache.Len())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	limiter := genai.NewLimiter(*interval)
+	var models []bench.Model
+	for _, name := range names {
+		rec := &bench.Recorder{}
+		c, err := newCompareVariant(ctx, *base, "", "", name, limiter, genai.WithAuditor(rec))
+		if err != nil {
+			return fmt.Errorf("model %s: %w", name, err)
+		}
+		defer c.Close()
+		models = append(models, bench.Model{Name: name, PromptHash: prompts.Hash, Client: c, Recorder: rec})
+	}
+
+	outs, err := bench.Run(ctx, m, models, cache, func(o bench.Outcome, cached bool) {
+		got := o.Read
+		if o.Error != "" {
+			got = "error: " + genai.Truncate(o.Error, 80)
+		}
+		if cached {
+			got += " (cached)"
+		}
+		log.Printf("%s %s: %s", o.Model, o.Image, got)
+	})
+	interrupted := errors.Is(err, context.Canceled)
+	if err != nil && !interrupted {
+		return err
+	}
+
+	report := bench.NewReport(m, names, outs)
+	s, err := report.Render(*format)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.WriteString(w, s); err != nil {
+		return err
+	}
+	if interrupted || !report.Complete() {
+		log.Printf("Not every image was read; run again to resume")
+	}
+	return nil
+}
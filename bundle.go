@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/bundle"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// runBundle implements the `bundle` subcommand: it packs the evidence of a
+// reading, or of the readings of a period, into a zip for a dispute over a
+// bill; see package bundle. `bundle verify` checks such a zip.
+func runBundle(args []string) error {
+	if len(args) > 0 && args[0] == "verify" {
+		return runBundleVerify(args[1:])
+	}
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	id := fs.String("id", "", "ID of the reading to bundle")
+	fromFlag := fs.String("from", "", "First day of the period to bundle (YYYY-MM-DD), instead of -id")
+	toFlag := fs.String("to", "", "Day after the period (YYYY-MM-DD, default: now)")
+	out := fs.String("o", "", "Zip file to write; it must not exist (default: bundle-<id or period>.zip)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bundle [-id ID | -from YYYY-MM-DD [-to YYYY-MM-DD]] [flags]\n       %s bundle verify FILE.zip\n", os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if (*id == "") == (*fromFlag == "") {
+		fs.Usage()
+		return errors.New("bundle: -id or -from")
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("bundle: needs store.path")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	var from, to time.Time
+	if *fromFlag != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, *fromFlag, time.Local); err != nil {
+			return fmt.Errorf("parse -from: %w", err)
+		}
+		to = time.Now()
+		if *toFlag != "" {
+			if to, err = time.ParseInLocation(time.DateOnly, *toFlag, time.Local); err != nil {
+				return fmt.Errorf("parse -to: %w", err)
+			}
+		}
+		if !to.After(from) {
+			return fmt.Errorf("empty period %s - %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+		}
+	}
+	if *out == "" {
+		*out = "bundle-" + *id + ".zip"
+		if *id == "" {
+			*out = "bundle-" + from.Format(time.DateOnly) + "-" + to.Format(time.DateOnly) + ".zip"
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	rs, err := bundleReadings(ctx, s, *meterID, *id, from, to)
+	if err != nil {
+		return err
+	}
+	c := bundle.Collector{AuditPath: config.Audit.Path}
+	if config.Archive != nil {
+		if c.Images, err = archive.New(ctx, *config.Archive); err != nil {
+			return fmt.Errorf("open archive: %w", err)
+		}
+	}
+
+	// Never overwrite a bundle: it may already be handed over.
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("create bundle: %w", err)
+	}
+	w := bundle.NewWriter(f, *meterID, time.Now())
+	for _, r := range rs {
+		if err = c.Add(ctx, w, *meterID, r); err != nil {
+			break
+		}
+	}
+	var sum string
+	if err == nil {
+		sum, err = w.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	rep, err := verifyBundleFile(*out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d readings in %d files to %s, manifest SHA-256 %s\n", len(rep.Manifest.Readings), len(rep.Manifest.Files), *out, sum)
+	printMissing(rep.Manifest)
+	return nil
+}
+
+// bundleReadings returns the reading of meterID with ID id, or, without an
+// id, its readings in [from, to).
+func bundleReadings(ctx context.Context, s store.Store, meterID, id string, from, to time.Time) ([]*genai.GasMeterReadResult, error) {
+	if id == "" {
+		rs, err := s.ReadingsBetween(ctx, meterID, from, to)
+		if err == nil && len(rs) == 0 {
+			err = fmt.Errorf("no readings of meter %s from %s to %s", meterID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+		}
+		return rs, err
+	}
+	rs, err := s.ReadingsBetween(ctx, meterID, time.Time{}, time.Now().AddDate(100, 0, 0))
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	for _, r := range rs {
+		if r.ID == id || r.ID == "" && genai.ReadingID(meterID, r) == id {
+			return []*genai.GasMeterReadResult{r}, nil
+		}
+	}
+	return nil, fmt.Errorf("reading %s of meter %s: %w", id, meterID, store.ErrNotFound)
+}
+
+// runBundleVerify implements `bundle verify`: it hashes the files of a
+// bundle again and prints every mismatch with the manifest.
+func runBundleVerify(args []string) error {
+	fs := flag.NewFlagSet("bundle verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bundle verify FILE.zip\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("bundle verify: needs a file")
+	}
+	rep, err := verifyBundleFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m := rep.Manifest
+	fmt.Printf("Bundle of %d readings of meter %s, created %s, manifest SHA-256 %s\n", len(m.Readings), m.MeterID, m.Created.Format(time.RFC3339), rep.ManifestHash)
+	printMissing(m)
+	for _, p := range rep.Problems {
+		fmt.Printf("MISMATCH %s\n", p)
+	}
+	if !rep.OK() {
+		return fmt.Errorf("bundle verify: %d mismatches", len(rep.Problems))
+	}
+	fmt.Printf("All %d files match\n", len(m.Files))
+	return nil
+}
+
+func verifyBundleFile(name string) (bundle.Report, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return bundle.Report{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return bundle.Report{}, err
+	}
+	return bundle.Verify(f, fi.Size())
+}
+
+// printMissing prints the artifacts m lists as missing.
+func printMissing(m bundle.Manifest) {
+	for _, miss := range m.Missing {
+		fmt.Printf("MISSING %s %s: %s\n", miss.Reading, miss.Artifact, miss.Reason)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/mqttdump"
+	"github.com/suapapa/mqvision/internal/picam"
+	"github.com/suapapa/mqvision/internal/quality"
+	"github.com/suapapa/mqvision/internal/roi"
+)
+
+// runCalibrate implements the `calibrate` subcommand for setting up a camera:
+// it scores a test shot, from a file, the camera attached to the host or the
+// next image on the MQTT topic, and asks the model once for the reading, the
+// counter box and the serial number. With -exposures the attached camera
+// takes a shot at each and the best one is read. It prints what it found as
+// config settings to paste. Nothing is stored and the reading is not taken
+// as the previous one.
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	imageFile := fs.String("image", "", "JPEG image to test (default: the next image on mqtt.topic)")
+	timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for an MQTT image")
+	shareSafe := fs.Bool("share-safe", false, "Redact the serial number, meter ID and image source in the output, for sharing it")
+	exposures := fs.String("exposures", "", "Comma-separated shutter times to sweep with the attached camera, e.g. 10ms,20ms,40ms")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s calibrate [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cal := &calibration{Source: *imageFile, Meter: config.GenAIMeter(), Padding: config.ROI.Padding}
+	primary := config.SourceConfigs()[0]
+	var jpg []byte
+	switch {
+	case *exposures != "":
+		if primary.Camera == nil {
+			return fmt.Errorf("-exposures needs a camera attached to the host as the first source")
+		}
+		shutters, err := parseDurations(*exposures)
+		if err != nil {
+			return fmt.Errorf("parse -exposures: %w", err)
+		}
+		if jpg, err = sweepExposures(ctx, cal, primary, shutters); err != nil {
+			return err
+		}
+	case *imageFile != "":
+		if jpg, err = os.ReadFile(*imageFile); err != nil {
+			return fmt.Errorf("read image: %w", err)
+		}
+	case primary.Camera != nil:
+		cam, err := picam.New(*primary.Camera, nil, nil)
+		if err != nil {
+			return fmt.Errorf("open camera: %w", err)
+		}
+		defer cam.Close()
+		fmt.Fprintf(os.Stderr, "Capturing with the camera of %s...\n", primary.Name)
+		if jpg, err = cam.Capture(ctx); err != nil {
+			return fmt.Errorf("capture: %w", err)
+		}
+		cal.Source = "camera:" + primary.Name
+	default:
+		topic := primary.Topic
+		fmt.Fprintf(os.Stderr, "Waiting for an image on %s...\n", topic)
+		if jpg, err = captureMQTT(ctx, config.MQTT.Host, topic, *timeout); err != nil {
+			return fmt.Errorf("capture: %w", err)
+		}
+		cal.Source = "mqtt:" + topic
+	}
+	if jpg, err = decodeImage(config, jpg); err != nil {
+		return err
+	}
+	if cal.Scores, err = quality.Measure(jpg); err != nil {
+		return err
+	}
+
+	client, err := newVisionClient(ctx, config,
+		genai.WithStateless(), genai.WithCounterBox(), genai.WithReadSerial(), genai.WithSerialCheck(false))
+	if err != nil {
+		return fmt.Errorf("create vision client: %w", err)
+	}
+	defer client.Close()
+	cal.Result, cal.Err = client.ReadGasGaugePic(ctx, bytes.NewReader(jpg))
+
+	var out io.Writer = os.Stdout
+	if *shareSafe {
+		if out, err = shareSafeOutput(config, out, cal.Result); err != nil {
+			return err
+		}
+	}
+	if _, err := cal.WriteTo(out); err != nil {
+		return err
+	}
+	if cal.Err != nil {
+		return fmt.Errorf("read image: %w", cal.Err)
+	}
+	return nil
+}
+
+// sweepExposures takes a shot with the camera of src at each of shutters,
+// with its configured gain, into cal and returns the best one.
+func sweepExposures(ctx context.Context, cal *calibration, src SourceConfig, shutters []time.Duration) ([]byte, error) {
+	cam, err := picam.New(*src.Camera, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open camera: %w", err)
+	}
+	defer cam.Close()
+	var best []byte
+	for _, d := range shutters {
+		shot := exposureShot{Exposure: picam.Exposure{Shutter: d, Gain: src.Camera.Gain}}
+		fmt.Fprintf(os.Stderr, "Capturing with the camera of %s at %s...\n", src.Name, shot.Exposure)
+		jpg, err := cam.CaptureWith(ctx, shot.Exposure)
+		if err == nil {
+			shot.Scores, err = quality.Measure(jpg)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		shot.Err = err
+		cal.Sweep = append(cal.Sweep, shot)
+		if bestExposure(cal.Sweep) == len(cal.Sweep)-1 {
+			best = jpg
+		}
+	}
+	i := bestExposure(cal.Sweep)
+	if i < 0 {
+		return nil, fmt.Errorf("no exposure captured: %w", cal.Sweep[len(cal.Sweep)-1].Err)
+	}
+	cal.Source = fmt.Sprintf("camera:%s at %s", src.Name, cal.Sweep[i].Exposure)
+	return best, nil
+}
+
+// exposureShot is a shot of an exposure sweep.
+type exposureShot struct {
+	Exposure picam.Exposure
+	Scores   quality.Scores
+	Err      error
+}
+
+// bestExposure returns the index of the best shot of sweep: the one with
+// the fewest quality problems, then the sharpest; -1 if all failed.
+func bestExposure(sweep []exposureShot) int {
+	best, bestProblems := -1, 0
+	for i, s := range sweep {
+		if s.Err != nil {
+			continue
+		}
+		problems := len(quality.DefaultGate.Check(s.Scores))
+		if best < 0 || problems < bestProblems || problems == bestProblems && s.Scores.Sharpness > sweep[best].Scores.Sharpness {
+			best, bestProblems = i, problems
+		}
+	}
+	return best
+}
+
+// parseDurations parses a comma-separated list of durations.
+func parseDurations(s string) ([]time.Duration, error) {
+	var ds []time.Duration
+	for _, f := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("%s is not a shutter time", d)
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// captureMQTT returns the next image published on topic at host.
+func captureMQTT(ctx context.Context, host, topic string, timeout time.Duration) ([]byte, error) {
+	c, err := mqttdump.NewClient(host, topic)
+	if err != nil {
+		return nil, err
+	}
+	images := make(chan []byte, 1)
+	if err := c.Run(func() io.WriteCloser { return &captureWriter{images: images} }); err != nil {
+		return nil, err
+	}
+	defer c.Stop()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case jpg := <-images:
+		return jpg, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no image on %s within %s", topic, timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// captureWriter hands the image written to it to images when closed,
+// unless one is there already.
+type captureWriter struct {
+	bytes.Buffer
+	images chan<- []byte
+}
+
+func (w *captureWriter) Close() error {
+	select {
+	case w.images <- w.Bytes():
+	default:
+	}
+	return nil
+}
+
+// calibration is what the calibrate command found out about a test shot.
+type calibration struct {
+	Source string
+	Scores quality.Scores
+	// Sweep is the exposure sweep the test shot is the best of, if any.
+	Sweep []exposureShot
+	// Result is the model's answer, or Err why there is none.
+	Result *genai.GasMeterReadResult
+	Err    error
+	// Meter and Padding are the configured meter and roi.padding.
+	Meter   genai.Meter
+	Padding float64
+}
+
+// WriteTo writes c as YAML: the findings as comments, then the settings
+// they suggest.
+func (c *calibration) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if len(c.Sweep) > 0 {
+		b.WriteString("# Exposure sweep:\n")
+		best := bestExposure(c.Sweep)
+		for i, s := range c.Sweep {
+			if s.Err != nil {
+				fmt.Fprintf(&b, "#   %s: %v\n", s.Exposure, s.Err)
+				continue
+			}
+			verdict := "ok"
+			if problems := quality.DefaultGate.Check(s.Scores); len(problems) > 0 {
+				verdict = strings.Join(problems, ", ")
+			}
+			if i == best {
+				verdict += " (best)"
+			}
+			fmt.Fprintf(&b, "#   %s: brightness %.2f, sharpness %.1f, %s\n", s.Exposure, s.Scores.Brightness, s.Scores.Sharpness, verdict)
+		}
+		if best >= 0 {
+			fmt.Fprintf(&b, "#   set shutter: %s in the camera of the source\n", c.Sweep[best].Exposure.Shutter)
+		}
+	}
+	fmt.Fprintf(&b, "# Test shot %s: %dx%d\n", c.Source, c.Scores.Width, c.Scores.Height)
+	fmt.Fprintf(&b, "# Brightness %.2f, sharpness %.1f\n", c.Scores.Brightness, c.Scores.Sharpness)
+	problems := quality.DefaultGate.Check(c.Scores)
+	for _, p := range problems {
+		fmt.Fprintf(&b, "#   %s\n", p)
+	}
+	if len(problems) == 0 {
+		b.WriteString("#   image quality ok\n")
+	}
+	if c.Err != nil {
+		fmt.Fprintf(&b, "# Reading failed: %v\n", c.Err)
+		n, err := io.WriteString(w, b.String())
+		return int64(n), err
+	}
+
+	r := c.Result
+	fmt.Fprintf(&b, "# Read %q", r.Read)
+	if r.Model != "" {
+		fmt.Fprintf(&b, " with %s", r.Model)
+	}
+	if r.Issue != nil {
+		fmt.Fprintf(&b, ", image issue: %s", r.Issue)
+	}
+	b.WriteString("\n")
+	intDigits, fracDigits := digitFormat(r.Read)
+	if intDigits != c.Meter.IntDigits || fracDigits != c.Meter.FracDigits {
+		fmt.Fprintf(&b, "#   the config has %s instead\n", c.Meter.Pattern())
+	}
+	serial := strings.TrimSpace(r.SerialNumber)
+	switch {
+	case serial == "":
+		b.WriteString("# No serial number read\n")
+	case c.Meter.Serial == "":
+		fmt.Fprintf(&b, "# Serial number %q; meter.serial is not set\n", serial)
+	case genai.CheckSerial(c.Meter, serial) == nil:
+		fmt.Fprintf(&b, "# Serial number %q matches meter.serial\n", serial)
+	default:
+		fmt.Fprintf(&b, "# Serial number %q does not match meter.serial %q\n", serial, c.Meter.Serial)
+	}
+	box := r.CounterBox
+	if box == nil {
+		b.WriteString("# No counter box found\n")
+	} else {
+		pad := c.Padding
+		if pad <= 0 {
+			pad = roi.DefaultPadding
+		}
+		crop := box.Pad(pad)
+		w, h := float64(c.Scores.Width), float64(c.Scores.Height)
+		fmt.Fprintf(&b, "# Counter box with roi.padding %.2f: crop %.0fx%.0f at (%.0f, %.0f)\n",
+			pad, crop.Width()*w, crop.Height()*h, crop.XMin*w, crop.YMin*h)
+	}
+
+	b.WriteString("meter:\n")
+	fmt.Fprintf(&b, "  int_digits: %d\n  frac_digits: %d\n", intDigits, fracDigits)
+	if serial != "" && !strings.Contains(serial, "?") {
+		fmt.Fprintf(&b, "  serial: %q\n", serial)
+	}
+	if box != nil {
+		fmt.Fprintf(&b, "roi:\n  box: {x_min: %.3f, y_min: %.3f, x_max: %.3f, y_max: %.3f}\n", box.XMin, box.YMin, box.XMax, box.YMax)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// digitFormat returns the digits of read before and after the decimal point,
+// counting unreadable ones ("?").
+func digitFormat(read string) (intDigits, fracDigits int) {
+	i, f, _ := strings.Cut(read, ".")
+	return len(i), len(f)
+}
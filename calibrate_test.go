@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/picam"
+	"github.com/suapapa/mqvision/internal/quality"
+)
+
+func TestCalibration(t *testing.T) {
+	t.Parallel()
+
+	meter := genai.DefaultMeter
+	meter.Serial = "GM2019-44113"
+	box := &genai.Box{XMin: 0.25, YMin: 0.4, XMax: 0.75, YMax: 0.5}
+	scores := quality.Scores{Width: 1000, Height: 800, Brightness: 0.5, Sharpness: 120}
+	tests := []struct {
+		name   string
+		result *genai.GasMeterReadResult
+		want   []string // in the output
+		serial string   // in the settings
+		format [2]int
+	}{
+		{"matching", &genai.GasMeterReadResult{Read: "02924.457", SerialNumber: "GM2019-44113", CounterBox: box},
+			[]string{"image quality ok", `matches meter.serial`, "crop 650x104 at (175, 308)"}, "GM2019-44113", [2]int{5, 3}},
+		{"other meter", &genai.GasMeterReadResult{Read: "1234.5?", SerialNumber: "XX1111-00000"},
+			[]string{`does not match meter.serial "GM2019-44113"`, "the config has NNNNN.NNN instead", "No counter box"}, "XX1111-00000", [2]int{4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var b strings.Builder
+			c := &calibration{Source: "test.jpg", Scores: scores, Result: tt.result, Meter: meter}
+			if _, err := c.WriteTo(&b); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			out := b.String()
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Fatalf("output lacks %q:\n%s", w, out)
+				}
+			}
+			var config Config
+			if err := yaml.Unmarshal([]byte(out), &config); err != nil {
+				t.Fatalf("output is not a config: %v\n%s", err, out)
+			}
+			if got := [2]int{config.Meter.IntDigits, config.Meter.FracDigits}; got != tt.format || config.Meter.Serial != tt.serial {
+				t.Fatalf("meter = %+v, want format %v and serial %q", config.Meter, tt.format, tt.serial)
+			}
+			if (config.ROI.Box != nil) != (tt.result.CounterBox != nil) || config.ROI.Box != nil && *config.ROI.Box != *box {
+				t.Fatalf("roi.box = %+v, want %+v", config.ROI.Box, tt.result.CounterBox)
+			}
+		})
+	}
+
+	var b strings.Builder
+	c := &calibration{Source: "dark.jpg", Scores: quality.Scores{Width: 10, Height: 10, Brightness: 0.05}, Err: errors.New("no reading"), Meter: meter}
+	c.WriteTo(&b)
+	if out := b.String(); !strings.Contains(out, "too dark") || !strings.Contains(out, "# Reading failed: no reading") || strings.Contains(out, "meter:") {
+		t.Fatalf("failed calibration:\n%s", out)
+	}
+}
+
+func TestExposureSweep(t *testing.T) {
+	t.Parallel()
+
+	shot := func(d time.Duration, brightness, sharpness float64) exposureShot {
+		return exposureShot{Exposure: picam.Exposure{Shutter: d}, Scores: quality.Scores{Width: 10, Height: 10, Brightness: brightness, Sharpness: sharpness}}
+	}
+	sweep := []exposureShot{
+		shot(5*time.Millisecond, 0.08, 200), // too dark, however sharp
+		shot(10*time.Millisecond, 0.35, 90),
+		shot(20*time.Millisecond, 0.6, 110),
+		shot(40*time.Millisecond, 0.95, 150), // too bright
+		{Exposure: picam.Exposure{Shutter: 80 * time.Millisecond}, Err: errors.New("camera capture timed out after 30s")},
+	}
+	if got := bestExposure(sweep); got != 2 {
+		t.Fatalf("bestExposure = %d, want 2", got)
+	}
+	if got := bestExposure(sweep[4:]); got != -1 {
+		t.Fatalf("bestExposure of failed shots = %d, want -1", got)
+	}
+
+	var b strings.Builder
+	c := &calibration{Source: "camera:front at 20ms", Scores: sweep[2].Scores, Sweep: sweep, Err: errors.New("no reading"), Meter: genai.DefaultMeter}
+	c.WriteTo(&b)
+	for _, want := range []string{
+		"#   5ms: brightness 0.08, sharpness 200.0, too dark: brightness 0.08 below 0.15\n",
+		"#   20ms: brightness 0.60, sharpness 110.0, ok (best)\n",
+		"#   80ms: camera capture timed out after 30s\n",
+		"#   set shutter: 20ms in the camera of the source\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("output lacks %q:\n%s", want, b.String())
+		}
+	}
+
+	if _, err := parseDurations("10ms, 20ms,40ms"); err != nil {
+		t.Fatalf("parseDurations: %v", err)
+	}
+	if _, err := parseDurations("10ms,0s"); err == nil {
+		t.Fatalf("parseDurations accepted a zero shutter")
+	}
+}
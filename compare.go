@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// runCompare implements the `compare` subcommand: it reads an image (or every
+// JPEG in a directory) with two prompt/model variants and prints the
+// per-image comparison followed by a summary.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	systemA := fs.String("system-a", "", "System prompt file for variant A (default: config)")
+	promptA := fs.String("prompt-a", "", "Image prompt file for variant A (default: config)")
+	modelA := fs.String("model-a", "", "Model for variant A (default: config)")
+	systemB := fs.String("system-b", "", "System prompt file for variant B (default: config)")
+	promptB := fs.String("prompt-b", "", "Image prompt file for variant B (default: config)")
+	modelB := fs.String("model-b", "", "Model for variant B (default: config)")
+	interval := fs.Duration("interval", time.Second, "Minimum interval between API calls across both variants")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare [flags] <image.jpg|dir>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one image file or directory")
+	}
+
+	base, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	limiter := genai.NewLimiter(*interval)
+	a, err := newCompareVariant(ctx, *base, *systemA, *promptA, *modelA, limiter)
+	if err != nil {
+		return fmt.Errorf("variant A: %w", err)
+	}
+	defer a.Close()
+	b, err := newCompareVariant(ctx, *base, *systemB, *promptB, *modelB, limiter)
+	if err != nil {
+		return fmt.Errorf("variant B: %w", err)
+	}
+	defer b.Close()
+
+	images, err := listImages(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	var cmps []*genai.Comparison
+	for _, img := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		jpg, err := os.ReadFile(img)
+		if err != nil {
+			return fmt.Errorf("read image: %w", err)
+		}
+		cmp := genai.Compare(ctx, a, b, jpg)
+		cmp.Image = img
+		cmps = append(cmps, cmp)
+		if err := enc.Encode(cmp); err != nil {
+			return err
+		}
+	}
+
+	return enc.Encode(genai.Summarize(cmps))
+}
+
+// newCompareVariant builds a stateless client from config c with the given
+// overrides and options.
+func newCompareVariant(ctx context.Context, c Config, systemFile, promptFile, model string, l *genai.Limiter, opts ...genai.Option) (genai.VisionClient, error) {
+	if systemFile != "" {
+		b, err := os.ReadFile(systemFile)
+		if err != nil {
+			return nil, fmt.Errorf("read system prompt: %w", err)
+		}
+		c.SystemPrompt = string(b)
+	}
+	if promptFile != "" {
+		b, err := os.ReadFile(promptFile)
+		if err != nil {
+			return nil, fmt.Errorf("read image prompt: %w", err)
+		}
+		c.Prompt = string(b)
+	}
+	if model != "" {
+		c.OpenAICompat.Model = model
+	}
+	return newVisionClient(ctx, &c, append([]genai.Option{genai.WithStateless(), genai.WithRateLimiter(l)}, opts...)...)
+}
+
+// listImages returns path itself, or the JPEG files in path if it is a directory.
+func listImages(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.IsDir() && (ext == ".jpg" || ext == ".jpeg") {
+			images = append(images, filepath.Join(path, e.Name()))
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
@@ -1,10 +1,38 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/anomaly"
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/concierge"
+	"github.com/suapapa/mqvision/internal/downsample"
+	"github.com/suapapa/mqvision/internal/egress"
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/export"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/imagefmt"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/ocr"
+	"github.com/suapapa/mqvision/internal/pushgateway"
+	"github.com/suapapa/mqvision/internal/quality"
+	"github.com/suapapa/mqvision/internal/redact"
+	"github.com/suapapa/mqvision/internal/report"
+	"github.com/suapapa/mqvision/internal/roi"
+	"github.com/suapapa/mqvision/internal/route"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/validate"
 )
 
 // Config holds YAML-loaded settings for MQTT, concierge, Gemini, and OpenAI-compatible backends.
@@ -17,6 +45,50 @@ type Config struct {
 		Addr  string `yaml:"addr"`
 		Token string `yaml:"token"`
 	} `yaml:"concierge"`
+	Meter struct {
+		ID         string `yaml:"id"`
+		Utility    string `yaml:"utility"` // gas (default), water or electricity
+		Type       string `yaml:"type"`    // counter (default) or dials
+		IntDigits  int    `yaml:"int_digits"`
+		FracDigits int    `yaml:"frac_digits"`
+		Unit       string `yaml:"unit"`
+		// Serial is the serial number printed on the meter; readings of a
+		// meter with another serial are rejected.
+		Serial         string `yaml:"serial"`
+		SerialMinMatch int    `yaml:"serial_min_match"`
+		// Normalize cleans up readings before they are validated; see
+		// [genai.Normalization].
+		Normalize struct {
+			Units            []string `yaml:"units"`
+			DecimalSeparator string   `yaml:"decimal_separator"`
+			InsertDot        bool     `yaml:"insert_dot"`
+		} `yaml:"normalize"`
+		// Validators decide, in order, whether a reading is accepted; see
+		// [validate.Config]. Default: format.
+		Validators []validate.Config `yaml:"validators"`
+		// Seed is the reading to start from, e.g. after installing the camera;
+		// without it the latest reading in Store is used.
+		Seed struct {
+			Read string    `yaml:"read"`
+			At   time.Time `yaml:"at"`
+		} `yaml:"seed"`
+		// Routing reads some cycles with another model; see [route.Config].
+		Routing route.Config `yaml:"routing"`
+		// Baseline is the reading the contract started from: the consumption
+		// since then goes with every reading, to the sinks, the dashboard and
+		// the reports. It is derived from the history, so changing it changes
+		// no stored reading; see [billing.Since].
+		Baseline *billing.Baseline `yaml:"baseline"`
+		// Maintenance puts the meter in maintenance mode, as while it is
+		// exchanged, from Since until Until; the maintenance command and API
+		// begin and end it on demand. See [maintenance.Config].
+		Maintenance *maintenance.Config `yaml:"maintenance"`
+	} `yaml:"meter"`
+	// Examples are few-shot images of the same meter model with their known reading.
+	Examples []struct {
+		Path string `yaml:"path"`
+		Read string `yaml:"read"`
+	} `yaml:"examples"`
 	// Gemini struct {
 	// 	APIKey string `yaml:"api_key"`
 	// 	Model  string `yaml:"model"`
@@ -26,8 +98,296 @@ type Config struct {
 		APIKey  string `yaml:"api_key"`
 		Model   string `yaml:"model"`
 	} `yaml:"openai_compat"`
+	// Audit enables the JSONL audit log of all model calls when Path is set.
+	Audit struct {
+		Path        string `yaml:"path"`
+		MaxSizeMB   int    `yaml:"max_size_mb"`
+		MaxBackups  int    `yaml:"max_backups"`
+		OmitPrompts bool   `yaml:"omit_prompts"`
+	} `yaml:"audit"`
+	// Redact masks the Fields (serial_number, meter_id, source_image) in the
+	// logs, the audit log and what the Sinks, receivers as in
+	// subscriptions, are sent, keeping a short suffix for correlation; see
+	// [redact.Redactor].
+	Redact struct {
+		Fields []string `yaml:"fields"`
+		Sinks  []string `yaml:"sinks"`
+	} `yaml:"redact"`
+	// Breaker stops API calls after Failures consecutive failed readings for OpenFor.
+	Breaker struct {
+		Failures int           `yaml:"failures"`
+		OpenFor  time.Duration `yaml:"open_for"`
+	} `yaml:"breaker"`
+	// StaleFallback republishes the last reading, flagged stale, while the breaker is open.
+	StaleFallback bool `yaml:"stale_fallback"`
+	// Retries retries a failed reading call within the same reading, with the
+	// FallbackModels in turn and then the model of the failed call.
+	Retries        int      `yaml:"retries"`
+	FallbackModels []string `yaml:"fallback_models"`
+	// Upload retries a failed image upload to the Files API Retries times,
+	// each attempt cut off after AttemptTimeout, from a copy of the image in
+	// memory.
+	Upload struct {
+		Retries        int           `yaml:"retries"`
+		AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	} `yaml:"upload"`
+	// Timeouts bound every reading: Call the whole of it, Upload the image
+	// upload with its retries, Generate each call to a model and Guess the
+	// disambiguation call, each within what is left of Call (0: no bound of
+	// its own).
+	Timeouts struct {
+		Call     time.Duration `yaml:"call"`
+		Upload   time.Duration `yaml:"upload"`
+		Generate time.Duration `yaml:"generate"`
+		Guess    time.Duration `yaml:"guess"`
+	} `yaml:"timeouts"`
+	// Generation bounds the thinking and the output of every model call;
+	// unset fields take the defaults of the model, see [genai.Generation].
+	Generation struct {
+		ThinkingBudget  *int   `yaml:"thinking_budget"`   // Gemini; 0: off, -1: the model decides
+		ReasoningEffort string `yaml:"reasoning_effort"`  // OpenAI reasoning models
+		MaxOutputTokens int    `yaml:"max_output_tokens"` // -1: no limit
+	} `yaml:"generation"`
+	// Ensemble cross-checks every reading with several models when Models is set.
+	Ensemble struct {
+		Models  []string `yaml:"models"`
+		Policy  string   `yaml:"policy"` // exact (default), epsilon or majority
+		Epsilon float64  `yaml:"epsilon"`
+	} `yaml:"ensemble"`
+	// Anomaly flags unusually high consumption when Factor or Absolute is set; needs Store.
+	Anomaly struct {
+		Factor     float64 `yaml:"factor"`
+		Absolute   float64 `yaml:"absolute"`
+		WindowDays int     `yaml:"window_days"`
+		MinSamples int     `yaml:"min_samples"`
+	} `yaml:"anomaly"`
+	// Leak warns about flow during an idle window (e.g. "02:00-05:00") on
+	// consecutive nights when Window is set; needs Store.
+	Leak struct {
+		Window    string  `yaml:"window"`
+		Threshold float64 `yaml:"threshold"`
+		Nights    int     `yaml:"nights"`
+		Timezone  string  `yaml:"timezone"`
+		// Flow, when enabled, has the model compare two photos of the flow
+		// indicator taken Interval apart (default 5s), every Every (default
+		// 1h) during the window, and lists the outcome with a leak; needs a
+		// source that captures on request.
+		Flow struct {
+			Enabled  bool          `yaml:"enabled"`
+			Interval time.Duration `yaml:"interval"`
+			Every    time.Duration `yaml:"every"`
+		} `yaml:"flow"`
+	} `yaml:"leak"`
+	// Digest notifies the report of every finished day, week or month when
+	// Period is set; needs Store.
+	Digest struct {
+		Period string `yaml:"period"`
+	} `yaml:"digest"`
+	// Gaps marks a reading taken more than Threshold after the previous
+	// stored one with gap_before and notifies a gap event. Attribution is how
+	// series, reports and stats count the consumption across such a gap:
+	// spread (default) evenly over it, or end, all at the reading after it.
+	Gaps struct {
+		Threshold   time.Duration `yaml:"threshold"`
+		Attribution string        `yaml:"attribution"`
+	} `yaml:"gaps"`
+	// Report sets how consumption is split into periods for reports, digests
+	// and stats: MaxGap is the longest gap between readings interpolated
+	// across at a period boundary (0: no limit).
+	Report struct {
+		MaxGap time.Duration `yaml:"max_gap"`
+	} `yaml:"report"`
+	// Anchor designates the daily anchor reading, the value at a fixed
+	// local time of each day, when set; needs Store. The anchors are saved
+	// with the history, recomputed as readings are saved or corrected, and
+	// listed in reports and digests; see [anchor.Config].
+	Anchor *anchor.Config `yaml:"anchor"`
+	// Downsample thins out the old history to one reading an hour, and then
+	// one a day, when set; needs Store. The daemon runs it at the start and
+	// every Every, the downsample command on demand; see [downsample.Config].
+	Downsample *downsample.Config `yaml:"downsample"`
+	// Email sends digests and critical alerts over SMTP when set; it is the
+	// email notifier of older configs.
+	Email *notify.EmailConfig `yaml:"email"`
+	// Notifiers deliver events besides the log: each is a registered
+	// notifier (log, email, or one added with [notify.Register]) with its
+	// options, subscribed to events under its ID; see [notify.Config].
+	Notifiers []notify.Config `yaml:"notifiers"`
+	// Tariff prices consumption in the stats and report commands when set.
+	Tariff *billing.Tariff `yaml:"tariff"`
+	// ROI asks the model where the counter is when Learn is set and, once the
+	// position is stable, crops captures to it; the region is kept in Store.
+	// Freeze stops learning, Reset starts over at the next start. Box crops
+	// to a fixed region instead of learning one, e.g. as suggested by the
+	// calibrate command. A crop covering the whole capture is only
+	// re-encoded if that makes it MinSaving (default 0.1) smaller.
+	ROI struct {
+		Learn     bool       `yaml:"learn"`
+		Padding   float64    `yaml:"padding"`
+		Samples   int        `yaml:"samples"`
+		Freeze    bool       `yaml:"freeze"`
+		Reset     bool       `yaml:"reset"`
+		Box       *genai.Box `yaml:"box"`
+		MinSaving float64    `yaml:"min_saving"`
+	} `yaml:"roi"`
+	// CrossCheck reads every image with a local recognizer too, in parallel
+	// with the model, when set: the seven-segment digits of a digital meter
+	// in Box, by default. Agreement raises the model's digit confidences; a
+	// disagreement is flagged by the cross_check validator, added to the
+	// pipeline unless listed, and with Verify the image is read again with
+	// self-verification first. See [ocr.Config].
+	CrossCheck *ocr.Config `yaml:"cross_check"`
+	// Sinks deliver every accepted reading besides /sensor, with its
+	// consumption when Tariff is set, and corrections: to standard output in
+	// the Stdout format (json, influx or keyvalue), to an InfluxDB bucket, to
+	// topics of the MQTT broker (see [sink.MQTT]), to the paths of a
+	// Webhook (see [sink.Webhook]) and to a Kafka topic (see [sink.Kafka]).
+	// Influx, Webhook and Kafka keep up to Buffer readings (default 1000)
+	// while the server is down.
+	Sinks struct {
+		Stdout  string              `yaml:"stdout"`
+		Influx  *sink.InfluxConfig  `yaml:"influx"`
+		MQTT    *sink.MQTTConfig    `yaml:"mqtt"`
+		Webhook *sink.WebhookConfig `yaml:"webhook"`
+		Kafka   *sink.KafkaConfig   `yaml:"kafka"`
+		Buffer  int                 `yaml:"buffer"`
+	} `yaml:"sinks"`
+	// Export sets the targets of the export subcommand, which pushes the
+	// hourly consumption of the history to long-term statistics: Home
+	// Assistant's.
+	Export struct {
+		HomeAssistant *export.HomeAssistantConfig `yaml:"homeassistant"`
+	} `yaml:"export"`
+	// Pushgateway is where the replay command pushes the metrics of its run
+	// (the counters of /debug/vars, the last reading, the duration and
+	// whether it succeeded) before exiting, so that a cron run can be
+	// monitored. A failed push is only logged.
+	Pushgateway *pushgateway.Config `yaml:"pushgateway"`
+	// Recapture asks the camera for a fresh photo when a reading is rejected
+	// as unreadable (format), for an image issue (quality) or for a digit
+	// read with low confidence (min_confidence), or the model reads nothing,
+	// up to Attempts times per cycle (default 2 with Topic), Delay after the
+	// failure. It publishes Payload (default "capture") to
+	// Topic and takes the next image within Timeout (default 30s) as the
+	// capture. Without Topic images are not re-captured.
+	Recapture struct {
+		Topic    string        `yaml:"topic"`
+		Payload  string        `yaml:"payload"`
+		Attempts int           `yaml:"attempts"`
+		Delay    time.Duration `yaml:"delay"`
+		Timeout  time.Duration `yaml:"timeout"`
+	} `yaml:"recapture"`
+	// Enhance, with a preset, makes a second attempt at an image of which
+	// the model could not read a single digit (an empty reading or only
+	// "?"): the image is enhanced, posted again and read once more, a call
+	// counted by the breaker like any other. A reading of the enhanced image
+	// is marked enhanced.
+	Enhance quality.Enhancement `yaml:"enhance"`
+	// Sources are the cameras of the meter in order of preference, the
+	// primary first, replacing mqtt.topic and recapture.topic; see
+	// [SourceConfig]. A reading that fails for a reason a fresh photo may fix
+	// is re-captured recapture.attempts times (default 0 with sources) and
+	// then falls through to the next source with a trigger.
+	Sources []SourceConfig `yaml:"sources"`
+	// Failover counts a source as failing when its last capture failed or it
+	// sent no image for Silence (default 1h); the images a later source
+	// publishes on its own are only read while all before it fail. Once the
+	// primary has been failing for AlertAfter (default 1h) without an
+	// accepted reading, a source_down event is sent.
+	Failover struct {
+		Silence    time.Duration `yaml:"silence"`
+		AlertAfter time.Duration `yaml:"alert_after"`
+	} `yaml:"failover"`
+	// Subscriptions select the events, and meters, each sink (stdout, influx, mqtt, webhook)
+	// and notifier (log, email, or the ID of one in Notifiers) receives.
+	// Sinks take accepted readings and their corrections by default, the
+	// notifiers failures, anomalies and digests, and the log all but the
+	// routine readings.
+	Subscriptions map[string]event.Subscription `yaml:"subscriptions"`
+	// Archive keeps every meter image, in a directory or an S3 or GCS bucket,
+	// for the replay command; see [archive.Config]. Failed uploads are
+	// retried and logged without failing the reading.
+	Archive *archive.Config `yaml:"archive"`
+	// Network is how the outbound HTTP clients reach the network, from the
+	// vision API and the concierge to the sinks, the archive, the export
+	// and the pushgateway: through a proxy, trusting a private CA, with a
+	// client certificate and a dial timeout; see [egress.Config].
+	Network egress.Config `yaml:"network"`
+	// Store keeps the reading history in a JSONL file when Path is set.
+	Store struct {
+		Path string `yaml:"path"`
+	} `yaml:"store"`
+	// API protects the endpoints with the bearer Tokens, each allowed the
+	// endpoints of its scopes; without tokens only the read endpoints are
+	// open. Token, the admin token in plain text of older configs, is
+	// refused.
+	// CORSOrigins are the origins browsers may call the API from, e.g. a
+	// Grafana; "*" is any. MaxPoints bounds the points of a series (default
+	// 5000). Expvar publishes the client's counters at /debug/vars under
+	// that name when set. Pool bounds the clients POST /v1/read keeps for
+	// the API keys of its requests: at most MaxSize (default 16), each
+	// closed once unused for Idle (default 15m).
+	API struct {
+		Token       string     `yaml:"token"`
+		Tokens      []APIToken `yaml:"tokens"`
+		CORSOrigins []string   `yaml:"cors_origins"`
+		MaxPoints   int        `yaml:"max_points"`
+		Expvar      string     `yaml:"expvar"`
+		Pool        struct {
+			MaxSize int           `yaml:"max_size"`
+			Idle    time.Duration `yaml:"idle"`
+		} `yaml:"pool"`
+	} `yaml:"api"`
+	// Readiness selects the checks of /readyz: store, reading, breaker and
+	// mqtt (default: those that apply). MaxReadingAge is how old the last
+	// successful reading may be (default 2h).
+	Readiness struct {
+		Checks        []string      `yaml:"checks"`
+		MaxReadingAge time.Duration `yaml:"max_reading_age"`
+	} `yaml:"readiness"`
+	// SelfTest reads Image, a photo of the meter showing Read, every day at
+	// At (local "HH:MM" of Timezone, default 04:00) with the prompts and
+	// options of the readings, and notifies a self_test_failed event if the
+	// answer is another or the call fails; the result is on /healthz. Its
+	// readings are not stored, published or read against.
+	SelfTest struct {
+		Image string `yaml:"image"`
+		Read  string `yaml:"read"`
+		At    string `yaml:"at"`
+	} `yaml:"self_test"`
+	// MaxImageKB rejects larger images before they are archived or read (0: no limit).
+	MaxImageKB int `yaml:"max_image_kb"`
+	// Transcode converts the images that are not JPEG, such as the HEIC
+	// photos of an iPhone, with heif-convert or ImageMagick before they are
+	// archived, checked or read; see [imagefmt.CommandConfig]. Without it
+	// they are rejected as unsupported.
+	Transcode *imagefmt.CommandConfig `yaml:"transcode"`
+	// SlowReading logs readings taking at least this long with their phases (0: never).
+	SlowReading time.Duration `yaml:"slow_reading"`
+	// SingleShot lets the model resolve uncertain digits in the reading call.
+	SingleShot bool `yaml:"single_shot"`
+	// SelfVerify asks the model to re-examine each reading in a second turn.
+	SelfVerify bool `yaml:"self_verify"`
+	// ResponseSchema sends an explicit JSON schema with each reading (default true);
+	// set it to false for backends that reject structured-output requests.
+	ResponseSchema *bool `yaml:"response_schema"`
+	// Timezone is the IANA zone (e.g. Asia/Seoul) dates without an offset are
+	// read in and date_parsed is given in (default: system zone).
+	Timezone string `yaml:"timezone"`
+	// Locale selects the built-in prompts ("en", "ko"); SystemPrompt and Prompt override them.
+	Locale string `yaml:"locale"`
+	// NumberLocale formats the numbers people read, in reports,
+	// notifications, the dashboard and the tables of the commands, e.g. "de"
+	// for "1.234,567"; stored values and machine payloads keep plain numbers.
+	// Notifiers may override it with a number_locale of their own.
+	NumberLocale string `yaml:"number_locale"`
+	// SystemPrompt and Prompt are text/templates rendered with [genai.PromptData].
 	SystemPrompt string `yaml:"system_prompt"`
 	Prompt       string `yaml:"prompt"`
+
+	// transport is the transport of Network, set by [LoadConfig]; nil is
+	// the default.
+	transport http.RoundTripper
 }
 
 // LoadConfig reads and parses a YAML configuration file into [Config].
@@ -45,5 +405,743 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, fmt.Errorf("decode config file: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config file: %w", err)
+	}
+	if err := config.useNetwork(); err != nil {
+		return nil, fmt.Errorf("network: %w", err)
+	}
+
 	return &config, nil
 }
+
+// useNetwork loads the transport of c.Network and has every outbound HTTP
+// client of c send through it, and the other clients, email and Kafka,
+// dial through its proxy.
+func (c *Config) useNetwork() error {
+	t, err := c.Network.Transport()
+	if err != nil || t == nil {
+		return err
+	}
+	c.transport = t
+	if c.Sinks.Influx != nil {
+		c.Sinks.Influx.Transport = t
+	}
+	if c.Sinks.Webhook != nil {
+		c.Sinks.Webhook.Transport = t
+	}
+	if c.Export.HomeAssistant != nil {
+		c.Export.HomeAssistant.Transport = t
+	}
+	if c.Pushgateway != nil {
+		c.Pushgateway.Transport = t
+	}
+	if c.Archive != nil {
+		c.Archive.Transport = t
+	}
+
+	// The clients that are not HTTP dial through the proxy themselves.
+	dial, err := c.Network.Dialer()
+	if err != nil {
+		return err
+	}
+	tlsCfg, err := c.Network.TLSConfig()
+	if err != nil {
+		return err
+	}
+	nw := notify.Network{Dial: dial, TLS: tlsCfg}
+	if c.Email != nil {
+		c.Email.Network = nw
+	}
+	for i := range c.Notifiers {
+		c.Notifiers[i].Network = nw
+	}
+	if c.Sinks.Kafka != nil {
+		c.Sinks.Kafka.Dial = dial
+	}
+	return nil
+}
+
+// Validate checks settings that would otherwise only fail on the first reading.
+// Prompt templates are dry-rendered with sample data.
+func (c *Config) Validate() error {
+	opts := genai.NewOptions(c.GenAIOptions()...)
+	if _, err := genai.NewPrompts(opts, c.SystemPrompt, c.Prompt); err != nil {
+		return fmt.Errorf("prompts: %w", err)
+	}
+	if _, err := numfmt.Parse(c.NumberLocale); err != nil {
+		return fmt.Errorf("number_locale: %w", err)
+	}
+	if c.Meter.Seed.Read != "" {
+		if err := genai.CheckSeed(opts.Meter, c.Meter.Seed.Read); err != nil {
+			return fmt.Errorf("meter: %w", err)
+		}
+	}
+	if b := c.Meter.Baseline; b != nil {
+		if err := b.Validate(opts.Meter); err != nil {
+			return fmt.Errorf("meter.baseline: %w", err)
+		}
+	}
+	if mc := c.Meter.Maintenance; mc != nil {
+		if err := mc.Validate(opts.Meter); err != nil {
+			return fmt.Errorf("meter.maintenance: %w", err)
+		}
+	}
+	if err := opts.Meter.Normalize.Validate(); err != nil {
+		return fmt.Errorf("meter.normalize: %w", err)
+	}
+	if c.Meter.SerialMinMatch < 0 {
+		return fmt.Errorf("meter: serial_min_match must not be negative")
+	}
+	if c.ROI.Padding < 0 || c.ROI.Samples < 0 {
+		return fmt.Errorf("roi: padding and samples must not be negative")
+	}
+	if c.ROI.MinSaving < 0 || c.ROI.MinSaving >= 1 {
+		return fmt.Errorf("roi: min_saving must be a fraction of the image size")
+	}
+	if c.ROI.Box != nil && !c.ROI.Box.Valid() {
+		return fmt.Errorf("roi.box: %+v is not a box within the image", *c.ROI.Box)
+	}
+	if c.CrossCheck != nil {
+		if _, err := ocr.New(opts.Meter, *c.CrossCheck); err != nil {
+			return fmt.Errorf("cross_check: %w", err)
+		}
+	}
+	if len(c.Examples) > genai.MaxExamples {
+		return fmt.Errorf("examples: at most %d allowed", genai.MaxExamples)
+	}
+	if (c.Anomaly.Factor > 0 || c.Anomaly.Absolute > 0) && c.Store.Path == "" {
+		return fmt.Errorf("anomaly: needs store.path")
+	}
+	if _, ok, err := c.LeakConfig(); err != nil {
+		return fmt.Errorf("leak: %w", err)
+	} else if ok && c.Store.Path == "" {
+		return fmt.Errorf("leak: needs store.path")
+	} else if f := c.Leak.Flow; f.Enabled && !ok {
+		return fmt.Errorf("leak: flow needs window")
+	} else if f.Interval < 0 || f.Every < 0 {
+		return fmt.Errorf("leak: flow interval and every must not be negative")
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	if _, err := c.Validators(); err != nil {
+		return fmt.Errorf("meter.validators: %w", err)
+	}
+	if c.API.MaxPoints < 0 {
+		return fmt.Errorf("api.max_points: negative %d", c.API.MaxPoints)
+	}
+	if c.API.Pool.MaxSize < 0 || c.API.Pool.Idle < 0 {
+		return fmt.Errorf("api.pool: negative max_size or idle")
+	}
+	if c.API.Expvar == "cmdline" || c.API.Expvar == "memstats" {
+		return fmt.Errorf("api.expvar: %q is taken by the runtime", c.API.Expvar)
+	}
+	if c.Report.MaxGap < 0 {
+		return fmt.Errorf("report.max_gap: negative %s", c.Report.MaxGap)
+	}
+	if c.Anchor != nil {
+		if err := c.Anchor.Validate(); err != nil {
+			return fmt.Errorf("anchor: %w", err)
+		}
+		if c.Store.Path == "" {
+			return fmt.Errorf("anchor: needs store.path")
+		}
+	}
+	if c.Downsample != nil {
+		if err := c.Downsample.Validate(); err != nil {
+			return fmt.Errorf("downsample: %w", err)
+		}
+		if c.Store.Path == "" {
+			return fmt.Errorf("downsample: needs store.path")
+		}
+	}
+	if c.Digest.Period != "" {
+		if err := report.Period(c.Digest.Period).Validate(); err != nil {
+			return fmt.Errorf("digest: %w", err)
+		}
+		if c.Store.Path == "" {
+			return fmt.Errorf("digest: needs store.path")
+		}
+	}
+	if c.Email != nil {
+		if err := c.Email.Validate(); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	if c.Sinks.Stdout != "" && !slices.Contains(sink.Formats, c.Sinks.Stdout) {
+		return fmt.Errorf("sinks: unknown stdout format %q", c.Sinks.Stdout)
+	}
+	if c.Sinks.Influx != nil {
+		if err := c.Sinks.Influx.Validate(); err != nil {
+			return fmt.Errorf("sinks: influx: %w", err)
+		}
+	}
+	if c.Sinks.MQTT != nil {
+		if err := c.Sinks.MQTT.Validate(); err != nil {
+			return fmt.Errorf("sinks: mqtt: %w", err)
+		}
+	}
+	if c.Sinks.Webhook != nil {
+		if err := c.Sinks.Webhook.Validate(); err != nil {
+			return fmt.Errorf("sinks: webhook: %w", err)
+		}
+	}
+	if c.Sinks.Kafka != nil {
+		if err := c.Sinks.Kafka.Validate(); err != nil {
+			return fmt.Errorf("sinks: kafka: %w", err)
+		}
+	}
+	if c.Export.HomeAssistant != nil {
+		if err := c.Export.HomeAssistant.Validate(); err != nil {
+			return fmt.Errorf("export: homeassistant: %w", err)
+		}
+	}
+	if c.Pushgateway != nil {
+		if err := c.Pushgateway.Validate(); err != nil {
+			return fmt.Errorf("pushgateway: %w", err)
+		}
+	}
+	if c.Sinks.Buffer < 0 {
+		return fmt.Errorf("sinks: buffer must not be negative")
+	}
+	if err := c.Enhance.Validate(); err != nil {
+		return fmt.Errorf("enhance: %w", err)
+	}
+	if c.Recapture.Attempts < 0 || c.Recapture.Delay < 0 || c.Recapture.Timeout < 0 {
+		return fmt.Errorf("recapture: attempts, delay and timeout must not be negative")
+	}
+	if err := c.validateSources(); err != nil {
+		return err
+	}
+	if err := c.Meter.Routing.Validate(); err != nil {
+		return fmt.Errorf("meter.routing: %w", err)
+	}
+	if c.Meter.Routing.Captures() && c.SourceConfigs()[0].Trigger == "" {
+		return fmt.Errorf("meter.routing: capture needs a trigger of the first source")
+	}
+	if c.Gaps.Threshold < 0 {
+		return fmt.Errorf("gaps: threshold must not be negative")
+	}
+	switch c.Gaps.Attribution {
+	case "", billing.GapSpread, billing.GapAtEnd:
+	default:
+		return fmt.Errorf("gaps: unknown attribution %q, want %s or %s", c.Gaps.Attribution, billing.GapSpread, billing.GapAtEnd)
+	}
+	if c.Archive != nil {
+		if err := c.Archive.Validate(); err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+	}
+	if c.Transcode != nil {
+		if err := c.Transcode.Validate(); err != nil {
+			return fmt.Errorf("transcode: %w", err)
+		}
+	}
+	if err := c.Network.Validate(); err != nil {
+		return fmt.Errorf("network: %w", err)
+	}
+	if c.API.Token != "" {
+		return fmt.Errorf("api.token is no longer supported, as it keeps an admin token in plain text: " +
+			"create a token with `mqvision token generate` and add it to api.tokens with the admin scope")
+	}
+	names := make(map[string]bool)
+	for i, t := range c.API.Tokens {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("api.tokens %d: %w", i, err)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("api.tokens %d: duplicate name %q", i, t.Name)
+		}
+		names[t.Name] = true
+	}
+	keys := map[string]bool{subscribeStdout: true, subscribeInflux: true, subscribeMQTT: true, subscribeWebhook: true, subscribeKafka: true, subscribeLog: true, subscribeEmail: c.Email != nil}
+	for i, nc := range c.Notifiers {
+		if _, err := notify.New(nc); err != nil {
+			return fmt.Errorf("notifiers %d: %w", i, err)
+		}
+		if keys[nc.Key()] {
+			return fmt.Errorf("notifiers %d: id %q is taken", i, nc.Key())
+		}
+		keys[nc.Key()] = true
+	}
+	for name, sub := range c.Subscriptions {
+		if err := c.subscriptionApplies(name); err != nil {
+			return fmt.Errorf("subscriptions: %w", err)
+		}
+		if err := sub.Validate(name == subscribeStdout || name == subscribeInflux || name == subscribeMQTT || name == subscribeWebhook || name == subscribeKafka); err != nil {
+			return fmt.Errorf("subscriptions: %s: %w", name, err)
+		}
+	}
+	if _, err := redact.New(c.Redact.Fields); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+	for _, name := range c.Redact.Sinks {
+		switch err := c.subscriptionApplies(name); {
+		case err != nil:
+			return fmt.Errorf("redact: %w", err)
+		case len(c.Redact.Fields) == 0:
+			return fmt.Errorf("redact: sinks need fields")
+		}
+	}
+	if c.Tariff != nil {
+		if err := c.Tariff.Validate(); err != nil {
+			return fmt.Errorf("tariff: %w", err)
+		}
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries: must not be negative")
+	}
+	if c.Upload.Retries < 0 || c.Upload.AttemptTimeout < 0 {
+		return fmt.Errorf("upload: retries and attempt_timeout must not be negative")
+	}
+	if t := c.Timeouts; t.Call < 0 || t.Upload < 0 || t.Generate < 0 || t.Guess < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if err := genai.Generation(c.Generation).Validate(); err != nil {
+		return fmt.Errorf("generation: %w", err)
+	}
+	if c.SelfTest.Image != "" || c.SelfTest.Read != "" {
+		if c.SelfTest.Image == "" || c.SelfTest.Read == "" {
+			return fmt.Errorf("self_test: needs both image and read")
+		}
+		if _, err := genai.ParseRead(opts.Meter, c.SelfTest.Read); err != nil {
+			return fmt.Errorf("self_test: read: %w", err)
+		}
+		if _, err := c.SelfTestAt(); err != nil {
+			return fmt.Errorf("self_test: at: %w", err)
+		}
+	}
+	for _, name := range c.Readiness.Checks {
+		if err := c.checkApplies(name); err != nil {
+			return fmt.Errorf("readiness: %w", err)
+		}
+	}
+	if c.StaleFallback && c.Breaker.Failures <= 0 {
+		return fmt.Errorf("stale_fallback: needs breaker.failures")
+	}
+	if _, err := c.GenAIAgreement(); err != nil {
+		return fmt.Errorf("ensemble: %w", err)
+	}
+	return nil
+}
+
+// ReadyChecks returns the names of the configured readiness checks, or of
+// all that apply.
+func (c *Config) ReadyChecks() []string {
+	if len(c.Readiness.Checks) > 0 {
+		return c.Readiness.Checks
+	}
+	var names []string
+	for _, name := range readyChecks {
+		if c.checkApplies(name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// validateSources checks Sources and Failover.
+func (c *Config) validateSources() error {
+	if c.Failover.Silence < 0 || c.Failover.AlertAfter < 0 {
+		return fmt.Errorf("failover: silence and alert_after must not be negative")
+	}
+	if len(c.Sources) == 0 {
+		return nil
+	}
+	if c.MQTT.Topic != "" || c.Recapture.Topic != "" {
+		return fmt.Errorf("sources: replace mqtt.topic and recapture.topic, set sources[].topic and trigger instead")
+	}
+	names, topics := map[string]bool{}, map[string]bool{}
+	for i, src := range c.Sources {
+		switch {
+		case src.Name == "" || src.Topic == "" && src.Camera == nil:
+			return fmt.Errorf("sources[%d]: needs a name and a topic or camera", i)
+		case src.Camera != nil && (src.Topic != "" || src.Trigger != ""):
+			return fmt.Errorf("sources[%d]: a camera takes no topic or trigger", i)
+		case names[src.Name]:
+			return fmt.Errorf("sources[%d]: duplicate name %q", i, src.Name)
+		case src.Topic != "" && topics[src.Topic]:
+			return fmt.Errorf("sources[%d]: duplicate topic %q", i, src.Topic)
+		case src.Timeout < 0 || src.Interval < 0:
+			return fmt.Errorf("sources[%d]: timeout and interval must not be negative", i)
+		}
+		if src.Camera != nil {
+			if err := src.Camera.Validate(); err != nil {
+				return fmt.Errorf("sources[%d]: camera: %w", i, err)
+			}
+		}
+		names[src.Name], topics[src.Topic] = true, true
+	}
+	return nil
+}
+
+// SourceConfigs returns the cameras of the meter: Sources, or the camera on
+// mqtt.topic, triggered through recapture.topic, of configs without them.
+func (c *Config) SourceConfigs() []SourceConfig {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	return []SourceConfig{{Topic: c.MQTT.Topic, Trigger: c.Recapture.Topic, Payload: c.Recapture.Payload, Timeout: c.Recapture.Timeout}}
+}
+
+// RecaptureAttempts returns the re-captures per reading cycle.
+func (c *Config) RecaptureAttempts() int {
+	if c.Recapture.Attempts == 0 && c.Recapture.Topic != "" {
+		return 2
+	}
+	return c.Recapture.Attempts
+}
+
+// Receivers of [Config.Subscriptions], besides [Config.Notifiers].
+const (
+	subscribeStdout  = "stdout"
+	subscribeInflux  = "influx"
+	subscribeMQTT    = "mqtt"
+	subscribeWebhook = "webhook"
+	subscribeKafka   = "kafka"
+	subscribeLog     = "log"
+	subscribeEmail   = "email"
+)
+
+// Redactor returns the redactor of redact.fields, or of every field if all,
+// knowing the configured meter ID, serial number and image store; nil if
+// nothing is redacted.
+func (c *Config) Redactor(all bool) (*redact.Redactor, error) {
+	fields := c.Redact.Fields
+	if all {
+		fields = redact.Fields
+	}
+	r, err := redact.New(fields)
+	if err != nil {
+		return nil, err
+	}
+	r.Value(redact.MeterID, c.Meter.ID)
+	r.Value(redact.SerialNumber, c.Meter.Serial)
+	if c.Concierge.Addr != "" {
+		r.Prefix(redact.SourceImage, concierge.ImageURL(c.Concierge.Addr, ""))
+	}
+	return r, nil
+}
+
+// Redacts reports whether the payloads of the receiver name are redacted.
+func (c *Config) Redacts(name string) bool {
+	return slices.Contains(c.Redact.Sinks, name)
+}
+
+// Subscription returns the configured subscription of the receiver name; no
+// events are its defaults.
+func (c *Config) Subscription(name string) event.Subscription {
+	return c.Subscriptions[name]
+}
+
+func (c *Config) subscriptionApplies(name string) error {
+	switch name {
+	case subscribeStdout:
+		if c.Sinks.Stdout == "" {
+			return fmt.Errorf("%s needs sinks.stdout", name)
+		}
+	case subscribeInflux:
+		if c.Sinks.Influx == nil {
+			return fmt.Errorf("%s needs sinks.influx", name)
+		}
+	case subscribeMQTT:
+		if c.Sinks.MQTT == nil {
+			return fmt.Errorf("%s needs sinks.mqtt", name)
+		}
+	case subscribeWebhook:
+		if c.Sinks.Webhook == nil {
+			return fmt.Errorf("%s needs sinks.webhook", name)
+		}
+	case subscribeKafka:
+		if c.Sinks.Kafka == nil {
+			return fmt.Errorf("%s needs sinks.kafka", name)
+		}
+	case subscribeLog:
+	default:
+		if name == subscribeEmail && c.Email != nil {
+			return nil
+		}
+		for _, nc := range c.Notifiers {
+			if nc.Key() == name {
+				return nil
+			}
+		}
+		if name == subscribeEmail {
+			return fmt.Errorf("%s needs email or an email notifier", name)
+		}
+		return fmt.Errorf("unknown receiver %q", name)
+	}
+	return nil
+}
+
+// MaxReadingAge returns how old the last reading may be for readiness.
+// ImageDecoder returns the decoder making JPEG of the images, with the
+// transcoder of c.Transcode if it is set.
+func (c *Config) ImageDecoder() (*imagefmt.Decoder, error) {
+	if c.Transcode == nil {
+		return imagefmt.NewDecoder(), nil
+	}
+	opts, err := c.Transcode.Options()
+	if err != nil {
+		return nil, err
+	}
+	return imagefmt.NewDecoder(opts...), nil
+}
+
+func (c *Config) MaxReadingAge() time.Duration {
+	if c.Readiness.MaxReadingAge > 0 {
+		return c.Readiness.MaxReadingAge
+	}
+	return 2 * time.Hour
+}
+
+func (c *Config) checkApplies(name string) error {
+	switch name {
+	case checkStore:
+		if c.Store.Path == "" {
+			return fmt.Errorf("check %s needs store.path", name)
+		}
+	case checkBreaker:
+		if c.Breaker.Failures <= 0 {
+			return fmt.Errorf("check %s needs breaker.failures", name)
+		}
+	case checkMQTT:
+		if c.MQTT.Host == "" {
+			return fmt.Errorf("check %s needs mqtt.host", name)
+		}
+	case checkReading:
+	default:
+		return fmt.Errorf("unknown check %q", name)
+	}
+	return nil
+}
+
+// GenAIAgreement returns the configured ensemble agreement policy.
+func (c *Config) GenAIAgreement() (genai.AgreementPolicy, error) {
+	switch c.Ensemble.Policy {
+	case "", "exact":
+		return genai.ExactMatch(), nil
+	case "epsilon":
+		if c.Ensemble.Epsilon <= 0 {
+			return nil, fmt.Errorf("policy epsilon needs a positive epsilon")
+		}
+		return genai.WithinEpsilon(c.Ensemble.Epsilon), nil
+	case "majority":
+		if len(c.Ensemble.Models) < 3 {
+			return nil, fmt.Errorf("policy majority needs at least 3 models")
+		}
+		return genai.Majority(), nil
+	default:
+		return nil, fmt.Errorf("unknown policy %q", c.Ensemble.Policy)
+	}
+}
+
+// GenAIBreaker returns the configured circuit breaker, or nil if it is disabled.
+// OpenFor defaults to 5 minutes.
+func (c *Config) GenAIBreaker() *genai.Breaker {
+	if c.Breaker.Failures <= 0 {
+		return nil
+	}
+	openFor := c.Breaker.OpenFor
+	if openFor <= 0 {
+		openFor = 5 * time.Minute
+	}
+	return genai.NewBreaker(c.Breaker.Failures, openFor)
+}
+
+// AnomalyConfig returns the anomaly detection settings and whether detection is enabled.
+func (c *Config) AnomalyConfig() (anomaly.Config, bool) {
+	cfg := anomaly.Config{
+		Factor:     c.Anomaly.Factor,
+		Absolute:   c.Anomaly.Absolute,
+		Window:     time.Duration(c.Anomaly.WindowDays) * 24 * time.Hour,
+		MinSamples: c.Anomaly.MinSamples,
+	}
+	return cfg, cfg.Factor > 0 || cfg.Absolute > 0
+}
+
+// ROIConfig returns the counter region settings and whether captures are
+// cropped, to a learned or a fixed region.
+func (c *Config) ROIConfig() (roi.Config, bool) {
+	cfg := roi.Config{
+		Padding:   c.ROI.Padding,
+		Samples:   c.ROI.Samples,
+		Freeze:    c.ROI.Freeze,
+		Reset:     c.ROI.Reset,
+		Box:       c.ROI.Box,
+		MinSaving: c.ROI.MinSaving,
+	}
+	return cfg, c.ROI.Learn || c.ROI.Box != nil
+}
+
+// ReportConfig returns the settings reports are generated with; periods
+// align to Timezone.
+func (c *Config) ReportConfig() report.Config {
+	cfg := report.Config{
+		Meter:    genai.NewOptions(genai.WithMeter(c.GenAIMeter())).Meter,
+		Tariff:   c.Tariff,
+		MaxGap:   c.Report.MaxGap,
+		Gaps:     c.Gaps.Attribution,
+		Anchor:   c.Anchor,
+		Baseline: c.Meter.Baseline,
+	}
+	if c.Timezone != "" {
+		cfg.Location, _ = time.LoadLocation(c.Timezone) // checked by Validate
+	}
+	cfg.NumberLocale = c.Numbers()
+	return cfg
+}
+
+// Numbers returns the locale of NumberLocale.
+func (c *Config) Numbers() numfmt.Locale {
+	l, _ := numfmt.Parse(c.NumberLocale) // checked by Validate
+	return l
+}
+
+// LeakConfig returns the overnight leak check settings and whether the check is enabled.
+func (c *Config) LeakConfig() (anomaly.LeakConfig, bool, error) {
+	var cfg anomaly.LeakConfig
+	if c.Leak.Window == "" {
+		return cfg, false, nil
+	}
+	start, end, ok := strings.Cut(c.Leak.Window, "-")
+	if !ok {
+		return cfg, false, fmt.Errorf("window %q is not HH:MM-HH:MM", c.Leak.Window)
+	}
+	var err error
+	if cfg.Start, err = parseTimeOfDay(start); err != nil {
+		return cfg, false, err
+	}
+	if cfg.End, err = parseTimeOfDay(end); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Start == cfg.End {
+		return cfg, false, fmt.Errorf("window %q is empty", c.Leak.Window)
+	}
+	if c.Leak.Timezone != "" {
+		if cfg.Location, err = time.LoadLocation(c.Leak.Timezone); err != nil {
+			return cfg, false, err
+		}
+	}
+	cfg.Threshold, cfg.Nights = c.Leak.Threshold, c.Leak.Nights
+	return cfg, true, nil
+}
+
+// SelfTestAt returns the time of day of the self-test.
+func (c *Config) SelfTestAt() (time.Duration, error) {
+	return parseTimeOfDay(cmp.Or(c.SelfTest.At, "04:00"))
+}
+
+// FlowCheck returns how far apart the two photos of a check of the flow
+// indicator are taken, and how often it is checked during the idle window.
+func (c *Config) FlowCheck() (interval, every time.Duration) {
+	return cmp.Or(c.Leak.Flow.Interval, 5*time.Second), cmp.Or(c.Leak.Flow.Every, time.Hour)
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("time of day %q: %w", s, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// GenAIExamples returns the configured few-shot examples.
+func (c *Config) GenAIExamples() []genai.Example {
+	examples := make([]genai.Example, len(c.Examples))
+	for i, e := range c.Examples {
+		examples[i] = genai.Example{Path: e.Path, ExpectedRead: e.Read}
+	}
+	return examples
+}
+
+// GenAIOptions returns the vision client options derived from the config.
+func (c *Config) GenAIOptions() []genai.Option {
+	opts := []genai.Option{
+		genai.WithLocale(c.Locale),
+		genai.WithMeter(c.GenAIMeter()),
+		genai.WithExampleImages(c.GenAIExamples()),
+	}
+	if c.Meter.Seed.Read != "" {
+		opts = append(opts, genai.WithSeed(c.Meter.Seed.Read, c.Meter.Seed.At))
+	}
+	if c.ResponseSchema != nil {
+		opts = append(opts, genai.WithResponseSchema(*c.ResponseSchema))
+	}
+	if c.MaxImageKB > 0 {
+		opts = append(opts, genai.WithMaxImageSize(int64(c.MaxImageKB)<<10))
+	}
+	if c.Retries > 0 || len(c.FallbackModels) > 0 {
+		opts = append(opts, genai.WithRetries(c.Retries, c.FallbackModels...))
+	}
+	if c.Upload.Retries > 0 {
+		opts = append(opts, genai.WithUploadRetries(c.Upload.Retries, c.Upload.AttemptTimeout))
+	}
+	if t := genai.Timeouts(c.Timeouts); t != (genai.Timeouts{}) {
+		opts = append(opts, genai.WithTimeouts(t))
+	}
+	if g := genai.Generation(c.Generation); g != (genai.Generation{}) {
+		opts = append(opts, genai.WithGeneration(g))
+	}
+	if c.SlowReading > 0 {
+		opts = append(opts, genai.WithSlowThreshold(c.SlowReading))
+	}
+	if c.transport != nil {
+		opts = append(opts, genai.WithTransport(c.transport))
+	}
+	if c.SingleShot {
+		opts = append(opts, genai.WithSingleShot())
+	}
+	if c.SelfVerify {
+		opts = append(opts, genai.WithSelfVerify())
+	}
+	if c.ROI.Learn {
+		opts = append(opts, genai.WithCounterBox())
+	}
+	if len(c.Ensemble.Models) > 0 {
+		policy, _ := c.GenAIAgreement() // checked by Validate
+		opts = append(opts, genai.WithEnsemble(c.Ensemble.Models, policy))
+	}
+	if c.Timezone != "" {
+		if loc, err := time.LoadLocation(c.Timezone); err == nil { // checked by Validate
+			opts = append(opts, genai.WithLocation(loc))
+		}
+	}
+	return opts
+}
+
+// Validators returns the acceptance pipeline of the meter, ending with
+// cross_check when CrossCheck is set and the validators do not list it.
+func (c *Config) Validators() (*validate.Pipeline, error) {
+	cfgs := c.Meter.Validators
+	if c.CrossCheck != nil && !slices.ContainsFunc(cfgs, func(v validate.Config) bool { return v.Name == validate.CrossCheck }) {
+		if len(cfgs) == 0 {
+			cfgs = validate.Default
+		}
+		cfgs = append(slices.Clip(cfgs), validate.Config{Name: validate.CrossCheck})
+	}
+	return validate.New(genai.NewOptions(genai.WithMeter(c.GenAIMeter())).Meter, cfgs)
+}
+
+// GenAIMeter returns the configured meter layout; unset fields use the
+// defaults of the utility, see [genai.LookupUtility].
+func (c *Config) GenAIMeter() genai.Meter {
+	return genai.Meter{
+		ID:             c.Meter.ID,
+		Utility:        c.Meter.Utility,
+		Type:           c.Meter.Type,
+		IntDigits:      c.Meter.IntDigits,
+		FracDigits:     c.Meter.FracDigits,
+		Unit:           c.Meter.Unit,
+		Serial:         c.Meter.Serial,
+		SerialMinMatch: c.Meter.SerialMinMatch,
+		Normalize: genai.Normalization{
+			Units:            c.Meter.Normalize.Units,
+			DecimalSeparator: c.Meter.Normalize.DecimalSeparator,
+			InsertDot:        c.Meter.Normalize.InsertDot,
+		},
+	}
+}
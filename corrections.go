@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// errBadCorrection is returned for a corrected value that does not fit the meter.
+var errBadCorrection = errors.New("bad correction")
+
+// correctionRequest is the body of a correction: the value read off the
+// meter in person and why.
+type correctionRequest struct {
+	Read string `json:"read"`
+	Note string `json:"note,omitempty"`
+}
+
+// correction is a corrected reading on its way to the reading consumer.
+type correction struct {
+	r      *genai.GasMeterReadResult
+	latest bool   // r is the latest reading of its meter
+	by     string // who corrected it: the name of the API token
+	// done, if set, is closed once the consumer has applied the correction.
+	done chan struct{}
+}
+
+// applied tells the sender of fix that it is applied.
+func (fix correction) applied() {
+	if fix.done != nil {
+		close(fix.done)
+	}
+}
+
+// correctReading checks req.Read against m and corrects the reading of
+// meterID with ID readingID in s. latest reports whether it is the latest
+// reading of the meter, i.e. the one the client reads the next from.
+func correctReading(ctx context.Context, s store.Store, m genai.Meter, meterID, readingID string, req correctionRequest) (r *genai.GasMeterReadResult, latest bool, err error) {
+	c, ok := s.(store.Corrector)
+	if !ok {
+		return nil, false, fmt.Errorf("store %T cannot correct readings", s)
+	}
+	if _, err := genai.ParseRead(m, req.Read); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", errBadCorrection, err)
+	}
+	if r, err = c.CorrectReading(ctx, meterID, readingID, req.Read, req.Note); err != nil {
+		return nil, false, err
+	}
+	l, err := s.Latest(ctx, meterID)
+	if err != nil {
+		return nil, false, fmt.Errorf("load latest reading: %w", err)
+	}
+	return r, l.ID == r.ID, nil
+}
+
+// correctedConsumption recomputes the consumption a correction of r changes:
+// from the reading before r to r, and from r to the reading after it. It is
+// nil without a tariff, when consumption is not published.
+func correctedConsumption(ctx context.Context, s store.Store, m genai.Meter, t *billing.Tariff, meterID string, r *genai.GasMeterReadResult) []sink.Consumption {
+	if t == nil {
+		return nil
+	}
+	var pairs [][2]*genai.GasMeterReadResult
+	if before, err := s.ReadingsBetween(ctx, meterID, time.Time{}, r.ReadAt); err == nil && len(before) > 0 {
+		pairs = append(pairs, [2]*genai.GasMeterReadResult{before[len(before)-1], r})
+	}
+	if after, err := s.ReadingsBetween(ctx, meterID, r.ReadAt.Add(time.Nanosecond), time.Now().AddDate(100, 0, 0)); err == nil && len(after) > 0 {
+		pairs = append(pairs, [2]*genai.GasMeterReadResult{r, after[0]})
+	}
+	var out []sink.Consumption
+	for _, p := range pairs {
+		prev, err1 := genai.ParseRead(m, p[0].Read)
+		cur, err2 := genai.ParseRead(m, p[1].Read)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if d, ok := m.DeltaTo(prev, p[1], cur); ok {
+			out = append(out, sink.Consumption{MeterID: meterID, At: p[1].ReadAt, Usage: t.Correct(p[1].ReadAt, d)})
+		}
+	}
+	return out
+}
+
+// publishCorrection dispatches a correction with d: the sinks are sent the
+// [sink.CorrectionEvent] and the consumption it changes, the notifiers the
+// event as its Data.
+func publishCorrection(ctx context.Context, d *event.Dispatcher, meterID string, fix correction, cons []sink.Consumption) {
+	c := sink.NewCorrectionEvent(meterID, fix.by, fix.r)
+	msg := "Reading " + c.Old + " corrected to " + c.New
+	if c.By != "" {
+		msg += " by " + c.By
+	}
+	err := d.Dispatch(ctx, event.Event{Type: event.Correction, Event: notify.Event{
+		Kind:     "correction",
+		Severity: notify.Info,
+		MeterID:  meterID,
+		Time:     fix.r.ReadAt,
+		Message:  msg,
+		Data:     c,
+	}, Reading: fix.r, Consumption: cons, Correction: &c})
+	if err != nil {
+		log.Printf("Error publishing correction: %v", err)
+	}
+}
+
+// Corrections serves POST /v1/meters/:id/readings/:reading_id/correction,
+// which corrects a stored reading to the JSON [correctionRequest] and answers
+// with the corrected reading. Its route needs the admin scope, see [Auth].
+type Corrections struct {
+	// Last corrects the readings, in its store; without one corrections fail.
+	Last  *LastReadings
+	Meter genai.Meter
+	// OnCorrect is called with every corrected reading.
+	OnCorrect func(ctx context.Context, fix correction)
+}
+
+// Handler implements the endpoint.
+func (h *Corrections) Handler(c *gin.Context) {
+	if id := c.Param("id"); id != h.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	if h.Last == nil || h.Last.Store == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "corrections need store.path"})
+		return
+	}
+	var req correctionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r, latest, err := h.Last.Correct(c.Request.Context(), h.Meter, h.Meter.ID, c.Param("reading_id"), req)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown reading"})
+		return
+	case errors.Is(err, errBadCorrection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	by := tokenName(c)
+	log.Printf("Corrected reading %s from %s to %s with token %s: %s", r.ID, r.Correction.Previous, r.Read, by, r.Correction.Note)
+	if h.OnCorrect != nil {
+		h.OnCorrect(c.Request.Context(), correction{r: r, latest: latest, by: by})
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// runCorrect implements the `correct` subcommand: it corrects a stored
+// reading, through the API of a running daemon if -addr is set and in the
+// store file otherwise.
+func runCorrect(args []string) error {
+	fs := flag.NewFlagSet("correct", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	readingID := fs.String("id", "", "ID of the reading to correct")
+	read := fs.String("read", "", "Corrected value, e.g. 01234.567")
+	note := fs.String("note", "", "Why the reading is corrected")
+	addr := fs.String("addr", "", "Address of the running daemon, e.g. http://localhost:8080")
+	token := fs.String("token", os.Getenv("MQVISION_TOKEN"), "Admin API token for -addr (default: $MQVISION_TOKEN)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s correct -id ID -read VALUE [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *readingID == "" || *read == "" {
+		fs.Usage()
+		return errors.New("correct: needs -id and -read")
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	req := correctionRequest{Read: *read, Note: *note}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var r *genai.GasMeterReadResult
+	if *addr != "" {
+		r, err = postCorrection(ctx, *addr, *token, *meterID, *readingID, req)
+	} else {
+		r, err = correctStoreFile(ctx, config, *meterID, *readingID, req)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(out))
+	return err
+}
+
+// correctStoreFile corrects the reading in the store file. It must not run
+// while the daemon has the file open: the daemon would go on writing to the
+// file it opened, which the correction replaces.
+func correctStoreFile(ctx context.Context, config *Config, meterID, readingID string, req correctionRequest) (*genai.GasMeterReadResult, error) {
+	if config.Store.Path == "" {
+		return nil, fmt.Errorf("correct: needs store.path")
+	}
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	m := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	r, _, err := correctReading(ctx, s, m, meterID, readingID, req)
+	if err != nil {
+		return nil, fmt.Errorf("correct reading: %w", err)
+	}
+	if config.Anchor != nil {
+		k, err := anchor.NewKeeper(s, *config.Anchor, m, config.ReportConfig().TimeZone())
+		if err != nil {
+			return nil, err
+		}
+		from, to := config.Anchor.Affected(r.ReadAt)
+		if _, err := k.Recompute(ctx, meterID, from, to); err != nil {
+			return nil, fmt.Errorf("recompute anchors: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// postCorrection corrects the reading through the API of the daemon at addr.
+func postCorrection(ctx context.Context, addr, token, meterID, readingID string, req correctionRequest) (*genai.GasMeterReadResult, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimRight(addr, "/") + "/v1/meters/" + url.PathEscape(meterID) + "/readings/" + url.PathEscape(readingID) + "/correction"
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("post correction: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("post correction: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	var r genai.GasMeterReadResult
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &r, nil
+}
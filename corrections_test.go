@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestCorrections(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	for i, read := range []string{"01234.000", "01284.000", "01236.000"} { // the second misread
+		r := &genai.GasMeterReadResult{ID: string(rune('a' + i)), Read: read, ReadAt: at.Add(time.Duration(i) * time.Hour)}
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	var fixes []correction
+	h := &Corrections{Last: &LastReadings{Store: s}, Meter: meter, OnCorrect: func(_ context.Context, fix correction) { fixes = append(fixes, fix) }}
+	auth, err := NewAuth([]APIToken{{Name: "ops", Hash: hashToken("secret"), Scopes: []string{scopeAdmin}}}, nil)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.POST("/v1/meters/:id/readings/:reading_id/correction", auth.Require(scopeAdmin), h.Handler)
+	post := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		body  string
+		code  int
+	}{
+		{"no token", "/v1/meters/home/readings/b/correction", "", `{"read":"01235.000"}`, http.StatusUnauthorized},
+		{"bad token", "/v1/meters/home/readings/b/correction", "guess", `{"read":"01235.000"}`, http.StatusUnauthorized},
+		{"unknown meter", "/v1/meters/cabin/readings/b/correction", "secret", `{"read":"01235.000"}`, http.StatusNotFound},
+		{"unknown reading", "/v1/meters/home/readings/z/correction", "secret", `{"read":"01235.000"}`, http.StatusNotFound},
+		{"bad value", "/v1/meters/home/readings/b/correction", "secret", `{"read":"12?5"}`, http.StatusBadRequest},
+		{"bad body", "/v1/meters/home/readings/b/correction", "secret", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := post(tt.path, tt.token, tt.body); w.Code != tt.code {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body)
+		}
+	}
+	if len(fixes) != 0 {
+		t.Fatalf("refused corrections were passed on: %v", fixes)
+	}
+
+	w := post("/v1/meters/home/readings/b/correction", "secret", `{"read":"01235.000","note":"read in person"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var got genai.GasMeterReadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Read != "01235.000" || got.Correction == nil || got.Correction.Original != "01284.000" || got.Correction.Note != "read in person" {
+		t.Fatalf("corrected reading = %+v, correction %+v", got, got.Correction)
+	}
+	if rs, _ := s.ReadingsBetween(ctx, "home", at, at.Add(3*time.Hour)); rs[1].Read != "01235.000" {
+		t.Fatalf("stored reading = %s, want the correction", rs[1].Read)
+	}
+	if len(fixes) != 1 || fixes[0].latest {
+		t.Fatalf("fixes = %v, want one of a reading that is not the latest", fixes)
+	}
+
+	cons := correctedConsumption(ctx, s, meter, &billing.Tariff{CorrectionFactor: 1}, "home", fixes[0].r)
+	if len(cons) != 2 || cons[0].Usage.Raw != 1 || cons[1].Usage.Raw != 1 || !cons[1].At.Equal(at.Add(2*time.Hour)) {
+		t.Fatalf("corrected consumption = %+v, want 1 m³ before and after", cons)
+	}
+
+	if w := post("/v1/meters/home/readings/c/correction", "secret", `{"read":"01236.500"}`); w.Code != http.StatusOK || !fixes[1].latest {
+		t.Fatalf("correcting the latest reading: status %d, fixes %v", w.Code, fixes)
+	}
+
+	auth, _ = NewAuth(nil, nil)
+	router = gin.New()
+	router.POST("/v1/meters/:id/readings/:reading_id/correction", auth.Require(scopeAdmin), h.Handler)
+	if w := post("/v1/meters/home/readings/b/correction", "secret", `{"read":"01235.000"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("without tokens: status %d, want 403", w.Code)
+	}
+}
+
+// TestCorrectionNotifies corrects a reading through the API and follows the
+// correction to the webhook and MQTT sinks and the notifiers.
+func TestCorrectionNotifies(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	if err := s.Save(ctx, "home", &genai.GasMeterReadResult{ID: "a", Read: "01284.000", ReadAt: at}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	hooks := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks[r.URL.Path], _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	topics := map[string][]byte{}
+	var notified []notify.Event
+	d := &event.Dispatcher{}
+	d.AddSink(sink.NewWebhook(sink.WebhookConfig{URL: srv.URL}), event.Subscription{})
+	d.AddSink(sink.NewMQTT(func(topic string, _ byte, _ bool, payload []byte) error {
+		topics[topic] = payload
+		return nil
+	}, sink.MQTTConfig{Topic: "mqvision/home"}, meter), event.Subscription{})
+	d.AddNotifier(notify.Func(func(_ context.Context, e notify.Event) error {
+		notified = append(notified, e)
+		return nil
+	}), event.Subscription{Events: []event.Type{event.Correction}})
+
+	h := &Corrections{Last: &LastReadings{Store: s}, Meter: meter, OnCorrect: func(ctx context.Context, fix correction) {
+		publishCorrection(ctx, d, "home", fix, nil)
+	}}
+	auth, err := NewAuth([]APIToken{{Name: "ops", Hash: hashToken("mqv_ops"), Scopes: []string{scopeAdmin}}}, nil)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.POST("/v1/meters/:id/readings/:reading_id/correction", auth.Require(scopeAdmin), h.Handler)
+	req := httptest.NewRequest(http.MethodPost, "/v1/meters/home/readings/a/correction", strings.NewReader(`{"read":"01235.000","note":"read in person"}`))
+	req.Header.Set("Authorization", "Bearer mqv_ops")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	check := func(where string, b []byte) {
+		t.Helper()
+		var c sink.CorrectionEvent
+		if err := json.Unmarshal(b, &c); err != nil {
+			t.Fatalf("%s: %s (%v)", where, b, err)
+		}
+		if c.MeterID != "home" || c.ReadingID != "a" || c.Old != "01284.000" || c.New != "01235.000" || c.Reason != "read in person" || c.By != "ops" || c.Reading == nil {
+			t.Fatalf("%s: correction %+v", where, c)
+		}
+	}
+	check("webhook", hooks["/correction"])
+	check("mqtt", topics["mqvision/home/correction/json"])
+	if _, ok := hooks["/reading"]; ok {
+		t.Fatalf("the correction was posted as a new reading")
+	}
+	if len(notified) != 1 || notified[0].Kind != "correction" || notified[0].Message != "Reading 01284.000 corrected to 01235.000 by ops" {
+		t.Fatalf("notified %+v", notified)
+	}
+	if c, ok := notified[0].Data.(sink.CorrectionEvent); !ok || c.By != "ops" {
+		t.Fatalf("notified data %#v, want the correction", notified[0].Data)
+	}
+}
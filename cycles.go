@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"sync"
+)
+
+// Cycles keeps at most one reading cycle per meter running. A capture that
+// arrives while the previous reading of its meter is still running, e.g.
+// behind a slow API call, is skipped rather than queued: a queued reading
+// would only be older by the time it runs, and readings would complete out
+// of order. Different meters do not wait for each other.
+type Cycles struct {
+	mu      sync.Mutex
+	running map[string]bool
+	skipped map[string]int64
+}
+
+// Start starts a cycle of meterID unless one is running. If ok, done must be
+// called when the cycle is over; otherwise the cycle is counted as skipped.
+func (c *Cycles) Start(meterID string) (done func(), ok bool) {
+	return c.start(meterID, true)
+}
+
+// TryStart is Start for work that waits its turn rather than being lost,
+// such as a self-test: a running cycle does not count it as skipped.
+func (c *Cycles) TryStart(meterID string) (done func(), ok bool) {
+	return c.start(meterID, false)
+}
+
+func (c *Cycles) start(meterID string, count bool) (done func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running[meterID] {
+		if !count {
+			return nil, false
+		}
+		if c.skipped == nil {
+			c.skipped = make(map[string]int64)
+		}
+		c.skipped[meterID]++
+		return nil, false
+	}
+	if c.running == nil {
+		c.running = make(map[string]bool)
+	}
+	c.running[meterID] = true
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			delete(c.running, meterID)
+		})
+	}, true
+}
+
+// Skipped returns the number of skipped cycles by meter.
+func (c *Cycles) Skipped() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.skipped))
+	maps.Copy(out, c.skipped)
+	return out
+}
+
+// discardCloser takes the image of a skipped cycle so the MQTT handler
+// completes as usual.
+type discardCloser struct{}
+
+func (discardCloser) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (discardCloser) Close() error                { return nil }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestCyclesSkipWhileReading(t *testing.T) {
+	t.Parallel()
+
+	// The fake API answers only once released, like a 90-second call.
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	reader := &genaitest.FakeReader{Generate: func(n int) (*genai.GasMeterReadResult, error) {
+		started <- struct{}{}
+		<-release
+		return &genai.GasMeterReadResult{Read: "01234.567"}, nil
+	}}
+	var cycles Cycles
+	var wg sync.WaitGroup
+	cycle := func(meterID string) bool {
+		done, ok := cycles.Start(meterID)
+		if !ok {
+			return false
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer done()
+			if _, err := reader.ReadGasGaugePicFromURL(context.Background(), "https://img/"+meterID); err != nil {
+				t.Errorf("read: %v", err)
+			}
+		}()
+		<-started
+		return true
+	}
+
+	if !cycle("home") {
+		t.Fatalf("first cycle skipped")
+	}
+	for range 3 {
+		if cycle("home") {
+			t.Fatalf("cycle ran while the previous one is reading")
+		}
+	}
+	if !cycle("cabin") {
+		t.Fatalf("cycle of another meter skipped")
+	}
+	if got := cycles.Skipped(); got["home"] != 3 || got["cabin"] != 0 {
+		t.Fatalf("Skipped = %v, want 3 of home", got)
+	}
+	if n := len(reader.Calls()); n != 2 {
+		t.Fatalf("API calls = %d, want one per meter", n)
+	}
+
+	close(release)
+	wg.Wait()
+	if !cycle("home") {
+		t.Fatalf("cycle after the reading finished skipped")
+	}
+	wg.Wait()
+	if n := len(reader.Calls()); n != 3 {
+		t.Fatalf("API calls = %d, want 3", n)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Dashboard settings: the range of the warnings shown, and at most how many.
+const (
+	dashboardWarningRange = 7 * 24 * time.Hour
+	dashboardWarnings     = 10
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// Dashboard serves GET /, a read-only page of the meter for a phone: the
+// latest reading and its age, the consumption since the baseline, a sparkline of the daily consumption of the
+// last 30 days from the series endpoint, the last archived photo and the
+// recent warnings. It follows the stream of the meter to stay current, and
+// carries the access_token of the request over to the endpoints it calls.
+type Dashboard struct {
+	Sensor *SensorServer
+	Store  store.Store   // no store: no sparkline, and only the latest warnings
+	Images archive.Store // no archive: no photo
+	Meter  genai.Meter
+	// Baseline, if set, shows the consumption since it, as carried by the
+	// latest reading.
+	Baseline *billing.Baseline
+	// NumberLocale formats the consumption shown; the zero locale shows
+	// plain numbers.
+	NumberLocale numfmt.Locale
+	Clock        genai.Clock // default genai.RealClock
+}
+
+// dashboardPage is the data of dashboardTemplate.
+type dashboardPage struct {
+	MeterID string
+	Read    string // the latest reading, or "" if there is none yet
+	Unit    string
+	Stale   bool
+	At      time.Time
+	Age     string
+	// Baseline is the date of the baseline, if any, and Cumulative the
+	// consumption since, or "" if the latest reading has none.
+	Baseline   string
+	Cumulative string
+	Series     bool
+	Photo      bool
+	Warnings   []dashboardWarning
+	Token      string
+	Locale     string // the BCP 47 tag of the numbers in the script, or ""
+}
+
+type dashboardWarning struct {
+	At   time.Time
+	Read string
+	Text string
+}
+
+// Handler implements the page.
+func (d *Dashboard) Handler(c *gin.Context) {
+	now := d.now()
+	p := dashboardPage{
+		MeterID: d.Meter.ID,
+		Unit:    d.Meter.Unit,
+		Series:  d.Store != nil,
+		Photo:   d.Images != nil,
+		Token:   c.Query(accessTokenParam),
+		Locale:  string(d.NumberLocale),
+	}
+	latest := d.Sensor.Latest()
+	if latest != nil && latest.GasMeterReadResult != nil {
+		p.Read, p.Stale, p.At = latest.Read, latest.Stale, latest.ReadAt
+		p.Age = now.Sub(p.At).Round(time.Minute).String()
+	}
+	if d.Baseline != nil {
+		p.Baseline = d.Baseline.At.Format(time.DateOnly)
+		if latest != nil && latest.Cumulative != nil {
+			p.Cumulative = d.NumberLocale.Float(latest.Cumulative.Raw, 3)
+		}
+	}
+
+	var recent []*genai.GasMeterReadResult
+	if d.Store != nil {
+		rs, err := d.Store.ReadingsBetween(c.Request.Context(), d.Meter.ID, now.Add(-dashboardWarningRange), now.Add(time.Minute))
+		if err != nil {
+			log.Printf("Error loading the readings of the dashboard: %v", err)
+		}
+		recent = rs
+	} else if latest != nil && latest.GasMeterReadResult != nil {
+		recent = append(recent, latest.GasMeterReadResult)
+	}
+	for _, r := range slices.Backward(recent) {
+		for _, w := range r.Warnings {
+			if len(p.Warnings) < dashboardWarnings {
+				p.Warnings = append(p.Warnings, dashboardWarning{At: r.ReadAt, Read: r.Read, Text: w})
+			}
+		}
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	if err := dashboardTemplate.Execute(c.Writer, p); err != nil {
+		log.Printf("Error rendering the dashboard: %v", err)
+	}
+}
+
+// PhotoHandler serves GET /v1/meters/:id/photo, the last archived image of
+// the meter.
+func (d *Dashboard) PhotoHandler(c *gin.Context) {
+	if id := c.Param("id"); id != d.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	if d.Images == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "photos need archive"})
+		return
+	}
+	img, err := d.lastPhoto(c.Request.Context())
+	switch {
+	case errors.Is(err, archive.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "no archived photo"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, http.DetectContentType(img), img)
+}
+
+// lastPhoto fetches the image of the latest reading or, after a restart,
+// the last one archived in the warning range.
+func (d *Dashboard) lastPhoto(ctx context.Context) ([]byte, error) {
+	if l := d.Sensor.Latest(); l != nil && l.ArchiveKey != "" {
+		// The upload may still be under way.
+		if img, err := d.Images.Fetch(ctx, l.ArchiveKey); !errors.Is(err, archive.ErrNotFound) {
+			return img, err
+		}
+	}
+	now := d.now()
+	imgs, err := d.Images.List(ctx, d.Meter.ID, now.Add(-dashboardWarningRange), now.Add(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("list archived photos: %w", err)
+	}
+	if len(imgs) == 0 {
+		return nil, archive.ErrNotFound
+	}
+	return d.Images.Fetch(ctx, imgs[len(imgs)-1].Key)
+}
+
+func (d *Dashboard) now() time.Time {
+	if d.Clock == nil {
+		return genai.RealClock.Now()
+	}
+	return d.Clock.Now()
+}
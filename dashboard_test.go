@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestDashboard(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	for i, r := range []*genai.GasMeterReadResult{
+		{Read: "01234.000", ReadAt: at.Add(-2 * time.Hour), Warnings: []string{"monotonic: decrease from 01234.100"}},
+		{Read: "01234.500", ReadAt: at.Add(-time.Hour)},
+	} {
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatalf("Save %d: %v", i, err)
+		}
+	}
+	images, err := archive.New(ctx, archive.Config{Backend: archive.BackendDir, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("archive.New: %v", err)
+	}
+	jpeg := []byte("\xff\xd8\xff\xe0 not quite a JPEG")
+	if err := images.Archive(ctx, images.Key("home", at.Add(-time.Hour)), jpeg); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	sensor := &SensorServer{Unit: meter.Unit, MeterID: "home"}
+	baseline := &billing.Baseline{At: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Read: "01000.000"}
+	d := &Dashboard{Sensor: sensor, Store: s, Images: images, Meter: meter, Baseline: baseline, Clock: genaitest.NewClock(at)}
+	router := gin.New()
+	router.GET("/", d.Handler)
+	router.GET("/v1/meters/:id/photo", d.PhotoHandler)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "no reading yet") {
+		t.Fatalf("before a reading: status %d: %s", w.Code, w.Body)
+	}
+	sensor.SetValue(1234.5, &Luggage{
+		GasMeterReadResult: &genai.GasMeterReadResult{Read: "01234.500", ReadAt: at.Add(-time.Hour)},
+		Cumulative:         &billing.Usage{Raw: 234.5, Corrected: 234.5},
+	})
+	w := get("/?access_token=mqv_phone")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`<span id="read">01234.500</span>`,
+		`1h0m0s ago`,
+		`Since 2025-07-01: <span id="cumulative">234.500</span>`,
+		`monotonic: decrease from 01234.100`,
+		`<svg id="spark"`,
+		`src="/v1/meters/home/photo?access_token=mqv_phone"`,
+		`token = "mqv_phone"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("page lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "https://") {
+		t.Fatalf("page loads external resources")
+	}
+
+	if w := get("/v1/meters/home/photo"); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), jpeg) || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("photo: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w := get("/v1/meters/cabin/photo"); w.Code != http.StatusNotFound {
+		t.Fatalf("photo of an unknown meter: status %d, want 404", w.Code)
+	}
+
+	// Without a store and an archive the page still shows the reading.
+	bare := &Dashboard{Sensor: sensor, Meter: meter, Clock: d.Clock}
+	router.GET("/bare", bare.Handler)
+	router.GET("/bare/:id/photo", bare.PhotoHandler)
+	if w := get("/bare"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), `id="spark"`) || strings.Contains(w.Body.String(), `id="photo"`) {
+		t.Fatalf("bare page: status %d: %s", w.Code, w.Body)
+	}
+	if w := get("/bare/home/photo"); w.Code != http.StatusNotImplemented {
+		t.Fatalf("photo without archive: status %d, want 501", w.Code)
+	}
+
+	// In German the consumption shows its separators, the reading as on the
+	// meter's face.
+	german := &Dashboard{Sensor: sensor, Meter: meter, Baseline: baseline, NumberLocale: "de", Clock: d.Clock}
+	router.GET("/de", german.Handler)
+	body = get("/de").Body.String()
+	for _, want := range []string{`<span id="read">01234.500</span>`, `<span id="cumulative">234,500</span>`, `locale = "de"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("German page lacks %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/downsample"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// downsampleEvery runs j for meterID at the start and then every interval
+// until ctx is done.
+func downsampleEvery(ctx context.Context, j *downsample.Job, meterID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		before := time.Now()
+		rec, err := j.Run(ctx, meterID, before)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Error downsampling history: %v", err)
+		case err == nil:
+			log.Printf("Downsampled history of %s in %s: %d readings deleted so far", meterID, time.Since(before).Round(time.Millisecond), rec.Deleted)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runDownsample implements the `downsample` subcommand: it thins out the
+// old history of the store as the daemon does, e.g. from cron while the
+// daemon is stopped, and prints the record of the runs.
+func runDownsample(args []string) error {
+	fs := flag.NewFlagSet("downsample", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s downsample [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Downsample == nil || config.Store.Path == "" {
+		return fmt.Errorf("downsample: needs downsample and store.path")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	meter.ID = *meterID
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	j, err := downsample.New(s, *config.Downsample, meter, config.ReportConfig().TimeZone(), config.Anchor)
+	if err != nil {
+		return err
+	}
+	rec, err := j.Run(ctx, *meterID, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Hourly until %s, daily until %s; %d readings deleted\n", downsampledUntil(rec.HourlyUntil), downsampledUntil(rec.DailyUntil), rec.Deleted)
+	return nil
+}
+
+// downsampledUntil formats how far the history is downsampled, "-" if not at all.
+func downsampledUntil(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Event feed limits: the events of a response, by default and at most, the
+// longest wait and how often the log is checked meanwhile.
+const (
+	eventFeedLimit    = 100
+	eventFeedMaxLimit = 1000
+	eventFeedMaxWait  = time.Minute
+	eventFeedPoll     = time.Second
+)
+
+// EventFeed serves GET /v1/events, the event log of the store for syncing
+// it elsewhere by sequence number: the events after since (default 0, all
+// of them), at most limit (default 100, at most 1000),
+//
+//	{"events":[{"seq":1235,"type":"reading_accepted","meter_id":"home","time":"...","reading":{...}},...],"next":1235}
+//
+// where next is the since of the following request. With wait, e.g. 30s,
+// a request finding no events long-polls: it is answered as soon as one is
+// logged, or with none after wait (at most a minute). Events compacted away
+// with the readings they were about are not served: if some after since
+// were, the response has "compacted", the highest sequence number of them,
+// and the consumer may have missed events up to it.
+type EventFeed struct {
+	Log   store.EventLog // no log: the feed fails
+	Clock genai.Clock    // default genai.RealClock
+}
+
+// Handler implements the endpoint.
+func (h *EventFeed) Handler(c *gin.Context) {
+	if h.Log == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "events need store.path"})
+		return
+	}
+	clock := h.Clock
+	if clock == nil {
+		clock = genai.RealClock
+	}
+	since, err := strconv.ParseUint(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("since: %v", err)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(eventFeedLimit)))
+	if err != nil || limit < 1 || limit > eventFeedMaxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit %q, want 1 to %d", c.Query("limit"), eventFeedMaxLimit)})
+		return
+	}
+	var wait time.Duration
+	if w := c.Query("wait"); w != "" {
+		if wait, err = time.ParseDuration(w); err != nil || wait < 0 || wait > eventFeedMaxWait {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("wait %q, want a duration up to %s", w, eventFeedMaxWait)})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	compacted, err := h.Log.Compacted(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deadline := clock.Now().Add(wait)
+	for {
+		es, err := h.Log.EventsSince(ctx, since, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(es) > 0 || !clock.Now().Before(deadline) {
+			next := since
+			if len(es) > 0 {
+				next = es[len(es)-1].Seq
+			} else {
+				es = []store.Event{}
+			}
+			resp := gin.H{"events": es, "next": next}
+			if since < compacted {
+				resp["compacted"] = compacted
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+		select {
+		case <-clock.After(min(eventFeedPoll, deadline.Sub(clock.Now()))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
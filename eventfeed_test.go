@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestEventFeed(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for i, read := range []string{"01234.000", "01235.000", "01236.000"} {
+		if err := s.Save(ctx, "home", &genai.GasMeterReadResult{Read: read, ReadAt: at.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &EventFeed{Log: s}
+	router := gin.New()
+	router.GET("/v1/events", h.Handler)
+	type response struct {
+		Events    []store.Event `json:"events"`
+		Next      uint64        `json:"next"`
+		Compacted uint64        `json:"compacted"`
+	}
+	get := func(query string) (int, response) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/events"+query, nil))
+		var resp response
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := get("?since=1&limit=1"); code != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].Seq != 2 || resp.Next != 2 {
+		t.Fatalf("since=1&limit=1: %d %+v", code, resp)
+	}
+	if code, resp := get("?since=2"); code != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].Reading.Read != "01236.000" || resp.Next != 3 {
+		t.Fatalf("since=2: %d %+v", code, resp)
+	}
+	if code, resp := get("?since=3"); code != http.StatusOK || resp.Events == nil || len(resp.Events) != 0 || resp.Next != 3 {
+		t.Fatalf("since=3, nothing new: %d %+v", code, resp)
+	}
+	// Pruning the first reading compacts its event away: a consumer behind
+	// it is told so.
+	if _, err := s.Prune(ctx, at.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := get("?since=0"); code != http.StatusOK || len(resp.Events) != 2 || resp.Events[0].Seq != 2 || resp.Compacted != 1 {
+		t.Fatalf("since=0 after pruning: %d %+v", code, resp)
+	}
+	if code, resp := get("?since=1"); code != http.StatusOK || len(resp.Events) != 2 || resp.Compacted != 0 {
+		t.Fatalf("since=1 after pruning: %d %+v", code, resp)
+	}
+	for _, q := range []string{"?since=-1", "?limit=0", "?limit=5000", "?wait=1h", "?wait=soon"} {
+		if code, _ := get(q); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, code)
+		}
+	}
+
+	// A long poll is answered with the next event logged.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Save(ctx, "home", &genai.GasMeterReadResult{Read: "01237.000", ReadAt: at.Add(3 * time.Hour)})
+	}()
+	start := time.Now()
+	if code, resp := get("?since=3&wait=10s"); code != http.StatusOK || len(resp.Events) != 1 || resp.Events[0].Seq != 4 {
+		t.Fatalf("long poll: %d %+v", code, resp)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Fatalf("long poll took %s", took)
+	}
+
+	router = gin.New()
+	router.GET("/v1/events", (&EventFeed{}).Handler)
+	if code, _ := get(""); code != http.StatusNotImplemented {
+		t.Fatalf("without a log: status %d, want 501", code)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/roi"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// errBadExchange is returned for an exchange that does not fit the meter or
+// its last reading.
+var errBadExchange = errors.New("bad exchange")
+
+// exchangeRequest is the body of recording a meter exchange: when (default
+// now), the final reading of the old meter, and the serial number and
+// starting value of the new one.
+type exchangeRequest struct {
+	At     time.Time `json:"at,omitzero"`
+	Final  string    `json:"final,omitempty"`
+	Serial string    `json:"serial,omitempty"`
+	Start  string    `json:"start,omitempty"`
+	Note   string    `json:"note,omitempty"`
+}
+
+// exchange returns the exchange req asks for as of now.
+func (req exchangeRequest) exchange(now time.Time) maintenance.Exchange {
+	e := maintenance.Exchange{At: req.At, Final: req.Final, Serial: req.Serial, Start: req.Start, Note: req.Note}
+	if e.At.IsZero() {
+		e.At = now
+	}
+	return e
+}
+
+// exchangeStatus is a recorded exchange as served: the readings are those
+// saved in the history, the final reading of the old meter if known and the
+// marker.
+type exchangeStatus struct {
+	Meter    string                      `json:"meter"`
+	Exchange maintenance.Exchange        `json:"exchange"`
+	Readings []*genai.GasMeterReadResult `json:"readings,omitempty"`
+}
+
+// meterExchange is a recorded exchange on its way to the reading consumer.
+type meterExchange struct {
+	exchangeStatus
+	// done, if set, is closed once the consumer has applied the exchange.
+	done chan struct{}
+}
+
+// applied tells the sender of x that it is applied.
+func (x meterExchange) applied() {
+	if x.done != nil {
+		close(x.done)
+	}
+}
+
+// exchangeMeter records e, an exchange of meterID laid out as m: it saves
+// the readings of e through l, has k end a maintenance of the exchange and
+// expect the serial number of the new meter, and forgets the counter region
+// with resetROI, if set, as the new meter may sit elsewhere in the frame.
+func exchangeMeter(ctx context.Context, l *LastReadings, k *maintenance.Keeper, m genai.Meter, meterID string, e maintenance.Exchange, resetROI func(context.Context) error) (exchangeStatus, error) {
+	e, rs, err := l.Exchange(ctx, m, meterID, e)
+	if err != nil {
+		return exchangeStatus{}, err
+	}
+	if err := k.Exchanged(ctx, meterID, e); err != nil {
+		return exchangeStatus{}, err
+	}
+	if resetROI != nil {
+		if err := resetROI(ctx); err != nil {
+			return exchangeStatus{}, err
+		}
+	}
+	return exchangeStatus{Meter: meterID, Exchange: e, Readings: rs}, nil
+}
+
+// useExchangedSerial makes the serial number of the last exchange recorded
+// in k the one expected of the meter of c, when one is expected at all.
+func useExchangedSerial(ctx context.Context, c *Config, k *maintenance.Keeper) error {
+	if c.Meter.Serial == "" {
+		return nil
+	}
+	e, ok, err := k.LastExchange(ctx, c.Meter.ID)
+	if err != nil || !ok || e.Serial == "" {
+		return err
+	}
+	c.Meter.Serial = e.Serial
+	return nil
+}
+
+// MeterExchange serves the exchange of the meter at
+// /v1/meters/:id/exchange: POST records one at once, as the JSON
+// [exchangeRequest] tells, and answers the [exchangeStatus]; GET answers
+// the last one recorded. The route of POST needs the admin scope, see
+// [Auth].
+type MeterExchange struct {
+	// Last saves the readings of the exchange, in its store; without one
+	// exchanges fail.
+	Last   *LastReadings
+	Keeper *maintenance.Keeper
+	Meter  genai.Meter
+	// Learner, if set, learns the counter region of the new meter again.
+	Learner *roi.Learner
+	Clock   genai.Clock // default genai.RealClock
+	// OnExchange is called with every recorded exchange.
+	OnExchange func(ctx context.Context, x meterExchange)
+}
+
+func (h *MeterExchange) now() time.Time {
+	if h.Clock == nil {
+		return genai.RealClock.Now()
+	}
+	return h.Clock.Now()
+}
+
+// Handler implements the endpoint.
+func (h *MeterExchange) Handler(c *gin.Context) {
+	if id := c.Param("id"); id != h.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	ctx := c.Request.Context()
+	if c.Request.Method == http.MethodGet {
+		e, ok, err := h.Keeper.LastExchange(ctx, h.Meter.ID)
+		switch {
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		case !ok:
+			c.JSON(http.StatusNotFound, gin.H{"error": "no exchange recorded"})
+		default:
+			c.JSON(http.StatusOK, exchangeStatus{Meter: h.Meter.ID, Exchange: e})
+		}
+		return
+	}
+	if h.Last == nil || h.Last.Store == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "exchanges need store.path"})
+		return
+	}
+	var req exchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var resetROI func(context.Context) error
+	if h.Learner != nil {
+		resetROI = h.Learner.Reset
+	}
+	status, err := exchangeMeter(ctx, h.Last, h.Keeper, h.Meter, h.Meter.ID, req.exchange(h.now()), resetROI)
+	switch {
+	case errors.Is(err, errBadExchange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	e := status.Exchange
+	log.Printf("Meter %s exchanged with token %s: the old one's final reading %s, the new one %s starting at %s",
+		h.Meter.ID, tokenName(c), orUnknown(e.Final), orUnknown(e.Serial), status.Readings[len(status.Readings)-1].Read)
+	if h.OnExchange != nil {
+		h.OnExchange(ctx, meterExchange{exchangeStatus: status})
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// runMeter implements the `meter` subcommand, of which `meter exchange` is
+// the only one so far.
+func runMeter(args []string) error {
+	if len(args) == 0 || args[0] != "exchange" {
+		fmt.Fprintf(os.Stderr, "Usage: %s meter exchange [flags]\n", os.Args[0])
+		return errors.New("meter: want the exchange command")
+	}
+	return runMeterExchange(args[1:])
+}
+
+// runMeterExchange implements `meter exchange`: it records the exchange of
+// a meter, through the API of a running daemon if -addr is set and in the
+// store file otherwise. The values not given as flags are asked for, and
+// the exchange is confirmed before it is recorded, unless -yes is set.
+func runMeterExchange(args []string) error {
+	fs := flag.NewFlagSet("meter exchange", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	final := fs.String("final", "", "Final reading of the old meter, e.g. 09876.543")
+	serial := fs.String("serial", "", "Serial number of the new meter")
+	start := fs.String("start", "", "The value the new meter starts at (default: zero)")
+	atFlag := fs.String("at", "", "When the meter was exchanged, as an RFC 3339 time (default: now)")
+	note := fs.String("note", "", "A note on the exchange")
+	yes := fs.Bool("yes", false, "Do not ask for missing values or confirmation")
+	addr := fs.String("addr", "", "Address of the running daemon, e.g. http://localhost:8080")
+	token := fs.String("token", os.Getenv("MQVISION_TOKEN"), "Admin API token for -addr (default: $MQVISION_TOKEN)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s meter exchange [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	req := exchangeRequest{Final: *final, Serial: *serial, Start: *start, Note: *note}
+	if *atFlag != "" {
+		if req.At, err = time.Parse(time.RFC3339, *atFlag); err != nil {
+			return fmt.Errorf("at: %w", err)
+		}
+	}
+	if !*yes {
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		ok, err := askExchange(os.Stdin, os.Stderr, *meterID, set, &req)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("meter exchange: not confirmed")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var status exchangeStatus
+	if *addr != "" {
+		status, err = postExchange(ctx, *addr, *token, *meterID, req)
+	} else {
+		status, err = exchangeStoreFile(ctx, config, *meterID, req)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(out))
+	return err
+}
+
+// askExchange asks on in, prompting on out, for the values of req not set
+// by flags, and then for confirmation of the exchange of meterID.
+func askExchange(in io.Reader, out io.Writer, meterID string, set map[string]bool, req *exchangeRequest) (bool, error) {
+	sc := bufio.NewScanner(in)
+	ask := func(prompt string) string {
+		fmt.Fprint(out, prompt)
+		if !sc.Scan() {
+			return ""
+		}
+		return strings.TrimSpace(sc.Text())
+	}
+	if !set["final"] {
+		req.Final = ask("Final reading of the old meter (empty if unknown): ")
+	}
+	if !set["serial"] {
+		req.Serial = ask("Serial number of the new meter (empty to keep the expected one): ")
+	}
+	if !set["start"] {
+		req.Start = ask("Value the new meter starts at (empty for zero): ")
+	}
+	at := "now"
+	if !req.At.IsZero() {
+		at = req.At.Format(time.RFC3339)
+	}
+	fmt.Fprintf(out, "Meter %s exchanged %s: the old one's final reading %s, the new one %s starting at %s.\n",
+		meterID, at, orUnknown(req.Final), orUnknown(req.Serial), startOrZero(req.Start))
+	answer := strings.ToLower(ask("Record the exchange? [y/N] "))
+	return answer == "y" || answer == "yes", sc.Err()
+}
+
+func startOrZero(start string) string {
+	if start == "" {
+		return "zero"
+	}
+	return start
+}
+
+// exchangeStoreFile records the exchange in the store file. It must not run
+// while the daemon has the file open, as [correctStoreFile].
+func exchangeStoreFile(ctx context.Context, config *Config, meterID string, req exchangeRequest) (exchangeStatus, error) {
+	if config.Store.Path == "" {
+		return exchangeStatus{}, fmt.Errorf("meter exchange: needs store.path or -addr")
+	}
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return exchangeStatus{}, fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	m := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	var resetROI func(context.Context) error
+	if _, ok := config.ROIConfig(); ok {
+		resetROI = func(ctx context.Context) error { return roi.Reset(ctx, s, meterID) }
+	}
+	status, err := exchangeMeter(ctx, &LastReadings{Store: s}, maintenance.NewKeeper(s, m), m, meterID, req.exchange(time.Now()), resetROI)
+	if err != nil {
+		return exchangeStatus{}, fmt.Errorf("record exchange: %w", err)
+	}
+	if config.Anchor != nil {
+		k, err := anchor.NewKeeper(s, *config.Anchor, m, config.ReportConfig().TimeZone())
+		if err != nil {
+			return exchangeStatus{}, err
+		}
+		from, to := config.Anchor.Affected(status.Exchange.At)
+		if _, err := k.Recompute(ctx, meterID, from, to); err != nil {
+			return exchangeStatus{}, fmt.Errorf("recompute anchors: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// postExchange records the exchange through the API of the daemon at addr.
+func postExchange(ctx context.Context, addr, token, meterID string, req exchangeRequest) (exchangeStatus, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return exchangeStatus{}, err
+	}
+	u := strings.TrimRight(addr, "/") + "/v1/meters/" + url.PathEscape(meterID) + "/exchange"
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return exchangeStatus{}, fmt.Errorf("create request: %w", err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return exchangeStatus{}, fmt.Errorf("post exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return exchangeStatus{}, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return exchangeStatus{}, fmt.Errorf("post exchange: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	var status exchangeStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return exchangeStatus{}, fmt.Errorf("decode response: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestMeterExchange(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	meter.Serial = "GM-2019-0001"
+	s := store.NewMemory()
+	if err := s.Save(ctx, "home", &genai.GasMeterReadResult{ID: "a", Read: "09876.000", ReadAt: at}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	l := &LastReadings{Store: s}
+	var changes []ReadingChange
+	l.Watch(func(c ReadingChange) { changes = append(changes, c) })
+	k := maintenance.NewKeeper(s, meter)
+
+	var exchanges []meterExchange
+	h := &MeterExchange{Last: l, Keeper: k, Meter: meter, Clock: genaitest.NewClock(at.Add(time.Hour)),
+		OnExchange: func(_ context.Context, x meterExchange) { exchanges = append(exchanges, x) }}
+	auth, err := NewAuth([]APIToken{{Name: "ops", Hash: hashToken("secret"), Scopes: []string{scopeAdmin}}}, nil)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.GET("/v1/meters/:id/exchange", h.Handler)
+	router.POST("/v1/meters/:id/exchange", auth.Require(scopeAdmin), h.Handler)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodGet, "/v1/meters/home/exchange", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET before any exchange: status %d: %s", w.Code, w.Body)
+	}
+	for _, tt := range []struct {
+		name string
+		path string
+		body string
+		code int
+	}{
+		{"unknown meter", "/v1/meters/cabin/exchange", `{"final":"09876.500"}`, http.StatusNotFound},
+		{"bad body", "/v1/meters/home/exchange", `{`, http.StatusBadRequest},
+		{"final below the last reading", "/v1/meters/home/exchange", `{"final":"09000.000"}`, http.StatusBadRequest},
+		{"before the last reading", "/v1/meters/home/exchange", `{"at":"2025-11-07T05:00:00Z"}`, http.StatusBadRequest},
+	} {
+		if w := serve(http.MethodPost, tt.path, tt.body); w.Code != tt.code {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body)
+		}
+	}
+	if len(exchanges) != 0 {
+		t.Fatalf("refused exchanges were passed on: %v", exchanges)
+	}
+
+	w := serve(http.MethodPost, "/v1/meters/home/exchange", `{"final":"09876.500","serial":"GM-2025-0002","note":"replaced by the gas company"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("exchange: status %d: %s", w.Code, w.Body)
+	}
+	var status exchangeStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Readings) != 2 || !status.Exchange.At.Equal(at.Add(time.Hour)) || status.Exchange.Marker != status.Readings[1].ID {
+		t.Fatalf("exchange = %+v; want the final reading and the marker now", status)
+	}
+	if last, err := l.Get(ctx, "home"); err != nil || last.ID != status.Exchange.Marker || last.Read != "00000.000" {
+		t.Fatalf("last reading = %+v, %v; want the marker", last, err)
+	}
+	if rs, err := s.ReadingsBetween(ctx, "home", at.Add(time.Minute), at.Add(2*time.Hour)); err != nil || len(rs) != 2 {
+		t.Fatalf("saved %d readings (%v), want 2", len(rs), err)
+	}
+	if len(exchanges) != 1 || len(changes) != 1 || !changes[0].Exchanged || changes[0].Reading.ID != status.Exchange.Marker {
+		t.Fatalf("exchanges %+v, changes %+v; want the exchange passed on", exchanges, changes)
+	}
+
+	w = serve(http.MethodGet, "/v1/meters/home/exchange", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"serial":"GM-2025-0002"`) {
+		t.Fatalf("GET: status %d: %s", w.Code, w.Body)
+	}
+	c := &Config{}
+	c.Meter.ID, c.Meter.Serial = "home", meter.Serial
+	if err := useExchangedSerial(ctx, c, k); err != nil || c.Meter.Serial != "GM-2025-0002" {
+		t.Fatalf("useExchangedSerial = %v, serial %q", err, c.Meter.Serial)
+	}
+
+	// Without a store the exchange cannot be saved.
+	h.Last = &LastReadings{}
+	if w := serve(http.MethodPost, "/v1/meters/home/exchange", `{}`); w.Code != http.StatusNotImplemented {
+		t.Fatalf("exchange without a store: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAskExchange(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	req := exchangeRequest{Serial: "GM-2025-0002"}
+	ok, err := askExchange(strings.NewReader("09876.500\n\ny\n"), &out, "home", map[string]bool{"serial": true}, &req)
+	if err != nil || !ok {
+		t.Fatalf("askExchange = %v, %v", ok, err)
+	}
+	if req.Final != "09876.500" || req.Serial != "GM-2025-0002" || req.Start != "" {
+		t.Fatalf("request = %+v", req)
+	}
+	if got := out.String(); strings.Contains(got, "Serial number") ||
+		!strings.Contains(got, "Meter home exchanged now: the old one's final reading 09876.500, the new one GM-2025-0002 starting at zero.") {
+		t.Fatalf("prompts %q", got)
+	}
+
+	req = exchangeRequest{}
+	if ok, err := askExchange(strings.NewReader("\n\n\n"), &out, "home", nil, &req); err != nil || ok {
+		t.Fatalf("askExchange without confirmation = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/export"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// runExport implements the `export` subcommand: it pushes the hourly
+// consumption recorded in the store to the configured targets, from where
+// the last export left off, or the whole history with -full. With -dry-run
+// it prints the rows instead and saves nothing.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	full := fs.Bool("full", false, "Export the whole history again")
+	dryRun := fs.Bool("dry-run", false, "Print the rows instead of sending them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s export [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("export: needs store.path")
+	}
+	if config.Export.HomeAssistant == nil {
+		return fmt.Errorf("export: needs export.homeassistant")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	meter.ID = *meterID
+	target := export.NewHomeAssistant(*config.Export.HomeAssistant, meter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	key := export.StateKeyPrefix + target.Name()
+	var cur export.Cursor
+	if !*full {
+		if err := s.LoadState(ctx, *meterID, key, &cur); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("load %s cursor: %w", target.Name(), err)
+		}
+	}
+	rows, next, err := export.Hourly(ctx, s, meter, *meterID, config.Gaps.Attribution, cur, time.Now())
+	if err != nil {
+		return err
+	}
+
+	meta := target.Metadata()
+	if *dryRun {
+		fmt.Printf("Would import %d rows into %s as %s (%q in %s, source %s, has_sum %t, has_mean %t):\n",
+			len(rows), target.Name(), meta.StatisticID, meta.Name, meta.Unit, meta.Source, meta.HasSum, meta.HasMean)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "start\tstate (%s)\tsum (%s)\t\n", meta.Unit, meta.Unit)
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%.3f\t%.3f\t\n", r.Start.Format(time.RFC3339), r.State, r.Sum)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			fmt.Printf("The next export would start at %s with a sum of %.3f\n", next.Hour.Format(time.RFC3339), next.Sum)
+		}
+		return nil
+	}
+	if len(rows) == 0 {
+		fmt.Println("Nothing to export")
+		return nil
+	}
+	if err := target.Export(ctx, rows); err != nil {
+		return fmt.Errorf("export to %s: %w", target.Name(), err)
+	}
+	if err := s.SaveState(ctx, *meterID, key, next); err != nil {
+		return fmt.Errorf("save %s cursor: %w", target.Name(), err)
+	}
+	fmt.Printf("Imported %d rows into %s as %s, from %s to %s\n", len(rows), target.Name(), meta.StatisticID,
+		rows[0].Start.Format(time.RFC3339), rows[len(rows)-1].Start.Format(time.RFC3339))
+	return nil
+}
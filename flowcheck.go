@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anomaly"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// checkFlowEvery checks the flow indicator of meterID with fd every every
+// while inside the idle window of leaks, photographing it twice with src
+// interval apart, and saves the outcome for the leak check.
+func checkFlowEvery(ctx context.Context, fd genai.FlowDetector, leaks *anomaly.LeakDetector, src *imageSource, meterID string, interval, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if leaks.InWindow(time.Now()) {
+			if err := checkFlow(ctx, fd, leaks, src, meterID, interval); err != nil && ctx.Err() == nil {
+				log.Printf("Error checking the flow indicator: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func checkFlow(ctx context.Context, fd genai.FlowDetector, leaks *anomaly.LeakDetector, src *imageSource, meterID string, interval time.Duration) error {
+	img1, err := src.capture.Recapture(ctx)
+	if err != nil {
+		return fmt.Errorf("capture from %s: %w", src.name, err)
+	}
+	select {
+	case <-time.After(interval):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	img2, err := src.capture.Recapture(ctx)
+	if err != nil {
+		return fmt.Errorf("capture from %s: %w", src.name, err)
+	}
+	res, err := fd.DetectFlow(ctx, bytes.NewReader(img1), bytes.NewReader(img2))
+	if err != nil {
+		return fmt.Errorf("detect flow: %w", err)
+	}
+	log.Printf("Flow indicator of %s: flow %t, rotation %.2f %s", meterID, res.FlowDetected, res.Rotation, res.Note)
+	return leaks.Observe(ctx, meterID, *res)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Readiness checks of [Config.Readiness].
+const (
+	checkStore   = "store"   // the store answers
+	checkReading = "reading" // the last reading is recent enough
+	checkBreaker = "breaker" // the circuit breaker is not open
+	checkMQTT    = "mqtt"    // the broker is connected
+)
+
+var readyChecks = []string{checkStore, checkReading, checkBreaker, checkMQTT}
+
+// probeTimeout bounds each readiness check.
+const probeTimeout = 2 * time.Second
+
+// ReadyCheck is one sub-check of /readyz; Check returns why it is not ready.
+type ReadyCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Health serves the container probes: /healthz answers while the process
+// is up with its config loaded, /readyz only while every check passes.
+type Health struct {
+	Version string
+	Started time.Time
+	Checks  []ReadyCheck
+	Clock   genai.Clock
+	// Cycles, if set, adds the skipped reading cycles to /healthz.
+	Cycles *Cycles
+	// SelfTest, if set, adds the outcome of its last test to /healthz.
+	SelfTest *SelfTest
+}
+
+// checkResult is the JSON detail of a sub-check.
+type checkResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthzHandler reports that the process is up.
+func (h *Health) HealthzHandler(c *gin.Context) {
+	body := gin.H{
+		"status":  "ok",
+		"version": h.Version,
+		"uptime":  genai.Since(h.clock(), h.Started).Round(time.Second).String(),
+	}
+	if h.Cycles != nil {
+		body["skipped_cycles"] = h.Cycles.Skipped()
+	}
+	if h.SelfTest != nil {
+		body["self_test"] = h.SelfTest.Last()
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// ReadyzHandler runs every check and answers 503 with the failing ones
+// unless all pass.
+func (h *Health) ReadyzHandler(c *gin.Context) {
+	status, body := http.StatusOK, gin.H{"status": "ready"}
+	checks := make(map[string]checkResult, len(h.Checks))
+	for _, rc := range h.Checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), probeTimeout)
+		err := rc.Check(ctx)
+		cancel()
+		if err != nil {
+			status, body["status"] = http.StatusServiceUnavailable, "not ready"
+			checks[rc.Name] = checkResult{Error: err.Error()}
+			continue
+		}
+		checks[rc.Name] = checkResult{OK: true}
+	}
+	body["checks"] = checks
+	c.JSON(status, body)
+}
+
+func (h *Health) clock() genai.Clock {
+	if h.Clock == nil {
+		return genai.RealClock
+	}
+	return h.Clock
+}
+
+// storeCheck is ready while s answers for meterID; a meter without readings
+// yet is fine.
+func storeCheck(s store.Store, meterID string) ReadyCheck {
+	return ReadyCheck{Name: checkStore, Check: func(ctx context.Context) error {
+		if _, err := s.Latest(ctx, meterID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		return nil
+	}}
+}
+
+// readingCheck is ready while the last successful reading, as returned by
+// last, is at most maxAge old. Before the first reading it is ready for
+// maxAge after started.
+func readingCheck(last func() time.Time, maxAge time.Duration, started time.Time, c genai.Clock) ReadyCheck {
+	return ReadyCheck{Name: checkReading, Check: func(context.Context) error {
+		at := last()
+		if at.IsZero() {
+			if age := genai.Since(c, started); age > maxAge {
+				return fmt.Errorf("no reading since start %s ago", age.Round(time.Second))
+			}
+			return nil
+		}
+		if age := genai.Since(c, at); age > maxAge {
+			return fmt.Errorf("last reading %s ago, over %s", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}}
+}
+
+// breakerCheck is ready while b is not open.
+func breakerCheck(b *genai.Breaker) ReadyCheck {
+	return ReadyCheck{Name: checkBreaker, Check: func(context.Context) error {
+		if s := b.State(); s == genai.BreakerOpen {
+			return fmt.Errorf("circuit breaker %s after %d trips", s, b.Trips())
+		}
+		return nil
+	}}
+}
+
+// mqttCheck is ready while connected reports a broker connection.
+func mqttCheck(connected func() bool) ReadyCheck {
+	return ReadyCheck{Name: checkMQTT, Check: func(context.Context) error {
+		if !connected() {
+			return errors.New("not connected to the broker")
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// downStore is a store whose queries fail.
+type downStore struct{ store.Store }
+
+func (downStore) Latest(context.Context, string) (*genai.GasMeterReadResult, error) {
+	return nil, errors.New("disk unavailable")
+}
+
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	start := time.Date(2025, 11, 17, 7, 0, 0, 0, time.UTC)
+	clock := genaitest.NewClock(start)
+	clock.Advance(time.Hour)
+	lastReading := start.Add(50 * time.Minute)
+	at := func(t time.Time) func() time.Time { return func() time.Time { return t } }
+
+	open := genai.NewBreakerWithClock(1, time.Minute, clock)
+	open.Allow()
+	open.Done(errors.New("503"))
+
+	tests := []struct {
+		name   string
+		checks []ReadyCheck
+		failed string // the failing check, if any
+	}{
+		{"ready", []ReadyCheck{
+			storeCheck(store.NewMemory(), "home"), // no readings yet is fine
+			readingCheck(at(lastReading), 30*time.Minute, start, clock),
+			breakerCheck(genai.NewBreakerWithClock(1, time.Minute, clock)),
+			mqttCheck(func() bool { return true }),
+		}, ""},
+		{"store down", []ReadyCheck{storeCheck(downStore{store.NewMemory()}, "home"), mqttCheck(func() bool { return true })}, checkStore},
+		{"stale reading", []ReadyCheck{readingCheck(at(start), 30*time.Minute, start, clock)}, checkReading},
+		{"no reading since start", []ReadyCheck{readingCheck(at(time.Time{}), 30*time.Minute, start, clock)}, checkReading},
+		{"breaker open", []ReadyCheck{breakerCheck(open)}, checkBreaker},
+		{"broker disconnected", []ReadyCheck{mqttCheck(func() bool { return false })}, checkMQTT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := &Health{Version: "test", Started: start, Checks: tt.checks, Clock: clock}
+			router := gin.New()
+			router.GET("/readyz", h.ReadyzHandler)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var body struct {
+				Status string                 `json:"status"`
+				Checks map[string]checkResult `json:"checks"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			want := http.StatusOK
+			if tt.failed != "" {
+				want = http.StatusServiceUnavailable
+			}
+			if w.Code != want || len(body.Checks) != len(tt.checks) {
+				t.Fatalf("GET /readyz = %d %s; want %d with %d checks", w.Code, w.Body, want, len(tt.checks))
+			}
+			for name, r := range body.Checks {
+				if failed := name == tt.failed; r.OK == failed || failed != (r.Error != "") {
+					t.Fatalf("check %s = %+v; want failed %v", name, r, failed)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	clock := genaitest.NewClock(time.Date(2025, 11, 17, 7, 0, 0, 0, time.UTC))
+	h := &Health{Version: "v1.2.3", Started: clock.Now(), Checks: []ReadyCheck{mqttCheck(func() bool { return false })}, Clock: clock}
+	clock.Advance(90 * time.Second)
+	router := gin.New()
+	router.GET("/healthz", h.HealthzHandler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Liveness does not depend on the readiness checks.
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK || body["version"] != "v1.2.3" || body["uptime"] != "1m30s" {
+		t.Fatalf("GET /healthz = %d %s (%v)", w.Code, w.Body, err)
+	}
+}
+
+func TestConfigReadyChecks(t *testing.T) {
+	t.Parallel()
+
+	var c Config
+	c.Store.Path = "readings.jsonl"
+	c.MQTT.Host = "mqtt://broker"
+	if got := c.ReadyChecks(); len(got) != 3 || got[0] != checkStore || got[1] != checkReading || got[2] != checkMQTT {
+		t.Fatalf("ReadyChecks = %v; want store, reading and mqtt", got)
+	}
+	c.Readiness.Checks = []string{checkStore}
+	if got := c.ReadyChecks(); len(got) != 1 {
+		t.Fatalf("ReadyChecks = %v; want only store", got)
+	}
+	for _, name := range []string{checkBreaker, "disk"} {
+		if err := c.checkApplies(name); err == nil {
+			t.Fatalf("checkApplies(%q) = nil; want an error", name)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Package anchor designates the daily anchor reading of a meter: its value
+// at a fixed local time of each day, such as the midnight value a supplier
+// bills from, taken from the reading closest to that time or interpolated
+// between the readings around it.
+package anchor
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// StateKey is the [store.StateStore] key the anchors are saved under.
+const StateKey = "anchors"
+
+// Modes of [Config.Mode].
+const (
+	ModeNearest     = "nearest"     // the reading closest to the anchor time (default)
+	ModeInterpolate = "interpolate" // the value at the anchor time, between the readings around it
+)
+
+// DefaultMaxDistance is the default of [Config.MaxDistance].
+const DefaultMaxDistance = 12 * time.Hour
+
+// Config sets the anchor of each day.
+type Config struct {
+	// At is the local time of day of the anchor, "HH:MM" (default "00:00").
+	// On a day the time does not exist, as in a daylight saving gap, it is
+	// the time as far past the gap.
+	At   string `yaml:"at"`
+	Mode string `yaml:"mode"`
+	// MaxDistance is how far from the anchor time the readings it is taken
+	// from may be (default 12h, at most 24h); a day without one has no
+	// anchor.
+	MaxDistance time.Duration `yaml:"max_distance"`
+}
+
+// Validate checks c.
+func (c Config) Validate() error {
+	if _, _, err := c.clock(); err != nil {
+		return err
+	}
+	switch c.Mode {
+	case "", ModeNearest, ModeInterpolate:
+	default:
+		return fmt.Errorf("unknown mode %q, want %s or %s", c.Mode, ModeNearest, ModeInterpolate)
+	}
+	if c.MaxDistance < 0 || c.MaxDistance > 24*time.Hour {
+		return fmt.Errorf("max_distance %s is not between 0 and 24h", c.MaxDistance)
+	}
+	return nil
+}
+
+// clock returns the hour and minute of At.
+func (c Config) clock() (hour, min int, err error) {
+	if c.At == "" {
+		return 0, 0, nil
+	}
+	t, err := time.Parse("15:04", c.At)
+	if err != nil {
+		return 0, 0, fmt.Errorf("at %q is not HH:MM", c.At)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// reach returns MaxDistance or its default.
+func (c Config) reach() time.Duration {
+	if c.MaxDistance > 0 {
+		return c.MaxDistance
+	}
+	return DefaultMaxDistance
+}
+
+// Time returns the anchor time of the day of t in loc.
+func (c Config) Time(t time.Time, loc *time.Location) time.Time {
+	hour, min, _ := c.clock() // checked by Validate
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, hour, min, 0, 0, loc)
+}
+
+// Affected returns the range of anchor times [from, to) a reading at t may
+// be the anchor of, or be interpolated into: those a saved, corrected or
+// backfilled reading at t makes [Keeper.Recompute] necessary for.
+func (c Config) Affected(t time.Time) (from, to time.Time) {
+	return t.Add(-c.reach()), t.Add(c.reach() + time.Nanosecond)
+}
+
+// Anchor is the value of a meter at the anchor time of a day.
+type Anchor struct {
+	Date string    `json:"date"` // the local day, YYYY-MM-DD
+	At   time.Time `json:"at"`   // the anchor time of the day
+	// Read is the value in the meter's pattern, and Value the same as a number.
+	Read  string  `json:"read"`
+	Value float64 `json:"value"`
+	// ReadingID and ReadAt are the reading the anchor is, unless it is
+	// Interpolated between Before and After.
+	ReadingID    string    `json:"reading_id,omitempty"`
+	ReadAt       time.Time `json:"read_at,omitzero"`
+	Interpolated bool      `json:"interpolated,omitempty"`
+	Before       string    `json:"before,omitempty"`
+	After        string    `json:"after,omitempty"`
+}
+
+// Select returns the anchor of the day of day in loc from rs, readings of m
+// oldest first, or false if none is within MaxDistance of its anchor time.
+// In ModeInterpolate the anchor is interpolated linearly between the last
+// reading before the anchor time and the first after it, allowing for the
+// counter rolling over; without both, or across a decrease, it is the
+// nearest reading.
+func Select(c Config, m genai.Meter, day time.Time, loc *time.Location, rs []*genai.GasMeterReadResult) (Anchor, bool) {
+	at := c.Time(day, loc)
+	reach := c.reach()
+	lo := sort.Search(len(rs), func(i int) bool { return !rs[i].ReadAt.Before(at.Add(-reach)) })
+	var before, after *genai.GasMeterReadResult
+	var vBefore, vAfter float64
+	for _, r := range rs[lo:] {
+		if r.ReadAt.After(at.Add(reach)) {
+			break
+		}
+		v, err := genai.ParseRead(m, r.Read)
+		if err != nil {
+			continue
+		}
+		switch {
+		case r.ReadAt.Before(at):
+			before, vBefore = r, v // the last one before
+		case after == nil:
+			after, vAfter = r, v
+		}
+	}
+	a := Anchor{Date: at.Format(time.DateOnly), At: at}
+	if c.Mode == ModeInterpolate && before != nil && after != nil && after.ReadAt.After(at) {
+		if d, ok := m.Delta(vBefore, vAfter); ok {
+			f := float64(at.Sub(before.ReadAt)) / float64(after.ReadAt.Sub(before.ReadAt))
+			a.Value = m.Wrap(vBefore + d*f)
+			a.Read = m.FormatRead(a.Value)
+			a.Value, _ = strconv.ParseFloat(a.Read, 64)
+			a.Interpolated = true
+			a.Before, a.After = readingID(m, before), readingID(m, after)
+			return a, true
+		}
+	}
+	near, v := before, vBefore
+	if after != nil && (near == nil || after.ReadAt.Sub(at) < at.Sub(near.ReadAt)) {
+		near, v = after, vAfter
+	}
+	if near == nil {
+		return Anchor{}, false
+	}
+	a.Read, a.Value = near.Read, v
+	a.ReadingID, a.ReadAt = readingID(m, near), near.ReadAt
+	return a, true
+}
+
+// FromReadings returns the anchors with from <= At < to from rs, readings
+// of m oldest first that must cover MaxDistance around the range; days
+// without an anchor are left out.
+func FromReadings(c Config, m genai.Meter, from, to time.Time, loc *time.Location, rs []*genai.GasMeterReadResult) []Anchor {
+	var out []Anchor
+	for day := c.Time(from, loc).AddDate(0, 0, -1); day.Before(to); day = nextDay(day, loc) {
+		at := c.Time(day, loc)
+		if at.Before(from) || !at.Before(to) {
+			continue
+		}
+		if a, ok := Select(c, m, day, loc, rs); ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// nextDay returns the start of the day after day in loc: AddDate alone
+// would drift from the anchor time across a daylight saving gap.
+func nextDay(day time.Time, loc *time.Location) time.Time {
+	y, m, d := day.In(loc).Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+}
+
+// Days returns the anchors with from <= At < to of meterID, computed from
+// its history in s.
+func Days(ctx context.Context, s store.Store, c Config, m genai.Meter, meterID string, from, to time.Time, loc *time.Location) ([]Anchor, error) {
+	reach := c.reach()
+	rs, err := s.ReadingsBetween(ctx, meterID, from.Add(-reach), to.Add(reach+time.Nanosecond))
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	return FromReadings(c, m, from, to, loc, rs), nil
+}
+
+// equal reports whether a and b are the same anchor, whatever the time
+// zones of their times.
+func (a Anchor) equal(b Anchor) bool {
+	a.At, a.ReadAt = a.At.UTC(), a.ReadAt.UTC()
+	b.At, b.ReadAt = b.At.UTC(), b.ReadAt.UTC()
+	return a == b
+}
+
+func readingID(m genai.Meter, r *genai.GasMeterReadResult) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return genai.ReadingID(m.ID, r)
+}
+
+// Keeper saves the anchors of a meter in the state of its store, each with
+// the reading it was taken from, so that they are served without walking
+// the history.
+type Keeper struct {
+	s   store.Store
+	ss  store.StateStore
+	cfg Config
+	m   genai.Meter
+	loc *time.Location
+
+	mu sync.Mutex // serializes the updates of the saved anchors
+}
+
+// NewKeeper returns a Keeper of the anchors of m in s, a store that also
+// keeps state.
+func NewKeeper(s store.Store, c Config, m genai.Meter, loc *time.Location) (*Keeper, error) {
+	ss, ok := s.(store.StateStore)
+	if !ok {
+		return nil, fmt.Errorf("store %T keeps no state for anchors", s)
+	}
+	return &Keeper{s: s, ss: ss, cfg: c, m: m, loc: loc}, nil
+}
+
+// Config returns the config of k.
+func (k *Keeper) Config() Config { return k.cfg }
+
+// Recompute computes the anchors with from <= At < to of meterID again and
+// saves them, dropping those of days left without one, as after readings
+// are backfilled or corrected. It returns the dates whose anchor was added,
+// changed or dropped.
+func (k *Keeper) Recompute(ctx context.Context, meterID string, from, to time.Time) ([]string, error) {
+	as, err := Days(ctx, k.s, k.cfg, k.m, meterID, from, to, k.loc)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	saved, err := k.load(ctx, meterID)
+	if err != nil {
+		return nil, err
+	}
+	fresh := make(map[string]Anchor, len(as))
+	for _, a := range as {
+		fresh[a.Date] = a
+	}
+	var changed []string
+	for date, a := range saved {
+		if a.At.Before(from) || !a.At.Before(to) {
+			continue
+		}
+		if _, ok := fresh[date]; !ok {
+			delete(saved, date)
+			changed = append(changed, date)
+		}
+	}
+	for date, a := range fresh {
+		if old, ok := saved[date]; ok && old.equal(a) {
+			continue
+		}
+		saved[date] = a
+		changed = append(changed, date)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	if err := k.ss.SaveState(ctx, meterID, StateKey, saved); err != nil {
+		return nil, fmt.Errorf("save anchors: %w", err)
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// Saved returns the saved anchors with from <= At < to of meterID, oldest
+// first.
+func (k *Keeper) Saved(ctx context.Context, meterID string, from, to time.Time) ([]Anchor, error) {
+	k.mu.Lock()
+	saved, err := k.load(ctx, meterID)
+	k.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []Anchor
+	for _, date := range slices.Sorted(maps.Keys(saved)) {
+		if a := saved[date]; !a.At.Before(from) && a.At.Before(to) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// load returns the saved anchors of meterID by date.
+func (k *Keeper) load(ctx context.Context, meterID string) (map[string]Anchor, error) {
+	saved := make(map[string]Anchor)
+	if err := k.ss.LoadState(ctx, meterID, StateKey, &saved); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("load anchors: %w", err)
+	}
+	return saved, nil
+}
+
+// csvHeader is the first row of [WriteCSV].
+var csvHeader = []string{"date", "at", "read", "value", "reading_id", "read_at", "interpolated"}
+
+// WriteCSV writes as to w as CSV with a header row, in the time zone of
+// their anchor times.
+func WriteCSV(w io.Writer, as []Anchor) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, a := range as {
+		readAt := ""
+		if !a.ReadAt.IsZero() {
+			readAt = a.ReadAt.In(a.At.Location()).Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			a.Date,
+			a.At.Format(time.RFC3339),
+			a.Read,
+			strconv.FormatFloat(a.Value, 'f', -1, 64),
+			a.ReadingID,
+			readAt,
+			strconv.FormatBool(a.Interpolated),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,286 @@
+package anchor_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var meter = genai.Meter{ID: "home", IntDigits: 5, FracDigits: 3, Unit: "m³"}
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	return loc
+}
+
+func reading(read string, at time.Time) *genai.GasMeterReadResult {
+	r := &genai.GasMeterReadResult{Read: read, ReadAt: at}
+	r.ID = genai.ReadingID(meter.ID, r)
+	return r
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	loc := berlin(t)
+	day := time.Date(2025, 11, 7, 0, 0, 0, 0, loc)
+	rs := []*genai.GasMeterReadResult{
+		reading("02924.000", day.Add(-3*time.Hour)), // 21:00 the day before
+		reading("02924.400", day.Add(time.Hour)),    // 01:00
+		reading("02925.000", day.Add(13*time.Hour)), // 13:00
+	}
+	tests := []struct {
+		name     string
+		cfg      anchor.Config
+		rs       []*genai.GasMeterReadResult
+		wantRead string
+		wantFrom string // the reading ID, or "interpolated"
+		wantNone bool
+	}{
+		{"nearest", anchor.Config{}, rs, "02924.400", rs[1].ID, false},
+		{"nearest at noon", anchor.Config{At: "12:00"}, rs, "02925.000", rs[2].ID, false},
+		{"interpolated", anchor.Config{Mode: anchor.ModeInterpolate}, rs, "02924.300", "interpolated", false},
+		{"interpolated at 07:00", anchor.Config{At: "07:00", Mode: anchor.ModeInterpolate}, rs, "02924.700", "interpolated", false},
+		{"interpolation needs both sides", anchor.Config{Mode: anchor.ModeInterpolate}, rs[1:], "02924.400", rs[1].ID, false},
+		{"too far", anchor.Config{MaxDistance: 30 * time.Minute}, rs, "", "", true},
+		{"no readings", anchor.Config{}, nil, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, ok := anchor.Select(tt.cfg, meter, day, loc, tt.rs)
+			if ok == tt.wantNone {
+				t.Fatalf("Select = %+v, %v; want an anchor %v", a, ok, !tt.wantNone)
+			}
+			if !ok {
+				return
+			}
+			from := a.ReadingID
+			if a.Interpolated {
+				from = "interpolated"
+			}
+			if a.Read != tt.wantRead || from != tt.wantFrom || a.Date != "2025-11-07" {
+				t.Fatalf("Select = %s on %s from %s, want %s from %s", a.Read, a.Date, from, tt.wantRead, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestSelectRollover(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC)
+	rs := []*genai.GasMeterReadResult{
+		reading("99999.800", day.Add(-time.Hour)),
+		reading("00000.200", day.Add(time.Hour)),
+	}
+	a, ok := anchor.Select(anchor.Config{Mode: anchor.ModeInterpolate}, meter, day, time.UTC, rs)
+	if !ok || a.Read != "00000.000" || !a.Interpolated {
+		t.Fatalf("Select across the rollover = %+v, %v; want 00000.000 interpolated", a, ok)
+	}
+
+	// A decrease is no consumption to interpolate: the nearest reading is.
+	rs[1] = reading("99999.500", day.Add(2*time.Hour))
+	a, ok = anchor.Select(anchor.Config{Mode: anchor.ModeInterpolate}, meter, day, time.UTC, rs)
+	if !ok || a.Read != "99999.800" || a.Interpolated {
+		t.Fatalf("Select across a decrease = %+v, %v; want the nearest 99999.800", a, ok)
+	}
+}
+
+// TestFromReadingsDST covers the days daylight saving time starts and ends,
+// 23 and 25 hours long, and an anchor time the start skips, along with a
+// day without readings.
+func TestFromReadingsDST(t *testing.T) {
+	t.Parallel()
+
+	loc := berlin(t)
+	// Readings every 6h from 2025-03-29 to 2025-04-01 and around the end of
+	// daylight saving time, but none on 2025-03-31.
+	var rs []*genai.GasMeterReadResult
+	v := 1000.0
+	add := func(from, to time.Time) {
+		for at := from; at.Before(to); at = at.Add(6 * time.Hour) {
+			v += 1.5
+			rs = append(rs, reading(meter.FormatRead(v), at))
+		}
+	}
+	add(time.Date(2025, 3, 29, 0, 30, 0, 0, time.UTC), time.Date(2025, 3, 30, 23, 0, 0, 0, time.UTC))
+	add(time.Date(2025, 4, 1, 0, 30, 0, 0, time.UTC), time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC))
+	add(time.Date(2025, 10, 25, 0, 30, 0, 0, time.UTC), time.Date(2025, 10, 28, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name      string
+		cfg       anchor.Config
+		from, to  time.Time
+		wantDates []string
+		wantAt    []string // the anchor times, in loc
+	}{
+		{
+			name:      "midnight through the start",
+			cfg:       anchor.Config{MaxDistance: 3 * time.Hour},
+			from:      time.Date(2025, 3, 29, 0, 0, 0, 0, loc),
+			to:        time.Date(2025, 4, 2, 0, 0, 0, 0, loc),
+			wantDates: []string{"2025-03-29", "2025-03-30", "2025-04-01"},
+			wantAt:    []string{"2025-03-29T00:00:00+01:00", "2025-03-30T00:00:00+01:00", "2025-04-01T00:00:00+02:00"},
+		},
+		{
+			name:      "in the skipped hour",
+			cfg:       anchor.Config{At: "02:30"},
+			from:      time.Date(2025, 3, 30, 0, 0, 0, 0, loc),
+			to:        time.Date(2025, 3, 31, 0, 0, 0, 0, loc),
+			wantDates: []string{"2025-03-30"},
+			wantAt:    []string{"2025-03-30T03:30:00+02:00"},
+		},
+		{
+			name:      "midnight through the end",
+			cfg:       anchor.Config{Mode: anchor.ModeInterpolate},
+			from:      time.Date(2025, 10, 25, 0, 0, 0, 0, loc),
+			to:        time.Date(2025, 10, 28, 0, 0, 0, 0, loc),
+			wantDates: []string{"2025-10-25", "2025-10-26", "2025-10-27"},
+			wantAt:    []string{"2025-10-25T00:00:00+02:00", "2025-10-26T00:00:00+02:00", "2025-10-27T00:00:00+01:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			as := anchor.FromReadings(tt.cfg, meter, tt.from, tt.to, loc, rs)
+			var dates, ats []string
+			for _, a := range as {
+				dates = append(dates, a.Date)
+				ats = append(ats, a.At.Format(time.RFC3339))
+				if d := a.ReadAt.Sub(a.At).Abs(); !a.Interpolated && d > tt.cfg.MaxDistance && tt.cfg.MaxDistance > 0 {
+					t.Errorf("anchor of %s read %s from its time", a.Date, d)
+				}
+			}
+			if !slices.Equal(dates, tt.wantDates) || !slices.Equal(ats, tt.wantAt) {
+				t.Fatalf("anchors on %q at %q, want %q at %q", dates, ats, tt.wantDates, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestKeeperRecompute(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	loc := berlin(t)
+	s := store.NewMemory()
+	k, err := anchor.NewKeeper(s, anchor.Config{}, meter, loc)
+	if err != nil {
+		t.Fatalf("NewKeeper: %v", err)
+	}
+	day := time.Date(2025, 11, 7, 0, 0, 0, 0, loc)
+	late := reading("02924.400", day.Add(5*time.Hour))
+	save := func(r *genai.GasMeterReadResult) []string {
+		t.Helper()
+		if err := s.Save(ctx, meter.ID, r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		from, to := k.Config().Affected(r.ReadAt)
+		changed, err := k.Recompute(ctx, meter.ID, from, to)
+		if err != nil {
+			t.Fatalf("Recompute: %v", err)
+		}
+		return changed
+	}
+	saved := func() []anchor.Anchor {
+		t.Helper()
+		as, err := k.Saved(ctx, meter.ID, day.AddDate(0, 0, -7), day.AddDate(0, 0, 7))
+		if err != nil {
+			t.Fatalf("Saved: %v", err)
+		}
+		return as
+	}
+
+	if changed := save(late); !slices.Equal(changed, []string{"2025-11-07"}) {
+		t.Fatalf("changed %q, want the day of the reading", changed)
+	}
+	if changed := save(reading("02924.600", day.Add(8*time.Hour))); len(changed) != 0 {
+		t.Fatalf("a later reading changed %q", changed)
+	}
+	// A reading backfilled closer to midnight becomes the anchor.
+	backfilled := reading("02924.300", day.Add(-time.Hour))
+	if changed := save(backfilled); !slices.Equal(changed, []string{"2025-11-07"}) {
+		t.Fatalf("backfill changed %q", changed)
+	}
+	as := saved()
+	if len(as) != 1 || as[0].ReadingID != backfilled.ID || as[0].Read != "02924.300" {
+		t.Fatalf("saved anchors %+v, want the backfilled reading", as)
+	}
+
+	// The anchors survive the keeper, and a day whose readings are pruned
+	// loses its anchor once recomputed.
+	k2, _ := anchor.NewKeeper(s, anchor.Config{}, meter, loc)
+	if as, _ := k2.Saved(ctx, meter.ID, day, day.AddDate(0, 0, 1)); len(as) != 1 {
+		t.Fatalf("saved anchors of a new keeper %+v", as)
+	}
+	if _, err := s.Prune(ctx, day.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := k2.Recompute(ctx, meter.ID, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+	if err != nil || !slices.Equal(changed, []string{"2025-11-07"}) || len(saved()) != 0 {
+		t.Fatalf("Recompute after prune = %q, %v; saved %+v", changed, err, saved())
+	}
+}
+
+func TestNewKeeperNeedsState(t *testing.T) {
+	t.Parallel()
+
+	if _, err := anchor.NewKeeper(statelessStore{store.NewMemory()}, anchor.Config{}, meter, time.UTC); err == nil {
+		t.Fatal("NewKeeper on a store without state succeeded")
+	}
+}
+
+type statelessStore struct{ store.Store }
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	as := []anchor.Anchor{
+		{Date: "2025-11-07", At: at, Read: "02924.400", Value: 2924.4, ReadingID: "abc", ReadAt: at.Add(time.Hour).UTC()},
+		{Date: "2025-11-08", At: at.AddDate(0, 0, 1), Read: "02925.000", Value: 2925, Interpolated: true},
+	}
+	var b strings.Builder
+	if err := anchor.WriteCSV(&b, as); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	want := "date,at,read,value,reading_id,read_at,interpolated\n" +
+		"2025-11-07,2025-11-07T00:00:00+01:00,02924.400,2924.4,abc,2025-11-07T01:00:00+01:00,false\n" +
+		"2025-11-08,2025-11-08T00:00:00+01:00,02925.000,2925,,,true\n"
+	if b.String() != want {
+		t.Fatalf("WriteCSV =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg     anchor.Config
+		wantErr bool
+	}{
+		{anchor.Config{}, false},
+		{anchor.Config{At: "06:30", Mode: anchor.ModeInterpolate, MaxDistance: 24 * time.Hour}, false},
+		{anchor.Config{At: "6.30"}, true},
+		{anchor.Config{Mode: "closest"}, true},
+		{anchor.Config{MaxDistance: 25 * time.Hour}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package anomaly flags unusual gas consumption from the reading history.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Config sets when a reading is flagged. Zero fields use the defaults noted.
+type Config struct {
+	// Window is the history the baseline is computed over (default 14 days).
+	Window time.Duration
+	// Factor flags consumption above Factor times the baseline (0: off).
+	Factor float64
+	// Absolute flags consumption more than Absolute units per hour above
+	// the baseline (0: off). Unlike Factor it also works for hours whose
+	// baseline is zero.
+	Absolute float64
+	// MinSamples is how many baseline intervals are needed before a reading
+	// is judged at all (default 3).
+	MinSamples int
+	// MaxGap is the longest interval between two readings that is compared;
+	// consumption across longer gaps is not attributed to an hour (default 3h).
+	MaxGap time.Duration
+	// Location is the time zone of the hour of day (default time.Local).
+	Location *time.Location
+}
+
+// Anomaly is a reading whose consumption exceeds the baseline, with the
+// numbers behind the decision.
+type Anomaly struct {
+	MeterID string    `json:"meter_id"`
+	At      time.Time `json:"at"`
+	Read    string    `json:"read"`
+	Prev    string    `json:"prev"`
+	// Consumption is the usage since Prev over Hours, i.e. Rate units per hour.
+	Consumption float64 `json:"consumption"`
+	Hours       float64 `json:"hours"`
+	Rate        float64 `json:"rate"`
+	// Baseline is the mean rate of Samples intervals starting in the same
+	// hour of day within the window.
+	Baseline float64 `json:"baseline"`
+	Samples  int     `json:"samples"`
+	Hour     int     `json:"hour"`
+	Reason   string  `json:"reason"` // "factor" or "absolute"
+}
+
+func (a *Anomaly) String() string { return a.Format("") }
+
+// Format is String with the numbers in locale l.
+func (a *Anomaly) Format(l numfmt.Locale) string {
+	return fmt.Sprintf("%s/h since %s (%s over %sh) vs. baseline %s/h at %02d:00 over %d samples (%s)",
+		l.Float(a.Rate, 3), a.Prev, l.Float(a.Consumption, 3), l.Float(a.Hours, 1), l.Float(a.Baseline, 3), a.Hour, a.Samples, a.Reason)
+}
+
+// Analyzer checks new readings against the history in a store.
+type Analyzer struct {
+	store store.Store
+	meter genai.Meter
+	cfg   Config
+}
+
+// New returns an Analyzer reading the history of meters laid out as m from s.
+func New(s store.Store, m genai.Meter, cfg Config) *Analyzer {
+	if cfg.Window <= 0 {
+		cfg.Window = 14 * 24 * time.Hour
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 3
+	}
+	if cfg.MaxGap <= 0 {
+		cfg.MaxGap = 3 * time.Hour
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	return &Analyzer{store: s, meter: m, cfg: cfg}
+}
+
+// Check compares the consumption since the previous stored reading with the
+// baseline of the same hour of day. It returns nil if r is normal or cannot be
+// judged: no recent previous reading, too few baseline samples, or a
+// decrease that is not a rollover. r itself need not be saved yet.
+func (a *Analyzer) Check(ctx context.Context, meterID string, r *genai.GasMeterReadResult) (*Anomaly, error) {
+	cur, err := genai.ParseRead(a.meter, r.Read)
+	if err != nil {
+		return nil, err
+	}
+	history, err := a.store.ReadingsBetween(ctx, meterID, r.ReadAt.Add(-a.cfg.Window), r.ReadAt)
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	pts := points(a.meter, history)
+	if len(pts) == 0 {
+		return nil, nil
+	}
+	prev := pts[len(pts)-1]
+	last, ok := a.interval(prev, point{at: r.ReadAt, value: cur, read: r.Read, exchanged: r.MeterExchanged != nil})
+	if !ok {
+		return nil, nil
+	}
+
+	hour := last.hour(a.cfg.Location)
+	var sum float64
+	var n int
+	for i := 1; i < len(pts); i++ {
+		iv, ok := a.interval(pts[i-1], pts[i])
+		if ok && iv.hour(a.cfg.Location) == hour {
+			sum += iv.rate()
+			n++
+		}
+	}
+	if n < a.cfg.MinSamples {
+		return nil, nil
+	}
+	baseline := sum / float64(n)
+
+	var reason string
+	switch rate := last.rate(); {
+	case a.cfg.Factor > 0 && baseline > 0 && rate > a.cfg.Factor*baseline:
+		reason = "factor"
+	case a.cfg.Absolute > 0 && rate > baseline+a.cfg.Absolute:
+		reason = "absolute"
+	default:
+		return nil, nil
+	}
+	return &Anomaly{
+		MeterID:     meterID,
+		At:          r.ReadAt,
+		Read:        r.Read,
+		Prev:        prev.read,
+		Consumption: last.delta,
+		Hours:       last.hours(),
+		Rate:        last.rate(),
+		Baseline:    baseline,
+		Samples:     n,
+		Hour:        hour,
+		Reason:      reason,
+	}, nil
+}
+
+// point is a parsed reading.
+type point struct {
+	at    time.Time
+	value float64
+	read  string
+	// exchanged marks the first reading of a new meter, which no interval
+	// ends at.
+	exchanged bool
+}
+
+// points parses the readings, skipping stale, maintenance and unparseable
+// ones.
+func points(m genai.Meter, rs []*genai.GasMeterReadResult) []point {
+	pts := make([]point, 0, len(rs))
+	for _, r := range rs {
+		if !r.Counted() {
+			continue
+		}
+		v, err := genai.ParseRead(m, r.Read)
+		if err != nil {
+			continue
+		}
+		pts = append(pts, point{at: r.ReadAt, value: v, read: r.Read, exchanged: r.MeterExchanged != nil})
+	}
+	return pts
+}
+
+// interval is the consumption between two consecutive readings.
+type interval struct {
+	from, to time.Time
+	delta    float64
+}
+
+func (iv interval) hours() float64 { return iv.to.Sub(iv.from).Hours() }
+func (iv interval) rate() float64  { return iv.delta / iv.hours() }
+
+// hour is the hour of day the interval starts in.
+func (iv interval) hour(loc *time.Location) int { return iv.from.In(loc).Hour() }
+
+// interval returns the interval from p to q unless they are too far apart,
+// not in order, q is lower than p without a rollover or of a new meter.
+func (a *Analyzer) interval(p, q point) (interval, bool) {
+	d := q.at.Sub(p.at)
+	if d <= 0 || d > a.cfg.MaxGap || q.exchanged {
+		return interval{}, false
+	}
+	delta, ok := a.meter.Delta(p.value, q.value)
+	if !ok {
+		return interval{}, false
+	}
+	return interval{from: p.at, to: q.at, delta: delta}, true
+}
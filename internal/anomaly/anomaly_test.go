@@ -0,0 +1,134 @@
+package anomaly_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anomaly"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var start = time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+
+// history saves n hourly readings from start, beginning at value and
+// consuming rate(day, hour) per hour, and returns the value after them.
+// skip drops readings.
+func history(t *testing.T, n int, value float64, rate func(day, hour int) float64, skip func(day, hour int) bool) (*store.Memory, float64) {
+	t.Helper()
+	s := store.NewMemory()
+	for i := range n {
+		day, hour := i/24, i%24
+		if skip == nil || !skip(day, hour) {
+			r := &genai.GasMeterReadResult{Read: genai.DefaultMeter.FormatRead(value), ReadAt: start.Add(time.Duration(i) * time.Hour)}
+			if err := s.Save(context.Background(), "home", r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		value = wrap(value + rate(day, hour))
+	}
+	return s, value
+}
+
+// wrap rolls a 5-digit counter over.
+func wrap(v float64) float64 {
+	if v >= 100000 {
+		return v - 100000
+	}
+	return v
+}
+
+func constant(r float64) func(int, int) float64 { return func(int, int) float64 { return r } }
+
+// checkedHours is the history length of the tests: two weeks ending at 12:00,
+// so the checked interval is 12:00-13:00.
+const checkedHours = 14*24 + 13
+
+// next is the reading value at the hour after n hourly readings.
+func next(n int, value float64) *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{
+		Read:   genai.DefaultMeter.FormatRead(wrap(value)),
+		ReadAt: start.Add(time.Duration(n) * time.Hour),
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	cfg := anomaly.Config{Factor: 3, Location: time.UTC}
+	tests := []struct {
+		name   string
+		start  float64
+		rate   func(day, hour int) float64
+		skip   func(day, hour int) bool
+		spike  float64 // consumption of the checked hour
+		cfg    anomaly.Config
+		reason string // "" for no anomaly
+	}{
+		{"normal", 2924, constant(0.1), nil, 0.11, cfg, ""},
+		{"spike", 2924, constant(0.1), nil, 0.5, cfg, "factor"},
+		{"gradual increase", 2924, func(day, _ int) float64 { return 0.1 * (1 + 0.05*float64(day)) }, nil, 0.17, cfg, ""},
+		{"spike at an idle hour", 2924, func(_, hour int) float64 {
+			if hour == 12 {
+				return 0
+			}
+			return 0.1
+		}, nil, 0.5, anomaly.Config{Factor: 3, Absolute: 0.2, Location: time.UTC}, "absolute"},
+		{"gaps in history", 2924, constant(0.1), func(day, _ int) bool { return day%2 == 1 }, 0.5, cfg, "factor"},
+		{"rollover spike", 99999.8 - 0.1*(checkedHours-1), constant(0.1), nil, 0.5, cfg, "factor"},
+		{"normal rollover", 99999.95 - 0.1*(checkedHours-1), constant(0.1), nil, 0.1, cfg, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, last := history(t, checkedHours, tt.start, tt.rate, tt.skip)
+			r := next(checkedHours, last-tt.rate(14, 12)+tt.spike)
+			a, err := anomaly.New(s, genai.DefaultMeter, tt.cfg).Check(context.Background(), "home", r)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			got := ""
+			if a != nil {
+				got = a.Reason
+				if a.Hour != 12 || a.Samples < 3 || a.Hours != 1 {
+					t.Fatalf("anomaly = %+v", a)
+				}
+			}
+			if got != tt.reason {
+				t.Fatalf("anomaly = %v, want reason %q", a, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCheckTooLittleHistory(t *testing.T) {
+	t.Parallel()
+
+	s, last := history(t, 2*24+13, 2924, constant(0.1), nil)
+	a := anomaly.New(s, genai.DefaultMeter, anomaly.Config{Factor: 3, Location: time.UTC})
+	if got, err := a.Check(context.Background(), "home", next(2*24+13, last+0.5)); got != nil || err != nil {
+		t.Fatalf("Check with 2 samples = %v, %v; want nothing", got, err)
+	}
+}
+
+func TestCheckMissingPrevious(t *testing.T) {
+	t.Parallel()
+
+	s, last := history(t, checkedHours, 2924, constant(0.1), nil)
+	a := anomaly.New(s, genai.DefaultMeter, anomaly.Config{Factor: 3, Location: time.UTC})
+	ctx := context.Background()
+
+	// The last stored reading is 5h old: the usage cannot be attributed to an hour.
+	late := next(checkedHours+4, last+5)
+	if got, err := a.Check(ctx, "home", late); got != nil || err != nil {
+		t.Fatalf("Check after a gap = %v, %v; want nothing", got, err)
+	}
+	// A decrease that is not a rollover is a misread, not consumption.
+	if got, err := a.Check(ctx, "home", next(checkedHours, last-1)); got != nil || err != nil {
+		t.Fatalf("Check after a decrease = %v, %v; want nothing", got, err)
+	}
+	if got, err := a.Check(ctx, "cabin", next(checkedHours, last+5)); got != nil || err != nil {
+		t.Fatalf("Check without history = %v, %v; want nothing", got, err)
+	}
+}
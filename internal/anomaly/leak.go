@@ -0,0 +1,204 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// LeakConfig sets the idle window in which no gas should flow.
+type LeakConfig struct {
+	// Start and End are the window as times of day, e.g. 2h and 5h for
+	// 02:00–05:00. A window with End before Start spans midnight.
+	Start, End time.Duration
+	// Threshold is the overnight consumption above which a night counts as flowing.
+	Threshold float64
+	// Nights is how many consecutive flowing nights raise an alert (default 3).
+	Nights int
+	// Location is the time zone of the window (default time.Local).
+	Location *time.Location
+}
+
+// NightFlow is the consumption measured in one idle window, between the first
+// and last reading available inside it.
+type NightFlow struct {
+	Date     string    `json:"date"` // the day the window ends, 2006-01-02
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	FromRead string    `json:"from_read"`
+	ToRead   string    `json:"to_read"`
+	Flow     float64   `json:"flow"`
+	// FlowChecks are the checks of the flow indicator in the window, see
+	// [LeakDetector.Observe].
+	FlowChecks []genai.FlowResult `json:"flow_checks,omitempty"`
+}
+
+// Leak reports steady overnight flow on consecutive nights, oldest first.
+type Leak struct {
+	MeterID string      `json:"meter_id"`
+	Nights  []NightFlow `json:"nights"`
+}
+
+func (l *Leak) String() string { return l.Format("") }
+
+// Format is String with the numbers in locale loc.
+func (l *Leak) Format(loc numfmt.Locale) string {
+	last := l.Nights[len(l.Nights)-1]
+	s := fmt.Sprintf("flow on %d consecutive nights, last %s between %s and %s (%s → %s)",
+		len(l.Nights), loc.Float(last.Flow, 3), last.From.Format("15:04"), last.To.Format("15:04"), last.FromRead, last.ToRead)
+	if n := len(last.FlowChecks); n > 0 {
+		moving := 0
+		for _, c := range last.FlowChecks {
+			if c.FlowDetected {
+				moving++
+			}
+		}
+		s += fmt.Sprintf("; flow indicator moving in %d of %d checks", moving, n)
+	}
+	return s
+}
+
+// FlowStateKey is the [store.StateStore] key the checks of the flow
+// indicator are saved under.
+const FlowStateKey = "flow_checks"
+
+// LeakDetector looks for consumption during the idle window in a store's history.
+type LeakDetector struct {
+	store store.Store
+	meter genai.Meter
+	cfg   LeakConfig
+
+	mu sync.Mutex // serializes the updates of the flow checks
+}
+
+// NewLeakDetector returns a LeakDetector for meters laid out as m.
+func NewLeakDetector(s store.Store, m genai.Meter, cfg LeakConfig) *LeakDetector {
+	if cfg.Nights <= 0 {
+		cfg.Nights = 3
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	return &LeakDetector{store: s, meter: m, cfg: cfg}
+}
+
+// Window returns the idle window ending on the day of t in the configured zone.
+func (d *LeakDetector) Window(t time.Time) (from, to time.Time) {
+	y, m, day := t.In(d.cfg.Location).Date()
+	at := func(day int, tod time.Duration) time.Time {
+		return time.Date(y, m, day, 0, 0, 0, 0, d.cfg.Location).Add(tod)
+	}
+	to = at(day, d.cfg.End)
+	if d.cfg.End > d.cfg.Start {
+		return at(day, d.cfg.Start), to
+	}
+	return at(day-1, d.cfg.Start), to
+}
+
+// InWindow reports whether t is inside an idle window.
+func (d *LeakDetector) InWindow(t time.Time) bool {
+	for _, day := range []time.Time{t, t.In(d.cfg.Location).AddDate(0, 0, 1)} {
+		if from, to := d.Window(day); !t.Before(from) && t.Before(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check looks at the last Nights idle windows that ended by now. It returns
+// a [Leak] if every one of them had more than Threshold flow, and nil if one
+// did not or lacks the two readings needed to measure it.
+func (d *LeakDetector) Check(ctx context.Context, meterID string, now time.Time) (*Leak, error) {
+	from, to := d.Window(now)
+	if to.After(now) {
+		from, to = d.Window(now.In(d.cfg.Location).AddDate(0, 0, -1))
+	}
+
+	checks, err := d.flowChecks(ctx, meterID)
+	if err != nil {
+		return nil, err
+	}
+	nights := make([]NightFlow, d.cfg.Nights)
+	for i := d.cfg.Nights - 1; i >= 0; i-- {
+		n, ok, err := d.night(ctx, meterID, from, to)
+		if err != nil || !ok || n.Flow <= d.cfg.Threshold {
+			return nil, err
+		}
+		for _, c := range checks {
+			if !c.At.Before(from) && !c.At.After(to) {
+				n.FlowChecks = append(n.FlowChecks, c)
+			}
+		}
+		nights[i] = n
+		from, to = d.Window(to.AddDate(0, 0, -1))
+	}
+	return &Leak{MeterID: meterID, Nights: nights}, nil
+}
+
+// night measures the flow between the first and last reading in [from, to].
+func (d *LeakDetector) night(ctx context.Context, meterID string, from, to time.Time) (NightFlow, bool, error) {
+	rs, err := d.store.ReadingsBetween(ctx, meterID, from, to.Add(time.Nanosecond))
+	if err != nil {
+		return NightFlow{}, false, fmt.Errorf("load history: %w", err)
+	}
+	pts := points(d.meter, rs)
+	if len(pts) < 2 {
+		return NightFlow{}, false, nil
+	}
+	first, last := pts[0], pts[len(pts)-1]
+	flow, ok := d.meter.Delta(first.value, last.value)
+	if !ok || slices.ContainsFunc(pts[1:], func(p point) bool { return p.exchanged }) {
+		return NightFlow{}, false, nil
+	}
+	return NightFlow{
+		Date:     to.Format("2006-01-02"),
+		From:     first.at.In(d.cfg.Location),
+		To:       last.at.In(d.cfg.Location),
+		FromRead: first.read,
+		ToRead:   last.read,
+		Flow:     flow,
+	}, true, nil
+}
+
+// Observe saves c, a check of the flow indicator of meterID, so that the
+// next [Leak] lists it with the night it was made in. Checks older than the
+// nights looked at are dropped. Without a store that keeps state it does
+// nothing.
+func (d *LeakDetector) Observe(ctx context.Context, meterID string, c genai.FlowResult) error {
+	ss, ok := d.store.(store.StateStore)
+	if !ok {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	checks, err := d.flowChecks(ctx, meterID)
+	if err != nil {
+		return err
+	}
+	oldest := c.At.AddDate(0, 0, -d.cfg.Nights-1)
+	checks = slices.DeleteFunc(checks, func(c genai.FlowResult) bool { return c.At.Before(oldest) })
+	if err := ss.SaveState(ctx, meterID, FlowStateKey, append(checks, c)); err != nil {
+		return fmt.Errorf("save flow checks: %w", err)
+	}
+	return nil
+}
+
+// flowChecks loads the saved checks of the flow indicator of meterID.
+func (d *LeakDetector) flowChecks(ctx context.Context, meterID string) ([]genai.FlowResult, error) {
+	ss, ok := d.store.(store.StateStore)
+	if !ok {
+		return nil, nil
+	}
+	var checks []genai.FlowResult
+	if err := ss.LoadState(ctx, meterID, FlowStateKey, &checks); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("load flow checks: %w", err)
+	}
+	return checks, nil
+}
@@ -0,0 +1,148 @@
+package anomaly_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anomaly"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var kst = time.FixedZone("KST", 9*60*60)
+
+// nights saves, for each night flow (nil for no readings), readings at the
+// given times of day in KST on consecutive days ending 2025-11-07, with the
+// flow spread evenly over them.
+func nights(t *testing.T, flows []*float64, times ...time.Duration) *store.Memory {
+	t.Helper()
+	s := store.NewMemory()
+	value := 2924.0
+	first := time.Date(2025, 11, 7, 0, 0, 0, 0, kst).AddDate(0, 0, 1-len(flows))
+	for i, f := range flows {
+		day := first.AddDate(0, 0, i)
+		if f == nil {
+			continue
+		}
+		for j, tod := range times {
+			if j > 0 {
+				value += *f / float64(len(times)-1)
+			}
+			// Stored in UTC: the window must still be found in KST.
+			r := &genai.GasMeterReadResult{Read: genai.DefaultMeter.FormatRead(value), ReadAt: day.Add(tod).UTC()}
+			if err := s.Save(context.Background(), "home", r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		value += 1.5 // daytime use
+	}
+	return s
+}
+
+func flow(f float64) *float64 { return &f }
+
+func TestLeakDetector(t *testing.T) {
+	t.Parallel()
+
+	cfg := anomaly.LeakConfig{Start: 2 * time.Hour, End: 5 * time.Hour, Threshold: 0.01, Nights: 3, Location: kst}
+	full := []time.Duration{2 * time.Hour, 3 * time.Hour, 4 * time.Hour, 5 * time.Hour}
+	now := time.Date(2025, 11, 7, 6, 0, 0, 0, kst)
+	tests := []struct {
+		name  string
+		flows []*float64
+		times []time.Duration
+		now   time.Time
+		leak  bool
+	}{
+		{"leak", []*float64{flow(0.03), flow(0.02), flow(0.04)}, full, now, true},
+		{"one quiet night", []*float64{flow(0.03), flow(0), flow(0.04)}, full, now, false},
+		{"too few nights", []*float64{flow(0.03), flow(0.04)}, full, now, false},
+		{"night without readings", []*float64{flow(0.03), nil, flow(0.04)}, full, now, false},
+		{"sparse readings", []*float64{flow(0.03), flow(0.02), flow(0.04)}, []time.Duration{3*time.Hour + 10*time.Minute, 4*time.Hour + 20*time.Minute}, now, true},
+		// Before 05:00 KST tonight's window is not over: the nights up to yesterday count.
+		{"window not over", []*float64{flow(0.03), flow(0.02), flow(0.04)}, full, time.Date(2025, 11, 8, 4, 0, 0, 0, kst), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := nights(t, tt.flows, tt.times...)
+			leak, err := anomaly.NewLeakDetector(s, genai.DefaultMeter, cfg).Check(context.Background(), "home", tt.now)
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if (leak != nil) != tt.leak {
+				t.Fatalf("leak = %v, want %v", leak, tt.leak)
+			}
+			if leak == nil {
+				return
+			}
+			last := leak.Nights[len(leak.Nights)-1]
+			if len(leak.Nights) != 3 || last.Date != "2025-11-07" || last.Flow < 0.039 || last.Flow > 0.041 {
+				t.Fatalf("leak = %+v", leak)
+			}
+		})
+	}
+}
+
+func TestLeakWindowAcrossMidnight(t *testing.T) {
+	t.Parallel()
+
+	d := anomaly.NewLeakDetector(store.NewMemory(), genai.DefaultMeter,
+		anomaly.LeakConfig{Start: 23 * time.Hour, End: 5 * time.Hour, Location: kst})
+	from, to := d.Window(time.Date(2025, 11, 6, 22, 0, 0, 0, time.UTC)) // 07:00 KST on the 7th
+	if want := time.Date(2025, 11, 6, 23, 0, 0, 0, kst); !from.Equal(want) {
+		t.Fatalf("from = %v, want %v", from, want)
+	}
+	if want := time.Date(2025, 11, 7, 5, 0, 0, 0, kst); !to.Equal(want) {
+		t.Fatalf("to = %v, want %v", to, want)
+	}
+	for _, tt := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2025, 11, 6, 23, 30, 0, 0, kst), true},
+		{time.Date(2025, 11, 7, 4, 59, 0, 0, kst), true},
+		{time.Date(2025, 11, 7, 5, 0, 0, 0, kst), false},
+		{time.Date(2025, 11, 7, 12, 0, 0, 0, kst), false},
+	} {
+		if got := d.InWindow(tt.t); got != tt.want {
+			t.Errorf("InWindow(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestLeakFlowChecks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := nights(t, []*float64{flow(0.03), flow(0.02), flow(0.04)}, 2*time.Hour, 5*time.Hour)
+	d := anomaly.NewLeakDetector(s, genai.DefaultMeter,
+		anomaly.LeakConfig{Start: 2 * time.Hour, End: 5 * time.Hour, Threshold: 0.01, Nights: 3, Location: kst})
+	checks := []genai.FlowResult{
+		{FlowDetected: true, Rotation: 0.1, At: time.Date(2025, 10, 20, 3, 0, 0, 0, kst)}, // dropped as too old
+		{FlowDetected: true, Rotation: 0.1, At: time.Date(2025, 11, 7, 3, 0, 0, 0, kst)},
+		{FlowDetected: false, At: time.Date(2025, 11, 7, 4, 0, 0, 0, kst)},
+		{FlowDetected: true, At: time.Date(2025, 11, 7, 12, 0, 0, 0, kst)}, // outside the window
+	}
+	for _, c := range checks {
+		if err := d.Observe(ctx, "home", c); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+	var saved []genai.FlowResult
+	if err := s.LoadState(ctx, "home", anomaly.FlowStateKey, &saved); err != nil || len(saved) != 3 {
+		t.Fatalf("saved checks = %v, %v; want 3", saved, err)
+	}
+	leak, err := d.Check(ctx, "home", time.Date(2025, 11, 7, 6, 0, 0, 0, kst))
+	if err != nil || leak == nil {
+		t.Fatalf("Check = %v, %v", leak, err)
+	}
+	if last := leak.Nights[2]; len(last.FlowChecks) != 2 || len(leak.Nights[1].FlowChecks) != 0 {
+		t.Fatalf("flow checks = %+v", leak.Nights)
+	}
+	if got := leak.String(); !strings.HasSuffix(got, "; flow indicator moving in 1 of 2 checks") {
+		t.Fatalf("String() = %q", got)
+	}
+}
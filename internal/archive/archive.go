@@ -0,0 +1,243 @@
+// Package archive keeps meter images for later reprocessing: in a local
+// directory, or in S3-compatible (MinIO included) or Google Cloud Storage
+// buckets. Images are stored under keys laid out by meter and time, so that
+// a range of them can be listed and fetched again.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Backends of [Config].
+const (
+	BackendDir = "dir"
+	BackendS3  = "s3"
+	BackendGCS = "gcs"
+)
+
+// Defaults of [Config].
+const (
+	DefaultPrefix      = "{meter}/{yyyy}/{mm}/"
+	DefaultContentType = "image/jpeg"
+)
+
+// keyTime is the layout of the time in the name of an image, in UTC.
+const keyTime = "20060102T150405.000Z"
+
+// ErrNotFound is returned for an image that is not archived.
+var ErrNotFound = errors.New("image not found")
+
+// Config selects and configures the archive backend.
+type Config struct {
+	Backend string `yaml:"backend"`
+	// Dir is the directory of the dir backend.
+	Dir string `yaml:"dir"`
+	// Bucket is the bucket of the s3 and gcs backends.
+	Bucket string `yaml:"bucket"`
+	// Prefix is prepended to the image names, with {meter}, {yyyy}, {mm}
+	// and {dd} replaced by the meter ID ("default" without one) and the UTC
+	// date of the image (default DefaultPrefix).
+	Prefix      string `yaml:"prefix"`
+	ContentType string `yaml:"content_type"` // default DefaultContentType
+	// Endpoint, Region and PathStyle address an S3-compatible service; the
+	// default is AWS in Region, which defaults to the SDK's ($AWS_REGION or
+	// the profile's) and then us-east-1. MinIO needs an endpoint such as
+	// http://minio:9000 with path_style. For gcs, Endpoint
+	// replaces https://storage.googleapis.com, e.g. for an emulator.
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	PathStyle bool   `yaml:"path_style"`
+	// Retries is how often a failed upload is retried (default 3), waiting
+	// RetryDelay (default 2s) and twice as long after each failure.
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper `yaml:"-"`
+}
+
+// Validate checks the settings.
+func (c Config) Validate() error {
+	switch c.Backend {
+	case BackendDir:
+		if c.Dir == "" {
+			return errors.New("dir backend needs dir")
+		}
+	case BackendS3, BackendGCS:
+		if c.Bucket == "" {
+			return fmt.Errorf("%s backend needs bucket", c.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown backend %q, want %s, %s or %s", c.Backend, BackendDir, BackendS3, BackendGCS)
+	}
+	if c.Retries < 0 || c.RetryDelay < 0 {
+		return errors.New("negative retries or retry_delay")
+	}
+	return nil
+}
+
+// Image is an archived image.
+type Image struct {
+	Key     string
+	MeterID string
+	At      time.Time
+}
+
+// Archiver stores meter images.
+type Archiver interface {
+	// Archive stores img under key.
+	Archive(ctx context.Context, key string, img []byte) error
+	// Key returns the key an image of meterID captured at at is stored
+	// under.
+	Key(meterID string, at time.Time) string
+}
+
+// Store is an Archiver that can also list and fetch its images.
+type Store interface {
+	Archiver
+	// List returns the images of meterID captured in [from, to), oldest
+	// first.
+	List(ctx context.Context, meterID string, from, to time.Time) ([]Image, error)
+	// Fetch returns the image stored under key, or ErrNotFound.
+	Fetch(ctx context.Context, key string) ([]byte, error)
+}
+
+// bucket is the storage of a backend, addressed by keys.
+type bucket interface {
+	put(ctx context.Context, key string, body []byte, contentType string) error
+	// get returns ErrNotFound for a missing key.
+	get(ctx context.Context, key string) ([]byte, error)
+	// list returns the keys starting with prefix.
+	list(ctx context.Context, prefix string) ([]string, error)
+}
+
+// New returns the Store of cfg; credentials of the s3 and gcs backends come
+// from the default chains of the cloud SDKs.
+func New(ctx context.Context, cfg Config) (Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var b bucket
+	var err error
+	switch cfg.Backend {
+	case BackendDir:
+		b = dirBucket(cfg.Dir)
+	case BackendS3:
+		b, err = newS3(ctx, cfg)
+	case BackendGCS:
+		b, err = newGCS(ctx, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s archive: %w", cfg.Backend, err)
+	}
+	return newStore(b, cfg), nil
+}
+
+func newStore(b bucket, cfg Config) *store {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = DefaultContentType
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = 3
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = 2 * time.Second
+	}
+	return &store{b: b, cfg: cfg}
+}
+
+// store lays out and retries the images of a bucket.
+type store struct {
+	b   bucket
+	cfg Config
+}
+
+// Key implements [Archiver].
+func (s *store) Key(meterID string, at time.Time) string {
+	return s.prefix(meterID, at) + at.UTC().Format(keyTime) + ".jpg"
+}
+
+func (s *store) prefix(meterID string, at time.Time) string {
+	if meterID == "" {
+		meterID = "default"
+	}
+	at = at.UTC()
+	return strings.NewReplacer(
+		"{meter}", meterID,
+		"{yyyy}", at.Format("2006"),
+		"{mm}", at.Format("01"),
+		"{dd}", at.Format("02"),
+	).Replace(s.cfg.Prefix)
+}
+
+// Archive implements [Archiver]. A failed upload is retried and then
+// returned, for the caller to log.
+func (s *store) Archive(ctx context.Context, key string, img []byte) error {
+	delay := s.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := s.b.put(ctx, key, img, s.cfg.ContentType)
+		if err == nil {
+			return nil
+		}
+		if attempt == s.cfg.Retries {
+			return fmt.Errorf("archive %s: %w", key, err)
+		}
+		log.Printf("Retrying archiving of %s in %s: %v", key, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("archive %s: %w", key, ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// List implements [Store]. It lists the prefix of every day in the range
+// once, so that a prefix by month is listed once a month.
+func (s *store) List(ctx context.Context, meterID string, from, to time.Time) ([]Image, error) {
+	var prefixes []string
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if p := s.prefix(meterID, day); !slices.Contains(prefixes, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	var images []Image
+	for _, p := range prefixes {
+		keys, err := s.b.list(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", p, err)
+		}
+		for _, key := range keys {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(key, p), ".jpg")
+			if !ok || strings.Contains(name, "/") {
+				continue // not an image of this layout
+			}
+			at, err := time.Parse(keyTime, name)
+			if err != nil || at.Before(from) || !at.Before(to) {
+				continue
+			}
+			images = append(images, Image{Key: key, MeterID: meterID, At: at})
+		}
+	}
+	slices.SortFunc(images, func(a, b Image) int { return a.At.Compare(b.At) })
+	return images, nil
+}
+
+// Fetch implements [Store].
+func (s *store) Fetch(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.b.get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", key, err)
+	}
+	return b, nil
+}
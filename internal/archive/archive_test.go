@@ -0,0 +1,236 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/auth"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newStore(dirBucket(t.TempDir()), Config{})
+	at := time.Date(2025, 11, 30, 23, 30, 0, 0, time.UTC)
+	if got, want := s.Key("home", at), "home/2025/11/20251130T233000.000Z.jpg"; got != want {
+		t.Fatalf("Key = %q, want %q", got, want)
+	}
+	var keys []string
+	for i := range 4 {
+		k := s.Key("home", at.Add(time.Duration(i)*time.Hour))
+		if err := s.Archive(ctx, k, []byte{byte(i)}); err != nil {
+			t.Fatalf("Archive: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := s.Archive(ctx, s.Key("cabin", at), []byte{9}); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	// The range spans two months, and so two prefixes.
+	images, err := s.List(ctx, "home", at.Add(time.Hour), at.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var got []string
+	for _, img := range images {
+		got = append(got, img.Key)
+	}
+	if !slices.Equal(got, keys[1:3]) || !images[0].At.Equal(at.Add(time.Hour)) {
+		t.Fatalf("List = %+v, want %q", images, keys[1:3])
+	}
+	if b, err := s.Fetch(ctx, keys[2]); err != nil || len(b) != 1 || b[0] != 2 {
+		t.Fatalf("Fetch = %v, %v", b, err)
+	}
+	if _, err := s.Fetch(ctx, "home/2020/01/20200101T000000.000Z.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch of a missing image = %v, want ErrNotFound", err)
+	}
+	if images, err := s.List(ctx, "home", at.AddDate(1, 0, 0), at.AddDate(1, 0, 2)); err != nil || len(images) != 0 {
+		t.Fatalf("List of an empty range = %v, %v", images, err)
+	}
+}
+
+// flakyBucket fails the first fails puts.
+type flakyBucket struct {
+	dirBucket
+	mu    sync.Mutex
+	fails int
+	puts  int
+}
+
+func (b *flakyBucket) put(ctx context.Context, key string, body []byte, contentType string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.puts++; b.puts <= b.fails {
+		return errors.New("503 Slow Down")
+	}
+	return b.dirBucket.put(ctx, key, body, contentType)
+}
+
+func TestArchiveRetries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	b := &flakyBucket{dirBucket: dirBucket(t.TempDir()), fails: 2}
+	s := newStore(b, Config{Retries: 2, RetryDelay: time.Millisecond})
+	if err := s.Archive(ctx, "a.jpg", []byte{1}); err != nil || b.puts != 3 {
+		t.Fatalf("Archive = %v after %d puts, want success after 3", err, b.puts)
+	}
+	b.puts, b.fails = 0, 5
+	if err := s.Archive(ctx, "b.jpg", []byte{1}); err == nil || b.puts != 3 {
+		t.Fatalf("Archive = %v after %d puts, want failure after 3", err, b.puts)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{Backend: BackendDir, Dir: "/var/lib/mqvision/images"}, true},
+		{Config{Backend: BackendS3, Bucket: "meter"}, true},
+		{Config{Backend: BackendDir}, false},
+		{Config{Backend: BackendGCS}, false},
+		{Config{Backend: "ftp", Bucket: "meter"}, false},
+		{Config{Backend: BackendS3, Bucket: "meter", Retries: -1}, false},
+	} {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Fatalf("Validate(%+v) = %v", c.cfg, err)
+		}
+	}
+}
+
+// objectServer is a fake of both S3 (path style) and the GCS JSON API. It
+// refuses S3 requests not signed by the access key ID s3Key.
+func objectServer(t *testing.T, s3Key string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authz := r.Header.Get("Authorization")
+		if authz == "" {
+			http.Error(w, "anonymous", http.StatusForbidden)
+			return
+		}
+		if strings.HasPrefix(authz, "AWS4-HMAC-SHA256 ") && !strings.Contains(authz, "Credential="+s3Key+"/") {
+			http.Error(w, "InvalidAccessKeyId", http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/meter/o"):
+			objects[q.Get("name")], _ = io.ReadAll(r.Body)
+		case strings.HasPrefix(r.URL.Path, "/storage/v1/b/meter/o/"):
+			b, ok := objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/meter/o/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+		case r.URL.Path == "/storage/v1/b/meter/o":
+			var page struct {
+				Items []map[string]string `json:"items"`
+			}
+			for k := range objects {
+				if strings.HasPrefix(k, q.Get("prefix")) {
+					page.Items = append(page.Items, map[string]string{"name": k})
+				}
+			}
+			json.NewEncoder(w).Encode(page)
+		case (r.URL.Path == "/meter" || r.URL.Path == "/meter/") && q.Get("list-type") == "2":
+			type content struct{ Key string }
+			var page struct {
+				XMLName  xml.Name `xml:"ListBucketResult"`
+				Contents []content
+			}
+			for k := range objects {
+				if strings.HasPrefix(k, q.Get("prefix")) {
+					page.Contents = append(page.Contents, content{k})
+				}
+			}
+			xml.NewEncoder(w).Encode(page)
+		case strings.HasPrefix(r.URL.Path, "/meter/") && r.Method == http.MethodPut:
+			objects[strings.TrimPrefix(r.URL.Path, "/meter/")], _ = io.ReadAll(r.Body)
+		case strings.HasPrefix(r.URL.Path, "/meter/"):
+			b, ok := objects[strings.TrimPrefix(r.URL.Path, "/meter/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (*auth.Token, error) {
+	return &auth.Token{Value: string(s)}, nil
+}
+
+func TestBuckets(t *testing.T) {
+	// The s3 backend takes the profile of the shared credentials file, as
+	// the AWS SDK does.
+	dir := t.TempDir()
+	creds := filepath.Join(dir, "credentials")
+	err := os.WriteFile(creds, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = s3cret\n\n[minio]\naws_access_key_id=minioadmin\naws_secret_access_key=minioadmin\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID": "", "AWS_SECRET_ACCESS_KEY": "", "AWS_SESSION_TOKEN": "",
+		"AWS_SHARED_CREDENTIALS_FILE": creds, "AWS_CONFIG_FILE": filepath.Join(dir, "config"),
+		"AWS_PROFILE": "minio", "AWS_EC2_METADATA_DISABLED": "true",
+	} {
+		t.Setenv(k, v)
+	}
+
+	srv := objectServer(t, "minioadmin")
+	s3, err := newS3(context.Background(), Config{Bucket: "meter", Endpoint: srv.URL, PathStyle: true})
+	if err != nil {
+		t.Fatalf("newS3: %v", err)
+	}
+	gcs := &gcsBucket{endpoint: srv.URL, bucket: "meter", tokens: staticToken("ya29.test"), client: srv.Client()}
+	for name, b := range map[string]bucket{"s3": s3, "gcs": gcs} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStore(b, Config{Prefix: name + "/{meter}/{yyyy}/{mm}/"})
+			at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+			key := s.Key("home", at)
+			if err := s.Archive(ctx, key, []byte("jpeg")); err != nil {
+				t.Fatalf("Archive: %v", err)
+			}
+			images, err := s.List(ctx, "home", at.Add(-time.Hour), at.Add(time.Hour))
+			if err != nil || len(images) != 1 || images[0].Key != key {
+				t.Fatalf("List = %+v, %v", images, err)
+			}
+			if img, err := s.Fetch(ctx, key); err != nil || string(img) != "jpeg" {
+				t.Fatalf("Fetch = %q, %v", img, err)
+			}
+			if _, err := s.Fetch(ctx, name+"/missing.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Fetch of a missing image = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+)
+
+// gcsScope is the OAuth scope of the gcs backend.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsBucket is a Google Cloud Storage bucket, requested through the JSON API.
+type gcsBucket struct {
+	endpoint string
+	bucket   string
+	tokens   auth.TokenProvider
+	client   *http.Client
+}
+
+// newGCS authenticates with Application Default Credentials:
+// $GOOGLE_APPLICATION_CREDENTIALS, the gcloud credentials or the metadata
+// server.
+func newGCS(_ context.Context, cfg Config) (*gcsBucket, error) {
+	client := &http.Client{Timeout: time.Minute, Transport: cfg.Transport}
+	detect := &credentials.DetectOptions{Scopes: []string{gcsScope}}
+	if cfg.Transport != nil {
+		detect.Client = client // the tokens come through the proxy too
+	}
+	creds, err := credentials.DetectDefault(detect)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	return &gcsBucket{endpoint: endpoint, bucket: cfg.Bucket, tokens: creds, client: client}, nil
+}
+
+func (b *gcsBucket) put(ctx context.Context, key string, body []byte, contentType string) error {
+	u := b.endpoint + "/upload/storage/v1/b/" + url.PathEscape(b.bucket) + "/o?" +
+		url.Values{"uploadType": {"media"}, "name": {key}}.Encode()
+	resp, err := b.do(ctx, http.MethodPost, u, body, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (b *gcsBucket) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.objects()+"/"+url.PathEscape(key)+"?alt=media", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *gcsBucket) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	q := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+	for {
+		resp, err := b.do(ctx, http.MethodGet, b.objects()+"?"+q.Encode(), nil, "")
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("no bucket %s", b.bucket)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode listing: %w", err)
+		}
+		for _, it := range page.Items {
+			keys = append(keys, it.Name)
+		}
+		if page.NextPageToken == "" {
+			return keys, nil
+		}
+		q.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (b *gcsBucket) objects() string {
+	return b.endpoint + "/storage/v1/b/" + url.PathEscape(b.bucket) + "/o"
+}
+
+// do sends an authorized request. Error statuses are returned as errors, 404
+// as ErrNotFound.
+func (b *gcsBucket) do(ctx context.Context, method, u string, body []byte, contentType string) (*http.Response, error) {
+	tok, err := b.tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.Value)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet && body == nil {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
@@ -0,0 +1,114 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Bucket is a bucket of an S3-compatible service.
+type s3Bucket struct {
+	client *s3.Client
+	bucket string
+}
+
+// newS3 finds the region and credentials with the default chain of the AWS
+// SDK: the environment, the shared config and credentials files with their
+// profiles and SSO, and the container or instance role. Region overrides
+// the chain's and defaults to us-east-1.
+func newS3(ctx context.Context, cfg Config) (*s3Bucket, error) {
+	// The credential providers, e.g. STS or SSO, send through the transport
+	// too.
+	opts := []func(*config.LoadOptions) error{config.WithHTTPClient(s3HTTPClient(cfg.Transport))}
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = "us-east-1"
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.UsePathStyle = cfg.PathStyle
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			// Not every S3-compatible service takes the checksums the SDK
+			// adds to uploads by default.
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	})
+	return &s3Bucket{client: client, bucket: cfg.Bucket}, nil
+}
+
+// s3HTTPClient returns the client of the SDK sending through t. The proxy,
+// dialer and TLS settings of an [http.Transport] are taken over by the SDK's
+// own client, which can still add $AWS_CA_BUNDLE; another t is used as is.
+func s3HTTPClient(t http.RoundTripper) config.HTTPClient {
+	client := awshttp.NewBuildableClient().WithTimeout(time.Minute)
+	switch t := t.(type) {
+	case nil:
+		return client
+	case *http.Transport:
+		return client.WithTransportOptions(func(bt *http.Transport) {
+			bt.Proxy = t.Proxy
+			if t.DialContext != nil {
+				bt.DialContext = t.DialContext
+			}
+			if t.TLSClientConfig != nil {
+				bt.TLSClientConfig = t.TLSClientConfig.Clone()
+			}
+		})
+	default:
+		return &http.Client{Timeout: time.Minute, Transport: t}
+	}
+}
+
+func (b *s3Bucket) put(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (b *s3Bucket) get(ctx context.Context, key string) ([]byte, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)})
+	// A missing key is NoSuchKey on AWS, a bare 404 on some compatibles.
+	var resp *awshttp.ResponseError
+	if errors.As(err, &resp) && resp.HTTPStatusCode() == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (b *s3Bucket) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{Bucket: aws.String(b.bucket), Prefix: aws.String(prefix)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,220 @@
+// Package audit writes [genai.AuditEntry] records as JSONL to a size-rotated file.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+const (
+	defaultMaxSize    = 10 << 20 // 10 MiB
+	defaultMaxBackups = 3
+	queueSize         = 256
+)
+
+// Logger is a best-effort [genai.Auditor]. Entries are queued and written by a
+// background goroutine; when the queue is full they are dropped rather than
+// blocking the reading.
+type Logger struct {
+	path        string
+	maxSize     int64
+	maxBackups  int
+	omitPrompts bool
+
+	f    *os.File
+	size int64
+
+	ch      chan genai.AuditEntry
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// Option configures a [Logger].
+type Option func(*Logger)
+
+// WithMaxSize rotates the file once it exceeds n bytes.
+func WithMaxSize(n int64) Option {
+	return func(l *Logger) {
+		if n > 0 {
+			l.maxSize = n
+		}
+	}
+}
+
+// WithMaxBackups keeps n rotated files (path.1 … path.n).
+func WithMaxBackups(n int) Option {
+	return func(l *Logger) {
+		if n >= 0 {
+			l.maxBackups = n
+		}
+	}
+}
+
+// WithoutPrompts omits rendered prompts from entries for privacy.
+func WithoutPrompts() Option {
+	return func(l *Logger) {
+		l.omitPrompts = true
+	}
+}
+
+// NewLogger opens (appending to) path and starts the writer goroutine.
+func NewLogger(path string, opts ...Option) (*Logger, error) {
+	l := &Logger{
+		path:       path,
+		maxSize:    defaultMaxSize,
+		maxBackups: defaultMaxBackups,
+		ch:         make(chan genai.AuditEntry, queueSize),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	go l.run()
+	return l, nil
+}
+
+// Audit implements [genai.Auditor]. It never blocks.
+func (l *Logger) Audit(e genai.AuditEntry) {
+	if l.omitPrompts {
+		e.SystemPrompt, e.Prompt = "", ""
+	}
+	select {
+	case l.ch <- e:
+	default:
+		l.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (l *Logger) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Close flushes queued entries and closes the file. Audit must not be called after Close.
+func (l *Logger) Close() error {
+	close(l.ch)
+	<-l.done
+	return l.f.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	for e := range l.ch {
+		if err := l.write(e); err != nil {
+			log.Printf("Error writing audit log: %v", err)
+		}
+	}
+}
+
+func (l *Logger) write(e genai.AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+// rotate shifts path.(n-1) → path.n, …, path → path.1 and reopens path.
+func (l *Logger) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	if l.maxBackups == 0 {
+		os.Remove(l.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups))
+		for i := l.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		os.Rename(l.path, l.path+".1")
+	}
+	return l.open()
+}
+
+// Line is an entry read back from the log, with the line as written.
+type Line struct {
+	genai.AuditEntry
+	Raw []byte
+	// File is the file of the log it was read from.
+	File string
+}
+
+// Read returns the entries of the log at path and its rotated backups that
+// keep matches, oldest first. A missing log has no entries; lines that are
+// no entry are skipped.
+func Read(path string, keep func(e genai.AuditEntry) bool) ([]Line, error) {
+	files := []string{path}
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		files = append(files, name)
+	}
+	var lines []Line
+	for i := len(files) - 1; i >= 0; i-- {
+		f, err := os.Open(files[i])
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open audit log: %w", err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 16<<20) // prompts and responses make long lines
+		for sc.Scan() {
+			var e genai.AuditEntry
+			if json.Unmarshal(sc.Bytes(), &e) != nil || !keep(e) {
+				continue
+			}
+			lines = append(lines, Line{AuditEntry: e, Raw: append([]byte(nil), sc.Bytes()...), File: files[i]})
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", files[i], err)
+		}
+	}
+	return lines, nil
+}
+
+// Between returns a keep of [Read] for the entries with from <= Time < to.
+func Between(from, to time.Time) func(e genai.AuditEntry) bool {
+	return func(e genai.AuditEntry) bool {
+		return !e.Time.Before(from) && e.Time.Before(to)
+	}
+}
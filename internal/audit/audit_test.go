@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+func readEntries(t *testing.T, path string) []genai.AuditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []genai.AuditEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e genai.AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestLoggerWritesAndOmitsPrompts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := NewLogger(path, WithoutPrompts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Audit(genai.AuditEntry{Call: genai.CallRead, Model: "m", SystemPrompt: "secret prompt", Prompt: "p", Response: "{}"})
+	l.Audit(genai.AuditEntry{Call: genai.CallGuess, Model: "m", Response: "01234.567"})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].SystemPrompt != "" || entries[0].Prompt != "" {
+		t.Fatalf("prompts were not omitted: %+v", entries[0])
+	}
+	if entries[1].Call != genai.CallGuess || entries[1].Response != "01234.567" {
+		t.Fatalf("entry = %+v", entries[1])
+	}
+}
+
+func TestLoggerRotates(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := NewLogger(path, WithMaxSize(200), WithMaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		l.Audit(genai.AuditEntry{Call: genai.CallRead, Response: strings.Repeat("x", 50)})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("stat %s: %v", p, err)
+		}
+		if fi.Size() > 200 {
+			t.Fatalf("%s is %d bytes, want <= 200", p, fi.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("%s.3 should not exist: %v", path, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := NewLogger(path, WithMaxSize(500), WithMaxBackups(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		l.Audit(genai.AuditEntry{Time: at.Add(time.Duration(i) * time.Minute), Call: genai.CallRead, Response: strings.Repeat("x", 100)})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("want the log rotated: %v", err)
+	}
+
+	lines, err := Read(path, Between(at.Add(2*time.Minute), at.Add(8*time.Minute)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 6", len(lines))
+	}
+	for i, line := range lines {
+		if want := at.Add(time.Duration(i+2) * time.Minute); !line.Time.Equal(want) {
+			t.Fatalf("line %d at %s, want %s, oldest first", i, line.Time, want)
+		}
+		var e genai.AuditEntry
+		if err := json.Unmarshal(line.Raw, &e); err != nil || !e.Time.Equal(line.Time) {
+			t.Fatalf("line %d: raw %q does not hold the entry: %v", i, line.Raw, err)
+		}
+	}
+	if lines, err := Read(filepath.Join(t.TempDir(), "none.jsonl"), Between(at, at.Add(time.Hour))); err != nil || len(lines) != 0 {
+		t.Fatalf("Read of a missing log = %v, %v", lines, err)
+	}
+}
@@ -0,0 +1,299 @@
+// Package bench evaluates vision models against a labeled set of meter
+// images, to re-validate the accuracy of a replacement before switching to
+// it. The answers of each model are cached per image, so an interrupted run
+// resumes where it stopped and adding a model to a run only evaluates that
+// model.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Case is one labeled image of a [Manifest].
+type Case struct {
+	// Image is the path of the JPEG, relative to the manifest.
+	Image string `yaml:"image" json:"image"`
+	Read  string `yaml:"read" json:"read,omitempty"`
+	// Tolerance accepts readings within ±Tolerance of Read, for images whose
+	// last digit is legitimately uncertain.
+	Tolerance float64 `yaml:"tolerance" json:"tolerance,omitempty"`
+	// Unreadable marks images with no visible counter; a model should fail
+	// or return an empty reading rather than invent one.
+	Unreadable bool   `yaml:"unreadable" json:"unreadable,omitempty"`
+	Note       string `yaml:"note" json:"note,omitempty"`
+}
+
+// Match reports whether a reading of c, or the error of the model reading
+// it, is right.
+func (c Case) Match(read string, err error) bool {
+	if c.Unreadable {
+		return err != nil || read == ""
+	}
+	if err != nil {
+		return false
+	}
+	if read == c.Read {
+		return true
+	}
+	want, werr := strconv.ParseFloat(c.Read, 64)
+	got, gerr := strconv.ParseFloat(read, 64)
+	if werr != nil || gerr != nil || len(read) != len(c.Read) {
+		return false
+	}
+	// Allow for float rounding at the tolerance boundary.
+	return math.Abs(got-want) <= c.Tolerance+1e-9
+}
+
+// Price is what a model costs, in USD per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+	// Cached is the price of cached input tokens (default: Input).
+	Cached float64 `yaml:"cached" json:"cached,omitempty"`
+}
+
+// Cost returns the cost of u in USD.
+func (p Price) Cost(u genai.Usage) float64 {
+	cached := p.Cached
+	if cached == 0 {
+		cached = p.Input
+	}
+	return (float64(u.InputTokens-u.CachedTokens)*p.Input + float64(u.CachedTokens)*cached +
+		float64(u.OutputTokens)*p.Output) / 1e6
+}
+
+// Manifest is an evaluation set, as a YAML file:
+//
+//	cases:
+//	  - image: ok.jpg
+//	    read: "02924.457"
+//	  - image: night.jpg
+//	    unreadable: true
+//	prices:
+//	  gemini-2.5-flash: {input: 0.30, output: 2.50, cached: 0.075}
+type Manifest struct {
+	Cases []Case `yaml:"cases"`
+	// Prices are the prices of the models, by name; the cost of a model
+	// left out is not reported.
+	Prices map[string]Price `yaml:"prices"`
+	// Dir is the directory the images are relative to.
+	Dir string `yaml:"-"`
+}
+
+// LoadManifest reads the manifest at path.
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	m.Dir = filepath.Dir(path)
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Validate checks that every case is labeled, once.
+func (m *Manifest) Validate() error {
+	if len(m.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	seen := make(map[string]bool)
+	for i, c := range m.Cases {
+		switch {
+		case c.Image == "":
+			return fmt.Errorf("case %d: image is empty", i+1)
+		case seen[c.Image]:
+			return fmt.Errorf("case %s: listed twice", c.Image)
+		case c.Read == "" && !c.Unreadable:
+			return fmt.Errorf("case %s: read is empty", c.Image)
+		case c.Read != "" && c.Unreadable:
+			return fmt.Errorf("case %s: both read and unreadable", c.Image)
+		case c.Tolerance < 0:
+			return fmt.Errorf("case %s: tolerance must not be negative", c.Image)
+		}
+		seen[c.Image] = true
+	}
+	return nil
+}
+
+// Path returns the path of the image of c.
+func (m *Manifest) Path(c Case) string {
+	if filepath.IsAbs(c.Image) {
+		return c.Image
+	}
+	return filepath.Join(m.Dir, c.Image)
+}
+
+// Outcome is the answer of one model for one image.
+type Outcome struct {
+	Image       string `json:"image"`
+	ImageSHA256 string `json:"image_sha256"`
+	Model       string `json:"model"`
+	PromptHash  string `json:"prompt_hash,omitempty"`
+
+	Read      string `json:"read,omitempty"`
+	Ambiguous bool   `json:"ambiguous,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Failed marks a call that failed before the model answered, such as
+	// with a network error or a quota exceeded. Failed outcomes are not
+	// cached: the next run tries again.
+	Failed bool `json:"failed,omitempty"`
+
+	// Latency is the time the model calls took, without rate limiting.
+	Latency time.Duration `json:"latency"`
+	Usage   genai.Usage   `json:"usage"`
+	At      time.Time     `json:"at"`
+}
+
+// err returns the error of the model reading o, nil if it read the image.
+func (o Outcome) err() error {
+	if o.Error == "" {
+		return nil
+	}
+	return errors.New(o.Error)
+}
+
+// Answered reports whether err, of a reading, is an answer of the model
+// rather than a failure to get one: a rejected output is the model's answer
+// as much as a reading is, and asking again gives the same.
+func Answered(err error) bool {
+	return err == nil ||
+		errors.Is(err, genai.ErrInvalidModelOutput) ||
+		errors.Is(err, genai.ErrEmptyReading) ||
+		errors.Is(err, genai.ErrTruncatedOutput) ||
+		errors.Is(err, genai.ErrWrongMeter) ||
+		errors.Is(err, genai.ErrImageTooLarge)
+}
+
+// Recorder is a [genai.Auditor] adding up the token usage and the latency
+// of the calls of a model.
+type Recorder struct {
+	mu      sync.Mutex
+	usage   genai.Usage
+	latency time.Duration
+	calls   int
+}
+
+func (r *Recorder) Audit(e genai.AuditEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage.InputTokens += e.Usage.InputTokens
+	r.usage.OutputTokens += e.Usage.OutputTokens
+	r.usage.CachedTokens += e.Usage.CachedTokens
+	if d, err := time.ParseDuration(e.Latency); err == nil {
+		r.latency += d
+	}
+	r.calls++
+}
+
+// take returns what was recorded since the last take.
+func (r *Recorder) take() (u genai.Usage, latency time.Duration, calls int) {
+	if r == nil {
+		return genai.Usage{}, 0, 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, latency, calls = r.usage, r.latency, r.calls
+	r.usage, r.latency, r.calls = genai.Usage{}, 0, 0
+	return u, latency, calls
+}
+
+// Model is a model under evaluation.
+type Model struct {
+	Name string
+	// PromptHash is the [genai.Prompts] hash of the prompts Client reads
+	// with; an answer is cached for the model and the prompts.
+	PromptHash string
+	// Client reads the images; its rate limiter paces the run.
+	Client genai.VisionClient
+	// Recorder, if set, audits the calls of Client, for the token usage and
+	// the latency of each reading. Without it the latency is the wall time
+	// of a reading.
+	Recorder *Recorder
+}
+
+// Run reads every image of m with every model, one model after the other,
+// taking the answers in cache (which may be nil) rather than asking again
+// and caching the new ones. progress, if set, is called with each outcome.
+// When ctx is canceled Run returns the outcomes so far with ctx.Err(); a
+// run with the same cache picks up from there.
+func Run(ctx context.Context, m *Manifest, models []Model, cache *Cache, progress func(o Outcome, cached bool)) ([]Outcome, error) {
+	images := make([][]byte, len(m.Cases))
+	shas := make([]string, len(m.Cases))
+	for i, c := range m.Cases {
+		b, err := os.ReadFile(m.Path(c))
+		if err != nil {
+			return nil, fmt.Errorf("read image: %w", err)
+		}
+		sum := sha256.Sum256(b)
+		images[i], shas[i] = b, hex.EncodeToString(sum[:])
+	}
+
+	var outs []Outcome
+	for _, model := range models {
+		for i, c := range m.Cases {
+			if err := ctx.Err(); err != nil {
+				return outs, err
+			}
+			o, cached := cache.Get(shas[i], model.Name, model.PromptHash)
+			if cached {
+				o.Image = c.Image
+			} else {
+				o = read(ctx, model, images[i])
+				o.Image, o.ImageSHA256 = c.Image, shas[i]
+				if o.Failed && ctx.Err() != nil {
+					// Interrupted rather than failed.
+					return outs, ctx.Err()
+				}
+				if err := cache.Put(o); err != nil {
+					return outs, err
+				}
+			}
+			outs = append(outs, o)
+			if progress != nil {
+				progress(o, cached)
+			}
+		}
+	}
+	return outs, nil
+}
+
+// read reads jpg with model.
+func read(ctx context.Context, model Model, jpg []byte) Outcome {
+	model.Recorder.take()
+	start := time.Now()
+	res, err := model.Client.ReadGasGaugePic(ctx, bytes.NewReader(jpg))
+	took := time.Since(start)
+	usage, latency, calls := model.Recorder.take()
+	if calls == 0 {
+		latency = took
+	}
+
+	o := Outcome{Model: model.Name, PromptHash: model.PromptHash, Latency: latency, Usage: usage, At: start}
+	if err != nil {
+		o.Error, o.Failed = err.Error(), !Answered(err)
+		return o
+	}
+	o.Read, o.Ambiguous = res.Read, res.Ambiguous
+	return o
+}
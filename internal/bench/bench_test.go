@@ -0,0 +1,207 @@
+package bench_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/bench"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+// writeManifest writes a manifest of images a.jpg, b.jpg and dark.jpg, the
+// last unreadable, to a new directory.
+func writeManifest(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "dark.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("jpeg of "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := `cases:
+  - image: a.jpg
+    read: "02924.457"
+  - image: b.jpg
+    read: "02931.002"
+    tolerance: 0.001
+  - image: dark.jpg
+    unreadable: true
+prices:
+  good: {input: 0.30, output: 2.50}
+`
+	path := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func reading(read string) *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{Read: read}
+}
+
+func TestLoadManifest(t *testing.T) {
+	t.Parallel()
+
+	path := writeManifest(t)
+	m, err := bench.LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if len(m.Cases) != 3 || m.Path(m.Cases[0]) != filepath.Join(filepath.Dir(path), "a.jpg") || m.Prices["good"].Output != 2.50 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	for name, cases := range map[string][]bench.Case{
+		"none":       nil,
+		"unlabeled":  {{Image: "a.jpg"}},
+		"twice":      {{Image: "a.jpg", Read: "1"}, {Image: "a.jpg", Read: "1"}},
+		"contradict": {{Image: "a.jpg", Read: "1", Unreadable: true}},
+	} {
+		if err := (&bench.Manifest{Cases: cases}).Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, cases)
+		}
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m, err := bench.LoadManifest(writeManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.jsonl")
+	cache, err := bench.OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+
+	good := genaitest.NewFakeReader(reading("02924.457"))
+	good.PushError(fmt.Errorf("429 Too Many Requests"))
+	good.PushError(fmt.Errorf("missing read: %w", genai.ErrEmptyReading))
+	outs, err := bench.Run(ctx, m, []bench.Model{{Name: "good", PromptHash: "p1", Client: good}}, cache, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(outs) != 3 || !outs[1].Failed || outs[2].Failed || cache.Len() != 2 {
+		t.Fatalf("outcomes %+v, %d cached", outs, cache.Len())
+	}
+	cache.Close()
+
+	// A run killed while writing leaves a partial line.
+	f, _ := os.OpenFile(cachePath, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"image":"b.jpg","model":"go`)
+	f.Close()
+
+	cache, err = bench.OpenCache(cachePath)
+	if err != nil {
+		t.Fatalf("OpenCache after a partial line: %v", err)
+	}
+	defer cache.Close()
+	// Only the failed image is read again with the first model, and the new
+	// one reads them all.
+	good.Push(reading("02931.003"))
+	other := genaitest.NewFakeReader(reading("02924.451"), reading("02931.002"), reading("?????.???"))
+	var cached int
+	outs, err = bench.Run(ctx, m, []bench.Model{
+		{Name: "good", PromptHash: "p1", Client: good},
+		{Name: "other", PromptHash: "p1", Client: other},
+	}, cache, func(o bench.Outcome, c bool) {
+		if c {
+			cached++
+		}
+	})
+	if err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	if len(good.Calls()) != 4 || len(other.Calls()) != 3 || cached != 2 || len(outs) != 6 || cache.Len() != 6 {
+		t.Fatalf("%d and %d calls, %d of %d outcomes cached, %d in the cache", len(good.Calls()), len(other.Calls()), cached, len(outs), cache.Len())
+	}
+
+	// Changing the prompts asks again.
+	again := genaitest.NewFakeReader()
+	if _, err := bench.Run(ctx, m, []bench.Model{{Name: "good", PromptHash: "p2", Client: again}}, cache, nil); err != nil || len(again.Calls()) != 3 {
+		t.Fatalf("Run with new prompts made %d calls: %v", len(again.Calls()), err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	t.Parallel()
+
+	m, err := bench.LoadManifest(writeManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fake := &genaitest.FakeReader{Generate: func(n int) (*genai.GasMeterReadResult, error) {
+		if n == 1 {
+			cancel()
+			return nil, context.Canceled
+		}
+		return reading("02924.457"), nil
+	}}
+	cache, _ := bench.OpenCache(filepath.Join(t.TempDir(), "cache.jsonl"))
+	defer cache.Close()
+	outs, err := bench.Run(ctx, m, []bench.Model{{Name: "m", Client: fake}}, cache, nil)
+	if !errors.Is(err, context.Canceled) || len(outs) != 1 || cache.Len() != 1 {
+		t.Fatalf("canceled Run = %d outcomes, %d cached, %v", len(outs), cache.Len(), err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	m, err := bench.LoadManifest(writeManifest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := genai.Usage{InputTokens: 1000, OutputTokens: 100}
+	outs := []bench.Outcome{
+		{Image: "a.jpg", Model: "good", Read: "02924.457", Latency: time.Second, Usage: usage},
+		{Image: "b.jpg", Model: "good", Read: "02931.003", Latency: 2 * time.Second, Usage: usage}, // within tolerance
+		{Image: "dark.jpg", Model: "good", Error: "empty reading", Latency: 3 * time.Second, Usage: usage},
+		{Image: "a.jpg", Model: "other", Read: "02924.4?7", Ambiguous: true},
+		{Image: "b.jpg", Model: "other", Read: "2931.002"}, // a digit short
+		{Image: "dark.jpg", Model: "other", Error: "429 Too Many Requests", Failed: true},
+	}
+	r := bench.NewReport(m, []string{"good", "other"}, outs)
+	good, other := r.Models[0], r.Models[1]
+	if good.Correct != 3 || good.Accuracy != 1 || good.AvgLatency != "2s" || good.FormatErrors != 0 {
+		t.Fatalf("good = %+v", good)
+	}
+	if want := []float64{0, 0, 0, 0, 0, 0, 0, 0.5}; fmt.Sprint(good.DigitErrors) != fmt.Sprint(want) {
+		t.Fatalf("good digit errors = %v, want %v", good.DigitErrors, want)
+	}
+	if good.Cost == nil || fmt.Sprintf("%.5f", *good.Cost) != "0.00165" {
+		t.Fatalf("good cost = %v", good.Cost)
+	}
+	if other.Correct != 0 || other.Answered != 2 || other.Failed != 1 || other.FormatErrors != 1 ||
+		other.AmbiguityRate != 0.5 || other.DigitErrors[6] != 1 || other.Cost != nil || r.Complete() {
+		t.Fatalf("other = %+v", other)
+	}
+
+	md := r.Markdown()
+	for _, want := range []string{
+		"| good | 100.0% (3/3) | 0.0% | 0 | 2s | 1000 in, 100 out | $0.0016 ($0.55 per 1000 reads) |",
+		"| other | 0.0% (0/2) | 50.0% | 1 | 0s | 0 in, 0 out | - |",
+		"| good | 0.0% | 0.0% | 0.0% | 0.0% | 0.0% | 0.0% | 0.0% | 50.0% |",
+		"- other, b.jpg: want 02931.002, got 2931.002",
+		"other: 1 failed and 0 not read yet",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md)
+		}
+	}
+	if _, err := r.Render("json"); err != nil {
+		t.Fatalf("Render json: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// cacheKey identifies an answer: the same image, read by the same model
+// with the same prompts, gets the same answer.
+type cacheKey struct {
+	sha, model, promptHash string
+}
+
+// Cache keeps the outcomes of the model answers, as a file of JSON lines
+// appended to as they come, so that an interrupted run loses no more than
+// the reading in flight. A nil Cache caches nothing. It is safe for
+// concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	f        *os.File
+	outcomes map[cacheKey]Outcome
+}
+
+// OpenCache opens the cache file at path, creating it if needed. A last
+// line cut short, by a run killed while writing it, is dropped.
+func OpenCache(path string) (*Cache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{f: f, outcomes: make(map[cacheKey]Outcome)}
+	if err := c.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("load cache %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// load reads the outcomes of the file and leaves it positioned for appending
+// after the last complete line.
+func (c *Cache) load() error {
+	var end int64 // of the last complete line
+	r := bufio.NewReader(c.f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // a partial line, if any, is dropped
+		}
+		if err != nil {
+			return err
+		}
+		end += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var o Outcome
+		if err := json.Unmarshal(line, &o); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		c.outcomes[cacheKey{o.ImageSHA256, o.Model, o.PromptHash}] = o
+	}
+	if err := c.f.Truncate(end); err != nil {
+		return err
+	}
+	_, err := c.f.Seek(end, io.SeekStart)
+	return err
+}
+
+// Len returns the number of outcomes cached.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.outcomes)
+}
+
+// Get returns the outcome of the model reading the image of SHA-256 sha
+// with the prompts of promptHash, if cached.
+func (c *Cache) Get(sha, model, promptHash string) (Outcome, bool) {
+	if c == nil {
+		return Outcome{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.outcomes[cacheKey{sha, model, promptHash}]
+	return o, ok
+}
+
+// Put caches o, unless it failed.
+func (c *Cache) Put(o Outcome) error {
+	if c == nil || o.Failed {
+		return nil
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	c.outcomes[cacheKey{o.ImageSHA256, o.Model, o.PromptHash}] = o
+	return nil
+}
+
+// Close closes the file.
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.f.Close()
+}
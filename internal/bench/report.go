@@ -0,0 +1,258 @@
+package bench
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Miss is an image a model got wrong.
+type Miss struct {
+	Image string `json:"image"`
+	Want  string `json:"want"`
+	Got   string `json:"got,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ModelReport is how well a model did.
+type ModelReport struct {
+	Model      string `json:"model"`
+	PromptHash string `json:"prompt_hash,omitempty"`
+	Cases      int    `json:"cases"`
+	// Answered counts the images the model answered for; Failed the calls
+	// that failed and Pending the images not read yet, both left for the
+	// next run.
+	Answered int `json:"answered"`
+	Failed   int `json:"failed,omitempty"`
+	Pending  int `json:"pending,omitempty"`
+	Correct  int `json:"correct"`
+	// Accuracy is Correct / Answered.
+	Accuracy float64 `json:"accuracy"`
+	// DigitErrors are, by position among the digits of the labels, first
+	// digit first, the share of the readings in the format of their label
+	// that have the digit wrong or ambiguous.
+	DigitErrors []float64 `json:"digit_errors"`
+	// FormatErrors counts the labeled images with no reading or a reading
+	// in another format than the label, e.g. a digit short.
+	FormatErrors int `json:"format_errors"`
+	// Ambiguous counts the readings with uncertain digits, and
+	// AmbiguityRate is their share of the readings.
+	Ambiguous     int     `json:"ambiguous"`
+	AmbiguityRate float64 `json:"ambiguity_rate"`
+	AvgLatency    string  `json:"avg_latency"`
+	// Tokens are the tokens of all the answers, and Cost their cost in USD
+	// if the model is priced.
+	Tokens      genai.Usage `json:"tokens"`
+	Cost        *float64    `json:"cost_usd,omitempty"`
+	CostPerRead *float64    `json:"cost_usd_per_read,omitempty"`
+	Misses      []Miss      `json:"misses,omitempty"`
+}
+
+// Report compares the models of a run.
+type Report struct {
+	Cases  int           `json:"cases"`
+	Models []ModelReport `json:"models"`
+}
+
+// Complete reports whether every model answered for every image.
+func (r *Report) Complete() bool {
+	for _, m := range r.Models {
+		if m.Answered < m.Cases {
+			return false
+		}
+	}
+	return true
+}
+
+// NewReport reports the outcomes of models, in order, over the cases of m.
+func NewReport(m *Manifest, models []string, outcomes []Outcome) *Report {
+	cases := make(map[string]Case, len(m.Cases))
+	for _, c := range m.Cases {
+		cases[c.Image] = c
+	}
+	r := &Report{Cases: len(m.Cases)}
+	for _, name := range models {
+		mr := ModelReport{Model: name, Cases: len(m.Cases), DigitErrors: []float64{}}
+		var (
+			latency      time.Duration
+			reads        int
+			wrong, total []int // by digit position
+		)
+		for _, o := range outcomes {
+			c, ok := cases[o.Image]
+			if o.Model != name || !ok {
+				continue
+			}
+			if o.Failed {
+				mr.Failed++
+				continue
+			}
+			mr.Answered++
+			mr.PromptHash = o.PromptHash
+			latency += o.Latency
+			mr.Tokens.InputTokens += o.Usage.InputTokens
+			mr.Tokens.OutputTokens += o.Usage.OutputTokens
+			mr.Tokens.CachedTokens += o.Usage.CachedTokens
+			if o.Error == "" {
+				reads++
+				if o.Ambiguous {
+					mr.Ambiguous++
+				}
+			}
+			if c.Match(o.Read, o.err()) {
+				mr.Correct++
+			} else {
+				want := c.Read
+				if c.Unreadable {
+					want = "(unreadable)"
+				}
+				mr.Misses = append(mr.Misses, Miss{Image: o.Image, Want: want, Got: o.Read, Error: o.Error})
+			}
+			if c.Unreadable {
+				continue
+			}
+			if o.Error != "" || !sameFormat(c.Read, o.Read) {
+				mr.FormatErrors++
+				continue
+			}
+			d := 0
+			for i := range c.Read {
+				if !isDigit(c.Read[i]) {
+					continue
+				}
+				if d == len(total) {
+					wrong, total = append(wrong, 0), append(total, 0)
+				}
+				total[d]++
+				if o.Read[i] != c.Read[i] {
+					wrong[d]++
+				}
+				d++
+			}
+		}
+		mr.Pending = max(mr.Cases-mr.Answered-mr.Failed, 0)
+		for d := range total {
+			mr.DigitErrors = append(mr.DigitErrors, float64(wrong[d])/float64(total[d]))
+		}
+		if mr.Answered > 0 {
+			mr.Accuracy = float64(mr.Correct) / float64(mr.Answered)
+			mr.AvgLatency = (latency / time.Duration(mr.Answered)).Round(time.Millisecond).String()
+		}
+		if reads > 0 {
+			mr.AmbiguityRate = float64(mr.Ambiguous) / float64(reads)
+		}
+		if p, ok := m.Prices[name]; ok {
+			cost := p.Cost(mr.Tokens)
+			mr.Cost = &cost
+			if mr.Answered > 0 {
+				per := cost / float64(mr.Answered)
+				mr.CostPerRead = &per
+			}
+		}
+		r.Models = append(r.Models, mr)
+	}
+	return r
+}
+
+// sameFormat reports whether got has the digits of want where want has.
+func sameFormat(want, got string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if isDigit(want[i]) != (isDigit(got[i]) || got[i] == '?') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// Markdown renders r as Markdown tables.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Models on %d images\n\n", r.Cases)
+	b.WriteString("| Model | Accuracy | Ambiguous | Format errors | Avg latency | Tokens per read | Cost |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	width := 0
+	for _, m := range r.Models {
+		tokens, cost := "-", "-"
+		if m.Answered > 0 {
+			tokens = fmt.Sprintf("%d in, %d out", m.Tokens.InputTokens/m.Answered, m.Tokens.OutputTokens/m.Answered)
+		}
+		if m.Cost != nil {
+			cost = fmt.Sprintf("$%.4f", *m.Cost)
+			if m.CostPerRead != nil {
+				cost += fmt.Sprintf(" ($%.2f per 1000 reads)", *m.CostPerRead*1000)
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s (%d/%d) | %s | %d | %s | %s | %s |\n", m.Model, percent(m.Accuracy), m.Correct, m.Answered,
+			percent(m.AmbiguityRate), m.FormatErrors, cmp.Or(m.AvgLatency, "-"), tokens, cost)
+		width = max(width, len(m.DigitErrors))
+	}
+
+	if width > 0 {
+		b.WriteString("\n### Digit error rates\n\n| Model |")
+		for d := range width {
+			fmt.Fprintf(&b, " %d |", d+1)
+		}
+		b.WriteString("\n|---|" + strings.Repeat("---|", width) + "\n")
+		for _, m := range r.Models {
+			fmt.Fprintf(&b, "| %s |", m.Model)
+			for d := range width {
+				cell := "-"
+				if d < len(m.DigitErrors) {
+					cell = percent(m.DigitErrors[d])
+				}
+				fmt.Fprintf(&b, " %s |", cell)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	var misses []string
+	for _, m := range r.Models {
+		for _, x := range m.Misses {
+			got := x.Got
+			if x.Error != "" {
+				got = "error: " + genai.Truncate(x.Error, 80)
+			}
+			misses = append(misses, fmt.Sprintf("- %s, %s: want %s, got %s\n", m.Model, x.Image, x.Want, cmp.Or(got, `""`)))
+		}
+	}
+	if len(misses) > 0 {
+		b.WriteString("\n### Misses\n\n" + strings.Join(misses, ""))
+	}
+
+	for _, m := range r.Models {
+		if m.Failed > 0 || m.Pending > 0 {
+			fmt.Fprintf(&b, "\n%s: %d failed and %d not read yet; run again to complete.\n", m.Model, m.Failed, m.Pending)
+		}
+	}
+	return b.String()
+}
+
+func percent(f float64) string { return fmt.Sprintf("%.1f%%", f*100) }
+
+// JSON renders r as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Render renders r in format: "markdown" (default) or "json".
+func (r *Report) Render(format string) (string, error) {
+	switch format {
+	case "", "markdown", "md":
+		return r.Markdown(), nil
+	case "json":
+		b, err := r.JSON()
+		return string(b) + "\n", err
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+}
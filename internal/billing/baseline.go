@@ -0,0 +1,76 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Baseline is the value of a meter when a contract started, such as the
+// reading a supplier's billing year counts from.
+type Baseline struct {
+	At   time.Time `yaml:"at"`
+	Read string    `yaml:"read"`
+}
+
+// Validate checks that b has a time and a reading of m.
+func (b Baseline) Validate(m genai.Meter) error {
+	if b.At.IsZero() {
+		return errors.New("needs at")
+	}
+	if _, err := genai.ParseRead(m, b.Read); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	return nil
+}
+
+// Since is the consumption of a meter since a [Baseline], kept up to date
+// one reading at a time. It counts from the configured value of the
+// baseline, never from the readings around its time, so a baseline before
+// the first stored reading counts all that was consumed until then as of
+// that reading.
+type Since struct {
+	Baseline Baseline
+	// Usage is the corrected consumption up to the reading at At.
+	Usage Usage
+	At    time.Time
+
+	t    Tariff
+	m    genai.Meter
+	last float64
+}
+
+// SinceBaseline returns the consumption of m since b, corrected with the
+// factors of t in effect at each reading, up to the last of rs, readings
+// oldest first; those not after b are skipped. It is derived anew from the
+// readings, so changing b changes it without touching the history.
+func (t Tariff) SinceBaseline(m genai.Meter, b Baseline, rs []*genai.GasMeterReadResult) *Since {
+	s := &Since{Baseline: b, At: b.At, t: t, m: m}
+	s.last, _ = genai.ParseRead(m, b.Read) // checked by Validate
+	for _, r := range rs {
+		s.Add(r)
+	}
+	return s
+}
+
+// Add counts the increase up to r, as between the readings of
+// [Consumption], and returns the consumption since the baseline. Readings
+// not after the last one added are skipped.
+func (s *Since) Add(r *genai.GasMeterReadResult) Usage {
+	if !r.Counted() || !r.ReadAt.After(s.At) {
+		return s.Usage
+	}
+	v, err := genai.ParseRead(s.m, r.Read)
+	if err != nil {
+		return s.Usage
+	}
+	d, ok := s.m.DeltaTo(s.last, r, v)
+	if !ok {
+		return s.Usage // keep last: the lower value is the misread
+	}
+	s.Usage = s.Usage.Add(s.t.Correct(r.ReadAt, d))
+	s.last, s.At = v, r.ReadAt
+	return s.Usage
+}
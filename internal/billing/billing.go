@@ -0,0 +1,468 @@
+// Package billing estimates the cost of gas consumption under a tariff.
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Units a tariff may price.
+const (
+	UnitM3  = "m³"
+	UnitKWh = "kWh"
+)
+
+// MJPerKWh converts calorific energy to kWh.
+const MJPerKWh = 3.6
+
+// Tier prices consumption up to UpTo billed units in a billing period;
+// the last tier has UpTo 0 and prices the rest.
+type Tier struct {
+	UpTo  float64 `yaml:"up_to" json:"up_to,omitempty"`
+	Price float64 `yaml:"price" json:"price"`
+}
+
+// Tariff is a supplier's price model.
+type Tariff struct {
+	// StandingCharge is the fixed charge per day.
+	StandingCharge float64 `yaml:"standing_charge"`
+	// Unit is what prices apply to: UnitM3 (default) or UnitKWh. Consumption of
+	// meters that count kWh themselves, like electricity meters, is priced as is
+	// with the default; UnitKWh converts gas m³ with CalorificValue.
+	Unit string `yaml:"unit"`
+	// UnitPrice is the price per Unit; Tiers replaces it when set.
+	UnitPrice float64 `yaml:"unit_price"`
+	Tiers     []Tier  `yaml:"tiers"`
+	// CorrectionFactor converts metered to corrected (billed) m³ and defaults
+	// to 1; CalorificValue (MJ/m³) converts corrected m³ to kWh:
+	// kWh = m³ × CorrectionFactor × CalorificValue / 3.6. Both apply until the
+	// first of Corrections takes effect.
+	CorrectionFactor float64 `yaml:"correction_factor"`
+	CalorificValue   float64 `yaml:"calorific_value"`
+	// Corrections are later changes of the correction, in effective order.
+	Corrections []Correction `yaml:"corrections"`
+	Currency    Currency     `yaml:"currency"`
+}
+
+// Correction is a correction factor and calorific value in effect from a
+// date (UTC midnight) on. Zero fields keep the previous value.
+type Correction struct {
+	From           time.Time `yaml:"from"`
+	Factor         float64   `yaml:"factor"`
+	CalorificValue float64   `yaml:"calorific_value"`
+}
+
+// Validate checks that the tariff can price consumption.
+func (t Tariff) Validate() error {
+	switch t.Unit {
+	case "", UnitM3, UnitKWh:
+	default:
+		return fmt.Errorf("unknown unit %q", t.Unit)
+	}
+	for i, tier := range t.Tiers {
+		last := i == len(t.Tiers)-1
+		if tier.UpTo == 0 && !last || tier.UpTo != 0 && last {
+			return errors.New("only the last tier must have no up_to")
+		}
+		if i > 0 && !last && tier.UpTo <= t.Tiers[i-1].UpTo {
+			return errors.New("tiers must be in increasing up_to order")
+		}
+	}
+	if t.CorrectionFactor < 0 || t.CalorificValue < 0 {
+		return errors.New("negative correction_factor or calorific_value")
+	}
+	for i, c := range t.Corrections {
+		if c.From.IsZero() {
+			return fmt.Errorf("correction %d: needs from", i+1)
+		}
+		if i > 0 && !c.From.After(t.Corrections[i-1].From) {
+			return errors.New("corrections must be in increasing from order")
+		}
+		if c.Factor < 0 || c.CalorificValue < 0 {
+			return fmt.Errorf("correction %d: negative factor or calorific_value", i+1)
+		}
+	}
+	if t.unit() == UnitKWh {
+		starts := []time.Time{{}}
+		for _, c := range t.Corrections {
+			starts = append(starts, c.From)
+		}
+		for _, at := range starts {
+			if _, cv := t.correctionAt(at); cv <= 0 {
+				return errors.New("unit kWh needs a calorific_value")
+			}
+		}
+	}
+	return t.Currency.Validate()
+}
+
+// correctionAt returns the correction factor and calorific value in effect at at.
+func (t Tariff) correctionAt(at time.Time) (factor, cv float64) {
+	factor, cv = t.CorrectionFactor, t.CalorificValue
+	for _, c := range t.Corrections {
+		if at.Before(c.From) {
+			break
+		}
+		if c.Factor != 0 {
+			factor = c.Factor
+		}
+		if c.CalorificValue != 0 {
+			cv = c.CalorificValue
+		}
+	}
+	if factor == 0 {
+		factor = 1
+	}
+	return factor, cv
+}
+
+// Usage is a consumption as metered and as billed. The raw meter reading is
+// never corrected; only consumption derived from it is.
+type Usage struct {
+	// Raw is the metered consumption in m³.
+	Raw float64 `json:"raw"`
+	// Corrected is Raw times the correction factor in effect.
+	Corrected float64 `json:"corrected"`
+	// Energy is Corrected in kWh, or 0 without a calorific value.
+	Energy float64 `json:"energy_kwh,omitempty"`
+}
+
+// Add returns the sum of u and v.
+func (u Usage) Add(v Usage) Usage {
+	return Usage{Raw: u.Raw + v.Raw, Corrected: u.Corrected + v.Corrected, Energy: u.Energy + v.Energy}
+}
+
+// Sub returns u minus v.
+func (u Usage) Sub(v Usage) Usage {
+	return Usage{Raw: u.Raw - v.Raw, Corrected: u.Corrected - v.Corrected, Energy: u.Energy - v.Energy}
+}
+
+// Scale returns u times f.
+func (u Usage) Scale(f float64) Usage {
+	return Usage{Raw: u.Raw * f, Corrected: u.Corrected * f, Energy: u.Energy * f}
+}
+
+// Correct applies the correction in effect at at to raw m³ consumed then.
+func (t Tariff) Correct(at time.Time, raw float64) Usage {
+	factor, cv := t.correctionAt(at)
+	u := Usage{Raw: raw, Corrected: raw * factor}
+	u.Energy = u.Corrected * cv / MJPerKWh
+	return u
+}
+
+// Consumption sums the corrected increases between consecutive readings as
+// [Consumption] does, correcting each with the factor in effect at the later
+// reading, so that history is recomputed with the factors of its time.
+func (t Tariff) Consumption(m genai.Meter, rs []*genai.GasMeterReadResult) Usage {
+	var u Usage
+	deltas(m, rs, func(at time.Time, d float64) {
+		u = u.Add(t.Correct(at, d))
+	})
+	return u
+}
+
+// Attributions of the consumption across a gap in the readings; see
+// [genai.GasMeterReadResult.GapBefore].
+const (
+	GapSpread = "spread" // evenly over the gap, as between any readings
+	GapAtEnd  = "end"    // all at the reading after the gap
+)
+
+// Point is the consumption accumulated from the first usable reading up to
+// a reading at At.
+type Point struct {
+	At time.Time
+	Usage
+	// AtEnd counts the consumption since the previous point at At rather
+	// than spread out up to it.
+	AtEnd bool
+	// Thinned marks a point the readings after which were downsampled: the
+	// interval up to the next point is known to be no gap.
+	Thinned bool
+}
+
+// MarkGaps sets AtEnd on the points of ps at the readings of rs that follow
+// a gap, for [GapAtEnd].
+func MarkGaps(ps []Point, rs []*genai.GasMeterReadResult) {
+	gaps := make(map[time.Time]bool)
+	for _, r := range rs {
+		if r.Gap() > 0 {
+			gaps[r.ReadAt] = true
+		}
+	}
+	for i := range ps {
+		if gaps[ps[i].At] {
+			ps[i].AtEnd = true
+		}
+	}
+}
+
+// Cumulative returns the accumulated corrected consumption at each usable
+// reading of rs, starting with zero at the first one; readings are skipped
+// as by [Consumption]. Points at downsampled readings are Thinned.
+func (t Tariff) Cumulative(m genai.Meter, rs []*genai.GasMeterReadResult) []Point {
+	var ps []Point
+	for _, r := range rs {
+		if r.Counted() {
+			if _, err := genai.ParseRead(m, r.Read); err == nil {
+				ps = append(ps, Point{At: r.ReadAt})
+				break
+			}
+		}
+	}
+	deltas(m, rs, func(at time.Time, d float64) {
+		ps = append(ps, Point{At: at, Usage: ps[len(ps)-1].Add(t.Correct(at, d))})
+	})
+	thinned := make(map[time.Time]bool)
+	for _, r := range rs {
+		if r.Downsampled != "" {
+			thinned[r.ReadAt] = true
+		}
+	}
+	for i := range ps {
+		ps[i].Thinned = thinned[ps[i].At]
+	}
+	return ps
+}
+
+// UsageAt interpolates the accumulated consumption of ps at at, assuming an
+// even flow between consecutive readings, or none before a point marked
+// AtEnd. Before the first and after the last point it is that point's, as
+// nothing is known of the flow there.
+func UsageAt(ps []Point, at time.Time) Usage {
+	i := sort.Search(len(ps), func(i int) bool { return !ps[i].At.Before(at) })
+	switch {
+	case len(ps) == 0:
+		return Usage{}
+	case i == 0:
+		return ps[0].Usage
+	case i == len(ps):
+		return ps[i-1].Usage
+	}
+	a, b := ps[i-1], ps[i]
+	if b.AtEnd && at.Before(b.At) {
+		return a.Usage
+	}
+	f := float64(at.Sub(a.At)) / float64(b.At.Sub(a.At))
+	return a.Add(b.Sub(a.Usage).Scale(f))
+}
+
+// Between returns the consumption of ps in [from, to), interpolated at both ends.
+func Between(ps []Point, from, to time.Time) Usage {
+	return UsageAt(ps, to).Sub(UsageAt(ps, from))
+}
+
+// Edge returns the accumulated consumption of ps at at, the start or end of a
+// period, interpolated as by [UsageAt] between the readings on either side
+// of at if they are at most maxGap apart (0: any gap) or the earlier is
+// Thinned, the interval being downsampled rather than a gap. Otherwise, or
+// if at is before the first or after the last point, ok is false and it is
+// the consumption at the nearest point inside the period: the flow in the
+// gap is left out rather than guessed.
+func Edge(ps []Point, at time.Time, maxGap time.Duration, start bool) (u Usage, ok bool) {
+	i := sort.Search(len(ps), func(i int) bool { return !ps[i].At.Before(at) })
+	switch {
+	case len(ps) == 0:
+		return Usage{}, false
+	case i < len(ps) && ps[i].At.Equal(at):
+		return ps[i].Usage, true
+	case i > 0 && i < len(ps) && (maxGap == 0 || ps[i-1].Thinned || ps[i].At.Sub(ps[i-1].At) <= maxGap):
+		return UsageAt(ps, at), true
+	case start:
+		return ps[min(i, len(ps)-1)].Usage, false
+	default:
+		return ps[max(i-1, 0)].Usage, false
+	}
+}
+
+// Span returns the consumption of ps in [from, to) between the [Edge]s of the
+// period; complete is false if either edge falls in a gap of more than
+// maxGap or outside the readings. Consecutive complete periods add up to the
+// consumption over all of them.
+func Span(ps []Point, from, to time.Time, maxGap time.Duration) (u Usage, complete bool) {
+	a, okFrom := Edge(ps, from, maxGap, true)
+	b, okTo := Edge(ps, to, maxGap, false)
+	if u = b.Sub(a); u.Raw < 0 {
+		u = Usage{} // both edges in the same gap
+	}
+	return u, okFrom && okTo
+}
+
+// Estimate is the cost of a consumption over a number of days. Amounts are
+// unrounded except Total, which is the rounded sum; see [Currency.Round].
+type Estimate struct {
+	Days float64 `json:"days"`
+	Usage
+	// Billed is the consumption in Unit: Corrected for m³, Energy for kWh.
+	Billed   float64 `json:"billed"`
+	Unit     string  `json:"unit"`
+	Standing float64 `json:"standing"`
+	Charge   float64 `json:"charge"` // for the billed consumption
+	Total    float64 `json:"total"`
+	// Formatted is Total in the tariff's currency format.
+	Formatted string `json:"formatted"`
+}
+
+// Cost prices consumption u over days.
+func (t Tariff) Cost(u Usage, days float64) Estimate {
+	e := Estimate{Days: days, Usage: u, Billed: u.Corrected, Unit: t.unit()}
+	if e.Unit == UnitKWh {
+		e.Billed = u.Energy
+	}
+	e.Standing = t.StandingCharge * days
+	e.Charge = t.usageCost(e.Billed)
+	e.Total = t.Currency.Round(e.Standing + e.Charge)
+	e.Formatted = t.Currency.Format(e.Total)
+	return e
+}
+
+func (t Tariff) unit() string {
+	if t.Unit == "" {
+		return UnitM3
+	}
+	return t.Unit
+}
+
+func (t Tariff) usageCost(billed float64) float64 {
+	if len(t.Tiers) == 0 {
+		return billed * t.UnitPrice
+	}
+	var cost, below float64
+	for _, tier := range t.Tiers {
+		upTo := tier.UpTo
+		if upTo == 0 || billed < upTo {
+			upTo = billed
+		}
+		if upTo > below {
+			cost += (upTo - below) * tier.Price
+			below = upTo
+		}
+	}
+	return cost
+}
+
+// Period prices the consumption of meterID recorded in s in [from, to),
+// interpolated at both ends between the nearest readings within a period's
+// length of them, as by [Between].
+func (t Tariff) Period(ctx context.Context, s store.Store, m genai.Meter, meterID string, from, to time.Time) (Estimate, error) {
+	margin := to.Sub(from)
+	rs, err := s.ReadingsBetween(ctx, meterID, from.Add(-margin), to.Add(margin))
+	if err != nil {
+		return Estimate{}, fmt.Errorf("load history: %w", err)
+	}
+	return t.Cost(Between(t.Cumulative(m, rs), from, to), to.Sub(from).Hours()/24), nil
+}
+
+// Consumption sums the increases between consecutive readings, allowing for
+// rollover and meter exchanges. Stale, maintenance and unparseable readings
+// and decreases (misreads) are skipped.
+func Consumption(m genai.Meter, rs []*genai.GasMeterReadResult) float64 {
+	var total float64
+	deltas(m, rs, func(_ time.Time, d float64) { total += d })
+	return total
+}
+
+// deltas calls fn with each increase between consecutive readings and the
+// time of the later one.
+func deltas(m genai.Meter, rs []*genai.GasMeterReadResult, fn func(at time.Time, d float64)) {
+	var prev float64
+	havePrev := false
+	for _, r := range rs {
+		if !r.Counted() {
+			continue
+		}
+		v, err := genai.ParseRead(m, r.Read)
+		if err != nil {
+			continue
+		}
+		if havePrev {
+			d, ok := m.DeltaTo(prev, r, v)
+			if !ok {
+				continue // keep prev: the lower value is the misread
+			}
+			fn(r.ReadAt, d)
+		}
+		prev, havePrev = v, true
+	}
+}
+
+// Rounding modes of [Currency].
+const (
+	RoundHalfUp   = "half_up"   // 0.5 rounds away from zero (default)
+	RoundHalfEven = "half_even" // 0.5 rounds to the even digit
+	RoundDown     = "down"      // truncate towards zero
+)
+
+// Currency formats and rounds amounts.
+type Currency struct {
+	Symbol string `yaml:"symbol"` // e.g. "₩", "€"
+	// Decimals is the number of minor digits amounts are rounded to, e.g. 0
+	// for KRW and 2 for EUR.
+	Decimals int `yaml:"decimals"`
+	// Locale selects the separators, as a [numfmt.Locale]: "en" (default,
+	// 1,234.56), "ko" (1,234.56) or "de" (1.234,56), for example.
+	Locale   string `yaml:"locale"`
+	Rounding string `yaml:"rounding"`
+	// SymbolAfter places the symbol after the amount, e.g. "1.234,56 €".
+	SymbolAfter bool `yaml:"symbol_after"`
+}
+
+// Validate checks the locale and rounding mode.
+func (c Currency) Validate() error {
+	if _, err := numfmt.Parse(c.Locale); err != nil {
+		return fmt.Errorf("currency: %w", err)
+	}
+	switch c.Rounding {
+	case "", RoundHalfUp, RoundHalfEven, RoundDown:
+	default:
+		return fmt.Errorf("unknown rounding %q", c.Rounding)
+	}
+	if c.Decimals < 0 || c.Decimals > 6 {
+		return fmt.Errorf("decimals %d out of range", c.Decimals)
+	}
+	return nil
+}
+
+// Round rounds v to Decimals digits with the configured rounding mode.
+func (c Currency) Round(v float64) float64 {
+	scale := math.Pow10(c.Decimals)
+	// Round away float noise first so 1.005 (1.00499…) rounds as written.
+	x := math.Round(v*scale*1e6) / 1e6
+	switch c.Rounding {
+	case RoundHalfEven:
+		x = math.RoundToEven(x)
+	case RoundDown:
+		x = math.Trunc(x)
+	default:
+		x = math.Round(x)
+	}
+	return x / scale
+}
+
+// Format rounds v and formats it with the locale's separators and the symbol.
+func (c Currency) Format(v float64) string {
+	l, _ := numfmt.Parse(c.Locale) // checked by Validate
+	if l == "" {
+		l = "en"
+	}
+	out := l.Float(math.Abs(c.Round(v)), c.Decimals)
+	if c.Symbol != "" {
+		if c.SymbolAfter {
+			out += " " + c.Symbol
+		} else {
+			out = c.Symbol + out
+		}
+	}
+	if v < 0 && c.Round(v) != 0 {
+		out = "-" + out
+	}
+	return out
+}
@@ -0,0 +1,317 @@
+package billing_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestTariffCost(t *testing.T) {
+	t.Parallel()
+
+	krw := billing.Currency{Symbol: "₩", Decimals: 0, Locale: "ko"}
+	tests := []struct {
+		name   string
+		tariff billing.Tariff
+		volume float64
+		days   float64
+		billed float64
+		total  float64
+		text   string
+	}{
+		{"flat per m³", billing.Tariff{StandingCharge: 30, UnitPrice: 1000, Currency: krw}, 12.5, 30, 12.5, 13400, "₩13,400"},
+		{"kWh with correction", billing.Tariff{
+			Unit: billing.UnitKWh, UnitPrice: 0.1, CalorificValue: 39.6, CorrectionFactor: 0.9476,
+			Currency: billing.Currency{Symbol: "€", Decimals: 2, Locale: "de", SymbolAfter: true},
+		}, 100, 0, 100 * 0.9476 * 39.6 / 3.6, 104.24, "104,24 €"},
+		{"tiers", billing.Tariff{
+			UnitPrice: 999, // ignored
+			Tiers:     []billing.Tier{{UpTo: 10, Price: 100}, {UpTo: 20, Price: 200}, {Price: 400}},
+			Currency:  krw,
+		}, 25, 0, 25, 10*100 + 10*200 + 5*400, "₩5,000"},
+		{"within the first tier", billing.Tariff{
+			Tiers:    []billing.Tier{{UpTo: 10, Price: 100}, {Price: 400}},
+			Currency: krw,
+		}, 4, 0, 4, 400, "₩400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.tariff.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			e := tt.tariff.Cost(tt.tariff.Correct(time.Time{}, tt.volume), tt.days)
+			if math.Abs(e.Billed-tt.billed) > 1e-9 || e.Total != tt.total || e.Formatted != tt.text {
+				t.Fatalf("Cost = %+v; want billed %v, total %v (%s)", e, tt.billed, tt.total, tt.text)
+			}
+		})
+	}
+}
+
+func TestCurrencyRound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rounding string
+		in, want float64
+	}{
+		{"", 1.005, 1.01},
+		{billing.RoundHalfUp, 2.345, 2.35},
+		{billing.RoundHalfEven, 2.345, 2.34},
+		{billing.RoundHalfEven, 2.355, 2.36},
+		{billing.RoundDown, 2.349, 2.34},
+		{billing.RoundHalfUp, -2.345, -2.35},
+	}
+	for _, tt := range tests {
+		c := billing.Currency{Decimals: 2, Rounding: tt.rounding}
+		if got := c.Round(tt.in); got != tt.want {
+			t.Fatalf("Round(%v) with %q = %v, want %v", tt.in, tt.rounding, got, tt.want)
+		}
+	}
+	if got := (billing.Currency{Symbol: "$", Decimals: 2}).Format(-1234567.891); got != "-$1,234,567.89" {
+		t.Fatalf("Format = %q", got)
+	}
+}
+
+func TestTariffValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tariff := range []billing.Tariff{
+		{Unit: "therm"},
+		{Unit: billing.UnitKWh},
+		{Tiers: []billing.Tier{{Price: 1}, {UpTo: 10, Price: 2}}},
+		{Tiers: []billing.Tier{{UpTo: 10, Price: 1}, {UpTo: 5, Price: 2}, {Price: 3}}},
+		{Unit: billing.UnitKWh, CalorificValue: 40, Corrections: []billing.Correction{{From: time.Now(), Factor: 0.9}}, CorrectionFactor: -1},
+		{Corrections: []billing.Correction{{Factor: 0.9}}},
+		{Corrections: []billing.Correction{{From: time.Now(), Factor: 0.9}, {From: time.Now().AddDate(0, 0, -1), Factor: 0.95}}},
+		{Currency: billing.Currency{Rounding: "up"}},
+		{Currency: billing.Currency{Locale: "tlh"}},
+	} {
+		if err := tariff.Validate(); err == nil {
+			t.Fatalf("Validate(%+v) = nil, want an error", tariff)
+		}
+	}
+}
+
+func TestTariffPeriod(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.NewMemory()
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	for i, read := range []string{"99998.000", "99999.500", "00001.000", "00000.900", "00003.000"} {
+		// 00000.900 is a misread and must not count as 99999.9 m³.
+		r := &genai.GasMeterReadResult{Read: read, ReadAt: from.Add(time.Duration(i) * 24 * time.Hour)}
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tariff := billing.Tariff{StandingCharge: 10, UnitPrice: 100, Currency: billing.Currency{Decimals: 0}}
+	e, err := tariff.Period(ctx, s, genai.DefaultMeter, "home", from, from.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("Period: %v", err)
+	}
+	if math.Abs(e.Raw-5) > 1e-9 || e.Days != 10 || e.Total != 600 {
+		t.Fatalf("Period = %+v; want 5 m³ over 10 days costing 600", e)
+	}
+}
+
+func TestTariffCorrections(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	tariff := billing.Tariff{
+		Unit: billing.UnitKWh, UnitPrice: 0.1, CalorificValue: 36, // 10 kWh per m³
+		Corrections: []billing.Correction{
+			{From: day(10), Factor: 0.9},
+			{From: day(20), CalorificValue: 72},
+		},
+		Currency: billing.Currency{Decimals: 2},
+	}
+	if err := tariff.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	tests := []struct {
+		at                time.Time
+		corrected, energy float64
+	}{
+		{day(1), 10, 100},
+		{day(10), 9, 90},
+		{day(19).Add(23 * time.Hour), 9, 90},
+		{day(20), 9, 180},
+	}
+	for _, tt := range tests {
+		u := tariff.Correct(tt.at, 10)
+		if u.Raw != 10 || math.Abs(u.Corrected-tt.corrected) > 1e-9 || math.Abs(u.Energy-tt.energy) > 1e-9 {
+			t.Fatalf("Correct(%s, 10) = %+v; want corrected %v, energy %v", tt.at, u, tt.corrected, tt.energy)
+		}
+	}
+
+	// History is recomputed with the factor valid at each reading.
+	var rs []*genai.GasMeterReadResult
+	for i, read := range []string{"00100.000", "00110.000", "00120.000", "00130.000"} {
+		rs = append(rs, &genai.GasMeterReadResult{Read: read, ReadAt: day(1 + 9*i)})
+	}
+	u := tariff.Consumption(genai.DefaultMeter, rs)
+	if math.Abs(u.Raw-30) > 1e-9 || math.Abs(u.Corrected-27) > 1e-9 || math.Abs(u.Energy-360) > 1e-9 {
+		t.Fatalf("Consumption = %+v; want raw 30, corrected 27, energy 360", u)
+	}
+	if e := tariff.Cost(u, 0); e.Billed != u.Energy || e.Total != 36 {
+		t.Fatalf("Cost = %+v; want 360 kWh billed for 36", e)
+	}
+}
+
+func TestSinceBaseline(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2025, 7, d, 0, 0, 0, 0, time.UTC) }
+	tariff := billing.Tariff{Corrections: []billing.Correction{{From: day(5), Factor: 0.5}}}
+	var rs []*genai.GasMeterReadResult
+	for i, read := range []string{"00090.000", "00112.000", "00111.000", "00120.000"} {
+		// 00090.000 is before the baseline, 00111.000 a misread.
+		rs = append(rs, &genai.GasMeterReadResult{Read: read, ReadAt: day(1 + 2*i)})
+	}
+
+	tests := []struct {
+		name      string
+		baseline  billing.Baseline
+		raw, corr float64
+	}{
+		// The history starts before the baseline: readings up to it are skipped.
+		{"within the history", billing.Baseline{At: day(2), Read: "00100.000"}, 20, 16},
+		// The history starts after the baseline: the first reading counts from
+		// the configured value, not from the readings around it.
+		{"before the history", billing.Baseline{At: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), Read: "00080.000"}, 40, 36},
+	}
+	for _, tt := range tests {
+		s := tariff.SinceBaseline(genai.DefaultMeter, tt.baseline, rs)
+		if math.Abs(s.Usage.Raw-tt.raw) > 1e-9 || math.Abs(s.Usage.Corrected-tt.corr) > 1e-9 || !s.At.Equal(day(7)) {
+			t.Errorf("%s: SinceBaseline = %+v at %s; want raw %v, corrected %v at %s", tt.name, s.Usage, s.At, tt.raw, tt.corr, day(7))
+		}
+	}
+
+	s := tariff.SinceBaseline(genai.DefaultMeter, billing.Baseline{At: day(2), Read: "00100.000"}, rs[:1])
+	if u := s.Add(rs[1]); u.Raw != 12 {
+		t.Fatalf("Add = %+v; want 12 m³ since the baseline", u)
+	}
+	if u := s.Add(rs[1]); u.Raw != 12 {
+		t.Fatalf("Add of the same reading again = %+v; want it skipped", u)
+	}
+	if err := (billing.Baseline{At: day(1), Read: "100"}).Validate(genai.DefaultMeter); err == nil {
+		t.Fatal("Validate accepted a reading in another format")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	t.Parallel()
+
+	at := func(h int) time.Time { return time.Date(2025, 1, 1, h, 0, 0, 0, time.UTC) }
+	var rs []*genai.GasMeterReadResult
+	for i, read := range []string{"00100.000", "00104.000", "00101.000", "00110.000"} {
+		// 00101.000 is a misread and skipped.
+		rs = append(rs, &genai.GasMeterReadResult{Read: read, ReadAt: at(4 * i)})
+	}
+	ps := billing.Tariff{}.Cumulative(genai.DefaultMeter, rs)
+	if len(ps) != 3 {
+		t.Fatalf("Cumulative = %+v; want 3 points", ps)
+	}
+	tests := []struct {
+		from, to time.Time
+		raw      float64
+	}{
+		{at(0), at(4), 4},
+		{at(2), at(6), 2 + 1.5}, // 1 m³/h, then 6 m³ over 8 h
+		{at(6), at(14), 4.5},    // clamped to the last reading
+		{at(-4), at(0), 0},
+	}
+	for _, tt := range tests {
+		if u := billing.Between(ps, tt.from, tt.to); math.Abs(u.Raw-tt.raw) > 1e-9 || u.Corrected != u.Raw {
+			t.Fatalf("Between(%s, %s) = %+v; want raw %v", tt.from, tt.to, u, tt.raw)
+		}
+	}
+}
+
+func TestSpan(t *testing.T) {
+	t.Parallel()
+
+	// A reading every 4 hours from 02:00, at 1 m³/h, with no readings
+	// between 2025-01-03 02:00 and 2025-01-04 22:00 (a 44h gap).
+	start := time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC)
+	var rs []*genai.GasMeterReadResult
+	for h := 0; h <= 6*24; h += 4 {
+		at := start.Add(time.Duration(h) * time.Hour)
+		if at.After(time.Date(2025, 1, 3, 2, 0, 0, 0, time.UTC)) && at.Before(time.Date(2025, 1, 4, 22, 0, 0, 0, time.UTC)) {
+			continue
+		}
+		rs = append(rs, &genai.GasMeterReadResult{Read: fmt.Sprintf("%05d.000", 100+h), ReadAt: at})
+	}
+	ps := billing.Tariff{}.Cumulative(genai.DefaultMeter, rs)
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		from, to time.Time
+		raw      float64
+		complete bool
+	}{
+		{day(1), day(2), 22, false}, // before the first reading
+		{day(2), day(3), 24, true},  // interpolated at both midnights
+		{day(3), day(4), 2, false},  // up to the last reading before the gap
+		{day(4), day(5), 2, false},  // from the first reading after it
+		{day(5), day(6), 24, true},
+		{day(3).Add(3 * time.Hour), day(3).Add(5 * time.Hour), 0, false}, // within the gap
+	}
+	for _, tt := range tests {
+		u, complete := billing.Span(ps, tt.from, tt.to, 12*time.Hour)
+		if math.Abs(u.Raw-tt.raw) > 1e-9 || complete != tt.complete {
+			t.Fatalf("Span(%s, %s) = %v, %v; want %v, %v", tt.from, tt.to, u.Raw, complete, tt.raw, tt.complete)
+		}
+	}
+
+	// Consecutive complete periods add up to the span over all of them, at
+	// any period boundaries.
+	for _, step := range []time.Duration{24 * time.Hour, 7 * time.Hour, 90 * time.Minute} {
+		var sum float64
+		from, to := day(5), day(7).Add(2*time.Hour)
+		for a := from; a.Before(to); a = a.Add(step) {
+			b := a.Add(step)
+			if b.After(to) {
+				b = to
+			}
+			u, complete := billing.Span(ps, a, b, 12*time.Hour)
+			if !complete {
+				t.Fatalf("Span(%s, +%s) incomplete", a, step)
+			}
+			sum += u.Raw
+		}
+		if total, _ := billing.Span(ps, from, to, 12*time.Hour); math.Abs(sum-total.Raw) > 1e-9 || math.Abs(total.Raw-50) > 1e-9 {
+			t.Fatalf("periods of %s sum to %v, total %v; want 50", step, sum, total.Raw)
+		}
+	}
+
+	// Without a limit the gap is interpolated across.
+	if u, complete := billing.Span(ps, day(3), day(4), 0); !complete || math.Abs(u.Raw-24) > 1e-9 {
+		t.Fatalf("Span without max gap = %v, %v; want 24, complete", u.Raw, complete)
+	}
+
+	// Marked as a gap, its consumption is counted at the reading after it.
+	for _, r := range rs {
+		if r.ReadAt.Equal(time.Date(2025, 1, 4, 22, 0, 0, 0, time.UTC)) {
+			r.GapBefore = "44h0m0s"
+		}
+	}
+	billing.MarkGaps(ps, rs)
+	for _, tt := range []struct {
+		from, to time.Time
+		raw      float64
+	}{{day(3), day(4), 2}, {day(4), day(5), 46}} {
+		if u, _ := billing.Span(ps, tt.from, tt.to, 0); math.Abs(u.Raw-tt.raw) > 1e-9 {
+			t.Fatalf("Span(%s, %s) with the gap at the end = %v; want %v", tt.from, tt.to, u.Raw, tt.raw)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package bundle packs the evidence of readings into a zip for disputes
+// over a bill: per reading its stored result, the archived image and the
+// entries of the audit log, with a manifest of the SHA-256 of every file and
+// a file with the SHA-256 of the manifest. [Verify] hashes a bundle again,
+// so that an edit after the fact shows. Artifacts that could not be
+// collected are listed in the manifest, with why.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// Names of the files of a bundle.
+const (
+	ManifestName     = "manifest.json"
+	ManifestHashName = "manifest.json.sha256"
+)
+
+// Artifacts of a reading, and the names of their files under the directory
+// of the reading.
+const (
+	ArtifactResult = "result.json"
+	ArtifactImage  = "image.jpg"
+	ArtifactAudit  = "audit.jsonl"
+)
+
+// Version is the version of the [Manifest] layout.
+const Version = 1
+
+// Manifest lists what a bundle holds.
+type Manifest struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	MeterID  string    `json:"meter_id"`
+	Readings []Reading `json:"readings"`
+	Files    []File    `json:"files"`
+	// Missing lists the artifacts that are not in the bundle, and why.
+	Missing []Missing `json:"missing"`
+}
+
+// Reading sums up a reading of the bundle; its files are under its ID.
+type Reading struct {
+	ID            string    `json:"id"`
+	Read          string    `json:"read"`
+	ReadAt        time.Time `json:"read_at"`
+	Model         string    `json:"model,omitempty"`
+	PromptHash    string    `json:"prompt_hash,omitempty"`
+	ReaderVersion string    `json:"reader_version,omitempty"`
+	// ImageKey and ImageAt are of the archived image, if found.
+	ImageKey string    `json:"image_key,omitempty"`
+	ImageAt  time.Time `json:"image_at,omitzero"`
+}
+
+// File is a file of the bundle.
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Missing is an artifact of a reading that is not in the bundle.
+type Missing struct {
+	Reading  string `json:"reading"`
+	Artifact string `json:"artifact"`
+	Reason   string `json:"reason"`
+}
+
+// Writer writes a bundle. The manifest is written by Close.
+type Writer struct {
+	zw *zip.Writer
+	m  Manifest
+}
+
+// NewWriter returns a Writer of a bundle of the readings of meterID to w,
+// created at created.
+func NewWriter(w io.Writer, meterID string, created time.Time) *Writer {
+	return &Writer{zw: zip.NewWriter(w), m: Manifest{Version: Version, Created: created, MeterID: meterID}}
+}
+
+// AddReading adds the summary of a reading to the manifest.
+func (w *Writer) AddReading(r Reading) {
+	w.m.Readings = append(w.m.Readings, r)
+}
+
+// Add writes the file name with data and lists its hash in the manifest.
+func (w *Writer) Add(name string, data []byte) error {
+	if slices.ContainsFunc(w.m.Files, func(f File) bool { return f.Name == name }) || name == ManifestName || name == ManifestHashName {
+		return fmt.Errorf("add %s: duplicate file", name)
+	}
+	if err := w.write(name, data); err != nil {
+		return err
+	}
+	w.m.Files = append(w.m.Files, File{Name: name, Size: int64(len(data)), SHA256: hash(data)})
+	return nil
+}
+
+// Missing lists the artifact of readingID as missing from the bundle for
+// reason.
+func (w *Writer) Missing(readingID, artifact, reason string) {
+	w.m.Missing = append(w.m.Missing, Missing{Reading: readingID, Artifact: artifact, Reason: reason})
+}
+
+// Close writes the manifest and its hash and finishes the zip. It returns
+// the hash of the manifest.
+func (w *Writer) Close() (string, error) {
+	if w.m.Files == nil {
+		w.m.Files = []File{}
+	}
+	if w.m.Missing == nil {
+		w.m.Missing = []Missing{}
+	}
+	b, err := json.MarshalIndent(w.m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	b = append(b, '\n')
+	sum := hash(b)
+	if err := w.write(ManifestName, b); err != nil {
+		return "", err
+	}
+	// As sha256sum writes it, for sha256sum -c.
+	if err := w.write(ManifestHashName, []byte(sum+"  "+ManifestName+"\n")); err != nil {
+		return "", err
+	}
+	if err := w.zw.Close(); err != nil {
+		return "", fmt.Errorf("close bundle: %w", err)
+	}
+	return sum, nil
+}
+
+func (w *Writer) write(name string, data []byte) error {
+	f, err := w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: w.m.Created})
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Report is the outcome of verifying a bundle.
+type Report struct {
+	Manifest Manifest
+	// ManifestHash is the hash of the manifest as found in the bundle.
+	ManifestHash string
+	// Problems are the mismatches found; none if the bundle is as written.
+	Problems []string
+}
+
+// OK reports whether the bundle is as written.
+func (r Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify hashes the bundle in r of size bytes again and reports whether its
+// manifest and files match the hashes written. It fails only for a bundle
+// that cannot be read at all.
+func Verify(r io.ReaderAt, size int64) (Report, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Report{}, fmt.Errorf("open bundle: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var rep Report
+	problem := func(format string, args ...any) {
+		rep.Problems = append(rep.Problems, fmt.Sprintf(format, args...))
+	}
+
+	b, err := readFile(files[ManifestName])
+	if err != nil {
+		return Report{}, fmt.Errorf("read %s: %w", ManifestName, err)
+	}
+	if err := json.Unmarshal(b, &rep.Manifest); err != nil {
+		return Report{}, fmt.Errorf("decode %s: %w", ManifestName, err)
+	}
+	rep.ManifestHash = hash(b)
+	switch want, err := readFile(files[ManifestHashName]); {
+	case err != nil:
+		problem("%s: %v", ManifestHashName, err)
+	case !bytes.HasPrefix(want, []byte(rep.ManifestHash+" ")):
+		problem("%s: hash %s, want %s as in %s", ManifestName, rep.ManifestHash, bytes.TrimSpace(want), ManifestHashName)
+	}
+
+	listed := map[string]bool{ManifestName: true, ManifestHashName: true}
+	for _, mf := range rep.Manifest.Files {
+		listed[mf.Name] = true
+		b, err := readFile(files[mf.Name])
+		switch {
+		case err != nil:
+			problem("%s: %v", mf.Name, err)
+		case hash(b) != mf.SHA256:
+			problem("%s: hash %s, want %s", mf.Name, hash(b), mf.SHA256)
+		case int64(len(b)) != mf.Size:
+			problem("%s: %d bytes, want %d", mf.Name, len(b), mf.Size)
+		}
+	}
+	for _, f := range zr.File {
+		if !listed[f.Name] {
+			problem("%s: not in the manifest", f.Name)
+		}
+	}
+	return rep, nil
+}
+
+var errNotInBundle = errors.New("not in the bundle")
+
+func readFile(f *zip.File) ([]byte, error) {
+	if f == nil {
+		return nil, errNotInBundle
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,139 @@
+package bundle_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/bundle"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+var at = time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC)
+
+// collect bundles r with the image archived and audited 10s before it.
+func collect(t *testing.T, c bundle.Collector, r *genai.GasMeterReadResult) []byte {
+	t.Helper()
+	ctx := context.Background()
+	var b bytes.Buffer
+	w := bundle.NewWriter(&b, "home", at.Add(time.Hour))
+	if err := c.Add(ctx, w, "home", r); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return b.Bytes()
+}
+
+func verify(t *testing.T, b []byte) bundle.Report {
+	t.Helper()
+	rep, err := bundle.Verify(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return rep
+}
+
+// edit returns b with the file name replaced by data.
+func edit(t *testing.T, b []byte, name string, data []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range zr.File {
+		w, _ := zw.Create(f.Name)
+		if f.Name == name {
+			w.Write(data)
+			continue
+		}
+		rc, _ := f.Open()
+		var buf bytes.Buffer
+		buf.ReadFrom(rc)
+		rc.Close()
+		w.Write(buf.Bytes())
+	}
+	zw.Close()
+	return out.Bytes()
+}
+
+func TestBundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	images, err := archive.New(ctx, archive.Config{Backend: archive.BackendDir, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpg := []byte("\xff\xd8 meter \xff\xd9")
+	if err := images.Archive(ctx, images.Key("home", at.Add(-10*time.Second)), jpg); err != nil {
+		t.Fatal(err)
+	}
+	auditPath := filepath.Join(dir, "audit.jsonl")
+	var log bytes.Buffer
+	for _, e := range []genai.AuditEntry{
+		{Time: at.Add(-time.Hour), Call: genai.CallRead, Model: "gemini"}, // of an earlier reading
+		{Time: at.Add(-8 * time.Second), Call: genai.CallRead, Model: "gemini", Response: `{"read":"01234.567"}`},
+	} {
+		b, _ := json.Marshal(e)
+		log.Write(append(b, '\n'))
+	}
+	if err := os.WriteFile(auditPath, log.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := &genai.GasMeterReadResult{ID: "r1", Read: "01234.567", ReadAt: at, Model: "gemini", PromptHash: "abc"}
+
+	b := collect(t, bundle.Collector{Images: images, AuditPath: auditPath}, r)
+	rep := verify(t, b)
+	if !rep.OK() || len(rep.Manifest.Missing) != 0 {
+		t.Fatalf("Verify = %+v, want OK with nothing missing", rep)
+	}
+	var names []string
+	for _, f := range rep.Manifest.Files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, " "); got != "r1/result.json r1/image.jpg r1/audit.jsonl" {
+		t.Fatalf("files %s", got)
+	}
+	if s := rep.Manifest.Readings; len(s) != 1 || s[0].PromptHash != "abc" || !s[0].ImageAt.Equal(at.Add(-10*time.Second)) {
+		t.Fatalf("readings %+v", s)
+	}
+
+	// An edited file, or an edited manifest, shows.
+	if rep := verify(t, edit(t, b, "r1/result.json", []byte(`{"read":"01000.000"}`))); rep.OK() || !strings.HasPrefix(rep.Problems[0], "r1/result.json: hash") {
+		t.Fatalf("Verify of an edited result = %+v", rep.Problems)
+	}
+	edited := rep.Manifest
+	edited.Files = edited.Files[:1]
+	m, _ := json.MarshalIndent(edited, "", "  ")
+	if rep := verify(t, edit(t, b, bundle.ManifestName, m)); len(rep.Problems) != 3 {
+		t.Fatalf("Verify of an edited manifest = %q, want its hash and two unlisted files", rep.Problems)
+	}
+
+	// Without archive and audit log, both are listed missing.
+	rep = verify(t, collect(t, bundle.Collector{}, r))
+	if !rep.OK() || len(rep.Manifest.Files) != 1 || len(rep.Manifest.Missing) != 2 {
+		t.Fatalf("Verify without artifacts = %+v", rep)
+	}
+	for _, m := range rep.Manifest.Missing {
+		if m.Reading != "r1" || !strings.Contains(m.Reason, "not configured") {
+			t.Fatalf("missing %+v", m)
+		}
+	}
+	// A reading whose image is gone from the archive has it listed missing.
+	rep = verify(t, collect(t, bundle.Collector{Images: images, AuditPath: auditPath}, &genai.GasMeterReadResult{ID: "r2", Read: "01234.600", ReadAt: at.Add(time.Hour)}))
+	if len(rep.Manifest.Missing) != 2 || rep.Manifest.Missing[0].Artifact != bundle.ArtifactImage {
+		t.Fatalf("missing %+v, want the image and audit entries", rep.Manifest.Missing)
+	}
+}
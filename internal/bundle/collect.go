@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/audit"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// DefaultWindow is the default of [Collector.Window].
+const DefaultWindow = 5 * time.Minute
+
+// Collector collects the artifacts of readings into a bundle.
+type Collector struct {
+	// Images is the image archive; nil when archiving is off.
+	Images archive.Store
+	// AuditPath is the path of the audit log; empty when it is off.
+	AuditPath string
+	// Window is how long before a reading its image is looked for (default
+	// DefaultWindow). The image of a reading is the last archived in it.
+	Window time.Duration
+}
+
+// Add writes the artifacts of r, a reading of meterID, to w and lists those
+// it cannot find as missing. The audit entries of a reading are those of
+// the calls between its image, or the window, and a minute after it, and
+// those of the image.
+func (c Collector) Add(ctx context.Context, w *Writer, meterID string, r *genai.GasMeterReadResult) error {
+	id := cmp.Or(r.ID, genai.ReadingID(meterID, r))
+	sum := Reading{ID: id, Read: r.Read, ReadAt: r.ReadAt, Model: r.Model, PromptHash: r.PromptHash, ReaderVersion: r.ReaderVersion}
+	result, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode reading %s: %w", id, err)
+	}
+	if err := w.Add(path.Join(id, ArtifactResult), append(result, '\n')); err != nil {
+		return err
+	}
+
+	window := cmp.Or(c.Window, DefaultWindow)
+	from := r.ReadAt.Add(-window)
+	var imageSHA string
+	switch img, jpg, err := c.image(ctx, meterID, r, from); {
+	case err != nil:
+		w.Missing(id, ArtifactImage, err.Error())
+	default:
+		if err := w.Add(path.Join(id, ArtifactImage), jpg); err != nil {
+			return err
+		}
+		sum.ImageKey, sum.ImageAt = img.Key, img.At
+		from, imageSHA = img.At, hash(jpg)
+	}
+
+	switch lines, err := c.audit(from, r.ReadAt.Add(time.Minute), imageSHA); {
+	case err != nil:
+		w.Missing(id, ArtifactAudit, err.Error())
+	case len(lines) == 0:
+		w.Missing(id, ArtifactAudit, fmt.Sprintf("no entries from %s to a minute after the reading; rotated out or not written", from.Format(time.RFC3339)))
+	default:
+		var b bytes.Buffer
+		for _, l := range lines {
+			b.Write(l.Raw)
+			b.WriteByte('\n')
+		}
+		if err := w.Add(path.Join(id, ArtifactAudit), b.Bytes()); err != nil {
+			return err
+		}
+	}
+	w.AddReading(sum)
+	return nil
+}
+
+// image returns the last image of meterID archived from from up to r.
+func (c Collector) image(ctx context.Context, meterID string, r *genai.GasMeterReadResult, from time.Time) (archive.Image, []byte, error) {
+	if c.Images == nil {
+		return archive.Image{}, nil, errors.New("archive not configured")
+	}
+	// Keys keep milliseconds; one more finds the image of the same millisecond.
+	images, err := c.Images.List(ctx, meterID, from, r.ReadAt.Add(time.Millisecond))
+	if err != nil {
+		return archive.Image{}, nil, err
+	}
+	if len(images) == 0 {
+		return archive.Image{}, nil, fmt.Errorf("no image archived from %s to the reading", from.Format(time.RFC3339))
+	}
+	img := images[len(images)-1]
+	jpg, err := c.Images.Fetch(ctx, img.Key)
+	return img, jpg, err
+}
+
+// audit returns the entries of the audit log with from <= Time < to, and
+// those of the image with imageSHA if set.
+func (c Collector) audit(from, to time.Time, imageSHA string) ([]audit.Line, error) {
+	if c.AuditPath == "" {
+		return nil, errors.New("audit log not configured")
+	}
+	between := audit.Between(from, to)
+	return audit.Read(c.AuditPath, func(e genai.AuditEntry) bool {
+		return between(e) || imageSHA != "" && e.ImageSHA256 == imageSHA
+	})
+}
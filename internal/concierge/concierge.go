@@ -10,14 +10,18 @@ import (
 )
 
 type Client struct {
-	addr  string
-	token string
+	addr   string
+	token  string
+	client *http.Client
 }
 
-func NewClient(addr string, token string) *Client {
+// NewClient returns a client of the concierge at addr sending requests
+// through rt; nil is http.DefaultTransport.
+func NewClient(addr string, token string, rt http.RoundTripper) *Client {
 	return &Client{
-		addr:  addr,
-		token: token,
+		addr:   addr,
+		token:  token,
+		client: &http.Client{Transport: rt},
 	}
 }
 
@@ -60,7 +64,7 @@ func (c *Client) PostImage(image io.Reader, mimeType string) (string, error) {
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -74,5 +78,10 @@ func (c *Client) PostImage(image io.Reader, mimeType string) (string, error) {
 		return "", err
 	}
 
-	return c.addr + "/api/v1/luggage/" + result.Key, nil
+	return ImageURL(c.addr, result.Key), nil
+}
+
+// ImageURL returns the URL of the image stored as key at addr.
+func ImageURL(addr, key string) string {
+	return addr + "/api/v1/luggage/" + key
 }
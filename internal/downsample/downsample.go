@@ -0,0 +1,238 @@
+// Package downsample thins out the old history of a meter for long-term
+// storage: readings older than an age are reduced to one an hour, and older
+// still to one a day. The one kept of an hour or a day is its first usable
+// reading, so the consumption between the readings kept, and over whole
+// periods, stays exact; the readings after it are marked
+// [genai.GasMeterReadResult.Downsampled] so that the longer intervals are
+// not taken for gaps. Corrected readings, those entered by hand, those
+// following a gap or a meter exchange and those daily anchors are taken from
+// are never deleted. The events of a [store.EventLog] about the readings
+// deleted are deleted with them.
+package downsample
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// StateKey is the [store.StateStore] key the [Record] is saved under.
+const StateKey = "downsample"
+
+// DefaultEvery is the default of [Config.Every].
+const DefaultEvery = 24 * time.Hour
+
+// chunk is how much of the history is thinned at a time, between the saves
+// of the progress.
+const chunk = 7 * 24 * time.Hour
+
+// Config sets the ages readings are downsampled at.
+type Config struct {
+	// HourlyAfter is the age from which one reading an hour is kept, and
+	// DailyAfter the age from which one a day is; either may be zero.
+	HourlyAfter time.Duration `yaml:"hourly_after"`
+	DailyAfter  time.Duration `yaml:"daily_after"`
+	// Every is how often the daemon runs the job (default 24h).
+	Every time.Duration `yaml:"every"`
+}
+
+// Validate checks c.
+func (c Config) Validate() error {
+	if c.HourlyAfter < 0 || c.DailyAfter < 0 || c.Every < 0 {
+		return errors.New("hourly_after, daily_after and every must not be negative")
+	}
+	if c.HourlyAfter == 0 && c.DailyAfter == 0 {
+		return errors.New("needs hourly_after or daily_after")
+	}
+	for _, d := range []time.Duration{c.HourlyAfter, c.DailyAfter} {
+		if d > 0 && d < 24*time.Hour {
+			return fmt.Errorf("age %s is less than a day", d)
+		}
+	}
+	if c.HourlyAfter > 0 && c.DailyAfter > 0 && c.DailyAfter < c.HourlyAfter {
+		return fmt.Errorf("daily_after %s is before hourly_after %s", c.DailyAfter, c.HourlyAfter)
+	}
+	return nil
+}
+
+// Interval returns Every or its default.
+func (c Config) Interval() time.Duration {
+	return cmp.Or(c.Every, DefaultEvery)
+}
+
+// Record is the downsampling done of a meter, saved in the state of its
+// store.
+type Record struct {
+	// HourlyUntil and DailyUntil are how far the history has been thinned
+	// out to one reading an hour and a day.
+	HourlyUntil time.Time `json:"hourly_until,omitzero"`
+	DailyUntil  time.Time `json:"daily_until,omitzero"`
+	// Deleted counts the readings deleted by all runs.
+	Deleted int       `json:"deleted"`
+	LastRun time.Time `json:"last_run,omitzero"`
+}
+
+// Job downsamples the history of meters in a store.
+type Job struct {
+	s      store.Store
+	th     store.Thinner
+	ss     store.StateStore
+	cfg    Config
+	m      genai.Meter
+	loc    *time.Location
+	anchor *anchor.Config
+}
+
+// New returns a Job thinning out the history of meters laid out as m in s,
+// a store that can be thinned and keeps state, with days in loc. With
+// anchors set, the readings the daily anchors would be taken from are kept
+// besides the saved ones.
+func New(s store.Store, cfg Config, m genai.Meter, loc *time.Location, anchors *anchor.Config) (*Job, error) {
+	th, ok := s.(store.Thinner)
+	if !ok {
+		return nil, fmt.Errorf("store %T cannot be thinned out", s)
+	}
+	ss, ok := s.(store.StateStore)
+	if !ok {
+		return nil, fmt.Errorf("store %T keeps no state for downsampling", s)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Job{s: s, th: th, ss: ss, cfg: cfg, m: m, loc: loc, anchor: anchors}, nil
+}
+
+// Run thins out the history of meterID as of now and returns the record of
+// all runs. The history is thinned a week at a time and the progress saved
+// after each, so that a run cut short resumes where it stopped; running it
+// again deletes nothing more.
+func (j *Job) Run(ctx context.Context, meterID string, now time.Time) (Record, error) {
+	var rec Record
+	if err := j.ss.LoadState(ctx, meterID, StateKey, &rec); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return rec, fmt.Errorf("load downsampling record: %w", err)
+	}
+	saved := make(map[string]anchor.Anchor)
+	if err := j.ss.LoadState(ctx, meterID, anchor.StateKey, &saved); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return rec, fmt.Errorf("load anchors: %w", err)
+	}
+	keep := make(map[string]bool)
+	for _, a := range saved {
+		keep[a.ReadingID], keep[a.Before], keep[a.After] = true, true, true
+	}
+	delete(keep, "")
+
+	for _, p := range []struct {
+		res   time.Duration
+		after time.Duration
+		until *time.Time
+	}{
+		{time.Hour, j.cfg.HourlyAfter, &rec.HourlyUntil},
+		{24 * time.Hour, j.cfg.DailyAfter, &rec.DailyUntil},
+	} {
+		if p.after <= 0 {
+			continue
+		}
+		cutoff := j.bucket(now.Add(-p.after), p.res)
+		from := *p.until
+		if from.IsZero() {
+			first, err := j.first(ctx, meterID, cutoff)
+			if err != nil {
+				return rec, err
+			}
+			if first.IsZero() {
+				continue
+			}
+			from = j.bucket(first, p.res)
+		}
+		for from.Before(cutoff) {
+			to := j.bucket(from.Add(chunk), p.res)
+			if to.After(cutoff) {
+				to = cutoff
+			}
+			n, err := j.thin(ctx, meterID, from, to, p.res, keep)
+			if err != nil {
+				return rec, err
+			}
+			rec.Deleted += n
+			*p.until, from = to, to
+			if err := j.ss.SaveState(ctx, meterID, StateKey, rec); err != nil {
+				return rec, fmt.Errorf("save downsampling record: %w", err)
+			}
+		}
+	}
+	rec.LastRun = now
+	if err := j.ss.SaveState(ctx, meterID, StateKey, rec); err != nil {
+		return rec, fmt.Errorf("save downsampling record: %w", err)
+	}
+	return rec, nil
+}
+
+// first returns the time of the first reading of meterID before cutoff, or
+// the zero time if there is none.
+func (j *Job) first(ctx context.Context, meterID string, cutoff time.Time) (time.Time, error) {
+	rs, err := j.s.ReadingsBetween(ctx, meterID, time.Time{}, cutoff)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load history: %w", err)
+	}
+	if len(rs) == 0 {
+		return time.Time{}, nil
+	}
+	return rs[0].ReadAt, nil
+}
+
+// thin keeps the first usable reading of every period res long in
+// [from, to) of meterID, those that must be kept and those keep has the ID
+// of, and deletes the others.
+func (j *Job) thin(ctx context.Context, meterID string, from, to time.Time, res time.Duration, keep map[string]bool) (int, error) {
+	if j.anchor != nil {
+		// An anchor of a day around the range may be taken from a reading in it.
+		as, err := anchor.Days(ctx, j.s, *j.anchor, j.m, meterID, from.Add(-24*time.Hour), to.Add(24*time.Hour), j.loc)
+		if err != nil {
+			return 0, err
+		}
+		for _, a := range as {
+			keep[a.ReadingID], keep[a.Before], keep[a.After] = true, true, true
+		}
+		delete(keep, "")
+	}
+	n, err := j.th.Thin(ctx, meterID, from, to, func(rs []*genai.GasMeterReadResult) []bool {
+		kept := make([]bool, len(rs))
+		var period time.Time
+		last := -1 // the last usable reading kept
+		for i, r := range rs {
+			_, err := genai.ParseRead(j.m, r.Read)
+			usable := r.Counted() && err == nil
+			if p := j.bucket(r.ReadAt, res); usable && (last < 0 || !p.Equal(period)) {
+				kept[i], period = true, p
+			}
+			kept[i] = kept[i] || r.Correction != nil || r.Provenance == genai.ProvenanceEntered || r.GapBefore != "" || r.MeterExchanged != nil || keep[cmp.Or(r.ID, genai.ReadingID(meterID, r))]
+			switch {
+			case kept[i] && usable:
+				last = i
+			case !kept[i] && usable && last >= 0:
+				rs[last].Downsampled = res.String()
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return 0, fmt.Errorf("downsample %s to %s: %w", from.Format(time.DateOnly), to.Format(time.DateOnly), err)
+	}
+	return n, nil
+}
+
+// bucket returns the start of the period res long t is in: the hour, or
+// the local day.
+func (j *Job) bucket(t time.Time, res time.Duration) time.Time {
+	if res < 24*time.Hour {
+		return t.Truncate(res)
+	}
+	y, m, d := t.In(j.loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, j.loc)
+}
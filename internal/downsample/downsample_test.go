@@ -0,0 +1,280 @@
+package downsample_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/downsample"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var start = time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+
+// historyStore is a store [history] can be saved to.
+type historyStore interface {
+	store.Store
+	store.Corrector
+	store.StateStore
+}
+
+// history saves ten days of readings ten minutes apart, 0.01 m³ each, to
+// s, with a corrected reading and one after a gap on the first day, a stale
+// one on the second and one the saved anchor of the third is taken from.
+func history(t *testing.T, s historyStore) {
+	t.Helper()
+	ctx := context.Background()
+	for i := range 10 * 144 {
+		r := &genai.GasMeterReadResult{Read: fmt.Sprintf("%09.3f", 100+float64(i)*0.01), ReadAt: start.Add(time.Duration(i) * 10 * time.Minute)}
+		switch i {
+		case 30:
+			r.ID = "corrected"
+		case 100:
+			r.GapBefore = "10m0s"
+		case 200:
+			r.Stale = true
+		case 300:
+			r.ID = "anchored"
+		}
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.CorrectReading(ctx, "home", "corrected", "00100.300", "checked"); err != nil {
+		t.Fatal(err)
+	}
+	anchors := map[string]anchor.Anchor{"2025-11-03": {Date: "2025-11-03", ReadingID: "anchored"}}
+	if err := s.SaveState(ctx, "home", anchor.StateKey, anchors); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// days returns the consumption of each day of the history and whether it
+// is complete with gaps of at most three hours.
+func days(t *testing.T, s store.Store) (us []float64, complete bool) {
+	t.Helper()
+	rs, err := s.ReadingsBetween(context.Background(), "home", start, start.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := billing.Tariff{}.Cumulative(genai.DefaultMeter, rs)
+	complete = true
+	for d := range 9 {
+		u, ok := billing.Span(ps, start.AddDate(0, 0, d), start.AddDate(0, 0, d+1), 3*time.Hour)
+		us, complete = append(us, u.Raw), complete && ok
+	}
+	return us, complete
+}
+
+var cfg = downsample.Config{HourlyAfter: 48 * time.Hour, DailyAfter: 96 * time.Hour}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.NewMemory()
+	history(t, s)
+	before, _ := days(t, s)
+	j, err := downsample.New(s, cfg, genai.DefaultMeter, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := start.AddDate(0, 0, 11)
+	rec, err := j.Run(ctx, "home", now)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// One a day for days 0 to 6 and the three kept, one an hour for days 7
+	// and 8, all of day 9.
+	rs, _ := s.ReadingsBetween(ctx, "home", start, now)
+	if len(rs) != 7+3+48+144 || rec.Deleted != 1440-len(rs) {
+		t.Fatalf("%d readings left, %d deleted; want %d", len(rs), rec.Deleted, 7+3+48+144)
+	}
+	if !rec.HourlyUntil.Equal(start.AddDate(0, 0, 9)) || !rec.DailyUntil.Equal(start.AddDate(0, 0, 7)) || !rec.LastRun.Equal(now) {
+		t.Fatalf("Record = %+v", rec)
+	}
+	for _, r := range rs[:4] {
+		if r.ReadAt.Equal(start) && r.Downsampled != "24h0m0s" || r.ID == "corrected" && r.Correction == nil {
+			t.Fatalf("reading %+v", r)
+		}
+	}
+	var ids []string
+	for _, r := range rs[:10] {
+		if r.ID != "" || r.GapBefore != "" {
+			ids = append(ids, r.ID+r.GapBefore)
+		}
+	}
+	if fmt.Sprint(ids) != "[corrected 10m0s anchored]" {
+		t.Fatalf("kept %v; want the corrected, gap and anchor readings", ids)
+	}
+
+	// The consumption of every day is as before, and complete despite the
+	// longer intervals.
+	after, complete := days(t, s)
+	if !complete {
+		t.Fatalf("downsampled days are incomplete: %v", after)
+	}
+	for d := range before {
+		if math.Abs(after[d]-before[d]) > 1e-9 {
+			t.Fatalf("day %d: %v after downsampling, %v before", d, after[d], before[d])
+		}
+	}
+
+	// Running again deletes nothing more.
+	again, err := j.Run(ctx, "home", now)
+	if err != nil || again.Deleted != rec.Deleted {
+		t.Fatalf("second Run = %+v, %v; want %d deleted", again, err, rec.Deleted)
+	}
+	if rs2, _ := s.ReadingsBetween(ctx, "home", start, now); len(rs2) != len(rs) {
+		t.Fatalf("second Run left %d readings, want %d", len(rs2), len(rs))
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	history(t, s)
+	full := fileSize(t, path)
+	j, err := downsample.New(s, cfg, genai.DefaultMeter, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := start.AddDate(0, 0, 11)
+	rec, err := j.Run(ctx, "home", now)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The readings deleted and their events are gone from the file, which
+	// shrinks with them.
+	const left = 7 + 3 + 48 + 144
+	if rec.Deleted != 1440-left {
+		t.Fatalf("%d deleted, want %d", rec.Deleted, 1440-left)
+	}
+	if size := fileSize(t, path); size > full*left/1440*11/10 {
+		t.Fatalf("%d bytes after downsampling %d of 1440 readings to %d, from %d", size, rec.Deleted, left, full)
+	}
+	if s, err = store.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer s.Close()
+	rs, err := s.ReadingsBetween(ctx, "home", start, now)
+	if err != nil || len(rs) != left {
+		t.Fatalf("%d readings after reopening, %v; want %d", len(rs), err, left)
+	}
+	es, err := s.EventsSince(ctx, 0, 0)
+	if err != nil || len(es) != left+1 {
+		t.Fatalf("%d events after reopening, %v; want those of the %d readings left and the correction", len(es), err, left)
+	}
+	kept := map[time.Time]bool{}
+	for _, r := range rs {
+		kept[r.ReadAt] = true
+	}
+	for _, e := range es {
+		if !kept[e.Reading.ReadAt] {
+			t.Fatalf("event %d of a reading downsampled away: %+v", e.Seq, e.Reading)
+		}
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
+
+// flakyStore fails the thinning of the second week.
+type flakyStore struct {
+	*store.Memory
+	calls int
+}
+
+func (f *flakyStore) Thin(ctx context.Context, meterID string, from, to time.Time, keep func(rs []*genai.GasMeterReadResult) []bool) (int, error) {
+	if f.calls++; f.calls == 2 {
+		return 0, errors.New("disk full")
+	}
+	return f.Memory.Thin(ctx, meterID, from, to, keep)
+}
+
+func TestRunResumes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	want, s := store.NewMemory(), &flakyStore{Memory: store.NewMemory()}
+	history(t, want)
+	history(t, s.Memory)
+	now := start.AddDate(0, 0, 11)
+	j, err := downsample.New(want, cfg, genai.DefaultMeter, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := j.Run(ctx, "home", now); err != nil {
+		t.Fatal(err)
+	}
+
+	if j, err = downsample.New(s, cfg, genai.DefaultMeter, time.UTC, nil); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := j.Run(ctx, "home", now); err == nil {
+		t.Fatal("Run succeeded despite the failure")
+	}
+	var rec downsample.Record
+	if err := s.LoadState(ctx, "home", downsample.StateKey, &rec); err != nil || !rec.HourlyUntil.Equal(start.AddDate(0, 0, 7)) || !rec.DailyUntil.IsZero() {
+		t.Fatalf("Record after the failure = %+v, %v; want the first week", rec, err)
+	}
+	if rec, err = j.Run(ctx, "home", now); err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+	got, _ := s.ReadingsBetween(ctx, "home", start, now)
+	exp, _ := want.ReadingsBetween(ctx, "home", start, now)
+	if len(got) != len(exp) || rec.Deleted != 1440-len(exp) {
+		t.Fatalf("resumed: %d readings, %d deleted; want %d as in one run", len(got), rec.Deleted, len(exp))
+	}
+	for i := range got {
+		if got[i].Read != exp[i].Read || got[i].Downsampled != exp[i].Downsampled {
+			t.Fatalf("reading %d = %+v, want %+v", i, got[i], exp[i])
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg     downsample.Config
+		wantErr bool
+	}{
+		{downsample.Config{HourlyAfter: 30 * 24 * time.Hour, DailyAfter: 365 * 24 * time.Hour}, false},
+		{downsample.Config{DailyAfter: 90 * 24 * time.Hour}, false},
+		{downsample.Config{}, true},
+		{downsample.Config{HourlyAfter: time.Hour}, true},
+		{downsample.Config{HourlyAfter: 90 * 24 * time.Hour, DailyAfter: 30 * 24 * time.Hour}, true},
+		{downsample.Config{HourlyAfter: 30 * 24 * time.Hour, Every: -time.Hour}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
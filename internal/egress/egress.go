@@ -0,0 +1,234 @@
+// Package egress configures how the outbound clients of mqvision reach the
+// network: through a proxy, trusting a private CA, with a client
+// certificate and a dial timeout. [Config.Transport] is shared by every
+// HTTP client, from the vision API to the sinks, and [Config.Dialer] and
+// [Config.TLSConfig] by the others, such as SMTP, so that a meter behind an
+// egress proxy needs the settings once.
+package egress
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// defaultDialTimeout is the dial timeout of [Config.Dialer] without
+// DialTimeout, that of [http.DefaultTransport].
+const defaultDialTimeout = 30 * time.Second
+
+// Config is the network configuration of outbound HTTP.
+type Config struct {
+	// Proxy is the URL of the proxy all requests go through, e.g.
+	// "http://proxy.internal:3128" or "socks5://proxy.internal:1080". Empty
+	// follows $HTTPS_PROXY, $HTTP_PROXY and $NO_PROXY.
+	Proxy string `yaml:"proxy"`
+	// CAFile is a PEM bundle of CAs trusted besides the system's, e.g. the
+	// CA of a TLS-intercepting proxy.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the PEM client certificate, and its key,
+	// presented to servers that ask for one.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// DialTimeout bounds connecting to a server or the proxy (default 30s).
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+}
+
+// Validate checks c without loading its files.
+func (c Config) Validate() error {
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			return fmt.Errorf("proxy: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("proxy %q is not http, https or socks5", c.Proxy)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("proxy %q has no host", c.Proxy)
+		}
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("needs both cert_file and key_file")
+	}
+	if c.DialTimeout < 0 {
+		return errors.New("negative dial_timeout")
+	}
+	return nil
+}
+
+// Transport returns a transport as [http.DefaultTransport] with the
+// settings of c, loading its files. A zero c returns nil, which clients
+// take as the default transport.
+func (c Config) Transport() (http.RoundTripper, error) {
+	if c == (Config{}) {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if c.DialTimeout > 0 {
+		t.DialContext = (&net.Dialer{Timeout: c.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if c.CAFile != "" || c.CertFile != "" {
+		cfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = cfg
+	}
+	return t, nil
+}
+
+// Dialer returns the dial function of the outbound connections that are not
+// HTTP, such as SMTP and Kafka: through Proxy, if set, within DialTimeout.
+// An http or https proxy is asked to CONNECT to the address. Unlike
+// [Config.Transport], it does not follow the proxy environment variables,
+// which are meant for HTTP.
+func (c Config) Dialer() (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: cmp.Or(c.DialTimeout, defaultDialTimeout), KeepAlive: 30 * time.Second}
+	if c.Proxy == "" {
+		return d.DialContext, nil
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	if u.Scheme == "socks5" || u.Scheme == "socks5h" {
+		pd, err := proxy.FromURL(u, d)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		return pd.(proxy.ContextDialer).DialContext, nil
+	}
+	var tlsCfg *tls.Config
+	if u.Scheme == "https" {
+		if tlsCfg, err = c.tlsConfig(); err != nil {
+			return nil, err
+		}
+		tlsCfg.ServerName = u.Hostname()
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialConnect(ctx, d, u, tlsCfg, addr)
+	}, nil
+}
+
+// dialConnect connects to addr through a tunnel of the HTTP proxy at u,
+// with TLS of tlsCfg to the proxy if not nil.
+func dialConnect(ctx context.Context, d *net.Dialer, u *url.URL, tlsCfg *tls.Config, addr string) (net.Conn, error) {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy: %w", err)
+	}
+	if tlsCfg != nil {
+		tc := tls.Client(conn, tlsCfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("dial proxy: %w", err)
+		}
+		conn = tc
+	}
+	// The tunnel is set up within the dial timeout too.
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if u.User != nil {
+		pw, _ := u.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+pw)))
+	}
+	br := bufio.NewReader(conn)
+	err = req.Write(conn)
+	var resp *http.Response
+	if err == nil {
+		resp, err = http.ReadResponse(br, req)
+	}
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("proxy answered %s", resp.Status)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connect to %s through the proxy: %w", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		// The server spoke first, e.g. the greeting of SMTP.
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a conn of which r has read ahead.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// TLSConfig returns the TLS configuration of the CA bundle and client
+// certificate of c, loading its files, for the clients that are not HTTP;
+// nil without either.
+func (c Config) TLSConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" {
+		return nil, nil
+	}
+	return c.tlsConfig()
+}
+
+// tlsConfig returns the TLS configuration of c.
+func (c Config) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		if cfg.RootCAs, err = x509.SystemCertPool(); err != nil {
+			cfg.RootCAs = x509.NewCertPool()
+		}
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,208 @@
+package egress_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/egress"
+	"github.com/suapapa/mqvision/internal/egress/egresstest"
+)
+
+// writePEM writes der as a PEM block of typ to a temporary file name and
+// returns its path.
+func writePEM(t *testing.T, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) (*http.Response, error) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: rt, Timeout: 10 * time.Second}).Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestTransportCA(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	if _, err := get(t, http.DefaultTransport, srv.URL); err == nil {
+		t.Fatal("the self-signed server was trusted without its CA")
+	}
+	rt, err := egress.Config{CAFile: writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)}.Transport()
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if resp, err := get(t, rt, srv.URL); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET with the CA: %v, %v", resp, err)
+	}
+
+	if rt, err := (egress.Config{}).Transport(); rt != nil || err != nil {
+		t.Fatalf("Transport of no settings = %v, %v; want the default", rt, err)
+	}
+	if _, err := (egress.Config{CAFile: writePEM(t, "empty.pem", "NOTHING", nil)}).Transport(); err == nil {
+		t.Fatal("Transport of a bundle without certificates succeeded")
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	t.Parallel()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+	rt, err := egress.Config{Proxy: proxy.URL, DialTimeout: time.Second}.Transport()
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if resp, err := get(t, rt, "http://meter.invalid/reading"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET through the proxy: %v, %v", resp, err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://meter.invalid/reading" {
+		t.Fatalf("proxied %q", proxied)
+	}
+}
+
+func TestTransportClientCert(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) != 1 {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	ca := writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	cfg := egress.Config{CAFile: ca, CertFile: writePEM(t, "client.pem", "CERTIFICATE", der), KeyFile: writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)}
+	rt, err := cfg.Transport()
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if resp, err := get(t, rt, srv.URL); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET with the client certificate: %v, %v", resp, err)
+	}
+	rt, err = egress.Config{CAFile: ca}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, rt, srv.URL); err == nil {
+		t.Fatal("GET without a client certificate succeeded")
+	}
+}
+
+func TestDialer(t *testing.T) {
+	t.Parallel()
+
+	// The server speaks first, as SMTP does.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "220 ready\r\n")
+			io.Copy(conn, conn)
+			conn.Close()
+		}
+	}()
+	proxy := egresstest.NewProxy()
+	defer proxy.Close()
+
+	for _, cfg := range []egress.Config{{}, {Proxy: proxy.URL, DialTimeout: time.Second}} {
+		dial, err := cfg.Dialer()
+		if err != nil {
+			t.Fatalf("Dialer(%+v): %v", cfg, err)
+		}
+		conn, err := dial(context.Background(), "tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial with %+v: %v", cfg, err)
+		}
+		r := bufio.NewReader(conn)
+		greeting, _ := r.ReadString('\n')
+		io.WriteString(conn, "EHLO pi\r\n")
+		echo, _ := r.ReadString('\n')
+		conn.Close()
+		if greeting != "220 ready\r\n" || echo != "EHLO pi\r\n" {
+			t.Fatalf("with %+v read %q and %q", cfg, greeting, echo)
+		}
+	}
+	if got := proxy.Tunnels(); len(got) != 1 || got[0] != ln.Addr().String() {
+		t.Fatalf("tunnels %q, want one to %s", got, ln.Addr())
+	}
+
+	dial, err := egress.Config{Proxy: proxy.URL}.Dialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial(context.Background(), "tcp", "127.0.0.1:1"); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("dial of a closed port through the proxy = %v, want the proxy's 502", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		cfg  egress.Config
+		want string
+	}{
+		{egress.Config{Proxy: "http://proxy.internal:3128", DialTimeout: time.Second}, ""},
+		{egress.Config{Proxy: "socks5://proxy.internal:1080"}, ""},
+		{egress.Config{Proxy: "ftp://proxy.internal"}, "not http"},
+		{egress.Config{Proxy: "http://"}, "no host"},
+		{egress.Config{CertFile: "client.pem"}, "both cert_file and key_file"},
+		{egress.Config{DialTimeout: -time.Second}, "negative"},
+	} {
+		err := tt.cfg.Validate()
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
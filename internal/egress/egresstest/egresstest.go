@@ -0,0 +1,61 @@
+// Package egresstest has an HTTP CONNECT proxy for tests of clients that go
+// through [egress.Config.Proxy].
+package egresstest
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Proxy is an HTTP proxy that only tunnels, as for HTTPS, and records the
+// addresses it tunnels to.
+type Proxy struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	tunnels []string
+}
+
+// NewProxy starts a Proxy; Close stops it.
+func NewProxy() *Proxy {
+	p := &Proxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serve))
+	return p
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "tunnels only", http.StatusMethodNotAllowed)
+		return
+	}
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	p.mu.Lock()
+	p.tunnels = append(p.tunnels, r.Host)
+	p.mu.Unlock()
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+// Tunnels returns the addresses tunneled to so far, in order.
+func (p *Proxy) Tunnels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.tunnels...)
+}
@@ -0,0 +1,195 @@
+// Package event routes what happens to a meter to the sinks and notifiers
+// subscribed to it. Every event has a [Type]; each sink and notifier
+// subscribes to types, and optionally meters, in the config file.
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+// Type is the kind of an event.
+type Type string
+
+const (
+	ReadingAccepted Type = "reading_accepted" // a reading passed the validators
+	ReadingWarning  Type = "reading_warning"  // a reading was accepted with warnings or guessed digits
+	ReadingRejected Type = "reading_rejected" // the validators rejected a reading
+	ReadFailed      Type = "read_failed"      // an image could not be read, e.g. of another meter
+	DaemonStarted   Type = "daemon_started"
+	AnomalyDetected Type = "anomaly_detected" // unusual consumption or a possible leak
+	Correction      Type = "correction"       // a stored reading was corrected
+	Digest          Type = "digest"           // the usage report of a period
+	Gap             Type = "gap"              // a reading came long after the previous one
+	SourceDown      Type = "source_down"      // the primary camera has been failing for a while
+	SelfTestFailed  Type = "self_test_failed" // the reference image was misread or could not be read
+)
+
+// Types are all event types.
+var Types = []Type{ReadingAccepted, ReadingWarning, ReadingRejected, ReadFailed, DaemonStarted, AnomalyDetected, Correction, Digest, Gap, SourceDown, SelfTestFailed}
+
+// SinkTypes are the types sinks can subscribe to: those of readings to
+// deliver.
+var SinkTypes = []Type{ReadingAccepted, ReadingWarning, Correction}
+
+// Default subscriptions: sinks take accepted readings and their corrections,
+// notifiers failures, anomalies, gaps, failing cameras and self-tests, and
+// the digest if one is configured.
+var (
+	DefaultSinkEvents     = []Type{ReadingAccepted, Correction}
+	DefaultNotifierEvents = []Type{ReadingRejected, ReadFailed, AnomalyDetected, Digest, Gap, SourceDown, SelfTestFailed}
+)
+
+// Alerts are the types of the events that something is wrong, which
+// [Dispatcher.Suppress] may hold back from notifiers.
+var Alerts = []Type{ReadingWarning, ReadingRejected, ReadFailed, AnomalyDetected, Gap, SourceDown}
+
+// Event is something that happened to a meter. The embedded notification is
+// what notifiers are sent.
+type Event struct {
+	Type Type
+	notify.Event
+	// Reading is the reading sinks are sent, if any, with the Consumption it
+	// adds or, corrected, changes.
+	Reading     *genai.GasMeterReadResult
+	Consumption []sink.Consumption
+	// Correction is what changed in a Correction event; without it, it is
+	// derived from Reading.
+	Correction *sink.CorrectionEvent
+}
+
+// Subscription selects the events a sink or notifier receives.
+type Subscription struct {
+	Events []Type `yaml:"events"` // default depends on the receiver
+	// Meters are the meter IDs to receive events of (default: all).
+	Meters []string `yaml:"meters"`
+}
+
+// Validate checks the event types; a sink may only subscribe to [SinkTypes].
+func (s Subscription) Validate(forSink bool) error {
+	allowed := Types
+	if forSink {
+		allowed = SinkTypes
+	}
+	for _, t := range s.Events {
+		if !slices.Contains(allowed, t) {
+			return fmt.Errorf("unknown event %q, want one of %s", t, join(allowed))
+		}
+	}
+	return nil
+}
+
+// Matches reports whether e is subscribed to.
+func (s Subscription) Matches(e Event) bool {
+	return slices.Contains(s.Events, e.Type) && (len(s.Meters) == 0 || slices.Contains(s.Meters, e.MeterID))
+}
+
+// OrEvents returns s subscribed to events, unless it lists its own.
+func (s Subscription) OrEvents(events []Type) Subscription {
+	if len(s.Events) == 0 {
+		s.Events = events
+	}
+	return s
+}
+
+func join(types []Type) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Dispatcher fans events out to the sinks and notifiers subscribed to them.
+// Add them before dispatching; Dispatch may then be called concurrently.
+type Dispatcher struct {
+	sinks     []subscribed[sink.Sink]
+	notifiers []subscribed[notify.Notifier]
+	// Suppress, if set, is called with every [Alerts] event; the notifiers
+	// are not sent those it returns true for, e.g. of a meter in
+	// maintenance. Sinks are sent their readings regardless.
+	Suppress func(e Event) bool
+}
+
+type subscribed[T any] struct {
+	to  T
+	sub Subscription
+}
+
+// AddSink subscribes s, to [DefaultSinkEvents] unless sub lists events.
+func (d *Dispatcher) AddSink(s sink.Sink, sub Subscription) {
+	d.sinks = append(d.sinks, subscribed[sink.Sink]{s, sub.OrEvents(DefaultSinkEvents)})
+}
+
+// AddNotifier subscribes n, to [DefaultNotifierEvents] unless sub lists
+// events.
+func (d *Dispatcher) AddNotifier(n notify.Notifier, sub Subscription) {
+	d.notifiers = append(d.notifiers, subscribed[notify.Notifier]{n, sub.OrEvents(DefaultNotifierEvents)})
+}
+
+// Dispatch delivers e to its subscribers and returns their joined errors.
+// Sinks are sent the reading of e, if it has one: a correction to those
+// taking corrections, and its consumption to those taking consumption.
+func (d *Dispatcher) Dispatch(ctx context.Context, e Event) error {
+	var errs []error
+	if e.Reading != nil && slices.Contains(SinkTypes, e.Type) {
+		for _, s := range d.sinks {
+			if s.sub.Matches(e) {
+				errs = append(errs, publish(ctx, s.to, e)...)
+			}
+		}
+	}
+	if d.Suppress != nil && slices.Contains(Alerts, e.Type) && d.Suppress(e) {
+		return errors.Join(errs...)
+	}
+	for _, n := range d.notifiers {
+		if !n.sub.Matches(e) {
+			continue
+		}
+		if err := n.to.Notify(ctx, e.Event); err != nil {
+			errs = append(errs, fmt.Errorf("notify %s: %w", e.Kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func publish(ctx context.Context, s sink.Sink, e Event) []error {
+	var errs []error
+	if e.Type != Correction {
+		if err := s.Publish(ctx, e.MeterID, e.Reading); err != nil {
+			errs = append(errs, fmt.Errorf("publish reading: %w", err))
+		}
+	} else if cs, ok := s.(sink.CorrectionSink); ok {
+		c := sink.NewCorrectionEvent(e.MeterID, "", e.Reading)
+		if e.Correction != nil {
+			c = *e.Correction
+		}
+		if err := cs.PublishCorrection(ctx, c); err != nil {
+			errs = append(errs, fmt.Errorf("publish correction: %w", err))
+		}
+	}
+	if cs, ok := s.(sink.ConsumptionSink); ok {
+		for _, c := range e.Consumption {
+			if err := cs.PublishConsumption(ctx, c); err != nil {
+				errs = append(errs, fmt.Errorf("publish consumption: %w", err))
+			}
+		}
+	}
+	return errs
+}
+
+// Close closes the sinks.
+func (d *Dispatcher) Close() error {
+	var errs []error
+	for _, s := range d.sinks {
+		errs = append(errs, s.to.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,171 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+// recorder is a sink taking corrections and consumption, recording what it
+// is sent.
+type recorder struct {
+	got []string
+	err error
+}
+
+func (r *recorder) Publish(_ context.Context, meterID string, g *genai.GasMeterReadResult) error {
+	r.got = append(r.got, "reading:"+meterID+":"+g.Read)
+	return r.err
+}
+
+func (r *recorder) PublishCorrection(_ context.Context, c sink.CorrectionEvent) error {
+	r.got = append(r.got, "correction:"+c.MeterID+":"+c.New)
+	return nil
+}
+
+func (r *recorder) PublishConsumption(_ context.Context, c sink.Consumption) error {
+	r.got = append(r.got, "consumption:"+c.MeterID)
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func (r *recorder) Notify(_ context.Context, e notify.Event) error {
+	r.got = append(r.got, "notify:"+e.MeterID+":"+e.Kind)
+	return r.err
+}
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reading := func(typ event.Type, meterID, kind string) event.Event {
+		return event.Event{
+			Type:        typ,
+			Event:       notify.Event{Kind: kind, MeterID: meterID},
+			Reading:     &genai.GasMeterReadResult{Read: "02924.457"},
+			Consumption: []sink.Consumption{{MeterID: meterID}},
+		}
+	}
+	notice := func(typ event.Type, meterID, kind string) event.Event {
+		return event.Event{Type: typ, Event: notify.Event{Kind: kind, MeterID: meterID}}
+	}
+	stream := []event.Event{
+		reading(event.ReadingAccepted, "home", "reading"),
+		reading(event.ReadingWarning, "home", "validation"),
+		notice(event.ReadingWarning, "home", "ambiguous"),
+		notice(event.ReadFailed, "home", "wrong_meter"),
+		notice(event.AnomalyDetected, "cabin", "leak"),
+		reading(event.Correction, "cabin", "correction"),
+		notice(event.DaemonStarted, "home", "started"),
+		notice(event.Digest, "home", "digest"),
+	}
+
+	tests := []struct {
+		name string
+		sink bool
+		sub  event.Subscription
+		want []string
+	}{
+		{"sink defaults", true, event.Subscription{}, []string{
+			"reading:home:02924.457", "consumption:home",
+			"correction:cabin:02924.457", "consumption:cabin",
+		}},
+		{"sink with warnings", true, event.Subscription{Events: []event.Type{event.ReadingWarning}}, []string{
+			"reading:home:02924.457", "consumption:home",
+		}},
+		{"sink of a meter", true, event.Subscription{Meters: []string{"cabin"}}, []string{
+			"correction:cabin:02924.457", "consumption:cabin",
+		}},
+		{"notifier defaults", false, event.Subscription{}, []string{
+			"notify:home:wrong_meter", "notify:cabin:leak", "notify:home:digest",
+		}},
+		{"notifier of everything at home", false, event.Subscription{Events: event.Types, Meters: []string{"home"}}, []string{
+			"notify:home:reading", "notify:home:validation", "notify:home:ambiguous", "notify:home:wrong_meter",
+			"notify:home:started", "notify:home:digest",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := &recorder{}
+			d := &event.Dispatcher{}
+			if tt.sink {
+				d.AddSink(rec, tt.sub)
+			} else {
+				d.AddNotifier(rec, tt.sub)
+			}
+			for _, e := range stream {
+				if err := d.Dispatch(ctx, e); err != nil {
+					t.Fatalf("Dispatch(%s): %v", e.Type, err)
+				}
+			}
+			if !slices.Equal(rec.got, tt.want) {
+				t.Fatalf("got %q, want %q", rec.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchErrors(t *testing.T) {
+	t.Parallel()
+
+	errDown := errors.New("down")
+	failing, ok := &recorder{err: errDown}, &recorder{}
+	d := &event.Dispatcher{}
+	d.AddSink(failing, event.Subscription{})
+	d.AddNotifier(failing, event.Subscription{Events: []event.Type{event.ReadingAccepted}})
+	d.AddSink(ok, event.Subscription{})
+	err := d.Dispatch(context.Background(), event.Event{Type: event.ReadingAccepted, Reading: &genai.GasMeterReadResult{}})
+	if !errors.Is(err, errDown) || len(ok.got) != 1 || len(failing.got) != 2 {
+		t.Fatalf("Dispatch = %v, delivered %q and %q; want every receiver called and the errors returned", err, failing.got, ok.got)
+	}
+}
+
+func TestDispatchSuppress(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	d := &event.Dispatcher{Suppress: func(e event.Event) bool { return e.MeterID == "home" }}
+	d.AddSink(rec, event.Subscription{Events: []event.Type{event.ReadingWarning}})
+	d.AddNotifier(rec, event.Subscription{Events: event.Types})
+	for _, e := range []event.Event{
+		{Type: event.ReadingWarning, Event: notify.Event{Kind: "validation", MeterID: "home"}, Reading: &genai.GasMeterReadResult{Read: "00000.100"}},
+		{Type: event.AnomalyDetected, Event: notify.Event{Kind: "leak", MeterID: "home"}},
+		{Type: event.AnomalyDetected, Event: notify.Event{Kind: "leak", MeterID: "cabin"}},
+		{Type: event.Digest, Event: notify.Event{Kind: "digest", MeterID: "home"}},
+	} {
+		if err := d.Dispatch(context.Background(), e); err != nil {
+			t.Fatalf("Dispatch(%s): %v", e.Type, err)
+		}
+	}
+	want := []string{"reading:home:00000.100", "notify:cabin:leak", "notify:home:digest"}
+	if !slices.Equal(rec.got, want) {
+		t.Fatalf("got %q, want %q: the alerts of home held back from the notifiers only", rec.got, want)
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		sub     event.Subscription
+		forSink bool
+		ok      bool
+	}{
+		{event.Subscription{}, true, true},
+		{event.Subscription{Events: event.Types}, false, true},
+		{event.Subscription{Events: []event.Type{event.AnomalyDetected}}, true, false},
+		{event.Subscription{Events: []event.Type{"reading"}}, false, false},
+	} {
+		if err := tt.sub.Validate(tt.forSink); (err == nil) != tt.ok {
+			t.Fatalf("%+v.Validate(%t) = %v, want ok %t", tt.sub, tt.forSink, err, tt.ok)
+		}
+	}
+}
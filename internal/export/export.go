@@ -0,0 +1,83 @@
+// Package export pushes the consumption history of a meter to long-term
+// statistics elsewhere, such as Home Assistant's, an hour at a time.
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/series"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// StateKeyPrefix prefixes the name of a target to make the
+// [store.StateStore] key its [Cursor] is saved under.
+const StateKeyPrefix = "export."
+
+// historyStart is where a full export starts walking the history.
+var historyStart = time.Unix(0, 0)
+
+// Row is an hour of the statistics.
+type Row struct {
+	Start time.Time // the start of the hour, in UTC
+	State float64   // the meter value at the end of the hour
+	// Sum is the consumption from the first exported reading to the end of
+	// the hour.
+	Sum float64
+}
+
+// Cursor is where an export left off. The hour of the last reading is sent
+// again by the next export, as later readings may add to it, so a re-run
+// sends the same rows and the target, keyed by hour, ends up the same.
+type Cursor struct {
+	// Hour is the start of the hour of the last reading exported.
+	Hour time.Time `json:"hour"`
+	// Sum is the Sum of the rows up to the start of Hour.
+	Sum float64 `json:"sum"`
+	// From is the time of the last reading before Hour, from which the next
+	// export walks the history to find the increase up to the first reading
+	// in Hour.
+	From time.Time `json:"from"`
+}
+
+// Target takes the rows of a meter; the rows of an hour it has are replaced.
+type Target interface {
+	Name() string
+	Export(ctx context.Context, rows []Row) error
+}
+
+// Hourly returns the rows of the history of meterID in s from cur up to to,
+// and the cursor to continue from; the zero Cursor is a full export. The
+// consumption follows the rules of [series.Walk], with gaps attributed as
+// gaps ([billing.GapSpread] by default).
+func Hourly(ctx context.Context, s store.Store, m genai.Meter, meterID, gaps string, cur Cursor, to time.Time) ([]Row, Cursor, error) {
+	from := cur.From
+	if from.IsZero() {
+		from = historyStart
+	}
+	if !from.Before(to) {
+		return nil, cur, nil
+	}
+	q := series.Query{From: from, To: to, Agg: series.AggHourly, Location: time.UTC, Gaps: gaps}
+	var rows []Row
+	next := cur
+	last := cur.From // the last reading before the bucket at hand
+	sum := cur.Sum
+	err := series.Walk(ctx, s, m, meterID, q, func(b series.Bucket) error {
+		if !b.At.Before(cur.Hour) {
+			// Rows before Hour were sent with the same values already.
+			next = Cursor{Hour: b.At, Sum: sum, From: last}
+			sum += b.Consumption
+			rows = append(rows, Row{Start: b.At, State: b.Value, Sum: sum})
+		}
+		if !b.LastAt.IsZero() {
+			last = b.LastAt
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, cur, err
+	}
+	return rows, next, nil
+}
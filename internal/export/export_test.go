@@ -0,0 +1,102 @@
+package export_test
+
+import (
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/export"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var meter = genai.Meter{ID: "home", Utility: genai.UtilityGas, IntDigits: 3, FracDigits: 1, Unit: "m³"}
+
+type reading struct {
+	at   time.Duration // after start
+	read string
+	gap  string
+}
+
+var start = time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+
+func save(t *testing.T, s store.Store, rs ...reading) {
+	t.Helper()
+	for _, r := range rs {
+		res := &genai.GasMeterReadResult{Read: r.read, ReadAt: start.Add(r.at), GapBefore: r.gap}
+		if err := s.Save(context.Background(), "home", res); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+func hourly(t *testing.T, s store.Store, cur export.Cursor) ([]export.Row, export.Cursor) {
+	t.Helper()
+	rows, next, err := export.Hourly(context.Background(), s, meter, "home", "", cur, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Hourly: %v", err)
+	}
+	return rows, next
+}
+
+func TestHourly(t *testing.T) {
+	t.Parallel()
+
+	before := []reading{
+		{0, "100.0", ""},
+		{20 * time.Minute, "100.5", ""},
+		{70 * time.Minute, "101.0", ""},
+		{80 * time.Minute, "090.0", ""}, // a misread
+		{100 * time.Minute, "101.5", ""},
+	}
+	// Down from 1:40 to 5:40: the increase is spread over the gap.
+	after := []reading{
+		{110 * time.Minute, "102.0", ""},
+		{340 * time.Minute, "106.0", "3h50m0s"},
+		{350 * time.Minute, "106.5", ""},
+	}
+	s := store.NewMemory()
+	save(t, s, before...)
+	rows, cur := hourly(t, s, export.Cursor{})
+	want := []export.Row{{start, 100.5, 0.5}, {start.Add(time.Hour), 101.5, 1.5}}
+	if !equal(rows, want) {
+		t.Fatalf("first export = %v; want %v", rows, want)
+	}
+	if !cur.Hour.Equal(start.Add(time.Hour)) || cur.Sum != 0.5 || !cur.From.Equal(start.Add(20*time.Minute)) {
+		t.Fatalf("cursor = %+v", cur)
+	}
+
+	// A re-run sends the last hour again, the same.
+	again, same := hourly(t, s, cur)
+	if !equal(again, want[1:]) || same != cur {
+		t.Fatalf("re-run = %v, %+v; want %v, %+v", again, same, want[1:], cur)
+	}
+
+	// Incrementally, the rows end up as those of a full export.
+	save(t, s, after...)
+	got := map[time.Time]export.Row{}
+	for _, r := range rows {
+		got[r.Start] = r
+	}
+	more, cur := hourly(t, s, cur)
+	for _, r := range more {
+		got[r.Start] = r
+	}
+	full, fullCur := hourly(t, s, export.Cursor{})
+	incremental := slices.SortedFunc(maps.Values(got), func(a, b export.Row) int { return a.Start.Compare(b.Start) })
+	if !equal(incremental, full) || cur != fullCur {
+		t.Fatalf("incremental export = %v, %+v; full = %v, %+v", incremental, cur, full, fullCur)
+	}
+	if last := full[len(full)-1]; len(full) != 6 || !last.Start.Equal(start.Add(5*time.Hour)) || last.Sum != 6.5 {
+		t.Fatalf("full export = %v; want 6 hours up to a sum of 6.5", full)
+	}
+}
+
+func equal(a, b []export.Row) bool {
+	return slices.EqualFunc(a, b, func(a, b export.Row) bool {
+		return a.Start.Equal(b.Start) && math.Abs(a.State-b.State) < 1e-9 && math.Abs(a.Sum-b.Sum) < 1e-9
+	})
+}
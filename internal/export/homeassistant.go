@@ -0,0 +1,231 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// HomeAssistantName is the name of the Home Assistant target.
+const HomeAssistantName = "homeassistant"
+
+// homeAssistantBatch is how many rows go in one import message.
+const homeAssistantBatch = 1000
+
+// statisticID is the form of an external statistic ID, "<source>:<name>".
+var statisticID = regexp.MustCompile(`^[a-z0-9_]+:[a-z0-9_]+$`)
+
+// HomeAssistantConfig is the Home Assistant instance an export writes to.
+type HomeAssistantConfig struct {
+	// URL is the instance, e.g. "http://homeassistant.local:8123".
+	URL string `yaml:"url"`
+	// Token is a long-lived access token of a user of the instance, made on
+	// its profile page.
+	Token string `yaml:"token"`
+	// StatisticID is the ID of the statistic, "mqvision:<name>" (default
+	// "mqvision:" and the meter ID, lowercased with other characters than
+	// letters, digits and underscores replaced by underscores).
+	StatisticID string `yaml:"statistic_id"`
+	// Name is how the statistic is shown (default the meter ID).
+	Name string `yaml:"name"`
+	// Unit is the unit of the statistic (default the meter's), one Home
+	// Assistant knows for gas or water such as "m³" or "ft³".
+	Unit string `yaml:"unit"`
+	// Timeout bounds the export (default 1m).
+	Timeout time.Duration `yaml:"timeout"`
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper `yaml:"-"`
+}
+
+// Validate checks that the instance and token are set.
+func (c HomeAssistantConfig) Validate() error {
+	if c.URL == "" || c.Token == "" {
+		return errors.New("needs url and token")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url: scheme %q, want http or https", u.Scheme)
+	}
+	if c.StatisticID != "" && (!statisticID.MatchString(c.StatisticID) || !strings.HasPrefix(c.StatisticID, "mqvision:")) {
+		return fmt.Errorf("statistic_id %q is not of the form mqvision:<name> in lowercase letters, digits and underscores", c.StatisticID)
+	}
+	return nil
+}
+
+// HomeAssistant imports the rows into the long-term statistics of Home
+// Assistant as an external statistic with a sum, which the energy dashboard
+// takes as gas or water consumption. Home Assistant takes statistics over its
+// WebSocket API only, the recorder/import_statistics command, which replaces
+// the rows of the hours it already has.
+type HomeAssistant struct {
+	cfg  HomeAssistantConfig
+	meta HomeAssistantMetadata
+}
+
+// HomeAssistantMetadata describes the statistic.
+type HomeAssistantMetadata struct {
+	StatisticID string `json:"statistic_id"`
+	Source      string `json:"source"`
+	Name        string `json:"name"`
+	Unit        string `json:"unit_of_measurement"`
+	HasMean     bool   `json:"has_mean"`
+	HasSum      bool   `json:"has_sum"`
+}
+
+// NewHomeAssistant returns a target writing the statistic of m to cfg's
+// instance.
+func NewHomeAssistant(cfg HomeAssistantConfig, m genai.Meter) *HomeAssistant {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	meta := HomeAssistantMetadata{StatisticID: cfg.StatisticID, Source: "mqvision", Name: cfg.Name, Unit: cfg.Unit, HasSum: true}
+	if meta.StatisticID == "" {
+		meta.StatisticID = "mqvision:" + slug(m.ID)
+	}
+	if meta.Name == "" {
+		meta.Name = m.ID
+	}
+	if meta.Unit == "" {
+		meta.Unit = m.Unit
+	}
+	return &HomeAssistant{cfg: cfg, meta: meta}
+}
+
+// slug turns id into the name of a statistic ID.
+func slug(id string) string {
+	b := []byte(strings.ToLower(id))
+	for i, c := range b {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "meter"
+	}
+	return string(b)
+}
+
+// Name implements [Target].
+func (h *HomeAssistant) Name() string { return HomeAssistantName }
+
+// Metadata returns the metadata the rows are imported with.
+func (h *HomeAssistant) Metadata() HomeAssistantMetadata { return h.meta }
+
+// homeAssistantRow is a [Row] as recorder/import_statistics takes it.
+type homeAssistantRow struct {
+	Start string  `json:"start"`
+	State float64 `json:"state"`
+	Sum   float64 `json:"sum"`
+}
+
+// homeAssistantMessage is a message of the WebSocket API, either way.
+type homeAssistantMessage struct {
+	ID          int                    `json:"id,omitempty"`
+	Type        string                 `json:"type"`
+	AccessToken string                 `json:"access_token,omitempty"`
+	Metadata    *HomeAssistantMetadata `json:"metadata,omitempty"`
+	Stats       []homeAssistantRow     `json:"stats,omitempty"`
+	Success     bool                   `json:"success,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Error       *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Export implements [Target].
+func (h *HomeAssistant) Export(ctx context.Context, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+	u, err := url.Parse(h.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("homeassistant url: %w", err)
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/websocket"
+	conn, _, err := websocket.Dial(ctx, u.String(), &websocket.DialOptions{HTTPClient: &http.Client{Transport: h.cfg.Transport}})
+	if err != nil {
+		return fmt.Errorf("connect to homeassistant: %w", err)
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(1 << 20) // the auth messages carry the version
+
+	if err := h.auth(ctx, conn); err != nil {
+		return err
+	}
+	for i, id := 0, 1; i < len(rows); i, id = i+homeAssistantBatch, id+1 {
+		batch := rows[i:min(i+homeAssistantBatch, len(rows))]
+		msg := homeAssistantMessage{ID: id, Type: "recorder/import_statistics", Metadata: &h.meta}
+		for _, r := range batch {
+			msg.Stats = append(msg.Stats, homeAssistantRow{Start: r.Start.UTC().Format(time.RFC3339), State: r.State, Sum: r.Sum})
+		}
+		if err := wsjson.Write(ctx, conn, msg); err != nil {
+			return fmt.Errorf("send statistics: %w", err)
+		}
+		if err := h.result(ctx, conn, id); err != nil {
+			return fmt.Errorf("import statistics from %s: %w", batch[0].Start.Format(time.RFC3339), err)
+		}
+	}
+	return conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// auth authenticates conn with the token.
+func (h *HomeAssistant) auth(ctx context.Context, conn *websocket.Conn) error {
+	var msg homeAssistantMessage
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		return fmt.Errorf("read homeassistant greeting: %w", err)
+	}
+	if msg.Type != "auth_required" {
+		return fmt.Errorf("homeassistant greeting %q, want auth_required", msg.Type)
+	}
+	if err := wsjson.Write(ctx, conn, homeAssistantMessage{Type: "auth", AccessToken: h.cfg.Token}); err != nil {
+		return fmt.Errorf("send homeassistant auth: %w", err)
+	}
+	msg = homeAssistantMessage{}
+	if err := wsjson.Read(ctx, conn, &msg); err != nil {
+		return fmt.Errorf("read homeassistant auth: %w", err)
+	}
+	switch msg.Type {
+	case "auth_ok":
+		return nil
+	case "auth_invalid":
+		return fmt.Errorf("homeassistant auth: %s", msg.Message)
+	}
+	return fmt.Errorf("homeassistant auth reply %q, want auth_ok", msg.Type)
+}
+
+// result waits for the result of command id.
+func (h *HomeAssistant) result(ctx context.Context, conn *websocket.Conn, id int) error {
+	for {
+		var msg homeAssistantMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			return fmt.Errorf("read result: %w", err)
+		}
+		if msg.Type != "result" || msg.ID != id {
+			continue
+		}
+		if msg.Success {
+			return nil
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("%s: %s", msg.Error.Code, msg.Error.Message)
+		}
+		return errors.New("failed")
+	}
+}
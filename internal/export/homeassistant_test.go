@@ -0,0 +1,119 @@
+package export_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/suapapa/mqvision/internal/export"
+)
+
+// fakeHomeAssistant is the WebSocket API of Home Assistant, keeping the
+// imported rows by start as the recorder does.
+type fakeHomeAssistant struct {
+	token string
+	meta  map[string]any
+	rows  map[string]map[string]any
+}
+
+func (f *fakeHomeAssistant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/websocket" {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	ctx := r.Context()
+	wsjson.Write(ctx, conn, map[string]any{"type": "auth_required", "ha_version": "2025.11.0"})
+	var auth map[string]any
+	if wsjson.Read(ctx, conn, &auth) != nil {
+		return
+	}
+	if auth["type"] != "auth" || auth["access_token"] != f.token {
+		wsjson.Write(ctx, conn, map[string]any{"type": "auth_invalid", "message": "Invalid access token or password"})
+		return
+	}
+	wsjson.Write(ctx, conn, map[string]any{"type": "auth_ok", "ha_version": "2025.11.0"})
+	for {
+		var msg struct {
+			ID       int              `json:"id"`
+			Type     string           `json:"type"`
+			Metadata map[string]any   `json:"metadata"`
+			Stats    []map[string]any `json:"stats"`
+		}
+		if wsjson.Read(ctx, conn, &msg) != nil {
+			return
+		}
+		if msg.Type != "recorder/import_statistics" {
+			wsjson.Write(ctx, conn, map[string]any{"id": msg.ID, "type": "result", "success": false, "error": map[string]any{"code": "unknown_command", "message": "Unknown command."}})
+			continue
+		}
+		f.meta = msg.Metadata
+		for _, row := range msg.Stats {
+			f.rows[row["start"].(string)] = row
+		}
+		wsjson.Write(ctx, conn, map[string]any{"id": msg.ID, "type": "result", "success": true, "result": nil})
+	}
+}
+
+func TestHomeAssistant(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := &fakeHomeAssistant{token: "long-lived", rows: map[string]map[string]any{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := export.HomeAssistantConfig{URL: srv.URL, Token: "long-lived"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	ha := export.NewHomeAssistant(cfg, meter)
+	if id := ha.Metadata().StatisticID; id != "mqvision:home" {
+		t.Fatalf("statistic ID %q, want mqvision:home", id)
+	}
+	rows := []export.Row{{start, 100.5, 0.5}, {start.Add(time.Hour), 101.5, 1.5}}
+	for range 2 {
+		if err := ha.Export(ctx, rows); err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+	}
+	if len(fake.rows) != 2 {
+		t.Fatalf("imported %v; want 2 rows", fake.rows)
+	}
+	row := fake.rows["2025-11-07T07:00:00Z"]
+	if row["state"] != 101.5 || row["sum"] != 1.5 {
+		t.Fatalf("row of 07:00 = %v", row)
+	}
+	if fake.meta["statistic_id"] != "mqvision:home" || fake.meta["source"] != "mqvision" || fake.meta["has_sum"] != true || fake.meta["has_mean"] != false || fake.meta["unit_of_measurement"] != "m³" {
+		t.Fatalf("metadata = %v", fake.meta)
+	}
+
+	bad := export.NewHomeAssistant(export.HomeAssistantConfig{URL: srv.URL, Token: "expired"}, meter)
+	if err := bad.Export(ctx, rows); err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Fatalf("Export with a bad token: %v", err)
+	}
+}
+
+func TestHomeAssistantConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []export.HomeAssistantConfig{
+		{URL: "http://homeassistant.local:8123"},
+		{URL: "mqtt://homeassistant.local", Token: "t"},
+		{URL: "http://homeassistant.local:8123", Token: "t", StatisticID: "sensor.gas"},
+		{URL: "http://homeassistant.local:8123", Token: "t", StatisticID: "recorder:gas"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("Validate(%+v) = nil, want an error", c)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package faults injects failures and latency into vision clients, stores and
+// sinks, to exercise retry and recovery paths without breaking real services.
+package faults
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// ErrInjected is the default injected error.
+var ErrInjected = errors.New("faults: injected failure")
+
+// Injector decides the fate of each call through a wrapped component.
+// The zero value passes every call through. It is safe for concurrent use.
+type Injector struct {
+	// Clock measures injected latency; nil uses [genai.RealClock].
+	Clock genai.Clock
+
+	mu       sync.Mutex
+	failNext int // < 0: fail every call
+	err      error
+	latency  time.Duration
+	calls    int
+	failed   int
+}
+
+// FailNext makes the next n calls fail with err ([ErrInjected] if nil).
+func (i *Injector) FailNext(n int, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.failNext, i.err = n, err
+}
+
+// FailAll makes every call fail with err ([ErrInjected] if nil) until [Injector.Reset].
+func (i *Injector) FailAll(err error) {
+	i.FailNext(-1, err)
+}
+
+// SetLatency delays every call by d before it runs (or fails).
+func (i *Injector) SetLatency(d time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.latency = d
+}
+
+// Reset stops injecting failures and latency.
+func (i *Injector) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.failNext, i.err, i.latency = 0, nil, 0
+}
+
+// Calls returns the number of calls seen and how many of them were failed.
+func (i *Injector) Calls() (calls, failed int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.calls, i.failed
+}
+
+// Inject is called before each wrapped call. It waits the configured latency
+// and returns the injected error, if any; ctx cancellation ends the wait.
+func (i *Injector) Inject(ctx context.Context) error {
+	i.mu.Lock()
+	i.calls++
+	latency := i.latency
+	var err error
+	if i.failNext != 0 {
+		err = i.err
+		if err == nil {
+			err = ErrInjected
+		}
+		if i.failNext > 0 {
+			i.failNext--
+		}
+		i.failed++
+	}
+	clock := i.Clock
+	i.mu.Unlock()
+
+	if latency > 0 {
+		if clock == nil {
+			clock = genai.RealClock
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(latency):
+		}
+	}
+	return err
+}
+
+// VisionClient wraps c so that reads go through inj. Close is not affected.
+func VisionClient(c genai.VisionClient, inj *Injector) genai.VisionClient {
+	return Middleware(inj)(c)
+}
+
+// Middleware is [VisionClient] as a [genai.Middleware], for [genai.Chain].
+func Middleware(inj *Injector) genai.Middleware {
+	return genai.Around(func(ctx context.Context, next func(context.Context) (*genai.GasMeterReadResult, error)) (*genai.GasMeterReadResult, error) {
+		if err := inj.Inject(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx)
+	})
+}
+
+// Sink wraps s so that publishes go through inj.
+func Sink(s sink.Sink, inj *Injector) sink.Sink {
+	return sink.Func(func(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+		if err := inj.Inject(ctx); err != nil {
+			return err
+		}
+		return s.Publish(ctx, meterID, r)
+	})
+}
+
+// Store wraps s so that every method except Close goes through inj.
+func Store(s store.Store, inj *Injector) store.Store {
+	return &faultyStore{s: s, inj: inj}
+}
+
+type faultyStore struct {
+	s   store.Store
+	inj *Injector
+}
+
+func (f *faultyStore) Save(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	if err := f.inj.Inject(ctx); err != nil {
+		return err
+	}
+	return f.s.Save(ctx, meterID, r)
+}
+
+func (f *faultyStore) Latest(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error) {
+	if err := f.inj.Inject(ctx); err != nil {
+		return nil, err
+	}
+	return f.s.Latest(ctx, meterID)
+}
+
+func (f *faultyStore) ReadingsBetween(ctx context.Context, meterID string, from, to time.Time) ([]*genai.GasMeterReadResult, error) {
+	if err := f.inj.Inject(ctx); err != nil {
+		return nil, err
+	}
+	return f.s.ReadingsBetween(ctx, meterID, from, to)
+}
+
+func (f *faultyStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	if err := f.inj.Inject(ctx); err != nil {
+		return 0, err
+	}
+	return f.s.Prune(ctx, before)
+}
+
+func (f *faultyStore) Close() error { return f.s.Close() }
@@ -0,0 +1,67 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestInjectorFailNext(t *testing.T) {
+	t.Parallel()
+
+	errQuota := errors.New("quota exceeded")
+	inj := &Injector{}
+	c := VisionClient(genaitest.NewMonotonicFake(1, 1), inj)
+	inj.FailNext(2, errQuota)
+
+	ctx := context.Background()
+	for i := range 2 {
+		if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, errQuota) {
+			t.Fatalf("call %d: err = %v, want %v", i, err, errQuota)
+		}
+	}
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil || res.Read != "00001.000" {
+		t.Fatalf("third call = %v, %v", res, err)
+	}
+	if calls, failed := inj.Calls(); calls != 3 || failed != 2 {
+		t.Fatalf("Calls = %d, %d; want 3, 2", calls, failed)
+	}
+}
+
+func TestInjectorLatency(t *testing.T) {
+	t.Parallel()
+
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	inj := &Injector{Clock: clock}
+	inj.SetLatency(time.Minute)
+	s := Store(store.NewMemory(), inj)
+
+	done := make(chan error)
+	go func() {
+		done <- s.Save(context.Background(), "home", &genai.GasMeterReadResult{Read: "00001.000"})
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Latest(ctx, "home"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Latest with cancelled ctx: err = %v", err)
+	}
+	inj.Reset()
+	if r, err := s.Latest(context.Background(), "home"); err != nil || r.Read != "00001.000" {
+		t.Fatalf("Latest = %v, %v", r, err)
+	}
+}
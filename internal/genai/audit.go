@@ -0,0 +1,100 @@
+package genai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Call types recorded in [AuditEntry].
+const (
+	CallRead   = "read"   // image reading
+	CallGuess  = "guess"  // disambiguation of "?" digits
+	CallVerify = "verify" // re-examination of the reading; see [WithSelfVerify]
+	CallFlow   = "flow"   // comparison of two photos of the flow indicator; see [FlowDetector]
+)
+
+// Usage is the token usage reported by the backend for one call.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// CachedTokens is the part of InputTokens the backend served from its
+	// cache, billed at a discount; see [WithContextCache].
+	CachedTokens int `json:"cached_tokens,omitempty"`
+	// ThinkingTokens is the part of OutputTokens the model spent thinking;
+	// see [Generation].
+	ThinkingTokens int `json:"thinking_tokens,omitempty"`
+}
+
+// Add returns the usage of u and v together.
+func (u Usage) Add(v Usage) Usage {
+	return Usage{
+		InputTokens:    u.InputTokens + v.InputTokens,
+		OutputTokens:   u.OutputTokens + v.OutputTokens,
+		CachedTokens:   u.CachedTokens + v.CachedTokens,
+		ThinkingTokens: u.ThinkingTokens + v.ThinkingTokens,
+	}
+}
+
+type usageKey struct{}
+
+// usageSum adds up the usage of the calls of one reading.
+type usageSum struct {
+	mu sync.Mutex
+	u  Usage
+}
+
+// WithUsage returns ctx, that of a reading, adding up the usage of its
+// calls reported with [AddUsage], and a function returning the sum.
+func WithUsage(ctx context.Context) (context.Context, func() Usage) {
+	sum := &usageSum{}
+	return context.WithValue(ctx, usageKey{}, sum), func() Usage {
+		sum.mu.Lock()
+		defer sum.mu.Unlock()
+		return sum.u
+	}
+}
+
+// AddUsage reports u, the usage of a call, to the reading of ctx.
+func AddUsage(ctx context.Context, u Usage) {
+	if sum, ok := ctx.Value(usageKey{}).(*usageSum); ok {
+		sum.mu.Lock()
+		sum.u = sum.u.Add(u)
+		sum.mu.Unlock()
+	}
+}
+
+// AuditEntry records one API call. It never contains credentials.
+type AuditEntry struct {
+	Time         time.Time `json:"time"`
+	Call         string    `json:"call"`
+	Model        string    `json:"model"`
+	SystemPrompt string    `json:"system_prompt,omitempty"`
+	Prompt       string    `json:"prompt,omitempty"`
+	ImageSHA256  string    `json:"image_sha256,omitempty"`
+	ImageSize    int64     `json:"image_size,omitempty"`
+	Response     string    `json:"response,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Usage        Usage     `json:"usage"`
+	Latency      string    `json:"latency"`
+}
+
+// Auditor receives an [AuditEntry] per API call. Audit must not block and
+// must not fail the reading; implementations are best-effort.
+type Auditor interface {
+	Audit(AuditEntry)
+}
+
+// WithAuditor records every API call to a.
+func WithAuditor(a Auditor) Option {
+	return func(o *Options) {
+		o.Auditor = a
+	}
+}
+
+// Audit forwards e to the configured [Auditor], if any.
+func (o *Options) Audit(e AuditEntry) {
+	if o.Auditor != nil {
+		o.Auditor.Audit(e)
+	}
+}
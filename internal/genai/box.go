@@ -0,0 +1,186 @@
+package genai
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"math"
+	"sync/atomic"
+)
+
+// Box is a rectangle in coordinates normalized to the image, from 0 at the
+// top left to 1 at the bottom right.
+type Box struct {
+	XMin float64 `json:"x_min"`
+	YMin float64 `json:"y_min"`
+	XMax float64 `json:"x_max"`
+	YMax float64 `json:"y_max"`
+}
+
+// WithCounterBox asks the model for the bounding box of the counter in the
+// image, returned as [GasMeterReadResult.CounterBox]; see the roi package.
+func WithCounterBox() Option {
+	return func(o *Options) {
+		o.CounterBox = true
+	}
+}
+
+// Valid reports whether b is a non-empty box within the image.
+func (b Box) Valid() bool {
+	return 0 <= b.XMin && b.XMin < b.XMax && b.XMax <= 1 &&
+		0 <= b.YMin && b.YMin < b.YMax && b.YMax <= 1
+}
+
+// Width and Height are the size of b as fractions of the image.
+func (b Box) Width() float64  { return b.XMax - b.XMin }
+func (b Box) Height() float64 { return b.YMax - b.YMin }
+
+// Pad grows b by f of its size on every side, clamped to the image.
+func (b Box) Pad(f float64) Box {
+	dx, dy := b.Width()*f, b.Height()*f
+	return Box{
+		XMin: math.Max(0, b.XMin-dx),
+		YMin: math.Max(0, b.YMin-dy),
+		XMax: math.Min(1, b.XMax+dx),
+		YMax: math.Min(1, b.YMax+dy),
+	}
+}
+
+// In maps b, relative to a crop of an image, to the coordinates of the image
+// the crop was cut from.
+func (b Box) In(crop Box) Box {
+	return Box{
+		XMin: crop.XMin + b.XMin*crop.Width(),
+		YMin: crop.YMin + b.YMin*crop.Height(),
+		XMax: crop.XMin + b.XMax*crop.Width(),
+		YMax: crop.YMin + b.YMax*crop.Height(),
+	}
+}
+
+// IoU is the intersection over union of b and o: 1 for the same box, 0 for
+// boxes that do not overlap.
+func (b Box) IoU(o Box) float64 {
+	w := math.Min(b.XMax, o.XMax) - math.Max(b.XMin, o.XMin)
+	h := math.Min(b.YMax, o.YMax) - math.Max(b.YMin, o.YMin)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+	return inter / (b.Width()*b.Height() + o.Width()*o.Height() - inter)
+}
+
+// CropJPEG cuts b out of the JPEG image jpg with a default [Encoder].
+func CropJPEG(jpg []byte, b Box) ([]byte, error) {
+	return (&Encoder{}).Crop(jpg, b)
+}
+
+// Paths of [Encoder.Encode], counted in [EncodeStats].
+const (
+	EncodeChanged  = "changed"  // the pixels changed and were encoded
+	EncodeSmaller  = "smaller"  // re-encoding saved at least MinSaving
+	EncodeOriginal = "original" // the original bytes were kept
+)
+
+// EncodeStats counts the images of an [Encoder] by path.
+type EncodeStats struct {
+	Changed  int64 `json:"changed"`
+	Smaller  int64 `json:"smaller"`
+	Original int64 `json:"original"`
+}
+
+// Encoder encodes preprocessed images as JPEGs, keeping the original bytes
+// of unchanged images unless re-encoding them makes them enough smaller:
+// re-encoding an already compressed image can make it bigger and blurrier.
+// It is safe for concurrent use.
+type Encoder struct {
+	// Quality is the JPEG quality (default 90).
+	Quality int
+	// MinSaving is the fraction of its size an unchanged image must lose to
+	// be re-encoded (default 0.1).
+	MinSaving float64
+	// Debug logs the path taken for every image.
+	Debug bool
+
+	changed, smaller, original atomic.Int64
+}
+
+// Encode returns img, decoded from orig, as a JPEG: encoded if changed is
+// set, and otherwise orig unless the encoding saves MinSaving.
+func (e *Encoder) Encode(orig []byte, img image.Image, changed bool) ([]byte, error) {
+	quality := e.Quality
+	if quality <= 0 {
+		quality = 90
+	}
+	minSaving := e.MinSaving
+	if minSaving <= 0 {
+		minSaving = 0.1
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	path, out := EncodeChanged, buf.Bytes()
+	switch {
+	case changed:
+		e.changed.Add(1)
+	case float64(len(out)) <= float64(len(orig))*(1-minSaving):
+		path = EncodeSmaller
+		e.smaller.Add(1)
+	default:
+		path, out = EncodeOriginal, orig
+		e.original.Add(1)
+	}
+	if e.Debug {
+		log.Printf("Encoded image (%s): %d bytes, %d re-encoded, %d sent", path, len(orig), buf.Len(), len(out))
+	}
+	return out, nil
+}
+
+// Stats returns the counts of the paths taken so far.
+func (e *Encoder) Stats() EncodeStats {
+	return EncodeStats{Changed: e.changed.Load(), Smaller: e.smaller.Load(), Original: e.original.Load()}
+}
+
+// Crop cuts b out of the JPEG image jpg. A box covering the whole image
+// changes no pixels, so that the original is kept if re-encoding does not
+// pay.
+func (e *Encoder) Crop(jpg []byte, b Box) ([]byte, error) {
+	if !b.Valid() {
+		return nil, fmt.Errorf("crop: invalid box %+v", b)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(jpg))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	r := img.Bounds()
+	w, h := float64(r.Dx()), float64(r.Dy())
+	rect := image.Rect(
+		r.Min.X+int(math.Floor(b.XMin*w)), r.Min.Y+int(math.Floor(b.YMin*h)),
+		r.Min.X+int(math.Ceil(b.XMax*w)), r.Min.Y+int(math.Ceil(b.YMax*h)),
+	).Intersect(r)
+	if rect == r {
+		return e.Encode(jpg, img, false)
+	}
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok || rect.Empty() {
+		return nil, fmt.Errorf("crop: cannot cut %v out of %T %v", rect, img, r)
+	}
+	return e.Encode(jpg, sub.SubImage(rect), true)
+}
+
+// counterBoxJSONSchema is the "counter_box" property of the answer schemas.
+var counterBoxJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"x_min": map[string]any{"type": "number"},
+		"y_min": map[string]any{"type": "number"},
+		"x_max": map[string]any{"type": "number"},
+		"y_max": map[string]any{"type": "number"},
+	},
+	"required":             []string{"x_min", "y_min", "x_max", "y_max"},
+	"additionalProperties": false,
+}
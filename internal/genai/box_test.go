@@ -0,0 +1,81 @@
+package genai
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+func TestBox(t *testing.T) {
+	t.Parallel()
+	near := func(a, b Box) bool {
+		return math.Abs(a.XMin-b.XMin) < 1e-9 && math.Abs(a.YMin-b.YMin) < 1e-9 &&
+			math.Abs(a.XMax-b.XMax) < 1e-9 && math.Abs(a.YMax-b.YMax) < 1e-9
+	}
+	b := Box{XMin: 0.2, YMin: 0.4, XMax: 0.6, YMax: 0.6}
+	if !b.Valid() || (Box{XMin: 0.5, XMax: 0.4, YMax: 1}).Valid() || (Box{XMax: 1.2, YMax: 1}).Valid() {
+		t.Fatalf("Valid is wrong")
+	}
+	if got, want := b.Pad(0.75), (Box{XMin: 0, YMin: 0.25, XMax: 0.9, YMax: 0.75}); !near(got, want) {
+		t.Fatalf("Pad = %+v, want %+v", got, want)
+	}
+	if got, want := (Box{XMin: 0.5, YMin: 0, XMax: 1, YMax: 0.5}).In(b), (Box{XMin: 0.4, YMin: 0.4, XMax: 0.6, YMax: 0.5}); !near(got, want) {
+		t.Fatalf("In = %+v, want %+v", got, want)
+	}
+	if iou := b.IoU(b); iou != 1 {
+		t.Fatalf("IoU with itself = %v", iou)
+	}
+	if iou := b.IoU(Box{XMin: 0.7, YMin: 0, XMax: 1, YMax: 1}); iou != 0 {
+		t.Fatalf("IoU of disjoint boxes = %v", iou)
+	}
+	if _, err := CropJPEG([]byte("not a jpeg"), b); err == nil {
+		t.Fatalf("CropJPEG of garbage succeeded")
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	t.Parallel()
+	encode := func(quality int) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+		for y := range 48 {
+			for x := range 64 {
+				img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), uint8(x * y), 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		return buf.Bytes()
+	}
+	small, large := encode(50), encode(100)
+	whole := Box{XMax: 1, YMax: 1}
+
+	e := &Encoder{}
+	got, err := e.Crop(small, whole)
+	if err != nil || !bytes.Equal(got, small) {
+		t.Fatalf("Crop of a small image to the whole frame changed it (%d -> %d bytes, %v)", len(small), len(got), err)
+	}
+	if got, err := e.Crop(large, whole); err != nil || len(got) >= len(large) {
+		t.Fatalf("Crop of a large image to the whole frame = %d bytes, %v, want fewer than %d", len(got), err, len(large))
+	}
+	got, err = e.Crop(small, Box{XMin: 0.25, YMin: 0.25, XMax: 0.75, YMax: 0.75})
+	if err != nil {
+		t.Fatalf("Crop: %v", err)
+	}
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(got)); err != nil || cfg.Width != 32 || cfg.Height != 24 {
+		t.Fatalf("crop is %+v, %v, want 32x24", cfg, err)
+	}
+	if got, want := e.Stats(), (EncodeStats{Changed: 1, Smaller: 1, Original: 1}); got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+
+	// Asking for a saving re-encoding cannot make keeps even the large image.
+	strict := &Encoder{MinSaving: 0.99}
+	if got, err := strict.Crop(large, whole); err != nil || !bytes.Equal(got, large) {
+		t.Fatalf("Crop with MinSaving 0.99 re-encoded the image (%v)", err)
+	}
+}
@@ -0,0 +1,148 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the API while a [Breaker] is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a [Breaker].
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails calls fast until the open duration has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker stops API calls after threshold consecutive failures. Once openFor
+// has passed it lets one probe through: a success closes it, a failure opens
+// it again. Like [Limiter], one Breaker may be shared by several clients.
+type Breaker struct {
+	threshold int
+	openFor   time.Duration
+	clock     Clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	trips    int64
+}
+
+// NewBreaker returns a closed Breaker.
+func NewBreaker(threshold int, openFor time.Duration) *Breaker {
+	return NewBreakerWithClock(threshold, openFor, RealClock)
+}
+
+// NewBreakerWithClock is like [NewBreaker] but measures openFor on c.
+func NewBreakerWithClock(threshold int, openFor time.Duration, c Clock) *Breaker {
+	return &Breaker{threshold: threshold, openFor: openFor, clock: c}
+}
+
+// Allow returns [ErrCircuitOpen] if the call must not be made. Every allowed
+// call must be followed by [Breaker.Done]. A nil Breaker allows everything.
+func (b *Breaker) Allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if Since(b.clock, b.openedAt) < b.openFor {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the outcome of an allowed call. Cancelled calls count neither
+// way, and an [InvalidOutputError] counts as a success since the API answered.
+func (b *Breaker) Done(err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+
+	var ioe *InvalidOutputError
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err == nil || errors.As(err, &ioe):
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+	case probe && b.state == BreakerHalfOpen:
+		b.open()
+	default:
+		b.failures++
+		if b.state == BreakerClosed && b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// State returns the current state; an open Breaker whose open duration has
+// passed still reports [BreakerOpen] until the next [Breaker.Allow].
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Trips returns how many times the breaker has opened.
+func (b *Breaker) Trips() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.trips
+}
+
+func (b *Breaker) open() {
+	b.openedAt = b.clock.Now()
+	b.trips++
+	b.setState(BreakerOpen)
+}
+
+func (b *Breaker) setState(s BreakerState) {
+	log.Printf("Circuit breaker %s -> %s (failures: %d)", b.state, s, b.failures)
+	b.state = s
+}
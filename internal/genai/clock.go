@@ -0,0 +1,31 @@
+package genai
+
+import "time"
+
+// Clock is the time source of clients and limiters. Tests replace it to get
+// deterministic ReadAt/ItTakes values and to drive waits without sleeping.
+type Clock interface {
+	Now() time.Time
+	// After is like [time.After] on this clock.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the system clock, the default of [NewOptions] and [NewLimiter].
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// WithClock sets the clock used for ReadAt, timing and audit timestamps.
+func WithClock(c Clock) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// Since returns the time elapsed on c since t.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
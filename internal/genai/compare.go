@@ -0,0 +1,100 @@
+package genai
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strconv"
+)
+
+// Comparison is the outcome of running one image through two clients (variants A and B).
+type Comparison struct {
+	Image   string              `json:"image,omitempty"`
+	A       *GasMeterReadResult `json:"a,omitempty"`
+	B       *GasMeterReadResult `json:"b,omitempty"`
+	ErrA    string              `json:"err_a,omitempty"`
+	ErrB    string              `json:"err_b,omitempty"`
+	Agree   bool                `json:"agree"`
+	Diff    float64             `json:"diff"`     // |A-B|, valid when HasDiff
+	HasDiff bool                `json:"has_diff"` // both readings parsed as numbers
+}
+
+// Compare reads jpg with both clients. Clients should be created with
+// [WithStateless] so the comparison does not disturb their previous reading,
+// and share one [Limiter] via [WithRateLimiter] so the rate limit spans both.
+func Compare(ctx context.Context, a, b VisionClient, jpg []byte) *Comparison {
+	cmp := &Comparison{}
+
+	var err error
+	if cmp.A, err = a.ReadGasGaugePic(ctx, bytes.NewReader(jpg)); err != nil {
+		cmp.ErrA = err.Error()
+	}
+	if cmp.B, err = b.ReadGasGaugePic(ctx, bytes.NewReader(jpg)); err != nil {
+		cmp.ErrB = err.Error()
+	}
+	if cmp.A == nil || cmp.B == nil {
+		return cmp
+	}
+
+	cmp.Agree = cmp.A.Read == cmp.B.Read
+	va, errA := strconv.ParseFloat(cmp.A.Read, 64)
+	vb, errB := strconv.ParseFloat(cmp.B.Read, 64)
+	if errA == nil && errB == nil {
+		cmp.Diff = math.Abs(va - vb)
+		cmp.HasDiff = true
+	}
+	return cmp
+}
+
+// CompareSummary aggregates comparisons over a set of images.
+type CompareSummary struct {
+	Images        int     `json:"images"`
+	Agreements    int     `json:"agreements"`
+	AgreementRate float64 `json:"agreement_rate"` // over images both variants read
+	MeanAbsDiff   float64 `json:"mean_abs_diff"`  // over images with numeric readings from both
+	AmbiguousA    int     `json:"ambiguous_a"`
+	AmbiguousB    int     `json:"ambiguous_b"`
+	ErrorsA       int     `json:"errors_a"`
+	ErrorsB       int     `json:"errors_b"`
+}
+
+// Summarize computes a [CompareSummary].
+func Summarize(cmps []*Comparison) CompareSummary {
+	var (
+		s       CompareSummary
+		both    int
+		diffs   int
+		diffSum float64
+	)
+	for _, c := range cmps {
+		s.Images++
+		if c.A == nil {
+			s.ErrorsA++
+		} else if c.A.Ambiguous {
+			s.AmbiguousA++
+		}
+		if c.B == nil {
+			s.ErrorsB++
+		} else if c.B.Ambiguous {
+			s.AmbiguousB++
+		}
+		if c.A == nil || c.B == nil {
+			continue
+		}
+		both++
+		if c.Agree {
+			s.Agreements++
+		}
+		if c.HasDiff {
+			diffs++
+			diffSum += c.Diff
+		}
+	}
+	if both > 0 {
+		s.AgreementRate = float64(s.Agreements) / float64(both)
+	}
+	if diffs > 0 {
+		s.MeanAbsDiff = diffSum / float64(diffs)
+	}
+	return s
+}
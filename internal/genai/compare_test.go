@@ -0,0 +1,88 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+type stubClient struct {
+	res *GasMeterReadResult
+	err error
+}
+
+func (s stubClient) ReadGasGaugePic(context.Context, io.Reader) (*GasMeterReadResult, error) {
+	return s.res, s.err
+}
+
+func (s stubClient) ReadGasGaugePicFromURL(context.Context, string) (*GasMeterReadResult, error) {
+	return s.res, s.err
+}
+
+func (s stubClient) Close() error { return nil }
+
+func TestCompareAndSummarize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	same := Compare(ctx,
+		stubClient{res: &GasMeterReadResult{Read: "00100.500"}},
+		stubClient{res: &GasMeterReadResult{Read: "00100.500"}},
+		nil)
+	if !same.Agree || !same.HasDiff || same.Diff != 0 {
+		t.Fatalf("same = %#v", same)
+	}
+
+	differ := Compare(ctx,
+		stubClient{res: &GasMeterReadResult{Read: "00100.500"}},
+		stubClient{res: &GasMeterReadResult{Read: "00101.000", Ambiguous: true}},
+		nil)
+	if differ.Agree || math.Abs(differ.Diff-0.5) > 1e-9 {
+		t.Fatalf("differ = %#v", differ)
+	}
+
+	failed := Compare(ctx,
+		stubClient{err: errors.New("boom")},
+		stubClient{res: &GasMeterReadResult{Read: "1"}},
+		nil)
+	if failed.ErrA != "boom" || failed.Agree {
+		t.Fatalf("failed = %#v", failed)
+	}
+
+	s := Summarize([]*Comparison{same, differ, failed})
+	want := CompareSummary{
+		Images:        3,
+		Agreements:    1,
+		AgreementRate: 0.5,
+		MeanAbsDiff:   0.25,
+		AmbiguousB:    1,
+		ErrorsA:       1,
+	}
+	if s != want {
+		t.Fatalf("Summarize() = %+v, want %+v", s, want)
+	}
+}
+
+func TestLimiterSpacesCalls(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(20 * time.Millisecond)
+	ctx := context.Background()
+	start := time.Now()
+	for range 3 {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if el := time.Since(start); el < 40*time.Millisecond {
+		t.Fatalf("3 calls took %v, want >= 40ms", el)
+	}
+
+	var nilLimiter *Limiter
+	if err := nilLimiter.Wait(ctx); err != nil {
+		t.Fatalf("nil limiter: %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package genai
+
+import "time"
+
+// DefaultContextCacheTTL is the lifetime of cached prompts of
+// [WithContextCache] without a TTL.
+const DefaultContextCacheTTL = time.Hour
+
+// WithContextCache makes backends that support context caching, such as
+// Gemini, keep the system prompt and the few-shot examples in cached content
+// for ttl (DefaultContextCacheTTL if not positive), so that reading calls
+// send only the image and its prompt. The backend creates the cache of each
+// model on its first reading, extends it as it is used and replaces it
+// when the prompts change; without support, or while the cache cannot be
+// created, readings are prompted as usual. The input tokens served from the
+// cache are counted in [Stats.CachedInputTokens].
+func WithContextCache(ttl time.Duration) Option {
+	return func(o *Options) {
+		if ttl <= 0 {
+			ttl = DefaultContextCacheTTL
+		}
+		o.ContextCacheTTL = ttl
+	}
+}
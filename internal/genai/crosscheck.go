@@ -0,0 +1,61 @@
+package genai
+
+// CrossCheck is the reading of a secondary recognizer, such as a local OCR
+// of the display, taken from the same image to check the model's against.
+type CrossCheck struct {
+	Recognizer string `json:"recognizer"`
+	// Read is the reading of the recognizer, with "?" for digits it could
+	// not tell; it need not have the decimal point.
+	Read       string  `json:"read,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	// Error is why the recognizer gave no reading to check, e.g. one less
+	// confident than the threshold.
+	Error string `json:"error,omitempty"`
+}
+
+// Agrees reports whether read has the digits of the recognizer's reading,
+// a "?" of either matching any digit; ok is false if there is no reading to
+// check against.
+func (c *CrossCheck) Agrees(read string) (agree, ok bool) {
+	if c == nil || c.Error != "" || c.Read == "" {
+		return false, false
+	}
+	a, b := crossDigits(c.Read), crossDigits(read)
+	if len(a) != len(b) {
+		return false, true
+	}
+	for i := range a {
+		if a[i] != b[i] && a[i] != '?' && b[i] != '?' {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+// crossDigits returns the digits and "?" of s.
+func crossDigits(s string) []byte {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if '0' <= s[i] && s[i] <= '9' || s[i] == '?' {
+			b = append(b, s[i])
+		}
+	}
+	return b
+}
+
+// SetCrossCheck records c, the check of the reading. When the two agree,
+// the model's digit confidences, if any, are raised as by independent
+// evidence: a digit both were 80% confident of is 96% certain. The
+// confidences are replaced, not changed in place, as the client may keep
+// the result.
+func (r *GasMeterReadResult) SetCrossCheck(c *CrossCheck) {
+	r.CrossCheck = c
+	if agree, ok := c.Agrees(r.Read); !ok || !agree || len(r.Confidences) == 0 {
+		return
+	}
+	boosted := make([]float64, len(r.Confidences))
+	for i, conf := range r.Confidences {
+		boosted[i] = 1 - (1-conf)*(1-c.Confidence)
+	}
+	r.Confidences = boosted
+}
@@ -0,0 +1,43 @@
+package genai
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCrossCheckAgrees(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		check     *CrossCheck
+		read      string
+		agree, ok bool
+	}{
+		{&CrossCheck{Read: "02924457"}, "02924.457", true, true}, // no decimal point
+		{&CrossCheck{Read: "0292?457"}, "02924.457", true, true},
+		{&CrossCheck{Read: "02924457"}, "02924.4?7", true, true},
+		{&CrossCheck{Read: "02924451"}, "02924.457", false, true},
+		{&CrossCheck{Read: "2924457"}, "02924.457", false, true},
+		{&CrossCheck{Error: "no digits found"}, "02924.457", false, false},
+		{nil, "02924.457", false, false},
+	}
+	for _, tt := range tests {
+		if agree, ok := tt.check.Agrees(tt.read); agree != tt.agree || ok != tt.ok {
+			t.Errorf("%+v.Agrees(%q) = %t, %t, want %t, %t", tt.check, tt.read, agree, ok, tt.agree, tt.ok)
+		}
+	}
+}
+
+func TestSetCrossCheck(t *testing.T) {
+	t.Parallel()
+
+	r := &GasMeterReadResult{Read: "02924.457", Confidences: []float64{1, 0.8, 0.5}}
+	r.SetCrossCheck(&CrossCheck{Read: "02924.457", Confidence: 0.8})
+	if got := fmt.Sprintf("%.2f", r.Confidences); got != "[1.00 0.96 0.90]" {
+		t.Fatalf("boosted confidences = %s", got)
+	}
+	r.SetCrossCheck(&CrossCheck{Read: "02924.451", Confidence: 0.8})
+	if got := fmt.Sprintf("%.2f", r.Confidences); got != "[1.00 0.96 0.90]" || r.CrossCheck.Read != "02924.451" {
+		t.Fatalf("confidences after a disagreement = %s", got)
+	}
+}
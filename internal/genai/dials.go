@@ -0,0 +1,143 @@
+package genai
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Meter types.
+const (
+	MeterCounter = "counter" // rolling digit counter (default)
+	MeterDials   = "dials"   // clock-style sub-dials, one per digit
+)
+
+// DialReading is the model's report of one dial, most significant first.
+type DialReading struct {
+	Value     float64 `json:"value"`               // pointer position in [0, 10), e.g. 3.6
+	Direction string  `json:"direction,omitempty"` // "cw" or "ccw", for debugging
+}
+
+// DialResultJSONSchema is the JSON schema of the model's answer in dials mode.
+var DialResultJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"dials": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value":     map[string]any{"type": "number"},
+					"direction": map[string]any{"type": "string", "enum": []string{"cw", "ccw"}},
+				},
+				"required":             []string{"value", "direction"},
+				"additionalProperties": false,
+			},
+		},
+		"date":  map[string]any{"type": "string"},
+		"issue": issueJSONSchema,
+	},
+	"required":             []string{"dials", "date", "issue"},
+	"additionalProperties": false,
+}
+
+// ResponseJSONSchema returns the answer schema for the meter type.
+func (m Meter) ResponseJSONSchema() map[string]any {
+	if m.Type == MeterDials {
+		return DialResultJSONSchema
+	}
+	return ReadResultJSONSchema
+}
+
+// dialEdge is how close to a number a pointer must be for the next dial to
+// decide whether it has reached it.
+const dialEdge = 0.15
+
+// AssembleDials turns per-dial pointer values into a reading in m's format.
+//
+// A pointer between two numbers takes the lower one. A pointer (almost) on a
+// number n is only read as n if the next dial has passed zero; if the next
+// dial is still in its upper half, the pointer has not quite reached n and
+// the digit is n-1. The last dial has no successor and always takes the lower value.
+func AssembleDials(m Meter, dials []DialReading) (string, error) {
+	if want := m.IntDigits + m.FracDigits; len(dials) != want {
+		return "", fmt.Errorf("got %d dials, want %d", len(dials), want)
+	}
+
+	digits := make([]int, len(dials))
+	for i, d := range dials {
+		v := d.Value
+		if v < 0 || v >= 10 || math.IsNaN(v) {
+			return "", fmt.Errorf("dial %d: value %v out of range", i, v)
+		}
+		n := math.Round(v)
+		if i+1 < len(dials) && math.Abs(v-n) < dialEdge {
+			if dials[i+1].Value >= 5 {
+				n--
+			}
+			digits[i] = (int(n) + 10) % 10
+			continue
+		}
+		digits[i] = int(math.Floor(v))
+	}
+	return formatDigits(m, digits), nil
+}
+
+func formatDigits(m Meter, digits []int) string {
+	var sb strings.Builder
+	for i, d := range digits {
+		if i == m.IntDigits {
+			sb.WriteByte('.')
+		}
+		sb.WriteByte(byte('0' + d))
+	}
+	return sb.String()
+}
+
+// ResolveDials fills out.Read from out.Dials. Dial readings are most often
+// off by one on a single dial, so when the assembled reading is below
+// prevRead, each single-dial ±1 variant is tried and the smallest one not
+// below prevRead is taken (marking the result ambiguous and
+// [ProvenanceHeuristic]).
+func ResolveDials(m Meter, out *GasMeterReadResult, prevRead string) error {
+	read, err := AssembleDials(m, out.Dials)
+	if err != nil {
+		return err
+	}
+	out.Read = read
+
+	prev, err := strconv.ParseFloat(prevRead, 64)
+	if err != nil {
+		return nil // no usable reference
+	}
+	cur, _ := strconv.ParseFloat(read, 64)
+	if cur >= prev {
+		return nil
+	}
+
+	digits := make([]int, 0, len(out.Dials))
+	for _, r := range read {
+		if r != '.' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	best, bestVal := "", math.Inf(1)
+	for i := range digits {
+		for _, delta := range []int{-1, 1} {
+			alt := append([]int(nil), digits...)
+			alt[i] = (alt[i] + delta + 10) % 10
+			s := formatDigits(m, alt)
+			v, _ := strconv.ParseFloat(s, 64)
+			if v >= prev && v < bestVal {
+				best, bestVal = s, v
+			}
+		}
+	}
+	if best != "" {
+		out.Read = best
+		out.Ambiguous = true
+		out.Provenance = ProvenanceHeuristic
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package genai
+
+import "testing"
+
+func TestAssembleDials(t *testing.T) {
+	t.Parallel()
+
+	m := Meter{Type: MeterDials, IntDigits: 3, FracDigits: 1}
+	tests := []struct {
+		name    string
+		values  []float64
+		want    string
+		wantErr bool
+	}{
+		{name: "between numbers", values: []float64{1.5, 2.5, 3.5, 4.5}, want: "123.4"},
+		{name: "on number, next passed zero", values: []float64{3.0, 0.4, 5.5, 7.2}, want: "305.7"},
+		{name: "on number, next not passed zero", values: []float64{3.0, 9.6, 5.5, 7.2}, want: "295.7"},
+		{name: "near zero, next high", values: []float64{0.05, 9.8, 0.5, 0.5}, want: "990.0"},
+		{name: "last dial floors", values: []float64{1.5, 2.5, 3.5, 4.98}, want: "123.4"},
+		{name: "wrong dial count", values: []float64{1, 2, 3}, wantErr: true},
+		{name: "out of range", values: []float64{1, 2, 3, 10}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dials := make([]DialReading, len(tt.values))
+			for i, v := range tt.values {
+				dials[i].Value = v
+			}
+			got, err := AssembleDials(m, dials)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleDials err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("AssembleDials() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDialsOffByOne(t *testing.T) {
+	t.Parallel()
+
+	m := Meter{Type: MeterDials, IntDigits: 4}
+	out := &GasMeterReadResult{Dials: []DialReading{{Value: 1.5}, {Value: 2.5}, {Value: 2.5}, {Value: 7.5}}}
+	if err := ResolveDials(m, out, "1236"); err != nil {
+		t.Fatal(err)
+	}
+	if out.Read != "1237" || !out.Ambiguous {
+		t.Fatalf("Read = %q, Ambiguous = %v; want 1237, true", out.Read, out.Ambiguous)
+	}
+
+	out = &GasMeterReadResult{Dials: []DialReading{{Value: 1.5}, {Value: 2.5}, {Value: 3.5}, {Value: 7.5}}}
+	if err := ResolveDials(m, out, "1236"); err != nil {
+		t.Fatal(err)
+	}
+	if out.Read != "1237" || out.Ambiguous {
+		t.Fatalf("Read = %q, Ambiguous = %v; want 1237, false", out.Read, out.Ambiguous)
+	}
+}
@@ -0,0 +1,162 @@
+package genai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AgreementPolicy decides whether the readings of an ensemble agree.
+type AgreementPolicy interface {
+	// Agree returns the accepted reading. reads holds one entry per model;
+	// models that failed have an empty string.
+	Agree(reads []string) (string, bool)
+}
+
+// ExactMatch accepts a reading only when every model returned it.
+func ExactMatch() AgreementPolicy { return exactMatch{} }
+
+type exactMatch struct{}
+
+func (exactMatch) Agree(reads []string) (string, bool) {
+	for _, r := range reads {
+		if r == "" || r != reads[0] || strings.Contains(r, "?") {
+			return "", false
+		}
+	}
+	return reads[0], len(reads) > 0
+}
+
+// WithinEpsilon accepts the first model's reading when every model answered
+// and all readings are within eps of each other.
+func WithinEpsilon(eps float64) AgreementPolicy { return withinEpsilon(eps) }
+
+type withinEpsilon float64
+
+func (eps withinEpsilon) Agree(reads []string) (string, bool) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, r := range reads {
+		v, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			return "", false
+		}
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if len(reads) == 0 || hi-lo > float64(eps)+1e-9 {
+		return "", false
+	}
+	return reads[0], true
+}
+
+// Majority accepts a reading returned by more than half of the models,
+// e.g. two of three.
+func Majority() AgreementPolicy { return majority{} }
+
+type majority struct{}
+
+func (majority) Agree(reads []string) (string, bool) {
+	counts := make(map[string]int)
+	for _, r := range reads {
+		if r != "" && !strings.Contains(r, "?") {
+			counts[r]++
+		}
+	}
+	for _, r := range reads {
+		if counts[r]*2 > len(reads) {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// ModelAnswer is one ensemble member's answer.
+type ModelAnswer struct {
+	Model string `json:"model"`
+	Read  string `json:"read,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// WithEnsemble reads every image with each of models (instead of the
+// client's model) and accepts the reading when policy agrees. Otherwise the
+// digits the models disagree on become "?" and go through disambiguation.
+// A nil policy means [ExactMatch].
+func WithEnsemble(models []string, policy AgreementPolicy) Option {
+	return func(o *Options) {
+		if policy == nil {
+			policy = ExactMatch()
+		}
+		o.Ensemble, o.Agreement = models, policy
+	}
+}
+
+// RunEnsemble reads with every model through read and combines the answers
+// under policy. The returned result is the first successful answer with Read
+// replaced by the accepted reading, or by the answers merged with "?" at
+// every disagreeing digit; agreed reports which. It fails when no model
+// answered or the answers cannot be merged.
+func RunEnsemble(ctx context.Context, models []string, policy AgreementPolicy,
+	read func(ctx context.Context, model string) (*GasMeterReadResult, error),
+) (out *GasMeterReadResult, agreed bool, err error) {
+	answers := make([]ModelAnswer, len(models))
+	reads := make([]string, len(models))
+	var firstErr error
+	for i, model := range models {
+		answers[i].Model = model
+		res, err := read(ctx, model)
+		if err != nil {
+			answers[i].Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		answers[i].Read, reads[i] = res.Read, res.Read
+		if out == nil {
+			out = res
+		}
+	}
+	if out == nil {
+		return nil, false, fmt.Errorf("all ensemble models failed: %w", firstErr)
+	}
+	out.Answers = answers
+	out.Model = strings.Join(models, "+")
+
+	if r, ok := policy.Agree(reads); ok {
+		out.Read = r
+		return out, true, nil
+	}
+	merged, ok := mergeReads(reads)
+	if !ok {
+		return nil, false, fmt.Errorf("ensemble answers %q cannot be reconciled", reads)
+	}
+	out.Read = merged
+	return out, false, nil
+}
+
+// mergeReads marks with "?" every position where the non-empty reads differ.
+func mergeReads(reads []string) (string, bool) {
+	var merged []byte
+	for _, r := range reads {
+		if r == "" {
+			continue
+		}
+		if merged == nil {
+			merged = []byte(r)
+			continue
+		}
+		if len(r) != len(merged) {
+			return "", false
+		}
+		for i := range merged {
+			if (merged[i] == '.') != (r[i] == '.') {
+				return "", false
+			}
+			if merged[i] != r[i] {
+				merged[i] = '?'
+			}
+		}
+	}
+	return string(merged), merged != nil
+}
@@ -0,0 +1,70 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAgreementPolicies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy AgreementPolicy
+		reads  []string
+		want   string
+		ok     bool
+	}{
+		{"exact agree", ExactMatch(), []string{"02924.457", "02924.457"}, "02924.457", true},
+		{"exact differ", ExactMatch(), []string{"02924.457", "02924.458"}, "", false},
+		{"exact failed model", ExactMatch(), []string{"02924.457", ""}, "", false},
+		{"exact ambiguous", ExactMatch(), []string{"0292?.457", "0292?.457"}, "", false},
+		{"epsilon within", WithinEpsilon(0.002), []string{"02924.457", "02924.459"}, "02924.457", true},
+		{"epsilon outside", WithinEpsilon(0.002), []string{"02924.457", "02924.460"}, "", false},
+		{"majority 2 of 3", Majority(), []string{"02924.458", "02924.457", "02924.457"}, "02924.457", true},
+		{"majority split", Majority(), []string{"02924.458", "02924.457", ""}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := tt.policy.Agree(tt.reads)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("Agree(%q) = %q, %v; want %q, %v", tt.reads, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRunEnsemble(t *testing.T) {
+	t.Parallel()
+
+	answers := map[string]string{"a": "02924.457", "b": "02925.457", "c": "1234"}
+	read := func(_ context.Context, model string) (*GasMeterReadResult, error) {
+		if model == "down" {
+			return nil, errors.New("unavailable")
+		}
+		return &GasMeterReadResult{Read: answers[model], Model: model}, nil
+	}
+	ctx := context.Background()
+
+	out, agreed, err := RunEnsemble(ctx, []string{"a", "a"}, ExactMatch(), read)
+	if err != nil || !agreed || out.Read != "02924.457" || out.Model != "a+a" || len(out.Answers) != 2 {
+		t.Fatalf("agree = %+v, %v, %v", out, agreed, err)
+	}
+
+	out, agreed, err = RunEnsemble(ctx, []string{"a", "b", "down"}, ExactMatch(), read)
+	if err != nil || agreed || out.Read != "0292?.457" {
+		t.Fatalf("disagree = %+v, %v, %v", out, agreed, err)
+	}
+	if out.Answers[2].Error == "" {
+		t.Fatalf("failed model not recorded: %+v", out.Answers)
+	}
+
+	if _, _, err := RunEnsemble(ctx, []string{"a", "c"}, ExactMatch(), read); err == nil {
+		t.Fatal("mismatched formats: want error")
+	}
+	if _, _, err := RunEnsemble(ctx, []string{"down"}, ExactMatch(), read); err == nil {
+		t.Fatal("all models failed: want error")
+	}
+}
@@ -0,0 +1,75 @@
+package genai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MaxExamples bounds [WithExampleImages]. Every example image is sent with
+// every reading, so each one adds roughly the input token cost of the real
+// image (a few hundred tokens for a typical meter photo) to every call.
+const MaxExamples = 3
+
+// Example is an annotated image of the same meter model, shown to the model
+// as a prior conversation turn before the real image.
+type Example struct {
+	Image        io.Reader // read once at client construction; takes precedence over Path
+	Path         string    // JPEG file, used when Image is nil
+	ExpectedRead string    // e.g. "02924.457"
+}
+
+// LoadedExample is an [Example] with its JPEG bytes read into memory.
+type LoadedExample struct {
+	JPEG         []byte
+	ExpectedRead string
+}
+
+// ModelAnswer is the model turn that follows the example image in the conversation.
+func (e LoadedExample) ModelAnswer() string {
+	b, _ := json.Marshal(map[string]string{"read": e.ExpectedRead})
+	return string(b)
+}
+
+// WithExampleImages adds few-shot examples to every reading. At most
+// [MaxExamples] are allowed; see there for the token cost.
+func WithExampleImages(examples []Example) Option {
+	return func(o *Options) {
+		o.Examples = examples
+	}
+}
+
+// LoadExamples reads each example image once so it can be uploaded or inlined
+// repeatedly.
+func LoadExamples(examples []Example) ([]LoadedExample, error) {
+	if len(examples) > MaxExamples {
+		return nil, fmt.Errorf("too many example images: %d (max %d)", len(examples), MaxExamples)
+	}
+	loaded := make([]LoadedExample, 0, len(examples))
+	for i, e := range examples {
+		if e.ExpectedRead == "" {
+			return nil, fmt.Errorf("example %d: empty expected read", i)
+		}
+		var (
+			b   []byte
+			err error
+		)
+		switch {
+		case e.Image != nil:
+			b, err = io.ReadAll(e.Image)
+		case e.Path != "":
+			b, err = os.ReadFile(e.Path)
+		default:
+			return nil, fmt.Errorf("example %d: no image or path", i)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("example %d: read image: %w", i, err)
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("example %d: empty image", i)
+		}
+		loaded = append(loaded, LoadedExample{JPEG: b, ExpectedRead: e.ExpectedRead})
+	}
+	return loaded, nil
+}
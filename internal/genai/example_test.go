@@ -0,0 +1,34 @@
+package genai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadExamples(t *testing.T) {
+	t.Parallel()
+
+	loaded, err := LoadExamples([]Example{
+		{Image: strings.NewReader("jpeg"), ExpectedRead: "02924.457"},
+	})
+	if err != nil {
+		t.Fatalf("LoadExamples: %v", err)
+	}
+	if len(loaded) != 1 || string(loaded[0].JPEG) != "jpeg" {
+		t.Fatalf("loaded = %#v", loaded)
+	}
+	if got, want := loaded[0].ModelAnswer(), `"read":"02924.457"`; !strings.Contains(got, want) {
+		t.Fatalf("ModelAnswer() = %q, want it to contain %q", got, want)
+	}
+
+	tooMany := make([]Example, MaxExamples+1)
+	if _, err := LoadExamples(tooMany); err == nil {
+		t.Fatal("expected error for too many examples")
+	}
+	if _, err := LoadExamples([]Example{{Image: strings.NewReader("x")}}); err == nil {
+		t.Fatal("expected error for missing expected read")
+	}
+	if _, err := LoadExamples([]Example{{ExpectedRead: "1"}}); err == nil {
+		t.Fatal("expected error for missing image")
+	}
+}
@@ -0,0 +1,71 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// FlowResult is the model's comparison of two photos of the small flow
+// indicator of a meter, taken a few seconds apart: whether it moved, and
+// how far. The indicator turns with any flow, even while the counter stands
+// still, so it corroborates an overnight leak.
+type FlowResult struct {
+	FlowDetected bool `json:"flow_detected"`
+	// Rotation is the estimated fraction of a turn the indicator moved
+	// between the photos, from 0 to 1.
+	Rotation float64   `json:"rotation"`
+	Note     string    `json:"note,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// FlowDetector is implemented by clients that can compare two photos of
+// the flow indicator, the second taken after the first.
+type FlowDetector interface {
+	DetectFlow(ctx context.Context, img1, img2 io.Reader) (*FlowResult, error)
+}
+
+// FlowResultJSONSchema is the JSON schema of the model's answer to the
+// flow prompt.
+var FlowResultJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"flow_detected": map[string]any{"type": "boolean"},
+		"rotation":      map[string]any{"type": "number"},
+		"note":          map[string]any{"type": "string"},
+	},
+	"required":             []string{"flow_detected", "rotation", "note"},
+	"additionalProperties": false,
+}
+
+// ParseFlowResult decodes the model's answer to the flow prompt as
+// [ParseReadResult] does a reading, and clamps the rotation to [0, 1].
+func ParseFlowResult(text string) (*FlowResult, error) {
+	var out FlowResult
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &out); err != nil {
+		s := ExtractJSONObject(text)
+		if s == "" {
+			return nil, &InvalidOutputError{Raw: text, Err: fmt.Errorf("no JSON object: %w", err)}
+		}
+		out = FlowResult{}
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, &InvalidOutputError{Raw: text, Err: err}
+		}
+	}
+	if math.IsNaN(out.Rotation) {
+		out.Rotation = 0
+	}
+	out.Rotation = min(max(out.Rotation, 0), 1)
+	out.Note = strings.TrimSpace(out.Note)
+	return &out, nil
+}
+
+// FlowPrompt returns the flow prompt for m.
+func (p *Prompts) FlowPrompt(m Meter) string {
+	return fmt.Sprintf(p.Flow, p.data(m, "").MeterName)
+}
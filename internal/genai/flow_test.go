@@ -0,0 +1,35 @@
+package genai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFlowResult(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text     string
+		detected bool
+		rotation float64
+		wantErr  bool
+	}{
+		{`{"flow_detected":true,"rotation":0.3,"note":"turned"}`, true, 0.3, false},
+		{"```json\n{\"flow_detected\":false,\"rotation\":0,\"note\":\"\"}\n```", false, 0, false},
+		{`The indicator moved: {"flow_detected":true,"rotation":2}`, true, 1, false},
+		{`{"flow_detected":false,"rotation":-0.5}`, false, 0, false},
+		{`moved a bit`, false, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFlowResult(tt.text)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidModelOutput) {
+				t.Errorf("ParseFlowResult(%q) err = %v, want ErrInvalidModelOutput", tt.text, err)
+			}
+			continue
+		}
+		if err != nil || got.FlowDetected != tt.detected || got.Rotation != tt.rotation {
+			t.Errorf("ParseFlowResult(%q) = %+v, %v; want %t, %v", tt.text, got, err, tt.detected, tt.rotation)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package genai
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"time"
 )
@@ -11,11 +13,200 @@ type VisionClient interface {
 	ReadGasGaugePic(ctx context.Context, jpgReader io.Reader) (*GasMeterReadResult, error)
 	// ReadGasGaugePicFromURL runs the same analysis using an image reachable at imageURL (e.g. https).
 	ReadGasGaugePicFromURL(ctx context.Context, imageURL string) (*GasMeterReadResult, error)
+	// Close releases backend resources such as uploaded example images.
+	Close() error
 }
 
 type GasMeterReadResult struct {
-	Read    string    `json:"read"`
-	Date    string    `json:"date"`
-	ReadAt  time.Time `json:"read_at,omitempty"`
-	ItTakes string    `json:"it_takes,omitempty"`
+	// ID identifies an accepted reading across retries and replays; see [ReadingID].
+	ID   string `json:"id,omitempty"`
+	Read string `json:"read"`
+	// RawRead is the reading as the model answered it, when normalization
+	// changed it; see [NormalizeRead].
+	RawRead string `json:"raw_read,omitempty"`
+	Utility string `json:"utility,omitempty"` // see [Meter.Utility]
+	Date    string `json:"date"`
+	// DateParsed is Date parsed and normalized to the configured time zone
+	// (see [WithLocation]); zero if Date is not recognized.
+	DateParsed time.Time `json:"date_parsed,omitzero"`
+	ReadAt     time.Time `json:"read_at,omitempty"`
+	// DateSkew is how far DateParsed is ahead of ReadAt, e.g. "-2h0m0s",
+	// when the date_skew validator found it beyond its limit.
+	DateSkew string  `json:"date_skew,omitempty"`
+	ItTakes  string  `json:"it_takes,omitempty"`
+	Timing   *Timing `json:"timing,omitempty"`
+	// Ambiguous reports that some digits were uncertain and had to be guessed,
+	// either by a disambiguation call or by the model itself in single-shot mode.
+	Ambiguous bool `json:"ambiguous,omitempty"`
+	// AmbiguousPositions are the indexes into Read of the digits the model
+	// resolved itself in single-shot mode.
+	AmbiguousPositions []int `json:"ambiguous_positions,omitempty"`
+	// Confidences are the model's confidences, from 0 to 1, in the digits of
+	// Read left to right, when it reports them, as checked by the
+	// min_confidence validator.
+	Confidences []float64 `json:"confidences,omitempty"`
+	// Dials holds the raw per-dial values in dials mode.
+	Dials []DialReading `json:"dials,omitempty"`
+	// Answers holds every model's reading in ensemble mode.
+	Answers []ModelAnswer `json:"answers,omitempty"`
+	// Issue is the condition of the image the model reported, if any.
+	Issue *Issue `json:"issue,omitempty"`
+	// SerialNumber is the meter's serial number as read from the image, with
+	// "?" for unreadable characters; only read when [Meter.Serial] is set.
+	SerialNumber string `json:"serial_number,omitempty"`
+	// CounterBox is where the model found the counter in the image it read,
+	// if asked with [WithCounterBox] and valid.
+	CounterBox *Box `json:"counter_box,omitempty"`
+	// ROI is the region of the captured image that was read, when it was
+	// cropped; CounterBox is then in the coordinates of the full image.
+	ROI *Box `json:"roi,omitempty"`
+	// VerifiedRead is the reading the model gave when asked to re-examine the
+	// image (see [WithSelfVerify]); FirstRead is its first answer, set only
+	// when the two differ. Read is the one accepted.
+	VerifiedRead string `json:"verified_read,omitempty"`
+	FirstRead    string `json:"first_read,omitempty"`
+	// Enhanced marks a reading of the image enhanced for a second attempt
+	// after the model could not read a single digit of the original.
+	Enhanced bool `json:"enhanced,omitempty"`
+	// Correction is set on a stored reading corrected by hand; Read is then
+	// the corrected value.
+	Correction *Correction `json:"correction,omitempty"`
+	// CrossCheck is the reading of the secondary recognizer of the image, if
+	// one is configured; see [GasMeterReadResult.SetCrossCheck].
+	CrossCheck *CrossCheck `json:"cross_check,omitempty"`
+	// Provenance is how the digits of Read were arrived at, e.g.
+	// [ProvenanceGuessed], and ReferenceID the ID of the reading they were
+	// read against, if any. Following the references back shows whether a
+	// guess rests on another guess; see [References].
+	Provenance  string `json:"provenance,omitempty"`
+	ReferenceID string `json:"reference_id,omitempty"`
+	// Warnings are the checks of the acceptance pipeline the reading failed
+	// without being rejected, e.g. "max_delta: increase of 12.000 over 5".
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Stale marks a repeat of the last known reading published while the API
+	// is unavailable; StaleSince is when that reading was taken. Consumers must
+	// not count consumption from stale readings. See [GasMeterReadResult.AsStale].
+	Stale      bool      `json:"stale,omitempty"`
+	StaleSince time.Time `json:"stale_since,omitzero"`
+	// GapBefore is the time since the previous stored reading, e.g. "48h0m0s",
+	// when it was longer than the gap threshold: the daemon or the camera was
+	// down. See [GasMeterReadResult.Gap].
+	GapBefore string `json:"gap_before,omitempty"`
+	// Downsampled is the resolution, e.g. "24h0m0s", the readings after this
+	// one up to the next stored were thinned out to by downsampling; the
+	// interval is then no gap. See package downsample.
+	Downsampled string `json:"downsampled,omitempty"`
+	// Maintenance marks a reading taken while the meter was in maintenance
+	// mode, e.g. being exchanged; consumption is not counted from it. See
+	// package maintenance.
+	Maintenance bool `json:"maintenance,omitempty"`
+	// MeterExchanged marks the first reading accepted after maintenance, or
+	// the start of the new meter when the exchange is recorded at once: the
+	// consumption up to it is counted on the new meter. See [Meter.DeltaTo].
+	MeterExchanged *Exchange `json:"meter_exchanged,omitempty"`
+
+	// Source is the name of the camera the image came from, when several
+	// are configured.
+	Source string `json:"source,omitempty"`
+
+	// UploadedFile is the display name of the image's Files API upload; only
+	// set in debug mode.
+	UploadedFile string `json:"uploaded_file,omitempty"`
+
+	// Model, PromptHash, ConfigHash and ReaderVersion attribute the reading
+	// to what produced it.
+	Model         string `json:"model,omitempty"`
+	PromptHash    string `json:"prompt_hash,omitempty"` // see [Prompts.Hash]
+	ConfigHash    string `json:"config_hash,omitempty"` // see [Snapshot.Hash]
+	ReaderVersion string `json:"reader_version,omitempty"`
+	// Route is the routing rule of the daemon that chose Model, if any, and
+	// Tags the tags its schedule attached to the reading's cycle.
+	Route string   `json:"route,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// ReadingID returns a stable ID for reading r of meterID: a hash of the meter,
+// the capture time (DateParsed, or ReadAt if the date was not recognized)
+// and the reading. Delivering or re-reading the same capture yields the same
+// ID, so consumers can use it as an idempotency key.
+func ReadingID(meterID string, r *GasMeterReadResult) string {
+	at := r.DateParsed
+	if at.IsZero() {
+		at = r.ReadAt
+	}
+	h := sha256.New()
+	for _, part := range []string{meterID, at.UTC().Format(time.RFC3339Nano), r.Read} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// Correction records a manual correction of a reading, e.g. after checking
+// the meter in person.
+type Correction struct {
+	// Original is the reading as read from the image, before any correction.
+	Original string `json:"original"`
+	// Previous is the reading this correction replaced: Original, or the
+	// value of an earlier correction.
+	Previous string    `json:"previous,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Exchange records the exchange of a meter at the first reading after it.
+type Exchange struct {
+	// Previous is the final reading of the old meter, if known.
+	Previous string `json:"previous,omitempty"`
+	// Start is the value the new meter started at; empty is zero.
+	Start string `json:"start,omitempty"`
+	// At is when the maintenance of the exchange began, or the exchange
+	// itself when recorded at once.
+	At time.Time `json:"at,omitzero"`
+	// Serial is the serial number of the new meter, if recorded.
+	Serial string `json:"serial,omitempty"`
+}
+
+// Correct marks r as corrected to read, with note, at at. The original
+// reading and the ID are kept across repeated corrections. Correcting a
+// reading to the value it was read as confirms it: its provenance is then
+// [ProvenanceConfirmed] rather than [ProvenanceCorrected].
+func (r *GasMeterReadResult) Correct(meterID, read, note string, at time.Time) {
+	if r.ID == "" {
+		r.ID = ReadingID(meterID, r)
+	}
+	orig := r.Read
+	if r.Correction != nil {
+		orig = r.Correction.Original
+	}
+	prev := r.Read
+	r.Read = read
+	r.Correction = &Correction{Original: orig, Previous: prev, Note: note, At: at}
+	r.Provenance = ProvenanceCorrected
+	if read == prev && read == orig {
+		r.Provenance = ProvenanceConfirmed
+	}
+}
+
+// Gap returns GapBefore, or 0 if r follows no gap.
+func (r *GasMeterReadResult) Gap() time.Duration {
+	d, _ := time.ParseDuration(r.GapBefore)
+	return d
+}
+
+// Counted reports whether consumption is counted from r: it is neither
+// stale nor taken during maintenance.
+func (r *GasMeterReadResult) Counted() bool {
+	return !r.Stale && !r.Maintenance
+}
+
+// AsStale returns a copy of r flagged as stale since its ReadAt. Slices are
+// shared with r.
+func (r *GasMeterReadResult) AsStale() *GasMeterReadResult {
+	out := *r
+	if !out.Stale {
+		out.Stale, out.StaleSince = true, r.ReadAt
+	}
+	return &out
 }
@@ -0,0 +1,176 @@
+package genaitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Interaction is one recorded HTTP exchange. Headers are not recorded, so
+// credentials sent in Authorization headers never reach a cassette.
+type Interaction struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"` // URL path and query, without scheme and host
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response"`
+}
+
+// Cassette is the on-disk form of a recording.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an [http.RoundTripper] that either records real HTTP
+// exchanges into a cassette file or replays them from it, in order.
+// Replay matches requests by method and path only; request bodies (which
+// carry images and prompts) are not compared.
+type Recorder struct {
+	path    string
+	record  bool
+	real    http.RoundTripper
+	secrets []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	next     int
+}
+
+// NewRecorder returns a Recorder for the cassette at path. In record mode
+// requests go to the network, and occurrences of secrets (API keys) are
+// replaced with "REDACTED" before anything is saved. Otherwise the cassette
+// is loaded and served without touching the network.
+func NewRecorder(path string, record bool, secrets ...string) (*Recorder, error) {
+	r := &Recorder{path: path, record: record, real: http.DefaultTransport}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	if record {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load cassette: %w", err)
+	}
+	if err := json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Client returns an HTTP client using r as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.record {
+		return r.recordTrip(req)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.cassette.Interactions) {
+		return nil, fmt.Errorf("cassette %s: unexpected request %d: %s %s", r.path, r.next, req.Method, req.URL.Path)
+	}
+	in := r.cassette.Interactions[r.next]
+	if got := r.scrub(requestPath(req.URL)); in.Method != req.Method || in.Path != got {
+		return nil, fmt.Errorf("cassette %s: request %d is %s %s, recorded %s %s", r.path, r.next, req.Method, got, in.Method, in.Path)
+	}
+	r.next++
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	return in.response(req), nil
+}
+
+func (r *Recorder) recordTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Method:      req.Method,
+		Path:        r.scrub(requestPath(req.URL)),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Response:    r.scrub(string(body)),
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Close writes the cassette in record mode. In replay mode it reports
+// recorded interactions that were never requested.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.record {
+		if left := len(r.cassette.Interactions) - r.next; left > 0 {
+			return fmt.Errorf("cassette %s: %d recorded interactions not replayed", r.path, left)
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(b, '\n'), 0o644)
+}
+
+func (r *Recorder) scrub(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "REDACTED")
+		// Query strings carry the key percent-encoded.
+		s = strings.ReplaceAll(s, url.QueryEscape(secret), "REDACTED")
+	}
+	return s
+}
+
+func requestPath(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + u.RawQuery
+}
+
+func (in Interaction) response(req *http.Request) *http.Response {
+	h := make(http.Header)
+	if in.ContentType != "" {
+		h.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(in.Response)),
+		ContentLength: int64(len(in.Response)),
+		Request:       req,
+	}
+}
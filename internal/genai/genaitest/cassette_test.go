@@ -0,0 +1,61 @@
+package genaitest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"echo":"`+r.URL.Query().Get("key")+`"}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "c.json")
+	rec, err := NewRecorder(path, true, "sk-secret")
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	resp, err := rec.Client().Get(srv.URL + "/v1/files?key=sk-secret")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	live, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(live), "sk-secret") || strings.Contains(string(raw), "sk-secret") {
+		t.Fatalf("live %s, cassette %s: secret must be scrubbed from the cassette only", live, raw)
+	}
+
+	rep, err := NewRecorder(path, false, "sk-secret")
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	resp, err = rep.Client().Get("http://replay.invalid/v1/files?key=sk-secret")
+	if err != nil {
+		t.Fatalf("replay Get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"echo":"REDACTED"}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("replayed %q", body)
+	}
+	if _, err := rep.Client().Get("http://replay.invalid/v1/files"); err == nil {
+		t.Fatal("replay beyond the cassette: want error")
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package genaitest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Clock is a manual [genai.Clock]. Time only moves with Advance, which
+// also fires the channels of pending After calls whose deadline has passed.
+// It is safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ genai.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock stopped at start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now implements [genai.Clock].
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements [genai.Clock].
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the After channels that are due,
+// earliest first.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+	n := 0
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			break
+		}
+		w.ch <- c.now
+		n++
+	}
+	c.waiters = c.waiters[n:]
+}
+
+// Waiters returns the number of pending After calls, so a test can wait for
+// a goroutine to block on the clock before advancing it.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,112 @@
+package genaitest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+func TestClockAdvance(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	a, b := c.After(2*time.Minute), c.After(time.Minute)
+
+	c.Advance(time.Minute)
+	select {
+	case got := <-b:
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Fatalf("fired at %v", got)
+		}
+	default:
+		t.Fatal("1m waiter did not fire")
+	}
+	select {
+	case <-a:
+		t.Fatal("2m waiter fired early")
+	default:
+	}
+	c.Advance(time.Minute)
+	<-a
+	if c.Waiters() != 0 || !c.Now().Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("waiters = %d, now = %v", c.Waiters(), c.Now())
+	}
+}
+
+func TestLimiterWithClock(t *testing.T) {
+	t.Parallel()
+
+	c := NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	l := genai.NewLimiterWithClock(time.Minute, c)
+	ctx := context.Background()
+
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	done := make(chan error)
+	go func() { done <- l.Wait(ctx) }()
+
+	for c.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("second Wait returned before the interval passed")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Advance(30 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("second Wait: %v", err)
+	}
+}
+
+func TestBreakerWithClock(t *testing.T) {
+	t.Parallel()
+
+	c := NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	b := genai.NewBreakerWithClock(2, time.Minute, c)
+	errDown := errors.New("503 service unavailable")
+
+	for i := range 2 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow %d: %v", i, err)
+		}
+		b.Done(errDown)
+	}
+	if b.State() != genai.BreakerOpen || b.Allow() != genai.ErrCircuitOpen {
+		t.Fatalf("state = %v after 2 failures, want open", b.State())
+	}
+
+	// A failed probe opens the breaker for another full period.
+	c.Advance(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if b.State() != genai.BreakerHalfOpen || b.Allow() != genai.ErrCircuitOpen {
+		t.Fatalf("state = %v during probe, want half-open with one call in flight", b.State())
+	}
+	b.Done(errDown)
+	c.Advance(30 * time.Second)
+	if b.Allow() != genai.ErrCircuitOpen {
+		t.Fatal("breaker let a call through right after a failed probe")
+	}
+
+	// A successful probe closes it fully: one more failure does not reopen it.
+	c.Advance(30 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("second probe: %v", err)
+	}
+	b.Done(nil)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow after recovery: %v", err)
+	}
+	b.Done(errDown)
+	if b.State() != genai.BreakerClosed || b.Trips() != 2 {
+		t.Fatalf("state = %v, trips = %d; want closed, 2", b.State(), b.Trips())
+	}
+}
@@ -0,0 +1,42 @@
+package genaitest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+// memorySink is the kind of consumer under test: it stores every successful reading.
+type memorySink struct {
+	reads []string
+}
+
+func (s *memorySink) consume(ctx context.Context, c genai.VisionClient, jpg string) error {
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader(jpg))
+	if err != nil {
+		return err
+	}
+	s.reads = append(s.reads, res.Read)
+	return nil
+}
+
+func ExampleFakeReader() {
+	fake := genaitest.NewMonotonicFake(2924.457, 0.5)
+	fake.PushError(fmt.Errorf("model unavailable"))
+
+	sink := &memorySink{}
+	for range 3 {
+		if err := sink.consume(context.Background(), fake, "jpeg"); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+	fmt.Println(sink.reads)
+	fmt.Println(len(fake.Calls()), "calls")
+	// Output:
+	// error: model unavailable
+	// [02924.457 02924.957]
+	// 3 calls
+}
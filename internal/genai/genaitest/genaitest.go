@@ -0,0 +1,175 @@
+// Package genaitest provides a scriptable [genai.VisionClient] for tests of
+// code built on top of the vision clients.
+package genaitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// ErrNoResponse is returned when a FakeReader has run out of scripted responses.
+var ErrNoResponse = errors.New("genaitest: no scripted response")
+
+// Call records one read request received by a FakeReader.
+type Call struct {
+	Image    []byte    // JPEG passed to ReadGasGaugePic; nil for URL reads
+	URL      string    // URL passed to ReadGasGaugePicFromURL
+	Deadline time.Time // context deadline; zero if none
+}
+
+// response is one scripted answer.
+type response struct {
+	res *genai.GasMeterReadResult
+	err error
+}
+
+// FakeReader implements [genai.VisionClient] by answering from a queue of
+// scripted results and errors. Once the queue is empty it falls back to
+// Generate when set, and fails with [ErrNoResponse] otherwise.
+// It is safe for concurrent use.
+type FakeReader struct {
+	// Generate produces the n-th (0-based) answer not taken from the queue.
+	Generate func(n int) (*genai.GasMeterReadResult, error)
+	// Clock stamps ReadAt on results that have none; nil uses [genai.RealClock].
+	Clock genai.Clock
+
+	mu        sync.Mutex
+	queue     []response
+	calls     []Call
+	generated int
+	closed    bool
+}
+
+var _ genai.VisionClient = (*FakeReader)(nil)
+
+// NewFakeReader returns a FakeReader answering with results in order.
+func NewFakeReader(results ...*genai.GasMeterReadResult) *FakeReader {
+	f := &FakeReader{}
+	for _, r := range results {
+		f.Push(r)
+	}
+	return f
+}
+
+// NewMonotonicFake returns a FakeReader producing plausible, increasing
+// readings of [genai.DefaultMeter]: start, start+step, start+2*step, ...
+func NewMonotonicFake(start, step float64) *FakeReader {
+	m := genai.DefaultMeter
+	return &FakeReader{
+		Generate: func(n int) (*genai.GasMeterReadResult, error) {
+			return &genai.GasMeterReadResult{Read: m.FormatRead(start + float64(n)*step)}, nil
+		},
+	}
+}
+
+// Push queues a successful result.
+func (f *FakeReader) Push(res *genai.GasMeterReadResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append(f.queue, response{res: res})
+}
+
+// PushError queues a failed read.
+func (f *FakeReader) PushError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queue = append(f.queue, response{err: err})
+}
+
+// Calls returns the requests received so far.
+func (f *FakeReader) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Closed reports whether Close has been called.
+func (f *FakeReader) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// ReadGasGaugePic implements [genai.VisionClient].
+func (f *FakeReader) ReadGasGaugePic(ctx context.Context, jpgReader io.Reader) (*genai.GasMeterReadResult, error) {
+	jpg, err := io.ReadAll(jpgReader)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	return f.answer(ctx, Call{Image: jpg})
+}
+
+// ReadGasGaugePicFromURL implements [genai.VisionClient].
+func (f *FakeReader) ReadGasGaugePicFromURL(ctx context.Context, imageURL string) (*genai.GasMeterReadResult, error) {
+	return f.answer(ctx, Call{URL: imageURL})
+}
+
+// Close implements [genai.VisionClient].
+func (f *FakeReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *FakeReader) answer(ctx context.Context, call Call) (*genai.GasMeterReadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	call.Deadline, _ = ctx.Deadline()
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	var (
+		res *genai.GasMeterReadResult
+		err error
+	)
+	switch {
+	case len(f.queue) > 0:
+		res, err = f.queue[0].res, f.queue[0].err
+		f.queue = f.queue[1:]
+	case f.Generate != nil:
+		gen, n := f.Generate, f.generated
+		f.generated++
+		f.mu.Unlock()
+		res, err = gen(n)
+		f.mu.Lock()
+	default:
+		err = ErrNoResponse
+	}
+	f.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	// Hand out a copy so callers may modify the result.
+	out := *res
+	if out.ReadAt.IsZero() {
+		clock := f.Clock
+		if clock == nil {
+			clock = genai.RealClock
+		}
+		out.ReadAt = clock.Now()
+	}
+	if out.Model == "" {
+		out.Model = "fake"
+	}
+	out.ReaderVersion = genai.Version
+	return &out, nil
+}
+
+// NormalizeTiming clears the fields of res that depend on wall-clock time,
+// so results of recorded or scripted runs compare equal across runs.
+func NormalizeTiming(res *genai.GasMeterReadResult) *genai.GasMeterReadResult {
+	if res != nil {
+		res.ReadAt = time.Time{}
+		res.ItTakes = ""
+	}
+	return res
+}
@@ -0,0 +1,51 @@
+package genaitest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+func TestFakeReaderQueue(t *testing.T) {
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+	f := NewFakeReader(&genai.GasMeterReadResult{Read: "00001.000"})
+	f.PushError(errBoom)
+
+	ctx := context.Background()
+	res, err := f.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil || res.Read != "00001.000" {
+		t.Fatalf("first = %v, %v", res, err)
+	}
+	if _, err := f.ReadGasGaugePicFromURL(ctx, "https://example.com/a.jpg"); !errors.Is(err, errBoom) {
+		t.Fatalf("second err = %v, want %v", err, errBoom)
+	}
+	if _, err := f.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("third err = %v, want %v", err, ErrNoResponse)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 3 || string(calls[0].Image) != "jpeg" || calls[1].URL != "https://example.com/a.jpg" {
+		t.Fatalf("calls = %#v", calls)
+	}
+}
+
+func TestNewMonotonicFake(t *testing.T) {
+	t.Parallel()
+
+	f := NewMonotonicFake(2924.457, 0.25)
+	want := []string{"02924.457", "02924.707", "02924.957"}
+	for i, w := range want {
+		res, err := f.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if res.Read != w {
+			t.Fatalf("call %d: Read = %q, want %q", i, res.Read, w)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package genaitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Golden is the expected outcome for one sample image, stored as a JSON file
+// next to the image (sample/ok.jpg → sample/ok.json).
+type Golden struct {
+	Image string `json:"-"` // path of the JPEG
+
+	Read string `json:"read,omitempty"`
+	// Tolerance accepts readings within ±Tolerance of Read, for samples whose
+	// last digit is legitimately uncertain.
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// Unreadable marks images with no visible counter; the reader should fail
+	// or return an empty reading rather than invent one.
+	Unreadable bool   `json:"unreadable,omitempty"`
+	Note       string `json:"note,omitempty"`
+}
+
+// LoadGoldens returns the golden cases for every *.jpg in dir that has a
+// matching *.json file, sorted by image name.
+func LoadGoldens(dir string) ([]Golden, error) {
+	imgs, err := filepath.Glob(filepath.Join(dir, "*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(imgs)
+
+	var out []Golden
+	for _, img := range imgs {
+		b, err := os.ReadFile(strings.TrimSuffix(img, ".jpg") + ".json")
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		g := Golden{Image: img}
+		if err := json.Unmarshal(b, &g); err != nil {
+			return nil, fmt.Errorf("parse golden for %s: %w", img, err)
+		}
+		if g.Read == "" && !g.Unreadable {
+			return nil, fmt.Errorf("golden for %s: read is empty", img)
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// Match reports whether a reader's outcome satisfies g.
+func (g Golden) Match(res *genai.GasMeterReadResult, err error) bool {
+	if g.Unreadable {
+		return err != nil || res.Read == ""
+	}
+	if err != nil {
+		return false
+	}
+	if res.Read == g.Read {
+		return true
+	}
+	want, werr := strconv.ParseFloat(g.Read, 64)
+	got, gerr := strconv.ParseFloat(res.Read, 64)
+	if werr != nil || gerr != nil || len(res.Read) != len(g.Read) {
+		return false
+	}
+	// Allow for float rounding at the tolerance boundary.
+	return math.Abs(got-want) <= g.Tolerance+1e-9
+}
+
+// GoldenOutcome is the result of one golden case.
+type GoldenOutcome struct {
+	Image string `json:"image"`
+	Want  string `json:"want"`
+	Got   string `json:"got,omitempty"`
+	Error string `json:"error,omitempty"`
+	Pass  bool   `json:"pass"`
+}
+
+// GoldenSummary aggregates a golden run.
+type GoldenSummary struct {
+	Model      string          `json:"model,omitempty"`
+	PromptHash string          `json:"prompt_hash,omitempty"`
+	Cases      int             `json:"cases"`
+	Passed     int             `json:"passed"`
+	Accuracy   float64         `json:"accuracy"` // Passed / Cases
+	Outcomes   []GoldenOutcome `json:"outcomes"`
+}
+
+// RunGoldens reads every golden image with c. Timing fields are ignored.
+func RunGoldens(ctx context.Context, c genai.VisionClient, goldens []Golden) (*GoldenSummary, error) {
+	s := &GoldenSummary{Cases: len(goldens)}
+	for _, g := range goldens {
+		f, err := os.Open(g.Image)
+		if err != nil {
+			return nil, err
+		}
+		res, rerr := c.ReadGasGaugePic(ctx, f)
+		f.Close()
+
+		o := GoldenOutcome{Image: filepath.Base(g.Image), Want: g.Read, Pass: g.Match(res, rerr)}
+		if g.Unreadable {
+			o.Want = "(unreadable)"
+		}
+		if rerr != nil {
+			o.Error = rerr.Error()
+		} else {
+			o.Got = res.Read
+			s.Model, s.PromptHash = res.Model, res.PromptHash
+		}
+		if o.Pass {
+			s.Passed++
+		}
+		s.Outcomes = append(s.Outcomes, o)
+	}
+	if s.Cases > 0 {
+		s.Accuracy = float64(s.Passed) / float64(s.Cases)
+	}
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package genaitest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+func TestGoldenMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		g    Golden
+		read string
+		err  error
+		want bool
+	}{
+		{Golden{Read: "02924.744"}, "02924.744", nil, true},
+		{Golden{Read: "02924.744"}, "02924.745", nil, false},
+		{Golden{Read: "02925.945", Tolerance: 0.001}, "02925.946", nil, true},
+		{Golden{Read: "02925.945", Tolerance: 0.001}, "02925.947", nil, false},
+		{Golden{Read: "02925.945", Tolerance: 0.001}, "2925.946", nil, false},
+		{Golden{Read: "02924.744"}, "", errors.New("boom"), false},
+		{Golden{Unreadable: true}, "", errors.New("boom"), true},
+		{Golden{Unreadable: true}, "", nil, true},
+		{Golden{Unreadable: true}, "00000.000", nil, false},
+	}
+	for _, tt := range tests {
+		var res *genai.GasMeterReadResult
+		if tt.err == nil {
+			res = &genai.GasMeterReadResult{Read: tt.read}
+		}
+		if got := tt.g.Match(res, tt.err); got != tt.want {
+			t.Fatalf("%+v.Match(%q, %v) = %v, want %v", tt.g, tt.read, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunGoldensOnSamples(t *testing.T) {
+	t.Parallel()
+
+	goldens, err := LoadGoldens("../../../sample")
+	if err != nil {
+		t.Fatalf("LoadGoldens: %v", err)
+	}
+	if len(goldens) == 0 {
+		t.Fatal("no golden samples")
+	}
+
+	// Answer every sample with its own golden reading.
+	f := &FakeReader{}
+	for _, g := range goldens {
+		f.Push(&genai.GasMeterReadResult{Read: g.Read})
+	}
+	s, err := RunGoldens(context.Background(), f, goldens)
+	if err != nil {
+		t.Fatalf("RunGoldens: %v", err)
+	}
+	if s.Passed != s.Cases || s.Accuracy != 1 {
+		t.Fatalf("summary = %+v", s)
+	}
+}
@@ -0,0 +1,69 @@
+package genaitest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+func TestChainOrder(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	tag := func(name string) genai.Middleware {
+		return genai.Around(func(ctx context.Context, next func(context.Context) (*genai.GasMeterReadResult, error)) (*genai.GasMeterReadResult, error) {
+			order = append(order, name)
+			return next(ctx)
+		})
+	}
+	f := NewFakeReader(&genai.GasMeterReadResult{Read: "00001.000"})
+	c := genai.Chain(f, tag("outer"), tag("inner"))
+
+	if _, err := c.ReadGasGaugePicFromURL(context.Background(), "https://example.com/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Fatalf("order = %v", order)
+	}
+	if calls := f.Calls(); len(calls) != 1 || calls[0].URL != "https://example.com/a.jpg" {
+		t.Fatalf("calls = %#v", calls)
+	}
+	if err := c.Close(); err != nil || !f.Closed() {
+		t.Fatalf("Close = %v, closed = %v", err, f.Closed())
+	}
+}
+
+func TestBreakerMiddleware(t *testing.T) {
+	t.Parallel()
+
+	clock := NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	b := genai.NewBreakerWithClock(1, time.Minute, clock)
+	errDown := errors.New("503 service unavailable")
+	f := NewFakeReader()
+	f.PushError(errDown)
+	f.Push(&genai.GasMeterReadResult{Read: "00001.000"})
+	c := genai.Chain(f, genai.BreakerMiddleware(b))
+	ctx := context.Background()
+
+	if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, errDown) {
+		t.Fatalf("err = %v, want %v", err, errDown)
+	}
+	if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, genai.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if n := len(f.Calls()); n != 1 {
+		t.Fatalf("calls = %d, want 1 (none while open)", n)
+	}
+
+	clock.Advance(time.Minute)
+	if res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil || res.Read != "00001.000" {
+		t.Fatalf("probe = %v, %v", res, err)
+	}
+	if b.State() != genai.BreakerClosed {
+		t.Fatalf("breaker = %v after a successful probe", b.State())
+	}
+}
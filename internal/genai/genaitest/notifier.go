@@ -0,0 +1,50 @@
+package genaitest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/suapapa/mqvision/internal/notify"
+)
+
+// Notifier is a [notify.Notifier] recording the events it receives, for
+// assertions. Err, if set, is returned for every event, which is recorded
+// all the same. It is safe for concurrent use.
+type Notifier struct {
+	Err error
+
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+var _ notify.Notifier = (*Notifier)(nil)
+
+// Notify implements [notify.Notifier].
+func (n *Notifier) Notify(ctx context.Context, e notify.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+	return n.Err
+}
+
+// Events returns the events received so far.
+func (n *Notifier) Events() []notify.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]notify.Event(nil), n.events...)
+}
+
+// Kinds returns the kinds of the events received so far, in order.
+func (n *Notifier) Kinds() []string {
+	var kinds []string
+	for _, e := range n.Events() {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+// Factory returns a [notify.Factory] building n whatever the options, to
+// register n for code that builds its notifiers from a config.
+func (n *Notifier) Factory() notify.Factory {
+	return func(notify.Options) (notify.Notifier, error) { return n, nil }
+}
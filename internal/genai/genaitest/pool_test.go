@@ -0,0 +1,119 @@
+package genaitest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// poolFactory creates FakeReaders and records them by "apiKey/model".
+type poolFactory struct {
+	mu      sync.Mutex
+	created map[string][]*FakeReader
+}
+
+func (f *poolFactory) New(_ context.Context, apiKey, model string) (genai.VisionClient, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.created == nil {
+		f.created = make(map[string][]*FakeReader)
+	}
+	r := NewFakeReader()
+	key := apiKey + "/" + model
+	f.created[key] = append(f.created[key], r)
+	return r, nil
+}
+
+func (f *poolFactory) get(key string) []*FakeReader {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created[key]
+}
+
+func TestClientPoolConcurrentGet(t *testing.T) {
+	t.Parallel()
+
+	f := &poolFactory{}
+	p := genai.NewClientPool(f.New, 0, 0)
+	var wg sync.WaitGroup
+	for i := range 30 {
+		wg.Go(func() {
+			c, err := p.Get(context.Background(), fmt.Sprintf("key-%d", i%3), "model")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			c.Close()
+		})
+	}
+	wg.Wait()
+
+	for i := range 3 {
+		if n := len(f.get(fmt.Sprintf("key-%d/model", i))); n != 1 {
+			t.Fatalf("key-%d: %d clients created, want 1", i, n)
+		}
+	}
+	if s := p.Stats(); s.Size != 3 || s.InUse != 0 || s.Creates != 3 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if !f.get(fmt.Sprintf("key-%d/model", i))[0].Closed() {
+			t.Fatalf("key-%d: not closed with the pool", i)
+		}
+	}
+	if _, err := p.Get(context.Background(), "key-0", "model"); err != genai.ErrPoolClosed {
+		t.Fatalf("Get after Close: err = %v", err)
+	}
+}
+
+func TestClientPoolEviction(t *testing.T) {
+	t.Parallel()
+
+	clock := NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	f := &poolFactory{}
+	p := genai.NewClientPoolWithClock(f.New, 2, time.Hour, clock)
+	ctx := context.Background()
+	get := func(key, model string) genai.VisionClient {
+		t.Helper()
+		c, err := p.Get(ctx, key, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	held := get("a", "model") // in use across the evictions below
+	clock.Advance(time.Minute)
+	get("b", "model").Close()
+	get("b", "other").Close() // full: evicts the least recently used, a, once released
+	if a := f.get("a/model")[0]; a.Closed() {
+		t.Fatal("a closed while in use")
+	}
+	held.Close()
+	held.Close() // releasing twice is harmless
+	if a := f.get("a/model")[0]; !a.Closed() {
+		t.Fatal("evicted a not closed by its release")
+	}
+
+	clock.Advance(time.Hour)
+	if n := p.EvictIdle(); n != 2 {
+		t.Fatalf("EvictIdle = %d, want 2", n)
+	}
+	if !f.get("b/model")[0].Closed() || !f.get("b/other")[0].Closed() {
+		t.Fatal("idle clients not closed")
+	}
+	get("b", "model").Close()
+	if n := len(f.get("b/model")); n != 2 {
+		t.Fatalf("b/model created %d times, want 2 (recreated after eviction)", n)
+	}
+	if s := p.Stats(); s.Size != 1 || s.SizeEvictions != 1 || s.IdleEvictions != 2 || s.Creates != 4 {
+		t.Fatalf("stats = %+v", s)
+	}
+}
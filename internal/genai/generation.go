@@ -0,0 +1,124 @@
+package genai
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Reasoning efforts of OpenAI reasoning models, see [Generation].
+const (
+	EffortMinimal = "minimal"
+	EffortLow     = "low"
+	EffortMedium  = "medium"
+	EffortHigh    = "high"
+)
+
+// DefaultMaxOutputTokens bounds the answer of a call, the JSON of a reading
+// with room to spare, when [Generation.MaxOutputTokens] is not set; what a
+// model may think is added to it.
+const DefaultMaxOutputTokens = 1024
+
+// truncationRaise multiplies the MaxOutputTokens of a reading cut off by it
+// for its one retry, see [Options.GenerateWithin].
+const truncationRaise = 4
+
+// Generation bounds what a model generates for a call, most of the latency
+// of a reading: the reasoning of a model that thinks is thrown away, and
+// the answer is a short JSON object. Zero fields take the defaults of the
+// model, see [Generation.For]; backends send what their API has.
+type Generation struct {
+	// ThinkingBudget caps the thinking tokens of Gemini models that think:
+	// 0 turns thinking off where the model allows it, -1 lets the model
+	// decide. Nil is the default of the model.
+	ThinkingBudget *int
+	// ReasoningEffort is the reasoning_effort of OpenAI reasoning models,
+	// one of the Effort constants.
+	ReasoningEffort string
+	// MaxOutputTokens caps the output of a call, thinking included; -1 is
+	// no limit.
+	MaxOutputTokens int
+}
+
+// Validate checks g.
+func (g Generation) Validate() error {
+	if g.ThinkingBudget != nil && *g.ThinkingBudget < -1 {
+		return fmt.Errorf("thinking budget %d, want -1 or more", *g.ThinkingBudget)
+	}
+	switch g.ReasoningEffort {
+	case "", EffortMinimal, EffortLow, EffortMedium, EffortHigh:
+	default:
+		return fmt.Errorf("unknown reasoning effort %q, want %s, %s, %s or %s", g.ReasoningEffort, EffortMinimal, EffortLow, EffortMedium, EffortHigh)
+	}
+	if g.MaxOutputTokens < -1 {
+		return fmt.Errorf("max output tokens %d, want -1 or more", g.MaxOutputTokens)
+	}
+	return nil
+}
+
+// WithGeneration bounds the generation of every call by g.
+func WithGeneration(g Generation) Option {
+	return func(o *Options) {
+		o.Generation = g
+	}
+}
+
+// reasoningTokens are what a reasoning model is given to think at each
+// effort, on top of the answer.
+var reasoningTokens = map[string]int{EffortMinimal: 1024, EffortLow: 4096, EffortMedium: 8192, EffortHigh: 16384}
+
+// For returns g with the defaults of model filled in: the least thinking
+// Gemini 2.5 models allow (none but for Pro, which needs 128 tokens), the
+// least reasoning effort of OpenAI reasoning models, and MaxOutputTokens
+// of [DefaultMaxOutputTokens] plus what the model may think. A
+// MaxOutputTokens that is not positive is no limit.
+func (g Generation) For(model string) Generation {
+	name := strings.ToLower(model[strings.LastIndex(model, "/")+1:])
+	if g.ThinkingBudget == nil {
+		switch {
+		case strings.HasPrefix(name, "gemini-2.5-pro"):
+			g.ThinkingBudget = ptr(128)
+		case strings.HasPrefix(name, "gemini-2.5"):
+			g.ThinkingBudget = ptr(0)
+		}
+	}
+	if g.ReasoningEffort == "" {
+		switch {
+		case strings.HasPrefix(name, "gpt-5"):
+			g.ReasoningEffort = EffortMinimal
+		case len(name) > 1 && name[0] == 'o' && name[1] >= '1' && name[1] <= '9':
+			g.ReasoningEffort = EffortLow // o1, o3, o4-mini, ...
+		}
+	}
+	if g.MaxOutputTokens == 0 {
+		g.MaxOutputTokens = DefaultMaxOutputTokens
+		switch b := g.ThinkingBudget; {
+		case b != nil && *b < 0:
+			g.MaxOutputTokens = -1 // as much as the model thinks
+		case b != nil:
+			g.MaxOutputTokens += *b
+		}
+		g.MaxOutputTokens += reasoningTokens[g.ReasoningEffort]
+	}
+	return g
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// GenerateWithin calls generate, a model call with model, with the
+// [Generation] of model. Output cut off by MaxOutputTokens, which
+// [CheckOutput] reports as [ErrTruncatedOutput], is generated once more
+// with a limit four times as high.
+func (o *Options) GenerateWithin(model string, generate func(g Generation) (*GasMeterReadResult, error)) (*GasMeterReadResult, error) {
+	g := o.Generation.For(model)
+	out, err := generate(g)
+	if errors.Is(err, ErrTruncatedOutput) && g.MaxOutputTokens > 0 {
+		log.Printf("Output of %s cut off at %d tokens, retrying with %d", model, g.MaxOutputTokens, g.MaxOutputTokens*truncationRaise)
+		g.MaxOutputTokens *= truncationRaise
+		out, err = generate(g)
+	}
+	return out, err
+}
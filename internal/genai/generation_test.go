@@ -0,0 +1,79 @@
+package genai
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerationFor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		model string
+		g     Generation
+		want  Generation
+	}{
+		{"gemini-2.5-flash", Generation{}, Generation{ThinkingBudget: ptr(0), MaxOutputTokens: 1024}},
+		{"models/gemini-2.5-pro", Generation{}, Generation{ThinkingBudget: ptr(128), MaxOutputTokens: 1152}},
+		{"gemini-2.0-flash", Generation{}, Generation{MaxOutputTokens: 1024}},
+		{"gemini-2.5-flash", Generation{ThinkingBudget: ptr(-1)}, Generation{ThinkingBudget: ptr(-1), MaxOutputTokens: -1}},
+		{"gemini-2.5-flash", Generation{ThinkingBudget: ptr(512), MaxOutputTokens: 700}, Generation{ThinkingBudget: ptr(512), MaxOutputTokens: 700}},
+		{"gpt-5-mini", Generation{}, Generation{ReasoningEffort: EffortMinimal, MaxOutputTokens: 2048}},
+		{"o4-mini", Generation{}, Generation{ReasoningEffort: EffortLow, MaxOutputTokens: 5120}},
+		{"o3", Generation{ReasoningEffort: EffortHigh}, Generation{ReasoningEffort: EffortHigh, MaxOutputTokens: 17408}},
+		{"gpt-4o", Generation{}, Generation{MaxOutputTokens: 1024}},
+		{"gpt-4o", Generation{MaxOutputTokens: -1}, Generation{MaxOutputTokens: -1}},
+	}
+	for _, tt := range tests {
+		got := tt.g.For(tt.model)
+		if got.ReasoningEffort != tt.want.ReasoningEffort || got.MaxOutputTokens != tt.want.MaxOutputTokens ||
+			(got.ThinkingBudget == nil) != (tt.want.ThinkingBudget == nil) ||
+			got.ThinkingBudget != nil && *got.ThinkingBudget != *tt.want.ThinkingBudget {
+			t.Errorf("%+v.For(%q) = %+v, want %+v", tt.g, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestGenerationValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		g    Generation
+		fail bool
+	}{
+		{Generation{}, false},
+		{Generation{ThinkingBudget: ptr(-1), ReasoningEffort: EffortMedium, MaxOutputTokens: -1}, false},
+		{Generation{ThinkingBudget: ptr(-2)}, true},
+		{Generation{ReasoningEffort: "none"}, true},
+		{Generation{MaxOutputTokens: -5}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.g.Validate(); (err != nil) != tt.fail {
+			t.Errorf("%+v.Validate() = %v, want failure %v", tt.g, err, tt.fail)
+		}
+	}
+}
+
+func TestGenerateWithin(t *testing.T) {
+	t.Parallel()
+
+	o := NewOptions()
+	var limits []int
+	truncated := func(g Generation) (*GasMeterReadResult, error) {
+		limits = append(limits, g.MaxOutputTokens)
+		return nil, CheckOutput(o.Meter, nil, "length", errors.New("unexpected end of JSON input"))
+	}
+	if _, err := o.GenerateWithin("gpt-4o", truncated); !errors.Is(err, ErrTruncatedOutput) {
+		t.Fatalf("err = %v, want ErrTruncatedOutput", err)
+	}
+	if len(limits) != 2 || limits[0] != 1024 || limits[1] != 4096 {
+		t.Fatalf("limits %v, want one retry with four times the limit", limits)
+	}
+
+	// Without a limit there is nothing to raise.
+	limits = nil
+	o = NewOptions(WithGeneration(Generation{MaxOutputTokens: -1}))
+	if _, err := o.GenerateWithin("gpt-4o", truncated); !errors.Is(err, ErrTruncatedOutput) || len(limits) != 1 {
+		t.Fatalf("err = %v after %d calls, want ErrTruncatedOutput after one", err, len(limits))
+	}
+}
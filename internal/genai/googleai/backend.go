@@ -0,0 +1,324 @@
+package googleai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	ggenai "google.golang.org/genai"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// imageRef identifies an image uploaded to the Files API.
+type imageRef struct {
+	URI      string
+	MIMEType string
+}
+
+// exampleTurn is a few-shot example: the model is shown Image and answers Answer.
+type exampleTurn struct {
+	Image  imageRef
+	Answer string
+}
+
+// readingPrompts are the prompts of one reading call. With Verify set, the
+// conversation goes on after User with the model's first answer, Answer,
+// and the question to re-examine it. With Cache set, System and Examples are
+// those of the cached content it names and are not sent.
+type readingPrompts struct {
+	System   string
+	Examples []exampleTurn
+	User     string
+	Answer   string
+	Verify   string
+	Cache    string
+}
+
+// genConfig holds per-call generation settings.
+type genConfig struct {
+	Model          string
+	Temperature    float32
+	TopK           float32
+	ResponseSchema map[string]any // nil for free-text answers
+	Gen            genai.Generation
+}
+
+// reply is the raw model answer of one call.
+type reply struct {
+	Text         string
+	Usage        genai.Usage
+	FinishReason string
+}
+
+// generator is the model-facing side of [Client].
+type generator interface {
+	// GenerateReading asks for a reading of img and parses the answer.
+	// The reply is returned even when parsing fails.
+	GenerateReading(ctx context.Context, img imageRef, p readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error)
+	// GenerateText asks a free-text question.
+	GenerateText(ctx context.Context, prompt string, cfg genConfig) (reply, error)
+	// GenerateImages asks a question about imgs, in order.
+	GenerateImages(ctx context.Context, imgs []imageRef, prompt string, cfg genConfig) (reply, error)
+}
+
+// cachedContent is content held by the API's context cache.
+type cachedContent struct {
+	Name      string
+	ExpiresAt time.Time // zero if unknown
+}
+
+// contextCache is the context caching side of [Client], implemented by
+// generators whose API supports it.
+type contextCache interface {
+	// CreateCache caches the System and Examples of p for model.
+	CreateCache(ctx context.Context, model, displayName string, p readingPrompts, ttl time.Duration) (cachedContent, error)
+	// RefreshCache makes cache name expire ttl from now.
+	RefreshCache(ctx context.Context, name string, ttl time.Duration) (cachedContent, error)
+	DeleteCache(ctx context.Context, name string) error
+}
+
+// uploadedFile is a file held by the Files API.
+type uploadedFile struct {
+	Name        string
+	DisplayName string
+	URI         string
+	CreatedAt   time.Time // zero if unknown
+	ExpiresAt   time.Time // zero if unknown
+}
+
+// fileStore is the upload side of [Client].
+type fileStore interface {
+	Upload(ctx context.Context, r io.Reader, mimeType, displayName string) (uploadedFile, error)
+	Delete(ctx context.Context, name string) error
+	// List returns every file of the API key, including other clients' files.
+	List(ctx context.Context) ([]uploadedFile, error)
+}
+
+// genkitGenerator implements [generator] with Genkit's Google AI plugin, and
+// [contextCache] with the GenAI API, which also runs the calls on cached
+// content as Genkit cannot.
+type genkitGenerator struct {
+	g *genkit.Genkit
+	c *ggenai.Client
+}
+
+func (gg *genkitGenerator) GenerateReading(ctx context.Context, img imageRef, p readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error) {
+	if p.Cache != "" {
+		rep, err := gg.generateCached(ctx, img, p, cfg)
+		if err != nil {
+			return nil, rep, err
+		}
+		out, err := genai.ParseReadResult(rep.Text)
+		return out, rep, err
+	}
+	msgs := []*ai.Message{
+		ai.NewSystemMessage(
+			// ai.NewMediaPart("image/jpeg", fileSample.URI), // system prompt denies to use image
+			ai.NewTextPart(p.System),
+		),
+	}
+	for _, e := range p.Examples {
+		msgs = append(msgs,
+			ai.NewUserMessage(ai.NewMediaPart(e.Image.MIMEType, e.Image.URI)),
+			ai.NewModelTextMessage(e.Answer),
+		)
+	}
+	msgs = append(msgs, ai.NewUserMessage(
+		ai.NewMediaPart(img.MIMEType, img.URI),
+		ai.NewTextPart(p.User),
+	))
+	if p.Verify != "" {
+		msgs = append(msgs, ai.NewModelTextMessage(p.Answer), ai.NewUserTextMessage(p.Verify))
+	}
+
+	rep, err := gg.generate(ctx, msgs, cfg)
+	if err != nil {
+		return nil, rep, err
+	}
+	out, err := genai.ParseReadResult(rep.Text)
+	return out, rep, err
+}
+
+func (gg *genkitGenerator) GenerateText(ctx context.Context, prompt string, cfg genConfig) (reply, error) {
+	return gg.generate(ctx, []*ai.Message{ai.NewUserMessage(ai.NewTextPart(prompt))}, cfg)
+}
+
+func (gg *genkitGenerator) GenerateImages(ctx context.Context, imgs []imageRef, prompt string, cfg genConfig) (reply, error) {
+	var parts []*ai.Part
+	for _, img := range imgs {
+		parts = append(parts, ai.NewMediaPart(img.MIMEType, img.URI))
+	}
+	return gg.generate(ctx, []*ai.Message{ai.NewUserMessage(append(parts, ai.NewTextPart(prompt))...)}, cfg)
+}
+
+func (gg *genkitGenerator) generate(ctx context.Context, msgs []*ai.Message, cfg genConfig) (reply, error) {
+	resp, err := genkit.Generate(ctx, gg.g,
+		ai.WithModelName(cfg.Model),
+		ai.WithMessages(msgs...),
+		ai.WithConfig(contentConfig(cfg)),
+	)
+	if err != nil {
+		return reply{}, err
+	}
+	rep := reply{
+		Text:         resp.Text(),
+		FinishReason: string(resp.FinishReason),
+	}
+	if resp.Usage != nil {
+		u := resp.Usage
+		rep.Usage = genai.Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens + u.ThoughtsTokens, CachedTokens: u.CachedContentTokens, ThinkingTokens: u.ThoughtsTokens}
+	}
+	return rep, nil
+}
+
+// generateCached runs the reading call p on its cached content.
+func (gg *genkitGenerator) generateCached(ctx context.Context, img imageRef, p readingPrompts, cfg genConfig) (reply, error) {
+	contents := []*ggenai.Content{{Role: ggenai.RoleUser, Parts: []*ggenai.Part{
+		{FileData: &ggenai.FileData{FileURI: img.URI, MIMEType: img.MIMEType}},
+		{Text: p.User},
+	}}}
+	if p.Verify != "" {
+		contents = append(contents,
+			&ggenai.Content{Role: ggenai.RoleModel, Parts: []*ggenai.Part{{Text: p.Answer}}},
+			&ggenai.Content{Role: ggenai.RoleUser, Parts: []*ggenai.Part{{Text: p.Verify}}},
+		)
+	}
+	gcfg := contentConfig(cfg)
+	gcfg.CachedContent = p.Cache
+	resp, err := gg.c.Models.GenerateContent(ctx, apiModel(cfg.Model), contents, gcfg)
+	if err != nil {
+		return reply{}, err
+	}
+	rep := reply{Text: resp.Text()}
+	if len(resp.Candidates) > 0 {
+		rep.FinishReason = finishReason(resp.Candidates[0].FinishReason)
+	}
+	if u := resp.UsageMetadata; u != nil {
+		rep.Usage = genai.Usage{InputTokens: int(u.PromptTokenCount), OutputTokens: int(u.CandidatesTokenCount + u.ThoughtsTokenCount),
+			CachedTokens: int(u.CachedContentTokenCount), ThinkingTokens: int(u.ThoughtsTokenCount)}
+	}
+	return rep, nil
+}
+
+func (gg *genkitGenerator) CreateCache(ctx context.Context, model, displayName string, p readingPrompts, ttl time.Duration) (cachedContent, error) {
+	var contents []*ggenai.Content
+	for _, e := range p.Examples {
+		contents = append(contents,
+			&ggenai.Content{Role: ggenai.RoleUser, Parts: []*ggenai.Part{{FileData: &ggenai.FileData{FileURI: e.Image.URI, MIMEType: e.Image.MIMEType}}}},
+			&ggenai.Content{Role: ggenai.RoleModel, Parts: []*ggenai.Part{{Text: e.Answer}}},
+		)
+	}
+	cc, err := gg.c.Caches.Create(ctx, apiModel(model), &ggenai.CreateCachedContentConfig{
+		DisplayName:       displayName,
+		TTL:               ttl,
+		SystemInstruction: &ggenai.Content{Parts: []*ggenai.Part{{Text: p.System}}},
+		Contents:          contents,
+	})
+	if err != nil {
+		return cachedContent{}, fmt.Errorf("create cached content: %w", err)
+	}
+	return cachedContent{Name: cc.Name, ExpiresAt: cc.ExpireTime}, nil
+}
+
+func (gg *genkitGenerator) RefreshCache(ctx context.Context, name string, ttl time.Duration) (cachedContent, error) {
+	cc, err := gg.c.Caches.Update(ctx, name, &ggenai.UpdateCachedContentConfig{TTL: ttl})
+	if err != nil {
+		return cachedContent{}, fmt.Errorf("refresh cached content %s: %w", name, err)
+	}
+	return cachedContent{Name: cc.Name, ExpiresAt: cc.ExpireTime}, nil
+}
+
+func (gg *genkitGenerator) DeleteCache(ctx context.Context, name string) error {
+	if _, err := gg.c.Caches.Delete(ctx, name, nil); err != nil {
+		return fmt.Errorf("delete cached content %s: %w", name, err)
+	}
+	return nil
+}
+
+// contentConfig returns the GenAI settings of cfg.
+func contentConfig(cfg genConfig) *ggenai.GenerateContentConfig {
+	gcfg := &ggenai.GenerateContentConfig{
+		TopK:        float32Ptr(cfg.TopK),
+		Temperature: float32Ptr(cfg.Temperature),
+	}
+	if cfg.ResponseSchema != nil {
+		gcfg.ResponseMIMEType = "application/json"
+		gcfg.ResponseJsonSchema = cfg.ResponseSchema
+	}
+	// Gemini counts the thoughts in the output tokens, as Generation does.
+	if b := cfg.Gen.ThinkingBudget; b != nil {
+		gcfg.ThinkingConfig = &ggenai.ThinkingConfig{ThinkingBudget: int32Ptr(int32(*b))}
+	}
+	if n := cfg.Gen.MaxOutputTokens; n > 0 {
+		gcfg.MaxOutputTokens = int32(n)
+	}
+	return gcfg
+}
+
+// apiModel returns the GenAI API name of a Genkit model name such as
+// "googleai/gemini-2.5-flash".
+func apiModel(model string) string {
+	return strings.TrimPrefix(model, "googleai/")
+}
+
+// finishReason returns r as Genkit reports it.
+func finishReason(r ggenai.FinishReason) string {
+	switch r {
+	case ggenai.FinishReasonStop:
+		return string(ai.FinishReasonStop)
+	case ggenai.FinishReasonMaxTokens:
+		return genai.FinishLength
+	}
+	return strings.ToLower(string(r))
+}
+
+// filesAPI implements [fileStore] with the GenAI Files API.
+type filesAPI struct {
+	c *ggenai.Client
+}
+
+func (f *filesAPI) Upload(ctx context.Context, r io.Reader, mimeType, displayName string) (uploadedFile, error) {
+	file, err := f.c.Files.Upload(ctx, r, &ggenai.UploadFileConfig{
+		MIMEType:    mimeType,
+		DisplayName: displayName,
+	})
+	if err != nil {
+		return uploadedFile{}, err
+	}
+	return toUploadedFile(file), nil
+}
+
+func (f *filesAPI) List(ctx context.Context) ([]uploadedFile, error) {
+	var files []uploadedFile
+	for file, err := range f.c.Files.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("list files: %w", err)
+		}
+		files = append(files, toUploadedFile(file))
+	}
+	return files, nil
+}
+
+func toUploadedFile(f *ggenai.File) uploadedFile {
+	return uploadedFile{Name: f.Name, DisplayName: f.DisplayName, URI: f.URI, CreatedAt: f.CreateTime, ExpiresAt: f.ExpirationTime}
+}
+
+func (f *filesAPI) Delete(ctx context.Context, name string) error {
+	if _, err := f.c.Files.Delete(ctx, name, nil); err != nil {
+		return fmt.Errorf("delete file %s: %w", name, err)
+	}
+	return nil
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
@@ -0,0 +1,118 @@
+package googleai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// promptCache is the cached content of the static prompts of a model's
+// reading calls.
+type promptCache struct {
+	cachedContent
+	hash     string    // of the prompts it holds, or failed to
+	failedAt time.Time // of the last failed creation
+}
+
+// cachedPrompts returns p on the cached content of its System and Examples
+// for model with [genai.WithContextCache], creating, extending or replacing
+// it as needed. Without context caching, or while creating the cache fails,
+// p is returned as is and sent in full.
+func (c *Client) cachedPrompts(ctx context.Context, s *config, model string, p readingPrompts) readingPrompts {
+	cache, ok := c.gen.(contextCache)
+	ttl := s.Options.ContextCacheTTL
+	if !ok || ttl <= 0 {
+		return p
+	}
+	hash := promptsHash(p)
+	now := s.Options.Clock.Now()
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	e := c.caches[model]
+	if e.hash != hash {
+		// The prompts changed, as when the examples are uploaded again.
+		c.deleteCache(cache, e.Name)
+		e = promptCache{hash: hash}
+	} else if e.Name != "" && !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt.Add(-time.Minute)) {
+		e.cachedContent = cachedContent{}
+	}
+	switch {
+	case e.Name == "" && !e.failedAt.IsZero() && now.Before(e.failedAt.Add(ttl)):
+		return p
+	case e.Name == "":
+		cc, err := cache.CreateCache(ctx, model, s.uploadName("prompts-"+hash[:12]), p, ttl)
+		if err != nil {
+			log.Printf("Error caching the prompts of %s, sending them in full for %s: %v", model, ttl, err)
+			e.failedAt = now
+			c.caches[model] = e
+			return p
+		}
+		e.cachedContent, e.failedAt = cc, time.Time{}
+		s.Options.Debugf("Cached the prompts of %s as %s until %s", model, cc.Name, cc.ExpiresAt.Format(time.RFC3339))
+	case !e.ExpiresAt.IsZero() && e.ExpiresAt.Sub(now) < ttl/2:
+		// A failed refresh leaves the cache until it expires.
+		if cc, err := cache.RefreshCache(ctx, e.Name, ttl); err != nil {
+			log.Printf("Error extending cached prompts %s: %v", e.Name, err)
+		} else {
+			e.cachedContent = cc
+		}
+	}
+	c.caches[model] = e
+	p.Cache = e.Name
+	return p
+}
+
+// cacheFailed drops the cached content name of model after a reading call
+// on it failed with err, so that the next call does not depend on it.
+// Invalid answers and cancellations say nothing of the cache.
+func (c *Client) cacheFailed(ctx context.Context, model, name string, err error) {
+	var ioe *genai.InvalidOutputError
+	if name == "" || err == nil || errors.As(err, &ioe) || ctx.Err() != nil {
+		return
+	}
+	cache, ok := c.gen.(contextCache)
+	if !ok {
+		return
+	}
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if e := c.caches[model]; e.Name == name {
+		c.deleteCache(cache, name)
+		delete(c.caches, model)
+	}
+}
+
+// deleteCache deletes cached content name, if any, in the background.
+func (c *Client) deleteCache(cache contextCache, name string) {
+	if name == "" {
+		return
+	}
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+		if err := cache.DeleteCache(ctx, name); err != nil {
+			log.Printf("Error deleting cached prompts: %v", err)
+		}
+	}()
+}
+
+// promptsHash identifies the cacheable prompts of p.
+func promptsHash(p readingPrompts) string {
+	h := sha256.New()
+	h.Write([]byte(p.System))
+	for _, e := range p.Examples {
+		h.Write([]byte{0})
+		h.Write([]byte(e.Image.URI))
+		h.Write([]byte{0})
+		h.Write([]byte(e.Answer))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
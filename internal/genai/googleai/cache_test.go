@@ -0,0 +1,187 @@
+package googleai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+// Token counts of the fake prompts, as Gemini would report them roughly.
+const (
+	systemTokens  = 1500
+	exampleTokens = 260 // an image and its answer
+	userTokens    = 310 // the image and its prompt
+)
+
+// cachingGenerator is a fakeGenerator with a context cache, reporting the
+// usage of every reading call by the tokens of its prompts.
+type cachingGenerator struct {
+	*fakeGenerator
+	clock     *genaitest.Clock
+	createErr error
+
+	mu        sync.Mutex
+	caches    map[string]time.Time // expiry by name
+	n         int
+	created   []string
+	refreshed []string
+	deleted   []string
+	prompts   []readingPrompts // of every reading call
+}
+
+func newCachingGenerator(clock *genaitest.Clock) *cachingGenerator {
+	return &cachingGenerator{fakeGenerator: &fakeGenerator{read: "02924.457"}, clock: clock, caches: map[string]time.Time{}}
+}
+
+func (g *cachingGenerator) GenerateReading(ctx context.Context, img imageRef, p readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error) {
+	g.mu.Lock()
+	g.prompts = append(g.prompts, p)
+	_, cached := g.caches[p.Cache]
+	g.mu.Unlock()
+	if p.Cache != "" && !cached {
+		return nil, reply{}, fmt.Errorf("403 cached content %s not found", p.Cache)
+	}
+	out, rep, err := g.fakeGenerator.GenerateReading(ctx, img, p, cfg)
+	static := systemTokens + exampleTokens*len(p.Examples)
+	rep.Usage = genai.Usage{InputTokens: static + userTokens}
+	if p.Cache != "" {
+		rep.Usage.CachedTokens = static
+	}
+	return out, rep, err
+}
+
+func (g *cachingGenerator) CreateCache(_ context.Context, model, displayName string, p readingPrompts, ttl time.Duration) (cachedContent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.createErr != nil {
+		return cachedContent{}, g.createErr
+	}
+	name := fmt.Sprintf("cachedContents/%d", g.n)
+	g.n++
+	g.caches[name] = g.clock.Now().Add(ttl)
+	g.created = append(g.created, displayName)
+	return cachedContent{Name: name, ExpiresAt: g.caches[name]}, nil
+}
+
+func (g *cachingGenerator) RefreshCache(_ context.Context, name string, ttl time.Duration) (cachedContent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.caches[name] = g.clock.Now().Add(ttl)
+	g.refreshed = append(g.refreshed, name)
+	return cachedContent{Name: name, ExpiresAt: g.caches[name]}, nil
+}
+
+func (g *cachingGenerator) DeleteCache(_ context.Context, name string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.caches, name)
+	g.deleted = append(g.deleted, name)
+	return nil
+}
+
+func (g *cachingGenerator) lastPrompts() readingPrompts {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.prompts[len(g.prompts)-1]
+}
+
+func TestReadGasGaugePicContextCache(t *testing.T) {
+	t.Parallel()
+
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	newCachingClient := func(t *testing.T, gen *cachingGenerator, opts ...genai.Option) *Client {
+		t.Helper()
+		examples := []genai.Example{{Image: strings.NewReader("example"), ExpectedRead: "02924.000"}}
+		c, err := newClient(gen, &fakeFileStore{}, "model", "", "",
+			append([]genai.Option{genai.WithClock(clock), genai.WithExampleImages(examples), genai.WithMeter(genai.Meter{ID: "home"})}, opts...)...)
+		if err != nil {
+			t.Fatalf("newClient: %v", err)
+		}
+		return c
+	}
+	ctx := context.Background()
+	read := func(t *testing.T, c *Client) {
+		t.Helper()
+		if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil {
+			t.Fatalf("ReadGasGaugePic: %v", err)
+		}
+	}
+
+	const reads = 3
+	plain := newCachingClient(t, newCachingGenerator(clock))
+	for range reads {
+		read(t, plain)
+	}
+	before := plain.Stats()
+
+	gen := newCachingGenerator(clock)
+	c := newCachingClient(t, gen, genai.WithContextCache(time.Hour), genai.WithRetries(1))
+	for range reads {
+		read(t, c)
+	}
+	after := c.Stats()
+	if len(gen.created) != 1 || gen.created[0] != "gas-meter/home/prompts-"+promptsHash(gen.lastPrompts())[:12] {
+		t.Fatalf("created caches %q, want one of the prompts", gen.created)
+	}
+	if p := gen.lastPrompts(); p.Cache != "cachedContents/0" {
+		t.Fatalf("reading call on cache %q", p.Cache)
+	}
+	// Every reading sends the same tokens, but only the image and its
+	// prompt are billed in full.
+	if after.InputTokens != before.InputTokens || after.CachedInputTokens != reads*(systemTokens+exampleTokens) || before.CachedInputTokens != 0 {
+		t.Fatalf("input tokens %d (cached %d), without context cache %d (cached %d)",
+			after.InputTokens, after.CachedInputTokens, before.InputTokens, before.CachedInputTokens)
+	}
+	t.Logf("input tokens of %d readings: %d in full without context cache, %d in full and %d cached with it",
+		reads, before.InputTokens, after.InputTokens-after.CachedInputTokens, after.CachedInputTokens)
+
+	// Past half its lifetime the cache is extended.
+	clock.Advance(40 * time.Minute)
+	read(t, c)
+	if len(gen.refreshed) != 1 || len(gen.created) != 1 {
+		t.Fatalf("after 40m: refreshed %q, created %q", gen.refreshed, gen.created)
+	}
+
+	// Uploading the example again changes the prompts: the cache is replaced.
+	c.cfg.Load().examples[0].file = uploadedFile{}
+	read(t, c)
+	if len(gen.created) != 2 || gen.lastPrompts().Cache != "cachedContents/1" {
+		t.Fatalf("after new prompts: created %q, reading on %q", gen.created, gen.lastPrompts().Cache)
+	}
+
+	// A cache gone from the API is dropped and the retry creates another.
+	gen.DeleteCache(ctx, "cachedContents/1")
+	read(t, c)
+	if len(gen.created) != 3 || gen.lastPrompts().Cache != "cachedContents/2" {
+		t.Fatalf("after a lost cache: created %q, reading on %q", gen.created, gen.lastPrompts().Cache)
+	}
+
+	// While no cache can be created, the prompts are sent in full.
+	gen.createErr = errors.New("400 cached content is too small")
+	gen.DeleteCache(ctx, "cachedContents/2")
+	read(t, c)
+	read(t, c)
+	if p := gen.lastPrompts(); p.Cache != "" || p.System == "" || len(p.Examples) != 1 || len(gen.created) != 3 {
+		t.Fatalf("without a cache: reading on %q with system prompt %v, %d examples; created %q", p.Cache, p.System != "", len(p.Examples), gen.created)
+	}
+
+	gen.createErr = nil
+	clock.Advance(time.Hour)
+	read(t, c)
+	if len(gen.created) != 4 {
+		t.Fatalf("an hour after the failure: created %q", gen.created)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(gen.caches) != 0 {
+		t.Fatalf("caches left after Close: %v (deleted %q)", gen.caches, gen.deleted)
+	}
+}
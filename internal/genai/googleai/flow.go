@@ -0,0 +1,94 @@
+package googleai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// DetectFlow implements [genai.FlowDetector]. Each image is uploaded once,
+// retried as those of readings, and deleted after the call as they are.
+func (c *Client) DetectFlow(ctx context.Context, img1, img2 io.Reader) (out *genai.FlowResult, err error) {
+	s := c.cfg.Load()
+	o := &s.Options
+	ctx, span := o.StartSpan(ctx, genai.SpanFlow, genai.AttrMeterID.String(o.Meter.ID))
+	defer func() { genai.EndSpan(span, err) }()
+
+	// The audit log records the first image, as the reading of it does.
+	digest := &imageDigest{h: sha256.New()}
+	stamp := o.Clock.Now().UTC().Format("20060102T150405.000Z")
+	uctx, uspan := o.StartSpan(ctx, genai.SpanUpload)
+	var refs []imageRef
+	for i, img := range []io.Reader{img1, img2} {
+		w := io.Discard
+		if i == 0 {
+			w = digest
+		}
+		var file uploadedFile
+		file, err = c.uploadFlowImage(uctx, s, io.TeeReader(genai.LimitImage(img, o.MaxImageSize), w), s.uploadName(fmt.Sprintf("%s-flow%d", stamp, i+1)))
+		if err != nil {
+			genai.EndSpan(uspan, err)
+			return nil, err
+		}
+		defer c.cleanup(ctx, s, file.Name)
+		refs = append(refs, imageRef{URI: file.URI, MIMEType: "image/jpeg"})
+	}
+	genai.EndSpan(uspan, nil)
+
+	if err := o.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	cfg := s.genConfig()
+	cfg.Model = genai.ModelFromContext(ctx, s.Model)
+	cfg.Gen = o.Generation.For(cfg.Model)
+	if o.ResponseSchema {
+		cfg.ResponseSchema = genai.FlowResultJSONSchema
+	}
+	prompt := s.Prompts.FlowPrompt(o.Meter)
+	start := o.Clock.Now()
+	gctx, gspan := o.StartSpan(ctx, genai.SpanGenerate)
+	rep, err := c.gen.GenerateImages(gctx, refs, prompt, cfg)
+	gspan.SetAttributes(genai.CallAttributes(genai.CallFlow, cfg.Model, rep.Usage, rep.FinishReason)...)
+	genai.EndSpan(gspan, err)
+	if err == nil {
+		out, err = genai.ParseFlowResult(rep.Text)
+	}
+	c.audit(ctx, s, genai.CallFlow, cfg.Model, start, prompt, digest, rep, err)
+	if err != nil {
+		return nil, fmt.Errorf("detect flow: %w", err)
+	}
+	out.Model, out.At = cfg.Model, o.Clock.Now()
+	return out, nil
+}
+
+// uploadFlowImage uploads the image r as name with the retries of s.
+func (c *Client) uploadFlowImage(ctx context.Context, s *config, r io.Reader, name string) (uploadedFile, error) {
+	o := &s.Options
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return uploadedFile{}, fmt.Errorf("read image: %w", err)
+	}
+	if len(buf) == 0 {
+		return uploadedFile{}, fmt.Errorf("empty image")
+	}
+	var file uploadedFile
+	upload := func(ctx context.Context, r io.Reader) (err error) {
+		file, err = c.files.Upload(ctx, r, "image/jpeg", name)
+		return err
+	}
+	if o.UploadRetries > 0 {
+		var attempts genai.UploadAttempts
+		attempts, err = o.RetryUpload(ctx, buf, upload)
+		c.stats.CountUpload(attempts)
+	} else {
+		err = upload(ctx, bytes.NewReader(buf))
+	}
+	if err != nil {
+		return uploadedFile{}, fmt.Errorf("upload image: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,118 @@
+package googleai
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+var (
+	flagLive      = flag.Bool("live", false, "run the golden sample suite against Gemini (also enabled by GEMINI_API_KEY)")
+	flagModel     = flag.String("golden-model", "googleai/gemini-2.5-flash-lite", "model for the golden sample suite")
+	flagThreshold = flag.Float64("golden-threshold", 0.6, "minimum accuracy of the golden sample suite")
+	flagSummary   = flag.String("golden-summary", "", "write the golden sample summary JSON to this file")
+)
+
+// TestGoldenSamples reads every image in sample/ and compares the reading with
+// its golden JSON file. It calls the real API, so it only runs with -live or
+// GEMINI_API_KEY set:
+//
+//	GEMINI_API_KEY=... go test ./internal/genai/googleai -run Golden -v -golden-summary=golden.json
+func TestGoldenSamples(t *testing.T) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if !*flagLive && apiKey == "" {
+		t.Skip("set GEMINI_API_KEY or -live to run the golden sample suite")
+	}
+
+	goldens, err := genaitest.LoadGoldens("../../../sample")
+	if err != nil {
+		t.Fatalf("LoadGoldens: %v", err)
+	}
+
+	ctx := context.Background()
+	c, err := NewClient(ctx, apiKey, *flagModel, "", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer c.Close()
+
+	s, err := genaitest.RunGoldens(ctx, c, goldens)
+	if err != nil {
+		t.Fatalf("RunGoldens: %v", err)
+	}
+	for _, o := range s.Outcomes {
+		t.Logf("%-24s want %-14s got %-10s pass=%v %s", o.Image, o.Want, o.Got, o.Pass, o.Error)
+	}
+	t.Logf("accuracy %.0f%% (%d/%d), prompt %s", s.Accuracy*100, s.Passed, s.Cases, s.PromptHash)
+
+	if *flagSummary != "" {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			t.Fatalf("marshal summary: %v", err)
+		}
+		if err := os.WriteFile(*flagSummary, b, 0o644); err != nil {
+			t.Fatalf("write summary: %v", err)
+		}
+	}
+	if s.Accuracy < *flagThreshold {
+		t.Fatalf("accuracy %.2f below threshold %.2f", s.Accuracy, *flagThreshold)
+	}
+}
+
+// TestContextCacheLive reads the golden samples with and without
+// [genai.WithContextCache], the first one serving as a few-shot example, and
+// logs the input tokens of both runs. Like TestGoldenSamples it only runs
+// with -live or GEMINI_API_KEY set:
+//
+//	GEMINI_API_KEY=... go test ./internal/genai/googleai -run ContextCacheLive -v
+func TestContextCacheLive(t *testing.T) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if !*flagLive && apiKey == "" {
+		t.Skip("set GEMINI_API_KEY or -live to run the context cache comparison")
+	}
+
+	goldens, err := genaitest.LoadGoldens("../../../sample")
+	if err != nil {
+		t.Fatalf("LoadGoldens: %v", err)
+	}
+	if len(goldens) < 2 || goldens[0].Unreadable {
+		t.Skip("needs a readable sample for the example and another to read")
+	}
+	ctx := context.Background()
+	run := func(opts ...genai.Option) (genai.Stats, bool) {
+		img, err := os.Open(goldens[0].Image)
+		if err != nil {
+			t.Fatalf("open example: %v", err)
+		}
+		defer img.Close()
+		opts = append(opts, genai.WithExampleImages([]genai.Example{{Image: img, ExpectedRead: goldens[0].Read}}))
+		c, err := NewClient(ctx, apiKey, *flagModel, "", "", opts...)
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		defer c.Close()
+		if _, err := genaitest.RunGoldens(ctx, c, goldens[1:]); err != nil {
+			t.Fatalf("RunGoldens: %v", err)
+		}
+		c.cacheMu.Lock()
+		defer c.cacheMu.Unlock()
+		return c.Stats(), c.caches[*flagModel].Name != ""
+	}
+
+	before, _ := run()
+	after, cached := run(genai.WithContextCache(10 * time.Minute))
+	t.Logf("input tokens of %d readings: %d without context cache, %d with it of which %d cached",
+		len(goldens)-1, before.InputTokens, after.InputTokens, after.CachedInputTokens)
+	if !cached {
+		t.Skip("the prompts were not cached; the model may not support context caching or they are too short")
+	}
+	if after.CachedInputTokens == 0 {
+		t.Fatalf("no cached input tokens reported on a cached prompt")
+	}
+}
@@ -2,15 +2,22 @@
 package googleai
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/firebase/genkit/go/plugins/googlegenai"
 	ggenai "google.golang.org/genai"
@@ -22,117 +29,365 @@ import (
 
 // Client uploads JPEG input through the GenAI Files API and runs structured generation with Genkit.
 type Client struct {
-	g *genkit.Genkit
-	c *ggenai.Client
+	gen   generator
+	files fileStore
 
-	model        string
-	systemPrompt string
-	promptForImg string
+	// cfg is the current configuration; every call uses the one it
+	// started with, see [genai.Snapshot].
+	cfg atomic.Pointer[config]
 
-	lastRead string
+	// refs are the previous readings, which a correction may replace while
+	// reading.
+	refs *genai.References
+
+	// cacheMu guards caches, the cached prompts by model; see
+	// [genai.WithContextCache].
+	cacheMu sync.Mutex
+	caches  map[string]promptCache
+
+	stats genai.Counters
+
+	// pending tracks asynchronous cleanups for Close.
+	pending        sync.WaitGroup
+	cleanupBackoff time.Duration
+}
+
+// Deleting an uploaded image is detached from the reading's context, so a
+// cancelled reading still cleans up, and bounded by cleanupTimeout per attempt.
+const (
+	cleanupTimeout  = 10 * time.Second
+	cleanupAttempts = 3
+)
+
+// config is a [genai.Snapshot] with the uploads of its few-shot examples.
+type config struct {
+	*genai.Snapshot
+
+	exMu     sync.Mutex
+	examples []exampleFile
+}
+
+func newConfig(model, systemPrompt, prompt string, opts ...genai.Option) (*config, error) {
+	s, err := genai.NewSnapshot(model, systemPrompt, prompt, opts...)
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]exampleFile, len(s.Examples))
+	for i, e := range s.Examples {
+		examples[i].LoadedExample = e
+	}
+	return &config{Snapshot: s, examples: examples}, nil
+}
+
+// exampleFile caches the Files API upload of a few-shot example across calls.
+type exampleFile struct {
+	genai.LoadedExample
+	file uploadedFile
 }
 
 // NewClient initializes Genkit with the Google AI plugin and an API-key-backed GenAI HTTP client.
+// systemPrompt and prompt are text/templates rendered with [genai.PromptData];
+// empty values use the built-in prompts of the configured locale.
 func NewClient(ctx context.Context,
 	apiKey string,
 	model string,
 	systemPrompt string,
 	prompt string,
+	opts ...genai.Option,
 ) (*Client, error) {
 	gk := genkit.Init(ctx, genkit.WithPlugins(&googlegenai.GoogleAI{}))
 
 	// Create Files API client
+	o := genai.NewOptions(opts...)
 	c, err := ggenai.NewClient(ctx, &ggenai.ClientConfig{
-		Backend: ggenai.BackendGeminiAPI,
-		APIKey:  apiKey, // os.Getenv("GEMINI_API_KEY"),
+		Backend:    ggenai.BackendGeminiAPI,
+		APIKey:     apiKey, // os.Getenv("GEMINI_API_KEY"),
+		HTTPClient: o.Client(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create genai client: %w", err)
 	}
 
-	return &Client{
-		g:            gk,
-		c:            c,
-		model:        model,
-		systemPrompt: systemPrompt,
-		promptForImg: prompt,
-	}, nil
+	return newClient(&genkitGenerator{g: gk, c: c}, &filesAPI{c: c}, model, systemPrompt, prompt, opts...)
+}
+
+// newClient builds a Client on top of the given backends.
+func newClient(gen generator, files fileStore, model, systemPrompt, prompt string, opts ...genai.Option) (*Client, error) {
+	s, err := newConfig(model, systemPrompt, prompt, opts...)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := genai.ResolveSeed(context.Background(), s.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		gen:    gen,
+		files:  files,
+		refs:   genai.NewReferences(seed),
+		caches: map[string]promptCache{},
+
+		cleanupBackoff: time.Second,
+	}
+	c.cfg.Store(s)
+	return c, nil
+}
+
+// Reconfigure implements [genai.Reconfigurer]. Uploads of the examples are
+// kept if they did not change; those replaced are left to
+// [Client.CollectOrphans], since running calls may still refer to them.
+func (c *Client) Reconfigure(model, systemPrompt, prompt string, opts ...genai.Option) error {
+	s, err := newConfig(model, systemPrompt, prompt, opts...)
+	if err != nil {
+		return err
+	}
+	old := c.cfg.Load()
+	old.exMu.Lock()
+	defer old.exMu.Unlock()
+	if slices.EqualFunc(old.examples, s.examples, func(a, b exampleFile) bool {
+		return bytes.Equal(a.JPEG, b.JPEG) && a.ExpectedRead == b.ExpectedRead
+	}) {
+		copy(s.examples, old.examples)
+	}
+	c.cfg.Store(s)
+	return nil
+}
+
+// Snapshot implements [genai.Reconfigurer].
+func (c *Client) Snapshot() *genai.Snapshot {
+	return c.cfg.Load().Snapshot
 }
 
 // ReadGasGaugePic implements [genai.VisionClient].
 func (c *Client) ReadGasGaugePic(
 	ctx context.Context,
 	jpgReader io.Reader,
-) (*genai.GasMeterReadResult, error) {
+) (out *genai.GasMeterReadResult, err error) {
+	defer func() { c.stats.CountRead(out, err) }()
 
-	start := time.Now()
+	s := c.cfg.Load()
+	o := &s.Options
+	start := o.Clock.Now()
+	ctx, span := o.StartSpan(ctx, genai.SpanRead, genai.AttrMeterID.String(o.Meter.ID))
+	defer func() { genai.EndSpan(span, err) }()
+	ctx, timer, cancel := o.StartTimer(ctx)
+	defer cancel()
+	ctx, usage := genai.WithUsage(ctx)
 
-	// fileSample, err := c.c.Files.UploadFromPath(ctx, "sample/gauge_20251107_051332.jpg", &genai.UploadFileConfig{
-	// 	MIMEType:    "image/jpeg",
-	// 	DisplayName: "Test Image",
-	// })
-	// if err != nil {
-	// 	return nil, fmt.Errorf("failed to upload: %v", err)
-	// }
+	prev := c.reference(s)
+	prompt, err := s.Prompts.ImageTmpl.Render(s.Prompts.Data(o.Meter, prev.Read))
+	if err != nil {
+		return nil, err
+	}
+	if o.SingleShotMode() {
+		prompt = s.Prompts.SingleShotPrompt(prompt, prev.Read)
+	}
+	o.Debugf("Rendered image prompt: %s", prompt)
 
-	// Initialize Genkit
-	file, err := c.c.Files.Upload(ctx, jpgReader, &ggenai.UploadFileConfig{
-		MIMEType:    "image/jpeg",
-		DisplayName: "Gas Meter Image",
-	})
+	// Hash the image for the audit log as it streams to the Files API.
+	digest := &imageDigest{h: sha256.New()}
+
+	// The image is streamed, never held in memory as a whole, unless failed
+	// uploads are retried.
+	img := genai.LimitImage(jpgReader, o.MaxImageSize)
+	var phases genai.Phases
+	uploadStart := o.Clock.Now()
+	uctx, ucancel := timer.Phase(ctx, genai.PhaseUpload)
+	defer ucancel()
+	uctx, uspan := o.StartSpan(uctx, genai.SpanUpload)
+	displayName := s.uploadName(start.UTC().Format("20060102T150405.000Z"))
+	var file uploadedFile
+	upload := func(ctx context.Context, r io.Reader) (err error) {
+		file, err = c.files.Upload(ctx, r, "image/jpeg", displayName)
+		return err
+	}
+	if o.UploadRetries > 0 {
+		// Retries upload the buffer again, without reading the source twice.
+		var buf []byte
+		if buf, err = io.ReadAll(io.TeeReader(img, digest)); err == nil {
+			phases.Uploads, err = o.RetryUpload(uctx, buf, upload)
+			c.stats.CountUpload(phases.Uploads)
+		}
+	} else {
+		phases.Uploads.Attempts = 1
+		err = upload(uctx, io.TeeReader(img, digest))
+	}
+	err = timer.Err(uctx, genai.PhaseUpload, err)
+	uspan.SetAttributes(genai.AttrImageSize.Int64(digest.n), genai.AttrUploadAttempts.Int(phases.Uploads.Attempts))
+	span.SetAttributes(genai.AttrImageSize.Int64(digest.n))
+	if img.TooLarge() {
+		genai.EndSpan(uspan, genai.ErrImageTooLarge)
+		return nil, genai.ErrImageTooLarge
+	}
 	if err != nil {
+		genai.EndSpan(uspan, err)
 		return nil, fmt.Errorf("upload image: %w", err)
 	}
-	// fmt.Printf("Uploaded! File URI: %s\n", file.URI)
-	defer func(ctx context.Context, fileName string) {
-		// Clean up
-		// c.c.Files.Delete(ctx, sampleFileName, nil)
-		c.c.Files.Delete(ctx, fileName, nil)
-		// fmt.Println("Cleaned up uploaded file")
-	}(ctx, file.Name)
-
-	// Use Files API URI directly with Genkit (now supported!)
-	// fmt.Println("Analyzing image with Genkit using Files API URI...")
-
-	out, _, err := genkit.GenerateData[genai.GasMeterReadResult](ctx, c.g,
-		ai.WithModelName(c.model),
-		ai.WithMessages(
-			ai.NewSystemMessage(
-				// ai.NewMediaPart("image/jpeg", fileSample.URI), // system prompt denies to use image
-				// ai.NewTextPart(readGuagePicPrompt),
-				ai.NewTextPart(c.systemPrompt),
-			),
-			ai.NewUserMessage(
-				ai.NewMediaPart("image/jpeg", file.URI),
-				// ai.NewTextPart("Process the image and extract the reading and date."),
-				ai.NewTextPart(c.promptForImg),
-			),
-		),
-		ai.WithConfig(&ggenai.GenerateContentConfig{
-			TopK:        float32Ptr(10),
-			Temperature: float32Ptr(0.1),
-		}),
-	)
+	defer c.cleanup(ctx, s, file.Name)
+	o.Debugf("Uploaded image %s as %s (%s)", displayName, file.Name, file.URI)
+
+	examples, err := c.exampleTurns(uctx, s)
+	err = timer.Err(uctx, genai.PhaseUpload, err)
+	genai.EndSpan(uspan, err)
+	ucancel()
 	if err != nil {
-		return nil, fmt.Errorf("analyze image: %w", err)
+		return nil, fmt.Errorf("upload example images: %w", err)
+	}
+	phases.Upload = genai.Since(o.Clock, uploadStart)
+
+	ref := imageRef{URI: file.URI, MIMEType: "image/jpeg"}
+	generate := func(ctx context.Context, model string, g genai.Generation) (*genai.GasMeterReadResult, error) {
+		if err := o.Limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		cfg := s.genConfig()
+		cfg.Model, cfg.Gen = model, g
+		if o.ResponseSchema {
+			cfg.ResponseSchema = o.ResponseJSONSchema()
+		}
+		genStart := o.Clock.Now()
+		gctx, gcancel := timer.Phase(ctx, genai.PhaseGenerate)
+		defer gcancel()
+		gctx, gspan := o.StartSpan(gctx, genai.SpanGenerate)
+		rp := c.cachedPrompts(gctx, s, model, readingPrompts{System: s.Prompts.SystemText, Examples: examples, User: prompt})
+		out, rep, err := c.gen.GenerateReading(gctx, ref, rp, cfg)
+		c.cacheFailed(gctx, model, rp.Cache, err)
+		err = timer.Err(gctx, genai.PhaseGenerate, err)
+		gspan.SetAttributes(genai.CallAttributes(genai.CallRead, model, rep.Usage, rep.FinishReason)...)
+		gspan.SetAttributes(genai.AttrImageSize.Int64(digest.n))
+		genai.EndSpan(gspan, err)
+
+		_, vspan := o.StartSpan(ctx, genai.SpanValidate, genai.AttrModel.String(model))
+		out, err = c.validate(s, out, rep.FinishReason, err, prev.Read)
+		genai.EndSpan(vspan, err)
+		c.audit(ctx, s, genai.CallRead, model, genStart, prompt, digest, rep, err)
+		if err != nil {
+			return nil, err
+		}
+		out.Model = model
+		return out, nil
+	}
+	attempt := func(ctx context.Context, model string) (*genai.GasMeterReadResult, error) {
+		return o.GenerateWithin(model, func(g genai.Generation) (*genai.GasMeterReadResult, error) {
+			return generate(ctx, model, g)
+		})
+	}
+	readWith := func(ctx context.Context, model string) (*genai.GasMeterReadResult, error) {
+		return o.Retry(ctx, model, attempt)
+	}
+
+	readStart := o.Clock.Now()
+	// The upload above is shared by every ensemble model and retry.
+	if len(o.Ensemble) > 0 {
+		var agreed bool
+		out, agreed, err = genai.RunEnsemble(ctx, o.Ensemble, o.Agreement, readWith)
+		if err == nil && !agreed {
+			c.stats.CountDisagreement()
+			log.Printf("Ensemble models disagree: %+v", out.Answers)
+		}
+	} else {
+		out, err = readWith(ctx, genai.ModelFromContext(ctx, s.Model))
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	phases.Generate = genai.Since(o.Clock, readStart)
+	out.Ambiguous = len(out.AmbiguousPositions) > 0
+
+	// In single-shot mode this is the fallback for digits the model still could not decide.
 	if strings.Contains(out.Read, "?") {
 		log.Printf("Ambiguous digits found in the reading: %s", out.Read)
-		out.Read, err = c.guessAmbiguousDigits(ctx, out.Read)
+		guessStart := o.Clock.Now()
+		gctx, gcancel := timer.Phase(ctx, genai.PhaseGuess)
+		defer gcancel()
+		gctx, gspan := o.StartSpan(gctx, genai.SpanGuess, genai.AttrRead.String(out.Read))
+		out.Read, err = c.guessAmbiguousDigits(gctx, s, out.Read, prev.Read)
+		err = timer.Err(gctx, genai.PhaseGuess, err)
+		genai.EndSpan(gspan, err)
 		if err != nil {
 			return nil, fmt.Errorf("guess ambiguous digits: %w", err)
 		}
+		out.Ambiguous = true
+		c.stats.CountGuess()
+		phases.Guess = genai.Since(o.Clock, guessStart)
 	}
 
-	out.ItTakes = time.Since(start).String()
-	out.ReadAt = time.Now()
+	// The re-examination reuses the uploaded image.
+	if o.SelfVerifyIn(ctx) {
+		verifyStart := o.Clock.Now()
+		vctx, vspan := o.StartSpan(ctx, genai.SpanVerify, genai.AttrRead.String(out.Read))
+		verified, err := c.verify(vctx, s, ref, readingPrompts{System: s.Prompts.SystemText, Examples: examples, User: prompt}, out, digest, prev.Read)
+		err = timer.Err(vctx, genai.PhaseVerify, err)
+		genai.EndSpan(vspan, err)
+		if err != nil {
+			return nil, fmt.Errorf("verify reading: %w", err)
+		}
+		out.SetVerified(verified)
+		if out.VerifyDisagrees() {
+			c.stats.CountVerifyDisagreement()
+			log.Printf("Reading changed on re-examination: %s, then %s", out.Read, verified)
+		}
+		phases.Verify = genai.Since(o.Clock, verifyStart)
+	}
 
-	c.lastRead = out.Read
+	phases.Total = genai.Since(o.Clock, start)
+	phases.TimedOut, phases.Usage = timer.TimedOut(), usage()
+	out.ItTakes = phases.Total.String()
+	out.Timing = phases.Timing(o.SingleShotMode())
+	c.stats.ObservePhases(phases)
+	o.LogSlow(out.Model, phases, digest.n)
+	out.ReadAt = o.Clock.Now()
+	out.PromptHash = s.Prompts.Hash
+	out.ConfigHash = s.Hash
+	out.ReaderVersion = genai.Version
+	out.Utility = o.Meter.Utility
+	s.Prompts.SetDateParsed(out, o.Location)
+	if o.Debug {
+		out.UploadedFile = displayName
+	}
+	out.SetProvenance(prev)
+	span.SetAttributes(genai.AttrRead.String(out.Read), genai.AttrModel.String(out.Model))
+
+	if !o.Stateless {
+		c.refs.Add(o.Meter.ID, out)
+	}
 
 	return out, nil
 }
 
+// cleanup deletes an uploaded file, in the background if [genai.WithAsyncCleanup] is set in s.
+func (c *Client) cleanup(ctx context.Context, s *config, name string) {
+	ctx = context.WithoutCancel(ctx)
+	if !s.Options.AsyncCleanup {
+		c.deleteFile(ctx, s, name)
+		return
+	}
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		c.deleteFile(ctx, s, name)
+	}()
+}
+
+// deleteFile tries to delete name up to cleanupAttempts times and logs the failure.
+func (c *Client) deleteFile(ctx context.Context, s *config, name string) {
+	var err error
+	for i := range cleanupAttempts {
+		if i > 0 {
+			<-s.Options.Clock.After(time.Duration(i) * c.cleanupBackoff)
+		}
+		dctx, cancel := context.WithTimeout(ctx, cleanupTimeout)
+		err = c.files.Delete(dctx, name)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("Error deleting uploaded file %s: %v", name, err)
+}
+
 // ReadGasGaugePicFromURL downloads the JPEG at imageURL and delegates to ReadGasGaugePic.
 func (c *Client) ReadGasGaugePicFromURL(
 	ctx context.Context,
@@ -146,7 +401,11 @@ func (c *Client) ReadGasGaugePicFromURL(
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	hc := c.cfg.Load().Options.Client()
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch image: %w", err)
 	}
@@ -154,47 +413,304 @@ func (c *Client) ReadGasGaugePicFromURL(
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("fetch image: status %s", resp.Status)
 	}
+	if max := c.cfg.Load().Options.MaxImageSize; max > 0 && resp.ContentLength > max {
+		return nil, genai.ErrImageTooLarge
+	}
 	return c.ReadGasGaugePic(ctx, resp.Body)
 }
 
+// genConfig returns the generation settings shared by all calls.
+func (s *config) genConfig() genConfig {
+	return genConfig{
+		Model:       s.Model,
+		Temperature: 0.1,
+		TopK:        10,
+	}
+}
+
+// exampleTurns returns the few-shot turns (user: example image, model:
+// expected reading), uploading example images on first use and again once the
+// Files API has expired them.
+func (c *Client) exampleTurns(ctx context.Context, s *config) ([]exampleTurn, error) {
+	s.exMu.Lock()
+	defer s.exMu.Unlock()
+
+	var turns []exampleTurn
+	for i := range s.examples {
+		e := &s.examples[i]
+		if e.file.URI == "" || (!e.file.ExpiresAt.IsZero() && s.Options.Clock.Now().After(e.file.ExpiresAt.Add(-time.Minute))) {
+			file, err := c.files.Upload(ctx, bytes.NewReader(e.JPEG), "image/jpeg", s.uploadName(fmt.Sprintf("example-%d", i+1)))
+			if err != nil {
+				return nil, err
+			}
+			e.file = file
+		} else {
+			c.stats.CountCacheHit()
+		}
+		turns = append(turns, exampleTurn{
+			Image:  imageRef{URI: e.file.URI, MIMEType: "image/jpeg"},
+			Answer: e.ModelAnswer(),
+		})
+	}
+	return turns, nil
+}
+
+// Close waits for pending cleanups and deletes uploaded example images and
+// cached prompts. It implements [genai.VisionClient].
+func (c *Client) Close() error {
+	c.pending.Wait()
+
+	s := c.cfg.Load()
+	s.exMu.Lock()
+	defer s.exMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var errs []error
+	if cache, ok := c.gen.(contextCache); ok {
+		c.cacheMu.Lock()
+		for model, e := range c.caches {
+			if e.Name == "" {
+				continue
+			}
+			if err := cache.DeleteCache(ctx, e.Name); err != nil {
+				errs = append(errs, err)
+			}
+			delete(c.caches, model)
+		}
+		c.cacheMu.Unlock()
+	}
+	for i := range s.examples {
+		e := &s.examples[i]
+		if e.file.Name == "" {
+			continue
+		}
+		if err := c.files.Delete(ctx, e.file.Name); err != nil {
+			errs = append(errs, fmt.Errorf("delete example: %w", err))
+		}
+		e.file = uploadedFile{}
+	}
+	return errors.Join(errs...)
+}
+
+// uploadName returns the display name "{prefix}/{meter ID}/{name}" of an upload.
+func (s *config) uploadName(name string) string {
+	meter := s.Options.Meter.ID
+	if meter == "" {
+		meter = "meter"
+	}
+	return s.Options.UploadPrefix + "/" + meter + "/" + name
+}
+
+// CollectOrphans deletes files left behind by crashed or killed processes:
+// uploads under this client's prefix (see [genai.WithUploadPrefix]) that
+// are older than minAge and are not one of its current example images. Files
+// of other prefixes, even of the same meter, are never touched. minAge should
+// exceed the duration of a reading so that files in use are spared.
+func (c *Client) CollectOrphans(ctx context.Context, minAge time.Duration) (int, error) {
+	files, err := c.files.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	s := c.cfg.Load()
+	s.exMu.Lock()
+	keep := make(map[string]bool, len(s.examples))
+	for _, e := range s.examples {
+		keep[e.file.Name] = true
+	}
+	s.exMu.Unlock()
+
+	prefix := s.Options.UploadPrefix + "/"
+	cutoff := s.Options.Clock.Now().Add(-minAge)
+	var n int
+	var errs []error
+	for _, f := range files {
+		if !strings.HasPrefix(f.DisplayName, prefix) || keep[f.Name] || f.CreatedAt.IsZero() || f.CreatedAt.After(cutoff) {
+			continue
+		}
+		if err := c.files.Delete(ctx, f.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("Deleted %d orphaned uploads under %s", n, prefix)
+	}
+	return n, errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the client's counters.
+func (c *Client) Stats() genai.Stats {
+	return c.stats.Snapshot()
+}
+
+// SeedLastRead implements [genai.Seeder].
+func (c *Client) SeedLastRead(read string, at time.Time) error {
+	o := c.cfg.Load().Options
+	if err := genai.CheckSeed(o.Meter, read); err != nil {
+		return err
+	}
+	if !o.Stateless {
+		c.refs.Reset(read, at)
+	}
+	return nil
+}
+
+// SeedReading implements [genai.Seeder].
+func (c *Client) SeedReading(r *genai.GasMeterReadResult) error {
+	o := c.cfg.Load().Options
+	if err := genai.CheckSeed(o.Meter, r.Read); err != nil {
+		return err
+	}
+	if !o.Stateless {
+		c.refs.Rewind(o.Meter.ID, r)
+	}
+	return nil
+}
+
+// Seed implements [genai.Seeder].
+func (c *Client) Seed() genai.Seed {
+	return c.refs.Seed()
+}
+
+// reference returns the reference reading for the prompts of s; stateless
+// clients have none.
+func (c *Client) reference(s *config) genai.Seed {
+	if s.Options.Stateless {
+		return genai.Seed{}
+	}
+	return c.refs.Reference()
+}
+
 func (c *Client) guessAmbiguousDigits(
 	ctx context.Context,
-	ambiguousValueString string,
+	s *config,
+	ambiguousValueString, prevRead string,
 ) (string, error) {
-	if !genai.ContainsOnly(ambiguousValueString, ".?0123456789") {
-		return "", fmt.Errorf("ambiguous value string %q is not valid", ambiguousValueString)
-	}
-
-	resp, err := genkit.Generate(ctx, c.g,
-		ai.WithModelName(c.model),
-		ai.WithMessages(
-			ai.NewUserMessage(
-				ai.NewTextPart(fmt.Sprintf(fixAmbiguousPromptFmt, ambiguousValueString, c.lastRead)),
-			),
-		),
-		ai.WithConfig(&ggenai.GenerateContentConfig{
-			TopK:        float32Ptr(10),
-			Temperature: float32Ptr(0.1),
-		}),
-	)
+	o := &s.Options
+	if err := o.Meter.CheckRead(ambiguousValueString); err != nil {
+		return "", fmt.Errorf("ambiguous value: %w", err)
+	}
+
+	if err := o.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	start := o.Clock.Now()
+	prompt := s.Prompts.DisambiguatePrompt(ambiguousValueString, prevRead)
+	ctx, span := o.StartSpan(ctx, genai.SpanGenerate)
+	cfg := s.genConfig()
+	cfg.Gen = o.Generation.For(cfg.Model)
+	rep, err := c.gen.GenerateText(ctx, prompt, cfg)
+	span.SetAttributes(genai.CallAttributes(genai.CallGuess, s.Model, rep.Usage, rep.FinishReason)...)
+	genai.EndSpan(span, err)
+	c.audit(ctx, s, genai.CallGuess, s.Model, start, prompt, nil, rep, err)
 	if err != nil {
 		return "", fmt.Errorf("generate disambiguation: %w", err)
 	}
 
-	return resp.Text(), nil
+	return genai.SanitizeGuess(o.Meter, ambiguousValueString, rep.Text)
 }
 
-const fixAmbiguousPromptFmt = `The value “%s” represents the output of a analog-meter-reading analysis performed on an image.
-Uncertain digits within the reading are denoted by the “?” character.
+// verify asks the model of out to re-examine img, continuing the
+// conversation of the reading p, and returns its answer.
+func (c *Client) verify(ctx context.Context, s *config, img imageRef, p readingPrompts, out *genai.GasMeterReadResult, digest *imageDigest, prevRead string) (string, error) {
+	o := &s.Options
+	if err := o.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	cfg := s.genConfig()
+	cfg.Model = out.Model
+	cfg.Gen = o.Generation.For(cfg.Model)
+	if o.ResponseSchema {
+		cfg.ResponseSchema = o.ResponseJSONSchema()
+	}
+	p.Answer, p.Verify = genai.VerifyAnswer(out), s.Prompts.VerifyPrompt(out.Read)
+	start := o.Clock.Now()
+	gctx, span := o.StartSpan(ctx, genai.SpanGenerate)
+	p = c.cachedPrompts(gctx, s, cfg.Model, p)
+	verified, rep, err := c.gen.GenerateReading(gctx, img, p, cfg)
+	c.cacheFailed(gctx, cfg.Model, p.Cache, err)
+	span.SetAttributes(genai.CallAttributes(genai.CallVerify, cfg.Model, rep.Usage, rep.FinishReason)...)
+	genai.EndSpan(span, err)
+	verified, err = c.validate(s, verified, rep.FinishReason, err, prevRead)
+	c.audit(ctx, s, genai.CallVerify, cfg.Model, start, p.Verify, digest, rep, err)
+	if err != nil {
+		return "", err
+	}
+	return genai.MergeVerified(out.Read, verified.Read), nil
+}
 
-Using the previously recorded meter value "%s" as a reference (only if it is not empty),
-infer and replace the “?” characters to estimate the most probable complete reading.
+// imageDigest hashes and counts image bytes for the audit log.
+type imageDigest struct {
+	h hash.Hash
+	n int64
+}
 
-Instructions:
-- Return a string with the exact same length as the input value.
-- Output only the predicted value, without any explanations or additional text.
-`
+func (d *imageDigest) Write(p []byte) (int, error) {
+	d.h.Write(p)
+	d.n += int64(len(p))
+	return len(p), nil
+}
+
+// validate checks the output of a reading call that returned err, against
+// the previous reading prevRead.
+func (c *Client) validate(s *config, out *genai.GasMeterReadResult, finishReason string, err error, prevRead string) (*genai.GasMeterReadResult, error) {
+	o := &s.Options
+	if err := genai.CheckOutput(o.Meter, out, finishReason, err); err != nil {
+		var ioe *genai.InvalidOutputError
+		if errors.As(err, &ioe) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("analyze image: %w", err)
+	}
+	if o.Meter.Type == genai.MeterDials {
+		if err := genai.ResolveDials(o.Meter, out, prevRead); err != nil {
+			return nil, fmt.Errorf("assemble dials: %w", err)
+		}
+	}
+	if err := genai.NormalizeResult(o.Meter, out); err != nil {
+		return nil, err
+	}
+	out.AmbiguousPositions = genai.CleanPositions(out.Read, out.AmbiguousPositions)
+	out.Confidences = genai.CleanConfidences(out.Read, out.Confidences)
+	out.Issue = genai.CleanIssue(out.Issue)
+	if out.CounterBox != nil && !out.CounterBox.Valid() {
+		out.CounterBox = nil
+	}
+	if o.SerialCheck {
+		if err := genai.CheckSerial(o.Meter, out.SerialNumber); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
 
-func float32Ptr(v float32) *float32 {
-	return &v
+// audit records one generation call with the auditor of s, and its usage
+// with the reading of ctx.
+func (c *Client) audit(ctx context.Context, s *config, kind, model string, start time.Time, prompt string, img *imageDigest, rep reply, err error) {
+	e := genai.AuditEntry{
+		Time:     start,
+		Call:     kind,
+		Model:    model,
+		Prompt:   prompt,
+		Response: rep.Text,
+		Usage:    rep.Usage,
+		Latency:  genai.Since(s.Options.Clock, start).String(),
+	}
+	if kind == genai.CallRead {
+		e.SystemPrompt = s.Prompts.SystemText
+	}
+	if img != nil {
+		e.ImageSHA256 = hex.EncodeToString(img.h.Sum(nil))
+		e.ImageSize = img.n
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	c.stats.CountUsage(rep.Usage)
+	genai.AddUsage(ctx, rep.Usage)
+	s.Options.Audit(e)
 }
@@ -0,0 +1,612 @@
+package googleai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+// fakeGenerator answers GenerateReading with read (and positions), or
+// verified when asked to re-examine it, and GenerateText with guess.
+type fakeGenerator struct {
+	read      string
+	verified  string
+	positions []int
+	readErr   error
+	// output replaces the answer built from read, as the raw model text, and
+	// finish is its finish reason.
+	output   string
+	finish   string
+	guess    string
+	guessErr error
+	flow     string      // the answer to GenerateImages
+	usage    genai.Usage // of every call
+
+	mu         sync.Mutex
+	readCalls  int
+	guessCalls int
+	lastUser   string
+	lastGuess  string
+	lastSchema map[string]any
+	lastVerify readingPrompts
+	images     []imageRef         // of every reading call
+	gens       []genai.Generation // of every reading call
+	flowImages []imageRef         // of the last GenerateImages call
+}
+
+func (g *fakeGenerator) GenerateReading(_ context.Context, img imageRef, p readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.images = append(g.images, img)
+	g.gens = append(g.gens, cfg.Gen)
+	if p.Verify != "" {
+		g.lastVerify = p
+		return &genai.GasMeterReadResult{Read: g.verified}, reply{Text: fmt.Sprintf(`{"read":%q}`, g.verified), Usage: g.usage}, nil
+	}
+	g.readCalls++
+	g.lastUser = p.User
+	g.lastSchema = cfg.ResponseSchema
+	if g.readErr != nil {
+		return nil, reply{}, g.readErr
+	}
+	if g.output != "" {
+		rep := reply{Text: g.output, FinishReason: g.finish}
+		out, err := genai.ParseReadResult(g.output)
+		return out, rep, err
+	}
+	out := &genai.GasMeterReadResult{Read: g.read, AmbiguousPositions: g.positions}
+	return out, reply{Text: fmt.Sprintf(`{"read":%q}`, g.read), Usage: g.usage, FinishReason: g.finish}, nil
+}
+
+func (g *fakeGenerator) GenerateText(_ context.Context, prompt string, _ genConfig) (reply, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.guessCalls++
+	g.lastGuess = prompt
+	if g.guessErr != nil {
+		return reply{}, g.guessErr
+	}
+	return reply{Text: g.guess, Usage: g.usage}, nil
+}
+
+func (g *fakeGenerator) GenerateImages(_ context.Context, imgs []imageRef, _ string, _ genConfig) (reply, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flowImages = imgs
+	return reply{Text: g.flow, Usage: g.usage}, nil
+}
+
+// fakeFileStore records uploads and deletes.
+type fakeFileStore struct {
+	uploadErr   error
+	deleteFails int // Delete fails this many times before succeeding
+
+	mu            sync.Mutex
+	uploads       []string
+	deletes       []string // successfully deleted names
+	deleteCtxErrs []error  // ctx.Err() seen by every Delete call
+	// existing are files of earlier runs and other clients, returned by List
+	// until deleted.
+	existing []uploadedFile
+}
+
+func (f *fakeFileStore) Upload(_ context.Context, r io.Reader, _, displayName string) (uploadedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploadErr != nil {
+		return uploadedFile{}, f.uploadErr
+	}
+	io.Copy(io.Discard, r)
+	name := fmt.Sprintf("files/%d", len(f.uploads))
+	f.uploads = append(f.uploads, displayName)
+	return uploadedFile{Name: name, URI: "https://example.com/" + name}, nil
+}
+
+func (f *fakeFileStore) Delete(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleteCtxErrs = append(f.deleteCtxErrs, ctx.Err())
+	if f.deleteFails > 0 {
+		f.deleteFails--
+		return errors.New("delete failed")
+	}
+	f.deletes = append(f.deletes, name)
+	return nil
+}
+
+func (f *fakeFileStore) List(context.Context) ([]uploadedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var files []uploadedFile
+	for _, file := range f.existing {
+		if !slices.Contains(f.deletes, file.Name) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func newTestClient(t *testing.T, gen *fakeGenerator, files *fakeFileStore, opts ...genai.Option) *Client {
+	t.Helper()
+	c, err := newClient(gen, files, "model", "", "", opts...)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	c.cleanupBackoff = time.Millisecond
+	return c
+}
+
+func TestReadGasGaugePicAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{read: "0292?.457", guess: "02924.457"}
+	files := &fakeFileStore{}
+	c := newTestClient(t, gen, files)
+
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Read != "02924.457" || !res.Ambiguous {
+		t.Fatalf("result = %q, ambiguous %v; want 02924.457, true", res.Read, res.Ambiguous)
+	}
+	if gen.guessCalls != 1 || !strings.Contains(gen.lastGuess, "0292?.457") {
+		t.Fatalf("guess calls = %d, prompt %q", gen.guessCalls, gen.lastGuess)
+	}
+	if len(files.uploads) != 1 || len(files.deletes) != 1 {
+		t.Fatalf("uploads = %v, deletes = %v; want one each", files.uploads, files.deletes)
+	}
+
+	gen.read = "02925.000"
+	res, err = c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Ambiguous || gen.guessCalls != 1 {
+		t.Fatalf("clear reading: ambiguous %v, guess calls %d", res.Ambiguous, gen.guessCalls)
+	}
+}
+
+func TestReadGasGaugePicSingleShot(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{read: "02924.457", guess: "02925.457"}
+	c := newTestClient(t, gen, &fakeFileStore{}, genai.WithSingleShot())
+	c.refs.Reset("02924.399", time.Time{})
+	ctx := context.Background()
+
+	// The model resolved the uncertain digit itself: no disambiguation call.
+	gen.positions = []int{4, 4, 5, 42}
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if !res.Ambiguous || !slices.Equal(res.AmbiguousPositions, []int{4}) || gen.guessCalls != 0 {
+		t.Fatalf("result = %+v, guess calls %d; want ambiguous at [4] without a guess", res, gen.guessCalls)
+	}
+	if !strings.Contains(gen.lastUser, `"02924.399"`) || gen.lastSchema["properties"].(map[string]any)["ambiguous_positions"] == nil {
+		t.Fatalf("prompt %q / schema %v lack the single-shot additions", gen.lastUser, gen.lastSchema)
+	}
+	if res.Timing == nil || !res.Timing.SingleShot || res.Timing.Guess != "" {
+		t.Fatalf("timing = %+v", res.Timing)
+	}
+
+	// A "?" left in the answer still falls back to the two-call path.
+	gen.read, gen.positions = "0292?.457", nil
+	res, err = c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Read != "02925.457" || !res.Ambiguous || gen.guessCalls != 1 || res.Timing.Guess == "" {
+		t.Fatalf("fallback = %+v (timing %+v), guess calls %d", res, res.Timing, gen.guessCalls)
+	}
+}
+
+func TestReadGasGaugePicSelfVerify(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{read: "02924.457", verified: "02924.457"}
+	files := &fakeFileStore{}
+	c := newTestClient(t, gen, files, genai.WithSelfVerify())
+	ctx := context.Background()
+
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.VerifiedRead != "02924.457" || res.VerifyDisagrees() || res.Timing.Verify == "" {
+		t.Fatalf("agreeing result = %+v (timing %+v)", res, res.Timing)
+	}
+	// The re-examination continues the conversation on the uploaded image.
+	p := gen.lastVerify
+	if len(files.uploads) != 1 || len(gen.images) != 2 || gen.images[0] != gen.images[1] ||
+		p.User != gen.lastUser || !strings.Contains(p.Answer, `"read":"02924.457"`) || !strings.Contains(p.Verify, `"02924.457"`) {
+		t.Fatalf("uploads %q, images %+v, verify prompts %+v", files.uploads, gen.images, p)
+	}
+
+	gen.verified = "02924.?51"
+	res, err = c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Read != "02924.457" || res.FirstRead != "02924.457" || res.VerifiedRead != "02924.451" || c.Stats().VerifyDisagreements != 1 {
+		t.Fatalf("disagreeing result = %+v", res)
+	}
+}
+
+func TestReadGasGaugePicCleanup(t *testing.T) {
+	t.Parallel()
+
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			t.Parallel()
+
+			var opts []genai.Option
+			if async {
+				opts = append(opts, genai.WithAsyncCleanup())
+			}
+			files := &fakeFileStore{deleteFails: 1}
+			c := newTestClient(t, &fakeGenerator{readErr: context.Canceled}, files, opts...)
+
+			// The caller gives up mid-reading; the upload must still be deleted.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if len(files.deletes) != 1 || len(files.deleteCtxErrs) != 2 {
+				t.Fatalf("deletes = %v after %d attempts; want one after a retry", files.deletes, len(files.deleteCtxErrs))
+			}
+			for _, err := range files.deleteCtxErrs {
+				if err != nil {
+					t.Fatalf("Delete ran with a done context: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadGasGaugePicErrors(t *testing.T) {
+	t.Parallel()
+
+	errBackend := errors.New("backend down")
+	tests := []struct {
+		name  string
+		gen   *fakeGenerator
+		files *fakeFileStore
+		want  string
+	}{
+		{"upload", &fakeGenerator{read: "02924.457"}, &fakeFileStore{uploadErr: errBackend}, "upload image: "},
+		{"generate", &fakeGenerator{readErr: errBackend}, &fakeFileStore{}, "analyze image: "},
+		{"guess", &fakeGenerator{read: "0292?.457", guessErr: errBackend}, &fakeFileStore{}, "guess ambiguous digits: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newTestClient(t, tt.gen, tt.files)
+			_, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+			if !errors.Is(err, errBackend) || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q wrapping %v", err, tt.want, errBackend)
+			}
+			if c.refs.Reference().Read != "" {
+				t.Fatalf("lastRead = %q after error", c.refs.Reference().Read)
+			}
+			if len(tt.files.uploads) != len(tt.files.deletes) {
+				t.Fatalf("uploads = %v, deletes = %v", tt.files.uploads, tt.files.deletes)
+			}
+		})
+	}
+
+	t.Run("invalid output", func(t *testing.T) {
+		t.Parallel()
+		ioe := &genai.InvalidOutputError{Raw: "nope", Err: errBackend}
+		c := newTestClient(t, &fakeGenerator{readErr: ioe}, &fakeFileStore{})
+		_, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+		if !errors.Is(err, genai.ErrInvalidModelOutput) || strings.HasPrefix(err.Error(), "analyze image") {
+			t.Fatalf("err = %v, want unwrapped invalid output", err)
+		}
+	})
+}
+
+func TestReadGasGaugePicLastRead(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{read: "02924.457"}
+	c := newTestClient(t, gen, &fakeFileStore{})
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if c.refs.Reference().Read != "02924.457" {
+		t.Fatalf("lastRead = %q", c.refs.Reference().Read)
+	}
+
+	// The next prompt refers to the previous reading; a failed call keeps it.
+	gen.readErr = errors.New("backend down")
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err == nil {
+		t.Fatal("ReadGasGaugePic: want error")
+	}
+	if !strings.Contains(gen.lastUser, "02924.457") {
+		t.Fatalf("prompt does not mention previous reading: %q", gen.lastUser)
+	}
+	if c.refs.Reference().Read != "02924.457" {
+		t.Fatalf("lastRead = %q after error", c.refs.Reference().Read)
+	}
+
+	gen = &fakeGenerator{read: "02924.457"}
+	c = newTestClient(t, gen, &fakeFileStore{}, genai.WithStateless())
+	for range 2 {
+		if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+			t.Fatalf("ReadGasGaugePic: %v", err)
+		}
+	}
+	if c.refs.Reference().Read != "" || strings.Contains(gen.lastUser, "02924.457") {
+		t.Fatalf("stateless client kept lastRead %q, prompt %q", c.refs.Reference().Read, gen.lastUser)
+	}
+}
+
+// modelGenerator answers with a reading per model.
+type modelGenerator struct {
+	fakeGenerator
+	reads map[string]string
+}
+
+func (g *modelGenerator) GenerateReading(_ context.Context, _ imageRef, _ readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error) {
+	g.readCalls++
+	return &genai.GasMeterReadResult{Read: g.reads[cfg.Model]}, reply{}, nil
+}
+
+func TestReadGasGaugePicEnsemble(t *testing.T) {
+	t.Parallel()
+
+	gen := &modelGenerator{
+		fakeGenerator: fakeGenerator{guess: "02924.457"},
+		reads:         map[string]string{"a": "02924.457", "b": "02924.457", "c": "02924.451"},
+	}
+	files := &fakeFileStore{}
+	c := newTestClient(t, &gen.fakeGenerator, files, genai.WithEnsemble([]string{"a", "b", "c"}, genai.ExactMatch()))
+	c.gen = gen
+
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if gen.readCalls != 3 || len(files.uploads) != 1 {
+		t.Fatalf("read calls = %d, uploads = %v; want 3 calls sharing one upload", gen.readCalls, files.uploads)
+	}
+	// The last digit is disputed, so it goes through disambiguation.
+	if gen.guessCalls != 1 || !strings.Contains(gen.lastGuess, "02924.45?") || !res.Ambiguous {
+		t.Fatalf("guess calls = %d, prompt %q, ambiguous %v", gen.guessCalls, gen.lastGuess, res.Ambiguous)
+	}
+	if res.Read != "02924.457" || len(res.Answers) != 3 || res.Model != "a+b+c" {
+		t.Fatalf("result = %+v", res)
+	}
+	if s := c.Stats(); s.Reads != 1 || s.EnsembleDisagreements != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	c = newTestClient(t, &gen.fakeGenerator, files, genai.WithEnsemble([]string{"a", "b", "c"}, genai.Majority()))
+	c.gen = gen
+	if res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil || res.Ambiguous {
+		t.Fatalf("majority = %+v, %v", res, err)
+	}
+	if c.Stats().EnsembleDisagreements != 0 {
+		t.Fatalf("majority counted a disagreement")
+	}
+}
+
+func TestReadGasGaugePicMaxImageSize(t *testing.T) {
+	t.Parallel()
+
+	gen, files := &fakeGenerator{read: "02924.457"}, &fakeFileStore{}
+	c := newTestClient(t, gen, files, genai.WithMaxImageSize(4))
+
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("image at the limit: %v", err)
+	}
+	_, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg!"))
+	if !errors.Is(err, genai.ErrImageTooLarge) || gen.readCalls != 1 {
+		t.Fatalf("err = %v after %d reads, want ErrImageTooLarge without a reading", err, gen.readCalls)
+	}
+}
+
+// BenchmarkReadGasGaugePic shows that the image is streamed to the Files API:
+// allocations do not grow with its 4 MiB size.
+func BenchmarkReadGasGaugePic(b *testing.B) {
+	c, err := newClient(&fakeGenerator{read: "02924.457"}, &fakeFileStore{}, "model", "", "")
+	if err != nil {
+		b.Fatalf("newClient: %v", err)
+	}
+	img := bytes.Repeat([]byte{0xff}, 4<<20)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.ReadGasGaugePic(ctx, bytes.NewReader(img)); err != nil {
+			b.Fatalf("ReadGasGaugePic: %v", err)
+		}
+	}
+}
+
+func TestUploadNamesAndCollectOrphans(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 11, 7, 5, 13, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour)
+	files := &fakeFileStore{existing: []uploadedFile{
+		{Name: "files/old", DisplayName: "cabin-gas/home/20251107T031300.000Z", CreatedAt: old},
+		{Name: "files/old-example", DisplayName: "cabin-gas/home/example-1", CreatedAt: old},
+		{Name: "files/recent", DisplayName: "cabin-gas/home/20251107T051259.000Z", CreatedAt: now.Add(-time.Second)},
+		{Name: "files/other", DisplayName: "cabin-gasworks/home/20251107T031300.000Z", CreatedAt: old},
+		{Name: "files/unrelated", DisplayName: "Vacation photo", CreatedAt: old},
+	}}
+	c := newTestClient(t, &fakeGenerator{read: "02924.457"}, files,
+		genai.WithMeter(genai.Meter{ID: "home"}),
+		genai.WithUploadPrefix("cabin-gas/"),
+		genai.WithClock(genaitest.NewClock(now)),
+		genai.WithDebug(true),
+		genai.WithExampleImages([]genai.Example{{Image: strings.NewReader("example"), ExpectedRead: "02924.000"}}),
+	)
+
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	want := []string{"cabin-gas/home/20251107T051300.000Z", "cabin-gas/home/example-1"}
+	if !slices.Equal(files.uploads, want) {
+		t.Fatalf("uploads = %q, want %q", files.uploads, want)
+	}
+	if res.UploadedFile != want[0] {
+		t.Fatalf("UploadedFile = %q, want %q", res.UploadedFile, want[0])
+	}
+
+	// The current example upload (files/1) is listed too and must be kept.
+	files.existing = append(files.existing, uploadedFile{Name: "files/1", DisplayName: want[1], CreatedAt: old})
+	files.deletes = nil
+	n, err := c.CollectOrphans(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("CollectOrphans: %v", err)
+	}
+	if wantDeleted := []string{"files/old", "files/old-example"}; n != 2 || !slices.Equal(files.deletes, wantDeleted) {
+		t.Fatalf("CollectOrphans deleted %d: %v, want %v", n, files.deletes, wantDeleted)
+	}
+}
+
+func TestReadGasGaugePicEmptyOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		gen    *fakeGenerator
+		want   error
+		reason string
+	}{
+		{"empty read", &fakeGenerator{read: "", finish: "stop"}, genai.ErrEmptyReading, `"stop"`},
+		{"blank read", &fakeGenerator{read: "  ", finish: "stop"}, genai.ErrEmptyReading, `"stop"`},
+		{"truncated json", &fakeGenerator{output: `{"read":"0292`, finish: genai.FinishLength}, genai.ErrTruncatedOutput, `"length"`},
+		{"truncated empty read", &fakeGenerator{read: "", finish: genai.FinishLength}, genai.ErrTruncatedOutput, `"length"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gen := tt.gen
+			c := newTestClient(t, gen, &fakeFileStore{}, genai.WithSeed("02924.457", time.Time{}))
+			_, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.reason) {
+				t.Fatalf("err = %v, want the finish reason %s", err, tt.reason)
+			}
+			if gen.guessCalls != 0 {
+				t.Fatalf("guess calls = %d, want none", gen.guessCalls)
+			}
+			if c.reference(c.cfg.Load()).Read != "02924.457" {
+				t.Fatalf("prevRead = %q after a failed reading", c.reference(c.cfg.Load()).Read)
+			}
+		})
+	}
+}
+
+func TestReadGasGaugePicGeneration(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{output: `{"read":"0292`, finish: genai.FinishLength}
+	c, err := newClient(gen, &fakeFileStore{}, "googleai/gemini-2.5-pro", "", "")
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); !errors.Is(err, genai.ErrTruncatedOutput) {
+		t.Fatalf("err = %v, want ErrTruncatedOutput", err)
+	}
+	if len(gen.gens) != 2 || gen.gens[0].MaxOutputTokens != 1152 || gen.gens[1].MaxOutputTokens != 4*1152 {
+		t.Fatalf("generations %+v, want one retry with four times the limit", gen.gens)
+	}
+	gcfg := contentConfig(genConfig{Gen: gen.gens[0]})
+	if gcfg.ThinkingConfig == nil || *gcfg.ThinkingConfig.ThinkingBudget != 128 || gcfg.MaxOutputTokens != 1152 {
+		t.Fatalf("content config: thinking %+v, max output tokens %d", gcfg.ThinkingConfig, gcfg.MaxOutputTokens)
+	}
+}
+
+func TestStatsConcurrentReads(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{read: "0292?.457", guess: "02924.457", usage: genai.Usage{InputTokens: 100, OutputTokens: 10}}
+	examples := []genai.Example{{Image: strings.NewReader("example jpeg"), ExpectedRead: "01234.567"}}
+	c := newTestClient(t, gen, &fakeFileStore{}, genai.WithExampleImages(examples), genai.WithMaxImageSize(8))
+	ctx := context.Background()
+
+	const workers, reads = 8, 25
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range reads {
+				img := "jpeg"
+				if (w+i)%5 == 0 {
+					img = "oversized jpeg"
+				}
+				c.ReadGasGaugePic(ctx, strings.NewReader(img))
+				_ = c.Stats() // snapshots race with the reads
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := c.Stats()
+	fails := int64(workers * reads / 5)
+	if s.Reads != workers*reads || s.Failures != fails || s.Successes != s.Reads-fails || s.FailuresBy.ImageTooLarge != fails {
+		t.Fatalf("Stats = %+v; want %d reads, %d failures", s, workers*reads, fails)
+	}
+	if s.Ambiguous != s.Successes || s.Guessed != s.Successes || s.Total.Count != s.Successes {
+		t.Fatalf("ambiguous %d, guessed %d, timed %d; want %d", s.Ambiguous, s.Guessed, s.Total.Count, s.Successes)
+	}
+	// Every successful reading calls the model twice and all but the first
+	// reuse the upload of the example.
+	if s.InputTokens != 200*s.Successes || s.OutputTokens != 20*s.Successes || s.CacheHits != s.Successes-1 {
+		t.Fatalf("tokens %d/%d, cache hits %d", s.InputTokens, s.OutputTokens, s.CacheHits)
+	}
+}
+
+func TestDetectFlow(t *testing.T) {
+	t.Parallel()
+
+	gen := &fakeGenerator{flow: `{"flow_detected":true,"rotation":0.25,"note":"quarter turn"}`}
+	files := &fakeFileStore{}
+	c := newTestClient(t, gen, files, genai.WithMeter(genai.Meter{ID: "home", IntDigits: 5, FracDigits: 3}))
+	res, err := c.DetectFlow(context.Background(), strings.NewReader("first"), strings.NewReader("second"))
+	if err != nil {
+		t.Fatalf("DetectFlow: %v", err)
+	}
+	if !res.FlowDetected || res.Rotation != 0.25 || res.Model != "model" {
+		t.Fatalf("DetectFlow = %+v", res)
+	}
+	if len(files.uploads) != 2 || len(gen.flowImages) != 2 || gen.flowImages[0].URI == gen.flowImages[1].URI {
+		t.Fatalf("uploads %q, images asked about %+v; want each image uploaded once", files.uploads, gen.flowImages)
+	}
+	if !strings.Contains(files.uploads[0], "/home/") || !slices.Equal(files.deletes, []string{"files/1", "files/0"}) {
+		t.Fatalf("uploads %q, deletes %q; want both deleted", files.uploads, files.deletes)
+	}
+
+	gen.flow = "no idea"
+	if _, err := c.DetectFlow(context.Background(), strings.NewReader("first"), strings.NewReader("second")); !errors.Is(err, genai.ErrInvalidModelOutput) {
+		t.Fatalf("DetectFlow of a bad answer: %v, want ErrInvalidModelOutput", err)
+	}
+	if len(files.deletes) != 4 {
+		t.Fatalf("deletes %q, want the images of the failed call deleted too", files.deletes)
+	}
+}
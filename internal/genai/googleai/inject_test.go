@@ -0,0 +1,253 @@
+package googleai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/faults"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+// faultyGenerator and faultyFileStore route calls through [faults.Injector]s.
+type faultyGenerator struct {
+	generator
+	read, guess *faults.Injector
+}
+
+func (g faultyGenerator) GenerateReading(ctx context.Context, img imageRef, p readingPrompts, cfg genConfig) (*genai.GasMeterReadResult, reply, error) {
+	if err := g.read.Inject(ctx); err != nil {
+		return nil, reply{}, err
+	}
+	return g.generator.GenerateReading(ctx, img, p, cfg)
+}
+
+func (g faultyGenerator) GenerateText(ctx context.Context, prompt string, cfg genConfig) (reply, error) {
+	if err := g.guess.Inject(ctx); err != nil {
+		return reply{}, err
+	}
+	return g.generator.GenerateText(ctx, prompt, cfg)
+}
+
+type faultyFileStore struct {
+	fileStore
+	inj *faults.Injector
+}
+
+func (f faultyFileStore) Upload(ctx context.Context, r io.Reader, mimeType, displayName string) (uploadedFile, error) {
+	if err := f.inj.Inject(ctx); err != nil {
+		return uploadedFile{}, err
+	}
+	return f.fileStore.Upload(ctx, r, mimeType, displayName)
+}
+
+func TestReadGasGaugePicInjectedFaults(t *testing.T) {
+	t.Parallel()
+
+	readInj, guessInj, fileInj := &faults.Injector{}, &faults.Injector{}, &faults.Injector{}
+	files := &fakeFileStore{}
+	c := newTestClient(t, &fakeGenerator{read: "0292?.457", guess: "02924.457"}, files)
+	c.gen = faultyGenerator{c.gen, readInj, guessInj}
+	c.files = faultyFileStore{c.files, fileInj}
+	ctx := context.Background()
+
+	errUnavailable := errors.New("503 service unavailable")
+	fileInj.FailNext(1, nil)
+	readInj.FailNext(1, errUnavailable)
+	guessInj.FailNext(1, context.DeadlineExceeded)
+	for _, want := range []struct {
+		prefix string
+		err    error
+	}{
+		{"upload image: ", faults.ErrInjected},
+		{"analyze image: ", errUnavailable},
+		{"guess ambiguous digits: ", context.DeadlineExceeded},
+	} {
+		_, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+		if !errors.Is(err, want.err) || !strings.HasPrefix(err.Error(), want.prefix) {
+			t.Fatalf("err = %v, want %q wrapping %v", err, want.prefix, want.err)
+		}
+		if c.refs.Reference().Read != "" {
+			t.Fatalf("lastRead = %q after a fault", c.refs.Reference().Read)
+		}
+	}
+
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil || res.Read != "02924.457" {
+		t.Fatalf("after recovery = %v, %v", res, err)
+	}
+	// Every successful upload is deleted, whichever step failed afterwards.
+	if len(files.uploads) != 3 || len(files.deletes) != 3 {
+		t.Fatalf("uploads = %v, deletes = %v; want 3 each", files.uploads, files.deletes)
+	}
+}
+
+func TestReadGasGaugePicBreaker(t *testing.T) {
+	t.Parallel()
+
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC))
+	b := genai.NewBreakerWithClock(2, 10*time.Minute, clock)
+	readInj := &faults.Injector{}
+	files := &fakeFileStore{}
+	c := newTestClient(t, &fakeGenerator{read: "02924.457"}, files, genai.WithClock(clock))
+	c.gen = faultyGenerator{c.gen, readInj, &faults.Injector{}}
+	r := genai.Chain(c, genai.BreakerMiddleware(b))
+	ctx := context.Background()
+
+	readInj.FailAll(errors.New("503 service unavailable"))
+	for range 2 {
+		if _, err := r.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err == nil {
+			t.Fatal("ReadGasGaugePic succeeded during the outage")
+		}
+	}
+	if _, err := r.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); !errors.Is(err, genai.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if len(files.uploads) != 2 {
+		t.Fatalf("uploads = %d, want 2 (none while open)", len(files.uploads))
+	}
+	if b.State() != genai.BreakerOpen || b.Trips() != 1 {
+		t.Fatalf("breaker = %v after %d trips", b.State(), b.Trips())
+	}
+
+	readInj.Reset()
+	clock.Advance(10 * time.Minute)
+	if res, err := r.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil || res.Read != "02924.457" {
+		t.Fatalf("probe = %v, %v", res, err)
+	}
+	if b.State() != genai.BreakerClosed {
+		t.Fatalf("breaker = %v after a successful probe", b.State())
+	}
+}
+
+func TestReadGasGaugePicRetriesReuseUpload(t *testing.T) {
+	t.Parallel()
+
+	errUnavailable := errors.New("503 service unavailable")
+	tests := []struct {
+		name      string
+		fails     int
+		wantErr   bool
+		wantModel string
+	}{
+		{"third attempt succeeds", 2, false, "fallback"},
+		{"every attempt fails", 3, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			readInj := &faults.Injector{}
+			readInj.FailNext(tt.fails, errUnavailable)
+			files := &fakeFileStore{}
+			c := newTestClient(t, &fakeGenerator{read: "02924.457"}, files, genai.WithRetries(2, "fallback"))
+			c.gen = faultyGenerator{c.gen, readInj, &faults.Injector{}}
+
+			res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && res.Model != tt.wantModel {
+				t.Fatalf("Model = %q, want %q", res.Model, tt.wantModel)
+			}
+			if calls, _ := readInj.Calls(); calls != 3 {
+				t.Fatalf("reading calls = %d, want 3", calls)
+			}
+			if len(files.uploads) != 1 || len(files.deletes) != 1 {
+				t.Fatalf("uploads = %v, deletes = %v; want one of each", files.uploads, files.deletes)
+			}
+		})
+	}
+}
+
+// flakyFileStore fails the first fails uploads halfway through the image,
+// the first of them by stalling until the attempt times out.
+type flakyFileStore struct {
+	fileStore
+	fails int
+	sent  int
+}
+
+func (f *flakyFileStore) Upload(ctx context.Context, r io.Reader, mimeType, displayName string) (uploadedFile, error) {
+	if f.fails > 0 {
+		f.fails--
+		half := make([]byte, 2)
+		n, _ := io.ReadFull(r, half)
+		f.sent += n
+		if f.fails == 0 {
+			return uploadedFile{}, errors.New("connection reset by peer")
+		}
+		<-ctx.Done()
+		return uploadedFile{}, ctx.Err()
+	}
+	return f.fileStore.Upload(ctx, r, mimeType, displayName)
+}
+
+// onceReader fails if read again after its end.
+type onceReader struct {
+	r    io.Reader
+	done bool
+}
+
+func (o *onceReader) Read(p []byte) (int, error) {
+	if o.done {
+		return 0, errors.New("source read twice")
+	}
+	n, err := o.r.Read(p)
+	o.done = err == io.EOF
+	return n, err
+}
+
+func TestReadGasGaugePicRetriesUpload(t *testing.T) {
+	t.Parallel()
+
+	files := &fakeFileStore{}
+	c := newTestClient(t, &fakeGenerator{read: "02924.457"}, files, genai.WithUploadRetries(2, 10*time.Millisecond))
+	flaky := &flakyFileStore{fileStore: files, fails: 2}
+	c.files = flaky
+
+	res, err := c.ReadGasGaugePic(context.Background(), &onceReader{r: strings.NewReader("jpeg")})
+	if err != nil || res.Read != "02924.457" {
+		t.Fatalf("ReadGasGaugePic = %v, %v", res, err)
+	}
+	if res.Timing == nil || res.Timing.UploadAttempts != 3 || res.Timing.UploadRetriedBytes != 8 {
+		t.Fatalf("Timing = %+v; want 3 upload attempts, 8 bytes retried", res.Timing)
+	}
+	if s := c.Stats(); s.UploadRetries != 2 || s.UploadRetriedBytes != 8 {
+		t.Fatalf("Stats = %+v; want 2 upload retries, 8 bytes", s)
+	}
+	if len(files.uploads) != 1 || len(files.deletes) != 1 {
+		t.Fatalf("uploads = %v, deletes = %v; want one of each", files.uploads, files.deletes)
+	}
+
+	// Out of retries the last error is returned.
+	flaky.fails = 3
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("err = %v, want the error of the last attempt", err)
+	}
+	if s := c.Stats(); s.UploadRetries != 4 || s.Failures != 1 {
+		t.Fatalf("Stats = %+v; want 4 upload retries, 1 failure", s)
+	}
+}
+
+func TestReadGasGaugePicUploadTimeout(t *testing.T) {
+	t.Parallel()
+
+	files := &fakeFileStore{}
+	c := newTestClient(t, &fakeGenerator{read: "02924.457"}, files,
+		genai.WithUploadRetries(10, 10*time.Millisecond), genai.WithTimeouts(genai.Timeouts{Upload: 35 * time.Millisecond}))
+	c.files = &flakyFileStore{fileStore: files, fails: 20}
+
+	// The upload phase ends before its retries do.
+	_, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	var te *genai.TimeoutError
+	if !errors.As(err, &te) || te.Phase != genai.PhaseUpload || te.Call {
+		t.Fatalf("ReadGasGaugePic: %v, want an upload timeout", err)
+	}
+	if s := c.Stats(); s.UploadRetries >= 10 || s.FailuresBy.Timeout != 1 {
+		t.Fatalf("Stats = %+v; want the retries cut short by the timeout", s)
+	}
+}
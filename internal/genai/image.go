@@ -0,0 +1,60 @@
+package genai
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrImageTooLarge is returned for images over the size set by [WithMaxImageSize].
+var ErrImageTooLarge = errors.New("image too large")
+
+// WithMaxImageSize rejects images larger than n bytes with [ErrImageTooLarge]
+// before they are sent anywhere. Zero (default) means no limit.
+func WithMaxImageSize(n int64) Option {
+	return func(o *Options) {
+		o.MaxImageSize = n
+	}
+}
+
+// ImageReader reads an image, failing with [ErrImageTooLarge] once more than
+// max bytes have been read. See [LimitImage].
+type ImageReader struct {
+	r        io.Reader
+	left     int64
+	tooLarge bool
+}
+
+// LimitImage wraps r so that reading more than max bytes fails with
+// [ErrImageTooLarge]; a max of zero or less disables the limit.
+func LimitImage(r io.Reader, max int64) *ImageReader {
+	if max <= 0 {
+		return &ImageReader{r: r, left: -1}
+	}
+	return &ImageReader{r: r, left: max}
+}
+
+func (l *ImageReader) Read(p []byte) (int, error) {
+	if l.tooLarge {
+		return 0, ErrImageTooLarge
+	}
+	if l.left < 0 {
+		return l.r.Read(p)
+	}
+	// Read one byte past the limit to tell "exactly max" from "too large".
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		l.tooLarge = true
+		return int(l.left), ErrImageTooLarge
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// TooLarge reports whether the limit was hit, for callers that only see an
+// error wrapped beyond recognition, e.g. by an SDK.
+func (l *ImageReader) TooLarge() bool {
+	return l.tooLarge
+}
@@ -0,0 +1,71 @@
+package genai
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Image issues of [Issue.Kind], reported by the model along with the reading.
+const (
+	IssueNone        = "none"
+	IssueGlare       = "glare"        // reflections or direct light
+	IssueBlur        = "blur"         // out of focus or motion blur
+	IssueObstruction = "obstruction"  // condensation, dirt or an object over the counter
+	IssuePartialView = "partial_view" // the counter is cut off by the frame
+)
+
+// IssueKinds are the kinds the model may report, IssueNone first.
+var IssueKinds = []string{IssueNone, IssueGlare, IssueBlur, IssueObstruction, IssuePartialView}
+
+// Issue is a physical condition of the image that makes it hard to read,
+// telling a camera problem from a prompt problem.
+type Issue struct {
+	Kind string `json:"kind"`
+	// Note is the model's description, e.g. "sun reflection over the last two digits".
+	Note string `json:"note,omitempty"`
+}
+
+// UnmarshalJSON also accepts a bare kind, as models answering without a
+// response schema sometimes give.
+func (i *Issue) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '"' {
+		*i = Issue{}
+		return json.Unmarshal(b, &i.Kind)
+	}
+	type issue Issue // without the method
+	return json.Unmarshal(b, (*issue)(i))
+}
+
+// String is the kind followed by the note, e.g. "glare (sun on the glass)".
+func (i *Issue) String() string {
+	if i.Note == "" {
+		return i.Kind
+	}
+	return i.Kind + " (" + i.Note + ")"
+}
+
+// CleanIssue normalizes the issue the model reported: nil for none. Kinds
+// outside [IssueKinds] are kept as reported.
+func CleanIssue(i *Issue) *Issue {
+	if i == nil {
+		return nil
+	}
+	kind := strings.ToLower(strings.TrimSpace(i.Kind))
+	kind = strings.NewReplacer(" ", "_", "-", "_").Replace(kind)
+	if kind == "" || kind == IssueNone {
+		return nil
+	}
+	return &Issue{Kind: kind, Note: strings.TrimSpace(i.Note)}
+}
+
+// issueJSONSchema is the "issue" property of the answer schemas.
+var issueJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"kind": map[string]any{"type": "string", "enum": IssueKinds},
+		"note": map[string]any{"type": "string"},
+	},
+	"required":             []string{"kind", "note"},
+	"additionalProperties": false,
+}
@@ -0,0 +1,34 @@
+package genai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIssue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want *Issue
+	}{
+		{name: "object", in: `{"read":"1","date":"","issue":{"kind":"glare","note":" sun on the glass "}}`, want: &Issue{Kind: IssueGlare, Note: "sun on the glass"}},
+		{name: "none", in: `{"read":"1","date":"","issue":{"kind":"none","note":""}}`},
+		{name: "bare kind", in: `{"read":"1","date":"","issue":"Partial View"}`, want: &Issue{Kind: IssuePartialView}},
+		{name: "unknown kind kept", in: `{"read":"1","date":"","issue":{"kind":"spider","note":"web"}}`, want: &Issue{Kind: "spider", Note: "web"}},
+		{name: "absent", in: `{"read":"1","date":""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := ParseReadResult(tt.in)
+			if err != nil {
+				t.Fatalf("ParseReadResult(%q): %v", tt.in, err)
+			}
+			if got := CleanIssue(res.Issue); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("issue = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package genai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is used when no locale is configured.
+const DefaultLocale = "en"
+
+// PromptSet is the set of built-in prompts and date layouts for one locale.
+type PromptSet struct {
+	System       string   // text/template, rendered once per client with [PromptData]
+	Image        string   // text/template, rendered per call with [PromptData]
+	DialSystem   string   // System for [MeterDials]
+	DialImage    string   // Image for [MeterDials]
+	Disambiguate string   // fmt format taking the ambiguous reading and the previous reading
+	SingleShot   string   // fmt format taking the previous reading, appended to Image by [WithSingleShot]
+	Verify       string   // fmt format taking the first reading, asked after it by [WithSelfVerify]
+	Flow         string   // fmt format taking the [PromptData.MeterName], asked by [FlowDetector]
+	DateLayouts  []string // tried after RFC3339 by [PromptSet.ParseDate]
+	// MeterNames and MeterHints are [PromptData.MeterName] and
+	// [PromptData.MeterHint] per utility.
+	MeterNames map[string]string
+	MeterHints map[string]string
+}
+
+var localePromptSets = map[string]PromptSet{
+	"en": {
+		System:       enSystemPrompt,
+		Image:        enImagePrompt,
+		DialSystem:   enDialSystemPrompt,
+		DialImage:    enDialImagePrompt,
+		Disambiguate: enDisambiguatePromptFmt,
+		SingleShot:   enSingleShotPromptFmt,
+		Verify:       enVerifyPromptFmt,
+		Flow:         enFlowPromptFmt,
+		MeterNames: map[string]string{
+			UtilityGas:         "gas meter",
+			UtilityWater:       "water meter",
+			UtilityElectricity: "electricity meter",
+		},
+		MeterHints: map[string]string{
+			UtilityWater:       "Read only the main counter in cubic metres; ignore the small red pointer dials for litres.",
+			UtilityElectricity: "Read only the digit counter; ignore the rotating disc and its red mark.",
+		},
+		DateLayouts: []string{
+			"2006-01-02 15:04:05",
+			"2006-01-02 15:04",
+			"2006/01/02 15:04:05",
+			"2006/01/02 15:04",
+			"01/02/2006 15:04:05",
+			"2006-01-02",
+			"2006.01.02 15:04:05",
+			"2006.01.02 15:04",
+			"2006.01.02",
+		},
+	},
+	"ko": {
+		System:       koSystemPrompt,
+		Image:        koImagePrompt,
+		DialSystem:   koDialSystemPrompt,
+		DialImage:    koDialImagePrompt,
+		Disambiguate: koDisambiguatePromptFmt,
+		SingleShot:   koSingleShotPromptFmt,
+		Verify:       koVerifyPromptFmt,
+		Flow:         koFlowPromptFmt,
+		MeterNames: map[string]string{
+			UtilityGas:         "가스 계량기",
+			UtilityWater:       "수도 계량기",
+			UtilityElectricity: "전력량계",
+		},
+		MeterHints: map[string]string{
+			UtilityWater:       "세제곱미터 단위의 주 숫자 카운터만 읽고, 리터 단위의 작은 빨간 바늘 다이얼은 무시하세요.",
+			UtilityElectricity: "숫자 카운터만 읽고, 회전하는 원판과 그 빨간 표시는 무시하세요.",
+		},
+		DateLayouts: []string{
+			"2006년 1월 2일 15시 4분 5초",
+			"2006년 1월 2일 15시 4분",
+			"2006년 1월 2일 PM 3시 4분",
+			"2006년 1월 2일 15:04:05",
+			"2006년 1월 2일 15:04",
+			"2006년 1월 2일",
+			"2006.1.2 15:04:05",
+			"2006.1.2 15:04",
+			"2006. 1. 2. 15:04:05",
+			"2006. 1. 2. 15:04",
+			"2006. 1. 2. PM 3:04",
+			"2006-01-02 15:04:05",
+			"2006-01-02 15:04",
+			"2006.1.2",
+			"2006. 1. 2.",
+		},
+	},
+}
+
+// Locales lists the locales with built-in prompt sets.
+func Locales() []string {
+	return []string{"en", "ko"}
+}
+
+// LocalePromptSet returns the built-in prompt set for locale ("en", "ko").
+func LocalePromptSet(locale string) (PromptSet, error) {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	ps, ok := localePromptSets[strings.ToLower(locale)]
+	if !ok {
+		return PromptSet{}, fmt.Errorf("unsupported locale %q", locale)
+	}
+	return ps, nil
+}
+
+// ParseDate parses a model-reported date as RFC3339 or one of the locale's
+// layouts, after [NormalizeDate]. Layouts without a UTC offset are
+// interpreted in loc.
+func (ps PromptSet) ParseDate(s string, loc *time.Location) (time.Time, error) {
+	n := NormalizeDate(s)
+	if t, err := time.Parse(time.RFC3339, n); err == nil {
+		return t, nil
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range ps.DateLayouts {
+		if t, err := time.ParseInLocation(layout, n, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+// weekdayRe matches a parenthesized weekday such as "(금)" or "(Fri)".
+var weekdayRe = regexp.MustCompile(`\([^()0-9]*\)`)
+
+// NormalizeDate maps full-width digits and punctuation to ASCII, replaces
+// the Korean 오전/오후 by AM/PM, drops a parenthesized weekday and collapses
+// spaces. The weekday is not checked: when it contradicts the date, the
+// numbers are trusted.
+func NormalizeDate(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		case r == '\u3000': // ideographic space
+			return ' '
+		}
+		return r
+	}, s)
+	s = weekdayRe.ReplaceAllString(s, " ")
+	s = strings.NewReplacer("오전", "AM", "오후", "PM").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// SetDateParsed sets out.DateParsed to out.Date parsed with the prompt set's
+// layouts, in loc. An unrecognized date leaves it zero; the reading is still valid.
+func (p *Prompts) SetDateParsed(out *GasMeterReadResult, loc *time.Location) {
+	if loc == nil {
+		loc = time.Local
+	}
+	t, err := p.ParseDate(out.Date, loc)
+	if err != nil {
+		return
+	}
+	out.DateParsed = t.In(loc)
+}
+
+// Prompts are the effective prompts of a client: the locale's built-in set
+// with any custom prompt overriding it.
+type Prompts struct {
+	PromptSet
+	SystemText string // rendered system prompt
+	ImageTmpl  *PromptTemplate
+	// Hash is a truncated SHA-256 over the rendered system prompt and the image
+	// and disambiguation templates (and the single-shot and verification ones when enabled). The image prompt is hashed unrendered since
+	// it embeds the previous reading, which changes every call.
+	Hash string
+}
+
+// NewPrompts resolves the prompts for o. Non-empty system or image override
+// the locale defaults.
+func NewPrompts(o Options, system, image string) (*Prompts, error) {
+	ps, err := LocalePromptSet(o.Locale)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := LookupUtility(o.Meter.Utility); err != nil {
+		return nil, err
+	}
+	switch o.Meter.Type {
+	case MeterCounter, "":
+	case MeterDials:
+		ps.System, ps.Image = ps.DialSystem, ps.DialImage
+	default:
+		return nil, fmt.Errorf("unsupported meter type %q", o.Meter.Type)
+	}
+	if strings.TrimSpace(system) != "" {
+		ps.System = system
+	}
+	if strings.TrimSpace(image) != "" {
+		ps.Image = image
+	}
+
+	sysTmpl, err := ParsePromptTemplate(ps.System)
+	if err != nil {
+		return nil, fmt.Errorf("system prompt: %w", err)
+	}
+	sysData := ps.data(o.Meter, "")
+	sysData.CounterBox = o.CounterBox
+	sysData.Serial = sysData.Serial || o.ReadSerial
+	sysText, err := sysTmpl.Render(sysData)
+	if err != nil {
+		return nil, fmt.Errorf("system prompt: %w", err)
+	}
+	imgTmpl, err := ParsePromptTemplate(ps.Image)
+	if err != nil {
+		return nil, fmt.Errorf("image prompt: %w", err)
+	}
+	parts := []string{sysText, ps.Image, ps.Disambiguate}
+	if o.SingleShotMode() {
+		parts = append(parts, ps.SingleShot)
+	}
+	if o.SelfVerifyMode() {
+		parts = append(parts, ps.Verify)
+	}
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return &Prompts{
+		PromptSet:  ps,
+		SystemText: sysText,
+		ImageTmpl:  imgTmpl,
+		Hash:       hex.EncodeToString(h.Sum(nil))[:12],
+	}, nil
+}
+
+// Data returns the [PromptData] of m and prevRead with the locale's meter name and hint.
+func (p *Prompts) Data(m Meter, prevRead string) PromptData {
+	return p.data(m, prevRead)
+}
+
+func (ps PromptSet) data(m Meter, prevRead string) PromptData {
+	d := NewPromptData(m, prevRead)
+	utility := m.Utility
+	if utility == "" {
+		utility = UtilityGas
+	}
+	d.MeterName, d.MeterHint = ps.MeterNames[utility], ps.MeterHints[utility]
+	return d
+}
+
+// DisambiguatePrompt formats the disambiguation prompt.
+func (p *Prompts) DisambiguatePrompt(ambiguous, prevRead string) string {
+	return fmt.Sprintf(p.Disambiguate, ambiguous, prevRead)
+}
+
+const enSystemPrompt = `Analyze the provided image of a {{.MeterName}}. Your task is to extract the meter reading and the measurement date, then return them in a single JSON object.
+
+Output Format: Respond only with the JSON object. Do not add any explanatory text.
+
+{
+  "read": "string",
+  "confidences": [number],
+  "date": "string",
+  "issue": {"kind": "none" | "glare" | "blur" | "obstruction" | "partial_view", "note": "string"}{{if .Serial}},
+  "serial_number": "string"{{end}}{{if .CounterBox}},
+  "counter_box": {"x_min": number, "y_min": number, "x_max": number, "y_max": number}{{end}}
+}
+
+## Instructions for JSON Fields
+
+### 1. read (Meter Reading):
+
+The reading is composed of {{.IntDigits}} integer digits{{if .FracDigits}} and {{.FracDigits}} decimal digits (usually in a separate box or in red){{end}}.
+{{- if .FracDigits}}
+Combine these into a single string, separating the integer and decimal parts with a . (decimal point).
+{{- end}}
+If any single digit is unclear, partially visible, or appears to be mid-rotation, represent that specific digit with a question mark (?).
+{{- if .MeterHint}}
+{{.MeterHint}}
+{{- end}}
+
+- Format: "{{.Pattern}}" (YOU MUST USE THIS FORMAT! SHOW ALL DIGITS! DO NOT MISS ANY DIGIT!)
+- Leading Zeros: You MUST preserve any leading zeros.
+- Ambiguous digits are represented with a question mark (?).
+- Confidences: In "confidences", give your confidence in each digit and question mark of "read", left to right, as a number from 0 (a guess) to 1 (certain).
+
+### 2. date (Measurement Date):
+
+Find the date and time imprinted at the top of the image.
+Format this value as an RFC3339 string.
+The time provided is local time for UTC+9. You MUST include this offset in the final string.
+
+- Example: "2025-10-28T14:30:00+09:00"
+
+### 3. issue (Image Condition):
+
+Report anything about the image that makes the counter hard to read.
+Set "kind" to one of "glare" (reflections or direct light), "blur" (out of focus or motion blur),
+"obstruction" (condensation, dirt or an object over the counter), "partial_view" (the counter is cut off by the frame)
+or "none" if the counter is clearly visible. If there are several, report the one that affects the reading most.
+Describe it briefly in "note", e.g. "sun reflection over the last two digits" (an empty string for none).
+{{- if .Serial}}
+
+### 4. serial_number (Meter Serial Number):
+
+Find the serial number printed on the meter body or its label, usually near the counter, and copy its letters and digits exactly.
+Represent every character you cannot read with certainty with a question mark (?). Use an empty string if there is no serial number in view.
+{{- end}}
+{{- if .CounterBox}}
+
+### {{if .Serial}}5{{else}}4{{end}}. counter_box (Counter Position):
+
+Report the smallest rectangle containing all digits of the counter{{if .FracDigits}}, including the decimal digits{{end}}, as fractions of the image size:
+x from 0 (left edge) to 1 (right edge) and y from 0 (top edge) to 1 (bottom edge).
+{{- end}}
+`
+
+const enImagePrompt = `Process the image and extract the reading and date.
+The counter has {{.IntDigits}} integer digits and {{.FracDigits}} decimal digits; the unit is {{.Unit}}.
+{{- if .PrevRead}}
+The previous reading was {{.PrevRead}}.
+{{- end}}`
+
+const enDisambiguatePromptFmt = `The value "%s" represents the output of a analog-meter-reading analysis performed on an image.
+Uncertain digits within the reading are denoted by the "?" character.
+
+Using the previously recorded meter value "%s" as a reference (only if it is not empty),
+infer and replace the "?" characters to estimate the most probable complete reading.
+
+Instructions:
+- Return a string with the exact same length as the input value.
+- Output only the predicted value, without any explanations or additional text.
+`
+
+const enSingleShotPromptFmt = `If a digit is unclear or mid-rotation, do not answer "?" for it: use the previous reading "%s" (only if it is not empty)
+to choose the most probable digit, keeping in mind that the reading never decreases.
+List the zero-based character positions of every digit you resolved this way in "ambiguous_positions" (an empty array if there are none).
+Use "?" only for digits you still cannot decide.`
+
+const enVerifyPromptFmt = `Re-examine the image, digit by digit from left to right. Is the reading "%s" correct?
+Answer with the corrected reading, or the same one if it is correct, in the same JSON object as before.
+Mark only the digits you still cannot read with "?".`
+
+const enFlowPromptFmt = `The two images show the same %s a few seconds apart, the first one first.
+Find its small flow indicator: the little rotating wheel, star or disc that turns whenever anything flows, even while the digits of the counter stand still.
+Compare its position in the two images and answer with this JSON object only:
+
+{"flow_detected": boolean, "rotation": number, "note": "string"}
+
+- flow_detected: true if the indicator moved between the images, false if it is in the same position.
+- rotation: your estimate of how far it turned, as a fraction of a full turn from 0 to 1 (0 if it did not move).
+- note: a brief description, e.g. "indicator turned about a quarter", or why you cannot tell, e.g. "indicator not visible".
+If you cannot find the indicator in both images, answer false and 0 and say so in note.`
+
+const enDialSystemPrompt = `Analyze the provided image of a {{.MeterName}} with {{.Digits}} small clock-style dials. Your task is to report the pointer position of every dial and the measurement date in a single JSON object.
+
+Output Format: Respond only with the JSON object. Do not add any explanatory text.
+
+{
+  "dials": [{"value": number, "direction": "cw" | "ccw"}],
+  "date": "string",
+  "issue": {"kind": "none" | "glare" | "blur" | "obstruction" | "partial_view", "note": "string"}{{if .Serial}},
+  "serial_number": "string"{{end}}{{if .CounterBox}},
+  "counter_box": {"x_min": number, "y_min": number, "x_max": number, "y_max": number}{{end}}
+}
+
+## Instructions for JSON Fields
+
+### 1. dials:
+
+List the {{.Digits}} dials from left (most significant) to right.
+For every dial report the exact pointer position as a number between 0 and 10 (e.g. 3.6 when the pointer is a bit past the middle of 3 and 4),
+reading the numbers in the dial's own rotation direction. Neighbouring dials usually rotate in opposite directions;
+report each dial's direction as "cw" (clockwise) or "ccw" (counter-clockwise).
+Do not round. Do not combine the dials into a number yourself.
+
+### 2. date (Measurement Date):
+
+Find the date and time imprinted at the top of the image.
+Format this value as an RFC3339 string.
+The time provided is local time for UTC+9. You MUST include this offset in the final string.
+
+- Example: "2025-10-28T14:30:00+09:00"
+
+### 3. issue (Image Condition):
+
+Report anything about the image that makes the counter hard to read.
+Set "kind" to one of "glare" (reflections or direct light), "blur" (out of focus or motion blur),
+"obstruction" (condensation, dirt or an object over the counter), "partial_view" (the counter is cut off by the frame)
+or "none" if the counter is clearly visible. If there are several, report the one that affects the reading most.
+Describe it briefly in "note", e.g. "sun reflection over the last two digits" (an empty string for none).
+{{- if .Serial}}
+
+### 4. serial_number (Meter Serial Number):
+
+Find the serial number printed on the meter body or its label, usually near the counter, and copy its letters and digits exactly.
+Represent every character you cannot read with certainty with a question mark (?). Use an empty string if there is no serial number in view.
+{{- end}}
+{{- if .CounterBox}}
+
+### {{if .Serial}}5{{else}}4{{end}}. counter_box (Counter Position):
+
+Report the smallest rectangle containing all digits of the counter{{if .FracDigits}}, including the decimal digits{{end}}, as fractions of the image size:
+x from 0 (left edge) to 1 (right edge) and y from 0 (top edge) to 1 (bottom edge).
+{{- end}}
+`
+
+const enDialImagePrompt = `Process the image and report every dial's pointer position and the date.
+The meter has {{.Digits}} dials; the unit is {{.Unit}}.
+{{- if .PrevRead}}
+The previous reading was {{.PrevRead}}.
+{{- end}}`
+
+const koSystemPrompt = `제공된 {{.MeterName}} 이미지를 분석하세요. 계량기 지침값과 측정 일시를 추출하여 하나의 JSON 객체로 반환해야 합니다.
+
+출력 형식: JSON 객체만 응답하세요. 설명 문장을 추가하지 마세요.
+
+{
+  "read": "string",
+  "confidences": [number],
+  "date": "string",
+  "issue": {"kind": "none" | "glare" | "blur" | "obstruction" | "partial_view", "note": "string"}{{if .Serial}},
+  "serial_number": "string"{{end}}{{if .CounterBox}},
+  "counter_box": {"x_min": number, "y_min": number, "x_max": number, "y_max": number}{{end}}
+}
+
+## JSON 필드 작성 방법
+
+### 1. read (계량기 지침값):
+
+지침값은 정수 {{.IntDigits}}자리{{if .FracDigits}}와 소수 {{.FracDigits}}자리(보통 별도의 칸 또는 빨간색 숫자){{end}}로 구성됩니다.
+{{- if .FracDigits}}
+정수부와 소수부를 . (소수점)으로 구분하여 하나의 문자열로 합치세요.
+{{- end}}
+불분명하거나 일부만 보이거나 넘어가는 중인 숫자는 해당 자리를 물음표(?)로 표시하세요.
+{{- if .MeterHint}}
+{{.MeterHint}}
+{{- end}}
+
+- 형식: "{{.Pattern}}" (반드시 이 형식을 사용하고 모든 자리를 표시하세요!)
+- 앞자리 0: 앞자리의 0을 반드시 유지하세요.
+- 불분명한 숫자는 물음표(?)로 표시합니다.
+- 확신도: "confidences"에 "read"의 각 숫자와 물음표에 대한 확신도를 왼쪽부터 순서대로 0(추측)부터 1(확실)까지의 숫자로 나열하세요.
+
+### 2. date (측정 일시):
+
+이미지 상단에 찍힌 날짜와 시간을 찾으세요. "2025년 11월 07일 05시 13분" 같은 한국어 형식일 수 있습니다.
+이 값을 RFC3339 문자열로 변환하세요.
+표시된 시간은 한국 표준시(UTC+9)입니다. 최종 문자열에 반드시 이 오프셋을 포함하세요.
+
+- 예: "2025-10-28T14:30:00+09:00"
+
+### 3. issue (이미지 상태):
+
+계량기를 읽기 어렵게 만드는 이미지의 문제를 보고하세요.
+"kind"는 "glare"(반사광 또는 직사광), "blur"(초점이 맞지 않거나 흔들림), "obstruction"(김서림, 먼지 또는 계량기를 가리는 물체),
+"partial_view"(계량기가 화면 밖으로 잘림) 중 하나이며, 계량기가 잘 보이면 "none"입니다. 여러 가지라면 읽기에 가장 영향을 준 것을 고르세요.
+"note"에 간단히 설명하세요. 예: "마지막 두 자리에 햇빛 반사" (none이면 빈 문자열).
+{{- if .Serial}}
+
+### 4. serial_number (계량기 제조번호):
+
+계량기 본체나 명판(보통 카운터 근처)에 인쇄된 제조번호를 찾아 문자와 숫자를 그대로 옮기세요.
+확실히 읽을 수 없는 글자는 모두 물음표(?)로 표시하세요. 제조번호가 보이지 않으면 빈 문자열로 두세요.
+{{- end}}
+{{- if .CounterBox}}
+
+### {{if .Serial}}5{{else}}4{{end}}. counter_box (카운터 위치):
+
+카운터의 모든 숫자{{if .FracDigits}}(소수 자리 포함){{end}}를 포함하는 가장 작은 사각형을 이미지 크기에 대한 비율로 보고하세요.
+x는 0(왼쪽 끝)부터 1(오른쪽 끝), y는 0(위쪽 끝)부터 1(아래쪽 끝)입니다.
+{{- end}}
+`
+
+const koImagePrompt = `이미지를 처리하여 지침값과 측정 일시를 추출하세요.
+계량기는 정수 {{.IntDigits}}자리, 소수 {{.FracDigits}}자리이며 단위는 {{.Unit}}입니다.
+{{- if .PrevRead}}
+이전 지침값은 {{.PrevRead}}입니다.
+{{- end}}`
+
+const koDialSystemPrompt = `제공된 {{.MeterName}} 이미지에는 시계 모양의 작은 다이얼 {{.Digits}}개가 있습니다. 각 다이얼의 바늘 위치와 측정 일시를 하나의 JSON 객체로 반환해야 합니다.
+
+출력 형식: JSON 객체만 응답하세요. 설명 문장을 추가하지 마세요.
+
+{
+  "dials": [{"value": number, "direction": "cw" | "ccw"}],
+  "date": "string",
+  "issue": {"kind": "none" | "glare" | "blur" | "obstruction" | "partial_view", "note": "string"}{{if .Serial}},
+  "serial_number": "string"{{end}}{{if .CounterBox}},
+  "counter_box": {"x_min": number, "y_min": number, "x_max": number, "y_max": number}{{end}}
+}
+
+## JSON 필드 작성 방법
+
+### 1. dials:
+
+다이얼 {{.Digits}}개를 왼쪽(가장 높은 자리)부터 오른쪽 순서로 나열하세요.
+각 다이얼의 바늘 위치를 0 이상 10 미만의 숫자로 정확히 보고하세요(예: 3과 4의 중간을 조금 지났으면 3.6).
+숫자는 해당 다이얼의 회전 방향을 따라 읽으세요. 이웃한 다이얼은 보통 서로 반대 방향으로 회전하며,
+각 다이얼의 방향을 "cw"(시계 방향) 또는 "ccw"(반시계 방향)로 표시하세요.
+반올림하지 말고, 다이얼 값을 직접 하나의 숫자로 합치지 마세요.
+
+### 2. date (측정 일시):
+
+이미지 상단에 찍힌 날짜와 시간을 찾으세요. "2025년 11월 07일 05시 13분" 같은 한국어 형식일 수 있습니다.
+이 값을 RFC3339 문자열로 변환하세요.
+표시된 시간은 한국 표준시(UTC+9)입니다. 최종 문자열에 반드시 이 오프셋을 포함하세요.
+
+- 예: "2025-10-28T14:30:00+09:00"
+
+### 3. issue (이미지 상태):
+
+계량기를 읽기 어렵게 만드는 이미지의 문제를 보고하세요.
+"kind"는 "glare"(반사광 또는 직사광), "blur"(초점이 맞지 않거나 흔들림), "obstruction"(김서림, 먼지 또는 계량기를 가리는 물체),
+"partial_view"(계량기가 화면 밖으로 잘림) 중 하나이며, 계량기가 잘 보이면 "none"입니다. 여러 가지라면 읽기에 가장 영향을 준 것을 고르세요.
+"note"에 간단히 설명하세요. 예: "마지막 두 자리에 햇빛 반사" (none이면 빈 문자열).
+{{- if .Serial}}
+
+### 4. serial_number (계량기 제조번호):
+
+계량기 본체나 명판(보통 카운터 근처)에 인쇄된 제조번호를 찾아 문자와 숫자를 그대로 옮기세요.
+확실히 읽을 수 없는 글자는 모두 물음표(?)로 표시하세요. 제조번호가 보이지 않으면 빈 문자열로 두세요.
+{{- end}}
+{{- if .CounterBox}}
+
+### {{if .Serial}}5{{else}}4{{end}}. counter_box (카운터 위치):
+
+카운터의 모든 숫자{{if .FracDigits}}(소수 자리 포함){{end}}를 포함하는 가장 작은 사각형을 이미지 크기에 대한 비율로 보고하세요.
+x는 0(왼쪽 끝)부터 1(오른쪽 끝), y는 0(위쪽 끝)부터 1(아래쪽 끝)입니다.
+{{- end}}
+`
+
+const koDialImagePrompt = `이미지를 처리하여 모든 다이얼의 바늘 위치와 측정 일시를 보고하세요.
+계량기에는 다이얼이 {{.Digits}}개 있으며 단위는 {{.Unit}}입니다.
+{{- if .PrevRead}}
+이전 지침값은 {{.PrevRead}}입니다.
+{{- end}}`
+
+const koDisambiguatePromptFmt = `값 "%s"는 이미지에서 아날로그 계량기를 읽은 결과입니다.
+불확실한 숫자는 "?" 문자로 표시되어 있습니다.
+
+이전에 기록된 계량기 값 "%s"를 참고하여(비어 있지 않은 경우에만)
+"?" 문자를 가장 가능성 높은 숫자로 추정하여 바꾸세요.
+
+지시사항:
+- 입력 값과 정확히 같은 길이의 문자열을 반환하세요.
+- 설명 없이 추정한 값만 출력하세요.
+`
+
+const koSingleShotPromptFmt = `불분명하거나 넘어가는 중인 숫자도 "?"로 표시하지 말고, 이전 지침값 "%s"(비어 있지 않은 경우에만)를 참고하여
+가장 가능성 높은 숫자를 고르세요. 지침값은 줄어들지 않습니다.
+이렇게 추정한 모든 숫자의 위치(0부터 시작하는 문자 위치)를 "ambiguous_positions"에 나열하세요 (없으면 빈 배열).
+그래도 판단할 수 없는 숫자만 "?"로 표시하세요.`
+
+const koVerifyPromptFmt = `이미지를 왼쪽부터 한 자리씩 다시 살펴보세요. 읽은 값 "%s"가 맞습니까?
+수정한 값을, 맞다면 같은 값을 이전과 같은 JSON 객체로 답하세요.
+그래도 읽을 수 없는 숫자만 "?"로 표시하세요.`
+
+const koFlowPromptFmt = `두 이미지는 같은 %s를 몇 초 간격으로 찍은 것이며, 첫 번째 이미지가 먼저 찍은 것입니다.
+작은 유량 표시기를 찾으세요: 카운터 숫자가 멈춰 있어도 무언가 흐르면 도는 작은 바퀴, 별 또는 원판입니다.
+두 이미지에서 그 위치를 비교하고 다음 JSON 객체로만 답하세요:
+
+{"flow_detected": boolean, "rotation": number, "note": "string"}
+
+- flow_detected: 두 이미지 사이에 표시기가 움직였으면 true, 같은 위치이면 false.
+- rotation: 표시기가 돈 정도를 한 바퀴에 대한 비율(0부터 1까지)로 추정한 값 (움직이지 않았으면 0).
+- note: 짧은 설명, 예: "표시기가 약 4분의 1 바퀴 돎", 또는 판단할 수 없는 이유, 예: "표시기가 보이지 않음".
+두 이미지 모두에서 표시기를 찾을 수 없으면 false와 0으로 답하고 note에 그렇게 적으세요.`
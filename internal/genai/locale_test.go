@@ -0,0 +1,232 @@
+package genai
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalePromptSetsRender(t *testing.T) {
+	t.Parallel()
+
+	for _, locale := range Locales() {
+		t.Run(locale, func(t *testing.T) {
+			t.Parallel()
+			o := NewOptions(WithLocale(locale))
+			p, err := NewPrompts(o, "", "")
+			if err != nil {
+				t.Fatalf("NewPrompts: %v", err)
+			}
+			if !strings.Contains(p.SystemText, "NNNNN.NNN") {
+				t.Fatalf("system prompt does not contain the reading pattern:\n%s", p.SystemText)
+			}
+			img, err := p.ImageTmpl.Render(NewPromptData(o.Meter, "01234.567"))
+			if err != nil {
+				t.Fatalf("Render image prompt: %v", err)
+			}
+			if !strings.Contains(img, "01234.567") {
+				t.Fatalf("image prompt does not mention the previous reading:\n%s", img)
+			}
+			if d := p.DisambiguatePrompt("0123?.567", "01234.567"); strings.Contains(d, "%!") {
+				t.Fatalf("disambiguation prompt: bad format verbs:\n%s", d)
+			}
+
+			dials, err := NewPrompts(NewOptions(WithLocale(locale), WithMeter(Meter{Type: MeterDials, IntDigits: 4})), "", "")
+			if err != nil {
+				t.Fatalf("NewPrompts(dials): %v", err)
+			}
+			if !strings.Contains(dials.SystemText, "4") || !strings.Contains(dials.SystemText, `"dials"`) {
+				t.Fatalf("dials system prompt:\n%s", dials.SystemText)
+			}
+		})
+	}
+}
+
+func TestNewPromptsOverride(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPrompts(NewOptions(WithLocale("ko")), "custom system", "custom {{.Unit}}")
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	if p.SystemText != "custom system" {
+		t.Fatalf("SystemText = %q", p.SystemText)
+	}
+	img, _ := p.ImageTmpl.Render(NewPromptData(DefaultMeter, ""))
+	if img != "custom m³" {
+		t.Fatalf("image prompt = %q", img)
+	}
+	if !strings.Contains(p.Disambiguate, "계량기") {
+		t.Fatal("disambiguation prompt should keep the locale default")
+	}
+
+	if _, err := NewPrompts(NewOptions(WithLocale("xx")), "", ""); err == nil {
+		t.Fatal("expected error for unsupported locale")
+	}
+}
+
+func TestPromptSetParseDate(t *testing.T) {
+	t.Parallel()
+
+	kst := time.FixedZone("KST", 9*60*60)
+	want := time.Date(2025, 11, 7, 5, 13, 0, 0, kst)
+
+	tests := []struct {
+		locale string
+		in     string
+	}{
+		{locale: "en", in: "2025-11-07T05:13:00+09:00"},
+		{locale: "en", in: "2025-11-07 05:13"},
+		{locale: "ko", in: "2025년 11월 07일 05시 13분"},
+		{locale: "ko", in: "2025년 11월 7일 5시 13분"},
+		{locale: "ko", in: "2025.11.07 05:13"},
+		{locale: "ko", in: "２０２５년 １１월 ０７일 ０５시 １３분"},
+		{locale: "ko", in: "2025.11.07 (금) 05:13"},
+		{locale: "ko", in: "2025.11.07（금요일）05:13"},
+		{locale: "ko", in: "2025. 11. 7. 오전 5:13"},
+		{locale: "ko", in: "2025년 11월 7일 05:13"},
+		// The weekday is wrong (7 Nov 2025 is a Friday): trust the numbers.
+		{locale: "ko", in: "2025.11.07 (월) 05:13"},
+		{locale: "en", in: "2025-11-07 (Mon) 05:13"},
+	}
+	for _, tt := range tests {
+		ps, err := LocalePromptSet(tt.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ps.ParseDate(tt.in, kst)
+		if err != nil {
+			t.Fatalf("ParseDate(%q): %v", tt.in, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("ParseDate(%q) = %v, want %v", tt.in, got, want)
+		}
+	}
+
+	en, _ := LocalePromptSet("en")
+	if _, err := en.ParseDate("2025년 11월 07일 05시 13분", kst); err == nil {
+		t.Fatal("en locale should not parse Korean layouts")
+	}
+}
+
+func TestSetDateParsed(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPrompts(NewOptions(WithLocale("ko")), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kst := time.FixedZone("KST", 9*60*60)
+	utc := time.UTC
+
+	out := &GasMeterReadResult{Date: "2025년 11월 07일 05시 13분"}
+	p.SetDateParsed(out, kst)
+	if want := time.Date(2025, 11, 7, 5, 13, 0, 0, kst); !out.DateParsed.Equal(want) || out.DateParsed.Location() != kst {
+		t.Fatalf("DateParsed = %v, want %v", out.DateParsed, want)
+	}
+
+	// Dates with an offset are converted to the configured zone.
+	out = &GasMeterReadResult{Date: "2025-11-07T05:13:00+09:00"}
+	p.SetDateParsed(out, utc)
+	if want := time.Date(2025, 11, 6, 20, 13, 0, 0, utc); out.DateParsed != want {
+		t.Fatalf("DateParsed = %v, want %v", out.DateParsed, want)
+	}
+
+	out = &GasMeterReadResult{Date: "sometime"}
+	p.SetDateParsed(out, kst)
+	if !out.DateParsed.IsZero() {
+		t.Fatalf("DateParsed = %v for an unrecognized date, want zero", out.DateParsed)
+	}
+}
+
+func FuzzParseDate(f *testing.F) {
+	// Seeds include dates models have returned instead of RFC3339.
+	for _, s := range []string{
+		"2025-11-07T05:13:00+09:00", "2025-11-07T05:13:00Z", "2025-11-07 05:13",
+		"2025년 11월 07일 05시 13분", "2025.11.07 05:13", "Fri Nov  7 05:23:24 2025",
+		"2025-11-07 (Fri) 05:23:24", "２０２５.１１.０７ （금） ０５:１３", "2025. 11. 7. 오후 5:13",
+		"", "0000-00-00T00:00:00+09:00", "9999-12-31T23:59:59+14:00",
+	} {
+		f.Add("ko", s)
+		f.Add("en", s)
+	}
+	kst := time.FixedZone("KST", 9*60*60)
+	f.Fuzz(func(t *testing.T, locale, s string) {
+		ps, err := LocalePromptSet(locale)
+		if err != nil {
+			return
+		}
+		got, err := ps.ParseDate(s, kst)
+		if err != nil {
+			return
+		}
+		if got.IsZero() {
+			t.Fatalf("ParseDate(%q) returned the zero time without error", s)
+		}
+		if y := got.Year(); y < 0 || y > 9999 {
+			return // RFC3339 cannot represent it
+		}
+		back, err := ps.ParseDate(got.Format(time.RFC3339Nano), kst)
+		if err != nil || !back.Equal(got) {
+			t.Fatalf("ParseDate(%q) = %v, round trip gives %v, %v", s, got, back, err)
+		}
+	})
+}
+
+func TestPromptsHash(t *testing.T) {
+	t.Parallel()
+
+	a, _ := NewPrompts(NewOptions(), "", "")
+	b, _ := NewPrompts(NewOptions(), "", "")
+	c, _ := NewPrompts(NewOptions(), "", "other {{.Unit}}")
+	if len(a.Hash) != 12 {
+		t.Fatalf("Hash = %q, want 12 hex chars", a.Hash)
+	}
+	if a.Hash != b.Hash {
+		t.Fatal("same prompts hash differently")
+	}
+	if a.Hash == c.Hash {
+		t.Fatal("different prompts hash the same")
+	}
+}
+
+func TestUtilityPrompts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		utility, locale     string
+		name, hint, pattern string
+		unit, deviceClass   string
+	}{
+		{UtilityGas, "en", "gas meter", "", "NNNNN.NNN", "m³", "gas"},
+		{UtilityWater, "en", "water meter", "red pointer dials", "NNNNN.NNN", "m³", "water"},
+		{UtilityElectricity, "en", "electricity meter", "rotating disc", "NNNNNN.N", "kWh", "energy"},
+		{UtilityElectricity, "ko", "전력량계", "회전하는 원판", "NNNNNN.N", "kWh", "energy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.utility+"/"+tt.locale, func(t *testing.T) {
+			t.Parallel()
+			o := NewOptions(WithLocale(tt.locale), WithMeter(Meter{Utility: tt.utility}))
+			p, err := NewPrompts(o, "", "")
+			if err != nil {
+				t.Fatalf("NewPrompts: %v", err)
+			}
+			for _, want := range []string{tt.name, tt.hint, tt.pattern} {
+				if !strings.Contains(p.SystemText, want) {
+					t.Fatalf("system prompt does not contain %q:\n%s", want, p.SystemText)
+				}
+			}
+			img, err := p.ImageTmpl.Render(p.Data(o.Meter, ""))
+			if err != nil || !strings.Contains(img, tt.unit) {
+				t.Fatalf("image prompt = %q, %v; want unit %s", img, err, tt.unit)
+			}
+			if got := o.Meter.DeviceClass(); got != tt.deviceClass {
+				t.Fatalf("DeviceClass() = %q, want %q", got, tt.deviceClass)
+			}
+		})
+	}
+
+	if _, err := NewPrompts(NewOptions(WithMeter(Meter{Utility: "steam"})), "", ""); err == nil {
+		t.Fatal("NewPrompts accepted an unknown utility")
+	}
+}
@@ -0,0 +1,80 @@
+package genai
+
+import (
+	"context"
+	"io"
+)
+
+// Middleware wraps a [VisionClient] with cross-cutting behaviour. Code that
+// reads meters depends on VisionClient only, so backends, fakes and wrapped
+// clients are interchangeable.
+type Middleware func(VisionClient) VisionClient
+
+// Chain wraps c with mws, the first being the outermost: Chain(c, a, b)
+// calls a, then b, then c.
+//
+// Order matters. Anything that should still answer while the backend is
+// down, such as a cache, goes outside the breaker, or it is cut off with the
+// backend. The breaker goes outside a rate limit, so that an open breaker
+// fails fast instead of waiting for a slot, and outside fault injection,
+// which stands in for the backend. Retries go inside a budget guard, so that
+// every attempt is charged. Ensemble reads stay inside the client, where the
+// models share one upload of the image.
+func Chain(c VisionClient, mws ...Middleware) VisionClient {
+	for i := len(mws) - 1; i >= 0; i-- {
+		c = mws[i](c)
+	}
+	return c
+}
+
+// Around returns a Middleware that runs both read methods through fn, which
+// must call next to read. Close is passed through.
+func Around(fn func(ctx context.Context, next func(context.Context) (*GasMeterReadResult, error)) (*GasMeterReadResult, error)) Middleware {
+	return func(c VisionClient) VisionClient {
+		return &around{next: c, fn: fn}
+	}
+}
+
+type around struct {
+	next VisionClient
+	fn   func(ctx context.Context, next func(context.Context) (*GasMeterReadResult, error)) (*GasMeterReadResult, error)
+}
+
+func (a *around) ReadGasGaugePic(ctx context.Context, r io.Reader) (*GasMeterReadResult, error) {
+	return a.fn(ctx, func(ctx context.Context) (*GasMeterReadResult, error) {
+		return a.next.ReadGasGaugePic(ctx, r)
+	})
+}
+
+func (a *around) ReadGasGaugePicFromURL(ctx context.Context, u string) (*GasMeterReadResult, error) {
+	return a.fn(ctx, func(ctx context.Context) (*GasMeterReadResult, error) {
+		return a.next.ReadGasGaugePicFromURL(ctx, u)
+	})
+}
+
+func (a *around) Close() error { return a.next.Close() }
+
+// BreakerMiddleware checks b before every reading and reports its outcome;
+// while b is open readings fail with [ErrCircuitOpen] without reaching the
+// client. A nil b lets everything through.
+func BreakerMiddleware(b *Breaker) Middleware {
+	return Around(func(ctx context.Context, next func(context.Context) (*GasMeterReadResult, error)) (out *GasMeterReadResult, err error) {
+		if err := b.Allow(); err != nil {
+			return nil, err
+		}
+		defer func() { b.Done(err) }()
+		return next(ctx)
+	})
+}
+
+// RateLimitMiddleware spaces whole readings with l. Unlike [WithRateLimiter],
+// which spaces every API call of a reading, it counts a reading with a
+// disambiguation call once.
+func RateLimitMiddleware(l *Limiter) Middleware {
+	return Around(func(ctx context.Context, next func(context.Context) (*GasMeterReadResult, error)) (*GasMeterReadResult, error) {
+		if err := l.Wait(ctx); err != nil {
+			return nil, err
+		}
+		return next(ctx)
+	})
+}
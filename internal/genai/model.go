@@ -0,0 +1,21 @@
+package genai
+
+import "context"
+
+type modelKey struct{}
+
+// ContextWithModel returns ctx making the readings of a client under it use
+// model instead of the client's, e.g. a stronger model for the reading that
+// feeds billing. Retries fall back to [WithRetries]' models as usual; the
+// models of [WithEnsemble] and the disambiguation call are not changed.
+func ContextWithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFromContext returns the model set by [ContextWithModel], or def.
+func ModelFromContext(ctx context.Context, def string) string {
+	if m, _ := ctx.Value(modelKey{}).(string); m != "" {
+		return m
+	}
+	return def
+}
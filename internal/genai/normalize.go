@@ -0,0 +1,155 @@
+package genai
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Normalization is how a meter's readings are cleaned up before they are
+// checked, for models that copy what is printed around the counter, e.g.
+// "01234,567 m³".
+type Normalization struct {
+	// Units are suffixes stripped from readings, such as "m3" printed in
+	// the frame of a water meter; [Meter.Unit] always is.
+	Units []string
+	// DecimalSeparator is converted to "." (default ".": none is).
+	DecimalSeparator string
+	// InsertDot inserts the decimal point the model left out, as for
+	// decimal digits as large as the others, into readings with as many
+	// digits as the meter; see [NormalizeResult].
+	InsertDot bool
+}
+
+// ErrFormatMismatch is returned for a reading without a decimal point that
+// [Normalization.InsertDot] cannot fix, as it has another number of digits
+// than the meter.
+var ErrFormatMismatch = errors.New("reading does not match the meter format")
+
+// WarningDotInserted names the warning of a reading whose decimal point was
+// inserted by [NormalizeResult].
+const WarningDotInserted = "dot_inserted"
+
+// Validate checks that the decimal separator is a single character that
+// cannot be part of a reading otherwise.
+func (n Normalization) Validate() error {
+	if sep := n.DecimalSeparator; sep != "" && (utf8.RuneCountInString(sep) != 1 || strings.ContainsAny(sep, "0123456789?")) {
+		return fmt.Errorf("decimal separator %q is not a single character other than a digit or \"?\"", sep)
+	}
+	return nil
+}
+
+// readChars are the characters a normalized reading may contain: digits, the
+// decimal point and "?" for the ambiguous digits that are guessed later.
+const readChars = "0123456789.?"
+
+func isReadChar(r rune) bool { return strings.ContainsRune(readChars, r) }
+
+// NormalizeRead cleans up the reading s as set by m.Normalize: it drops
+// whitespace and a trailing unit, then converts the decimal separator.
+// Leading zeros are kept.
+//
+// A reading wrapped in prose, as "approximately 02924.457" or "약 02924.457",
+// is extracted from it: of the runs of digits, "." and "?" left, the one
+// that fits m's [Meter.Pattern], or else the only one there is. Several
+// candidates are an error rather than a guess, and so are look-alike digits
+// such as full-width ones, as by [ParseRead].
+func NormalizeRead(m Meter, s string) (string, error) {
+	raw := s
+	if strings.IndexFunc(s, func(r rune) bool { return unicode.IsDigit(r) && (r < '0' || r > '9') }) >= 0 {
+		return "", fmt.Errorf("reading %q has digits other than ASCII ones", Truncate(raw, 40))
+	}
+	units := append([]string{m.Unit}, m.Normalize.Units...)
+	// "m³" before "m", should both be listed.
+	slices.SortFunc(units, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	if sep := m.Normalize.DecimalSeparator; sep != "" && sep != "." {
+		s = strings.ReplaceAll(s, sep, ".")
+	}
+	n := strings.Join(strings.FieldsFunc(s, unicode.IsSpace), "")
+	for _, u := range units {
+		if u = strings.Join(strings.Fields(u), ""); u != "" && strings.HasSuffix(n, u) {
+			n = strings.TrimSuffix(n, u)
+			break
+		}
+	}
+	if strings.IndexFunc(n, func(r rune) bool { return !isReadChar(r) }) < 0 {
+		return n, nil
+	}
+	// Units may contain digits, as "m3": they are no candidates.
+	for _, u := range units {
+		if u != "" {
+			s = strings.ReplaceAll(s, u, " ")
+		}
+	}
+	var fits, others []string
+	for _, run := range strings.FieldsFunc(s, func(r rune) bool { return !isReadChar(r) }) {
+		// A sentence may end, or ask a question, right after the number.
+		run = strings.Trim(run, ".")
+		if !strings.ContainsAny(run, "0123456789") {
+			continue
+		}
+		switch q := strings.TrimRight(run, "?."); {
+		case fitsPattern(m.Pattern(), run, true):
+			fits = appendNew(fits, run)
+		case fitsPattern(m.Pattern(), q, true):
+			fits = appendNew(fits, q)
+		default:
+			others = appendNew(others, run)
+		}
+	}
+	switch {
+	case len(fits) == 1:
+		return fits[0], nil
+	case len(fits) > 1:
+		return "", fmt.Errorf("reading %q has several candidates: %s", Truncate(raw, 60), strings.Join(fits, ", "))
+	case len(others) == 1:
+		return others[0], nil
+	case len(others) > 1:
+		return "", fmt.Errorf("reading %q has several candidates: %s", Truncate(raw, 60), strings.Join(others, ", "))
+	}
+	return "", fmt.Errorf("reading %q has characters other than digits and the decimal point", Truncate(raw, 40))
+}
+
+// appendNew appends s to list unless it is in it already.
+func appendNew(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// NormalizeResult normalizes out.Read with [NormalizeRead], keeping the
+// model's string in out.RawRead if it changed. The readings of dials meters
+// are assembled from the dials and left alone.
+//
+// With m.Normalize.InsertDot, a reading without a decimal point gets one
+// FracDigits from the end if it has the meter's number of digits, and a
+// [WarningDotInserted] warning; with another number it is rejected with
+// [ErrFormatMismatch] rather than guessed at.
+func NormalizeResult(m Meter, out *GasMeterReadResult) error {
+	if m.Type == MeterDials {
+		return nil
+	}
+	n, err := NormalizeRead(m, out.Read)
+	if err != nil {
+		return err
+	}
+	if m.Normalize.InsertDot && !strings.Contains(n, ".") {
+		// Normalized readings are ASCII: bytes are digits.
+		if len(n) != m.IntDigits+m.FracDigits {
+			return fmt.Errorf("%w: %q has %d digits and no decimal point, want %s", ErrFormatMismatch, Truncate(n, 40), len(n), m.Pattern())
+		}
+		if m.FracDigits > 0 {
+			n = n[:m.IntDigits] + "." + n[m.IntDigits:]
+			out.Warnings = append(out.Warnings, fmt.Sprintf("%s: no decimal point in %q, read as %s", WarningDotInserted, Truncate(out.Read, 40), n))
+		}
+	}
+	if n != out.Read {
+		out.RawRead, out.Read = out.Read, n
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package genai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRead(t *testing.T) {
+	t.Parallel()
+
+	water := DefaultMeter
+	water.Normalize = Normalization{Units: []string{"m3", "m"}, DecimalSeparator: ","}
+	tests := []struct {
+		m       Meter
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{DefaultMeter, "02924.457", "02924.457", false},
+		{DefaultMeter, " 02924.457 m³\n", "02924.457", false},
+		{DefaultMeter, "02 924.457", "02924.457", false},
+		{DefaultMeter, "0292?.457", "0292?.457", false},
+		{DefaultMeter, "02924,457", "", true}, // "," is not the separator
+		{DefaultMeter, "02924.457 m3", "02924.457", false},
+		{DefaultMeter, "０2924.457", "", true},
+		{DefaultMeter, "02924.45７", "", true},
+		{water, "01234,567 m3", "01234.567", false},
+		{water, "01234,567m³", "01234.567", false},
+		{water, "01234,567 m", "01234.567", false},
+		{water, "00012.000", "00012.000", false},
+		{water, "01234,567 l", "01234.567", false},
+		{water, "01234,567 m3.", "01234.567", false},
+		// Prose around the number.
+		{DefaultMeter, "approximately 02924.457", "02924.457", false},
+		{DefaultMeter, "약 02924.457", "02924.457", false},
+		{DefaultMeter, "약 02924.457 입니다.", "02924.457", false},
+		{DefaultMeter, "The reading is 0292?.457.", "0292?.457", false},
+		{DefaultMeter, "Is it 02924.457?", "02924.457", false},
+		{DefaultMeter, "reading #1: 02924.457", "02924.457", false},
+		{DefaultMeter, "about 2924.457", "2924.457", false}, // the only candidate, for the format validator to reject
+		{DefaultMeter, "unreadable", "", true},
+		// More than one candidate is not guessed from.
+		{DefaultMeter, "02924.457 or 02924.458", "", true},
+		{DefaultMeter, "previous 02923.999, now 02924.457", "", true},
+		{DefaultMeter, "about 2924.457 or 2924.458", "", true},
+		{DefaultMeter, "02924.457 (02924.457)", "02924.457", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeRead(tt.m, tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Fatalf("NormalizeRead(%+v, %q) = %q, %v; want %q, error %v", tt.m.Normalize, tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	r := &GasMeterReadResult{Read: "02924.457"}
+	if err := NormalizeResult(DefaultMeter, r); err != nil || r.RawRead != "" {
+		t.Fatalf("NormalizeResult of a clean reading: RawRead = %q, %v", r.RawRead, err)
+	}
+	r = &GasMeterReadResult{Read: "약 02924.457 m³"}
+	if err := NormalizeResult(DefaultMeter, r); err != nil || r.Read != "02924.457" || r.RawRead != "약 02924.457 m³" {
+		t.Fatalf("NormalizeResult of a wrapped reading: Read = %q, RawRead = %q, %v", r.Read, r.RawRead, err)
+	}
+}
+
+func TestNormalizeResultInsertDot(t *testing.T) {
+	t.Parallel()
+
+	m := DefaultMeter
+	m.Normalize.InsertDot = true
+	whole := Meter{Utility: UtilityWater, IntDigits: 6, Unit: "m³", Normalize: Normalization{InsertDot: true}}
+	tests := []struct {
+		name     string
+		m        Meter
+		in, want string
+		warn     bool
+		err      error
+	}{
+		{"dot inserted", m, "02924457", "02924.457", true, nil},
+		{"uncertain digit", m, "0292?457", "0292?.457", true, nil},
+		{"dot given", m, "02924.457", "02924.457", false, nil},
+		{"too few digits", m, "2924457", "", false, ErrFormatMismatch},
+		{"too many digits", m, "029244570", "", false, ErrFormatMismatch},
+		{"off", DefaultMeter, "02924457", "02924457", false, nil},
+		{"no decimals", whole, "012345", "012345", false, nil},
+		{"no decimals, wrong count", whole, "0123456", "", false, ErrFormatMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &GasMeterReadResult{Read: tt.in}
+			err := NormalizeResult(tt.m, r)
+			if !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Fatalf("NormalizeResult(%q) = %v, want %v", tt.in, err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			warned := len(r.Warnings) == 1 && strings.HasPrefix(r.Warnings[0], WarningDotInserted+": ")
+			if r.Read != tt.want || warned != tt.warn || !tt.warn && len(r.Warnings) > 0 {
+				t.Fatalf("NormalizeResult(%q): Read = %q, warnings %q; want %q, warned %t", tt.in, r.Read, r.Warnings, tt.want, tt.warn)
+			}
+			if tt.warn && r.RawRead != tt.in {
+				t.Fatalf("RawRead = %q, want the model's %q", r.RawRead, tt.in)
+			}
+			// The inserted point makes it a reading the format check accepts.
+			if tt.warn && tt.m.CheckRead(r.Read) != nil {
+				t.Fatalf("CheckRead(%q): %v", r.Read, tt.m.CheckRead(r.Read))
+			}
+		})
+	}
+}
+
+func FuzzNormalizeRead(f *testing.F) {
+	// Seeds include answers models have actually produced.
+	for _, s := range []string{
+		"02924.457", "approximately 02924.457", "약 1234.56", "02924.457 m³", "0292?.457.",
+		"02924.457 or 02924.458", "０2924.457", "m3 3", "?", ".", "", "\xff02924.457",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := NormalizeRead(DefaultMeter, s)
+		if err != nil {
+			return
+		}
+		if strings.IndexFunc(n, func(r rune) bool { return !isReadChar(r) }) >= 0 {
+			t.Fatalf("NormalizeRead(%q) = %q, has other characters", s, n)
+		}
+		if again, err := NormalizeRead(DefaultMeter, n); err != nil || again != n {
+			t.Fatalf("NormalizeRead(%q) = %q, normalizes again to %q, %v", s, n, again, err)
+		}
+		if fitsPattern(DefaultMeter.Pattern(), s, true) && n != s {
+			t.Fatalf("NormalizeRead(%q) = %q, changed a reading of the meter", s, n)
+		}
+	})
+}
+
+func TestNormalizationValidate(t *testing.T) {
+	t.Parallel()
+
+	for sep, ok := range map[string]bool{"": true, ".": true, ",": true, "·": true, ", ": false, "7": false, "?": false} {
+		if err := (Normalization{DecimalSeparator: sep}).Validate(); (err == nil) != ok {
+			t.Fatalf("Validate(%q) = %v", sep, err)
+		}
+	}
+}
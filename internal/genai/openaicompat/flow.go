@@ -0,0 +1,56 @@
+package openaicompat
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// DetectFlow implements [genai.FlowDetector]. Both images are sent inline
+// in one request.
+func (c *Client) DetectFlow(ctx context.Context, img1, img2 io.Reader) (out *genai.FlowResult, err error) {
+	s := c.cfg.Load()
+	o := &s.Options
+	ctx, span := o.StartSpan(ctx, genai.SpanFlow, genai.AttrMeterID.String(o.Meter.ID))
+	defer func() { genai.EndSpan(span, err) }()
+
+	prompt := s.Prompts.FlowPrompt(o.Meter)
+	parts := []contentPart{{Type: "text", Text: prompt}}
+	var jpgs [][]byte
+	for _, img := range []io.Reader{img1, img2} {
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(genai.LimitImage(img, o.MaxImageSize)); err != nil {
+			return nil, fmt.Errorf("read image: %w", err)
+		}
+		if buf.Len() == 0 {
+			return nil, fmt.Errorf("empty image")
+		}
+		jpgs = append(jpgs, buf.Bytes())
+		parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURLPart{jpeg: buf.Bytes()}})
+	}
+	var format *responseFormat
+	if o.ResponseSchema {
+		format = &responseFormat{Type: "json_schema", JSONSchema: &jsonSchema{Name: "flow_indicator", Schema: genai.FlowResultJSONSchema, Strict: true}}
+	}
+	model := genai.ModelFromContext(ctx, s.Model)
+	content, _, err := c.chatCompletion(ctx, s, completionCall{
+		kind:        genai.CallFlow,
+		model:       model,
+		messages:    []chatMessage{{Role: "user", Content: parts}},
+		temperature: 0.1,
+		format:      format,
+		prompt:      prompt,
+		image:       jpgs[0], // the audit log records the first image
+	})
+	if err == nil {
+		out, err = genai.ParseFlowResult(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("detect flow: %w", err)
+	}
+	out.Model, out.At = model, o.Clock.Now()
+	return out, nil
+}
@@ -4,13 +4,18 @@ package openaicompat
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/suapapa/mqvision/internal/genai"
@@ -18,30 +23,70 @@ import (
 
 // Client calls an OpenAI-compatible HTTP API for vision + structured JSON extraction.
 type Client struct {
-	httpClient   *http.Client
-	baseURL      string
-	apiKey       string
-	model        string
-	systemPrompt string
-	promptForImg string
-	lastRead     string
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	// cfg is the current configuration; every call uses the one it
+	// started with, see [genai.Snapshot].
+	cfg atomic.Pointer[genai.Snapshot]
+	// refs are the previous readings, which a correction may replace while
+	// reading.
+	refs *genai.References
+
+	stats genai.Counters
 }
 
 // NewClient constructs a Client. baseURL should be the API root (e.g. https://host/v1) without a trailing slash.
+// systemPrompt and promptForImg are text/templates rendered with [genai.PromptData];
+// empty values use the built-in prompts of the configured locale.
 func NewClient(
 	baseURL, apiKey, model, systemPrompt, promptForImg string,
-) *Client {
+	opts ...genai.Option,
+) (*Client, error) {
+	s, err := genai.NewSnapshot(model, systemPrompt, promptForImg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := genai.ResolveSeed(context.Background(), s.Options)
+	if err != nil {
+		return nil, err
+	}
+	hc := s.Options.HTTPClient
+	if hc == nil {
+		hc = &http.Client{
+			Timeout:   120 * time.Second,
+			Transport: s.Options.Transport,
+		}
+	}
 	b := strings.TrimRight(strings.TrimSpace(baseURL), "/")
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
-		baseURL:      b,
-		apiKey:       apiKey,
-		model:        model,
-		systemPrompt: systemPrompt,
-		promptForImg: promptForImg,
+	c := &Client{
+		httpClient: hc,
+		baseURL:    b,
+		apiKey:     apiKey,
+		refs:       genai.NewReferences(seed),
+	}
+	c.cfg.Store(s)
+	return c, nil
+}
+
+// Reconfigure implements [genai.Reconfigurer].
+func (c *Client) Reconfigure(model, systemPrompt, promptForImg string, opts ...genai.Option) error {
+	s, err := genai.NewSnapshot(model, systemPrompt, promptForImg, opts...)
+	if err != nil {
+		return err
 	}
+	c.cfg.Store(s)
+	return nil
+}
+
+// Snapshot implements [genai.Reconfigurer].
+func (c *Client) Snapshot() *genai.Snapshot {
+	return c.cfg.Load()
+}
+
+// Close implements [genai.VisionClient]. Example images are sent inline, so there is nothing to release.
+func (c *Client) Close() error {
+	return nil
 }
 
 // ReadGasGaugePicFromURL runs the same analysis as ReadGasGaugePic using a public image URL.
@@ -54,57 +99,241 @@ func (c *Client) ReadGasGaugePicFromURL(
 	if u == "" {
 		return nil, fmt.Errorf("empty image URL")
 	}
-	return c.readGasGaugeFromVisionURL(ctx, u)
+	return c.readGasGaugeFromVisionURL(ctx, c.cfg.Load(), &imageURLPart{URL: u}, nil)
 }
 
+// imageBufs holds the buffers images are read into, so concurrent and
+// successive readings reuse memory instead of growing a new buffer each time.
+var imageBufs = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
 // ReadGasGaugePic implements [genai.VisionClient].
 func (c *Client) ReadGasGaugePic(
 	ctx context.Context,
 	jpgReader io.Reader,
 ) (*genai.GasMeterReadResult, error) {
-	jpgBytes, err := io.ReadAll(jpgReader)
-	if err != nil {
+	s := c.cfg.Load()
+	buf := imageBufs.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		imageBufs.Put(buf)
+	}()
+	if _, err := buf.ReadFrom(genai.LimitImage(jpgReader, s.Options.MaxImageSize)); err != nil {
 		return nil, fmt.Errorf("read image: %w", err)
 	}
-	if len(jpgBytes) == 0 {
+	if buf.Len() == 0 {
 		return nil, fmt.Errorf("empty image")
 	}
-	dataURL := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(jpgBytes)
-	return c.readGasGaugeFromVisionURL(ctx, dataURL)
+	return c.readGasGaugeFromVisionURL(ctx, s, &imageURLPart{jpeg: buf.Bytes()}, buf.Bytes())
 }
 
-// readGasGaugeFromVisionURL sends imageURL as an OpenAI-style image_url (data URI or https URL).
-func (c *Client) readGasGaugeFromVisionURL(ctx context.Context, imageURL string) (*genai.GasMeterReadResult, error) {
-	start := time.Now()
+// readGasGaugeFromVisionURL sends image as an OpenAI-style image_url (inline image or https URL)
+// with the configuration s. jpg is the inline image, used only for the audit log; nil for https URLs.
+func (c *Client) readGasGaugeFromVisionURL(ctx context.Context, s *genai.Snapshot, image *imageURLPart, jpg []byte) (out *genai.GasMeterReadResult, err error) {
+	defer func() { c.stats.CountRead(out, err) }()
+	o := &s.Options
+	start := o.Clock.Now()
+	ctx, span := o.StartSpan(ctx, genai.SpanRead, genai.AttrMeterID.String(o.Meter.ID), genai.AttrImageSize.Int(len(jpg)))
+	defer func() { genai.EndSpan(span, err) }()
+	ctx, timer, cancel := o.StartTimer(ctx)
+	defer cancel()
+	ctx, usage := genai.WithUsage(ctx)
 
-	content, err := c.chatCompletion(ctx, []chatMessage{
-		{Role: "system", Content: c.systemPrompt},
-		{Role: "user", Content: []contentPart{
-			{Type: "text", Text: c.promptForImg},
-			{Type: "image_url", ImageURL: &imageURLPart{URL: imageURL}},
-		}},
-	}, 0.1)
+	ref := c.reference(s)
+	prompt, err := s.Prompts.ImageTmpl.Render(s.Prompts.Data(o.Meter, ref.Read))
 	if err != nil {
 		return nil, err
 	}
+	if o.SingleShotMode() {
+		prompt = s.Prompts.SingleShotPrompt(prompt, ref.Read)
+	}
+	o.Debugf("Rendered image prompt: %s", prompt)
+
+	msgs := []chatMessage{{Role: "system", Content: s.Prompts.SystemText}}
+	// Few-shot examples are inlined as data URLs on every call.
+	for _, e := range s.Examples {
+		msgs = append(msgs,
+			chatMessage{Role: "user", Content: []contentPart{
+				{Type: "image_url", ImageURL: &imageURLPart{jpeg: e.JPEG}},
+			}},
+			chatMessage{Role: "assistant", Content: e.ModelAnswer()},
+		)
+	}
+	msgs = append(msgs, chatMessage{Role: "user", Content: []contentPart{
+		{Type: "text", Text: prompt},
+		{Type: "image_url", ImageURL: image},
+	}})
+
+	var format *responseFormat
+	if o.ResponseSchema {
+		format = readingFormat(o.ResponseJSONSchema())
+	}
+	generate := func(ctx context.Context, model string, g genai.Generation) (*genai.GasMeterReadResult, error) {
+		gctx, gcancel := timer.Phase(ctx, genai.PhaseGenerate)
+		defer gcancel()
+		content, finish, err := c.chatCompletion(gctx, s, completionCall{
+			kind:        genai.CallRead,
+			model:       model,
+			messages:    msgs,
+			temperature: 0.1,
+			format:      format,
+			gen:         &g,
+			prompt:      prompt,
+			image:       jpg,
+		})
+		if err = timer.Err(gctx, genai.PhaseGenerate, err); err != nil {
+			return nil, err
+		}
+		_, span := o.StartSpan(ctx, genai.SpanValidate, genai.AttrModel.String(model))
+		out, err := c.validate(s, content, finish, ref.Read)
+		genai.EndSpan(span, err)
+		if err != nil {
+			return nil, err
+		}
+		out.Model = model
+		return out, nil
+	}
+	attempt := func(ctx context.Context, model string) (*genai.GasMeterReadResult, error) {
+		return o.GenerateWithin(model, func(g genai.Generation) (*genai.GasMeterReadResult, error) {
+			return generate(ctx, model, g)
+		})
+	}
+	readWith := func(ctx context.Context, model string) (*genai.GasMeterReadResult, error) {
+		return o.Retry(ctx, model, attempt)
+	}
 
-	out, err := parseGasMeterJSON(content)
+	var phases genai.Phases
+	readStart := o.Clock.Now()
+	if len(o.Ensemble) > 0 {
+		var agreed bool
+		out, agreed, err = genai.RunEnsemble(ctx, o.Ensemble, o.Agreement, readWith)
+		if err == nil && !agreed {
+			c.stats.CountDisagreement()
+			log.Printf("Ensemble models disagree: %+v", out.Answers)
+		}
+	} else {
+		out, err = readWith(ctx, genai.ModelFromContext(ctx, s.Model))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("parse model JSON: %w", err)
+		return nil, err
 	}
 
+	phases.Generate = genai.Since(o.Clock, readStart)
+	out.Ambiguous = len(out.AmbiguousPositions) > 0
+
+	// In single-shot mode this is the fallback for digits the model still could not decide.
 	if strings.Contains(out.Read, "?") {
 		log.Printf("Ambiguous digits found in the reading: %s", out.Read)
-		fixed, err := c.guessAmbiguousDigits(ctx, out.Read)
+		guessStart := o.Clock.Now()
+		gctx, gcancel := timer.Phase(ctx, genai.PhaseGuess)
+		defer gcancel()
+		gctx, span := o.StartSpan(gctx, genai.SpanGuess, genai.AttrRead.String(out.Read))
+		fixed, err := c.guessAmbiguousDigits(gctx, s, out.Read, ref.Read)
+		err = timer.Err(gctx, genai.PhaseGuess, err)
+		genai.EndSpan(span, err)
 		if err != nil {
 			return nil, fmt.Errorf("guess ambiguous digits: %w", err)
 		}
 		out.Read = fixed
+		out.Ambiguous = true
+		c.stats.CountGuess()
+		phases.Guess = genai.Since(o.Clock, guessStart)
 	}
 
-	out.ItTakes = time.Since(start).String()
-	out.ReadAt = time.Now()
-	c.lastRead = out.Read
+	if o.SelfVerifyIn(ctx) {
+		verifyStart := o.Clock.Now()
+		vctx, span := o.StartSpan(ctx, genai.SpanVerify, genai.AttrRead.String(out.Read))
+		verified, err := c.verify(vctx, s, msgs, out, format, jpg, ref.Read)
+		err = timer.Err(vctx, genai.PhaseVerify, err)
+		genai.EndSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("verify reading: %w", err)
+		}
+		out.SetVerified(verified)
+		if out.VerifyDisagrees() {
+			c.stats.CountVerifyDisagreement()
+			log.Printf("Reading changed on re-examination: %s, then %s", out.Read, verified)
+		}
+		phases.Verify = genai.Since(o.Clock, verifyStart)
+	}
+
+	phases.Total = genai.Since(o.Clock, start)
+	phases.TimedOut, phases.Usage = timer.TimedOut(), usage()
+	out.ItTakes = phases.Total.String()
+	out.Timing = phases.Timing(o.SingleShotMode())
+	c.stats.ObservePhases(phases)
+	o.LogSlow(out.Model, phases, int64(len(jpg)))
+	out.ReadAt = o.Clock.Now()
+	out.PromptHash = s.Prompts.Hash
+	out.ConfigHash = s.Hash
+	out.ReaderVersion = genai.Version
+	out.Utility = o.Meter.Utility
+	s.Prompts.SetDateParsed(out, o.Location)
+	out.SetProvenance(ref)
+	span.SetAttributes(genai.AttrRead.String(out.Read), genai.AttrModel.String(out.Model))
+	if !o.Stateless {
+		c.refs.Add(o.Meter.ID, out)
+	}
+	return out, nil
+}
+
+// verify asks the model of out to re-examine the image in msgs, the
+// conversation of the reading, and returns its answer.
+func (c *Client) verify(ctx context.Context, s *genai.Snapshot, msgs []chatMessage, out *genai.GasMeterReadResult, format *responseFormat, jpg []byte, prevRead string) (string, error) {
+	prompt := s.Prompts.VerifyPrompt(out.Read)
+	msgs = append(slices.Clip(msgs),
+		chatMessage{Role: "assistant", Content: genai.VerifyAnswer(out)},
+		chatMessage{Role: "user", Content: prompt},
+	)
+	content, finish, err := c.chatCompletion(ctx, s, completionCall{
+		kind:        genai.CallVerify,
+		model:       out.Model,
+		messages:    msgs,
+		temperature: 0.1,
+		format:      format,
+		prompt:      prompt,
+		image:       jpg,
+	})
+	if err != nil {
+		return "", err
+	}
+	verified, err := c.validate(s, content, finish, prevRead)
+	if err != nil {
+		return "", err
+	}
+	return genai.MergeVerified(out.Read, verified.Read), nil
+}
+
+// validate parses and checks the model's answer to a reading call against
+// the previous reading prevRead.
+func (c *Client) validate(s *genai.Snapshot, content, finish, prevRead string) (*genai.GasMeterReadResult, error) {
+	o := &s.Options
+	var out *genai.GasMeterReadResult
+	var err error
+	if content != "" {
+		out, err = genai.ParseReadResult(content)
+	}
+	if err := genai.CheckOutput(o.Meter, out, finish, err); err != nil {
+		return nil, err
+	}
+	if o.Meter.Type == genai.MeterDials {
+		if err := genai.ResolveDials(o.Meter, out, prevRead); err != nil {
+			return nil, fmt.Errorf("assemble dials: %w", err)
+		}
+	}
+	if err := genai.NormalizeResult(o.Meter, out); err != nil {
+		return nil, err
+	}
+	out.AmbiguousPositions = genai.CleanPositions(out.Read, out.AmbiguousPositions)
+	out.Confidences = genai.CleanConfidences(out.Read, out.Confidences)
+	out.Issue = genai.CleanIssue(out.Issue)
+	if out.CounterBox != nil && !out.CounterBox.Valid() {
+		out.CounterBox = nil
+	}
+	if o.SerialCheck {
+		if err := genai.CheckSerial(o.Meter, out.SerialNumber); err != nil {
+			return nil, err
+		}
+	}
 	return out, nil
 }
 
@@ -119,14 +348,105 @@ type contentPart struct {
 	ImageURL *imageURLPart `json:"image_url,omitempty"`
 }
 
+// imageURLPart is an image by URL or, with jpeg set, an inline JPEG. Inline
+// images are base64-encoded into the data URL only while the request body is
+// sent (see [requestBody]), so no encoded copy is held in memory.
 type imageURLPart struct {
-	URL string `json:"url"`
+	URL  string `json:"url"`
+	jpeg []byte
+}
+
+// inlineJPEGMarker stands in for an inline image in the marshalled request.
+const inlineJPEGMarker = `"\u0000inline-jpeg\u0000"`
+
+func (p *imageURLPart) MarshalJSON() ([]byte, error) {
+	if p.jpeg == nil {
+		type plain imageURLPart
+		return json.Marshal((*plain)(p))
+	}
+	return []byte(`{"url":` + inlineJPEGMarker + `}`), nil
+}
+
+// inlineJPEGs returns the inline images of msgs in marshalling order.
+func inlineJPEGs(msgs []chatMessage) [][]byte {
+	var imgs [][]byte
+	for _, m := range msgs {
+		parts, _ := m.Content.([]contentPart)
+		for _, p := range parts {
+			if p.ImageURL != nil && p.ImageURL.jpeg != nil {
+				imgs = append(imgs, p.ImageURL.jpeg)
+			}
+		}
+	}
+	return imgs
+}
+
+// requestBody marshals body and returns a reader that streams it with the
+// inline images base64-encoded on the fly, along with its length.
+func requestBody(body chatCompletionRequest) (io.Reader, int64, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	imgs := inlineJPEGs(body.Messages)
+	chunks := bytes.Split(raw, []byte(inlineJPEGMarker))
+	if len(chunks) != len(imgs)+1 {
+		return nil, 0, fmt.Errorf("found %d inline image markers for %d images", len(chunks)-1, len(imgs))
+	}
+
+	const prefix = `"data:image/jpeg;base64,`
+	readers := []io.Reader{bytes.NewReader(chunks[0])}
+	size := int64(len(chunks[0]))
+	for i, img := range imgs {
+		readers = append(readers,
+			strings.NewReader(prefix), &base64Reader{src: img}, strings.NewReader(`"`),
+			bytes.NewReader(chunks[i+1]),
+		)
+		size += int64(len(prefix) + base64.StdEncoding.EncodedLen(len(img)) + 1 + len(chunks[i+1]))
+	}
+	return io.MultiReader(readers...), size, nil
+}
+
+// base64Reader encodes src with standard base64 a block at a time.
+type base64Reader struct {
+	src []byte
+	buf [4096]byte
+	out []byte
+}
+
+func (r *base64Reader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 {
+		if len(r.src) == 0 {
+			return 0, io.EOF
+		}
+		n := min(len(r.src), len(r.buf)/4*3)
+		base64.StdEncoding.Encode(r.buf[:], r.src[:n])
+		r.out, r.src = r.buf[:base64.StdEncoding.EncodedLen(n)], r.src[n:]
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
 }
 
 type chatCompletionRequest struct {
-	Model       string        `json:"model"`
-	Messages    []chatMessage `json:"messages"`
-	Temperature float64       `json:"temperature,omitempty"`
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	Temperature         float64         `json:"temperature,omitempty"`
+	ResponseFormat      *responseFormat `json:"response_format,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string          `json:"reasoning_effort,omitempty"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+type jsonSchema struct {
+	Name   string `json:"name"`
+	Schema any    `json:"schema"`
+	Strict bool   `json:"strict"`
 }
 
 type chatCompletionResponse struct {
@@ -134,28 +454,132 @@ type chatCompletionResponse struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		// PromptTokensDetails reports the prompt prefix the backend cached
+		// on its own, as OpenAI does; there is no context cache to manage.
+		PromptTokensDetails *struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details,omitempty"`
+		// CompletionTokensDetails reports the part of CompletionTokens a
+		// reasoning model spent thinking.
+		CompletionTokensDetails *struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details,omitempty"`
+	} `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
-func (c *Client) chatCompletion(ctx context.Context, messages []chatMessage, temperature float64) (string, error) {
+// readingFormat asks the backend to constrain output to the meter's answer schema.
+func readingFormat(schema map[string]any) *responseFormat {
+	return &responseFormat{
+		Type: "json_schema",
+		JSONSchema: &jsonSchema{
+			Name:   "gas_meter_reading",
+			Schema: schema,
+			Strict: true,
+		},
+	}
+}
+
+// completionCall is one chat/completions request.
+type completionCall struct {
+	kind        string // genai.CallRead, genai.CallGuess, genai.CallVerify or genai.CallFlow
+	model       string // empty for the client's model
+	messages    []chatMessage
+	temperature float64
+	format      *responseFormat   // nil for free-text answers
+	gen         *genai.Generation // nil for that of the options and the model
+	prompt      string            // rendered user prompt, for the audit log
+	image       []byte            // for the audit log (hash and size only); may be nil
+}
+
+// chatCompletion runs call and returns the first choice's content and finish
+// reason, recording the call with the configured auditor.
+func (c *Client) chatCompletion(ctx context.Context, s *genai.Snapshot, call completionCall) (content, finishReason string, err error) {
+	o := &s.Options
+	if err := o.Limiter.Wait(ctx); err != nil {
+		return "", "", err
+	}
+
+	if call.model == "" {
+		call.model = s.Model
+	}
+	if call.gen == nil {
+		g := o.Generation.For(call.model)
+		call.gen = &g
+	}
+	start := o.Clock.Now()
+	ctx, span := o.StartSpan(ctx, genai.SpanGenerate)
+	content, finishReason, usage, err := c.doChatCompletion(ctx, call)
+	span.SetAttributes(genai.CallAttributes(call.kind, call.model, usage, finishReason)...)
+	if call.image != nil {
+		span.SetAttributes(genai.AttrImageSize.Int(len(call.image)))
+	}
+	genai.EndSpan(span, err)
+
+	e := genai.AuditEntry{
+		Time:     start,
+		Call:     call.kind,
+		Model:    call.model,
+		Prompt:   call.prompt,
+		Response: content,
+		Usage:    usage,
+		Latency:  genai.Since(o.Clock, start).String(),
+	}
+	if call.kind == genai.CallRead {
+		e.SystemPrompt = s.Prompts.SystemText
+	}
+	if call.image != nil {
+		sum := sha256.Sum256(call.image)
+		e.ImageSHA256 = hex.EncodeToString(sum[:])
+		e.ImageSize = int64(len(call.image))
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	c.stats.CountUsage(usage)
+	genai.AddUsage(ctx, usage)
+	o.Audit(e)
+
+	return content, finishReason, err
+}
+
+func (c *Client) doChatCompletion(ctx context.Context, call completionCall) (string, string, genai.Usage, error) {
+	var usage genai.Usage
+
 	body := chatCompletionRequest{
-		Model:       c.model,
-		Messages:    messages,
-		Temperature: temperature,
+		Model:           call.model,
+		Messages:        call.messages,
+		Temperature:     call.temperature,
+		ResponseFormat:  call.format,
+		ReasoningEffort: call.gen.ReasoningEffort,
 	}
-	raw, err := json.Marshal(body)
+	if n := call.gen.MaxOutputTokens; n > 0 {
+		// Reasoning models take only the newer field, which counts the
+		// reasoning too; other backends may know the older one only.
+		if call.gen.ReasoningEffort != "" {
+			body.MaxCompletionTokens = n
+		} else {
+			body.MaxTokens = n
+		}
+	}
+	reqBody, size, err := requestBody(body)
 	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
+		return "", "", usage, fmt.Errorf("marshal request: %w", err)
 	}
 
 	url := c.baseURL + "/chat/completions"
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("build request: %w", err)
+		return "", "", usage, fmt.Errorf("build request: %w", err)
 	}
+	req.ContentLength = size
 	req.Header.Set("Content-Type", "application/json")
 	if c.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+c.apiKey)
@@ -163,119 +587,104 @@ func (c *Client) chatCompletion(ctx context.Context, messages []chatMessage, tem
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("http: %w", err)
+		return "", "", usage, fmt.Errorf("http: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return "", "", usage, fmt.Errorf("read response: %w", err)
 	}
 
 	var parsed chatCompletionResponse
 	decodeErr := json.Unmarshal(respBody, &parsed)
+	if parsed.Usage != nil {
+		usage = genai.Usage{InputTokens: parsed.Usage.PromptTokens, OutputTokens: parsed.Usage.CompletionTokens}
+		if d := parsed.Usage.PromptTokensDetails; d != nil {
+			usage.CachedTokens = d.CachedTokens
+		}
+		if d := parsed.Usage.CompletionTokensDetails; d != nil {
+			usage.ThinkingTokens = d.ReasoningTokens
+		}
+	}
 	if decodeErr != nil {
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return "", fmt.Errorf("http status %d: %w; body: %s", resp.StatusCode, decodeErr, truncate(string(respBody), 500))
+			return "", "", usage, fmt.Errorf("http status %d: %w; body: %s", resp.StatusCode, decodeErr, genai.Truncate(string(respBody), 500))
 		}
-		return "", fmt.Errorf("decode response (status %d): %w; body: %s", resp.StatusCode, decodeErr, truncate(string(respBody), 500))
+		return "", "", usage, fmt.Errorf("decode response (status %d): %w; body: %s", resp.StatusCode, decodeErr, genai.Truncate(string(respBody), 500))
 	}
 	if parsed.Error != nil && parsed.Error.Message != "" {
-		return "", fmt.Errorf("api error: %s", parsed.Error.Message)
+		return "", "", usage, fmt.Errorf("api error: %s", parsed.Error.Message)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("http status %d: %s", resp.StatusCode, truncate(string(respBody), 500))
+		return "", "", usage, fmt.Errorf("http status %d: %s", resp.StatusCode, genai.Truncate(string(respBody), 500))
 	}
 	if len(parsed.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response: %s", truncate(string(respBody), 500))
-	}
-	content := strings.TrimSpace(parsed.Choices[0].Message.Content)
-	if content == "" {
-		return "", fmt.Errorf("empty message content")
+		return "", "", usage, fmt.Errorf("no choices in response: %s", genai.Truncate(string(respBody), 500))
 	}
-	return content, nil
+	choice := parsed.Choices[0]
+	return strings.TrimSpace(choice.Message.Content), choice.FinishReason, usage, nil
 }
 
-func truncate(s string, max int) string {
-	if len(s) <= max {
-		return s
-	}
-	return s[:max] + "…"
+// Stats returns a snapshot of the client's counters.
+func (c *Client) Stats() genai.Stats {
+	return c.stats.Snapshot()
 }
 
-func parseGasMeterJSON(text string) (*genai.GasMeterReadResult, error) {
-	jsonStr := extractJSONObject(text)
-	if jsonStr == "" {
-		return nil, fmt.Errorf("no JSON object in model output: %s", truncate(text, 300))
+// SeedLastRead implements [genai.Seeder].
+func (c *Client) SeedLastRead(read string, at time.Time) error {
+	o := c.cfg.Load().Options
+	if err := genai.CheckSeed(o.Meter, read); err != nil {
+		return err
 	}
-	var out genai.GasMeterReadResult
-	if err := json.Unmarshal([]byte(jsonStr), &out); err != nil {
-		return nil, fmt.Errorf("json: %w", err)
+	if !o.Stateless {
+		c.refs.Reset(read, at)
 	}
-	return &out, nil
+	return nil
 }
 
-func extractJSONObject(s string) string {
-	s = strings.TrimSpace(s)
-	s = stripMarkdownFence(s)
-
-	start := strings.Index(s, "{")
-	if start == -1 {
-		return ""
+// SeedReading implements [genai.Seeder].
+func (c *Client) SeedReading(r *genai.GasMeterReadResult) error {
+	o := c.cfg.Load().Options
+	if err := genai.CheckSeed(o.Meter, r.Read); err != nil {
+		return err
 	}
-	depth := 0
-	for i := start; i < len(s); i++ {
-		switch s[i] {
-		case '{':
-			depth++
-		case '}':
-			depth--
-			if depth == 0 {
-				return s[start : i+1]
-			}
-		}
+	if !o.Stateless {
+		c.refs.Rewind(o.Meter.ID, r)
 	}
-	return ""
+	return nil
 }
 
-func stripMarkdownFence(s string) string {
-	s = strings.TrimSpace(s)
-	if strings.HasPrefix(s, "```") {
-		s = strings.TrimPrefix(s, "```")
-		s = strings.TrimSpace(s)
-		if idx := strings.Index(s, "\n"); idx != -1 {
-			// optional language tag on first line
-			rest := s[idx+1:]
-			if end := strings.Index(rest, "```"); end != -1 {
-				return strings.TrimSpace(rest[:end])
-			}
-			return strings.TrimSpace(rest)
-		}
+// Seed implements [genai.Seeder].
+func (c *Client) Seed() genai.Seed {
+	return c.refs.Seed()
+}
+
+// reference returns the reference reading for the prompts of s; stateless
+// clients have none.
+func (c *Client) reference(s *genai.Snapshot) genai.Seed {
+	if s.Options.Stateless {
+		return genai.Seed{}
 	}
-	return s
+	return c.refs.Reference()
 }
 
-func (c *Client) guessAmbiguousDigits(ctx context.Context, ambiguousValueString string) (string, error) {
-	if !genai.ContainsOnly(ambiguousValueString, ".?0123456789") {
-		return "", fmt.Errorf("ambiguous value string %q is not valid", ambiguousValueString)
+func (c *Client) guessAmbiguousDigits(ctx context.Context, s *genai.Snapshot, ambiguousValueString, prevRead string) (string, error) {
+	if err := s.Options.Meter.CheckRead(ambiguousValueString); err != nil {
+		return "", fmt.Errorf("ambiguous value: %w", err)
 	}
-	prompt := fmt.Sprintf(fixAmbiguousPromptFmt, ambiguousValueString, c.lastRead)
-	content, err := c.chatCompletion(ctx, []chatMessage{
-		{Role: "user", Content: prompt},
-	}, 0.1)
+	prompt := s.Prompts.DisambiguatePrompt(ambiguousValueString, prevRead)
+	content, finish, err := c.chatCompletion(ctx, s, completionCall{
+		kind:        genai.CallGuess,
+		messages:    []chatMessage{{Role: "user", Content: prompt}},
+		temperature: 0.1,
+		prompt:      prompt,
+	})
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(content), nil
+	if content == "" {
+		return "", fmt.Errorf("empty guess (finish reason %q)", finish)
+	}
+	return genai.SanitizeGuess(s.Options.Meter, ambiguousValueString, content)
 }
-
-const fixAmbiguousPromptFmt = `The value "%s" represents the output of a analog-meter-reading analysis performed on an image.
-Uncertain digits within the reading are denoted by the "?" character.
-
-Using the previously recorded meter value "%s" as a reference (only if it is not empty),
-infer and replace the "?" characters to estimate the most probable complete reading.
-
-Instructions:
-- Return a string with the exact same length as the input value.
-- Output only the predicted value, without any explanations or additional text.
-`
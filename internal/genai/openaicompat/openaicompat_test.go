@@ -1,58 +1,881 @@
 package openaicompat
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+	"github.com/suapapa/mqvision/internal/validate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func TestExtractJSONObject(t *testing.T) {
+// newTestServer answers chat/completions with content and records the last request.
+func newTestServer(t *testing.T, content string, got *chatCompletionRequest) *httptest.Server {
+	t.Helper()
+	return newFinishServer(t, content, "stop", got)
+}
+
+// newFinishServer is newTestServer with the finish reason of the answer.
+func newFinishServer(t *testing.T, content, finishReason string, got *chatCompletionRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = chatCompletionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		var resp chatCompletionResponse
+		resp.Choices = make([]struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		}, 1)
+		resp.Choices[0].Message.Content = content
+		resp.Choices[0].FinishReason = finishReason
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReadGasGaugePicResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, "```json\n{\"read\":\"02924.457\",\"date\":\"\"}\n```", &got)
+
+	c, err := NewClient(srv.URL, "key", "model", "", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Read != "02924.457" {
+		t.Fatalf("Read = %q", res.Read)
+	}
+	if res.Model != "model" || res.PromptHash == "" || res.ReaderVersion != genai.Version {
+		t.Fatalf("attribution fields = %q, %q, %q", res.Model, res.PromptHash, res.ReaderVersion)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_schema" {
+		t.Fatalf("response_format = %#v, want json_schema", got.ResponseFormat)
+	}
+	if schema, ok := got.ResponseFormat.JSONSchema.Schema.(map[string]any); !ok || !slices.Contains(schema["required"].([]any), any("confidences")) {
+		t.Fatalf("response_format schema = %v, want confidences required", got.ResponseFormat.JSONSchema.Schema)
+	}
+
+	res, err = c.ReadGasGaugePic(genai.ContextWithModel(context.Background(), "strong"), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic with a context model: %v", err)
+	}
+	if got.Model != "strong" || res.Model != "strong" {
+		t.Fatalf("model of the request %q and result %q, want the context's", got.Model, res.Model)
+	}
+
+	c, err = NewClient(srv.URL, "key", "model", "", "", genai.WithResponseSchema(false))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if got.ResponseFormat != nil {
+		t.Fatalf("response_format = %#v, want none", got.ResponseFormat)
+	}
+}
+
+type recordingAuditor struct {
+	entries []genai.AuditEntry
+}
+
+func (a *recordingAuditor) Audit(e genai.AuditEntry) { a.entries = append(a.entries, e) }
+
+func TestReadGasGaugePicAudit(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+
+	a := &recordingAuditor{}
+	c, err := NewClient(srv.URL, "sk-secret", "model", "", "", genai.WithAuditor(a))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+
+	if len(a.entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(a.entries))
+	}
+	e := a.entries[0]
+	if e.Call != genai.CallRead || e.ImageSize != 4 || e.ImageSHA256 == "" || e.Prompt == "" {
+		t.Fatalf("entry = %+v", e)
+	}
+	raw, _ := json.Marshal(e)
+	if strings.Contains(string(raw), "sk-secret") {
+		t.Fatalf("audit entry leaks the API key: %s", raw)
+	}
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct{ n atomic.Int32 }
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.n.Add(1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestReadGasGaugePicTransport(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+	rt := &countingTransport{}
+	c, err := NewClient(srv.URL, "sk", "model", "", "", genai.WithTransport(rt))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if n := rt.n.Load(); n != 1 {
+		t.Fatalf("%d requests through the transport, want 1", n)
+	}
+}
+
+func TestReadGasGaugePicClock(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+
+	now := time.Date(2025, 11, 7, 5, 13, 17, 0, time.UTC)
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithClock(genaitest.NewClock(now)))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if !res.ReadAt.Equal(now) || res.ItTakes != "0s" {
+		t.Fatalf("ReadAt = %v, ItTakes = %q; want %v, 0s", res.ReadAt, res.ItTakes, now)
+	}
+}
+
+// BenchmarkReadGasGaugePic reports the allocations of sending a 4 MiB image.
+func BenchmarkReadGasGaugePic(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"choices":[{"message":{"content":"{\"read\":\"02924.457\",\"date\":\"\"}"}}]}`)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, "key", "model", "", "")
+	if err != nil {
+		b.Fatalf("NewClient: %v", err)
+	}
+	img := bytes.Repeat([]byte{0xff}, 4<<20)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.ReadGasGaugePic(ctx, bytes.NewReader(img)); err != nil {
+			b.Fatalf("ReadGasGaugePic: %v", err)
+		}
+	}
+}
+
+func TestReadGasGaugePicInlineImage(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+
+	example := filepath.Join(t.TempDir(), "example.jpg")
+	if err := os.WriteFile(example, []byte("example jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(srv.URL, "key", "model", "", "",
+		genai.WithExampleImages([]genai.Example{{Path: example, ExpectedRead: "01234.567"}}),
+		genai.WithMaxImageSize(5000),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	// Long enough to span several base64Reader blocks.
+	img := bytes.Repeat([]byte("jpeg\x00\xff"), 800)
+	if _, err := c.ReadGasGaugePic(context.Background(), bytes.NewReader(img)); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+
+	var urls []string
+	for _, m := range got.Messages {
+		parts, _ := m.Content.([]any)
+		for _, p := range parts {
+			if u, ok := p.(map[string]any)["image_url"].(map[string]any); ok {
+				urls = append(urls, u["url"].(string))
+			}
+		}
+	}
+	want := []string{
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString([]byte("example jpeg")),
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(img),
+	}
+	if !slices.Equal(urls, want) {
+		t.Fatalf("image urls = %.80q, want %.80q", urls, want)
+	}
+
+	_, err = c.ReadGasGaugePic(context.Background(), bytes.NewReader(make([]byte, 5001)))
+	if !errors.Is(err, genai.ErrImageTooLarge) {
+		t.Fatalf("err = %v, want ErrImageTooLarge", err)
+	}
+}
+
+// TestSeedRestart reads, stores the reading and restarts: the new client
+// must start from the stored reading, and an explicit seed must win over it.
+func TestSeedRestart(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	ctx := context.Background()
+	const prompt = "prev={{.PrevRead}}"
+	home := genai.WithMeter(genai.Meter{ID: "home"})
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(srv.URL, "key", "model", "", prompt, home, genai.WithSeedStore(s))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if seed := c.Seed(); seed.Source != genai.SeedNone {
+		t.Fatalf("seed of an empty store = %+v", seed)
+	}
+	res, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if err := s.Save(ctx, "home", res); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c, err = NewClient(srv.URL, "key", "model", "", prompt, home, genai.WithSeedStore(s))
+	if err != nil {
+		t.Fatalf("NewClient after restart: %v", err)
+	}
+	if seed := c.Seed(); seed.Source != genai.SeedStore || seed.Read != "02924.457" || !seed.At.Equal(res.ReadAt) {
+		t.Fatalf("seed after restart = %+v", seed)
+	}
+	if _, err := c.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if raw, _ := json.Marshal(got.Messages); !strings.Contains(string(raw), "prev=02924.457") {
+		t.Fatalf("first prompt after restart lacks the stored reading: %s", raw)
+	}
+
+	at := time.Date(2025, 11, 8, 5, 0, 0, 0, time.UTC)
+	c, err = NewClient(srv.URL, "key", "model", "", prompt, home, genai.WithSeedStore(s), genai.WithSeed("02930.000", at))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if seed := c.Seed(); seed.Source != genai.SeedExplicit || seed.Read != "02930.000" {
+		t.Fatalf("explicit seed = %+v", seed)
+	}
+	if err := c.SeedLastRead("2930", at); err == nil {
+		t.Fatal("SeedLastRead accepted a reading in another format")
+	}
+	if _, err := NewClient(srv.URL, "key", "model", "", prompt, home, genai.WithSeed("2930", at)); err == nil {
+		t.Fatal("NewClient accepted a seed in another format")
+	}
+}
+
+func TestReadGasGaugePicTruncated(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name string
-		in   string
-		want string
+		name, content, finish string
+		want                  []error
 	}{
-		{name: "plain object", in: `{"read":"1.23","date":"x"}`, want: `{"read":"1.23","date":"x"}`},
-		{name: "prefixed text", in: `Here: {"read":"1"}`, want: `{"read":"1"}`},
-		{name: "markdown fence", in: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
-		{name: "no object", in: "no brace", want: ""},
+		{"cut off json", `{"read":"0292`, "length", []error{genai.ErrTruncatedOutput, genai.ErrInvalidModelOutput}},
+		{"no content", "", "content_filter", []error{genai.ErrEmptyReading}},
+		{"empty read", `{"read":"","date":""}`, "stop", []error{genai.ErrEmptyReading}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got := extractJSONObject(tt.in)
-			if got != tt.want {
-				t.Fatalf("extractJSONObject() = %q, want %q", got, tt.want)
+			var got chatCompletionRequest
+			srv := newFinishServer(t, tt.content, tt.finish, &got)
+			c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithSeed("02924.457", time.Time{}))
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			_, err = c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+			if !strings.Contains(err.Error(), tt.finish) {
+				t.Fatalf("err = %v, want the finish reason %q", err, tt.finish)
+			}
+			if c.reference(c.Snapshot()).Read != "02924.457" {
+				t.Fatalf("prevRead = %q after a failed reading", c.reference(c.Snapshot()).Read)
 			}
 		})
 	}
 }
 
-func TestParseGasMeterJSON(t *testing.T) {
+func TestReadGasGaugePicTracing(t *testing.T) {
 	t.Parallel()
 
-	valid := `{"read":"123.4","date":"2024-01-01"}`
-	res, err := parseGasMeterJSON(valid)
+	tests := []struct {
+		name, content, finish string
+		wantErr               bool
+	}{
+		{"ok", `{"read":"02924.457","date":""}`, "stop", false},
+		{"truncated", `{"read":"0292`, "length", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got chatCompletionRequest
+			srv := newFinishServer(t, tt.content, tt.finish, &got)
+			rec := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+			c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithTracerProvider(tp))
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); (err != nil) != tt.wantErr {
+				t.Fatalf("ReadGasGaugePic error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, s := range rec.Ended() {
+				spans[s.Name()] = s
+			}
+			read := spans[genai.SpanRead]
+			if read == nil {
+				t.Fatalf("no %s span in %v", genai.SpanRead, slices.Collect(maps.Keys(spans)))
+			}
+			for _, name := range []string{genai.SpanGenerate, genai.SpanValidate} {
+				s := spans[name]
+				if s == nil || s.Parent().SpanID() != read.SpanContext().SpanID() {
+					t.Fatalf("%s span = %v, want a child of the read span", name, s)
+				}
+			}
+			generate := spans[genai.SpanGenerate]
+			if v := spanAttr(generate, genai.AttrModel); v.AsString() != "model" {
+				t.Fatalf("%s = %q", genai.AttrModel, v.AsString())
+			}
+			if v := spanAttr(generate, genai.AttrImageSize); v.AsInt64() != 4 {
+				t.Fatalf("%s = %d", genai.AttrImageSize, v.AsInt64())
+			}
+			wantCode := codes.Unset
+			if tt.wantErr {
+				wantCode = codes.Error
+			}
+			for _, s := range []sdktrace.ReadOnlySpan{read, spans[genai.SpanValidate]} {
+				if s.Status().Code != wantCode {
+					t.Fatalf("%s status = %v, want %v", s.Name(), s.Status(), wantCode)
+				}
+			}
+			if v := spanAttr(read, genai.AttrRead); !tt.wantErr && v.AsString() != "02924.457" {
+				t.Fatalf("%s = %q", genai.AttrRead, v.AsString())
+			}
+		})
+	}
+}
+
+// spanAttr returns the attribute key of s.
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestReadGasGaugePicWrongMeter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, content string
+		wantErr       bool
+	}{
+		{"own meter", `{"read":"02924.457","date":"","serial_number":"GM2019-4??13"}`, false},
+		{"neighbour", `{"read":"01022.100","date":"","serial_number":"GM2019-51077"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got chatCompletionRequest
+			srv := newTestServer(t, tt.content, &got)
+			meter := genai.DefaultMeter
+			meter.Serial = "GM2019-48213"
+			c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithMeter(meter))
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+			if tt.wantErr {
+				if !errors.Is(err, genai.ErrWrongMeter) {
+					t.Fatalf("err = %v, want ErrWrongMeter", err)
+				}
+				if c.reference(c.Snapshot()).Read != "" {
+					t.Fatalf("prevRead = %q after a rejected reading", c.reference(c.Snapshot()).Read)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadGasGaugePic: %v", err)
+			}
+			if res.SerialNumber != "GM2019-4??13" {
+				t.Fatalf("SerialNumber = %q", res.SerialNumber)
+			}
+			if !strings.Contains(got.Messages[0].Content.(string), "serial_number") {
+				t.Fatalf("system prompt does not ask for the serial number")
+			}
+		})
+	}
+}
+
+func TestReadGasGaugePicNormalize(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":" 01234,567 m3","date":""}`, &got)
+	meter := genai.DefaultMeter
+	meter.Normalize = genai.Normalization{Units: []string{"m3"}, DecimalSeparator: ","}
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithMeter(meter))
 	if err != nil {
-		t.Fatalf("parseGasMeterJSON: %v", err)
+		t.Fatalf("NewClient: %v", err)
 	}
-	if res.Read != "123.4" || res.Date != "2024-01-01" {
-		t.Fatalf("result = %#v", res)
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
 	}
+	if res.Read != "01234.567" || res.RawRead != " 01234,567 m3" || c.reference(c.Snapshot()).Read != "01234.567" {
+		t.Fatalf("Read = %q, RawRead = %q, prevRead %q", res.Read, res.RawRead, c.reference(c.Snapshot()).Read)
+	}
+}
+
+func TestReadGasGaugePicSelfVerify(t *testing.T) {
+	t.Parallel()
 
-	_, err = parseGasMeterJSON("no json")
-	if err == nil {
-		t.Fatal("expected error for no json")
+	// The model corrects the last digit and is still unsure of another.
+	answers := []string{`{"read":"02924.457","date":"2025-11-07"}`, `{"read":"0292?.451","date":"2025-11-07"}`}
+	var reqs []chatCompletionRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+		fmt.Fprintf(w, `{"choices":[{"message":{"content":%q},"finish_reason":"stop"}]}`, answers[len(reqs)-1])
+	}))
+	t.Cleanup(srv.Close)
+
+	a := &recordingAuditor{}
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithSelfVerify(), genai.WithAuditor(a))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if res.Read != "02924.457" || res.FirstRead != "02924.457" || res.VerifiedRead != "02924.451" || !res.VerifyDisagrees() {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.Timing == nil || res.Timing.Verify == "" || c.Stats().VerifyDisagreements != 1 {
+		t.Fatalf("timing = %+v, stats = %+v", res.Timing, c.Stats())
+	}
+	if len(reqs) != 2 || len(a.entries) != 2 || a.entries[1].Call != genai.CallVerify || a.entries[1].ImageSize != 4 {
+		t.Fatalf("%d requests, audit entries %+v; want a read and a verify call", len(reqs), a.entries)
+	}
+	// The follow-up turn carries the conversation of the reading, image included.
+	msgs := reqs[1].Messages
+	if len(msgs) != 4 || msgs[2].Role != "assistant" || msgs[2].Content != `{"date":"2025-11-07","read":"02924.457"}` ||
+		!strings.Contains(fmt.Sprint(msgs[3].Content), `"02924.457"`) || fmt.Sprint(msgs[1]) != fmt.Sprint(reqs[0].Messages[1]) {
+		t.Fatalf("verify messages = %+v", msgs)
 	}
 }
 
-func TestStripMarkdownFence(t *testing.T) {
+func TestReadGasGaugePicSelfVerifyContext(t *testing.T) {
 	t.Parallel()
 
-	in := "```json\n" + strings.TrimSpace(`{"x":1}`) + "\n```"
-	got := stripMarkdownFence(in)
-	if !strings.Contains(got, "{") {
-		t.Fatalf("stripMarkdownFence: %q", got)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"read\":\"02924.457\",\"date\":\"\"}"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithStateless())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil || calls != 1 {
+		t.Fatalf("plain read made %d calls: %v", calls, err)
+	}
+	res, err := c.ReadGasGaugePic(genai.ContextWithSelfVerify(context.Background()), strings.NewReader("jpeg"))
+	if err != nil || calls != 3 || res.VerifiedRead != "02924.457" || res.VerifyDisagrees() {
+		t.Fatalf("read under ContextWithSelfVerify made %d calls: %+v, %v", calls-1, res, err)
+	}
+}
+
+func TestReadGasGaugePicCrossCheck(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, `{"read":"02924.457","confidences":[1,1,1,1,0.6,1,1,0.5],"date":"","issue":{"kind":"none","note":""}}`, &got)
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithStateless())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	p, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.MinConfidence, Threshold: 0.8}, {Name: validate.CrossCheck, Fatal: true}})
+	if err != nil {
+		t.Fatalf("validate.New: %v", err)
+	}
+	read := func(cc *genai.CrossCheck) (*genai.GasMeterReadResult, error) {
+		t.Helper()
+		res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+		if err != nil {
+			t.Fatalf("ReadGasGaugePic: %v", err)
+		}
+		res.SetCrossCheck(cc)
+		return res, p.Run(context.Background(), nil, res)
+	}
+
+	res, err := read(&genai.CrossCheck{Recognizer: "seven_segment", Read: "0292?457", Confidence: 0.7})
+	if err != nil {
+		t.Fatalf("agreeing reading rejected: %v", err)
+	}
+	if got := fmt.Sprintf("%.2f", res.Confidences); got != "[1.00 1.00 1.00 1.00 0.88 1.00 1.00 0.85]" {
+		t.Fatalf("confidences after agreement = %s", got)
+	}
+
+	res, err = read(&genai.CrossCheck{Recognizer: "seven_segment", Read: "02924451", Confidence: 0.7})
+	if !errors.Is(err, validate.ErrLowConfidence) {
+		t.Fatalf("disagreeing reading: %v, want the model's own confidences rejected", err)
+	}
+	if got := fmt.Sprintf("%.2f", res.Confidences); got != "[1.00 1.00 1.00 1.00 0.60 1.00 1.00 0.50]" {
+		t.Fatalf("confidences after disagreement = %s", got)
+	}
+}
+
+func TestDetectFlow(t *testing.T) {
+	t.Parallel()
+
+	var got chatCompletionRequest
+	srv := newTestServer(t, "```json\n{\"flow_detected\":true,\"rotation\":1.5,\"note\":\"turned\"}\n```", &got)
+	a := &recordingAuditor{}
+	c, err := NewClient(srv.URL, "key", "model", "", "", genai.WithAuditor(a), genai.WithResponseSchema(true))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	res, err := c.DetectFlow(context.Background(), strings.NewReader("first"), strings.NewReader("second"))
+	if err != nil {
+		t.Fatalf("DetectFlow: %v", err)
+	}
+	if !res.FlowDetected || res.Rotation != 1 || res.Note != "turned" || res.Model != "model" || res.At.IsZero() {
+		t.Fatalf("DetectFlow = %+v, want flow with the rotation clamped to 1", res)
+	}
+
+	var urls []string
+	parts, _ := got.Messages[0].Content.([]any)
+	for _, p := range parts {
+		if u, ok := p.(map[string]any)["image_url"].(map[string]any); ok {
+			urls = append(urls, u["url"].(string))
+		}
+	}
+	want := []string{
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString([]byte("first")),
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString([]byte("second")),
+	}
+	if len(got.Messages) != 1 || !slices.Equal(urls, want) {
+		t.Fatalf("%d messages with image urls %q, want one with %q", len(got.Messages), urls, want)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.JSONSchema.Name != "flow_indicator" {
+		t.Fatalf("response_format = %#v, want the flow schema", got.ResponseFormat)
+	}
+	if len(a.entries) != 1 || a.entries[0].Call != genai.CallFlow || a.entries[0].ImageSize != 5 {
+		t.Fatalf("audit entries %+v, want one flow call of the first image", a.entries)
+	}
+
+	if _, err := c.DetectFlow(context.Background(), strings.NewReader("first"), strings.NewReader("")); err == nil {
+		t.Fatal("DetectFlow accepted an empty image")
+	}
+}
+
+func TestReconfigureDuringRead(t *testing.T) {
+	t.Parallel()
+
+	// The first reading call blocks until the client is reconfigured; its
+	// ambiguous answer makes the reading call the model again to guess.
+	answers := []string{`{"read":"0292?.457","date":""}`, "02924.457", `{"read":"02925.000","date":""}`}
+	started, reconfigured := make(chan struct{}), make(chan struct{})
+	var reqs []chatCompletionRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+		n := len(reqs)
+		if n == 1 {
+			close(started)
+			<-reconfigured
+		}
+		var resp chatCompletionResponse
+		resp.Choices = make([]struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		}, 1)
+		resp.Choices[0].Message.Content = answers[min(n, len(answers))-1]
+		resp.Choices[0].FinishReason = "stop"
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, "key", "old-model", "", "old prompt", genai.WithSerialCheck(false))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	old := c.Snapshot()
+
+	type result struct {
+		res *genai.GasMeterReadResult
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+		done <- result{res, err}
+	}()
+	<-started
+	if err := c.Reconfigure("new-model", "", "new prompt", genai.WithSerialCheck(false)); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	close(reconfigured)
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", r.err)
+	}
+	if r.res.Read != "02924.457" || r.res.Model != "old-model" || r.res.ConfigHash != old.Hash || r.res.PromptHash != old.Prompts.Hash {
+		t.Fatalf("reading during the reload = %+v, want the old config %s", r.res, old.Hash)
+	}
+	if len(reqs) != 2 || reqs[1].Model != "old-model" {
+		t.Fatalf("disambiguation requests %d, model %q; want the old model", len(reqs), reqs[len(reqs)-1].Model)
+	}
+
+	if got := c.reference(c.Snapshot()).Read; got != "02924.457" {
+		t.Fatalf("prevRead = %q after the reload, want the reading kept", got)
+	}
+
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	body, _ := json.Marshal(reqs[2])
+	if res.Model != "new-model" || res.ConfigHash != c.Snapshot().Hash || res.ConfigHash == old.Hash || !strings.Contains(string(body), "new prompt") {
+		t.Fatalf("reading after the reload = %+v with request %s, want the new config", res, body)
+	}
+}
+
+func TestGuessReference(t *testing.T) {
+	t.Parallel()
+
+	// A confident reading, then two with an uncertain digit the model guesses.
+	answers := []string{`{"read":"02924.457","date":""}`, `{"read":"0292?.557","date":""}`, "02924.557", `{"read":"0292?.657","date":""}`, "02924.657"}
+	var reqs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, string(body))
+		var resp chatCompletionResponse
+		resp.Choices = make([]struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		}, 1)
+		resp.Choices[0].Message.Content = answers[min(len(reqs), len(answers))-1]
+		resp.Choices[0].FinishReason = "stop"
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, "key", "m", "", "", genai.WithSerialCheck(false), genai.WithMeter(genai.Meter{ID: "home"}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	var res []*genai.GasMeterReadResult
+	for range 3 {
+		r, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+		if err != nil {
+			t.Fatalf("ReadGasGaugePic: %v", err)
+		}
+		res = append(res, r)
+	}
+	first := genai.ReadingID("home", res[0])
+	if res[0].Provenance != genai.ProvenanceConfident || res[0].ReferenceID != "" {
+		t.Fatalf("first reading %q, reference %q", res[0].Provenance, res[0].ReferenceID)
+	}
+	for _, r := range res[1:] {
+		if r.Provenance != genai.ProvenanceGuessed || r.ReferenceID != first {
+			t.Fatalf("reading %s: %q, reference %q; want guessed against %s", r.Read, r.Provenance, r.ReferenceID, first)
+		}
+	}
+	// The second guess is made against the confident reading, not the first guess.
+	if guess := reqs[4]; !strings.Contains(guess, "02924.457") || strings.Contains(guess, "02924.557") {
+		t.Fatalf("second disambiguation request %s, want the confident reading", guess)
+	}
+}
+
+func TestReadGasGaugePicTimeouts(t *testing.T) {
+	t.Parallel()
+
+	// hangs answers quickly once the first n calls hung until cut off.
+	hangs := func(n int) *httptest.Server {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= int32(n) {
+				// Read the body for the server to notice the client going.
+				io.Copy(io.Discard, r.Body)
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+				return
+			}
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"read\":\"02924.457\",\"date\":\"2025-11-07\"}"},"finish_reason":"stop"}]}`)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	read := func(srv *httptest.Server, opts ...genai.Option) (*genai.GasMeterReadResult, error) {
+		c, err := NewClient(srv.URL, "key", "model", "", "", opts...)
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		return c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	}
+
+	// The retry gets a generate timeout of its own, and the timing shows
+	// the one that was cut off.
+	res, err := read(hangs(1), genai.WithRetries(1), genai.WithTimeouts(genai.Timeouts{Call: 5 * time.Second, Generate: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic with a retry: %v", err)
+	}
+	if !slices.Equal(res.Timing.TimedOut, []string{genai.PhaseGenerate}) {
+		t.Fatalf("timing = %+v, want generate timed out", res.Timing)
+	}
+
+	_, err = read(hangs(1), genai.WithTimeouts(genai.Timeouts{Generate: 50 * time.Millisecond}))
+	var te *genai.TimeoutError
+	if !errors.As(err, &te) || te.Phase != genai.PhaseGenerate || te.Call || te.Timeout != 50*time.Millisecond {
+		t.Fatalf("ReadGasGaugePic: %v, want a generate timeout", err)
+	}
+	if genai.ClassifyFailure(err) != genai.FailureTimeout {
+		t.Fatalf("ClassifyFailure(%v) = %s", err, genai.ClassifyFailure(err))
+	}
+
+	// The deadline of the call bounds the phases, retries included.
+	_, err = read(hangs(2), genai.WithRetries(1), genai.WithTimeouts(genai.Timeouts{Call: 100 * time.Millisecond, Generate: time.Minute}))
+	if !errors.As(err, &te) || te.Phase != genai.PhaseGenerate || !te.Call {
+		t.Fatalf("ReadGasGaugePic: %v, want the call timed out in generate", err)
+	}
+}
+
+func TestReadGasGaugePicGeneration(t *testing.T) {
+	t.Parallel()
+
+	// The first answer is cut off by the limit, the second fits in the raised one.
+	answers := []string{
+		`{"choices":[{"message":{"content":"{\"read\":\"0292"},"finish_reason":"length"}],"usage":{"prompt_tokens":900,"completion_tokens":2048,"completion_tokens_details":{"reasoning_tokens":2000}}}`,
+		`{"choices":[{"message":{"content":"{\"read\":\"02924.457\",\"date\":\"\"}"},"finish_reason":"stop"}],"usage":{"prompt_tokens":900,"completion_tokens":2100,"completion_tokens_details":{"reasoning_tokens":2080}}}`,
+	}
+	var reqs []chatCompletionRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		reqs = append(reqs, req)
+		fmt.Fprint(w, answers[len(reqs)-1])
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.URL, "key", "gpt-5-mini", "", "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	res, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("%d requests, want a retry of the truncated one", len(reqs))
+	}
+	if r := reqs[0]; r.ReasoningEffort != genai.EffortMinimal || r.MaxCompletionTokens != 2048 || r.MaxTokens != 0 {
+		t.Fatalf("first request: reasoning_effort %q, max_completion_tokens %d, max_tokens %d", r.ReasoningEffort, r.MaxCompletionTokens, r.MaxTokens)
+	}
+	if n := reqs[1].MaxCompletionTokens; n != 4*2048 {
+		t.Fatalf("retry max_completion_tokens = %d, want %d", n, 4*2048)
+	}
+	if tm := res.Timing; tm == nil || tm.InputTokens != 1800 || tm.OutputTokens != 4148 || tm.ThinkingTokens != 4080 {
+		t.Fatalf("timing = %+v, want the tokens of both calls", res.Timing)
+	}
+
+	// A model that does not reason is sent max_tokens only.
+	var got chatCompletionRequest
+	srv = newTestServer(t, `{"read":"02924.457","date":""}`, &got)
+	c, err = NewClient(srv.URL, "key", "llava", "", "", genai.WithGeneration(genai.Generation{MaxOutputTokens: 300}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.ReadGasGaugePic(context.Background(), strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	if got.MaxTokens != 300 || got.MaxCompletionTokens != 0 || got.ReasoningEffort != "" {
+		t.Fatalf("request: max_tokens %d, max_completion_tokens %d, reasoning_effort %q", got.MaxTokens, got.MaxCompletionTokens, got.ReasoningEffort)
 	}
 }
@@ -0,0 +1,89 @@
+package openaicompat
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+var flagRecord = flag.Bool("record", false,
+	"record cassettes against the real API named by OPENAI_BASE_URL, OPENAI_API_KEY and OPENAI_MODEL")
+
+// replayClient returns a Client backed by the cassette testdata/cassettes/<name>.json.
+// With -record it talks to the real API and rewrites the cassette if the test passes:
+//
+//	OPENAI_BASE_URL=https://api.openai.com/v1 OPENAI_API_KEY=... OPENAI_MODEL=gpt-4o-mini \
+//	  go test ./internal/genai/openaicompat -run Replay -record
+func replayClient(t *testing.T, name string, opts ...genai.Option) *Client {
+	t.Helper()
+
+	baseURL, apiKey, model := "http://replay.invalid/v1", "", "replay-model"
+	if *flagRecord {
+		baseURL, apiKey, model = os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY"), os.Getenv("OPENAI_MODEL")
+		if baseURL == "" || model == "" {
+			t.Skip("-record needs OPENAI_BASE_URL and OPENAI_MODEL")
+		}
+	}
+
+	rec, err := genaitest.NewRecorder(filepath.Join("testdata", "cassettes", name+".json"), *flagRecord, apiKey)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	t.Cleanup(func() {
+		if *flagRecord && t.Failed() {
+			return // keep the previous cassette
+		}
+		if err := rec.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	opts = append(opts, genai.WithHTTPClient(rec.Client()), genai.WithStateless())
+	c, err := NewClient(baseURL, apiKey, model, "", "", opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func readSample(t *testing.T, c *Client, name string) *genai.GasMeterReadResult {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "..", "..", "sample", name))
+	if err != nil {
+		t.Fatalf("open sample: %v", err)
+	}
+	defer f.Close()
+	res, err := c.ReadGasGaugePic(context.Background(), f)
+	if err != nil {
+		t.Fatalf("ReadGasGaugePic: %v", err)
+	}
+	return genaitest.NormalizeTiming(res)
+}
+
+func TestReplayRead(t *testing.T) {
+	t.Parallel()
+
+	res := readSample(t, replayClient(t, "read_ok"), "ok.jpg")
+	if res.Read != "02924.744" || res.Date != "2025-11-07T05:23:24+09:00" || res.Ambiguous {
+		t.Fatalf("result = %+v", res)
+	}
+	if !res.ReadAt.IsZero() || res.ItTakes != "" {
+		t.Fatalf("timing fields not normalized: %+v", res)
+	}
+}
+
+// TestReplayAmbiguous covers the two-call flow: a reading with "?" followed
+// by the disambiguation request.
+func TestReplayAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	res := readSample(t, replayClient(t, "read_ambiguous"), "ambiguous_digit_ok.jpg")
+	if res.Read != "02925.945" || !res.Ambiguous {
+		t.Fatalf("result = %+v", res)
+	}
+}
@@ -0,0 +1,226 @@
+package genai
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options holds settings shared by all [VisionClient] backends.
+type Options struct {
+	Meter    Meter
+	Debug    bool
+	Examples []Example
+	Locale   string
+	Limiter  *Limiter
+	// Stateless clients neither use nor update the previous reading.
+	Stateless bool
+	// Seed and SeedStore set the previous reading a client starts from; see [ResolveSeed].
+	Seed      Seed
+	SeedStore LatestReader
+	// ResponseSchema passes [Options.ResponseJSONSchema] to backends that support it.
+	ResponseSchema bool
+	// SerialCheck rejects readings of another meter; see [WithSerialCheck].
+	SerialCheck bool
+	// ReadSerial asks for the serial number without Meter.Serial; see [WithReadSerial].
+	ReadSerial bool
+	Auditor    Auditor
+	// MaxImageSize is the largest accepted image in bytes; see [WithMaxImageSize].
+	MaxImageSize int64
+	// AsyncCleanup deletes uploaded images without waiting; see [WithAsyncCleanup].
+	AsyncCleanup bool
+	// UploadPrefix starts the display name of every uploaded file; see [WithUploadPrefix].
+	UploadPrefix string
+	// HTTPClient replaces the backend's default HTTP client when non-nil.
+	HTTPClient *http.Client
+	// Transport, when non-nil, sends the requests of the backend's default
+	// HTTP client, e.g. through a proxy.
+	Transport http.RoundTripper
+	Clock     Clock
+	// Location is the time zone of [GasMeterReadResult.DateParsed]; see [WithLocation].
+	Location *time.Location
+	// SlowThreshold is the duration from which readings are logged; see [WithSlowThreshold].
+	SlowThreshold time.Duration
+	// SingleShot resolves uncertain digits in the reading call; see [WithSingleShot].
+	SingleShot bool
+	// CounterBox asks for the counter's bounding box; see [WithCounterBox].
+	CounterBox bool
+	// SelfVerify asks the model to re-examine its reading; see [WithSelfVerify].
+	SelfVerify bool
+	// ContextCacheTTL keeps the static prompts in the backend's cache; see
+	// [WithContextCache].
+	ContextCacheTTL time.Duration
+	// Retries and FallbackModels retry failed reading calls; see [WithRetries].
+	Retries        int
+	FallbackModels []string
+	// UploadRetries and UploadTimeout retry failed image uploads; see
+	// [WithUploadRetries].
+	UploadRetries int
+	UploadTimeout time.Duration
+	// Timeouts bound every reading and its phases; see [WithTimeouts].
+	Timeouts Timeouts
+	// Generation bounds what the models generate; see [WithGeneration].
+	Generation Generation
+	// Ensemble lists the models to cross-check with; see [WithEnsemble].
+	Ensemble  []string
+	Agreement AgreementPolicy
+	// TracerProvider records the pipeline's spans; see [WithTracerProvider].
+	TracerProvider trace.TracerProvider
+}
+
+// Option configures [Options].
+type Option func(*Options)
+
+// WithMeter sets the meter layout exposed to the image prompt template.
+// Zero fields fall back to the defaults of the meter's utility (see
+// [LookupUtility]); an unknown utility is reported by [NewPrompts].
+func WithMeter(m Meter) Option {
+	return func(o *Options) {
+		if m.Utility == "" {
+			m.Utility = UtilityGas
+		}
+		d, err := LookupUtility(m.Utility)
+		if err != nil {
+			d = utilityDefaults[UtilityGas]
+		}
+		if m.IntDigits == 0 && m.FracDigits == 0 {
+			m.IntDigits, m.FracDigits = d.IntDigits, d.FracDigits
+		}
+		if m.Unit == "" {
+			m.Unit = d.Unit
+		}
+		if m.Type == "" {
+			m.Type = MeterCounter
+		}
+		o.Meter = m
+	}
+}
+
+// WithDebug enables debug logging (rendered prompts, raw model output).
+func WithDebug(on bool) Option {
+	return func(o *Options) {
+		o.Debug = on
+	}
+}
+
+// WithLocale selects the built-in prompt set and date layouts ("en", "ko").
+// Custom prompts passed to a client constructor still take precedence.
+func WithLocale(locale string) Option {
+	return func(o *Options) {
+		o.Locale = locale
+	}
+}
+
+// WithRateLimiter makes the client wait on l before every API call.
+func WithRateLimiter(l *Limiter) Option {
+	return func(o *Options) {
+		o.Limiter = l
+	}
+}
+
+// WithStateless stops the client from using or updating its previous reading,
+// e.g. for comparisons and tests that must not influence regular reads.
+func WithStateless() Option {
+	return func(o *Options) {
+		o.Stateless = true
+	}
+}
+
+// WithResponseSchema enables (default) or disables sending the explicit
+// response schema, for backends that reject structured-output requests.
+func WithResponseSchema(on bool) Option {
+	return func(o *Options) {
+		o.ResponseSchema = on
+	}
+}
+
+// WithSerialCheck enables (default) or disables rejecting readings whose
+// serial number does not match [Meter.Serial] with [ErrWrongMeter], e.g.
+// when the daemon's acceptance pipeline checks the serial instead.
+func WithSerialCheck(on bool) Option {
+	return func(o *Options) {
+		o.SerialCheck = on
+	}
+}
+
+// WithHTTPClient makes the client send API requests through hc, e.g. for a
+// proxy or a record/replay transport in tests. The Gemini backend uses it for
+// Files API calls only.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = hc
+	}
+}
+
+// WithTransport makes the default HTTP client of the backend, and that
+// fetching images by URL, send requests through rt, e.g. a transport of
+// egress.Config; [WithHTTPClient] takes precedence.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *Options) {
+		o.Transport = rt
+	}
+}
+
+// Client returns the HTTP client of o: HTTPClient if set, else a client of
+// Transport, else nil for the default of the backend.
+func (o *Options) Client() *http.Client {
+	switch {
+	case o.HTTPClient != nil:
+		return o.HTTPClient
+	case o.Transport != nil:
+		return &http.Client{Transport: o.Transport}
+	}
+	return nil
+}
+
+// DefaultUploadPrefix is the default of [WithUploadPrefix].
+const DefaultUploadPrefix = "gas-meter"
+
+// WithUploadPrefix makes the Gemini backend name uploads
+// "{prefix}/{meter ID}/{capture time}" (examples "{prefix}/{meter ID}/example-{n}")
+// and limits orphan collection to files under "{prefix}/". Clients sharing an
+// API key should use distinct prefixes.
+func WithUploadPrefix(prefix string) Option {
+	return func(o *Options) {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			o.UploadPrefix = prefix
+		}
+	}
+}
+
+// WithLocation sets the time zone dates without a UTC offset are read in and
+// [GasMeterReadResult.DateParsed] is given in (default time.Local).
+func WithLocation(loc *time.Location) Option {
+	return func(o *Options) {
+		if loc != nil {
+			o.Location = loc
+		}
+	}
+}
+
+// WithAsyncCleanup makes the Gemini backend delete uploaded images in the
+// background instead of before ReadGasGaugePic returns. Close waits for them.
+func WithAsyncCleanup() Option {
+	return func(o *Options) {
+		o.AsyncCleanup = true
+	}
+}
+
+// NewOptions applies opts over the defaults.
+func NewOptions(opts ...Option) Options {
+	o := Options{Meter: DefaultMeter, Locale: DefaultLocale, ResponseSchema: true, SerialCheck: true, Clock: RealClock, Location: time.Local, UploadPrefix: DefaultUploadPrefix}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Debugf logs only when debug logging is enabled.
+func (o *Options) Debugf(format string, args ...any) {
+	if o.Debug {
+		log.Printf("debug: "+format, args...)
+	}
+}
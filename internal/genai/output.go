@@ -0,0 +1,201 @@
+package genai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidModelOutput is matched (via errors.Is) by [*InvalidOutputError].
+var ErrInvalidModelOutput = errors.New("invalid model output")
+
+// InvalidOutputError reports model output that does not match the reading
+// schema even after the repair pass. Raw holds the full model text.
+type InvalidOutputError struct {
+	Raw string
+	Err error
+}
+
+func (e *InvalidOutputError) Error() string {
+	return fmt.Sprintf("%v: %v; raw: %s", ErrInvalidModelOutput, e.Err, Truncate(e.Raw, 300))
+}
+
+func (e *InvalidOutputError) Unwrap() error { return e.Err }
+
+func (e *InvalidOutputError) Is(target error) bool { return target == ErrInvalidModelOutput }
+
+// ErrEmptyReading is returned for a model answer without a reading, most
+// often because the output was cut off; see [CheckOutput].
+var ErrEmptyReading = errors.New("empty reading")
+
+// ErrTruncatedOutput is returned for model output cut off by the token limit.
+var ErrTruncatedOutput = errors.New("model output truncated")
+
+// FinishLength is the finish reason of output cut off by the token limit, in
+// both OpenAI-compatible APIs and Genkit.
+const FinishLength = "length"
+
+// CheckOutput vets a model answer before any post-processing. out and err are
+// the result of [ParseReadResult], finishReason is why the backend ended the
+// output. Truncated output is an error wrapping [ErrTruncatedOutput] (and err,
+// if parsing failed), and an empty answer or one without a reading, such as
+// a reading of only "?", is one wrapping [ErrEmptyReading]; both name the
+// finish reason. Other errors are
+// returned as is. A reading of a dials meter is its raw dial values.
+func CheckOutput(m Meter, out *GasMeterReadResult, finishReason string, err error) error {
+	if finishReason == FinishLength {
+		if err != nil {
+			return fmt.Errorf("%w (finish reason %q): %w", ErrTruncatedOutput, finishReason, err)
+		}
+		return fmt.Errorf("%w (finish reason %q)", ErrTruncatedOutput, finishReason)
+	}
+	if err != nil {
+		var ioe *InvalidOutputError
+		if !errors.As(err, &ioe) || strings.TrimSpace(ioe.Raw) != "" {
+			return err
+		}
+	} else if hasReading(m, out) {
+		return nil
+	}
+	if finishReason == "" {
+		return ErrEmptyReading
+	}
+	return fmt.Errorf("%w (finish reason %q)", ErrEmptyReading, finishReason)
+}
+
+func hasReading(m Meter, out *GasMeterReadResult) bool {
+	if out == nil {
+		return false
+	}
+	if m.Type == MeterDials {
+		return len(out.Dials) > 0
+	}
+	// Not a single digit could be made out: there is nothing to guess from.
+	return strings.ContainsFunc(out.Read, func(r rune) bool {
+		return r != '?' && r != '.' && r != ',' && !unicode.IsSpace(r)
+	})
+}
+
+// ReadResultJSONSchema is the JSON schema of the model's answer, passed to
+// backends that support structured output.
+var ReadResultJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"read":        map[string]any{"type": "string"},
+		"confidences": confidencesJSONSchema,
+		"date":        map[string]any{"type": "string"},
+		"issue":       issueJSONSchema,
+	},
+	"required":             []string{"read", "confidences", "date", "issue"},
+	"additionalProperties": false,
+}
+
+// confidencesJSONSchema is the schema of the model's confidences in the
+// digits of its reading; see [GasMeterReadResult.Confidences].
+var confidencesJSONSchema = map[string]any{"type": "array", "items": map[string]any{"type": "number"}}
+
+// CleanConfidences returns conf clamped to [0, 1] if it has one confidence
+// for each digit and "?" of read, and nil otherwise: confidences that do not
+// line up with the digits cannot be told apart.
+func CleanConfidences(read string, conf []float64) []float64 {
+	n := 0
+	for _, r := range read {
+		if unicode.IsDigit(r) || r == '?' {
+			n++
+		}
+	}
+	if len(conf) == 0 || len(conf) != n {
+		return nil
+	}
+	out := make([]float64, len(conf))
+	for i, c := range conf {
+		out[i] = min(max(c, 0), 1)
+	}
+	return out
+}
+
+// withProperty returns a copy of the object schema with the required
+// property name added.
+func withProperty(schema map[string]any, name string, prop map[string]any) map[string]any {
+	out := maps.Clone(schema)
+	props := maps.Clone(schema["properties"].(map[string]any))
+	props[name] = prop
+	out["properties"] = props
+	out["required"] = append(slices.Clone(schema["required"].([]string)), name)
+	return out
+}
+
+// ParseReadResult decodes the model's answer. Strict JSON is tried first; if
+// that fails, a repair pass strips markdown fences and surrounding prose and
+// decodes the first JSON object. Failures are [*InvalidOutputError].
+func ParseReadResult(text string) (*GasMeterReadResult, error) {
+	var out GasMeterReadResult
+	strictErr := json.Unmarshal([]byte(strings.TrimSpace(text)), &out)
+	if strictErr == nil {
+		return &out, nil
+	}
+
+	jsonStr := ExtractJSONObject(text)
+	if jsonStr == "" {
+		return nil, &InvalidOutputError{Raw: text, Err: fmt.Errorf("no JSON object: %w", strictErr)}
+	}
+	out = GasMeterReadResult{}
+	if err := json.Unmarshal([]byte(jsonStr), &out); err != nil {
+		return nil, &InvalidOutputError{Raw: text, Err: err}
+	}
+	return &out, nil
+}
+
+// ExtractJSONObject returns the first balanced {...} in s after stripping a
+// markdown code fence, or "" if there is none.
+func ExtractJSONObject(s string) string {
+	s = strings.TrimSpace(s)
+	s = stripMarkdownFence(s)
+
+	start := strings.Index(s, "{")
+	if start == -1 {
+		return ""
+	}
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[start : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+func stripMarkdownFence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSpace(s)
+		if idx := strings.Index(s, "\n"); idx != -1 {
+			// optional language tag on first line
+			rest := s[idx+1:]
+			if end := strings.Index(rest, "```"); end != -1 {
+				return strings.TrimSpace(rest[:end])
+			}
+			return strings.TrimSpace(rest)
+		}
+	}
+	return s
+}
+
+// Truncate shortens s to max bytes for log and error messages.
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "…"
+}
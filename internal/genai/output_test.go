@@ -0,0 +1,103 @@
+package genai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtractJSONObject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain object", in: `{"read":"1.23","date":"x"}`, want: `{"read":"1.23","date":"x"}`},
+		{name: "prefixed text", in: `Here: {"read":"1"}`, want: `{"read":"1"}`},
+		{name: "markdown fence", in: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
+		{name: "no object", in: "no brace", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := ExtractJSONObject(tt.in)
+			if got != tt.want {
+				t.Fatalf("ExtractJSONObject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReadResult(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		in       string
+		wantRead string
+		wantErr  bool
+	}{
+		{name: "strict", in: `{"read":"123.4","date":"2024-01-01"}`, wantRead: "123.4"},
+		{name: "fenced", in: "```json\n{\"read\":\"02924.457\",\"date\":\"\"}\n```", wantRead: "02924.457"},
+		{name: "prefixed", in: `Sure! Here is the result: {"read":"02924.457","date":""} Hope it helps.`, wantRead: "02924.457"},
+		{name: "truncated", in: `{"read":"0292`, wantErr: true},
+		{name: "wrong type", in: `{"read":2924.457}`, wantErr: true},
+		{name: "no json", in: "no json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := ParseReadResult(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidModelOutput) {
+					t.Fatalf("ParseReadResult(%q) err = %v, want ErrInvalidModelOutput", tt.in, err)
+				}
+				var ioe *InvalidOutputError
+				if !errors.As(err, &ioe) || ioe.Raw != tt.in {
+					t.Fatalf("error does not carry the raw text: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReadResult(%q): %v", tt.in, err)
+			}
+			if res.Read != tt.wantRead {
+				t.Fatalf("Read = %q, want %q", res.Read, tt.wantRead)
+			}
+		})
+	}
+}
+
+func TestStripMarkdownFence(t *testing.T) {
+	t.Parallel()
+
+	in := "```json\n" + strings.TrimSpace(`{"x":1}`) + "\n```"
+	got := stripMarkdownFence(in)
+	if !strings.Contains(got, "{") {
+		t.Fatalf("stripMarkdownFence: %q", got)
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		read   string
+		finish string
+		empty  bool
+	}{
+		{"02924.457", "stop", false},
+		{"0292?.457", "stop", false},
+		{"", "stop", true},
+		{"?????.???", "stop", true},
+		{" ??? ?? ", "", true},
+	}
+	for _, tt := range tests {
+		err := CheckOutput(DefaultMeter, &GasMeterReadResult{Read: tt.read}, tt.finish, nil)
+		if errors.Is(err, ErrEmptyReading) != tt.empty {
+			t.Fatalf("CheckOutput(%q) = %v; want empty %t", tt.read, err, tt.empty)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by [ClientPool.Get] after [ClientPool.Close].
+var ErrPoolClosed = errors.New("client pool closed")
+
+// ClientFactory creates the client of an API key and model, e.g. with
+// googleai.NewClient.
+type ClientFactory func(ctx context.Context, apiKey, model string) (VisionClient, error)
+
+// PoolStats is a snapshot of a [ClientPool].
+type PoolStats struct {
+	Size    int   `json:"size"`
+	InUse   int   `json:"in_use"`
+	Creates int64 `json:"creates"`
+	// IdleEvictions and SizeEvictions count clients closed for being idle
+	// and for making room.
+	IdleEvictions int64 `json:"idle_evictions"`
+	SizeEvictions int64 `json:"size_evictions"`
+}
+
+// ClientPool lazily creates and caches clients by API key and model, for
+// serving several tenants without building a client per request. Clients
+// unused for the idle timeout are closed on the next Get or [ClientPool.EvictIdle];
+// at most maxSize clients are kept, closing the least recently used one to
+// make room. A client is only closed once every holder has released it.
+type ClientPool struct {
+	newClient ClientFactory
+	maxSize   int
+	idle      time.Duration
+	clock     Clock
+
+	mu      sync.Mutex
+	entries map[poolKey]*poolEntry
+	closed  bool
+	stats   PoolStats
+}
+
+type poolKey struct{ apiKey, model string }
+
+type poolEntry struct {
+	ready  chan struct{} // closed once client or err is set
+	client VisionClient
+	err    error
+
+	lastUsed time.Time
+	users    int
+	evicted  bool // closed when the last user releases it
+}
+
+// NewClientPool returns an empty pool. maxSize <= 0 means no bound and idle
+// <= 0 no idle eviction.
+func NewClientPool(newClient ClientFactory, maxSize int, idle time.Duration) *ClientPool {
+	return NewClientPoolWithClock(newClient, maxSize, idle, RealClock)
+}
+
+// NewClientPoolWithClock is like [NewClientPool] but measures idle time on c.
+func NewClientPoolWithClock(newClient ClientFactory, maxSize int, idle time.Duration, c Clock) *ClientPool {
+	return &ClientPool{newClient: newClient, maxSize: maxSize, idle: idle, clock: c, entries: make(map[poolKey]*poolEntry)}
+}
+
+// Get returns the client of apiKey and model, creating it if needed. It is
+// safe for concurrent use; concurrent Gets of a new key create one client.
+// Closing the returned client releases it to the pool instead of closing
+// it, and must be done once the caller is finished with it.
+func (p *ClientPool) Get(ctx context.Context, apiKey, model string) (VisionClient, error) {
+	key := poolKey{apiKey, model}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	toClose := p.evictIdleLocked()
+	e, ok := p.entries[key]
+	if !ok {
+		if p.maxSize > 0 && len(p.entries) >= p.maxSize {
+			toClose = append(toClose, p.evictOldestLocked()...)
+		}
+		e = &poolEntry{ready: make(chan struct{})}
+		p.entries[key] = e
+		p.stats.Creates++
+	}
+	e.users++
+	e.lastUsed = p.clock.Now()
+	p.mu.Unlock()
+	closeAll(toClose)
+
+	if !ok {
+		e.client, e.err = p.newClient(ctx, apiKey, model)
+		close(e.ready)
+	}
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		p.release(key, e)
+		return nil, ctx.Err()
+	}
+	if e.err != nil {
+		p.mu.Lock()
+		if p.entries[key] == e {
+			delete(p.entries, key)
+		}
+		e.users--
+		p.mu.Unlock()
+		return nil, e.err
+	}
+	return &pooledClient{VisionClient: e.client, pool: p, key: key, entry: e}, nil
+}
+
+// EvictIdle closes the clients that have been unused for the idle timeout
+// and returns how many there were. Get does this too; call it periodically
+// to release clients of tenants that stopped reading.
+func (p *ClientPool) EvictIdle() int {
+	p.mu.Lock()
+	toClose := p.evictIdleLocked()
+	p.mu.Unlock()
+	closeAll(toClose)
+	return len(toClose)
+}
+
+// Stats returns the current size and counters of the pool.
+func (p *ClientPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Size = len(p.entries)
+	for _, e := range p.entries {
+		if e.users > 0 {
+			s.InUse++
+		}
+	}
+	return s
+}
+
+// Close closes every client that is not in use and the others once they are
+// released. Get fails afterwards.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	var toClose []io.Closer
+	for key, e := range p.entries {
+		if c := p.removeLocked(key, e); c != nil {
+			toClose = append(toClose, c)
+		}
+	}
+	p.mu.Unlock()
+	return errors.Join(closeAll(toClose)...)
+}
+
+// evictIdleLocked removes the idle entries and returns the clients to close.
+func (p *ClientPool) evictIdleLocked() []io.Closer {
+	if p.idle <= 0 {
+		return nil
+	}
+	var toClose []io.Closer
+	for key, e := range p.entries {
+		if e.users == 0 && Since(p.clock, e.lastUsed) >= p.idle {
+			if c := p.removeLocked(key, e); c != nil {
+				toClose = append(toClose, c)
+			}
+			p.stats.IdleEvictions++
+		}
+	}
+	return toClose
+}
+
+// evictOldestLocked removes the least recently used entry to make room.
+func (p *ClientPool) evictOldestLocked() []io.Closer {
+	var oldest poolKey
+	var oe *poolEntry
+	for key, e := range p.entries {
+		if oe == nil || e.lastUsed.Before(oe.lastUsed) {
+			oldest, oe = key, e
+		}
+	}
+	if oe == nil {
+		return nil
+	}
+	p.stats.SizeEvictions++
+	if c := p.removeLocked(oldest, oe); c != nil {
+		return []io.Closer{c}
+	}
+	return nil
+}
+
+// removeLocked drops e from the pool and returns its client if it can be
+// closed now; a client still in use is closed by its last release.
+func (p *ClientPool) removeLocked(key poolKey, e *poolEntry) io.Closer {
+	delete(p.entries, key)
+	if e.users > 0 {
+		e.evicted = true
+		return nil
+	}
+	return e.client
+}
+
+func (p *ClientPool) release(key poolKey, e *poolEntry) {
+	p.mu.Lock()
+	e.users--
+	e.lastUsed = p.clock.Now()
+	closeNow := e.evicted && e.users == 0
+	p.mu.Unlock()
+	if closeNow {
+		<-e.ready
+		if e.client != nil {
+			e.client.Close()
+		}
+	}
+}
+
+// closeAll closes cs and returns their errors.
+func closeAll(cs []io.Closer) []error {
+	var errs []error
+	for _, c := range cs {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// pooledClient is a client handed out by a [ClientPool]; Close releases it.
+type pooledClient struct {
+	VisionClient
+	pool  *ClientPool
+	key   poolKey
+	entry *poolEntry
+	once  sync.Once
+}
+
+func (c *pooledClient) Close() error {
+	c.once.Do(func() { c.pool.release(c.key, c.entry) })
+	return nil
+}
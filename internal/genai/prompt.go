@@ -0,0 +1,117 @@
+package genai
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Meter describes the counter layout of a physical meter. It is exposed to
+// image prompt templates so one template can serve meters of different shapes.
+type Meter struct {
+	ID         string
+	Utility    string // [UtilityGas] (default), [UtilityWater] or [UtilityElectricity]
+	Type       string // [MeterCounter] (default) or [MeterDials]
+	IntDigits  int
+	FracDigits int
+	Unit       string
+	// Serial is the serial number printed on the meter. When set, the model
+	// reads it along with the reading and readings of another meter are
+	// rejected; see [CheckSerial].
+	Serial         string
+	SerialMinMatch int // see [CheckSerial]
+	// Normalize cleans up readings before they are checked.
+	Normalize Normalization
+}
+
+// DefaultMeter is the 5+3 digit m³ gas counter the built-in prompts were written for.
+var DefaultMeter = Meter{Utility: UtilityGas, IntDigits: 5, FracDigits: 3, Unit: "m³"}
+
+// Pattern returns the reading format with N per digit, e.g. "NNNNN.NNN".
+func (m Meter) Pattern() string {
+	p := strings.Repeat("N", m.IntDigits)
+	if m.FracDigits > 0 {
+		p += "." + strings.Repeat("N", m.FracDigits)
+	}
+	return p
+}
+
+// PromptData is the value an image prompt template is executed with.
+type PromptData struct {
+	MeterID string
+	Utility string // see [Meter.Utility]
+	// MeterName and MeterHint are the locale's name of the kind of meter,
+	// e.g. "water meter", and utility-specific reading advice (may be empty).
+	// They are only set by [Prompts.Data].
+	MeterName  string
+	MeterHint  string
+	IntDigits  int
+	FracDigits int
+	Unit       string
+	Digits     int    // IntDigits + FracDigits, i.e. the number of dials in dials mode
+	Pattern    string // see [Meter.Pattern]
+	PrevRead   string // empty when there is no previous reading
+	// Serial asks for the serial number printed on the meter; set when
+	// [Meter.Serial] is.
+	Serial bool
+	// CounterBox asks for the bounding box of the counter; set in the system
+	// prompt by [WithCounterBox].
+	CounterBox bool
+}
+
+// NewPromptData combines the meter layout and the previous reading into [PromptData].
+func NewPromptData(m Meter, prevRead string) PromptData {
+	return PromptData{
+		MeterID:    m.ID,
+		Utility:    m.Utility,
+		IntDigits:  m.IntDigits,
+		FracDigits: m.FracDigits,
+		Unit:       m.Unit,
+		Digits:     m.IntDigits + m.FracDigits,
+		Pattern:    m.Pattern(),
+		PrevRead:   prevRead,
+		Serial:     m.Serial != "",
+	}
+}
+
+// samplePromptData is used for the dry render in [ParsePromptTemplate].
+var samplePromptData = PromptData{
+	MeterID:    "sample",
+	Utility:    UtilityGas,
+	MeterName:  "gas meter",
+	IntDigits:  5,
+	FracDigits: 3,
+	Unit:       "m³",
+	Digits:     8,
+	Pattern:    "NNNNN.NNN",
+	PrevRead:   "01234.567",
+}
+
+// PromptTemplate is a parsed text/template for the per-call image prompt.
+type PromptTemplate struct {
+	tmpl *template.Template
+}
+
+// ParsePromptTemplate parses text as a text/template and dry-renders it with
+// sample data, so unknown fields and execution errors surface at config time
+// rather than on the first reading.
+func ParsePromptTemplate(text string) (*PromptTemplate, error) {
+	t, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template: %w", err)
+	}
+	p := &PromptTemplate{tmpl: t}
+	if _, err := p.Render(samplePromptData); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Render executes the template with d.
+func (p *PromptTemplate) Render(d PromptData) (string, error) {
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, d); err != nil {
+		return "", fmt.Errorf("render prompt template: %w", err)
+	}
+	return sb.String(), nil
+}
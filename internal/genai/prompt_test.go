@@ -0,0 +1,52 @@
+package genai
+
+import "testing"
+
+func TestParsePromptTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "plain text", text: "Process the image."},
+		{name: "meter fields", text: "{{.IntDigits}}+{{.FracDigits}} {{.Unit}} {{.MeterID}} {{.PrevRead}}"},
+		{name: "syntax error", text: "{{.IntDigits", wantErr: true},
+		{name: "unknown field", text: "{{.NoSuchField}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParsePromptTemplate(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePromptTemplate(%q) err = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPromptTemplateRender(t *testing.T) {
+	t.Parallel()
+
+	p, err := ParsePromptTemplate("{{.IntDigits}}.{{.FracDigits}} {{.Unit}}{{if .PrevRead}} prev={{.PrevRead}}{{end}}")
+	if err != nil {
+		t.Fatalf("ParsePromptTemplate: %v", err)
+	}
+
+	got, err := p.Render(NewPromptData(Meter{IntDigits: 6, FracDigits: 2, Unit: "m3"}, ""))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "6.2 m3"; got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+
+	got, err = p.Render(NewPromptData(DefaultMeter, "01234.567"))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "5.3 m³ prev=01234.567"; got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package genai
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Provenances of a reading: how its digits were arrived at. See
+// [GasMeterReadResult.Provenance].
+const (
+	ProvenanceConfident = "confident" // read with no uncertain digit
+	ProvenanceHeuristic = "heuristic" // resolved locally against the reference, e.g. [ResolveDials]
+	ProvenanceGuessed   = "guessed"   // uncertain digits completed by the model
+	ProvenanceConfirmed = "confirmed" // corrected by hand to the value it had
+	ProvenanceCorrected = "corrected" // corrected by hand to another value
+	ProvenanceEntered   = "entered"   // entered by hand, as the readings of a meter exchange
+)
+
+// Trusted reports whether a reading of provenance p can be the reference
+// of the next ones without carrying a guess forward. Readings stored before
+// the provenance was recorded, and explicit seeds, have none and count as
+// trusted.
+func Trusted(p string) bool {
+	return p != ProvenanceHeuristic && p != ProvenanceGuessed
+}
+
+// SetProvenance sets the provenance of r, a reading just made against ref,
+// from how its digits were resolved unless a step set it already, and its
+// ReferenceID to the ID of ref.
+func (r *GasMeterReadResult) SetProvenance(ref Seed) {
+	if r.Provenance == "" {
+		r.Provenance = ProvenanceConfident
+		if r.Ambiguous {
+			r.Provenance = ProvenanceGuessed
+		}
+	}
+	r.ReferenceID = ref.ID
+}
+
+// ProvenanceReader is implemented by stores that can walk the references
+// of a reading back; see store.Provenancer.
+type ProvenanceReader interface {
+	// Provenance returns the reading of meterID with ID readingID followed
+	// by its reference, its reference's reference and so on.
+	Provenance(ctx context.Context, meterID, readingID string) ([]*GasMeterReadResult, error)
+}
+
+// seedOf returns r, a reading of meterID, as a seed from source.
+func seedOf(meterID string, r *GasMeterReadResult, source string) Seed {
+	return Seed{Read: r.Read, At: r.ReadAt, Source: source, ID: cmp.Or(r.ID, ReadingID(meterID, r)), Provenance: r.Provenance}
+}
+
+// trustedAncestor returns the latest trusted reading in the chain of
+// references of seed, found through s, or nil.
+func trustedAncestor(ctx context.Context, s ProvenanceReader, m Meter, seed Seed) *Seed {
+	chain, err := s.Provenance(ctx, m.ID, seed.ID)
+	if err != nil {
+		log.Printf("Not loading the reference of the seed: %v", err)
+		return nil
+	}
+	for _, r := range chain {
+		if Trusted(r.Provenance) && CheckSeed(m, r.Read) == nil {
+			ref := seedOf(m.ID, r, SeedStore)
+			return &ref
+		}
+	}
+	return nil
+}
+
+// maxReferences is how many readings [References] keeps.
+const maxReferences = 100
+
+// References are the recent readings of a client, oldest first, from which
+// it takes the reference of the next: the most recent trusted one, so that
+// a guess is not the reference of the next guess and errors do not
+// cascade, else the latest.
+type References struct {
+	mu     sync.Mutex
+	seed   Seed
+	recent []Seed
+}
+
+// NewReferences returns the references of a client starting from seed,
+// and from its [Seed.Reference] if it is not trusted.
+func NewReferences(seed Seed) *References {
+	rs := &References{seed: seed}
+	if seed.Reference != nil {
+		rs.recent = append(rs.recent, *seed.Reference)
+	}
+	if seed.Source != SeedNone {
+		rs.recent = append(rs.recent, seed)
+	}
+	return rs
+}
+
+// Reference returns the reference of the next reading, or a zero Seed.
+func (rs *References) Reference() Seed {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for i := len(rs.recent) - 1; i >= 0; i-- {
+		if Trusted(rs.recent[i].Provenance) {
+			return rs.recent[i]
+		}
+	}
+	if len(rs.recent) > 0 {
+		return rs.recent[len(rs.recent)-1]
+	}
+	return Seed{}
+}
+
+// Seed returns the seed the client started from or was last given.
+func (rs *References) Seed() Seed {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.seed
+}
+
+// Add records r, a reading of meterID the client made.
+func (rs *References) Add(meterID string, r *GasMeterReadResult) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.recent = append(rs.recent, seedOf(meterID, r, ""))
+	if n := len(rs.recent) - maxReferences; n > 0 {
+		rs.recent = rs.recent[n:]
+	}
+}
+
+// Reset replaces the readings with the explicit seed read, taken at at.
+func (rs *References) Reset(read string, at time.Time) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.seed = Seed{Read: read, At: at, Source: SeedExplicit}
+	rs.recent = []Seed{rs.seed}
+}
+
+// Rewind makes r, a reading of meterID, the latest: the readings after it
+// are forgotten, as after the rejection of the next, and r replaces its
+// earlier copy, as after a correction. A reading not among them is
+// recorded as given.
+func (rs *References) Rewind(meterID string, r *GasMeterReadResult) {
+	s := seedOf(meterID, r, SeedExplicit)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.seed = s
+	for i := len(rs.recent) - 1; i >= 0; i-- {
+		if rs.recent[i].ID == s.ID {
+			rs.recent = append(rs.recent[:i], s)
+			return
+		}
+	}
+	rs.recent = append(rs.recent, s)
+}
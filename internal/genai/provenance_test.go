@@ -0,0 +1,125 @@
+package genai
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+	reading := func(read, provenance string, h int) *GasMeterReadResult {
+		return &GasMeterReadResult{Read: read, ReadAt: at.Add(time.Duration(h) * time.Hour), Provenance: provenance}
+	}
+	rs := NewReferences(Seed{})
+	if ref := rs.Reference(); ref != (Seed{}) {
+		t.Fatalf("Reference without readings = %+v", ref)
+	}
+
+	confident := reading("02924.457", ProvenanceConfident, 1)
+	rs.Add("home", confident)
+	guessed := reading("02924.557", ProvenanceGuessed, 2)
+	rs.Add("home", guessed)
+	rs.Add("home", reading("02924.657", ProvenanceHeuristic, 3))
+	// The guesses are not the reference of the next reading.
+	if ref := rs.Reference(); ref.Read != confident.Read || ref.ID != ReadingID("home", confident) {
+		t.Fatalf("Reference = %+v, want the confident %s", ref, confident.Read)
+	}
+
+	// The next was rejected: back to guessed, and its correction makes it
+	// the reference.
+	rs.Rewind("home", guessed)
+	rs.Add("home", reading("02925.000", ProvenanceGuessed, 4))
+	rs.Rewind("home", guessed)
+	guessed.Correct("home", "02924.600", "read in person", at.Add(5*time.Hour))
+	rs.Rewind("home", guessed)
+	if ref := rs.Reference(); ref.Read != "02924.600" || ref.Provenance != ProvenanceCorrected || ref.ID != guessed.ID {
+		t.Fatalf("Reference after the correction = %+v", ref)
+	}
+	if seed := rs.Seed(); seed.Read != "02924.600" || seed.Source != SeedExplicit {
+		t.Fatalf("Seed = %+v", seed)
+	}
+
+	// Without a trusted reading the latest is the reference.
+	rs.Reset("02930.000", at)
+	if ref := rs.Reference(); ref.Read != "02930.000" || ref.Source != SeedExplicit {
+		t.Fatalf("Reference after Reset = %+v", ref)
+	}
+	rs = NewReferences(Seed{})
+	for i := range maxReferences + 1 {
+		rs.Add("home", reading("02930.000", ProvenanceGuessed, i))
+	}
+	if ref := rs.Reference(); ref.Read != "02930.000" || ref.Provenance != ProvenanceGuessed {
+		t.Fatalf("Reference of guesses = %+v", ref)
+	}
+	if n := len(rs.recent); n != maxReferences {
+		t.Fatalf("kept %d readings, want %d", n, maxReferences)
+	}
+}
+
+func TestSetProvenance(t *testing.T) {
+	t.Parallel()
+
+	ref := Seed{Read: "02924.457", ID: "ref"}
+	tests := []struct {
+		r    GasMeterReadResult
+		want string
+	}{
+		{GasMeterReadResult{Read: "02924.557"}, ProvenanceConfident},
+		{GasMeterReadResult{Read: "02924.557", Ambiguous: true}, ProvenanceGuessed},
+		{GasMeterReadResult{Read: "02924.557", Ambiguous: true, Provenance: ProvenanceHeuristic}, ProvenanceHeuristic},
+	}
+	for _, tt := range tests {
+		tt.r.SetProvenance(ref)
+		if tt.r.Provenance != tt.want || tt.r.ReferenceID != "ref" {
+			t.Errorf("SetProvenance: %q, reference %q; want %q", tt.r.Provenance, tt.r.ReferenceID, tt.want)
+		}
+	}
+
+	// Correcting a reading to its value confirms it.
+	r := &GasMeterReadResult{Read: "02924.557", Provenance: ProvenanceGuessed}
+	if r.Correct("home", "02924.557", "", time.Now()); r.Provenance != ProvenanceConfirmed {
+		t.Fatalf("Provenance = %q after confirming", r.Provenance)
+	}
+	if r.Correct("home", "02924.657", "", time.Now()); r.Provenance != ProvenanceCorrected {
+		t.Fatalf("Provenance = %q after correcting", r.Provenance)
+	}
+}
+
+// chainStore is a store of readings of one meter by ID.
+type chainStore map[string]*GasMeterReadResult
+
+func (s chainStore) Latest(context.Context, string) (*GasMeterReadResult, error) {
+	return s["latest"], nil
+}
+
+func (s chainStore) Provenance(_ context.Context, _, id string) ([]*GasMeterReadResult, error) {
+	var chain []*GasMeterReadResult
+	for r := s[id]; r != nil; r = s[r.ReferenceID] {
+		chain = append(chain, r)
+	}
+	return chain, nil
+}
+
+func TestResolveSeedReference(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+	s := chainStore{
+		"latest": {ID: "latest", Read: "02924.657", ReadAt: at.Add(2 * time.Hour), Provenance: ProvenanceGuessed, ReferenceID: "middle"},
+		"middle": {ID: "middle", Read: "02924.557", ReadAt: at.Add(time.Hour), Provenance: ProvenanceHeuristic, ReferenceID: "first"},
+		"first":  {ID: "first", Read: "02924.457", ReadAt: at, Provenance: ProvenanceConfident},
+	}
+	seed, err := ResolveSeed(context.Background(), NewOptions(WithMeter(Meter{ID: "home"}), WithSeedStore(s)))
+	if err != nil {
+		t.Fatalf("ResolveSeed: %v", err)
+	}
+	if seed.ID != "latest" || seed.Reference == nil || seed.Reference.ID != "first" {
+		t.Fatalf("seed = %+v, reference %+v", seed, seed.Reference)
+	}
+	if ref := NewReferences(seed).Reference(); ref.Read != "02924.457" {
+		t.Fatalf("Reference = %+v, want the confident first reading", ref)
+	}
+}
@@ -0,0 +1,54 @@
+package genai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces API calls at least interval apart. One Limiter may be shared
+// by several clients so the limit applies across all of them.
+type Limiter struct {
+	interval time.Duration
+	clock    Clock
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewLimiter returns a Limiter allowing one call per interval.
+func NewLimiter(interval time.Duration) *Limiter {
+	return NewLimiterWithClock(interval, RealClock)
+}
+
+// NewLimiterWithClock is like [NewLimiter] but measures interval on c.
+func NewLimiterWithClock(interval time.Duration, c Clock) *Limiter {
+	return &Limiter{interval: interval, clock: c}
+}
+
+// Wait blocks until the next call is allowed or ctx is done. A nil Limiter never blocks.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := at.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.clock.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package genai
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseRead validates s against m's [Meter.Pattern] and returns its value.
+// Only ASCII digits are accepted, so look-alike runes from the model (e.g.
+// full-width digits) are rejected rather than misread.
+func ParseRead(m Meter, s string) (float64, error) {
+	if p := m.Pattern(); !fitsPattern(p, s, false) {
+		return 0, fmt.Errorf("reading %q does not match %s", Truncate(s, 40), p)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// CheckRead checks that s is a reading of m that may still have uncertain
+// digits: it has the length and decimal point of m's [Meter.Pattern], and an
+// ASCII digit or "?" for each of its digits.
+func (m Meter) CheckRead(s string) error {
+	if p := m.Pattern(); !fitsPattern(p, s, true) {
+		return fmt.Errorf("reading %q does not match %s", Truncate(s, 40), p)
+	}
+	return nil
+}
+
+// fitsPattern reports whether s matches the pattern p, with "?" for a digit
+// if uncertain.
+func fitsPattern(p, s string, uncertain bool) bool {
+	if len(s) != len(p) {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		switch c := s[i]; {
+		case p[i] == '.':
+			if c != '.' {
+				return false
+			}
+		case c == '?':
+			if !uncertain {
+				return false
+			}
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return true
+}
+
+// FormatRead formats v in m's [Meter.Pattern], e.g. 2924.457 → "02924.457".
+func (m Meter) FormatRead(v float64) string {
+	if m.FracDigits == 0 {
+		return fmt.Sprintf("%0*.0f", m.IntDigits, v)
+	}
+	return fmt.Sprintf("%0*.*f", m.IntDigits+m.FracDigits+1, m.FracDigits, v)
+}
+
+// Delta returns the consumption from prev to cur, allowing for the counter
+// rolling over from all nines back to zero. ok is false for any other
+// decrease, e.g. a misread.
+func (m Meter) Delta(prev, cur float64) (delta float64, ok bool) {
+	if cur >= prev {
+		return cur - prev, true
+	}
+	max := math.Pow10(m.IntDigits)
+	if prev >= 0.9*max && cur < 0.1*max {
+		return cur + max - prev, true
+	}
+	return 0, false
+}
+
+// DeltaTo returns the consumption from prev up to r, which reads cur, as
+// [Meter.Delta] does; up to the first reading after an exchange of the meter
+// it is what the new meter counted since its start, whatever prev was.
+func (m Meter) DeltaTo(prev float64, r *GasMeterReadResult, cur float64) (delta float64, ok bool) {
+	if r.MeterExchanged == nil {
+		return m.Delta(prev, cur)
+	}
+	start, _ := ParseRead(m, r.MeterExchanged.Start) // empty or checked when set
+	if cur < start {
+		return 0, false
+	}
+	return cur - start, true
+}
+
+// Wrap returns v as the counter shows it, rolled over past all nines.
+func (m Meter) Wrap(v float64) float64 {
+	return math.Mod(v, math.Pow10(m.IntDigits))
+}
+
+// SanitizeGuess extracts the completed reading from a disambiguation answer
+// for ambiguous, a reading of m (see [Meter.CheckRead]). Models tend to wrap
+// the number in prose, quotes or code fences, so the first run of digits and
+// dots that fits ambiguous is taken: it has the same length, and agrees with
+// every digit of ambiguous that is not "?".
+func SanitizeGuess(m Meter, ambiguous, guess string) (string, error) {
+	if err := m.CheckRead(ambiguous); err != nil {
+		return "", fmt.Errorf("ambiguous value: %w", err)
+	}
+	for _, run := range strings.FieldsFunc(guess, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}) {
+		// A sentence may end right after the number.
+		for _, cand := range []string{run, strings.TrimRight(run, ".")} {
+			if fitsAmbiguous(ambiguous, cand) {
+				return cand, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("guess %q does not complete %q", Truncate(guess, 100), ambiguous)
+}
+
+func fitsAmbiguous(ambiguous, s string) bool {
+	if len(s) != len(ambiguous) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch a := ambiguous[i]; {
+		case a == '?':
+			if s[i] < '0' || s[i] > '9' {
+				return false
+			}
+		case s[i] != a:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package genai
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRead(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		m    Meter
+		s    string
+		want float64
+		ok   bool
+	}{
+		{DefaultMeter, "02924.457", 2924.457, true},
+		{DefaultMeter, "2924.457", 0, false},
+		{DefaultMeter, "02924,457", 0, false},
+		{DefaultMeter, "0292４.457", 0, false},
+		{DefaultMeter, "0292?.457", 0, false},
+		{Meter{IntDigits: 4}, "0123", 123, true},
+		{Meter{IntDigits: 4}, "012.", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRead(tt.m, tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Fatalf("ParseRead(%q) = %v, %v; want %v, ok %v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMeterDelta(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prev, cur float64
+		want      float64
+		ok        bool
+	}{
+		{2924.457, 2925.000, 0.543, true},
+		{2924.457, 2924.457, 0, true},
+		{99999.5, 0.25, 0.75, true}, // rollover
+		{2924.457, 2924.000, 0, false},
+		{50000, 1, 0, false}, // a drop this large is a misread, not a rollover
+	}
+	for _, tt := range tests {
+		got, ok := DefaultMeter.Delta(tt.prev, tt.cur)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Fatalf("Delta(%v, %v) = %v, %v; want %v, %v", tt.prev, tt.cur, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSanitizeGuess(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amb, guess, want string
+	}{
+		{"0292?.457", "02924.457", "02924.457"},
+		{"0292?.457", "  02924.457\n", "02924.457"},
+		{"0292?.457", "```\n02924.457\n```", "02924.457"},
+		{"0292?.457", `The most probable reading is "02924.457".`, "02924.457"},
+		{"0292?.457", "Previous 02923.999, so 02924.457.", "02924.457"},
+		{"0292?.457", "02925.458", ""},
+		{"0292?.457", "2924.457", ""},
+		{"0292?.457", "0292４.457", ""},
+		{"0292?.457", ".", ""},
+		{"0292?.457", "", ""},
+		{"292?.457", "2924.457", ""}, // not a reading of the meter
+	}
+	for _, tt := range tests {
+		got, err := SanitizeGuess(DefaultMeter, tt.amb, tt.guess)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Fatalf("SanitizeGuess(%q, %q) = %q, %v; want %q", tt.amb, tt.guess, got, err, tt.want)
+		}
+	}
+}
+
+func FuzzSanitizeGuess(f *testing.F) {
+	// Seeds include answers models have actually produced.
+	for _, s := range [][2]string{
+		{"0292?.457", "02924.457"},
+		{"0292?.457", "The most probable reading is 02924.457."},
+		{"0292?.457", "```json\n{\"read\": \"02924.457\"}\n```"},
+		{"0292?.45?", "0292?.457"},
+		{"?????.???", "."},
+		{"0292?.457", "0292４.457"},
+		{"0292?.457", strings.Repeat("9", 4096)},
+		{".", "."},
+		{"", ""},
+	} {
+		f.Add(s[0], s[1])
+	}
+	f.Fuzz(func(t *testing.T, amb, guess string) {
+		out, err := SanitizeGuess(DefaultMeter, amb, guess)
+		if err != nil {
+			return
+		}
+		if len(out) != len(amb) {
+			t.Fatalf("len(%q) = %d, want %d", out, len(out), len(amb))
+		}
+		if strings.Contains(out, "?") || !fitsAmbiguous(amb, out) {
+			t.Fatalf("%q does not complete %q", out, amb)
+		}
+		if !strings.Contains(guess, out) {
+			t.Fatalf("%q is not taken from %q", out, guess)
+		}
+	})
+}
+
+func FuzzParseRead(f *testing.F) {
+	for _, s := range []string{
+		"02924.457", "2924.457", "02924.4570", "0292?.457", ".", "", "-2924.457",
+		"0292４.457", "+2924.457", "0x3p4.457", "1e004.457", "02924.45\n",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		v, err := ParseRead(DefaultMeter, s)
+		if err != nil {
+			return
+		}
+		if got := DefaultMeter.FormatRead(v); got != s {
+			t.Fatalf("ParseRead(%q) = %v, formats back as %q", s, v, got)
+		}
+	})
+}
+
+func TestAsStale(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 5, 13, 17, 0, time.UTC)
+	r := &GasMeterReadResult{Read: "02924.457", ReadAt: at}
+	s := r.AsStale()
+	if r.Stale || !s.Stale || !s.StaleSince.Equal(at) || s.Read != r.Read {
+		t.Fatalf("AsStale = %+v from %+v", s, r)
+	}
+	// Repeating a stale reading keeps the original StaleSince.
+	s.ReadAt = at.Add(time.Hour)
+	if again := s.AsStale(); !again.StaleSince.Equal(at) {
+		t.Fatalf("StaleSince = %v, want %v", again.StaleSince, at)
+	}
+}
+
+func TestReadingID(t *testing.T) {
+	t.Parallel()
+
+	kst := time.FixedZone("KST", 9*60*60)
+	captured := time.Date(2025, 11, 7, 5, 13, 0, 0, kst)
+	r := &GasMeterReadResult{Read: "02924.457", DateParsed: captured, ReadAt: captured.Add(5 * time.Second)}
+	id := ReadingID("home", r)
+	if len(id) != 32 {
+		t.Fatalf("ReadingID = %q, want 32 hex chars", id)
+	}
+
+	// Reading the same capture again, later and in another zone, gives the same ID.
+	again := *r
+	again.ReadAt = r.ReadAt.Add(time.Minute)
+	again.DateParsed = captured.UTC()
+	if got := ReadingID("home", &again); got != id {
+		t.Fatalf("ReadingID of a re-read = %q, want %q", got, id)
+	}
+
+	for name, other := range map[string]func() (string, *GasMeterReadResult){
+		"meter": func() (string, *GasMeterReadResult) { return "cabin", r },
+		"read":  func() (string, *GasMeterReadResult) { o := *r; o.Read = "02924.458"; return "home", &o },
+		"capture": func() (string, *GasMeterReadResult) {
+			o := *r
+			o.DateParsed = captured.Add(time.Hour)
+			return "home", &o
+		},
+		"no date": func() (string, *GasMeterReadResult) { o := *r; o.DateParsed = time.Time{}; return "home", &o },
+	} {
+		if got := ReadingID(other()); got == id {
+			t.Fatalf("ReadingID with another %s = %q, same as the original", name, got)
+		}
+	}
+}
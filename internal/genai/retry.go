@@ -0,0 +1,81 @@
+package genai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// WithRetries retries a failed reading call up to n times within the same
+// reading, reusing its uploaded image. Retry i uses fallbackModels[i-1] if
+// there is one and the failed model otherwise; n is raised to the number of
+// fallback models. Readings whose context is done are not retried.
+func WithRetries(n int, fallbackModels ...string) Option {
+	return func(o *Options) {
+		o.Retries = max(n, len(fallbackModels))
+		o.FallbackModels = fallbackModels
+	}
+}
+
+// Retry calls read with model and retries it as set by [WithRetries],
+// returning the first success or the last error.
+func (o *Options) Retry(ctx context.Context, model string, read func(ctx context.Context, model string) (*GasMeterReadResult, error)) (*GasMeterReadResult, error) {
+	out, err := read(ctx, model)
+	for i := 0; err != nil && i < o.Retries && ctx.Err() == nil; i++ {
+		next := model
+		if i < len(o.FallbackModels) {
+			next = o.FallbackModels[i]
+		}
+		log.Printf("Reading with %s failed, retrying with %s (%d/%d): %v", model, next, i+1, o.Retries, err)
+		model = next
+		out, err = read(ctx, model)
+	}
+	return out, err
+}
+
+// WithUploadRetries retries a failed image upload up to n times, each
+// attempt bounded by attemptTimeout if positive, so that an upload stalled
+// mid-stream is cut off well before the deadline of the reading. The image
+// is then held in memory, up to [WithMaxImageSize], and uploaded again from
+// there: the source is read once, whatever the number of attempts.
+func WithUploadRetries(n int, attemptTimeout time.Duration) Option {
+	return func(o *Options) {
+		o.UploadRetries = n
+		o.UploadTimeout = attemptTimeout
+	}
+}
+
+// UploadAttempts are the attempts of one image upload, in [Timing] and
+// [Stats].
+type UploadAttempts struct {
+	Attempts int
+	// RetriedBytes is the size of the image times the attempts that failed.
+	RetriedBytes int64
+}
+
+// RetryUpload calls upload with img and retries it as set by
+// [WithUploadRetries], returning the attempts and the last error. Uploads
+// whose context is done are not retried.
+func (o *Options) RetryUpload(ctx context.Context, img []byte, upload func(ctx context.Context, r io.Reader) error) (UploadAttempts, error) {
+	var a UploadAttempts
+	for {
+		a.Attempts++
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if o.UploadTimeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, o.UploadTimeout)
+		}
+		err := upload(actx, bytes.NewReader(img))
+		if err != nil && actx.Err() != nil && ctx.Err() == nil {
+			err = fmt.Errorf("attempt timed out after %s: %w", o.UploadTimeout, err)
+		}
+		cancel()
+		if err == nil || a.Attempts > o.UploadRetries || ctx.Err() != nil {
+			return a, err
+		}
+		a.RetriedBytes += int64(len(img))
+		log.Printf("Uploading the image failed, retrying (%d/%d): %v", a.Attempts, o.UploadRetries, err)
+	}
+}
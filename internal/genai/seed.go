@@ -0,0 +1,109 @@
+package genai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Sources of a [Seed].
+const (
+	SeedNone     = ""         // no previous reading
+	SeedExplicit = "explicit" // [WithSeed] or [Seeder.SeedLastRead]
+	SeedStore    = "store"    // the latest stored reading, see [WithSeedStore]
+)
+
+// Seed is the previous reading a client starts from, so that the first
+// reading after a restart is disambiguated and checked like any other.
+type Seed struct {
+	Read   string
+	At     time.Time
+	Source string
+	// ID and Provenance are those of the reading, if it is one; explicit
+	// seeds have neither.
+	ID         string
+	Provenance string
+	// Reference is the latest trusted reading the seed was read against, if
+	// the seed is not [Trusted] and the store finds it; see [References].
+	Reference *Seed
+}
+
+// Seeder is implemented by clients that keep the previous reading.
+type Seeder interface {
+	// SeedLastRead replaces the previous reading with read, taken at at. It
+	// fails if read does not match the meter's [Meter.Pattern]; stateless
+	// clients ignore it.
+	SeedLastRead(read string, at time.Time) error
+	// SeedReading makes r the latest reading the client made, keeping its
+	// ID and provenance, as [References.Rewind] does: after the rejection of
+	// the next reading, or a correction of r. It fails as SeedLastRead does.
+	SeedReading(r *GasMeterReadResult) error
+	// Seed returns the seed the client started from or was last given.
+	Seed() Seed
+}
+
+// LatestReader returns the latest reading of a meter; every store.Store is one.
+type LatestReader interface {
+	Latest(ctx context.Context, meterID string) (*GasMeterReadResult, error)
+}
+
+// WithSeed makes the client start from read, taken at at, instead of the
+// latest stored reading.
+func WithSeed(read string, at time.Time) Option {
+	return func(o *Options) {
+		o.Seed = Seed{Read: read, At: at, Source: SeedExplicit}
+	}
+}
+
+// WithSeedStore makes the client start from the latest reading of its meter
+// in s unless [WithSeed] is given.
+func WithSeedStore(s LatestReader) Option {
+	return func(o *Options) {
+		o.SeedStore = s
+	}
+}
+
+// CheckSeed reports whether read can seed a client for m.
+func CheckSeed(m Meter, read string) error {
+	if _, err := ParseRead(m, read); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+	return nil
+}
+
+// ResolveSeed returns the seed of a client with o: the explicit seed, else
+// the latest stored reading, with its latest trusted reference if it is not
+// trusted and the store is a [ProvenanceReader], else none. An invalid
+// explicit seed is an
+// error; a store that fails or holds a reading in another format is logged
+// and skipped, so that a new or migrated meter still starts. Stateless
+// clients are never seeded.
+func ResolveSeed(ctx context.Context, o Options) (Seed, error) {
+	if o.Stateless {
+		return Seed{}, nil
+	}
+	if o.Seed.Source != SeedNone {
+		if err := CheckSeed(o.Meter, o.Seed.Read); err != nil {
+			return Seed{}, err
+		}
+		return o.Seed, nil
+	}
+	if o.SeedStore == nil {
+		return Seed{}, nil
+	}
+	r, err := o.SeedStore.Latest(ctx, o.Meter.ID)
+	if err != nil {
+		log.Printf("Not seeding from the store: %v", err)
+		return Seed{}, nil
+	}
+	if err := CheckSeed(o.Meter, r.Read); err != nil {
+		log.Printf("Not seeding from the store: %v", err)
+		return Seed{}, nil
+	}
+	seed := seedOf(o.Meter.ID, r, SeedStore)
+	if pr, ok := o.SeedStore.(ProvenanceReader); ok && !Trusted(seed.Provenance) {
+		seed.Reference = trustedAncestor(ctx, pr, o.Meter, seed)
+	}
+	return seed, nil
+}
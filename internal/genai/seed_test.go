@@ -0,0 +1,62 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type latestFunc func(ctx context.Context, meterID string) (*GasMeterReadResult, error)
+
+func (f latestFunc) Latest(ctx context.Context, meterID string) (*GasMeterReadResult, error) {
+	return f(ctx, meterID)
+}
+
+func TestResolveSeed(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+	stored := latestFunc(func(_ context.Context, meterID string) (*GasMeterReadResult, error) {
+		if meterID != "home" {
+			return nil, errors.New("store: no reading")
+		}
+		return &GasMeterReadResult{Read: "02924.457", ReadAt: at}, nil
+	})
+	failing := latestFunc(func(context.Context, string) (*GasMeterReadResult, error) {
+		return nil, errors.New("disk on fire")
+	})
+	otherLayout := latestFunc(func(context.Context, string) (*GasMeterReadResult, error) {
+		return &GasMeterReadResult{Read: "2924.45", ReadAt: at}, nil
+	})
+	home := WithMeter(Meter{ID: "home"})
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    Seed
+		wantErr bool
+	}{
+		{"none", []Option{home}, Seed{}, false},
+		{"store", []Option{home, WithSeedStore(stored)}, Seed{Read: "02924.457", At: at, Source: SeedStore}, false},
+		{"explicit over store", []Option{home, WithSeedStore(stored), WithSeed("02930.000", at.Add(time.Hour))},
+			Seed{Read: "02930.000", At: at.Add(time.Hour), Source: SeedExplicit}, false},
+		{"store of another meter", []Option{WithMeter(Meter{ID: "cellar"}), WithSeedStore(stored)}, Seed{}, false},
+		{"store error", []Option{home, WithSeedStore(failing)}, Seed{}, false},
+		{"store in another layout", []Option{home, WithSeedStore(otherLayout)}, Seed{}, false},
+		{"invalid explicit", []Option{home, WithSeed("2930", at)}, Seed{}, true},
+		{"stateless", []Option{home, WithSeedStore(stored), WithSeed("02930.000", at), WithStateless()}, Seed{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ResolveSeed(context.Background(), NewOptions(tt.opts...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Read != tt.want.Read || !got.At.Equal(tt.want.At) || got.Source != tt.want.Source {
+				t.Fatalf("seed = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package genai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrWrongMeter is returned for a reading whose serial number does not
+// match [Meter.Serial], or could not be read well enough to tell, e.g.
+// because the camera shifted to a neighbouring meter.
+var ErrWrongMeter = errors.New("wrong meter")
+
+// DefaultSerialMinMatch is the number of serial characters that must be
+// read and match [Meter.Serial] unless [Meter.SerialMinMatch] is set.
+const DefaultSerialMinMatch = 4
+
+// WithReadSerial asks the model for the serial number printed on the meter
+// even if [Meter.Serial] is not set, e.g. to find it out while setting up a
+// camera. Readings are only checked against a set Meter.Serial.
+func WithReadSerial() Option {
+	return func(o *Options) {
+		o.ReadSerial = true
+	}
+}
+
+// CheckSerial checks the serial number read from the image against
+// m.Serial, if set. Only letters and digits are compared, case-insensitively;
+// characters the model marked unreadable ("?") are skipped, and a serial
+// partly cut off or with extra characters around it is aligned with the
+// expected one. It fails with [ErrWrongMeter] unless, at some alignment,
+// every readable character matches and at least m.SerialMinMatch
+// (default [DefaultSerialMinMatch]) do.
+func CheckSerial(m Meter, read string) error {
+	want := normalizeSerial(m.Serial)
+	if want == "" {
+		return nil
+	}
+	need := m.SerialMinMatch
+	if need <= 0 {
+		need = DefaultSerialMinMatch
+	}
+	need = min(need, len(want))
+	got := normalizeSerial(read)
+	if readable := len(got) - strings.Count(got, "?"); readable < need {
+		return fmt.Errorf("%w: serial %q unreadable, %d characters read, need %d", ErrWrongMeter, read, readable, need)
+	}
+	if serialMatches(got, want) >= need {
+		return nil
+	}
+	return fmt.Errorf("%w: serial %q, want %s", ErrWrongMeter, read, m.Serial)
+}
+
+// serialMatches returns the most characters of got matching want at an
+// alignment where none differ, with the shorter of the two inside the longer.
+func serialMatches(got, want string) int {
+	short, long := got, want
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	best := 0
+	for off := 0; off+len(short) <= len(long); off++ {
+		matches := 0
+		for i := range len(short) {
+			a, b := short[i], long[off+i]
+			if a == '?' || b == '?' {
+				continue
+			}
+			if a != b {
+				matches = -1
+				break
+			}
+			matches++
+		}
+		best = max(best, matches)
+	}
+	return best
+}
+
+// normalizeSerial upper-cases s and keeps only letters, digits and "?".
+func normalizeSerial(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z', r == '?':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,69 @@
+package genai
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheckSerial(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		serial string
+		min    int
+		read   string
+		ok     bool
+	}{
+		{name: "not configured", read: "anything", ok: true},
+		{name: "exact", serial: "GM-2019-48213", read: "GM2019-48213", ok: true},
+		{name: "case and spacing", serial: "GM201948213", read: "gm 2019 48213", ok: true},
+		{name: "unreadable characters skipped", serial: "GM201948213", read: "GM20?9?8213", ok: true},
+		{name: "cut off", serial: "GM201948213", read: "48213", ok: true},
+		{name: "extra prefix", serial: "201948213", read: "No. 201948213", ok: true},
+		{name: "neighbour", serial: "GM201948213", read: "GM201951077"},
+		{name: "one wrong character", serial: "GM201948213", read: "GM2019?8214"},
+		{name: "too few readable", serial: "GM201948213", read: "??????4821?", min: 6},
+		{name: "not in view", serial: "GM201948213", read: ""},
+		{name: "min capped at serial length", serial: "482", read: "482", min: 6, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := CheckSerial(Meter{Serial: tt.serial, SerialMinMatch: tt.min}, tt.read)
+			if tt.ok && err != nil {
+				t.Fatalf("CheckSerial(%q, %q) = %v, want nil", tt.serial, tt.read, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrWrongMeter) {
+				t.Fatalf("CheckSerial(%q, %q) = %v, want ErrWrongMeter", tt.serial, tt.read, err)
+			}
+		})
+	}
+}
+
+func TestResponseJSONSchemaSerial(t *testing.T) {
+	t.Parallel()
+
+	o := NewOptions(WithMeter(Meter{Serial: "GM201948213"}), WithSingleShot())
+	schema := o.ResponseJSONSchema()
+	if _, ok := schema["properties"].(map[string]any)["serial_number"]; !ok || !slices.Contains(schema["required"].([]string), "serial_number") {
+		t.Fatalf("schema = %v, want a required serial_number", schema)
+	}
+	if _, ok := SingleShotJSONSchema["properties"].(map[string]any)["serial_number"]; ok {
+		t.Fatal("SingleShotJSONSchema was modified")
+	}
+	if len(SingleShotJSONSchema["required"].([]string)) == len(schema["required"].([]string)) {
+		t.Fatal("required list shared with SingleShotJSONSchema")
+	}
+
+	o = NewOptions(WithReadSerial())
+	if _, ok := o.ResponseJSONSchema()["properties"].(map[string]any)["serial_number"]; !ok {
+		t.Fatal("WithReadSerial does not ask for serial_number")
+	}
+	p, err := NewPrompts(o, "", "")
+	if err != nil || !strings.Contains(p.SystemText, "serial_number") {
+		t.Fatalf("system prompt with WithReadSerial does not ask for the serial (%v)", err)
+	}
+}
@@ -0,0 +1,68 @@
+package genai
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SingleShotJSONSchema is [ReadResultJSONSchema] with the positions of the
+// digits the model resolved itself.
+var SingleShotJSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"read":                map[string]any{"type": "string"},
+		"confidences":         confidencesJSONSchema,
+		"date":                map[string]any{"type": "string"},
+		"ambiguous_positions": map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+		"issue":               issueJSONSchema,
+	},
+	"required":             []string{"read", "confidences", "date", "ambiguous_positions", "issue"},
+	"additionalProperties": false,
+}
+
+// WithSingleShot asks the model to resolve uncertain digits itself using the
+// previous reading, so the disambiguation call is only made when the answer
+// still contains "?". It has no effect on [MeterDials].
+func WithSingleShot() Option {
+	return func(o *Options) {
+		o.SingleShot = true
+	}
+}
+
+// SingleShotMode reports whether single-shot mode applies to the meter.
+func (o *Options) SingleShotMode() bool {
+	return o.SingleShot && o.Meter.Type != MeterDials
+}
+
+// ResponseJSONSchema returns the answer schema for the meter type and mode.
+func (o *Options) ResponseJSONSchema() map[string]any {
+	schema := o.Meter.ResponseJSONSchema()
+	if o.SingleShotMode() {
+		schema = SingleShotJSONSchema
+	}
+	if o.Meter.Serial != "" || o.ReadSerial {
+		schema = withProperty(schema, "serial_number", map[string]any{"type": "string"})
+	}
+	if o.CounterBox {
+		schema = withProperty(schema, "counter_box", counterBoxJSONSchema)
+	}
+	return schema
+}
+
+// SingleShotPrompt appends the single-shot instructions to a rendered image prompt.
+func (p *Prompts) SingleShotPrompt(prompt, prevRead string) string {
+	return prompt + "\n\n" + fmt.Sprintf(p.SingleShot, prevRead)
+}
+
+// CleanPositions returns the positions in pos that index a digit of read,
+// sorted and without duplicates.
+func CleanPositions(read string, pos []int) []int {
+	var out []int
+	for _, i := range pos {
+		if i >= 0 && i < len(read) && read[i] >= '0' && read[i] <= '9' {
+			out = append(out, i)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -0,0 +1,81 @@
+package genai
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCleanPositions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		read string
+		pos  []int
+		want []int
+	}{
+		{"none", "02924.457", nil, nil},
+		{"sorted and deduplicated", "02924.457", []int{8, 4, 8}, []int{4, 8}},
+		{"decimal point", "02924.457", []int{5}, nil},
+		{"out of range", "02924.457", []int{-1, 9}, nil},
+		{"question mark", "0292?.457", []int{4, 3}, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := CleanPositions(tt.read, tt.pos); !slices.Equal(got, tt.want) {
+				t.Fatalf("CleanPositions(%q, %v) = %v, want %v", tt.read, tt.pos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanConfidences(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		read string
+		conf []float64
+		want []float64
+	}{
+		{"none", "02924.457", nil, nil},
+		{"one per digit", "02924.457", []float64{1, 1, 1, 1, 0.9, 1, 1, 0.5}, []float64{1, 1, 1, 1, 0.9, 1, 1, 0.5}},
+		{"question mark", "0292?.457", []float64{1, 1, 1, 1, 0.2, 1, 1, 1}, []float64{1, 1, 1, 1, 0.2, 1, 1, 1}},
+		{"clamped", "02924.457", []float64{1.5, 1, 1, 1, -0.1, 1, 1, 1}, []float64{1, 1, 1, 1, 0, 1, 1, 1}},
+		{"one per character", "02924.457", []float64{1, 1, 1, 1, 1, 1, 1, 1, 1}, nil},
+		{"too few", "02924.457", []float64{0.5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := CleanConfidences(tt.read, tt.conf); !slices.Equal(got, tt.want) {
+				t.Fatalf("CleanConfidences(%q, %v) = %v, want %v", tt.read, tt.conf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleShotMode(t *testing.T) {
+	t.Parallel()
+
+	plain := NewOptions()
+	single := NewOptions(WithSingleShot())
+	dials := NewOptions(WithSingleShot(), WithMeter(Meter{Type: MeterDials}))
+	if plain.SingleShotMode() || !single.SingleShotMode() || dials.SingleShotMode() {
+		t.Fatalf("SingleShotMode = %v, %v, %v; want false, true, false",
+			plain.SingleShotMode(), single.SingleShotMode(), dials.SingleShotMode())
+	}
+
+	p1, err := NewPrompts(plain, "", "")
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	p2, err := NewPrompts(single, "", "")
+	if err != nil {
+		t.Fatalf("NewPrompts: %v", err)
+	}
+	if p1.Hash == p2.Hash {
+		t.Fatal("single-shot mode does not change the prompt hash")
+	}
+}
@@ -0,0 +1,91 @@
+package genai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Snapshot is the effective configuration of a client: the model, the
+// prompts, the options and the few-shot examples. A client takes the current
+// one at the start of every call and uses only it, including for the
+// disambiguation and validation of the reading, so that a call that is
+// running while the client is reconfigured sees one configuration
+// throughout. A Snapshot is not changed once made.
+type Snapshot struct {
+	Model    string
+	Prompts  *Prompts
+	Options  Options
+	Examples []LoadedExample
+	// Hash is a truncated SHA-256 over what the readings depend on; see
+	// [GasMeterReadResult.ConfigHash].
+	Hash string
+}
+
+// NewSnapshot resolves the configuration of a client of model with the
+// custom prompts of [NewPrompts] and opts.
+func NewSnapshot(model, systemPrompt, prompt string, opts ...Option) (*Snapshot, error) {
+	o := NewOptions(opts...)
+	prompts, err := NewPrompts(o, systemPrompt, prompt)
+	if err != nil {
+		return nil, err
+	}
+	examples, err := LoadExamples(o.Examples)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Model: model, Prompts: prompts, Options: o, Examples: examples}
+	if s.Hash, err = s.hash(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// hash hashes the model, the prompts, the examples and the options that
+// change what is read or accepted, not those of logging and the backend.
+func (s *Snapshot) hash() (string, error) {
+	o := s.Options
+	b, err := json.Marshal(struct {
+		Model, Prompts, Locale, Location, Agreement string
+		Meter                                       Meter
+		ResponseSchema, SerialCheck, ReadSerial     bool
+		Stateless, SingleShot, CounterBox           bool
+		SelfVerify                                  bool
+		MaxImageSize                                int64
+		Retries                                     int
+		FallbackModels, Ensemble                    []string
+	}{
+		Model: s.Model, Prompts: s.Prompts.Hash, Locale: o.Locale, Location: o.Location.String(), Agreement: fmt.Sprintf("%#v", o.Agreement),
+		Meter:          o.Meter,
+		ResponseSchema: o.ResponseSchema, SerialCheck: o.SerialCheck, ReadSerial: o.ReadSerial,
+		Stateless: o.Stateless, SingleShot: o.SingleShot, CounterBox: o.CounterBox,
+		SelfVerify:     o.SelfVerify,
+		MaxImageSize:   o.MaxImageSize,
+		Retries:        o.Retries,
+		FallbackModels: o.FallbackModels, Ensemble: o.Ensemble,
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash config: %w", err)
+	}
+	h := sha256.New()
+	h.Write(b)
+	for _, e := range s.Examples {
+		h.Write([]byte{0})
+		h.Write(e.JPEG)
+		h.Write([]byte{0})
+		h.Write([]byte(e.ExpectedRead))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// Reconfigurer is implemented by clients whose configuration can be
+// replaced while they run, as on a reload of the config file. Calls already
+// running finish with the [Snapshot] they started with. The previous
+// reading, the counters and the connection to the backend are kept, so
+// the seed and the HTTP client of opts are not used.
+type Reconfigurer interface {
+	Reconfigure(model, systemPrompt, prompt string, opts ...Option) error
+	// Snapshot returns the current configuration.
+	Snapshot() *Snapshot
+}
@@ -0,0 +1,36 @@
+package genai
+
+import "testing"
+
+func TestSnapshotHash(t *testing.T) {
+	t.Parallel()
+
+	base, err := NewSnapshot("model", "", "")
+	if err != nil {
+		t.Fatalf("NewSnapshot: %v", err)
+	}
+	same, err := NewSnapshot("model", "", "", WithDebug(true))
+	if err != nil {
+		t.Fatalf("NewSnapshot: %v", err)
+	}
+	if base.Hash == "" || same.Hash != base.Hash {
+		t.Fatalf("hashes %q and %q, want one not depending on debug logging", base.Hash, same.Hash)
+	}
+	for name, tt := range map[string]struct {
+		model, prompt string
+		opts          []Option
+	}{
+		"model":        {model: "other"},
+		"prompt":       {model: "model", prompt: "read the meter"},
+		"serial check": {model: "model", opts: []Option{WithSerialCheck(false)}},
+		"meter":        {model: "model", opts: []Option{WithMeter(Meter{IntDigits: 6, FracDigits: 2})}},
+	} {
+		s, err := NewSnapshot(tt.model, "", tt.prompt, tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: NewSnapshot: %v", name, err)
+		}
+		if s.Hash == base.Hash {
+			t.Errorf("%s: hash %q unchanged", name, s.Hash)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of a client's counters. It holds no references, so
+// copies are independent. Each counter is read atomically, but a snapshot
+// taken during a reading may count it in some counters and not yet in others.
+type Stats struct {
+	Reads     int64 `json:"reads"`
+	Successes int64 `json:"successes"`
+	Failures  int64 `json:"failures"`
+	// FailuresBy splits Failures by [ClassifyFailure].
+	FailuresBy Failures `json:"failures_by"`
+	// Ambiguous counts successful readings with uncertain digits, and Guessed
+	// those of them completed by a disambiguation call rather than by the
+	// model itself in single-shot mode.
+	Ambiguous int64 `json:"ambiguous"`
+	Guessed   int64 `json:"guessed"`
+	// CacheHits counts few-shot example images reused from an earlier upload
+	// rather than uploaded again.
+	CacheHits int64 `json:"cache_hits"`
+	// InputTokens and OutputTokens add up the usage reported for every model
+	// call, failed ones and guesses included.
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	// CachedInputTokens is the part of InputTokens served from the backend's
+	// cache, such as the prompts of [WithContextCache].
+	CachedInputTokens int64 `json:"cached_input_tokens"`
+	// AverageSeconds is the mean duration of successful readings.
+	AverageSeconds float64 `json:"average_seconds"`
+	// EnsembleDisagreements counts ensemble reads the models did not agree on.
+	EnsembleDisagreements int64 `json:"ensemble_disagreements"`
+	// VerifyDisagreements counts readings the model changed on re-examination;
+	// see [WithSelfVerify].
+	VerifyDisagreements int64 `json:"verify_disagreements"`
+	// Upload, Generate, Guess, Verify and Total are the durations of the
+	// phases of successful readings; see [Phases].
+	// UploadRetries counts the image uploads attempted again, and
+	// UploadRetriedBytes the bytes sent again; see [WithUploadRetries].
+	UploadRetries      int64     `json:"upload_retries"`
+	UploadRetriedBytes int64     `json:"upload_retried_bytes"`
+	Upload             Histogram `json:"upload"`
+	Generate           Histogram `json:"generate"`
+	Guess              Histogram `json:"guess"`
+	Verify             Histogram `json:"verify"`
+	Total              Histogram `json:"total"`
+}
+
+// Failures counts failed readings by category; see [ClassifyFailure].
+type Failures struct {
+	Timeout       int64 `json:"timeout"`
+	Canceled      int64 `json:"canceled"`
+	ImageTooLarge int64 `json:"image_too_large"`
+	Truncated     int64 `json:"truncated"`
+	Empty         int64 `json:"empty"`
+	InvalidOutput int64 `json:"invalid_output"`
+	WrongMeter    int64 `json:"wrong_meter"`
+	Other         int64 `json:"other"`
+}
+
+// Failure categories, as returned by [ClassifyFailure].
+const (
+	FailureTimeout       = "timeout"
+	FailureCanceled      = "canceled"
+	FailureImageTooLarge = "image_too_large"
+	FailureTruncated     = "truncated"
+	FailureEmpty         = "empty"
+	FailureInvalidOutput = "invalid_output"
+	FailureWrongMeter    = "wrong_meter"
+	FailureOther         = "other" // e.g. an API or network error
+)
+
+// failureCategories are the categories in the order of the fields of [Failures].
+var failureCategories = [...]string{
+	FailureTimeout, FailureCanceled, FailureImageTooLarge, FailureTruncated,
+	FailureEmpty, FailureInvalidOutput, FailureWrongMeter, FailureOther,
+}
+
+// ClassifyFailure returns the category of the error of a failed reading.
+func ClassifyFailure(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	case errors.Is(err, context.Canceled):
+		return FailureCanceled
+	case errors.Is(err, ErrImageTooLarge):
+		return FailureImageTooLarge
+	case errors.Is(err, ErrTruncatedOutput):
+		return FailureTruncated
+	case errors.Is(err, ErrEmptyReading):
+		return FailureEmpty
+	case errors.Is(err, ErrInvalidModelOutput):
+		return FailureInvalidOutput
+	case errors.Is(err, ErrWrongMeter):
+		return FailureWrongMeter
+	}
+	return FailureOther
+}
+
+// StatsReporter is implemented by clients that keep [Stats].
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// Counters accumulates [Stats] for a client; the zero value is ready to use.
+type Counters struct {
+	reads, successes, failures, disagreements atomic.Int64
+	verifyDisagreements                       atomic.Int64
+	failuresBy                                [len(failureCategories)]atomic.Int64
+	ambiguous, guessed, cacheHits             atomic.Int64
+	inputTokens, outputTokens, cachedTokens   atomic.Int64
+	uploadRetries, uploadRetriedBytes         atomic.Int64
+
+	upload, generate, guess, verify, total histogram
+}
+
+// CountRead records the outcome of one ReadGasGaugePic call: out on success,
+// err otherwise.
+func (c *Counters) CountRead(out *GasMeterReadResult, err error) {
+	c.reads.Add(1)
+	if err != nil {
+		c.failures.Add(1)
+		c.failuresBy[slices.Index(failureCategories[:], ClassifyFailure(err))].Add(1)
+		return
+	}
+	c.successes.Add(1)
+	if out != nil && out.Ambiguous {
+		c.ambiguous.Add(1)
+	}
+}
+
+// CountGuess records a reading completed by a disambiguation call.
+func (c *Counters) CountGuess() {
+	c.guessed.Add(1)
+}
+
+// CountCacheHit records a few-shot example reused from an earlier upload.
+func (c *Counters) CountCacheHit() {
+	c.cacheHits.Add(1)
+}
+
+// CountUsage records the tokens of one model call.
+func (c *Counters) CountUsage(u Usage) {
+	c.inputTokens.Add(int64(u.InputTokens))
+	c.outputTokens.Add(int64(u.OutputTokens))
+	c.cachedTokens.Add(int64(u.CachedTokens))
+}
+
+// CountUpload records the attempts of an image upload, whether or not it
+// succeeded in the end.
+func (c *Counters) CountUpload(a UploadAttempts) {
+	if a.Attempts > 1 {
+		c.uploadRetries.Add(int64(a.Attempts - 1))
+		c.uploadRetriedBytes.Add(a.RetriedBytes)
+	}
+}
+
+// CountDisagreement records an ensemble disagreement.
+func (c *Counters) CountDisagreement() {
+	c.disagreements.Add(1)
+}
+
+// CountVerifyDisagreement records a reading changed on re-examination.
+func (c *Counters) CountVerifyDisagreement() {
+	c.verifyDisagreements.Add(1)
+}
+
+// ObservePhases records the phases of a successful reading. Phases a reading
+// skipped, such as the guess of a reading without uncertain digits, are not
+// counted.
+func (c *Counters) ObservePhases(p Phases) {
+	if p.Upload > 0 {
+		c.upload.observe(p.Upload)
+	}
+	c.generate.observe(p.Generate)
+	if p.Guess > 0 {
+		c.guess.observe(p.Guess)
+	}
+	if p.Verify > 0 {
+		c.verify.observe(p.Verify)
+	}
+	c.total.observe(p.Total)
+}
+
+// Snapshot returns the current counts and histograms.
+func (c *Counters) Snapshot() Stats {
+	s := Stats{
+		Reads:                 c.reads.Load(),
+		Successes:             c.successes.Load(),
+		Failures:              c.failures.Load(),
+		Ambiguous:             c.ambiguous.Load(),
+		Guessed:               c.guessed.Load(),
+		CacheHits:             c.cacheHits.Load(),
+		InputTokens:           c.inputTokens.Load(),
+		OutputTokens:          c.outputTokens.Load(),
+		CachedInputTokens:     c.cachedTokens.Load(),
+		EnsembleDisagreements: c.disagreements.Load(),
+		VerifyDisagreements:   c.verifyDisagreements.Load(),
+		UploadRetries:         c.uploadRetries.Load(),
+		UploadRetriedBytes:    c.uploadRetriedBytes.Load(),
+		Upload:                c.upload.snapshot(),
+		Generate:              c.generate.snapshot(),
+		Guess:                 c.guess.snapshot(),
+		Verify:                c.verify.snapshot(),
+		Total:                 c.total.snapshot(),
+	}
+	by := &s.FailuresBy
+	for i, n := range []*int64{&by.Timeout, &by.Canceled, &by.ImageTooLarge, &by.Truncated, &by.Empty, &by.InvalidOutput, &by.WrongMeter, &by.Other} {
+		*n = c.failuresBy[i].Load()
+	}
+	if s.Total.Count > 0 {
+		s.AverageSeconds = s.Total.SumSeconds / float64(s.Total.Count)
+	}
+	return s
+}
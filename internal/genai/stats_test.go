@@ -0,0 +1,40 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("analyze image: %w", context.DeadlineExceeded), FailureTimeout},
+		{context.Canceled, FailureCanceled},
+		{ErrImageTooLarge, FailureImageTooLarge},
+		{fmt.Errorf("%w (finish reason %q)", ErrTruncatedOutput, FinishLength), FailureTruncated},
+		{ErrEmptyReading, FailureEmpty},
+		{&InvalidOutputError{Raw: "{", Err: errors.New("unexpected EOF")}, FailureInvalidOutput},
+		{fmt.Errorf("%w: serial GM2019-51077", ErrWrongMeter), FailureWrongMeter},
+		{errors.New("503 service unavailable"), FailureOther},
+	}
+	var c Counters
+	for _, tt := range tests {
+		if got := ClassifyFailure(tt.err); got != tt.want {
+			t.Fatalf("ClassifyFailure(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+		c.CountRead(nil, tt.err)
+	}
+	c.CountRead(&GasMeterReadResult{Ambiguous: true}, nil)
+
+	s := c.Snapshot()
+	want := Failures{Timeout: 1, Canceled: 1, ImageTooLarge: 1, Truncated: 1, Empty: 1, InvalidOutput: 1, WrongMeter: 1, Other: 1}
+	if s.FailuresBy != want || s.Failures != 8 || s.Successes != 1 || s.Ambiguous != 1 {
+		t.Fatalf("Snapshot = %+v", s)
+	}
+}
@@ -0,0 +1,134 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Phases of a reading, as named by a [TimeoutError] and [Timing.TimedOut].
+const (
+	PhaseUpload   = "upload"   // the image and example uploads
+	PhaseGenerate = "generate" // one reading call to a model
+	PhaseGuess    = "guess"    // the disambiguation call
+	PhaseVerify   = "verify"   // the re-examination turn
+)
+
+// Timeouts bound a reading: Call the whole of it, and Upload, Generate and
+// Guess their phase, each within what is left of Call. Generate bounds every
+// call to a model, retries and ensemble models each getting their own. Zero
+// means no bound of its own, so that with Call alone every phase may use
+// all of it.
+type Timeouts struct {
+	Call, Upload, Generate, Guess time.Duration
+}
+
+// WithTimeouts bounds every reading by t.
+func WithTimeouts(t Timeouts) Option {
+	return func(o *Options) {
+		o.Timeouts = t
+	}
+}
+
+func (t Timeouts) of(phase string) time.Duration {
+	switch phase {
+	case PhaseUpload:
+		return t.Upload
+	case PhaseGenerate:
+		return t.Generate
+	case PhaseGuess:
+		return t.Guess
+	}
+	return 0
+}
+
+// TimeoutError is returned for a reading whose Phase ran out of time: its
+// own Timeout, or the deadline of the whole call if Call is set. It is a
+// [context.DeadlineExceeded].
+type TimeoutError struct {
+	Phase   string
+	Call    bool
+	Timeout time.Duration // zero if the deadline is not that of [Timeouts]
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	switch {
+	case !e.Call:
+		return fmt.Sprintf("%s timed out after %s: %v", e.Phase, e.Timeout, e.Err)
+	case e.Timeout > 0:
+		return fmt.Sprintf("%s ran past the %s deadline of the call: %v", e.Phase, e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("%s ran past the deadline of the call: %v", e.Phase, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every TimeoutError a [context.DeadlineExceeded], whatever the
+// backend wrapped it in.
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// PhaseTimer applies the [Timeouts] of one reading and records the phases
+// that hit theirs. It is safe for concurrent use, as by the models of an
+// ensemble.
+type PhaseTimer struct {
+	t    Timeouts
+	call context.Context
+
+	mu       sync.Mutex
+	timedOut []string
+}
+
+// StartTimer bounds ctx, that of a whole reading, by [Options.Timeouts] and
+// returns the timer of its phases.
+func (o *Options) StartTimer(ctx context.Context) (context.Context, *PhaseTimer, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if o.Timeouts.Call > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.Timeouts.Call)
+	}
+	return ctx, &PhaseTimer{t: o.Timeouts, call: ctx}, cancel
+}
+
+// Phase bounds ctx, a context of the reading, by the timeout of phase.
+func (pt *PhaseTimer) Phase(ctx context.Context, phase string) (context.Context, context.CancelFunc) {
+	if d := pt.t.of(phase); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// Err returns err, that of phase run with ctx, as a [*TimeoutError] if ctx
+// ran out of time.
+func (pt *PhaseTimer) Err(ctx context.Context, phase string, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		return err
+	}
+	te = &TimeoutError{Phase: phase, Err: err}
+	if pt.call.Err() != nil {
+		te.Call, te.Timeout = true, pt.t.Call
+		return te
+	}
+	te.Timeout = pt.t.of(phase)
+	pt.mu.Lock()
+	pt.timedOut = append(pt.timedOut, phase)
+	pt.mu.Unlock()
+	return te
+}
+
+// TimedOut returns the phases that hit their own timeout so far, in order,
+// e.g. a generate call that was then retried.
+func (pt *PhaseTimer) TimedOut() []string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return append([]string(nil), pt.timedOut...)
+}
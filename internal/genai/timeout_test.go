@@ -0,0 +1,45 @@
+package genai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	t.Parallel()
+
+	o := NewOptions(WithTimeouts(Timeouts{Upload: time.Millisecond, Guess: time.Hour}))
+	ctx, timer, cancel := o.StartTimer(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatal("call deadline without Timeouts.Call")
+	}
+
+	uctx, ucancel := timer.Phase(ctx, PhaseUpload)
+	defer ucancel()
+	<-uctx.Done()
+	err := timer.Err(uctx, PhaseUpload, uctx.Err())
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Phase != PhaseUpload || te.Call || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Err = %v, want an upload timeout", err)
+	}
+	if got := err.Error(); got != "upload timed out after 1ms: context deadline exceeded" {
+		t.Fatalf("Error() = %q", got)
+	}
+
+	// Other errors, and phases without their own timeout, pass through.
+	gctx, gcancel := timer.Phase(ctx, PhaseGenerate)
+	defer gcancel()
+	if _, ok := gctx.Deadline(); ok {
+		t.Fatal("generate deadline without Timeouts.Generate")
+	}
+	other := errors.New("bad request")
+	if err := timer.Err(gctx, PhaseGenerate, other); err != other {
+		t.Fatalf("Err = %v, want %v", err, other)
+	}
+	if got := timer.TimedOut(); len(got) != 1 || got[0] != PhaseUpload {
+		t.Fatalf("TimedOut = %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+package genai
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Timing breaks ItTakes down by phase.
+type Timing struct {
+	// Upload is the image upload to the Files API, including example images
+	// uploaded on first use; backends that send images inline leave it empty.
+	Upload string `json:"upload,omitempty"`
+	// UploadAttempts counts the attempts of the image upload when it was
+	// retried, and UploadRetriedBytes the bytes sent again; see
+	// [WithUploadRetries].
+	UploadAttempts     int   `json:"upload_attempts,omitempty"`
+	UploadRetriedBytes int64 `json:"upload_retried_bytes,omitempty"`
+	// Read is the reading call, or all ensemble calls together.
+	Read string `json:"read"`
+	// Guess is the disambiguation call, the round-trip single-shot mode saves.
+	Guess string `json:"guess,omitempty"`
+	// Verify is the re-examination turn of [WithSelfVerify].
+	Verify     string `json:"verify,omitempty"`
+	SingleShot bool   `json:"single_shot,omitempty"`
+	// TimedOut lists the phases that hit their timeout of [WithTimeouts]
+	// and were retried, e.g. "generate" for a model call cut off before the
+	// fallback model answered.
+	TimedOut []string `json:"timed_out,omitempty"`
+	// InputTokens, OutputTokens and ThinkingTokens add up the usage of the
+	// calls of the reading, as far as the backend reports it; thinking is
+	// part of the output. See [WithGeneration].
+	InputTokens    int `json:"input_tokens,omitempty"`
+	OutputTokens   int `json:"output_tokens,omitempty"`
+	ThinkingTokens int `json:"thinking_tokens,omitempty"`
+}
+
+// Phases are the durations of one reading; [Phases.Timing] is their form
+// in a result.
+type Phases struct {
+	Upload, Generate, Guess, Verify, Total time.Duration
+	// Uploads are the attempts of the image upload.
+	Uploads UploadAttempts
+	// TimedOut are the phases that hit their timeout; see [PhaseTimer.TimedOut].
+	TimedOut []string
+	// Usage is that of all calls; see [WithUsage].
+	Usage Usage
+}
+
+// Timing returns the [Timing] of p.
+func (p Phases) Timing(singleShot bool) *Timing {
+	t := &Timing{
+		Read: p.Generate.String(), SingleShot: singleShot, TimedOut: p.TimedOut,
+		InputTokens: p.Usage.InputTokens, OutputTokens: p.Usage.OutputTokens, ThinkingTokens: p.Usage.ThinkingTokens,
+	}
+	if p.Upload > 0 {
+		t.Upload = p.Upload.String()
+	}
+	if p.Uploads.Attempts > 1 {
+		t.UploadAttempts, t.UploadRetriedBytes = p.Uploads.Attempts, p.Uploads.RetriedBytes
+	}
+	if p.Guess > 0 {
+		t.Guess = p.Guess.String()
+	}
+	if p.Verify > 0 {
+		t.Verify = p.Verify.String()
+	}
+	return t
+}
+
+// WithSlowThreshold makes the client log a warning with the phases and the
+// image size of every reading that takes d or longer. Zero disables it.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *Options) {
+		o.SlowThreshold = d
+	}
+}
+
+// LogSlow logs p if the reading took [Options.SlowThreshold] or longer.
+// imageSize is 0 if unknown, e.g. for image URLs.
+func (o *Options) LogSlow(model string, p Phases, imageSize int64) {
+	if o.SlowThreshold <= 0 || p.Total < o.SlowThreshold {
+		return
+	}
+	log.Printf("warning: slow reading: total=%s upload=%s upload_attempts=%d generate=%s guess=%s verify=%s image_bytes=%d model=%s",
+		p.Total, p.Upload, max(p.Uploads.Attempts, 1), p.Generate, p.Guess, p.Verify, imageSize, model)
+}
+
+// DurationBuckets are the upper bounds of the buckets of a [Histogram].
+var DurationBuckets = [...]time.Duration{
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second,
+	5 * time.Second, 10 * time.Second, 20 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// Histogram is a snapshot of durations counted into [DurationBuckets].
+// Counts are cumulative, as in a Prometheus histogram: Counts[i] is the
+// number of durations up to DurationBuckets[i]; Count includes longer ones.
+type Histogram struct {
+	Counts     []int64 `json:"counts"`
+	Count      int64   `json:"count"`
+	SumSeconds float64 `json:"sum_seconds"`
+}
+
+// histogram accumulates a [Histogram]; the zero value is ready to use.
+type histogram struct {
+	counts [len(DurationBuckets)]atomic.Int64 // per bucket, not cumulative
+	count  atomic.Int64
+	sum    atomic.Int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	for i, le := range DurationBuckets {
+		if d <= le {
+			h.counts[i].Add(1)
+			break
+		}
+	}
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Counts:     make([]int64, len(DurationBuckets)),
+		Count:      h.count.Load(),
+		SumSeconds: time.Duration(h.sum.Load()).Seconds(),
+	}
+	var n int64
+	for i := range s.Counts {
+		n += h.counts[i].Load()
+		s.Counts[i] = n
+	}
+	return s
+}
@@ -0,0 +1,41 @@
+package genai
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestObservePhases(t *testing.T) {
+	t.Parallel()
+
+	var c Counters
+	c.ObservePhases(Phases{Upload: 300 * time.Millisecond, Generate: 4 * time.Second, Total: 5 * time.Second})
+	c.ObservePhases(Phases{Generate: 11 * time.Second, Guess: 2 * time.Second, Total: 90 * time.Second})
+	s := c.Snapshot()
+
+	if s.Upload.Count != 1 || !slices.Equal(s.Upload.Counts, []int64{0, 1, 1, 1, 1, 1, 1, 1, 1}) {
+		t.Fatalf("upload = %+v", s.Upload)
+	}
+	if !slices.Equal(s.Generate.Counts, []int64{0, 0, 0, 0, 1, 1, 2, 2, 2}) || s.Generate.SumSeconds != 15 {
+		t.Fatalf("generate = %+v", s.Generate)
+	}
+	if s.Guess.Count != 1 || s.Guess.Counts[3] != 1 {
+		t.Fatalf("guess = %+v", s.Guess)
+	}
+	// 90s is above the last bucket: counted, but in no bucket.
+	if s.Total.Count != 2 || s.Total.Counts[len(DurationBuckets)-1] != 1 {
+		t.Fatalf("total = %+v", s.Total)
+	}
+}
+
+func TestPhasesTiming(t *testing.T) {
+	t.Parallel()
+
+	got := *Phases{Upload: 1500 * time.Millisecond, Generate: 3 * time.Second, Total: 5 * time.Second, TimedOut: []string{PhaseGenerate}}.Timing(true)
+	want := Timing{Upload: "1.5s", Read: "3s", SingleShot: true, TimedOut: []string{PhaseGenerate}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Timing = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package genai
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the reading pipeline's spans.
+const TracerName = "github.com/suapapa/mqvision"
+
+// Spans of the reading pipeline, in order. Clients record read (the whole
+// reading), upload, generate (one per model call), validate, guess and verify; the
+// daemon records the image span around all of them and the others.
+const (
+	SpanImage      = "image"
+	SpanCapture    = "capture"     // receiving the image
+	SpanArchive    = "archive"     // storing the image for reference
+	SpanCrossCheck = "cross_check" // the secondary recognizer, alongside read
+	SpanRead       = "read"
+	SpanUpload     = "upload"
+	SpanGenerate   = "generate"
+	SpanValidate   = "validate"
+	SpanGuess      = "guess"
+	SpanVerify     = "verify"
+	SpanFlow       = "flow" // a [FlowDetector] call, in place of read
+	SpanStore      = "store"
+	SpanPublish    = "publish"
+)
+
+// Span attributes.
+const (
+	AttrMeterID        = attribute.Key("meter.id")
+	AttrImageSize      = attribute.Key("image.size_bytes")
+	AttrUploadAttempts = attribute.Key("upload.attempts")
+	AttrRead           = attribute.Key("meter.read")
+	AttrCall           = attribute.Key("gen_ai.operation.name") // CallRead, CallGuess, CallVerify or CallFlow
+	AttrModel          = attribute.Key("gen_ai.request.model")
+	AttrInputTokens    = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens   = attribute.Key("gen_ai.usage.output_tokens")
+	AttrFinishReason   = attribute.Key("gen_ai.response.finish_reason")
+)
+
+// WithTracerProvider records the spans of the client with tp instead of the
+// global provider, which is a no-op unless set with otel.SetTracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Options) {
+		o.TracerProvider = tp
+	}
+}
+
+// StartSpan starts the span name of the reading pipeline with attrs.
+func (o *Options) StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tp := o.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err, if any, on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// CallAttributes are the attributes of a generate span of a model call.
+func CallAttributes(call, model string, u Usage, finishReason string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrCall.String(call),
+		AttrModel.String(model),
+		AttrInputTokens.Int(u.InputTokens),
+		AttrOutputTokens.Int(u.OutputTokens),
+	}
+	if finishReason != "" {
+		attrs = append(attrs, AttrFinishReason.String(finishReason))
+	}
+	return attrs
+}
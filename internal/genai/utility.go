@@ -0,0 +1,49 @@
+package genai
+
+import "fmt"
+
+// Utilities a meter can measure.
+const (
+	UtilityGas         = "gas" // default
+	UtilityWater       = "water"
+	UtilityElectricity = "electricity"
+)
+
+// UtilityDefaults are the layout and Home Assistant metadata of a typical
+// meter of a utility, used for fields the configuration leaves unset.
+type UtilityDefaults struct {
+	IntDigits   int
+	FracDigits  int
+	Unit        string
+	DeviceClass string // Home Assistant sensor device_class
+}
+
+var utilityDefaults = map[string]UtilityDefaults{
+	UtilityGas:   {IntDigits: 5, FracDigits: 3, Unit: "m³", DeviceClass: "gas"},
+	UtilityWater: {IntDigits: 5, FracDigits: 3, Unit: "m³", DeviceClass: "water"},
+	// A Ferraris meter: six black integer digits and one red tenth.
+	UtilityElectricity: {IntDigits: 6, FracDigits: 1, Unit: "kWh", DeviceClass: "energy"},
+}
+
+// Utilities lists the supported utilities.
+func Utilities() []string {
+	return []string{UtilityGas, UtilityWater, UtilityElectricity}
+}
+
+// LookupUtility returns the defaults of utility; empty means [UtilityGas].
+func LookupUtility(utility string) (UtilityDefaults, error) {
+	if utility == "" {
+		utility = UtilityGas
+	}
+	d, ok := utilityDefaults[utility]
+	if !ok {
+		return UtilityDefaults{}, fmt.Errorf("unsupported utility %q", utility)
+	}
+	return d, nil
+}
+
+// DeviceClass returns the Home Assistant device_class of m's utility.
+func (m Meter) DeviceClass() string {
+	d, _ := LookupUtility(m.Utility)
+	return d.DeviceClass
+}
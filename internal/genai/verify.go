@@ -0,0 +1,84 @@
+package genai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// WithSelfVerify asks the model, after it answered, to re-examine the image
+// and confirm or correct its reading. The question is a follow-up turn of
+// the same conversation, so the image is not sent or uploaded again; it
+// costs one more model call per reading. Both answers are kept in the
+// result (see [GasMeterReadResult.SetVerified]). It has no effect on
+// [MeterDials].
+func WithSelfVerify() Option {
+	return func(o *Options) {
+		o.SelfVerify = true
+	}
+}
+
+// SelfVerifyMode reports whether self-verification applies to the meter.
+func (o *Options) SelfVerifyMode() bool {
+	return o.SelfVerify && o.Meter.Type != MeterDials
+}
+
+type selfVerifyKey struct{}
+
+// ContextWithSelfVerify returns ctx making the readings of a client under it
+// self-verify as with [WithSelfVerify], e.g. a reading a cross-check
+// disputes, without it for every reading.
+func ContextWithSelfVerify(ctx context.Context) context.Context {
+	return context.WithValue(ctx, selfVerifyKey{}, true)
+}
+
+// SelfVerifyIn reports whether self-verification applies to a reading under
+// ctx: with [WithSelfVerify] or [ContextWithSelfVerify].
+func (o *Options) SelfVerifyIn(ctx context.Context) bool {
+	forced, _ := ctx.Value(selfVerifyKey{}).(bool)
+	return (o.SelfVerify || forced) && o.Meter.Type != MeterDials
+}
+
+// VerifyPrompt formats the verification prompt for the first answer read.
+func (p *Prompts) VerifyPrompt(read string) string {
+	return fmt.Sprintf(p.Verify, read)
+}
+
+// VerifyAnswer is the model turn that stands for the first answer in the
+// verification conversation: its reading, after any disambiguation, and date.
+func VerifyAnswer(out *GasMeterReadResult) string {
+	b, _ := json.Marshal(map[string]string{"read": out.Read, "date": out.Date})
+	return string(b)
+}
+
+// MergeVerified returns the re-examined reading verified with the digits the
+// model still marked "?" taken from the first reading read, position by
+// position; a verified reading of another length is returned as is.
+func MergeVerified(read, verified string) string {
+	if len(verified) != len(read) {
+		return verified
+	}
+	b := []byte(verified)
+	for i := range b {
+		if b[i] == '?' {
+			b[i] = read[i]
+		}
+	}
+	return string(b)
+}
+
+// SetVerified records verified, the model's reading on re-examination. When
+// it differs from Read, FirstRead keeps the first answer, which stays Read
+// until the acceptance pipeline chooses between the two.
+func (r *GasMeterReadResult) SetVerified(verified string) {
+	r.VerifiedRead = verified
+	if verified != r.Read {
+		r.FirstRead = r.Read
+	}
+}
+
+// VerifyDisagrees reports whether the model changed its reading when asked to
+// re-examine the image with [WithSelfVerify].
+func (r *GasMeterReadResult) VerifyDisagrees() bool {
+	return r.FirstRead != ""
+}
@@ -0,0 +1,6 @@
+package genai
+
+// Version is the reader version recorded in every result. Set it at build time:
+//
+//	go build -ldflags "-X github.com/suapapa/mqvision/internal/genai.Version=v1.2.3"
+var Version = "dev"
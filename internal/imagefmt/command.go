@@ -0,0 +1,174 @@
+package imagefmt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commands a [CommandConfig] runs.
+const (
+	CommandHEIFConvert = "heif-convert" // of libheif
+	CommandMagick      = "magick"       // ImageMagick 7
+)
+
+// Defaults of [CommandConfig].
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultMaxInputKB = 32 << 10
+	DefaultQuality    = 90
+)
+
+// CommandConfig configures [Command], the transcoder that runs heif-convert
+// or ImageMagick on every image that is not JPEG.
+type CommandConfig struct {
+	// Command is heif-convert or magick, by name or path (default: the
+	// first of them on the PATH).
+	Command string `yaml:"command"`
+	// Timeout stops a conversion taking longer (default 30s).
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxInputKB rejects larger images before they are converted (default
+	// 32768); MaxOutputKB larger results (0: no limit).
+	MaxInputKB  int `yaml:"max_input_kb"`
+	MaxOutputKB int `yaml:"max_output_kb"`
+	// Quality is the JPEG quality, 1 to 100 (default 90).
+	Quality int `yaml:"quality"`
+}
+
+// Validate checks c.
+func (c CommandConfig) Validate() error {
+	if c.Command != "" {
+		if _, err := commandArgs(c.Command, "in", "out", DefaultQuality); err != nil {
+			return err
+		}
+	}
+	if c.Timeout < 0 || c.MaxInputKB < 0 || c.MaxOutputKB < 0 {
+		return errors.New("timeout, max_input_kb and max_output_kb must not be negative")
+	}
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("quality %d, want 1 to 100", c.Quality)
+	}
+	return nil
+}
+
+// Options returns the options of a [Decoder] transcoding with c, and an
+// error if its command is not found.
+func (c CommandConfig) Options() ([]Option, error) {
+	t, err := Command(c)
+	if err != nil {
+		return nil, err
+	}
+	return []Option{WithTranscoder(t), WithMaxOutput(int64(c.MaxOutputKB) << 10)}, nil
+}
+
+// Command returns a [Transcoder] running the command of c, looked up on the
+// PATH now so that a missing one fails at startup rather than on the first
+// photo. The image goes through a temporary directory, as heif-convert
+// reads and writes files only.
+func Command(c CommandConfig) (Transcoder, error) {
+	path, err := lookCommand(c.Command)
+	if err != nil {
+		return nil, err
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.MaxInputKB == 0 {
+		c.MaxInputKB = DefaultMaxInputKB
+	}
+	if c.Quality == 0 {
+		c.Quality = DefaultQuality
+	}
+	return func(r io.Reader, mime string) (io.Reader, string, error) {
+		return runCommand(path, c, r, mime)
+	}, nil
+}
+
+// lookCommand finds name, or the first known command, on the PATH.
+func lookCommand(name string) (string, error) {
+	if name != "" {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("transcode command: %w", err)
+		}
+		return path, nil
+	}
+	for _, name := range []string{CommandHEIFConvert, CommandMagick} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("transcode command: neither %s nor %s on the PATH", CommandHEIFConvert, CommandMagick)
+}
+
+// commandArgs returns the arguments converting in to the JPEG out with
+// command, by its base name.
+func commandArgs(command, in, out string, quality int) ([]string, error) {
+	switch strings.TrimSuffix(filepath.Base(command), ".exe") {
+	case CommandHEIFConvert:
+		return []string{"-q", fmt.Sprint(quality), in, out}, nil
+	case CommandMagick:
+		// [0]: the primary image of a sequence or burst.
+		return []string{in + "[0]", "-auto-orient", "-quality", fmt.Sprint(quality), out}, nil
+	}
+	return nil, fmt.Errorf("unknown transcode command %q, want %s or %s", command, CommandHEIFConvert, CommandMagick)
+}
+
+// extensions name the temporary input by type, for the commands that go by
+// the extension.
+var extensions = map[string]string{MIMEHEIC: ".heic", MIMEHEIF: ".heif", MIMEAVIF: ".avif"}
+
+func runCommand(path string, c CommandConfig, r io.Reader, mime string) (io.Reader, string, error) {
+	maxInput := int64(c.MaxInputKB) << 10
+	img, err := io.ReadAll(io.LimitReader(r, maxInput+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(img)) > maxInput {
+		return nil, "", fmt.Errorf("image over %d bytes", maxInput)
+	}
+
+	dir, err := os.MkdirTemp("", "mqvision-transcode-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(dir)
+	ext, ok := extensions[mime]
+	if !ok {
+		ext = ".img"
+	}
+	in, out := filepath.Join(dir, "in"+ext), filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(in, img, 0o600); err != nil {
+		return nil, "", err
+	}
+	args, err := commandArgs(path, in, out, c.Quality)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	// Do not wait on children still holding stderr once it is killed.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, "", fmt.Errorf("%s: timed out after %s", filepath.Base(path), c.Timeout)
+		}
+		return nil, "", fmt.Errorf("%s: %w: %s", filepath.Base(path), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	jpg, err := os.ReadFile(out)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return bytes.NewReader(jpg), MIMEJPEG, nil
+}
@@ -0,0 +1,115 @@
+// Package imagefmt makes JPEG of the images the cameras and phones send:
+// everything downstream, the quality gate, the crops, the concierge upload
+// and the models, takes JPEG only. JPEG passes through; other formats, such
+// as the HEIC photos of an iPhone, go through a [Transcoder] when one is set.
+package imagefmt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MIME types of the images [Detect] tells apart beyond [http.DetectContentType].
+const (
+	MIMEJPEG = "image/jpeg"
+	MIMEHEIC = "image/heic"
+	MIMEHEIF = "image/heif"
+	MIMEAVIF = "image/avif"
+)
+
+// ErrUnsupportedImage is returned for an image that is not JPEG when there
+// is no transcoder, or whose transcoder does not return JPEG.
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// Transcoder converts r, an image of type mime, to another image and
+// returns it with its type.
+type Transcoder func(r io.Reader, mime string) (io.Reader, string, error)
+
+// Option configures a [Decoder].
+type Option func(*Decoder)
+
+// WithTranscoder converts the images that are not JPEG with t.
+func WithTranscoder(t Transcoder) Option {
+	return func(d *Decoder) {
+		d.transcoder = t
+	}
+}
+
+// WithMaxOutput rejects transcoded images larger than n bytes (0: no limit).
+func WithMaxOutput(n int64) Option {
+	return func(d *Decoder) {
+		d.maxOutput = n
+	}
+}
+
+// Decoder makes JPEG of images; the zero Decoder takes JPEG only.
+type Decoder struct {
+	transcoder Transcoder
+	maxOutput  int64
+}
+
+// NewDecoder returns a Decoder configured by opts.
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// JPEG returns img as JPEG: img itself if it is one, else what the
+// transcoder makes of it. It fails with [ErrUnsupportedImage], naming the
+// detected type, without a transcoder or if the transcoded image is not
+// JPEG either.
+func (d *Decoder) JPEG(img []byte) ([]byte, error) {
+	mime := Detect(img)
+	if mime == MIMEJPEG {
+		return img, nil
+	}
+	if d == nil || d.transcoder == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImage, mime)
+	}
+	r, _, err := d.transcoder(bytes.NewReader(img), mime)
+	if err != nil {
+		return nil, fmt.Errorf("transcode %s: %w", mime, err)
+	}
+	if d.maxOutput > 0 {
+		r = io.LimitReader(r, d.maxOutput+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("transcode %s: %w", mime, err)
+	}
+	if d.maxOutput > 0 && int64(len(out)) > d.maxOutput {
+		return nil, fmt.Errorf("transcode %s: output over %d bytes", mime, d.maxOutput)
+	}
+	// Trust the bytes over the type the transcoder claims.
+	if got := Detect(out); got != MIMEJPEG {
+		return nil, fmt.Errorf("%w: %s transcoded to %s", ErrUnsupportedImage, mime, got)
+	}
+	return out, nil
+}
+
+// heifBrands are the ISO BMFF brands of HEIF images and sequences, by the
+// type they are reported as.
+var heifBrands = map[string]string{
+	"heic": MIMEHEIC, "heix": MIMEHEIC, "hevc": MIMEHEIC, "hevx": MIMEHEIC,
+	"heim": MIMEHEIC, "heis": MIMEHEIC,
+	"mif1": MIMEHEIF, "msf1": MIMEHEIF,
+	"avif": MIMEAVIF, "avis": MIMEAVIF,
+}
+
+// Detect returns the MIME type of img: that of [http.DetectContentType],
+// which does not know HEIF, unless its ISO BMFF "ftyp" box names a HEIF or
+// AVIF brand.
+func Detect(img []byte) string {
+	if len(img) >= 12 && string(img[4:8]) == "ftyp" {
+		if mime, ok := heifBrands[string(img[8:12])]; ok {
+			return mime
+		}
+	}
+	return http.DetectContentType(img)
+}
@@ -0,0 +1,162 @@
+package imagefmt
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heic is the start of a HEIC file: its ftyp box.
+var heic = []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+
+func encode(t *testing.T, enc func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := enc(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, nil)
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		img  []byte
+		want string
+	}{
+		{encode(t, encodeJPEG), MIMEJPEG},
+		{encode(t, png.Encode), "image/png"},
+		{heic, MIMEHEIC},
+		{[]byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00"), MIMEHEIF},
+		{[]byte("\x00\x00\x00\x18ftypavif\x00\x00\x00\x00"), MIMEAVIF},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.img); got != tt.want {
+			t.Errorf("Detect(%q...) = %q, want %q", tt.img[:12], got, tt.want)
+		}
+	}
+}
+
+func TestDecoderJPEG(t *testing.T) {
+	t.Parallel()
+
+	jpg := encode(t, encodeJPEG)
+	if got, err := NewDecoder().JPEG(jpg); err != nil || !bytes.Equal(got, jpg) {
+		t.Fatalf("JPEG(jpeg) = %d bytes, %v; want it unchanged", len(got), err)
+	}
+	_, err := NewDecoder().JPEG(heic)
+	if !errors.Is(err, ErrUnsupportedImage) || !strings.Contains(err.Error(), MIMEHEIC) {
+		t.Fatalf("JPEG(heic) without a transcoder: %v", err)
+	}
+
+	var gotMIME string
+	d := NewDecoder(WithTranscoder(func(r io.Reader, mime string) (io.Reader, string, error) {
+		gotMIME = mime
+		return bytes.NewReader(jpg), MIMEJPEG, nil
+	}))
+	if got, err := d.JPEG(heic); err != nil || !bytes.Equal(got, jpg) || gotMIME != MIMEHEIC {
+		t.Fatalf("JPEG(heic) = %d bytes, %v, transcoded as %q", len(got), err, gotMIME)
+	}
+
+	// A transcoder claiming JPEG is not believed.
+	d = NewDecoder(WithTranscoder(func(r io.Reader, mime string) (io.Reader, string, error) {
+		return r, MIMEJPEG, nil
+	}))
+	if _, err := d.JPEG(heic); !errors.Is(err, ErrUnsupportedImage) {
+		t.Fatalf("JPEG with a transcoder returning HEIC: %v", err)
+	}
+	d = NewDecoder(WithTranscoder(func(r io.Reader, mime string) (io.Reader, string, error) {
+		return bytes.NewReader(jpg), MIMEJPEG, nil
+	}), WithMaxOutput(int64(len(jpg)-1)))
+	if _, err := d.JPEG(heic); err == nil {
+		t.Fatal("JPEG over the output limit: no error")
+	}
+}
+
+// fakeCommand writes a shell script named name to a new directory that
+// runs script, and returns its path.
+func fakeCommand(t *testing.T, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	jpg := encode(t, encodeJPEG)
+	src := filepath.Join(t.TempDir(), "src.jpg")
+	if err := os.WriteFile(src, jpg, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// heif-convert -q 90 IN OUT
+	convert := fakeCommand(t, CommandHEIFConvert, `case "$3" in *.heic) cp "`+src+`" "$4";; *) exit 1;; esac`)
+	tc, err := Command(CommandConfig{Command: convert})
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	if got, err := NewDecoder(WithTranscoder(tc)).JPEG(heic); err != nil || !bytes.Equal(got, jpg) {
+		t.Fatalf("JPEG through %s = %d bytes, %v", CommandHEIFConvert, len(got), err)
+	}
+
+	tc, err = Command(CommandConfig{Command: convert, MaxInputKB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tc(bytes.NewReader(make([]byte, 2<<10)), MIMEHEIC); err == nil {
+		t.Fatal("transcoding over max_input_kb: no error")
+	}
+
+	slow := fakeCommand(t, CommandMagick, "sleep 5")
+	tc, err = Command(CommandConfig{Command: slow, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tc(bytes.NewReader(heic), MIMEHEIC); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("slow command: %v", err)
+	}
+
+	if _, err := Command(CommandConfig{Command: filepath.Join(t.TempDir(), "heif-convert")}); err == nil {
+		t.Fatal("Command with a missing command: no error")
+	}
+}
+
+func TestCommandConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		c       CommandConfig
+		wantErr bool
+	}{
+		{CommandConfig{}, false},
+		{CommandConfig{Command: "/usr/local/bin/magick", Timeout: time.Minute, Quality: 85}, false},
+		{CommandConfig{Command: "convert"}, true},
+		{CommandConfig{Timeout: -time.Second}, true},
+		{CommandConfig{Quality: 101}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tt.c, err, tt.wantErr)
+		}
+	}
+}
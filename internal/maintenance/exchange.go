@@ -0,0 +1,144 @@
+package maintenance
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// ExchangeKey is the [store.StateStore] key the last [Exchange] of a meter
+// is saved under.
+const ExchangeKey = "exchange"
+
+// Exchange is the exchange of a meter recorded at once, after the fact,
+// rather than by keeping the meter in maintenance while it happens.
+type Exchange struct {
+	// At is when the meter was exchanged.
+	At time.Time `json:"at"`
+	// Final is the final reading of the old meter; empty if unknown, and the
+	// old meter counts up to its last reading.
+	Final string `json:"final,omitempty"`
+	// Serial is the serial number of the new meter, expected of its readings
+	// from then on; empty keeps the one expected before.
+	Serial string `json:"serial,omitempty"`
+	// Start is the value the new meter starts at; empty is zero.
+	Start string `json:"start,omitempty"`
+	Note  string `json:"note,omitempty"`
+	// Marker is the ID of the reading marking the exchange in the history,
+	// see [Exchange.Readings].
+	Marker string `json:"marker,omitempty"`
+}
+
+// Validate checks e, an exchange of a meter laid out as m whose last
+// reading is prev, if any: Final and Start are readings of m, Final is not
+// below prev but for a rollover, and the exchange is not before prev.
+func (e Exchange) Validate(m genai.Meter, prev *genai.GasMeterReadResult) error {
+	if e.At.IsZero() {
+		return errors.New("needs the time of the exchange")
+	}
+	if err := checkStart(m, e.Start); err != nil {
+		return err
+	}
+	if prev == nil {
+		if e.Final == "" {
+			return nil
+		}
+		if _, err := genai.ParseRead(m, e.Final); err != nil {
+			return fmt.Errorf("final: %w", err)
+		}
+		return nil
+	}
+	if e.At.Before(prev.ReadAt) {
+		return fmt.Errorf("exchanged at %s, before the last reading at %s", e.At.Format(time.RFC3339), prev.ReadAt.Format(time.RFC3339))
+	}
+	if e.Final == "" {
+		return nil
+	}
+	final, err := genai.ParseRead(m, e.Final)
+	if err != nil {
+		return fmt.Errorf("final: %w", err)
+	}
+	if v, err := genai.ParseRead(m, prev.Read); err == nil && prev.Counted() {
+		if _, ok := m.Delta(v, final); !ok {
+			return fmt.Errorf("final %s is below the last reading %s", e.Final, prev.Read)
+		}
+	}
+	return nil
+}
+
+// Readings returns the readings of e, of meterID laid out as m, to save in
+// its history in order, both taken at At and entered by hand: the final
+// reading of the old meter, if known, and the marker, the start of the new
+// meter marked [genai.GasMeterReadResult.MeterExchanged]. The consumption up
+// to the final reading is counted on the old meter and from the marker on
+// the new one, none in between; see [genai.Meter.DeltaTo].
+func (e Exchange) Readings(m genai.Meter, meterID string) []*genai.GasMeterReadResult {
+	var rs []*genai.GasMeterReadResult
+	if e.Final != "" {
+		rs = append(rs, &genai.GasMeterReadResult{Read: e.Final, ReadAt: e.At, Provenance: genai.ProvenanceEntered})
+	}
+	start := cmp.Or(e.Start, m.FormatRead(0))
+	rs = append(rs, &genai.GasMeterReadResult{
+		Read:           start,
+		ReadAt:         e.At,
+		SerialNumber:   e.Serial,
+		Provenance:     genai.ProvenanceEntered,
+		MeterExchanged: &genai.Exchange{Previous: e.Final, Start: e.Start, At: e.At, Serial: e.Serial},
+	})
+	for _, r := range rs {
+		r.ID = genai.ReadingID(meterID, r)
+	}
+	return rs
+}
+
+// LastExchange returns the last exchange of meterID recorded with
+// [Keeper.Exchanged]; ok is false if there is none.
+func (k *Keeper) LastExchange(ctx context.Context, meterID string) (e Exchange, ok bool, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch err := k.ss.LoadState(ctx, meterID, ExchangeKey, &e); {
+	case errors.Is(err, store.ErrNotFound):
+		return Exchange{}, false, nil
+	case err != nil:
+		return Exchange{}, false, fmt.Errorf("load exchange: %w", err)
+	}
+	return e, true, nil
+}
+
+// Exchanged records e, an exchange of meterID whose readings were saved, as
+// the last exchange of the meter. A maintenance in progress, or waiting for
+// the first reading after it, is done with the marker of e, so that the
+// next reading is not marked exchanged once more.
+func (k *Keeper) Exchanged(ctx context.Context, meterID string, e Exchange) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec, err := k.load(ctx, meterID)
+	if err != nil {
+		return err
+	}
+	if c := rec.Current; c != nil {
+		active := c.Active(e.At)
+		if active {
+			c.Ended = e.At
+		}
+		c.Exchanged, c.Start = e.Marker, cmp.Or(e.Start, c.Start)
+		rec.Current, rec.Last = nil, c
+		if active {
+			err = k.saveToggled(ctx, meterID, rec, false, *c)
+		} else {
+			err = k.save(ctx, meterID, rec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := k.ss.SaveState(ctx, meterID, ExchangeKey, e); err != nil {
+		return fmt.Errorf("save exchange: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Package maintenance keeps meters in maintenance mode, as while the utility
+// exchanges one: the value drops or jumps and the serial number changes, so
+// the validators of [validate.Exchange] only warn and alerts are held back.
+// The readings taken meanwhile are marked
+// [genai.GasMeterReadResult.Maintenance] and not counted; the first accepted
+// after maintenance is marked [genai.GasMeterReadResult.MeterExchanged], so
+// that consumption runs up to the final reading of the old meter and on from
+// the start of the new one. An exchange can also be recorded at once, after
+// the fact, as an [Exchange]. The state is saved in the store of the
+// history.
+package maintenance
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// StateKey is the [store.StateStore] key the [Record] of a meter is saved
+// under.
+const StateKey = "maintenance"
+
+// ErrNotActive is returned for ending the maintenance of a meter not in it.
+var ErrNotActive = errors.New("meter not in maintenance")
+
+// Config is a maintenance window in the config file. It begins once, at the
+// first start of the daemon after Since, and ends at Until; removing it ends
+// the maintenance early.
+type Config struct {
+	// Since is when maintenance begins (default: the start of the daemon).
+	Since time.Time `yaml:"since"`
+	Until time.Time `yaml:"until"`
+	Note  string    `yaml:"note"`
+	// Start is the value the new meter starts at (default: zero).
+	Start string `yaml:"start"`
+}
+
+// Validate checks that Until is set and after Since, and Start is a reading
+// of m.
+func (c Config) Validate(m genai.Meter) error {
+	if c.Until.IsZero() {
+		return errors.New("needs until")
+	}
+	if !c.Since.IsZero() && !c.Until.After(c.Since) {
+		return fmt.Errorf("until %s is not after since %s", c.Until.Format(time.RFC3339), c.Since.Format(time.RFC3339))
+	}
+	return checkStart(m, c.Start)
+}
+
+func checkStart(m genai.Meter, start string) error {
+	if start == "" {
+		return nil
+	}
+	if _, err := genai.ParseRead(m, start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	return nil
+}
+
+// State is a maintenance of a meter.
+type State struct {
+	Since time.Time `json:"since"`
+	// Until is when the maintenance ends by itself; zero when it lasts until
+	// ended.
+	Until time.Time `json:"until,omitzero"`
+	// Ended is when it was ended before Until.
+	Ended time.Time `json:"ended,omitzero"`
+	Note  string    `json:"note,omitempty"`
+	// Start is the value the new meter starts at; empty is zero.
+	Start string `json:"start,omitempty"`
+	// Final is the last reading accepted before the maintenance, of the old
+	// meter.
+	Final string `json:"final,omitempty"`
+	// Config marks a maintenance of the config file.
+	Config bool `json:"config,omitempty"`
+	// Exchanged is the ID of the first reading accepted after it, once there
+	// is one.
+	Exchanged string `json:"exchanged,omitempty"`
+}
+
+// Active reports whether the maintenance lasts at at.
+func (s State) Active(at time.Time) bool {
+	return !at.Before(s.Since) && (s.Until.IsZero() || at.Before(s.Until)) && (s.Ended.IsZero() || at.Before(s.Ended))
+}
+
+// Record is the maintenance of a meter, saved in the state of its store.
+type Record struct {
+	// Current is the maintenance in progress, or ended and waiting for the
+	// first reading after it.
+	Current *State `json:"current,omitempty"`
+	// Last is the last maintenance followed by a reading.
+	Last *State `json:"last,omitempty"`
+}
+
+// Event is the data of the [store.EventMaintenance] events a [Keeper] logs
+// when a maintenance begins or ends.
+type Event struct {
+	Active bool  `json:"active"`
+	State  State `json:"state"`
+}
+
+// Keeper keeps the maintenance of meters. It is safe for concurrent use.
+type Keeper struct {
+	ss store.StateStore
+	el store.EventLog // nil if the store has none
+	m  genai.Meter
+	mu sync.Mutex
+}
+
+// NewKeeper returns a Keeper of meters laid out as m, saving their state in
+// s; without a store keeping state, it is kept in memory only and lost on
+// restart. If s is a [store.EventLog], the maintenance begun and ended is
+// logged to it.
+func NewKeeper(s store.Store, m genai.Meter) *Keeper {
+	ss, ok := s.(store.StateStore)
+	if !ok {
+		ss = store.NewMemory()
+	}
+	el, _ := s.(store.EventLog)
+	return &Keeper{ss: ss, el: el, m: m}
+}
+
+// Load returns the record of meterID.
+func (k *Keeper) Load(ctx context.Context, meterID string) (Record, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.load(ctx, meterID)
+}
+
+func (k *Keeper) load(ctx context.Context, meterID string) (Record, error) {
+	var rec Record
+	if err := k.ss.LoadState(ctx, meterID, StateKey, &rec); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return rec, fmt.Errorf("load maintenance: %w", err)
+	}
+	return rec, nil
+}
+
+func (k *Keeper) save(ctx context.Context, meterID string, rec Record) error {
+	if err := k.ss.SaveState(ctx, meterID, StateKey, rec); err != nil {
+		return fmt.Errorf("save maintenance: %w", err)
+	}
+	return nil
+}
+
+// saveToggled saves rec, in which the maintenance s began if active and
+// ended otherwise, and logs it.
+func (k *Keeper) saveToggled(ctx context.Context, meterID string, rec Record, active bool, s State) error {
+	if err := k.save(ctx, meterID, rec); err != nil || k.el == nil {
+		return err
+	}
+	data, err := json.Marshal(Event{Active: active, State: s})
+	if err != nil {
+		return fmt.Errorf("log maintenance: %w", err)
+	}
+	if _, err := k.el.LogEvent(ctx, store.Event{Type: store.EventMaintenance, MeterID: meterID, Time: time.Now(), Data: data}); err != nil {
+		return fmt.Errorf("log maintenance: %w", err)
+	}
+	return nil
+}
+
+// Active reports whether meterID is in maintenance at at.
+func (k *Keeper) Active(ctx context.Context, meterID string, at time.Time) (bool, error) {
+	rec, err := k.Load(ctx, meterID)
+	return rec.Current != nil && rec.Current.Active(at), err
+}
+
+// Begin puts meterID in maintenance from s.Since until s.Until, or until
+// ended if zero, and returns the state. A maintenance in progress is
+// extended instead: its Since and Final are kept.
+func (k *Keeper) Begin(ctx context.Context, meterID string, s State) (State, error) {
+	if err := checkStart(k.m, s.Start); err != nil {
+		return State{}, err
+	}
+	if !s.Until.IsZero() && !s.Until.After(s.Since) {
+		return State{}, fmt.Errorf("until %s is not after %s", s.Until.Format(time.RFC3339), s.Since.Format(time.RFC3339))
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec, err := k.load(ctx, meterID)
+	if err != nil {
+		return State{}, err
+	}
+	if c := rec.Current; c != nil {
+		s.Final = c.Final
+		if c.Active(s.Since) {
+			s.Since = c.Since
+		}
+	}
+	rec.Current = &s
+	return s, k.saveToggled(ctx, meterID, rec, true, s)
+}
+
+// End ends the maintenance of meterID at at, with start, if set, as the
+// value the new meter starts at, and returns the state. The next reading
+// accepted is marked exchanged.
+func (k *Keeper) End(ctx context.Context, meterID string, at time.Time, start string) (State, error) {
+	if err := checkStart(k.m, start); err != nil {
+		return State{}, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec, err := k.load(ctx, meterID)
+	if err != nil {
+		return State{}, err
+	}
+	c := rec.Current
+	if c == nil || !c.Active(at) {
+		return State{}, ErrNotActive
+	}
+	c.Ended, c.Start = at, cmp.Or(start, c.Start)
+	return *c, k.saveToggled(ctx, meterID, rec, false, *c)
+}
+
+// Configure applies the maintenance window c, or its removal if nil, to
+// meterID at now and returns the state of the maintenance in progress, if
+// any. A window begins unless it is over or it began before; one removed
+// from the config ends.
+func (k *Keeper) Configure(ctx context.Context, meterID string, c *Config, now time.Time) (*State, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec, err := k.load(ctx, meterID)
+	if err != nil {
+		return nil, err
+	}
+	cur := rec.Current
+	switch {
+	case c == nil:
+		if cur == nil || !cur.Config || !cur.Active(now) {
+			return cur, nil
+		}
+		cur.Ended = now
+		return cur, k.saveToggled(ctx, meterID, rec, false, *cur)
+	case cur != nil && (!cur.Config || cur.Until.Equal(c.Until)),
+		rec.Last != nil && rec.Last.Config && rec.Last.Until.Equal(c.Until),
+		!now.Before(c.Until):
+		// Begun from the API or before, or over.
+		return cur, nil
+	default:
+		s := State{Since: cmp.Or(c.Since, now), Until: c.Until, Note: c.Note, Start: c.Start, Config: true}
+		if cur != nil {
+			s.Final = cur.Final
+		}
+		rec.Current = &s
+		return rec.Current, k.saveToggled(ctx, meterID, rec, true, s)
+	}
+}
+
+// Mark marks r, a reading of meterID after prev, for the maintenance: as
+// taken during it, or as the first reading after it. It is called before
+// the reading is validated.
+func (k *Keeper) Mark(ctx context.Context, meterID string, prev, r *genai.GasMeterReadResult) error {
+	rec, err := k.Load(ctx, meterID)
+	c := rec.Current
+	switch {
+	case err != nil || c == nil || r.ReadAt.Before(c.Since):
+	case c.Active(r.ReadAt):
+		r.Maintenance = true
+	default:
+		final := c.Final
+		if final == "" && prev != nil && prev.Counted() {
+			final = prev.Read
+		}
+		r.MeterExchanged = &genai.Exchange{Previous: final, Start: c.Start, At: c.Since}
+	}
+	return err
+}
+
+// Accepted records that r, a reading of meterID after prev marked by Mark,
+// was accepted: the first taken during the maintenance keeps prev as the
+// final reading of the old meter, and the first after it ends the
+// maintenance.
+func (k *Keeper) Accepted(ctx context.Context, meterID string, prev, r *genai.GasMeterReadResult) error {
+	if !r.Maintenance && r.MeterExchanged == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rec, err := k.load(ctx, meterID)
+	if err != nil || rec.Current == nil {
+		return err
+	}
+	c := rec.Current
+	switch {
+	case r.MeterExchanged != nil:
+		c.Exchanged = cmp.Or(r.ID, genai.ReadingID(meterID, r))
+		rec.Current, rec.Last = nil, c
+		if c.Ended.IsZero() {
+			// Ended at Until, by itself.
+			return k.saveToggled(ctx, meterID, rec, false, *c)
+		}
+	case c.Final == "" && prev != nil && prev.Counted():
+		c.Final = prev.Read
+	default:
+		return nil
+	}
+	return k.save(ctx, meterID, rec)
+}
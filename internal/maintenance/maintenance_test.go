@@ -0,0 +1,245 @@
+package maintenance_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var at = time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC)
+
+func reading(read string, after time.Duration) *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{Read: read, ReadAt: at.Add(after)}
+}
+
+// accept marks r and records it accepted after prev, as the daemon does.
+func accept(t *testing.T, k *maintenance.Keeper, prev, r *genai.GasMeterReadResult) *genai.GasMeterReadResult {
+	t.Helper()
+	ctx := context.Background()
+	if err := k.Mark(ctx, "home", prev, r); err != nil {
+		t.Fatalf("Mark: %v", err)
+	}
+	if err := k.Accepted(ctx, "home", prev, r); err != nil {
+		t.Fatalf("Accepted: %v", err)
+	}
+	return r
+}
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.NewMemory()
+	k := maintenance.NewKeeper(s, genai.DefaultMeter)
+	old := accept(t, k, nil, reading("09876.000", 0))
+	final := accept(t, k, old, reading("09876.500", time.Hour))
+	if old.Maintenance || final.Maintenance || final.MeterExchanged != nil {
+		t.Fatalf("readings before maintenance marked: %+v, %+v", old, final)
+	}
+
+	if _, err := k.Begin(ctx, "home", maintenance.State{Since: at.Add(90 * time.Minute), Note: "exchange"}); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	during := []*genai.GasMeterReadResult{accept(t, k, final, reading("00000.000", 2*time.Hour))}
+	during = append(during, accept(t, k, during[0], reading("09999.999", 3*time.Hour)))
+	for _, r := range during {
+		if !r.Maintenance {
+			t.Fatalf("reading during maintenance not marked: %+v", r)
+		}
+	}
+	if _, err := k.End(ctx, "home", at.Add(4*time.Hour), "00000.100"); err != nil {
+		t.Fatalf("End: %v", err)
+	}
+	if active, _ := k.Active(ctx, "home", at.Add(5*time.Hour)); active {
+		t.Fatal("still active after End")
+	}
+	es, err := s.EventsSince(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var toggled []maintenance.Event
+	for _, e := range es {
+		if e.Type == store.EventMaintenance {
+			var me maintenance.Event
+			if err := json.Unmarshal(e.Data, &me); err != nil {
+				t.Fatalf("event %d: %v", e.Seq, err)
+			}
+			toggled = append(toggled, me)
+		}
+	}
+	if len(toggled) != 2 || !toggled[0].Active || toggled[1].Active || toggled[1].State.Start != "00000.100" {
+		t.Fatalf("logged maintenance %+v, want it begun and ended", toggled)
+	}
+
+	// A fresh keeper of the store, as after a restart, marks the exchange.
+	k = maintenance.NewKeeper(s, genai.DefaultMeter)
+	first := accept(t, k, during[1], reading("00000.400", 5*time.Hour))
+	want := genai.Exchange{Previous: "09876.500", Start: "00000.100", At: at.Add(90 * time.Minute)}
+	if first.Maintenance || first.MeterExchanged == nil || *first.MeterExchanged != want {
+		t.Fatalf("first reading after maintenance = %+v, want exchanged %+v", first, want)
+	}
+	next := accept(t, k, first, reading("00000.600", 6*time.Hour))
+	if next.MeterExchanged != nil || next.Maintenance {
+		t.Fatalf("second reading after maintenance marked: %+v", next)
+	}
+	rec, err := k.Load(ctx, "home")
+	if err != nil || rec.Current != nil || rec.Last == nil || rec.Last.Exchanged != genai.ReadingID("home", first) || rec.Last.Final != "09876.500" {
+		t.Fatalf("Load = %+v, %v; want the maintenance done", rec, err)
+	}
+
+	// The old meter counts up to its final reading, the new one from its
+	// start; the readings in between count for nothing.
+	rs := append([]*genai.GasMeterReadResult{old, final}, append(during, first, next)...)
+	if got := billing.Consumption(genai.DefaultMeter, rs); got < 1.0-1e-9 || got > 1.0+1e-9 {
+		t.Fatalf("consumption across the exchange = %v, want 0.5 + 0.3 + 0.2", got)
+	}
+
+	if _, err := k.End(ctx, "home", at.Add(7*time.Hour), ""); !errors.Is(err, maintenance.ErrNotActive) {
+		t.Fatalf("End after the maintenance: %v, want ErrNotActive", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k := maintenance.NewKeeper(nil, genai.DefaultMeter)
+	if _, err := k.Begin(ctx, "home", maintenance.State{Since: at, Until: at.Add(24 * time.Hour)}); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if r := accept(t, k, nil, reading("00001.000", 23*time.Hour)); !r.Maintenance {
+		t.Fatalf("reading before until = %+v, want maintenance", r)
+	}
+	if r := accept(t, k, nil, reading("00001.500", 25*time.Hour)); r.MeterExchanged == nil || r.MeterExchanged.Previous != "" {
+		t.Fatalf("reading after until = %+v, want exchanged without a final reading", r)
+	}
+	if _, err := k.Begin(ctx, "home", maintenance.State{Since: at, Until: at}); err == nil {
+		t.Fatal("Begin accepted until not after since")
+	}
+	if _, err := k.Begin(ctx, "home", maintenance.State{Since: at, Start: "1.0"}); err == nil {
+		t.Fatal("Begin accepted a start that is no reading")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	k := maintenance.NewKeeper(nil, genai.DefaultMeter)
+	c := &maintenance.Config{Until: at.Add(48 * time.Hour), Note: "exchange"}
+	s, err := k.Configure(ctx, "home", c, at)
+	if err != nil || s == nil || !s.Config || !s.Since.Equal(at) || !s.Active(at.Add(time.Hour)) {
+		t.Fatalf("Configure = %+v, %v; want begun at the start", s, err)
+	}
+	// Restarting with the same window keeps it.
+	if s, _ := k.Configure(ctx, "home", c, at.Add(time.Hour)); s == nil || !s.Since.Equal(at) {
+		t.Fatalf("Configure again = %+v, want the window as begun", s)
+	}
+	// Removing it from the config ends it.
+	if s, _ := k.Configure(ctx, "home", nil, at.Add(2*time.Hour)); s == nil || s.Active(at.Add(2*time.Hour)) {
+		t.Fatalf("Configure without a window = %+v, want ended", s)
+	}
+	accept(t, k, nil, reading("00000.100", 3*time.Hour))
+	// The window done, it does not begin again.
+	if s, _ := k.Configure(ctx, "home", c, at.Add(4*time.Hour)); s != nil {
+		t.Fatalf("Configure after the exchange = %+v, want none", s)
+	}
+	// Nor does one that is over.
+	over := &maintenance.Config{Since: at.Add(-48 * time.Hour), Until: at.Add(-24 * time.Hour)}
+	if s, _ := k.Configure(ctx, "home", over, at); s != nil {
+		t.Fatalf("Configure of a past window = %+v, want none", s)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg     maintenance.Config
+		wantErr bool
+	}{
+		{maintenance.Config{Until: at}, false},
+		{maintenance.Config{Since: at, Until: at.Add(time.Hour), Start: "00000.000"}, false},
+		{maintenance.Config{}, true},
+		{maintenance.Config{Since: at, Until: at}, true},
+		{maintenance.Config{Until: at, Start: "12"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(genai.DefaultMeter); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExchangeAtOnce(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := genai.DefaultMeter
+	prev := reading("09876.000", 0)
+	exchangedAt := at.Add(2 * time.Hour)
+	for _, tc := range []struct {
+		name string
+		e    maintenance.Exchange
+	}{
+		{"no time", maintenance.Exchange{Final: "09876.500"}},
+		{"before the last reading", maintenance.Exchange{At: at.Add(-time.Hour), Final: "09876.500"}},
+		{"final below the last reading", maintenance.Exchange{At: exchangedAt, Final: "09875.000"}},
+		{"bad final", maintenance.Exchange{At: exchangedAt, Final: "1"}},
+		{"bad start", maintenance.Exchange{At: exchangedAt, Start: "x"}},
+	} {
+		if err := tc.e.Validate(m, prev); err == nil {
+			t.Errorf("%s: Validate = nil, want an error", tc.name)
+		}
+	}
+
+	e := maintenance.Exchange{At: exchangedAt, Final: "09876.500", Serial: "GM-2025-0002", Start: "00000.100"}
+	if err := e.Validate(m, prev); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	rs := e.Readings(m, "home")
+	if len(rs) != 2 || rs[0].Read != "09876.500" || rs[1].Read != "00000.100" || rs[1].SerialNumber != "GM-2025-0002" ||
+		rs[0].Provenance != genai.ProvenanceEntered || rs[1].MeterExchanged == nil || rs[1].MeterExchanged.Serial != "GM-2025-0002" {
+		t.Fatalf("Readings = %+v, %+v; want the final reading and the marker", rs[0], rs[1])
+	}
+	if unknown := (maintenance.Exchange{At: exchangedAt}).Readings(m, "home"); len(unknown) != 1 || unknown[0].Read != "00000.000" {
+		t.Fatalf("Readings without a final = %+v; want a marker from zero", unknown)
+	}
+
+	// The old meter counts up to its final reading, the new one from its
+	// start.
+	next := reading("00000.400", 3*time.Hour)
+	if got := billing.Consumption(m, append([]*genai.GasMeterReadResult{prev}, append(rs, next)...)); got < 0.8-1e-9 || got > 0.8+1e-9 {
+		t.Fatalf("consumption across the exchange = %v, want 0.5 + 0.3", got)
+	}
+
+	// An exchange recorded during maintenance ends it, and the reading
+	// after it is not marked exchanged once more.
+	k := maintenance.NewKeeper(store.NewMemory(), m)
+	if _, ok, err := k.LastExchange(ctx, "home"); err != nil || ok {
+		t.Fatalf("LastExchange before any = %v, %v", ok, err)
+	}
+	if _, err := k.Begin(ctx, "home", maintenance.State{Since: at.Add(time.Hour)}); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	e.Marker = rs[1].ID
+	if err := k.Exchanged(ctx, "home", e); err != nil {
+		t.Fatalf("Exchanged: %v", err)
+	}
+	if active, _ := k.Active(ctx, "home", at.Add(3*time.Hour)); active {
+		t.Fatal("still in maintenance after the exchange")
+	}
+	if r := accept(t, k, rs[1], next); r.MeterExchanged != nil || r.Maintenance {
+		t.Fatalf("reading after the exchange marked: %+v", r)
+	}
+	if got, ok, err := k.LastExchange(ctx, "home"); err != nil || !ok || got != e {
+		t.Fatalf("LastExchange = %+v, %v, %v; want %+v", got, ok, err, e)
+	}
+}
@@ -16,6 +16,7 @@ type SubHandler func() io.WriteCloser
 type Client struct {
 	client      paho.Client
 	topic       string
+	handlers    map[string]SubHandler // topics subscribed besides topic
 	chError     chan error
 	chConnected chan bool
 
@@ -63,6 +64,11 @@ func NewClient(addr string, topic string) (*Client, error) {
 	}, nil
 }
 
+// Connected reports whether the client is connected to the broker.
+func (c *Client) Connected() bool {
+	return c.client.IsConnectionOpen()
+}
+
 func (c *Client) Run(h SubHandler) error {
 	go func() {
 		for err := range c.chError {
@@ -84,16 +90,54 @@ func (c *Client) Run(h SubHandler) error {
 	}
 
 	// Subscribe to topic with message handler
-	if token := c.client.Subscribe(c.topic, 0, newMessageHandler(h, c.chError)); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("error subscribing to topic: %v", token.Error())
+	if c.topic != "" {
+		if token := c.client.Subscribe(c.topic, 0, newMessageHandler(h, c.chError)); token.Wait() && token.Error() != nil {
+			return fmt.Errorf("error subscribing to topic: %v", token.Error())
+		}
+	}
+	for topic, th := range c.handlers {
+		if token := c.client.Subscribe(topic, 0, newMessageHandler(th, c.chError)); token.Wait() && token.Error() != nil {
+			return fmt.Errorf("error subscribing to %s: %v", topic, token.Error())
+		}
 	}
 
 	return nil
 }
 
+// Handle subscribes h to topic as well when the client runs; it must be
+// called before [Client.Run]. The topic of [NewClient] may then be empty.
+func (c *Client) Handle(topic string, h SubHandler) {
+	if c.handlers == nil {
+		c.handlers = make(map[string]SubHandler)
+	}
+	c.handlers[topic] = h
+}
+
+// Publish sends payload to topic, e.g. to trigger a camera.
+func (c *Client) Publish(topic string, payload []byte) error {
+	return c.PublishWith(topic, 0, false, payload)
+}
+
+// PublishWith sends payload to topic with qos and the retain flag.
+func (c *Client) PublishWith(topic string, qos byte, retain bool, payload []byte) error {
+	if token := c.client.Publish(topic, qos, retain, payload); token.Wait() && token.Error() != nil {
+		return fmt.Errorf("error publishing to %s: %v", topic, token.Error())
+	}
+	return nil
+}
+
 func (c *Client) Stop() error {
-	if token := c.client.Unsubscribe(c.topic); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("error unsubscribing from topic: %v", token.Error())
+	topics := make([]string, 0, len(c.handlers)+1)
+	if c.topic != "" {
+		topics = append(topics, c.topic)
+	}
+	for topic := range c.handlers {
+		topics = append(topics, topic)
+	}
+	if len(topics) > 0 {
+		if token := c.client.Unsubscribe(topics...); token.Wait() && token.Error() != nil {
+			return fmt.Errorf("error unsubscribing from topic: %v", token.Error())
+		}
 	}
 	c.client.Disconnect(1000)
 
@@ -0,0 +1,392 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Transport security of [EmailConfig.TLS].
+const (
+	EmailSTARTTLS = "starttls" // upgrade the connection, or fail (default)
+	EmailTLS      = "tls"      // implicit TLS, usually on port 465
+	EmailPlain    = "none"     // unencrypted; for a relay on localhost
+)
+
+// Default templates of [EmailConfig]. They are rendered with [EmailData].
+const (
+	DefaultEmailSubject = `[mqvision] {{.Kind}} ({{.Severity}}) for meter {{.MeterID}}`
+	DefaultEmailText    = `{{.Message}}
+{{if .Suppressed}}
+{{.Suppressed}} more {{.Kind}} alerts were suppressed since the last email.
+{{end}}
+Meter: {{.MeterID}}
+Time: {{.Time.Format "2006-01-02 15:04:05 MST"}}
+`
+	DefaultEmailHTML = `<pre style="font-family: sans-serif; white-space: pre-wrap">{{.Message}}</pre>
+{{if .Suppressed}}<p>{{.Suppressed}} more {{.Kind}} alerts were suppressed since the last email.</p>
+{{end}}<p style="color: #666">Meter {{.MeterID}}, {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+`
+)
+
+// EmailConfig configures an [Email] notifier.
+type EmailConfig struct {
+	Host string `yaml:"host"`
+	// Port defaults to 587, or 465 with TLS EmailTLS.
+	Port     int    `yaml:"port"`
+	TLS      string `yaml:"tls"`
+	Username string `yaml:"username"` // PLAIN auth when set
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	// To receives digests and critical events. Recipients replaces it for
+	// the event kinds it lists, and events of a listed kind are sent
+	// whatever their severity.
+	To         []string            `yaml:"to"`
+	Recipients map[string][]string `yaml:"recipients"`
+	// Subject, Text and HTML are templates rendered with [EmailData]
+	// (default DefaultEmailSubject, DefaultEmailText and DefaultEmailHTML).
+	Subject string `yaml:"subject"`
+	Text    string `yaml:"text"`
+	HTML    string `yaml:"html"`
+	// AttachImage attaches the meter image of the event, if it has one.
+	AttachImage bool `yaml:"attach_image"`
+	// AlertInterval is the least time between two emails of the same alert
+	// kind (default 15m); alerts in between are counted and mentioned in the
+	// next one. Digests are never limited.
+	AlertInterval time.Duration `yaml:"alert_interval"`
+	// Retries is how often a failed delivery is retried (default 3), waiting
+	// RetryDelay (default 10s) and twice as long after each failure.
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	// Network is how the server is reached; the zero value dials directly
+	// and trusts the system's CAs.
+	Network Network `yaml:"-"`
+}
+
+// Validate checks the settings and templates.
+func (c EmailConfig) Validate() error {
+	if c.Host == "" || c.From == "" {
+		return errors.New("needs host and from")
+	}
+	switch c.TLS {
+	case "", EmailSTARTTLS, EmailTLS, EmailPlain:
+	default:
+		return fmt.Errorf("unknown tls %q", c.TLS)
+	}
+	if len(c.To) == 0 && len(c.Recipients) == 0 {
+		return errors.New("needs to or recipients")
+	}
+	if c.Retries < 0 || c.AlertInterval < 0 || c.RetryDelay < 0 {
+		return errors.New("negative retries, alert_interval or retry_delay")
+	}
+	_, err := newEmailTemplates(c)
+	return err
+}
+
+// EmailData is what email templates are rendered with.
+type EmailData struct {
+	Event
+	// Suppressed is the number of alerts of the kind that were not emailed
+	// because of [EmailConfig.AlertInterval].
+	Suppressed int
+}
+
+type emailTemplates struct {
+	subject, text *template.Template
+	html          *htmltemplate.Template
+}
+
+func newEmailTemplates(c EmailConfig) (*emailTemplates, error) {
+	subject, text, html := c.Subject, c.Text, c.HTML
+	if subject == "" {
+		subject = DefaultEmailSubject
+	}
+	if text == "" {
+		text = DefaultEmailText
+	}
+	if html == "" {
+		html = DefaultEmailHTML
+	}
+	var t emailTemplates
+	var err error
+	if t.subject, err = template.New("subject").Parse(subject); err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	if t.text, err = template.New("text").Parse(text); err != nil {
+		return nil, fmt.Errorf("text: %w", err)
+	}
+	if t.html, err = htmltemplate.New("html").Parse(html); err != nil {
+		return nil, fmt.Errorf("html: %w", err)
+	}
+	return &t, nil
+}
+
+// Email sends events as multipart (text and HTML) emails over SMTP: digests
+// and critical events to [EmailConfig.To], and the kinds listed in
+// [EmailConfig.Recipients] to theirs. Other events are ignored.
+type Email struct {
+	cfg  EmailConfig
+	tmpl *emailTemplates
+
+	mu         sync.Mutex
+	lastSent   map[string]time.Time // by alert kind
+	suppressed map[string]int
+}
+
+// NewEmail returns an Email notifier for cfg.
+func NewEmail(cfg EmailConfig) (*Email, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	tmpl, _ := newEmailTemplates(cfg) // checked by Validate
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == EmailTLS {
+			cfg.Port = 465
+		}
+	}
+	if cfg.AlertInterval == 0 {
+		cfg.AlertInterval = 15 * time.Minute
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = 3
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = 10 * time.Second
+	}
+	return &Email{cfg: cfg, tmpl: tmpl, lastSent: make(map[string]time.Time), suppressed: make(map[string]int)}, nil
+}
+
+func (m *Email) useNetwork(n Network) { m.cfg.Network = n }
+
+// Notify implements [Notifier]. A failed delivery is retried and then
+// returned, for the caller to log; it is not sent again later.
+func (m *Email) Notify(ctx context.Context, e Event) error {
+	to := m.recipients(e)
+	if len(to) == 0 {
+		return nil
+	}
+	suppressed, ok := m.admit(e)
+	if !ok {
+		return nil
+	}
+	msg, err := m.message(e, to, suppressed)
+	if err != nil {
+		return fmt.Errorf("email %s event: %w", e.Kind, err)
+	}
+	delay := m.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err = m.send(ctx, to, msg)
+		if err == nil || attempt == m.cfg.Retries {
+			break
+		}
+		log.Printf("Retrying email of %s event in %s: %v", e.Kind, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("email %s event: %w", e.Kind, ctx.Err())
+		}
+		delay *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("email %s event: %w", e.Kind, err)
+	}
+	return nil
+}
+
+func (m *Email) recipients(e Event) []string {
+	if to, ok := m.cfg.Recipients[e.Kind]; ok {
+		return to
+	}
+	if e.Kind == "digest" || e.Severity == Critical {
+		return m.cfg.To
+	}
+	return nil
+}
+
+// admit rate-limits alerts per kind; it returns how many were suppressed
+// since the last one sent.
+func (m *Email) admit(e Event) (suppressed int, ok bool) {
+	if e.Kind == "digest" {
+		return 0, true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if last, sent := m.lastSent[e.Kind]; sent && now.Sub(last) < m.cfg.AlertInterval {
+		m.suppressed[e.Kind]++
+		log.Printf("Not emailing %s event: last one sent %s ago", e.Kind, now.Sub(last).Round(time.Second))
+		return 0, false
+	}
+	suppressed = m.suppressed[e.Kind]
+	m.lastSent[e.Kind] = now
+	delete(m.suppressed, e.Kind)
+	return suppressed, true
+}
+
+// message renders the RFC 5322 message of e.
+func (m *Email) message(e Event, to []string, suppressed int) ([]byte, error) {
+	data := EmailData{Event: e, Suppressed: suppressed}
+	var subject, text, html bytes.Buffer
+	if err := m.tmpl.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("render subject: %w", err)
+	}
+	if err := m.tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("render text: %w", err)
+	}
+	if err := m.tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("render html: %w", err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	var body bytes.Buffer
+	alt := multipart.NewWriter(&body)
+	if err := writeQuotedPrintable(alt, "text/plain; charset=utf-8", text.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(alt, "text/html; charset=utf-8", html.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := alt.Close(); err != nil {
+		return nil, err
+	}
+	altType := "multipart/alternative; boundary=" + alt.Boundary()
+	if !m.cfg.AttachImage || len(e.Image) == 0 {
+		fmt.Fprintf(&b, "Content-Type: %s\r\n\r\n", altType)
+		b.Write(body.Bytes())
+		return b.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {altType}})
+	if err != nil {
+		return nil, err
+	}
+	part.Write(body.Bytes())
+	typ := http.DetectContentType(e.Image)
+	ext := ".jpg"
+	if exts, _ := mime.ExtensionsByType(typ); len(exts) > 0 {
+		ext = exts[0]
+	}
+	part, err = mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {typ},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="meter` + ext + `"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	enc := base64.StdEncoding.EncodeToString(e.Image)
+	for len(enc) > 76 {
+		part.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	part.Write([]byte(enc + "\r\n"))
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType string, body []byte) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// send delivers msg in one SMTP session.
+func (m *Email) send(ctx context.Context, to []string, msg []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{}
+	if m.cfg.Network.TLS != nil {
+		tlsConfig = m.cfg.Network.TLS.Clone()
+	}
+	tlsConfig.ServerName = m.cfg.Host
+	dial := m.cfg.Network.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+	if m.cfg.TLS == EmailTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if m.cfg.TLS == "" || m.cfg.TLS == EmailSTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err := c.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,241 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/notify"
+)
+
+// smtpServer is a minimal SMTP server recording delivered messages; it drops
+// the first failConns connections right away.
+type smtpServer struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	failConns int
+	rcpts     [][]string
+	msgs      []string
+}
+
+func newSMTPServer(t *testing.T, failConns int) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, failConns: failConns}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	fail := s.failConns > 0
+	s.failConns--
+	s.mu.Unlock()
+	if fail {
+		return
+	}
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP")
+	var rcpts []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpts = append(rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, rcpts)
+			s.msgs = append(s.msgs, msg.String())
+			s.mu.Unlock()
+			rcpts = nil
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) delivered() ([][]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rcpts, s.msgs
+}
+
+func newTestEmail(t *testing.T, s *smtpServer, cfg notify.EmailConfig) *notify.Email {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	cfg.Host, cfg.TLS, cfg.From = host, notify.EmailPlain, "meter@example.com"
+	cfg.Port, _ = strconv.Atoi(port)
+	cfg.RetryDelay = time.Millisecond
+	m, err := notify.NewEmail(cfg)
+	if err != nil {
+		t.Fatalf("NewEmail: %v", err)
+	}
+	return m
+}
+
+// parts returns the content types and bodies of the leaves of msg.
+func parts(t *testing.T, msg string) (subject string, types []string, bodies []string) {
+	t.Helper()
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, _ = new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	var walk func(contentType string, r io.Reader)
+	walk = func(contentType string, r io.Reader) {
+		typ, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatalf("ParseMediaType(%q): %v", contentType, err)
+		}
+		if !strings.HasPrefix(typ, "multipart/") {
+			b, _ := io.ReadAll(r)
+			types, bodies = append(types, typ), append(bodies, string(b))
+			return
+		}
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextPart: %v", err)
+			}
+			walk(p.Header.Get("Content-Type"), p)
+		}
+	}
+	walk(m.Header.Get("Content-Type"), m.Body)
+	return subject, types, bodies
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newSMTPServer(t, 2) // two failed connections are retried
+	m := newTestEmail(t, s, notify.EmailConfig{
+		To:          []string{"home@example.com"},
+		Recipients:  map[string][]string{"digest": {"a@example.com", "b@example.com"}},
+		Subject:     `Gas {{.Kind}}: {{.MeterID}}`,
+		AttachImage: true,
+	})
+	at := time.Date(2025, 11, 17, 7, 0, 0, 0, time.UTC)
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+
+	if err := m.Notify(ctx, notify.Event{Kind: "digest", Severity: notify.Info, MeterID: "home", Time: at, Message: "This week you used 14.3 m³ <€16>."}); err != nil {
+		t.Fatalf("Notify digest: %v", err)
+	}
+	// Warnings are only sent to kinds with their own recipients.
+	if err := m.Notify(ctx, notify.Event{Kind: "anomaly", Severity: notify.Warning, MeterID: "home", Time: at}); err != nil {
+		t.Fatalf("Notify anomaly: %v", err)
+	}
+	if err := m.Notify(ctx, notify.Event{Kind: "leak", Severity: notify.Critical, MeterID: "home", Time: at, Message: "Possible gas leak", Image: png}); err != nil {
+		t.Fatalf("Notify leak: %v", err)
+	}
+
+	rcpts, msgs := s.delivered()
+	if len(msgs) != 2 || strings.Join(rcpts[0], ",") != "a@example.com,b@example.com" || strings.Join(rcpts[1], ",") != "home@example.com" {
+		t.Fatalf("delivered %d messages to %v; want the digest to a and b and the leak to home", len(msgs), rcpts)
+	}
+	subject, types, bodies := parts(t, msgs[0])
+	if subject != "Gas digest: home" || strings.Join(types, ",") != "text/plain,text/html" {
+		t.Fatalf("digest: subject %q, parts %v", subject, types)
+	}
+	if !strings.Contains(bodies[0], "This week you used 14.3 m³ <€16>.") || !strings.Contains(bodies[1], "&lt;€16&gt;") {
+		t.Fatalf("digest bodies = %q; want the message as text and escaped HTML", bodies)
+	}
+	_, types, bodies = parts(t, msgs[1])
+	if strings.Join(types, ",") != "text/plain,text/html,image/png" || !strings.Contains(bodies[2], "iVBORw0K") {
+		t.Fatalf("leak: parts %v; want text, HTML and the image", types)
+	}
+}
+
+func TestEmailAlertInterval(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := newSMTPServer(t, 0)
+	m := newTestEmail(t, s, notify.EmailConfig{To: []string{"home@example.com"}, AlertInterval: 50 * time.Millisecond})
+	leak := notify.Event{Kind: "leak", Severity: notify.Critical, MeterID: "home", Time: time.Now()}
+
+	for range 3 {
+		if err := m.Notify(ctx, leak); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, msgs := s.delivered(); len(msgs) != 1 {
+		t.Fatalf("delivered %d alerts; want 1 within the interval", len(msgs))
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := m.Notify(ctx, leak); err != nil {
+		t.Fatal(err)
+	}
+	_, msgs := s.delivered()
+	if len(msgs) != 2 {
+		t.Fatalf("delivered %d alerts; want 2 after the interval", len(msgs))
+	}
+	if _, _, bodies := parts(t, msgs[1]); !strings.Contains(bodies[0], "2 more leak alerts were suppressed") {
+		t.Fatalf("body = %q; want the suppressed alerts mentioned", bodies[0])
+	}
+}
+
+func TestEmailDeliveryFailure(t *testing.T) {
+	t.Parallel()
+
+	s := newSMTPServer(t, 10)
+	m := newTestEmail(t, s, notify.EmailConfig{To: []string{"home@example.com"}, Retries: 2})
+	err := m.Notify(context.Background(), notify.Event{Kind: "leak", Severity: notify.Critical, MeterID: "home"})
+	if err == nil {
+		t.Fatal("Notify = nil; want the delivery error after the retries")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failConns != 10-3 {
+		t.Fatalf("%d connections; want 3", 10-s.failConns)
+	}
+}
@@ -0,0 +1,86 @@
+// Package notify delivers events such as consumption alerts.
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/numfmt"
+)
+
+// Severity ranks events.
+type Severity string
+
+const (
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+	Critical Severity = "critical"
+)
+
+// Event is something a person may want to be told about.
+type Event struct {
+	Kind     string    `json:"kind"` // e.g. "anomaly"
+	Severity Severity  `json:"severity"`
+	MeterID  string    `json:"meter_id"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+	// Data holds the numbers behind the event, e.g. an [anomaly.Anomaly].
+	Data any `json:"data,omitempty"`
+	// Image is the meter image the event is about, if any.
+	Image []byte `json:"-"`
+	// Localize, if set, renders Message with its numbers in another locale;
+	// see [Localized].
+	Localize func(numfmt.Locale) string `json:"-"`
+}
+
+// Notifier receives events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Func adapts a function to [Notifier].
+type Func func(ctx context.Context, e Event) error
+
+// Notify implements [Notifier].
+func (f Func) Notify(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Log returns a Notifier writing every event to the standard logger.
+func Log() Notifier {
+	return Func(func(_ context.Context, e Event) error {
+		log.Printf("Event %s (%s) for meter %q: %s", e.Kind, e.Severity, e.MeterID, e.Message)
+		return nil
+	})
+}
+
+// Localized returns n sent the messages of events with their numbers in
+// locale l: Message is rendered again by Localize, if set. Only the message
+// is localized, never Data. The zero locale leaves events as they are.
+func Localized(l numfmt.Locale, n Notifier) Notifier {
+	if l == "" {
+		return n
+	}
+	return Func(func(ctx context.Context, e Event) error {
+		if e.Localize != nil {
+			e.Message = e.Localize(l)
+		}
+		return n.Notify(ctx, e)
+	})
+}
+
+// Multi delivers every event to all of its notifiers.
+type Multi []Notifier
+
+// Notify implements [Notifier]; it returns the joined errors of all notifiers.
+func (m Multi) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
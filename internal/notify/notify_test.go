@@ -0,0 +1,83 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/numfmt"
+)
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	errDown := errors.New("smtp down")
+	m := notify.Multi{
+		notify.Func(func(_ context.Context, e notify.Event) error { got = append(got, "a:"+e.Kind); return errDown }),
+		notify.Func(func(_ context.Context, e notify.Event) error { got = append(got, "b:"+e.Kind); return nil }),
+	}
+	err := m.Notify(context.Background(), notify.Event{Kind: "anomaly"})
+	if !errors.Is(err, errDown) || len(got) != 2 || got[1] != "b:anomaly" {
+		t.Fatalf("Notify = %v, delivered %v; want every notifier called and the error returned", err, got)
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	rec := notify.Func(func(_ context.Context, e notify.Event) error { got = append(got, e.Message); return nil })
+	e := notify.Event{Message: "1234.5 m³", Localize: func(l numfmt.Locale) string { return l.Float(1234.5, 1) + " m³" }}
+	ctx := context.Background()
+	notify.Localized("de", rec).Notify(ctx, e)
+	notify.Localized("", rec).Notify(ctx, e)
+	notify.Localized("de", rec).Notify(ctx, notify.Event{Message: "plain"})
+	if want := []string{"1.234,5 m³", "1234.5 m³", "plain"}; !slices.Equal(got, want) {
+		t.Fatalf("messages = %q, want %q", got, want)
+	}
+	if _, err := notify.New(notify.Config{Name: notify.LogName, NumberLocale: "tlh"}); err == nil {
+		t.Fatal("New with an unknown number_locale: no error")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	rec := &genaitest.Notifier{}
+	notify.Register("recorder", rec.Factory())
+	if !slices.Contains(notify.Names(), "recorder") || !slices.Contains(notify.Names(), notify.EmailName) {
+		t.Fatalf("Names = %v", notify.Names())
+	}
+	n, err := notify.New(notify.Config{Name: "recorder", ID: "ops"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := n.Notify(context.Background(), notify.Event{Kind: "digest"}); err != nil || !slices.Equal(rec.Kinds(), []string{"digest"}) {
+		t.Fatalf("Notify = %v, recorded %v", err, rec.Kinds())
+	}
+
+	email := notify.Options{"host": "smtp.example.com", "from": "meter@example.com", "to": []any{"me@example.com"}, "alert_interval": "1h"}
+	if _, err := notify.New(notify.Config{Name: notify.EmailName, Options: email}); err != nil {
+		t.Fatalf("New email: %v", err)
+	}
+	for _, c := range []notify.Config{
+		{Name: "pigeon"},
+		{Name: notify.LogName, Options: notify.Options{"level": "debug"}},
+		{Name: notify.EmailName, Options: notify.Options{"host": "smtp.example.com"}},
+		{Name: notify.EmailName, Options: notify.Options{"hots": "smtp.example.com", "from": "meter@example.com", "to": []any{"me@example.com"}}},
+	} {
+		if _, err := notify.New(c); err == nil {
+			t.Fatalf("New(%+v) succeeded", c)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Register of a taken name did not panic")
+		}
+	}()
+	notify.Register(notify.LogName, rec.Factory())
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"github.com/suapapa/mqvision/internal/numfmt"
+)
+
+// Names of the built-in notifiers.
+const (
+	LogName   = "log"   // the standard logger; no options
+	EmailName = "email" // SMTP; the options are an [EmailConfig]
+)
+
+// Options are the settings of a notifier as listed in the config file.
+type Options map[string]any
+
+// Decode decodes o into v, a pointer to a struct with yaml tags; unknown
+// options are an error.
+func (o Options) Decode(v any) error {
+	b, err := yaml.Marshal(map[string]any(o))
+	if err != nil {
+		return err
+	}
+	if err := yaml.UnmarshalWithOptions(b, v, yaml.DisallowUnknownField()); err != nil {
+		// Drop the position, which is in the re-encoded options.
+		msg := err.Error()
+		if _, rest, ok := strings.Cut(msg, "] "); ok && strings.HasPrefix(msg, "[") {
+			msg = rest
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// Factory builds a notifier from its options. It should check them without
+// connecting anywhere: configs are checked by building their notifiers.
+type Factory func(o Options) (Notifier, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a notifier available under name; it panics if name is
+// taken, like the built-ins registered by this package.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("notify: Register called twice for " + name)
+	}
+	factories[name] = f
+}
+
+// Names returns the registered notifiers, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config is a notifier as listed in the config file.
+type Config struct {
+	// Name is the registered notifier.
+	Name string `yaml:"name"`
+	// ID tells notifiers of the same name apart, e.g. in subscriptions
+	// (default Name).
+	ID      string  `yaml:"id"`
+	Options Options `yaml:"options"`
+	// NumberLocale, if set, formats the numbers of the messages sent to the
+	// notifier instead of the number_locale of the config; see [Localized].
+	NumberLocale string `yaml:"number_locale"`
+	// Network is given to the built-in notifiers that connect to their
+	// servers themselves, such as email.
+	Network Network `yaml:"-"`
+}
+
+// Network is how a notifier reaches its server.
+type Network struct {
+	// Dial connects, e.g. through a proxy; nil is a net.Dialer. TLS is
+	// layered on its connections.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// TLS has the CAs trusted, besides or instead of the system's, and the
+	// client certificate; the notifier sets the server name.
+	TLS *tls.Config
+}
+
+// networked is implemented by the notifiers taking a [Network].
+type networked interface {
+	useNetwork(Network)
+}
+
+// Key returns the ID of c, or its Name without one.
+func (c Config) Key() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.Name
+}
+
+// New builds the notifier of c.
+func New(c Config) (Notifier, error) {
+	mu.RLock()
+	f, ok := factories[c.Name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier %q, want one of %s", c.Name, strings.Join(Names(), ", "))
+	}
+	if _, err := numfmt.Parse(c.NumberLocale); err != nil {
+		return nil, fmt.Errorf("%s: %w", c.Key(), err)
+	}
+	n, err := f(c.Options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.Key(), err)
+	}
+	if nn, ok := n.(networked); ok {
+		nn.useNetwork(c.Network)
+	}
+	return n, nil
+}
+
+func init() {
+	Register(LogName, func(o Options) (Notifier, error) {
+		if len(o) > 0 {
+			return nil, errors.New("takes no options")
+		}
+		return Log(), nil
+	})
+	Register(EmailName, func(o Options) (Notifier, error) {
+		var cfg EmailConfig
+		if err := o.Decode(&cfg); err != nil {
+			return nil, err
+		}
+		return NewEmail(cfg)
+	})
+}
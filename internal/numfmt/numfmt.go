@@ -0,0 +1,75 @@
+// Package numfmt formats numbers for people, with the separators of a
+// locale: "1.234,567" in German. It is for what people read only, such as
+// reports, notifications, the dashboard and the tables of the commands;
+// stored values and the payloads of sinks and the API keep their
+// locale-independent format.
+package numfmt
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale is the language of a number format, e.g. "de". The zero Locale
+// formats as strconv does, without grouping.
+type Locale string
+
+// separators are the group and decimal separators of the locales.
+var separators = map[Locale][2]string{
+	"en": {",", "."},
+	"ko": {",", "."},
+	"ja": {",", "."},
+	"de": {".", ","},
+	"fr": {"\u202f", ","}, // a narrow no-break space
+}
+
+// Locales returns the supported locales, sorted.
+func Locales() []string {
+	names := make([]string, 0, len(separators))
+	for l := range separators {
+		names = append(names, string(l))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse returns the locale of the language tag s, e.g. "de" of "de-AT";
+// only the language counts. An empty s is the zero Locale.
+func Parse(s string) (Locale, error) {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(s, "_", "-"), "-")
+	l := Locale(strings.ToLower(lang))
+	if _, ok := separators[l]; !ok && l != "" {
+		return "", fmt.Errorf("unsupported number locale %q, want one of %s", s, strings.Join(Locales(), ", "))
+	}
+	return l, nil
+}
+
+// Float formats v with decimals digits after the separator, e.g.
+// "1.234,567" for 1234.567 and 3 in German.
+func (l Locale) Float(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sep, ok := separators[l]
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i := range len(intPart) {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sep[0])
+		}
+		b.WriteByte(intPart[i])
+	}
+	if frac != "" {
+		b.WriteString(sep[1] + frac)
+	}
+	return b.String()
+}
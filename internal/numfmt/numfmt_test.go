@@ -0,0 +1,57 @@
+package numfmt_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/numfmt"
+)
+
+func TestFloat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		locale   numfmt.Locale
+		v        float64
+		decimals int
+		want     string
+	}{
+		{"", 1234.567, 3, "1234.567"},
+		{"en", 1234.567, 3, "1,234.567"},
+		{"de", 1234.567, 3, "1.234,567"},
+		{"de", 1234567.5, 1, "1.234.567,5"},
+		{"fr", 1234.5, 1, "1\u202f234,5"},
+		{"de", 14.25, 0, "14"},
+		{"de", 999.96, 1, "1.000,0"},
+		{"de", -1234.5, 1, "-1.234,5"},
+		{"de", 0.4, 0, "0"},
+		{"de", math.NaN(), 1, "NaN"},
+	}
+	for _, tt := range tests {
+		if got := tt.locale.Float(tt.v, tt.decimals); got != tt.want {
+			t.Errorf("%q.Float(%v, %d) = %q, want %q", tt.locale, tt.v, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s       string
+		want    numfmt.Locale
+		wantErr bool
+	}{
+		{"", "", false},
+		{"de", "de", false},
+		{"de-AT", "de", false},
+		{"DE_de", "de", false},
+		{"ko-KR", "ko", false},
+		{"tlh", "", true},
+	}
+	for _, tt := range tests {
+		if got, err := numfmt.Parse(tt.s); got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) = %q, %v; want %q, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package ocr reads meter images without a model, as a cross-check of the
+// model's reading: a second opinion from a recognizer that fails
+// differently. The built-in recognizer reads the seven-segment digits of
+// digital meters in pure Go, so it builds wherever the daemon does,
+// including on a Raspberry Pi; others can be registered.
+package ocr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Recognizer reads a meter image.
+type Recognizer interface {
+	// Recognize returns the reading of the JPEG img, with "?" for digits it
+	// could not tell, and its confidence in it, from 0 to 1.
+	Recognize(img []byte) (string, float64, error)
+}
+
+// ErrNoDigits is returned by recognizers finding no digits in an image.
+var ErrNoDigits = errors.New("no digits found")
+
+// DefaultMinConfidence is the default [Config.MinConfidence].
+const DefaultMinConfidence = 0.5
+
+// Config is the cross-check as set in the config file.
+type Config struct {
+	// Recognizer is the registered recognizer to read with (default
+	// [SevenSegmentName]).
+	Recognizer string `yaml:"recognizer"`
+	// Box is where the display is in the image (default: the whole image).
+	Box *genai.Box `yaml:"box"`
+	// Invert is for displays with lit segments brighter than their
+	// background, such as LEDs; the default is dark segments on an LCD.
+	Invert bool `yaml:"invert"`
+	// MinConfidence is the confidence below which the reading of the
+	// recognizer is not checked against (default [DefaultMinConfidence]).
+	MinConfidence float64 `yaml:"min_confidence"`
+	// Verify reads the image again with self-verification when the two
+	// readings disagree; see [genai.ContextWithSelfVerify].
+	Verify bool `yaml:"verify"`
+}
+
+// Name returns c.Recognizer or its default.
+func (c Config) Name() string {
+	if c.Recognizer == "" {
+		return SevenSegmentName
+	}
+	return c.Recognizer
+}
+
+// Threshold returns c.MinConfidence or its default.
+func (c Config) Threshold() float64 {
+	if c.MinConfidence == 0 {
+		return DefaultMinConfidence
+	}
+	return c.MinConfidence
+}
+
+// Validate checks the settings.
+func (c Config) Validate() error {
+	mu.RLock()
+	_, ok := factories[c.Name()]
+	mu.RUnlock()
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown recognizer %q, want one of %s", c.Recognizer, strings.Join(Names(), ", "))
+	case c.Box != nil && !c.Box.Valid():
+		return fmt.Errorf("box %+v is not within the image", *c.Box)
+	case c.MinConfidence < 0 || c.MinConfidence > 1:
+		return fmt.Errorf("min_confidence must be 0 to 1")
+	}
+	return nil
+}
+
+// New returns the recognizer of c for meter m.
+func New(m genai.Meter, c Config) (Recognizer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	f := factories[c.Name()]
+	mu.RUnlock()
+	return f(m, c)
+}
+
+// Check reads img with r and returns the check of c against the model's
+// reading: a reading less confident than the threshold is left out, with
+// the reason in Error.
+func Check(r Recognizer, c Config, img []byte) *genai.CrossCheck {
+	cc := &genai.CrossCheck{Recognizer: c.Name()}
+	read, conf, err := r.Recognize(img)
+	switch {
+	case err != nil:
+		cc.Error = err.Error()
+	case conf < c.Threshold():
+		cc.Error = fmt.Sprintf("read %s with confidence %.2f, below %.2f", read, conf, c.Threshold())
+	default:
+		cc.Read = read
+	}
+	cc.Confidence = conf
+	return cc
+}
+
+// Factory builds the recognizer of c for meter m.
+type Factory func(m genai.Meter, c Config) (Recognizer, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a recognizer available under name; it panics if name is
+// taken, like the built-in registered by this package.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("ocr: Register called twice for " + name)
+	}
+	factories[name] = f
+}
+
+// Names returns the registered recognizers, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
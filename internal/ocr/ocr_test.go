@@ -0,0 +1,143 @@
+package ocr_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/ocr"
+)
+
+// segments are the lit segments of each digit, as in the usual a to g
+// lettering: a at the top, then clockwise, g in the middle.
+var segments = map[rune]string{
+	'0': "abcdef", '1': "bc", '2': "abdeg", '3': "abcdg", '4': "bcfg",
+	'5': "acdfg", '6': "acdefg", '7': "abc", '8': "abcdefg", '9': "abcdfg",
+	'H': "bcefg", // not a digit
+}
+
+// render draws read as a seven-segment display on a light LCD, or lit
+// segments on a dark one, within a margin of background.
+func render(t *testing.T, read string, invert bool) []byte {
+	t.Helper()
+	const w, h, th, gap, margin = 40, 70, 10, 16, 30
+	bg, fg := color.Gray{Y: 190}, color.Gray{Y: 40}
+	if invert {
+		bg, fg = fg, bg
+	}
+	img := image.NewGray(image.Rect(0, 0, 2*margin+len(read)*(w+gap), h+2*margin))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	fill := func(x0, y0, x1, y1 int) {
+		draw.Draw(img, image.Rect(x0, y0, x1, y1), image.NewUniform(fg), image.Point{}, draw.Src)
+	}
+	x := margin
+	for _, r := range read {
+		y := margin
+		if r == '.' {
+			fill(x-gap/2-4, y+h-8, x-gap/2+4, y+h)
+			continue
+		}
+		for _, s := range segments[r] {
+			switch s {
+			case 'a':
+				fill(x+th, y, x+w-th, y+th)
+			case 'b':
+				fill(x+w-th, y+th, x+w, y+h/2)
+			case 'c':
+				fill(x+w-th, y+h/2, x+w, y+h-th)
+			case 'd':
+				fill(x+th, y+h-th, x+w-th, y+h)
+			case 'e':
+				fill(x, y+h/2, x+th, y+h-th)
+			case 'f':
+				fill(x, y+th, x+th, y+h/2)
+			case 'g':
+				fill(x+th, y+h/2-th/2, x+w-th, y+h/2+th/2)
+			}
+		}
+		x += w + gap
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSevenSegment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		show   string
+		invert bool
+		digits int
+		want   string
+	}{
+		{"all digits", "0123456789", false, 0, "0123456789"},
+		{"decimal point", "02924.457", false, 8, "02924.457"},
+		{"lit segments", "17.40", true, 0, "17.40"},
+		{"not a digit", "12H4", false, 0, "12?4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := &ocr.SevenSegment{Invert: tt.invert, Digits: tt.digits}
+			read, conf, err := s.Recognize(render(t, tt.show, tt.invert))
+			if err != nil || read != tt.want {
+				t.Fatalf("Recognize = %q, %.2f, %v; want %q", read, conf, err, tt.want)
+			}
+			if strings.Contains(tt.want, "?") != (conf < 0.8) {
+				t.Fatalf("Recognize %q with confidence %.2f", read, conf)
+			}
+		})
+	}
+}
+
+func TestSevenSegmentErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, _, err := (&ocr.SevenSegment{Digits: 8}).Recognize(render(t, "2924.457", false)); err == nil || !strings.Contains(err.Error(), "found 7 digits, want 8") {
+		t.Fatalf("Recognize with a digit short = %v", err)
+	}
+	if _, _, err := (&ocr.SevenSegment{}).Recognize(render(t, "", false)); !errors.Is(err, ocr.ErrNoDigits) {
+		t.Fatalf("Recognize of a blank display = %v, want ErrNoDigits", err)
+	}
+	// The box leaves the display out.
+	box := &genai.Box{XMin: 0, YMin: 0, XMax: 0.05, YMax: 0.2}
+	if _, _, err := (&ocr.SevenSegment{Box: box}).Recognize(render(t, "42", false)); !errors.Is(err, ocr.ErrNoDigits) {
+		t.Fatalf("Recognize outside the display = %v, want ErrNoDigits", err)
+	}
+}
+
+// fixed is a recognizer with a fixed answer.
+type fixed struct {
+	read string
+	conf float64
+}
+
+func (f fixed) Recognize([]byte) (string, float64, error) { return f.read, f.conf, nil }
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	c := ocr.Config{}
+	if cc := ocr.Check(fixed{"02924457", 0.9}, c, nil); cc.Read != "02924457" || cc.Error != "" || cc.Recognizer != ocr.SevenSegmentName {
+		t.Fatalf("Check = %+v", cc)
+	}
+	if cc := ocr.Check(fixed{"02924457", 0.3}, c, nil); cc.Read != "" || cc.Error != "read 02924457 with confidence 0.30, below 0.50" {
+		t.Fatalf("Check of an unsure reading = %+v", cc)
+	}
+	if err := (ocr.Config{Recognizer: "tesseract"}).Validate(); err == nil {
+		t.Fatalf("Validate accepted an unknown recognizer")
+	}
+	if _, err := ocr.New(genai.Meter{Type: genai.MeterDials}, c); err == nil {
+		t.Fatalf("New accepted a dials meter")
+	}
+}
@@ -0,0 +1,363 @@
+package ocr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"slices"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// SevenSegmentName is the name of the built-in recognizer.
+const SevenSegmentName = "seven_segment"
+
+func init() {
+	Register(SevenSegmentName, func(m genai.Meter, c Config) (Recognizer, error) {
+		if m.Type == genai.MeterDials {
+			return nil, errors.New("reads digital displays, not dials")
+		}
+		return &SevenSegment{Box: c.Box, Invert: c.Invert, Digits: m.IntDigits + m.FracDigits}, nil
+	})
+}
+
+// maxWidth is the width the display is scaled down to, at most: plenty for
+// segments, and quick on a Raspberry Pi.
+const maxWidth = 640
+
+// SevenSegment reads the upright seven-segment digits of a digital display,
+// and its decimal point. The display is thresholded into segments and
+// background, split into digits at the columns without segments and every
+// digit told by which of its seven segments are lit. Slanted digits and
+// displays with anything else in the box, such as unit labels, read worse.
+type SevenSegment struct {
+	// Box is where the display is in the image; nil is the whole image.
+	Box *genai.Box
+	// Invert is for lit segments brighter than the background.
+	Invert bool
+	// Digits is the number of digits of the display; 0 takes any.
+	Digits int
+}
+
+// Recognize implements [Recognizer]. The confidence is that of the least
+// clear segment of the digits, less the share of them not recognized.
+func (s *SevenSegment) Recognize(img []byte) (string, float64, error) {
+	src, err := jpeg.Decode(bytes.NewReader(img))
+	if err != nil {
+		return "", 0, fmt.Errorf("decode image: %w", err)
+	}
+	ink, err := binarize(gray(src, s.Box), s.Invert)
+	if err != nil {
+		return "", 0, err
+	}
+	glyphs := ink.glyphs()
+	var (
+		read       []byte
+		digits, ok int
+		conf       = 1.0
+	)
+	for _, g := range glyphs {
+		if g.point {
+			read = append(read, '.')
+			continue
+		}
+		digits++
+		d, c := ink.digit(g)
+		read = append(read, d)
+		if d != '?' {
+			ok++
+			conf = min(conf, c)
+		}
+	}
+	switch {
+	case digits == 0:
+		return "", 0, ErrNoDigits
+	case s.Digits > 0 && digits != s.Digits:
+		return string(read), 0, fmt.Errorf("found %d digits, want %d: %s", digits, s.Digits, read)
+	case ok == 0:
+		return string(read), 0, nil
+	}
+	return string(read), conf * float64(ok) / float64(digits), nil
+}
+
+// gray returns the part of src in box, or all of it, in grays, scaled down
+// to at most maxWidth.
+func gray(src image.Image, box *genai.Box) *image.Gray {
+	r := src.Bounds()
+	if box != nil {
+		w, h := float64(r.Dx()), float64(r.Dy())
+		r = image.Rect(r.Min.X+int(box.XMin*w), r.Min.Y+int(box.YMin*h), r.Min.X+int(box.XMax*w), r.Min.Y+int(box.YMax*h)).Intersect(src.Bounds())
+	}
+	step := max(1, (r.Dx()+maxWidth-1)/maxWidth)
+	g := image.NewGray(image.Rect(0, 0, r.Dx()/step, r.Dy()/step))
+	for y := range g.Rect.Dy() {
+		for x := range g.Rect.Dx() {
+			c := color.GrayModel.Convert(src.At(r.Min.X+x*step+step/2, r.Min.Y+y*step+step/2)).(color.Gray)
+			g.Pix[y*g.Stride+x] = c.Y
+		}
+	}
+	return g
+}
+
+// minContrast is the least difference of the mean grays of segments and
+// background for a display to be read at all.
+const minContrast = 32
+
+// bitmap marks the pixels of segments.
+type bitmap struct {
+	w, h int
+	px   []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	return 0 <= x && x < b.w && 0 <= y && y < b.h && b.px[y*b.w+x]
+}
+
+// binarize thresholds g with Otsu's method into segments, darker than the
+// background or, inverted, brighter.
+func binarize(g *image.Gray, invert bool) (*bitmap, error) {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	if w == 0 || h == 0 {
+		return nil, ErrNoDigits
+	}
+	var hist [256]int
+	for y := range h {
+		for _, v := range g.Pix[y*g.Stride : y*g.Stride+w] {
+			hist[v]++
+		}
+	}
+	total := w * h
+	var sum float64
+	for v, n := range hist {
+		sum += float64(v * n)
+	}
+	var (
+		best, sumLow      float64
+		threshold, low    int
+		lowMean, highMean float64
+	)
+	for t := range 255 {
+		low += hist[t]
+		sumLow += float64(t * hist[t])
+		if low == 0 || low == total {
+			continue
+		}
+		ml, mh := sumLow/float64(low), (sum-sumLow)/float64(total-low)
+		if v := float64(low) * float64(total-low) * (ml - mh) * (ml - mh); v > best {
+			best, threshold, lowMean, highMean = v, t, ml, mh
+		}
+	}
+	if highMean-lowMean < minContrast {
+		return nil, fmt.Errorf("%w: no contrast", ErrNoDigits)
+	}
+	b := &bitmap{w: w, h: h, px: make([]bool, w*h)}
+	for y := range h {
+		for x := range w {
+			v := int(g.Pix[y*g.Stride+x])
+			b.px[y*w+x] = v <= threshold != invert
+		}
+	}
+	return b, nil
+}
+
+// glyph is a digit or a decimal point of the display: an extent of columns
+// with segments and the rows spanned.
+type glyph struct {
+	x0, x1, y0, y1 int // inclusive
+	point          bool
+}
+
+func (g glyph) width() int  { return g.x1 - g.x0 + 1 }
+func (g glyph) height() int { return g.y1 - g.y0 + 1 }
+
+// glyphs splits b into glyphs at the columns without segments, left to
+// right. Blobs much smaller than the digits are dropped as noise, but for
+// those low enough to be a decimal point.
+func (b *bitmap) glyphs() []glyph {
+	minInk := max(1, b.h/50)
+	var runs []glyph
+	for x := 0; x < b.w; {
+		if b.column(x, 0, b.h-1) < minInk {
+			x++
+			continue
+		}
+		g := glyph{x0: x}
+		for x < b.w && b.column(x, 0, b.h-1) >= minInk {
+			x++
+		}
+		g.x1 = x - 1
+		g.y0, g.y1 = -1, -1
+		for y := range b.h {
+			if b.row(y, g.x0, g.x1) > 0 {
+				if g.y0 < 0 {
+					g.y0 = y
+				}
+				g.y1 = y
+			}
+		}
+		runs = append(runs, g)
+	}
+	tallest := 0
+	for _, g := range runs {
+		tallest = max(tallest, g.height())
+	}
+
+	// The digits span the same rows, those of the digits of full height: a
+	// "1" or a "4" has no top segment.
+	var tops, bottoms []int
+	for _, g := range runs {
+		if 10*g.height() >= 9*tallest {
+			tops, bottoms = append(tops, g.y0), append(bottoms, g.y1)
+		}
+	}
+	if len(tops) == 0 {
+		return nil
+	}
+	slices.Sort(tops)
+	slices.Sort(bottoms)
+	top, bottom := tops[len(tops)/2], bottoms[len(bottoms)/2]
+	height := bottom - top + 1
+
+	var out []glyph
+	for _, g := range runs {
+		switch {
+		case 2*g.height() >= tallest:
+			g.y0, g.y1 = top, bottom
+			out = append(out, g)
+		case 4*g.height() <= height && 3*g.width() <= height && bottom-g.y1 <= height/5:
+			g.point = true
+			out = append(out, g)
+		}
+	}
+	// A digit without its left segments, such as a "1" or a "7", only
+	// spans the right of its cell.
+	width := int(0.4 * float64(height))
+	for _, g := range out {
+		if !g.point {
+			width = max(width, g.width())
+		}
+	}
+	for i, g := range out {
+		if !g.point && 10*g.width() < 9*width {
+			out[i].x0 = g.x1 - width + 1
+		}
+	}
+	return out
+}
+
+// column counts the segment pixels of column x in rows y0 to y1.
+func (b *bitmap) column(x, y0, y1 int) int {
+	n := 0
+	for y := y0; y <= y1; y++ {
+		if b.at(x, y) {
+			n++
+		}
+	}
+	return n
+}
+
+// row counts the segment pixels of row y in columns x0 to x1.
+func (b *bitmap) row(y, x0, x1 int) int {
+	n := 0
+	for x := x0; x <= x1; x++ {
+		if b.at(x, y) {
+			n++
+		}
+	}
+	return n
+}
+
+// Segments, as bits: a is the top one, then clockwise, g the middle one.
+const (
+	segA = 1 << iota
+	segB
+	segC
+	segD
+	segE
+	segF
+	segG
+)
+
+// digits are the digits by their lit segments, with the variants of 6, 7
+// and 9 some displays use.
+var digits = map[int]byte{
+	segA | segB | segC | segD | segE | segF:        '0',
+	segB | segC:                                    '1',
+	segA | segB | segD | segE | segG:               '2',
+	segA | segB | segC | segD | segG:               '3',
+	segB | segC | segF | segG:                      '4',
+	segA | segC | segD | segF | segG:               '5',
+	segA | segC | segD | segE | segF | segG:        '6',
+	segC | segD | segE | segF | segG:               '6',
+	segA | segB | segC:                             '7',
+	segA | segB | segC | segF:                      '7',
+	segA | segB | segC | segD | segE | segF | segG: '8',
+	segA | segB | segC | segD | segF | segG:        '9',
+	segA | segB | segC | segF | segG:               '9',
+}
+
+// scans are where each segment is looked for, as lines across it in the
+// fractions of a digit's cell: x0, y0 to x1, y1.
+var scans = [7][4]float64{
+	{0.5, 0, 0.5, 0.3},     // a
+	{0.5, 0.25, 1, 0.25},   // b
+	{0.5, 0.75, 1, 0.75},   // c
+	{0.5, 0.7, 0.5, 1},     // d
+	{0, 0.75, 0.5, 0.75},   // e
+	{0, 0.25, 0.5, 0.25},   // f
+	{0.5, 0.35, 0.5, 0.65}, // g
+}
+
+// lit is the share of a scan line across a segment that must be segment
+// pixels for it to be lit; a lit segment covers a third to a half of it.
+const lit = 0.15
+
+// digit tells the digit of g and the confidence in it, "?" if its segments
+// make no digit.
+func (b *bitmap) digit(g glyph) (byte, float64) {
+	pattern, conf := 0, 1.0
+	for i, s := range scans {
+		fill := b.scan(g, s)
+		if fill >= lit {
+			pattern |= 1 << i
+		}
+		conf = min(conf, math.Min(1, math.Abs(fill-lit)/lit))
+	}
+	d, ok := digits[pattern]
+	if !ok {
+		return '?', 0
+	}
+	return d, conf
+}
+
+// scan returns the share of segment pixels along the line s across g,
+// averaged over it and two lines beside it.
+func (b *bitmap) scan(g glyph, s [4]float64) float64 {
+	w, h := float64(g.width()), float64(g.height())
+	x0, y0 := float64(g.x0)+s[0]*(w-1), float64(g.y0)+s[1]*(h-1)
+	x1, y1 := float64(g.x0)+s[2]*(w-1), float64(g.y0)+s[3]*(h-1)
+	// The lines beside are offset across the line: sideways for a vertical
+	// scan, up and down for a horizontal one.
+	dx, dy := 0.0, 0.1*h
+	if x0 == x1 {
+		dx, dy = 0.1*w, 0
+	}
+	n := max(8, int(math.Hypot(x1-x0, y1-y0)))
+	var hits, total int
+	for _, off := range []float64{-1, 0, 1} {
+		for i := range n + 1 {
+			t := float64(i) / float64(n)
+			x := x0 + t*(x1-x0) + off*dx
+			y := y0 + t*(y1-y0) + off*dy
+			if b.at(int(math.Round(x)), int(math.Round(y))) {
+				hits++
+			}
+			total++
+		}
+	}
+	return float64(hits) / float64(total)
+}
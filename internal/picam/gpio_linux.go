@@ -0,0 +1,107 @@
+package picam
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The GPIO v2 character device ABI of linux/gpio.h.
+const (
+	gpioV2GetLineIoctl      = 0xc250b407 // _IOWR(0xB4, 0x07, struct gpio_v2_line_request)
+	gpioV2SetValuesIoctl    = 0xc010b40f // _IOWR(0xB4, 0x0F, struct gpio_v2_line_values)
+	gpioV2LineFlagActiveLow = 1 << 1
+	gpioV2LineFlagOutput    = 1 << 3
+	gpioV2LinesMax          = 64
+	gpioMaxNameSize         = 32
+	gpioV2LineNumAttrsMax   = 10
+	gpioV2LineRequestSize   = 592
+	gpioV2LineValuesSize    = 16
+)
+
+type gpioV2LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [gpioV2LineNumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [gpioV2LinesMax]uint32
+	Consumer        [gpioMaxNameSize]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+// Guard the layout the ioctl numbers encode.
+var (
+	_ [gpioV2LineRequestSize - unsafe.Sizeof(gpioV2LineRequest{})]struct{}
+	_ [unsafe.Sizeof(gpioV2LineRequest{}) - gpioV2LineRequestSize]struct{}
+	_ [gpioV2LineValuesSize - unsafe.Sizeof(gpioV2LineValues{})]struct{}
+)
+
+// chipLine is a line requested from a GPIO character device; the kernel
+// releases it, and drives it back to its default, when its fd is closed.
+type chipLine struct {
+	f *os.File
+}
+
+func openChipLine(chip string, line int, activeLow bool) (Pin, error) {
+	f, err := os.OpenFile(chip, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open gpio chip: %w", err)
+	}
+	defer f.Close()
+
+	req := gpioV2LineRequest{NumLines: 1}
+	req.Offsets[0] = uint32(line)
+	copy(req.Consumer[:], "mqvision")
+	req.Config.Flags = gpioV2LineFlagOutput
+	if activeLow {
+		req.Config.Flags |= gpioV2LineFlagActiveLow
+	}
+	if err := ioctl(f.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("request gpio line %d of %s: %w", line, chip, err)
+	}
+	return &chipLine{f: os.NewFile(uintptr(req.Fd), fmt.Sprintf("%s line %d", chip, line))}, nil
+}
+
+func (l *chipLine) Set(on bool) error {
+	v := gpioV2LineValues{Mask: 1}
+	if on {
+		v.Bits = 1
+	}
+	if err := ioctl(l.f.Fd(), gpioV2SetValuesIoctl, unsafe.Pointer(&v)); err != nil {
+		return fmt.Errorf("set gpio: %w", err)
+	}
+	return nil
+}
+
+func (l *chipLine) Close() error { return l.f.Close() }
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
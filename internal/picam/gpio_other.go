@@ -0,0 +1,9 @@
+//go:build !linux
+
+package picam
+
+import "fmt"
+
+func openChipLine(chip string, line int, activeLow bool) (Pin, error) {
+	return nil, fmt.Errorf("gpio chip %s: needs Linux; use sysfs", chip)
+}
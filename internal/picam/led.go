@@ -0,0 +1,77 @@
+package picam
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Pin is a GPIO output line.
+type Pin interface {
+	// Set drives the line active (on) or inactive.
+	Set(on bool) error
+	Close() error
+}
+
+// LEDConfig is the GPIO line of an LED lighting the meter, either on a GPIO
+// character device or through the older sysfs interface.
+type LEDConfig struct {
+	// Chip and Line are the character device, e.g. "gpiochip0" or
+	// "/dev/gpiochip0", and the offset of the line on it, which on a
+	// Raspberry Pi is the BCM number of the pin.
+	Chip string `yaml:"chip"`
+	Line int    `yaml:"line"`
+	// Sysfs is instead the value file of a line already exported as an
+	// output, e.g. "/sys/class/gpio/gpio17/value".
+	Sysfs string `yaml:"sysfs"`
+	// ActiveLow drives the line low to turn the LED on.
+	ActiveLow bool `yaml:"active_low"`
+}
+
+// Validate checks that exactly one interface is set.
+func (c LEDConfig) Validate() error {
+	switch {
+	case c.Chip == "" && c.Sysfs == "":
+		return fmt.Errorf("needs chip and line, or sysfs")
+	case c.Chip != "" && c.Sysfs != "":
+		return fmt.Errorf("chip and sysfs are exclusive")
+	case c.Line < 0:
+		return fmt.Errorf("line must not be negative")
+	}
+	return nil
+}
+
+// Open requests the line as an output with the LED off.
+func (c LEDConfig) Open() (Pin, error) {
+	if c.Sysfs != "" {
+		p := &sysfsPin{path: c.Sysfs, activeLow: c.ActiveLow}
+		if err := p.Set(false); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	chip := c.Chip
+	if !strings.HasPrefix(chip, "/") {
+		chip = "/dev/" + chip
+	}
+	return openChipLine(chip, c.Line, c.ActiveLow)
+}
+
+// sysfsPin is a line written through its sysfs value file.
+type sysfsPin struct {
+	path      string
+	activeLow bool
+}
+
+func (p *sysfsPin) Set(on bool) error {
+	v := "0"
+	if on != p.activeLow {
+		v = "1"
+	}
+	if err := os.WriteFile(p.path, []byte(v), 0); err != nil {
+		return fmt.Errorf("write gpio: %w", err)
+	}
+	return nil
+}
+
+func (p *sysfsPin) Close() error { return nil }
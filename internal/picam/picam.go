@@ -0,0 +1,281 @@
+// Package picam captures meter images with a Raspberry Pi camera through the
+// libcamera still app, rpicam-still or libcamera-still as it was called
+// before Bookworm. The exposure is fixed rather than left to the automatic
+// exposure, which a dark meter box with a bright LCD easily fools, and an
+// LED wired to a GPIO line can light the meter for the capture only.
+package picam
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a [Config].
+const (
+	DefaultWarmUp  = time.Second
+	DefaultTimeout = 30 * time.Second
+)
+
+// Commands are the camera apps tried when none is configured, in order.
+var Commands = []string{"rpicam-still", "libcamera-still"}
+
+var (
+	// ErrNotInstalled is returned when no camera app is found.
+	ErrNotInstalled = errors.New("libcamera still app not installed")
+	// ErrTimeout is returned when a capture takes longer than the timeout.
+	ErrTimeout = errors.New("camera capture timed out")
+	// ErrCapture is matched by every [*CaptureError].
+	ErrCapture = errors.New("camera capture failed")
+)
+
+// CaptureError is a capture the camera app failed, such as with the camera
+// missing or in use by another process.
+type CaptureError struct {
+	Command  string
+	ExitCode int    // -1 if the app exited cleanly but sent no JPEG
+	Stderr   string // the last lines the app printed
+}
+
+func (e *CaptureError) Error() string {
+	if e.ExitCode < 0 {
+		return fmt.Sprintf("%s sent no JPEG image: %s", e.Command, e.Stderr)
+	}
+	return fmt.Sprintf("%s exited with %d: %s", e.Command, e.ExitCode, e.Stderr)
+}
+
+// Is makes a CaptureError match [ErrCapture].
+func (e *CaptureError) Is(target error) bool { return target == ErrCapture }
+
+// Exposure is the exposure of a capture; zero fields are left to the
+// automatic exposure.
+type Exposure struct {
+	Shutter time.Duration
+	Gain    float64
+}
+
+func (e Exposure) String() string {
+	s := "auto"
+	if e.Shutter > 0 {
+		s = e.Shutter.String()
+	}
+	if e.Gain > 0 {
+		s += " gain " + strconv.FormatFloat(e.Gain, 'g', -1, 64)
+	}
+	return s
+}
+
+// Config is a camera as set in the config file.
+type Config struct {
+	// Command is the camera app (default: the first of [Commands] found).
+	Command string `yaml:"command"`
+	// Width and Height are the resolution of the images (default: the
+	// full resolution of the sensor).
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+	// Shutter and Gain fix the exposure time and the analogue gain; either
+	// left out is automatic.
+	Shutter time.Duration `yaml:"shutter"`
+	Gain    float64       `yaml:"gain"`
+	// Quality is the JPEG quality, 1 to 100 (default: the app's, 93).
+	Quality int `yaml:"quality"`
+	// WarmUp is how long the camera runs before the capture, letting the
+	// white balance (and the exposure, if automatic) settle; default
+	// [DefaultWarmUp].
+	WarmUp time.Duration `yaml:"warm_up"`
+	// Timeout bounds a whole capture (default [DefaultTimeout]).
+	Timeout time.Duration `yaml:"timeout"`
+	// Args are passed to the app after the others, e.g. "--rotation", "180".
+	Args []string `yaml:"args"`
+	// LED, if set, lights the meter for the capture.
+	LED *LEDConfig `yaml:"led"`
+}
+
+// Validate checks the settings.
+func (c Config) Validate() error {
+	switch {
+	case c.Width < 0 || c.Height < 0:
+		return fmt.Errorf("width and height must not be negative")
+	case c.Shutter < 0 || c.Gain < 0:
+		return fmt.Errorf("shutter and gain must not be negative")
+	case c.Quality < 0 || c.Quality > 100:
+		return fmt.Errorf("quality must be 1 to 100")
+	case c.WarmUp < 0 || c.Timeout < 0:
+		return fmt.Errorf("warm_up and timeout must not be negative")
+	case c.Timeout > 0 && c.Timeout <= c.warmUp():
+		return fmt.Errorf("timeout %s must be longer than warm_up %s", c.Timeout, c.warmUp())
+	}
+	if c.LED != nil {
+		if err := c.LED.Validate(); err != nil {
+			return fmt.Errorf("led: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c Config) warmUp() time.Duration {
+	if c.WarmUp > 0 {
+		return c.WarmUp
+	}
+	return DefaultWarmUp
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+// args returns the arguments of the app capturing with e to standard
+// output.
+func (c Config) args(e Exposure) []string {
+	// The app's --timeout is the time it runs before the capture; it must
+	// not be 0, which runs it forever.
+	args := []string{"--nopreview", "--encoding", "jpg", "--output", "-",
+		"--timeout", strconv.FormatInt(max(c.warmUp().Milliseconds(), 1), 10)}
+	if c.Width > 0 {
+		args = append(args, "--width", strconv.Itoa(c.Width))
+	}
+	if c.Height > 0 {
+		args = append(args, "--height", strconv.Itoa(c.Height))
+	}
+	if e.Shutter > 0 {
+		args = append(args, "--shutter", strconv.FormatInt(e.Shutter.Microseconds(), 10))
+	}
+	if e.Gain > 0 {
+		args = append(args, "--gain", strconv.FormatFloat(e.Gain, 'g', -1, 64))
+	}
+	if c.Quality > 0 {
+		args = append(args, "--quality", strconv.Itoa(c.Quality))
+	}
+	return append(args, c.Args...)
+}
+
+// Runner runs the command name and returns its standard output. A command
+// that fails returns an [*exec.ExitError] with its standard error, as
+// [exec.Cmd.Output] does.
+type Runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func execRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// Camera captures images with the camera app. Captures are serialized: the
+// camera takes one process at a time.
+type Camera struct {
+	cfg     Config
+	command string
+	run     Runner
+	led     Pin
+
+	mu sync.Mutex
+}
+
+// New returns the camera of c, running the camera app with run (nil runs it
+// as a command) and lighting the LED of c with led (nil opens c.LED). The
+// app is looked up now, so a missing one is reported at start up.
+func New(c Config, run Runner, led Pin) (*Camera, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	cam := &Camera{cfg: c, command: c.Command, run: run, led: led}
+	if cam.run == nil {
+		cam.run = execRunner
+		if cam.command == "" {
+			for _, name := range Commands {
+				if _, err := exec.LookPath(name); err == nil {
+					cam.command = name
+					break
+				}
+			}
+		}
+		if cam.command == "" {
+			return nil, fmt.Errorf("%w: none of %s in PATH", ErrNotInstalled, strings.Join(Commands, ", "))
+		}
+		if _, err := exec.LookPath(cam.command); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotInstalled, err)
+		}
+	}
+	if cam.command == "" {
+		cam.command = Commands[0]
+	}
+	if cam.led == nil && c.LED != nil {
+		pin, err := c.LED.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open led: %w", err)
+		}
+		cam.led = pin
+	}
+	return cam, nil
+}
+
+// Exposure returns the configured exposure.
+func (c *Camera) Exposure() Exposure { return Exposure{Shutter: c.cfg.Shutter, Gain: c.cfg.Gain} }
+
+// Capture captures an image with the configured exposure.
+func (c *Camera) Capture(ctx context.Context) ([]byte, error) {
+	return c.CaptureWith(ctx, c.Exposure())
+}
+
+// CaptureWith captures an image with exposure e, lighting the LED for the
+// whole run of the app, warm-up included.
+func (c *Camera) CaptureWith(ctx context.Context, e Exposure) (img []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.led != nil {
+		if err := c.led.Set(true); err != nil {
+			return nil, fmt.Errorf("turn on led: %w", err)
+		}
+		defer func() {
+			if lerr := c.led.Set(false); lerr != nil && err == nil {
+				err = fmt.Errorf("turn off led: %w", lerr)
+			}
+		}()
+	}
+
+	timeout := c.cfg.timeout()
+	rctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	out, err := c.run(rctx, c.command, c.cfg.args(e)...)
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case rctx.Err() != nil:
+		return nil, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("%w: %w", ErrNotInstalled, err)
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return nil, &CaptureError{Command: c.command, ExitCode: exit.ExitCode(), Stderr: lastLines(exit.Stderr)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w", c.command, err)
+	}
+	if !bytes.HasPrefix(out, []byte{0xff, 0xd8}) {
+		return nil, &CaptureError{Command: c.command, ExitCode: -1, Stderr: fmt.Sprintf("%d bytes of output", len(out))}
+	}
+	return out, nil
+}
+
+// Close releases the LED.
+func (c *Camera) Close() error {
+	if c.led == nil {
+		return nil
+	}
+	return c.led.Close()
+}
+
+// lastLines returns the last three lines of the output b, where the app
+// prints its error after the camera enumeration.
+func lastLines(b []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	return strings.Join(lines[max(len(lines)-3, 0):], "; ")
+}
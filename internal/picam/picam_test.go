@@ -0,0 +1,170 @@
+package picam_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/picam"
+)
+
+var jpeg = []byte{0xff, 0xd8, 0xff, 0xe0, 'J', 'F', 'I', 'F'}
+
+// fakePin records the states of a line.
+type fakePin struct {
+	states []bool
+	closed bool
+}
+
+func (p *fakePin) Set(on bool) error {
+	p.states = append(p.states, on)
+	return nil
+}
+
+func (p *fakePin) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	led := &fakePin{}
+	var args []string
+	run := func(ctx context.Context, name string, a ...string) ([]byte, error) {
+		if name != "rpicam-still" || len(led.states) == 0 || !led.states[len(led.states)-1] {
+			t.Errorf("ran %s with the led %v", name, led.states)
+		}
+		args = a
+		return jpeg, nil
+	}
+	cam, err := picam.New(picam.Config{Width: 2028, Height: 1520, Shutter: 20 * time.Millisecond, Gain: 2,
+		WarmUp: 500 * time.Millisecond, Args: []string{"--rotation", "180"}}, run, led)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	img, err := cam.Capture(context.Background())
+	if err != nil || string(img) != string(jpeg) {
+		t.Fatalf("Capture = %q, %v", img, err)
+	}
+	want := "--nopreview --encoding jpg --output - --timeout 500 --width 2028 --height 1520 --shutter 20000 --gain 2 --rotation 180"
+	if got := strings.Join(args, " "); got != want {
+		t.Fatalf("args = %s, want %s", got, want)
+	}
+	if _, err := cam.CaptureWith(context.Background(), picam.Exposure{Shutter: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("CaptureWith: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "--shutter 5000") || strings.Contains(got, "--gain") {
+		t.Fatalf("args with 5ms = %s", got)
+	}
+	if err := cam.Close(); err != nil || !slices.Equal(led.states, []bool{true, false, true, false}) || !led.closed {
+		t.Fatalf("led %v closed %t after Close = %v", led.states, led.closed, err)
+	}
+}
+
+func TestCaptureErrors(t *testing.T) {
+	t.Parallel()
+
+	// A real process, for the *exec.ExitError the app fails with.
+	fail := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, "sh", "-c", "echo 'Made X/EGL preview window' >&2; echo 'ERROR: *** no cameras available ***' >&2; exit 255").Output()
+	}
+	hang := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	empty := func(ctx context.Context, name string, args ...string) ([]byte, error) { return nil, nil }
+	tests := []struct {
+		name string
+		cfg  picam.Config
+		run  picam.Runner
+		want error
+		msg  string
+	}{
+		{"exit", picam.Config{}, fail, picam.ErrCapture, "rpicam-still exited with 255: Made X/EGL preview window; ERROR: *** no cameras available ***"},
+		{"no image", picam.Config{Command: "libcamera-still"}, empty, picam.ErrCapture, "libcamera-still sent no JPEG image: 0 bytes of output"},
+		{"timeout", picam.Config{WarmUp: time.Millisecond, Timeout: 20 * time.Millisecond}, hang, picam.ErrTimeout, "camera capture timed out after 20ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			led := &fakePin{}
+			cam, err := picam.New(tt.cfg, tt.run, led)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			_, err = cam.Capture(context.Background())
+			if !errors.Is(err, tt.want) || err.Error() != tt.msg {
+				t.Fatalf("Capture error = %v, want %v: %s", err, tt.want, tt.msg)
+			}
+			if !slices.Equal(led.states, []bool{true, false}) {
+				t.Fatalf("led %v, want turned off after the failure", led.states)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cam, _ := picam.New(picam.Config{}, hang, nil)
+	if _, err := cam.Capture(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled Capture error = %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  picam.Config
+		ok   bool
+	}{
+		{"defaults", picam.Config{}, true},
+		{"sysfs led", picam.Config{LED: &picam.LEDConfig{Sysfs: "/sys/class/gpio/gpio17/value"}}, true},
+		{"chip led", picam.Config{LED: &picam.LEDConfig{Chip: "gpiochip0", Line: 17}}, true},
+		{"led without line", picam.Config{LED: &picam.LEDConfig{}}, false},
+		{"led on both", picam.Config{LED: &picam.LEDConfig{Chip: "gpiochip0", Sysfs: "/sys/class/gpio/gpio17/value"}}, false},
+		{"negative shutter", picam.Config{Shutter: -time.Millisecond}, false},
+		{"quality", picam.Config{Quality: 101}, false},
+		{"timeout within warm-up", picam.Config{WarmUp: 2 * time.Second, Timeout: time.Second}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.cfg.Validate(); (err == nil) != tt.ok {
+				t.Fatalf("Validate = %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSysfsLED(t *testing.T) {
+	t.Parallel()
+
+	value := filepath.Join(t.TempDir(), "value")
+	if err := os.WriteFile(value, []byte("1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pin, err := picam.LEDConfig{Sysfs: value, ActiveLow: true}.Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, tt := range []struct {
+		on   bool
+		want string
+	}{{false, "1"}, {true, "0"}, {false, "1"}} {
+		if err := pin.Set(tt.on); err != nil {
+			t.Fatalf("Set(%t): %v", tt.on, err)
+		}
+		if b, _ := os.ReadFile(value); string(b) != tt.want {
+			t.Fatalf("after Set(%t) the value is %q, want %q", tt.on, b, tt.want)
+		}
+	}
+}
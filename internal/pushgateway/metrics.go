@@ -0,0 +1,146 @@
+package pushgateway
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// ContentType is that of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types.
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+// Family is a metric with its samples.
+type Family struct {
+	Name, Help, Type string
+	Samples          []Sample
+}
+
+// Sample is a value of a family; Suffix is appended to the family name, as
+// "_bucket" for a histogram bucket.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Label is a label of a sample.
+type Label struct{ Name, Value string }
+
+// Encode writes fams in the Prometheus text format.
+func Encode(w io.Writer, fams []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range fams {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=%q", l.Name, l.Value)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// StatsFamilies returns the families of the counters of a vision client, as
+// /debug/vars shows them.
+func StatsFamilies(s genai.Stats) []Family {
+	counter := func(name, help string, v int64) Family {
+		return Family{Name: name, Help: help, Type: Counter, Samples: []Sample{{Value: float64(v)}}}
+	}
+	by := s.FailuresBy
+	failures := Family{Name: "mqvision_read_failures_total", Help: "Failed readings by reason.", Type: Counter}
+	for _, f := range []struct {
+		reason string
+		n      int64
+	}{
+		{genai.FailureTimeout, by.Timeout}, {genai.FailureCanceled, by.Canceled},
+		{genai.FailureImageTooLarge, by.ImageTooLarge}, {genai.FailureTruncated, by.Truncated},
+		{genai.FailureEmpty, by.Empty}, {genai.FailureInvalidOutput, by.InvalidOutput},
+		{genai.FailureWrongMeter, by.WrongMeter}, {genai.FailureOther, by.Other},
+	} {
+		failures.Samples = append(failures.Samples, Sample{Labels: []Label{{"reason", f.reason}}, Value: float64(f.n)})
+	}
+	return []Family{
+		counter("mqvision_reads_total", "Readings asked of the vision model.", s.Reads),
+		counter("mqvision_read_successes_total", "Successful readings.", s.Successes),
+		failures,
+		counter("mqvision_read_ambiguous_total", "Successful readings with uncertain digits.", s.Ambiguous),
+		counter("mqvision_read_guessed_total", "Ambiguous readings completed by a disambiguation call.", s.Guessed),
+		counter("mqvision_model_input_tokens_total", "Input tokens of every model call.", s.InputTokens),
+		counter("mqvision_model_output_tokens_total", "Output tokens of every model call.", s.OutputTokens),
+		counter("mqvision_model_cached_input_tokens_total", "Input tokens served from the backend's cache.", s.CachedInputTokens),
+		histogram("mqvision_read_duration_seconds", "Duration of successful readings.", s.Total),
+	}
+}
+
+func histogram(name, help string, h genai.Histogram) Family {
+	f := Family{Name: name, Help: help, Type: Histogram}
+	for i, le := range genai.DurationBuckets {
+		var n int64
+		if i < len(h.Counts) {
+			n = h.Counts[i]
+		}
+		f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: []Label{{"le", formatValue(le.Seconds())}}, Value: float64(n)})
+	}
+	f.Samples = append(f.Samples,
+		Sample{Suffix: "_bucket", Labels: []Label{{"le", "+Inf"}}, Value: float64(h.Count)},
+		Sample{Suffix: "_sum", Value: h.SumSeconds},
+		Sample{Suffix: "_count", Value: float64(h.Count)},
+	)
+	return f
+}
+
+// Run is the outcome of a run of a command.
+type Run struct {
+	MeterID string
+	// Reading is the value of the last reading, if HasReading.
+	Reading    float64
+	HasReading bool
+	Duration   time.Duration
+	// Success is set if the run did all it was to.
+	Success bool
+}
+
+// Families returns the families of r.
+func (r Run) Families() []Family {
+	meter := []Label{{"meter", r.MeterID}}
+	success := 0.0
+	if r.Success {
+		success = 1
+	}
+	fams := []Family{
+		{Name: "mqvision_run_duration_seconds", Help: "Duration of the last run.", Type: Gauge, Samples: []Sample{{Labels: meter, Value: r.Duration.Seconds()}}},
+		{Name: "mqvision_run_success", Help: "Whether the last run succeeded (1) or not (0).", Type: Gauge, Samples: []Sample{{Labels: meter, Value: success}}},
+	}
+	if r.HasReading {
+		fams = append(fams, Family{Name: "mqvision_reading", Help: "Meter value of the last reading of the run.", Type: Gauge, Samples: []Sample{{Labels: meter, Value: r.Reading}}})
+	}
+	return fams
+}
@@ -0,0 +1,129 @@
+// Package pushgateway pushes the metrics of a short-lived run to a
+// Prometheus Pushgateway, which holds them for Prometheus to scrape after the
+// run has exited.
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultJob is the job label by default.
+const DefaultJob = "mqvision"
+
+// Config is the Pushgateway a run pushes to.
+type Config struct {
+	// URL is the gateway, e.g. "http://pushgateway:9091".
+	URL string `yaml:"url"`
+	// Job and Instance are the labels of the group of metrics (default
+	// [DefaultJob] and the host name); a push replaces the group.
+	Job      string `yaml:"job"`
+	Instance string `yaml:"instance"`
+	// Username and Password, if set, are sent as basic auth.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Timeout bounds a push (default 10s).
+	Timeout time.Duration `yaml:"timeout"`
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper `yaml:"-"`
+}
+
+// Validate checks that the gateway is set.
+func (c Config) Validate() error {
+	if c.URL == "" {
+		return errors.New("needs url")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url: scheme %q, want http or https", u.Scheme)
+	}
+	return nil
+}
+
+// GroupURL returns the URL of the group of c, with label values that do
+// not fit in a path segment base64-encoded as the gateway takes them.
+func (c Config) GroupURL() string {
+	job, instance := c.Job, c.Instance
+	if job == "" {
+		job = DefaultJob
+	}
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	u := strings.TrimSuffix(c.URL, "/") + "/metrics/" + segment("job", job)
+	if instance != "" {
+		u += "/" + segment("instance", instance)
+	}
+	return u
+}
+
+func segment(name, value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		return name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return name + "/" + url.PathEscape(value)
+}
+
+// Client pushes to a gateway.
+type Client struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New returns a client pushing to cfg's gateway.
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Client{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport}}
+}
+
+// Push replaces the group with fams.
+func (c *Client) Push(ctx context.Context, fams []Family) error {
+	var buf bytes.Buffer
+	if err := Encode(&buf, fams); err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPut, &buf)
+}
+
+// Delete removes the group and all its metrics.
+func (c *Client) Delete(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.GroupURL(), body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", ContentType)
+	}
+	if c.cfg.Username != "" || c.cfg.Password != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package pushgateway_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/pushgateway"
+)
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		method, path, user, body string
+	}
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		user, _, _ := r.BasicAuth()
+		got = append(got, request{r.Method, r.URL.EscapedPath(), user, string(b)})
+		if strings.Contains(r.URL.Path, "broken") {
+			http.Error(w, "storage full", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := pushgateway.New(pushgateway.Config{URL: srv.URL, Instance: "cron/nas", Username: "prom", Password: "secret"})
+	stats := genai.Stats{Reads: 3, Successes: 2, Failures: 1, FailuresBy: genai.Failures{Empty: 1}, InputTokens: 900, CachedInputTokens: 600,
+		Total: genai.Histogram{Counts: []int64{0, 0, 1, 2, 2, 2, 2, 2, 2}, Count: 2, SumSeconds: 2.5}}
+	run := pushgateway.Run{MeterID: "home", Reading: 2924.457, HasReading: true, Duration: 1500 * time.Millisecond, Success: true}
+	if err := c.Push(ctx, append(pushgateway.StatsFamilies(stats), run.Families()...)); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if err := c.Delete(ctx); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(got) != 2 || got[0].method != http.MethodPut || got[1].method != http.MethodDelete {
+		t.Fatalf("requests = %+v", got)
+	}
+	// The instance has a slash, so it is base64-encoded.
+	if want := "/metrics/job/mqvision/instance@base64/Y3Jvbi9uYXM"; got[0].path != want || got[1].path != want {
+		t.Fatalf("group path %q, want %q", got[0].path, want)
+	}
+	if got[0].user != "prom" {
+		t.Fatalf("basic auth user %q", got[0].user)
+	}
+	for _, want := range []string{
+		"# TYPE mqvision_reads_total counter\nmqvision_reads_total 3\n",
+		`mqvision_read_failures_total{reason="empty"} 1`,
+		`mqvision_read_duration_seconds_bucket{le="1"} 1`,
+		`mqvision_read_duration_seconds_bucket{le="+Inf"} 2`,
+		"mqvision_read_duration_seconds_sum 2.5\n",
+		"mqvision_model_cached_input_tokens_total 600\n",
+		`mqvision_reading{meter="home"} 2924.457`,
+		`mqvision_run_duration_seconds{meter="home"} 1.5`,
+		`mqvision_run_success{meter="home"} 1`,
+	} {
+		if !strings.Contains(got[0].body, want) {
+			t.Fatalf("pushed metrics lack %q:\n%s", want, got[0].body)
+		}
+	}
+
+	broken := pushgateway.New(pushgateway.Config{URL: srv.URL, Job: "broken"})
+	if err := broken.Push(ctx, run.Families()); err == nil || !strings.Contains(err.Error(), "storage full") {
+		t.Fatalf("Push to a failing gateway: %v", err)
+	}
+}
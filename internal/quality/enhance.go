@@ -0,0 +1,189 @@
+package quality
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+)
+
+// Enhancement presets.
+const (
+	// EnhanceStretch stretches the contrast of the luma tile by tile, like
+	// CLAHE: each part of the image is spread over the full range, so that
+	// digits in a shadow or under glare stand out.
+	EnhanceStretch = "stretch"
+	// EnhanceGamma brightens (or darkens) the luma with Gamma, for a dark
+	// or washed-out image.
+	EnhanceGamma = "gamma"
+)
+
+// Stretch settings: the grid of tiles, the share of the darkest and
+// brightest pixels of a tile clipped, and the narrowest range of a tile
+// stretched, which keeps flat tiles from turning into noise.
+const (
+	stretchTiles    = 8
+	stretchClip     = 0.01
+	stretchMinRange = 32
+)
+
+// DefaultGamma is the gamma of [EnhanceGamma] by default.
+const DefaultGamma = 0.5
+
+// Enhancement is how an image the model could not read at all is enhanced
+// for a second attempt. The enhanced image is grayscale.
+type Enhancement struct {
+	// Preset is EnhanceStretch or EnhanceGamma; without one images are not
+	// enhanced.
+	Preset string `yaml:"preset"`
+	// Gamma is the exponent of EnhanceGamma (default [DefaultGamma]): below
+	// 1 brightens, above 1 darkens.
+	Gamma float64 `yaml:"gamma"`
+}
+
+// Validate checks the preset.
+func (e Enhancement) Validate() error {
+	switch e.Preset {
+	case "", EnhanceStretch, EnhanceGamma:
+	default:
+		return fmt.Errorf("unknown preset %q, want %s or %s", e.Preset, EnhanceStretch, EnhanceGamma)
+	}
+	if e.Gamma < 0 {
+		return fmt.Errorf("gamma must not be negative")
+	}
+	return nil
+}
+
+// Enabled reports whether a preset is set.
+func (e Enhancement) Enabled() bool { return e.Preset != "" }
+
+// Apply decodes the JPEG image jpg, enhances it and encodes it again.
+func (e Enhancement) Apply(jpg []byte) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(jpg))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	var out *image.Gray
+	switch e.Preset {
+	case EnhanceStretch:
+		out = Stretch(img)
+	case EnhanceGamma:
+		g := e.Gamma
+		if g == 0 {
+			g = DefaultGamma
+		}
+		out = Gamma(img, g)
+	default:
+		return nil, fmt.Errorf("unknown preset %q", e.Preset)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// gray returns the luma of img.
+func gray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			g.SetGray(x, y, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray))
+		}
+	}
+	return g
+}
+
+// Gamma returns the luma of img raised to the power g.
+func Gamma(img image.Image, g float64) *image.Gray {
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = uint8(math.Round(255 * math.Pow(float64(v)/255, g)))
+	}
+	out := gray(img)
+	for i, v := range out.Pix {
+		out.Pix[i] = lut[v]
+	}
+	return out
+}
+
+// Stretch returns the luma of img with the contrast of every tile of a grid
+// stretched to the full range. The bounds of a pixel are interpolated
+// between the centres of the tiles around it, so tiles show no seams.
+func Stretch(img image.Image) *image.Gray {
+	out := gray(img)
+	w, h := out.Rect.Dx(), out.Rect.Dy()
+	if w == 0 || h == 0 {
+		return out
+	}
+	tw, th := max((w+stretchTiles-1)/stretchTiles, 1), max((h+stretchTiles-1)/stretchTiles, 1)
+	nx, ny := (w+tw-1)/tw, (h+th-1)/th
+	lo := make([][]float64, ny)
+	hi := make([][]float64, ny)
+	for ty := range ny {
+		lo[ty], hi[ty] = make([]float64, nx), make([]float64, nx)
+		for tx := range nx {
+			var hist [256]int
+			n := 0
+			for y := ty * th; y < min((ty+1)*th, h); y++ {
+				for x := tx * tw; x < min((tx+1)*tw, w); x++ {
+					hist[out.Pix[y*out.Stride+x]]++
+					n++
+				}
+			}
+			l, u := percentile(&hist, n, stretchClip), percentile(&hist, n, 1-stretchClip)
+			if u-l < stretchMinRange {
+				mid := (l + u) / 2
+				l, u = mid-stretchMinRange/2, mid+stretchMinRange/2
+			}
+			lo[ty][tx], hi[ty][tx] = l, u
+		}
+	}
+	// at returns the tile index below and the weight of the next one along an
+	// axis of n tiles of size s.
+	at := func(p, s, n int) (int, float64) {
+		f := (float64(p)+0.5)/float64(s) - 0.5
+		i := int(math.Floor(f))
+		switch {
+		case i < 0:
+			return 0, 0
+		case i >= n-1:
+			return n - 1, 0
+		}
+		return i, f - float64(i)
+	}
+	for y := range h {
+		ty, fy := at(y, th, ny)
+		ty1 := min(ty+1, ny-1)
+		for x := range w {
+			tx, fx := at(x, tw, nx)
+			tx1 := min(tx+1, nx-1)
+			bilerp := func(t [][]float64) float64 {
+				top := t[ty][tx]*(1-fx) + t[ty][tx1]*fx
+				bottom := t[ty1][tx]*(1-fx) + t[ty1][tx1]*fx
+				return top*(1-fy) + bottom*fy
+			}
+			l, u := bilerp(lo), bilerp(hi)
+			v := (float64(out.Pix[y*out.Stride+x]) - l) / (u - l) * 255
+			out.Pix[y*out.Stride+x] = uint8(math.Round(min(max(v, 0), 255)))
+		}
+	}
+	return out
+}
+
+// percentile returns the value below which the share q of the n values of
+// hist are.
+func percentile(hist *[256]int, n int, q float64) float64 {
+	target := int(q * float64(n))
+	seen := 0
+	for v, c := range hist {
+		seen += c
+		if seen > target {
+			return float64(v)
+		}
+	}
+	return 255
+}
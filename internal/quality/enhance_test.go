@@ -0,0 +1,62 @@
+package quality_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/quality"
+)
+
+func TestEnhance(t *testing.T) {
+	t.Parallel()
+
+	// Faint digits: stripes of 100 and 120, the right half in a shadow.
+	img := image.NewGray(image.Rect(0, 0, 256, 128))
+	for y := range 128 {
+		for x := range 256 {
+			v := uint8(100 + x/8%2*20)
+			if x >= 128 {
+				v -= 60
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	before := quality.MeasureImage(img)
+
+	tests := []struct {
+		e     quality.Enhancement
+		check func(before, after quality.Scores) bool
+	}{
+		// Stretched, the stripes span the range in both halves: far sharper.
+		{quality.Enhancement{Preset: quality.EnhanceStretch}, func(b, a quality.Scores) bool { return a.Sharpness > 20*b.Sharpness }},
+		{quality.Enhancement{Preset: quality.EnhanceGamma}, func(b, a quality.Scores) bool { return a.Brightness > b.Brightness+0.15 }},
+		{quality.Enhancement{Preset: quality.EnhanceGamma, Gamma: 2}, func(b, a quality.Scores) bool { return a.Brightness < b.Brightness }},
+	}
+	for _, tt := range tests {
+		out, err := tt.e.Apply(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%+v: Apply: %v", tt.e, err)
+		}
+		after, err := quality.Measure(out)
+		if err != nil {
+			t.Fatalf("%+v: Measure: %v", tt.e, err)
+		}
+		if after.Width != 256 || after.Height != 128 || !tt.check(before, after) {
+			t.Fatalf("%+v: scores %+v, were %+v", tt.e, after, before)
+		}
+	}
+
+	if err := (quality.Enhancement{Preset: "clahe"}).Validate(); err == nil {
+		t.Fatalf("Validate accepted an unknown preset")
+	}
+	if _, err := (quality.Enhancement{Preset: quality.EnhanceGamma}).Apply([]byte("not a jpeg")); err == nil {
+		t.Fatalf("Apply accepted a broken image")
+	}
+}
@@ -0,0 +1,117 @@
+// Package quality scores meter images for exposure and focus, so that a
+// camera can be set up before the model is asked to read what it captures.
+package quality
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+// side is the longest side images are scaled down to before scoring, so that
+// scores do not depend on the camera's resolution.
+const side = 512
+
+// Scores are the measures of an image.
+type Scores struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Brightness is the mean luma, from 0 (black) to 1 (white).
+	Brightness float64 `json:"brightness"`
+	// Sharpness is the variance of the Laplacian of the luma (0–255): low
+	// for a blurred image, in which neighbouring pixels differ little.
+	Sharpness float64 `json:"sharpness"`
+}
+
+// Measure decodes the JPEG image jpg and scores it.
+func Measure(jpg []byte) (Scores, error) {
+	img, err := jpeg.Decode(bytes.NewReader(jpg))
+	if err != nil {
+		return Scores{}, fmt.Errorf("decode image: %w", err)
+	}
+	return MeasureImage(img), nil
+}
+
+// MeasureImage scores img.
+func MeasureImage(img image.Image) Scores {
+	b := img.Bounds()
+	s := Scores{Width: b.Dx(), Height: b.Dy()}
+	g := luma(img)
+	h, w := len(g), 0
+	if h > 0 {
+		w = len(g[0])
+	}
+	if w == 0 {
+		return s
+	}
+
+	var sum float64
+	for _, row := range g {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	s.Brightness = sum / float64(w*h) / 255
+
+	// The 4-neighbour Laplacian of the inner pixels.
+	var n, mean, m2 float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			l := g[y-1][x] + g[y+1][x] + g[y][x-1] + g[y][x+1] - 4*g[y][x]
+			n++
+			d := l - mean
+			mean += d / n
+			m2 += d * (l - mean)
+		}
+	}
+	if n > 0 {
+		s.Sharpness = m2 / n
+	}
+	return s
+}
+
+// luma samples the luma of img on a grid of at most side pixels on its
+// longest side.
+func luma(img image.Image) [][]float64 {
+	b := img.Bounds()
+	step := 1
+	if long := max(b.Dx(), b.Dy()); long > side {
+		step = (long + side - 1) / side
+	}
+	var g [][]float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		row := make([]float64, 0, b.Dx()/step+1)
+		for x := b.Min.X; x < b.Max.X; x += step {
+			row = append(row, float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y))
+		}
+		g = append(g, row)
+	}
+	return g
+}
+
+// Gate bounds the scores of an image worth reading.
+type Gate struct {
+	MinBrightness, MaxBrightness float64
+	MinSharpness                 float64
+}
+
+// DefaultGate is what the daemon's camera set-up is checked against: neither
+// under- nor overexposed, and in focus enough for the digits to have edges.
+var DefaultGate = Gate{MinBrightness: 0.15, MaxBrightness: 0.9, MinSharpness: 30}
+
+// Check returns the problems of s, none if it passes.
+func (g Gate) Check(s Scores) []string {
+	var problems []string
+	switch {
+	case s.Brightness < g.MinBrightness:
+		problems = append(problems, fmt.Sprintf("too dark: brightness %.2f below %.2f", s.Brightness, g.MinBrightness))
+	case g.MaxBrightness > 0 && s.Brightness > g.MaxBrightness:
+		problems = append(problems, fmt.Sprintf("too bright: brightness %.2f above %.2f", s.Brightness, g.MaxBrightness))
+	}
+	if s.Sharpness < g.MinSharpness {
+		problems = append(problems, fmt.Sprintf("blurred: sharpness %.1f below %.1f", s.Sharpness, g.MinSharpness))
+	}
+	return problems
+}
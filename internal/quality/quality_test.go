@@ -0,0 +1,71 @@
+package quality_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/quality"
+)
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+
+	fill := func(w, h int, f func(x, y int) uint8) image.Image {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for y := range h {
+			for x := range w {
+				img.SetGray(x, y, color.Gray{Y: f(x, y)})
+			}
+		}
+		return img
+	}
+	flat := fill(64, 48, func(int, int) uint8 { return 128 })
+	// Stripes 8 pixels wide; a large image scaled down keeps its edges.
+	stripes := fill(2048, 1024, func(x, _ int) uint8 { return uint8(x / 8 % 2 * 200) })
+	dark := fill(64, 48, func(x, y int) uint8 { return uint8((x + y) % 2 * 20) })
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		problems []string
+	}{
+		{"flat", flat, []string{"blurred"}},
+		{"stripes", stripes, nil},
+		{"dark", dark, []string{"too dark"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, tt.img, &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatal(err)
+		}
+		s, err := quality.Measure(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%s: Measure: %v", tt.name, err)
+		}
+		if b := tt.img.Bounds(); s.Width != b.Dx() || s.Height != b.Dy() {
+			t.Fatalf("%s: size %dx%d", tt.name, s.Width, s.Height)
+		}
+		problems := quality.DefaultGate.Check(s)
+		if len(problems) != len(tt.problems) {
+			t.Fatalf("%s: Check(%+v) = %q, want %q", tt.name, s, problems, tt.problems)
+		}
+		for i, p := range problems {
+			if !strings.HasPrefix(p, tt.problems[i]) {
+				t.Fatalf("%s: Check(%+v) = %q, want %q", tt.name, s, problems, tt.problems)
+			}
+		}
+	}
+
+	s := quality.MeasureImage(flat)
+	if math.Abs(s.Brightness-128.0/255) > 1e-9 || s.Sharpness != 0 {
+		t.Fatalf("flat = %+v", s)
+	}
+	if _, err := quality.Measure([]byte("not a jpeg")); err == nil {
+		t.Fatal("Measure of garbage succeeded")
+	}
+}
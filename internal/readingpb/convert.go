@@ -0,0 +1,299 @@
+// Package readingpb is the protobuf schema of readings and consumption
+// shared with other services, and its conversion from and to
+// [genai.GasMeterReadResult]. The schema is in reading.proto; regenerate
+// reading.pb.go with go generate after changing it.
+package readingpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative reading.proto
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SchemaVersion is the schema_version this package writes and the highest
+// it reads.
+const SchemaVersion = 1
+
+// ToProto returns r, a reading of meter meterID, as a Reading.
+func ToProto(meterID string, r *genai.GasMeterReadResult) *Reading {
+	m := &Reading{
+		SchemaVersion: SchemaVersion,
+		MeterId:       meterID,
+		Id:            r.ID,
+		Read:          r.Read,
+		RawRead:       r.RawRead,
+		Utility:       r.Utility,
+		Date:          r.Date,
+		DateParsed:    timestamp(r.DateParsed),
+		ReadAt:        timestamp(r.ReadAt),
+		DateSkew:      r.DateSkew,
+		ItTakes:       r.ItTakes,
+		Ambiguous:     r.Ambiguous,
+		SerialNumber:  r.SerialNumber,
+		CounterBox:    boxToProto(r.CounterBox),
+		Roi:           boxToProto(r.ROI),
+		VerifiedRead:  r.VerifiedRead,
+		FirstRead:     r.FirstRead,
+		Enhanced:      r.Enhanced,
+		Warnings:      r.Warnings,
+		Stale:         r.Stale,
+		StaleSince:    timestamp(r.StaleSince),
+		GapBefore:     r.GapBefore,
+		Downsampled:   r.Downsampled,
+		Maintenance:   r.Maintenance,
+		Source:        r.Source,
+		UploadedFile:  r.UploadedFile,
+		Model:         r.Model,
+		PromptHash:    r.PromptHash,
+		ConfigHash:    r.ConfigHash,
+		Provenance:    r.Provenance,
+		ReferenceId:   r.ReferenceID,
+		ReaderVersion: r.ReaderVersion,
+		Route:         r.Route,
+		Tags:          r.Tags,
+		Confidences:   r.Confidences,
+	}
+	if !r.DateParsed.IsZero() {
+		_, offset := r.DateParsed.Zone()
+		m.DateParsedUtcOffset = int32(offset)
+	}
+	if t := r.Timing; t != nil {
+		m.Timing = &Timing{Upload: t.Upload, Read: t.Read, Guess: t.Guess, Verify: t.Verify, SingleShot: t.SingleShot,
+			UploadAttempts: int32(t.UploadAttempts), UploadRetriedBytes: t.UploadRetriedBytes, TimedOut: t.TimedOut,
+			InputTokens: int32(t.InputTokens), OutputTokens: int32(t.OutputTokens), ThinkingTokens: int32(t.ThinkingTokens)}
+	}
+	for _, p := range r.AmbiguousPositions {
+		m.AmbiguousPositions = append(m.AmbiguousPositions, int32(p))
+	}
+	for _, d := range r.Dials {
+		m.Dials = append(m.Dials, &Dial{Value: d.Value, Direction: d.Direction})
+	}
+	for _, a := range r.Answers {
+		m.Answers = append(m.Answers, &ModelAnswer{Model: a.Model, Read: a.Read, Error: a.Error})
+	}
+	if i := r.Issue; i != nil {
+		m.Issue = &Issue{Kind: i.Kind, Note: i.Note}
+	}
+	if c := r.Correction; c != nil {
+		m.Correction = &Correction{Original: c.Original, Previous: c.Previous, Note: c.Note, At: timestamp(c.At)}
+	}
+	if e := r.MeterExchanged; e != nil {
+		m.MeterExchanged = &Exchange{Previous: e.Previous, Start: e.Start, At: timestamp(e.At), Serial: e.Serial}
+	}
+	if c := r.CrossCheck; c != nil {
+		m.CrossCheck = &CrossCheck{Recognizer: c.Recognizer, Read: c.Read, Confidence: c.Confidence, Error: c.Error}
+	}
+	return m
+}
+
+// FromProto returns the reading m holds and the ID of its meter. Times come
+// back in UTC, except DateParsed which keeps the UTC offset it was given in.
+// Readings of a newer schema version are rejected.
+func FromProto(m *Reading) (string, *genai.GasMeterReadResult, error) {
+	if err := checkVersion(m.GetSchemaVersion()); err != nil {
+		return "", nil, err
+	}
+	r := &genai.GasMeterReadResult{
+		ID:            m.GetId(),
+		Read:          m.GetRead(),
+		RawRead:       m.GetRawRead(),
+		Utility:       m.GetUtility(),
+		Date:          m.GetDate(),
+		ReadAt:        fromTimestamp(m.GetReadAt()),
+		DateSkew:      m.GetDateSkew(),
+		ItTakes:       m.GetItTakes(),
+		Ambiguous:     m.GetAmbiguous(),
+		SerialNumber:  m.GetSerialNumber(),
+		CounterBox:    boxFromProto(m.GetCounterBox()),
+		ROI:           boxFromProto(m.GetRoi()),
+		VerifiedRead:  m.GetVerifiedRead(),
+		FirstRead:     m.GetFirstRead(),
+		Enhanced:      m.GetEnhanced(),
+		Warnings:      m.GetWarnings(),
+		Stale:         m.GetStale(),
+		StaleSince:    fromTimestamp(m.GetStaleSince()),
+		GapBefore:     m.GetGapBefore(),
+		Downsampled:   m.GetDownsampled(),
+		Maintenance:   m.GetMaintenance(),
+		Source:        m.GetSource(),
+		UploadedFile:  m.GetUploadedFile(),
+		Model:         m.GetModel(),
+		PromptHash:    m.GetPromptHash(),
+		ConfigHash:    m.GetConfigHash(),
+		Provenance:    m.GetProvenance(),
+		ReferenceID:   m.GetReferenceId(),
+		ReaderVersion: m.GetReaderVersion(),
+		Route:         m.GetRoute(),
+		Tags:          m.GetTags(),
+		Confidences:   m.GetConfidences(),
+	}
+	if t := fromTimestamp(m.GetDateParsed()); !t.IsZero() {
+		r.DateParsed = t.In(time.FixedZone("", int(m.GetDateParsedUtcOffset())))
+	}
+	if t := m.GetTiming(); t != nil {
+		r.Timing = &genai.Timing{Upload: t.GetUpload(), Read: t.GetRead(), Guess: t.GetGuess(), Verify: t.GetVerify(), SingleShot: t.GetSingleShot(),
+			UploadAttempts: int(t.GetUploadAttempts()), UploadRetriedBytes: t.GetUploadRetriedBytes(), TimedOut: t.GetTimedOut(),
+			InputTokens: int(t.GetInputTokens()), OutputTokens: int(t.GetOutputTokens()), ThinkingTokens: int(t.GetThinkingTokens())}
+	}
+	for _, p := range m.GetAmbiguousPositions() {
+		r.AmbiguousPositions = append(r.AmbiguousPositions, int(p))
+	}
+	for _, d := range m.GetDials() {
+		r.Dials = append(r.Dials, genai.DialReading{Value: d.GetValue(), Direction: d.GetDirection()})
+	}
+	for _, a := range m.GetAnswers() {
+		r.Answers = append(r.Answers, genai.ModelAnswer{Model: a.GetModel(), Read: a.GetRead(), Error: a.GetError()})
+	}
+	if i := m.GetIssue(); i != nil {
+		r.Issue = &genai.Issue{Kind: i.GetKind(), Note: i.GetNote()}
+	}
+	if c := m.GetCorrection(); c != nil {
+		r.Correction = &genai.Correction{Original: c.GetOriginal(), Previous: c.GetPrevious(), Note: c.GetNote(), At: fromTimestamp(c.GetAt())}
+	}
+	if e := m.GetMeterExchanged(); e != nil {
+		r.MeterExchanged = &genai.Exchange{Previous: e.GetPrevious(), Start: e.GetStart(), At: fromTimestamp(e.GetAt()), Serial: e.GetSerial()}
+	}
+	if c := m.GetCrossCheck(); c != nil {
+		r.CrossCheck = &genai.CrossCheck{Recognizer: c.GetRecognizer(), Read: c.GetRead(), Confidence: c.GetConfidence(), Error: c.GetError()}
+	}
+	return m.GetMeterId(), r, nil
+}
+
+// ConsumptionToProto returns the usage u of meter meterID up to the reading
+// at at as a Consumption.
+func ConsumptionToProto(meterID string, at time.Time, u billing.Usage) *Consumption {
+	return &Consumption{
+		SchemaVersion: SchemaVersion,
+		MeterId:       meterID,
+		At:            timestamp(at),
+		Raw:           u.Raw,
+		Corrected:     u.Corrected,
+		EnergyKwh:     u.Energy,
+	}
+}
+
+// ConsumptionFromProto returns the meter ID, time and usage m holds, the
+// time in UTC. Consumption of a newer schema version is rejected.
+func ConsumptionFromProto(m *Consumption) (string, time.Time, billing.Usage, error) {
+	if err := checkVersion(m.GetSchemaVersion()); err != nil {
+		return "", time.Time{}, billing.Usage{}, err
+	}
+	u := billing.Usage{Raw: m.GetRaw(), Corrected: m.GetCorrected(), Energy: m.GetEnergyKwh()}
+	return m.GetMeterId(), fromTimestamp(m.GetAt()), u, nil
+}
+
+// UsageToProto returns u as a Usage, nil if u is, as for
+// [Consumption.Cumulative].
+func UsageToProto(u *billing.Usage) *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{Raw: u.Raw, Corrected: u.Corrected, EnergyKwh: u.Energy}
+}
+
+// UsageFromProto returns the usage m holds, nil if m is.
+func UsageFromProto(m *Usage) *billing.Usage {
+	if m == nil {
+		return nil
+	}
+	return &billing.Usage{Raw: m.GetRaw(), Corrected: m.GetCorrected(), Energy: m.GetEnergyKwh()}
+}
+
+// CorrectionToProto returns the correction of r, a corrected reading of
+// meter meterID, made by by, as a CorrectionEvent.
+func CorrectionToProto(meterID, by string, r *genai.GasMeterReadResult) *CorrectionEvent {
+	m := &CorrectionEvent{
+		SchemaVersion: SchemaVersion,
+		MeterId:       meterID,
+		ReadingId:     r.ID,
+		New:           r.Read,
+		By:            by,
+		Reading:       ToProto(meterID, r),
+	}
+	if c := r.Correction; c != nil {
+		m.Old, m.Reason, m.At = cmp.Or(c.Previous, c.Original), c.Note, timestamp(c.At)
+	}
+	return m
+}
+
+// CorrectionFromProto returns the meter ID, the maker and the corrected
+// reading m holds. Events of a newer schema version are rejected.
+func CorrectionFromProto(m *CorrectionEvent) (meterID, by string, r *genai.GasMeterReadResult, err error) {
+	if err := checkVersion(m.GetSchemaVersion()); err != nil {
+		return "", "", nil, err
+	}
+	if m.GetReading() == nil {
+		return "", "", nil, errors.New("correction without a reading")
+	}
+	if _, r, err = FromProto(m.GetReading()); err != nil {
+		return "", "", nil, err
+	}
+	return m.GetMeterId(), m.GetBy(), r, nil
+}
+
+// Marshal returns the wire encoding of the reading r of meter meterID.
+func Marshal(meterID string, r *genai.GasMeterReadResult) ([]byte, error) {
+	b, err := proto.Marshal(ToProto(meterID, r))
+	if err != nil {
+		return nil, fmt.Errorf("marshal reading: %w", err)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a reading marshaled by [Marshal], skipping the fields of
+// newer schemas it does not know.
+func Unmarshal(b []byte) (string, *genai.GasMeterReadResult, error) {
+	var m Reading
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return "", nil, fmt.Errorf("unmarshal reading: %w", err)
+	}
+	return FromProto(&m)
+}
+
+// checkVersion rejects schema versions this package cannot read. A missing
+// version is an encoding that is not a reading at all.
+func checkVersion(v uint32) error {
+	switch {
+	case v == 0:
+		return errors.New("missing schema_version")
+	case v > SchemaVersion:
+		return fmt.Errorf("schema_version %d is newer than %d", v, SchemaVersion)
+	}
+	return nil
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func boxToProto(b *genai.Box) *Box {
+	if b == nil {
+		return nil
+	}
+	return &Box{XMin: b.XMin, YMin: b.YMin, XMax: b.XMax, YMax: b.YMax}
+}
+
+func boxFromProto(b *Box) *genai.Box {
+	if b == nil {
+		return nil
+	}
+	return &genai.Box{XMin: b.GetXMin(), YMin: b.GetYMin(), XMax: b.GetXMax(), YMax: b.GetYMax()}
+}
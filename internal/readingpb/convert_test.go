@@ -0,0 +1,285 @@
+package readingpb_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/readingpb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var kst = time.FixedZone("KST", 9*60*60)
+
+// fullResult sets every field of a reading, with its times other than
+// DateParsed in UTC, as they come back from the schema.
+func fullResult() *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{
+		ID:         "5d0c8e1f2a3b4c5d6e7f8091a2b3c4d5",
+		Read:       "02924.457",
+		RawRead:    "02924,457 m³",
+		Utility:    genai.UtilityGas,
+		Date:       "2025-11-07T15:13:17+09:00",
+		DateParsed: time.Date(2025, 11, 7, 15, 13, 17, 0, kst),
+		ReadAt:     time.Date(2025, 11, 7, 6, 13, 17, 123456789, time.UTC),
+		DateSkew:   "-2h0m0s",
+		ItTakes:    "2.5s",
+		Timing: &genai.Timing{Upload: "0.2s", UploadAttempts: 2, UploadRetriedBytes: 4096, Read: "1.5s", Guess: "1s", Verify: "0.8s", SingleShot: true, TimedOut: []string{"generate"},
+			InputTokens: 1800, OutputTokens: 120, ThinkingTokens: 40},
+		Ambiguous:          true,
+		AmbiguousPositions: []int{4, 6},
+		Confidences:        []float64{0.99, 0.98, 0.97, 0.95, 0.62, 0.9, 0.41, 0.88},
+		Dials:              []genai.DialReading{{Value: 2.9, Direction: "cw"}, {Value: 9.1, Direction: "ccw"}},
+		Answers:            []genai.ModelAnswer{{Model: "a", Read: "02924.457"}, {Model: "b", Error: "timeout"}},
+		Issue:              &genai.Issue{Kind: genai.IssueGlare, Note: "sun on the glass"},
+		SerialNumber:       "GM2019-4??13",
+		CounterBox:         &genai.Box{XMin: 0.2, YMin: 0.4, XMax: 0.8, YMax: 0.6},
+		ROI:                &genai.Box{XMin: 0.1, YMin: 0.3, XMax: 0.9, YMax: 0.7},
+		VerifiedRead:       "02924.457",
+		FirstRead:          "02924.451",
+		Enhanced:           true,
+		Correction:         &genai.Correction{Original: "02924.451", Previous: "02924.454", Note: "checked", At: time.Date(2025, 11, 8, 9, 0, 0, 0, time.UTC)},
+		CrossCheck:         &genai.CrossCheck{Recognizer: "seven_segment", Read: "02924457", Confidence: 0.72, Error: "found 7 digits, want 8: 0292445"},
+		Warnings:           []string{"monotonic: decrease from 02924.500"},
+		Stale:              true,
+		StaleSince:         time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC),
+		GapBefore:          "48h0m0s",
+		Downsampled:        "1h0m0s",
+		Maintenance:        true,
+		MeterExchanged:     &genai.Exchange{Previous: "09876.543", Start: "00000.100", At: time.Date(2025, 11, 7, 9, 0, 0, 0, time.UTC), Serial: "GM-2025-0002"},
+		Source:             "hallway",
+		UploadedFile:       "gas-meter/home/20251107T061317.123Z",
+		Model:              "gpt-4o-mini",
+		PromptHash:         "3f9a0c1b2d4e",
+		ConfigHash:         "8b1d2e4f6a0c",
+		Provenance:         genai.ProvenanceGuessed,
+		ReferenceID:        "4c5d6e7f8091a2b3c4d55d0c8e1f2a3b",
+		ReaderVersion:      "v1.0.0",
+		Route:              "official",
+		Tags:               []string{"daily"},
+	}
+}
+
+// TestFullResult keeps fullResult, and with it the round trip, covering
+// the fields added to readings.
+func TestFullResult(t *testing.T) {
+	t.Parallel()
+
+	v := reflect.ValueOf(*fullResult())
+	for i := range v.NumField() {
+		if v.Field(i).IsZero() {
+			t.Errorf("fullResult leaves %s zero", v.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		r    *genai.GasMeterReadResult
+	}{
+		{"full", fullResult()},
+		{"minimal", &genai.GasMeterReadResult{Read: "00001.000", Date: "2025-11-07"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := readingpb.Marshal("home", tt.r)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			meterID, got, err := readingpb.Unmarshal(b)
+			if err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if meterID != "home" {
+				t.Fatalf("meter ID %q, want home", meterID)
+			}
+			want, _ := json.Marshal(tt.r)
+			have, _ := json.Marshal(got)
+			if string(have) != string(want) {
+				t.Fatalf("round trip\n got %s\nwant %s", have, want)
+			}
+		})
+	}
+}
+
+// TestToProtoSetsEveryField catches fields added to the schema but not to
+// the conversion.
+func TestToProtoSetsEveryField(t *testing.T) {
+	t.Parallel()
+
+	var check func(path string, m protoreflect.Message)
+	check = func(path string, m protoreflect.Message) {
+		fields := m.Descriptor().Fields()
+		for i := range fields.Len() {
+			fd := fields.Get(i)
+			name := path + string(fd.Name())
+			if !m.Has(fd) {
+				t.Errorf("%s not set", name)
+				continue
+			}
+			switch {
+			case fd.IsList() && fd.Message() != nil:
+				// Each field must be set in one of the elements.
+				list := m.Get(fd).List()
+				merged := list.NewElement().Message().Interface()
+				for j := range list.Len() {
+					proto.Merge(merged, list.Get(j).Message().Interface())
+				}
+				check(name+"[].", merged.ProtoReflect())
+			case fd.Message() != nil && fd.Message().FullName() != "google.protobuf.Timestamp":
+				check(name+".", m.Get(fd).Message())
+			}
+		}
+	}
+	check("", readingpb.ToProto("home", fullResult()).ProtoReflect())
+	c := readingpb.ConsumptionToProto("home", time.Now(), billing.Usage{Raw: 1.5, Corrected: 1.45, Energy: 16.2})
+	c.Cumulative = readingpb.UsageToProto(&billing.Usage{Raw: 320.5, Corrected: 310.2, Energy: 3474.2})
+	check("", c.ProtoReflect())
+	check("", readingpb.CorrectionToProto("home", "ops", fullResult()).ProtoReflect())
+}
+
+func TestDateParsedOffset(t *testing.T) {
+	t.Parallel()
+
+	r := fullResult()
+	r.ReadAt = r.ReadAt.In(kst)
+	m := readingpb.ToProto("home", r)
+	if m.GetDateParsedUtcOffset() != 9*60*60 {
+		t.Fatalf("date_parsed_utc_offset %d, want %d", m.GetDateParsedUtcOffset(), 9*60*60)
+	}
+	_, got, err := readingpb.FromProto(m)
+	if err != nil {
+		t.Fatalf("FromProto: %v", err)
+	}
+	if got.DateParsed.Format(time.RFC3339) != "2025-11-07T15:13:17+09:00" {
+		t.Fatalf("DateParsed %s, want the time in +09:00", got.DateParsed.Format(time.RFC3339))
+	}
+	if !got.ReadAt.Equal(r.ReadAt) || got.ReadAt.Location() != time.UTC {
+		t.Fatalf("ReadAt %s, want %s in UTC", got.ReadAt, r.ReadAt)
+	}
+}
+
+// TestUnknownFields is a reading of a newer schema of the same version:
+// what an older reader does not know is skipped, and kept when it passes
+// the message on.
+func TestUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	b, err := readingpb.Marshal("home", fullResult())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	b = protowire.AppendTag(b, 1000, protowire.BytesType)
+	b = protowire.AppendString(b, "added later")
+	b = protowire.AppendTag(b, 1001, protowire.VarintType)
+	b = protowire.AppendVarint(b, 42)
+
+	if _, r, err := readingpb.Unmarshal(b); err != nil || r.Read != "02924.457" {
+		t.Fatalf("Unmarshal with unknown fields = %v, %v", r, err)
+	}
+	var m readingpb.Reading
+	if err := proto.Unmarshal(b, &m); err != nil {
+		t.Fatalf("proto.Unmarshal: %v", err)
+	}
+	again, err := proto.Marshal(&m)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	if !strings.Contains(string(again), "added later") {
+		t.Fatalf("unknown fields dropped on re-marshal")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version uint32
+		wantErr string
+	}{
+		{0, "missing schema_version"},
+		{readingpb.SchemaVersion, ""},
+		{readingpb.SchemaVersion + 1, "newer"},
+	}
+	for _, tt := range tests {
+		m := readingpb.ToProto("home", fullResult())
+		m.SchemaVersion = tt.version
+		_, _, err := readingpb.FromProto(m)
+		c := readingpb.ConsumptionToProto("home", time.Now(), billing.Usage{Raw: 1})
+		c.SchemaVersion = tt.version
+		_, _, _, cerr := readingpb.ConsumptionFromProto(c)
+		for _, err := range []error{err, cerr} {
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("version %d: err = %v, want %q", tt.version, err, tt.wantErr)
+			}
+		}
+	}
+}
+
+func TestConsumptionRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 6, 13, 17, 0, time.UTC)
+	u := billing.Usage{Raw: 1.5, Corrected: 1.45, Energy: 16.2}
+	cum := billing.Usage{Raw: 320.5, Corrected: 310.2}
+	c := readingpb.ConsumptionToProto("home", at, u)
+	c.Cumulative = readingpb.UsageToProto(&cum)
+	b, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var m readingpb.Consumption
+	if err := proto.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	meterID, gotAt, got, err := readingpb.ConsumptionFromProto(&m)
+	if err != nil || meterID != "home" || !gotAt.Equal(at) || got != u {
+		t.Fatalf("ConsumptionFromProto = %q, %s, %+v, %v; want home, %s, %+v", meterID, gotAt, got, err, at, u)
+	}
+	if got := readingpb.UsageFromProto(m.GetCumulative()); got == nil || *got != cum {
+		t.Fatalf("cumulative = %+v, want %+v", got, cum)
+	}
+	if got := readingpb.UsageFromProto(readingpb.ConsumptionToProto("home", at, u).GetCumulative()); got != nil {
+		t.Fatalf("cumulative without a baseline = %+v, want nil", got)
+	}
+}
+
+func TestCorrectionRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	r := fullResult()
+	b, err := proto.Marshal(readingpb.CorrectionToProto("home", "ops", r))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var m readingpb.CorrectionEvent
+	if err := proto.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if m.GetReadingId() != r.ID || m.GetOld() != "02924.454" || m.GetNew() != r.Read || m.GetReason() != "checked" {
+		t.Fatalf("event = %v", &m)
+	}
+	meterID, by, got, err := readingpb.CorrectionFromProto(&m)
+	if err != nil || meterID != "home" || by != "ops" {
+		t.Fatalf("CorrectionFromProto = %q, %q, %v; want home, ops", meterID, by, err)
+	}
+	want, _ := json.Marshal(r)
+	have, _ := json.Marshal(got)
+	if string(have) != string(want) {
+		t.Fatalf("reading\n got %s\nwant %s", have, want)
+	}
+	if _, _, _, err := readingpb.CorrectionFromProto(&readingpb.CorrectionEvent{SchemaVersion: 1}); err == nil {
+		t.Fatal("CorrectionFromProto without a reading: no error")
+	}
+}
@@ -0,0 +1,276 @@
+// Package redact masks the fields that identify a meter and its household,
+// such as its serial number, in logs and payloads shared with others. Every
+// value is masked the same way, keeping a short suffix for correlation
+// ("…4113"), and once a value has been masked in a payload it is masked in
+// any text too: a log line mentioning it is redacted like the payload.
+package redact
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fields that can be redacted.
+const (
+	SerialNumber = "serial_number" // the serial number, configured or read
+	MeterID      = "meter_id"      // the configured meter ID
+	SourceImage  = "source_image"  // where the meter image is stored
+)
+
+// Fields are all redactable fields.
+var Fields = []string{SerialNumber, MeterID, SourceImage}
+
+// keys are the JSON keys holding each field, in any payload.
+var keys = map[string][]string{
+	SerialNumber: {"serial_number", "serial"},
+	MeterID:      {"meter_id", "meter"},
+	SourceImage:  {"src_image_url", "source_image", "uploaded_file", "archive_key"},
+}
+
+// suffix is how many characters of a value a mask keeps, of values at least
+// twice as long; shorter values are masked whole.
+const suffix = 4
+
+// Mask returns s masked as "…" and its last characters.
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n < 2*suffix {
+		return "…"
+	}
+	r := []rune(s)
+	return "…" + string(r[n-suffix:])
+}
+
+// Redactor masks the configured fields. A nil Redactor masks nothing. It is
+// safe for concurrent use.
+type Redactor struct {
+	keys map[string]string // JSON key → field
+
+	mu       sync.RWMutex
+	values   map[string]string // seen value → its mask
+	prefixes []string          // of values only known by their start
+	re       *regexp.Regexp    // of values and prefixes, nil if none
+}
+
+// New returns the Redactor of fields, or nil if there are none.
+func New(fields []string) (*Redactor, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	r := &Redactor{keys: map[string]string{}, values: map[string]string{}}
+	for _, f := range fields {
+		ks, ok := keys[f]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q, want one of %s", f, strings.Join(Fields, ", "))
+		}
+		for _, k := range ks {
+			r.keys[k] = f
+		}
+	}
+	return r, nil
+}
+
+// Enabled reports whether field is redacted.
+func (r *Redactor) Enabled(field string) bool {
+	if r == nil {
+		return false
+	}
+	for _, f := range r.keys {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns v of field, masked if the field is redacted; v is then
+// masked in text from now on.
+func (r *Redactor) Value(field, v string) string {
+	if !r.Enabled(field) || v == "" {
+		return v
+	}
+	m := Mask(v)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.values[v]; !ok {
+		r.values[v] = m
+		r.compile()
+	}
+	return m
+}
+
+// Prefix masks in text, from now on, the values of field starting with
+// prefix, up to the next space or quote: the image URLs of a store, say.
+func (r *Redactor) Prefix(field, prefix string) {
+	if !r.Enabled(field) || prefix == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.prefixes, prefix) {
+		r.prefixes = append(r.prefixes, prefix)
+		r.compile()
+	}
+}
+
+// compile rebuilds the pattern of the values and prefixes; r.mu is held.
+func (r *Redactor) compile() {
+	var alts []string
+	for _, p := range r.prefixes {
+		alts = append(alts, regexp.QuoteMeta(p)+`[^\s"'<>]*`)
+	}
+	values := make([]string, 0, len(r.values))
+	for v := range r.values {
+		values = append(values, v)
+	}
+	// Longest first, so a value containing another is masked whole.
+	slices.SortFunc(values, func(a, b string) int { return cmp.Or(len(b)-len(a), strings.Compare(a, b)) })
+	for _, v := range values {
+		alts = append(alts, boundary(v[:1])+regexp.QuoteMeta(v)+boundary(v[len(v)-1:]))
+	}
+	r.re = regexp.MustCompile(strings.Join(alts, "|"))
+}
+
+var wordChar = regexp.MustCompile(`^\w$`)
+
+// boundary returns a word boundary next to c if c is a word character, so
+// that a meter ID "home" is not masked within "homely".
+func boundary(c string) string {
+	if wordChar.MatchString(c) {
+		return `\b`
+	}
+	return ""
+}
+
+// Text returns s with every value seen, or starting with a prefix, masked.
+func (r *Redactor) Text(s string) string {
+	if r == nil {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.re == nil {
+		return s
+	}
+	return r.re.ReplaceAllStringFunc(s, func(v string) string {
+		if m, ok := r.values[v]; ok {
+			return m
+		}
+		return Mask(v)
+	})
+}
+
+// Writer returns a writer redacting the text written to w, such as the
+// output of the standard logger, which writes a line at a time.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return writer{r: r, w: w}
+}
+
+type writer struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.r.Text(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// JSON returns the JSON document b with the values of the redacted fields
+// masked at any depth, also within strings holding JSON, such as raw model
+// output, and the values seen masked in every other string.
+func (r *Redactor) JSON(b []byte) ([]byte, error) {
+	if r == nil {
+		return b, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	// Mask the fields first, so that their values are known when the other
+	// strings are masked.
+	r.fields(v)
+	return json.Marshal(r.texts(v))
+}
+
+// fields masks the values of the redacted fields in v.
+func (r *Redactor) fields(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if f, ok := r.keys[k]; ok {
+				if s, ok := e.(string); ok {
+					v[k] = r.Value(f, s)
+					continue
+				}
+			}
+			r.fields(e)
+		}
+	case []any:
+		for _, e := range v {
+			r.fields(e)
+		}
+	}
+}
+
+// texts masks the values seen in the other strings of v.
+func (r *Redactor) texts(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = r.texts(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = r.texts(e)
+		}
+	case string:
+		if t := strings.TrimSpace(v); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+			if b, err := r.JSON([]byte(t)); err == nil {
+				return string(b)
+			}
+		}
+		return r.Text(v)
+	}
+	return v
+}
+
+// Clone returns a copy of v, a value encoded as JSON, with the redacted
+// fields masked as by [Redactor.JSON]. Fields not encoded, such as image
+// bytes, are left out of the copy. A nil Redactor returns v itself; if the
+// copy fails the zero value is returned rather than anything unmasked.
+func Clone[T any](r *Redactor, v *T) *T {
+	if r == nil || v == nil {
+		return v
+	}
+	var c T
+	b, err := json.Marshal(v)
+	if err == nil {
+		b, err = r.JSON(b)
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return new(T)
+	}
+	return &c
+}
@@ -0,0 +1,123 @@
+package redact_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/redact"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+func TestMask(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{
+		"":             "",
+		"home":         "…",
+		"GM2019-44113": "…4113",
+		"가스계량기-거실":     "…기-거실", // runes, not bytes
+	} {
+		if got := redact.Mask(in); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	r, err := redact.New([]string{redact.SerialNumber, redact.SourceImage})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r.Prefix(redact.SourceImage, "http://concierge:8080/api/v1/luggage/")
+	in := `{"meter_id":"apartment-1203","read":"02924.457","serial_number":"GM2019-44113",` +
+		`"src_image_url":"http://concierge:8080/api/v1/luggage/a1b2c3d4",` +
+		`"response":"{\"read\":\"02924.457\",\"serial_number\":\"GM2019-44113\"}",` +
+		`"message":"serial GM2019-44113 at http://concierge:8080/api/v1/luggage/a1b2c3d4"}`
+	b, err := r.JSON([]byte(in))
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	out := string(b)
+	if strings.Contains(out, "44113") || strings.Contains(out, "a1b2c3d4") {
+		t.Fatalf("JSON leaks:\n%s", out)
+	}
+	for _, want := range []string{
+		`"serial_number":"…4113"`,
+		`"meter_id":"apartment-1203"`, // not a redacted field
+		`"src_image_url":"…c3d4"`,
+		`"message":"serial …4113 at …c3d4"`,
+		`\"serial_number\":\"…4113\"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("JSON lacks %s:\n%s", want, out)
+		}
+	}
+	if _, err := redact.New([]string{"address"}); err == nil {
+		t.Fatalf("New accepted an unknown field")
+	}
+}
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	r, _ := redact.New(redact.Fields)
+	r.Value(redact.MeterID, "home")
+	r.Value(redact.SerialNumber, "GM2019-44113")
+	var b strings.Builder
+	w := r.Writer(&b)
+	w.Write([]byte(`Event anomaly for meter "home": homely usage of GM2019-44113` + "\n"))
+	if want := `Event anomaly for meter "…": homely usage of …4113` + "\n"; b.String() != want {
+		t.Fatalf("written %q, want %q", b.String(), want)
+	}
+
+	var none *redact.Redactor
+	if got := none.Text("GM2019-44113"); got != "GM2019-44113" || none.Value(redact.SerialNumber, "x") != "x" {
+		t.Fatalf("nil Redactor masked %q", got)
+	}
+}
+
+// recorder is a sink taking corrections, but not consumption.
+type recorder struct {
+	meters  []string
+	serials []string
+}
+
+func (s *recorder) Publish(_ context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	s.meters, s.serials = append(s.meters, meterID), append(s.serials, r.SerialNumber)
+	return nil
+}
+
+func (s *recorder) PublishCorrection(ctx context.Context, c sink.CorrectionEvent) error {
+	return s.Publish(ctx, c.MeterID, c.Reading)
+}
+
+func (s *recorder) Close() error { return nil }
+
+func TestSink(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	r, _ := redact.New([]string{redact.MeterID, redact.SerialNumber})
+	rec := &recorder{}
+	s := redact.Sink(r, rec)
+	res := &genai.GasMeterReadResult{Read: "02924.457", SerialNumber: "GM2019-44113"}
+	if err := s.Publish(ctx, "apartment-1203", res); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := s.(sink.CorrectionSink).PublishCorrection(ctx, sink.NewCorrectionEvent("apartment-1203", "ops", res)); err != nil {
+		t.Fatalf("PublishCorrection: %v", err)
+	}
+	if err := s.(sink.ConsumptionSink).PublishConsumption(ctx, sink.Consumption{MeterID: "apartment-1203"}); err != nil {
+		t.Fatalf("PublishConsumption: %v", err)
+	}
+	if strings.Join(rec.meters, " ") != "…1203 …1203" || strings.Join(rec.serials, " ") != "…4113 …4113" {
+		t.Fatalf("published %v %v", rec.meters, rec.serials)
+	}
+	if res.SerialNumber != "GM2019-44113" {
+		t.Fatalf("the reading itself was masked: %q", res.SerialNumber)
+	}
+}
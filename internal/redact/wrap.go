@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+// Learn masks in text from now on the values of the redacted fields of v, a
+// value encoded as JSON, such as a result about to be printed as text.
+func (r *Redactor) Learn(v any) {
+	if r == nil {
+		return
+	}
+	if b, err := json.Marshal(v); err == nil {
+		r.JSON(b)
+	}
+}
+
+// Auditor returns next taking entries redacted by r.
+func Auditor(r *Redactor, next genai.Auditor) genai.Auditor {
+	if r == nil {
+		return next
+	}
+	return auditor{r: r, next: next}
+}
+
+type auditor struct {
+	r    *Redactor
+	next genai.Auditor
+}
+
+func (a auditor) Audit(e genai.AuditEntry) { a.next.Audit(*Clone(a.r, &e)) }
+
+// Sink returns next taking readings, their corrections and their
+// consumption redacted by r.
+func Sink(r *Redactor, next sink.Sink) sink.Sink {
+	if r == nil {
+		return next
+	}
+	return &redactedSink{r: r, next: next}
+}
+
+type redactedSink struct {
+	r    *Redactor
+	next sink.Sink
+}
+
+func (s *redactedSink) Publish(ctx context.Context, meterID string, res *genai.GasMeterReadResult) error {
+	return s.next.Publish(ctx, s.r.Value(MeterID, meterID), Clone(s.r, res))
+}
+
+// PublishConsumption implements [sink.ConsumptionSink] if next does.
+func (s *redactedSink) PublishConsumption(ctx context.Context, c sink.Consumption) error {
+	if cs, ok := s.next.(sink.ConsumptionSink); ok {
+		c.MeterID = s.r.Value(MeterID, c.MeterID)
+		return cs.PublishConsumption(ctx, c)
+	}
+	return nil
+}
+
+// PublishCorrection implements [sink.CorrectionSink] if next does.
+func (s *redactedSink) PublishCorrection(ctx context.Context, c sink.CorrectionEvent) error {
+	if cs, ok := s.next.(sink.CorrectionSink); ok {
+		return cs.PublishCorrection(ctx, *Clone(s.r, &c))
+	}
+	return nil
+}
+
+func (s *redactedSink) Close() error { return s.next.Close() }
+
+// Notifier returns next taking events redacted by r. The Data of a
+// redacted event is its JSON object; the image is kept.
+func Notifier(r *Redactor, next notify.Notifier) notify.Notifier {
+	if r == nil {
+		return next
+	}
+	return notify.Func(func(ctx context.Context, e notify.Event) error {
+		c := Clone(r, &e)
+		c.Image = e.Image
+		return next.Notify(ctx, *c)
+	})
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// issueWindow is the widest span of hours reported as the time of day an
+// issue clusters in.
+const issueWindow = 6
+
+// IssueCount is how often an image issue was reported.
+type IssueCount struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	// Hours is the time of day all of them were reported in, e.g.
+	// "15:00–17:00", when that spans at most six hours; glare at the same time
+	// every day points at the sun rather than the prompt.
+	Hours string `json:"hours,omitempty"`
+}
+
+// String is e.g. "glare: 14 times, all between 15:00–17:00".
+func (ic IssueCount) String() string {
+	s := fmt.Sprintf("%s: %d times", ic.Kind, ic.Count)
+	if ic.Count == 1 {
+		s = ic.Kind + ": once"
+	}
+	if ic.Hours != "" {
+		s += ", all between " + ic.Hours
+	}
+	return s
+}
+
+// CountIssues counts the issues reported with rs by kind, most frequent
+// first, with the hours of the day in loc they were reported in.
+func CountIssues(rs []*genai.GasMeterReadResult, loc *time.Location) []IssueCount {
+	type tally struct {
+		count          int
+		first, through int // hours of the day
+	}
+	tallies := make(map[string]*tally)
+	for _, r := range rs {
+		if r.Issue == nil || r.Stale {
+			continue
+		}
+		h := r.ReadAt.In(loc).Hour()
+		t, ok := tallies[r.Issue.Kind]
+		if !ok {
+			t = &tally{first: h, through: h}
+			tallies[r.Issue.Kind] = t
+		}
+		t.count++
+		t.first, t.through = min(t.first, h), max(t.through, h)
+	}
+	out := make([]IssueCount, 0, len(tallies))
+	for kind, t := range tallies {
+		ic := IssueCount{Kind: kind, Count: t.count}
+		if t.through+1-t.first <= issueWindow {
+			ic.Hours = fmt.Sprintf("%02d:00–%02d:00", t.first, t.through+1)
+		}
+		out = append(out, ic)
+	}
+	slices.SortFunc(out, func(a, b IssueCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Kind, b.Kind))
+	})
+	return out
+}
@@ -0,0 +1,563 @@
+// Package report summarises the consumption of a day, week or month for
+// people, e.g. "This week you used 14.3 m³ (≈158 kWh, ≈€16.40), 8% less than
+// the week before."
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Period is the length of a report.
+type Period string
+
+const (
+	Daily   Period = "day"
+	Weekly  Period = "week" // Monday to Sunday
+	Monthly Period = "month"
+)
+
+// Validate checks that p is a known period.
+func (p Period) Validate() error {
+	switch p {
+	case Daily, Weekly, Monthly:
+		return nil
+	}
+	return fmt.Errorf("unknown period %q", p)
+}
+
+// Bounds returns the period [from, to) containing t, aligned to midnight in loc.
+func (p Period) Bounds(t time.Time, loc *time.Location) (from, to time.Time) {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch p {
+	case Weekly:
+		from = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 0, 7)
+	case Monthly:
+		from = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 1, 0)
+	default:
+		from = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 0, 1)
+	}
+}
+
+// Config is what reports are generated with.
+type Config struct {
+	Meter genai.Meter
+	// Tariff prices the consumption when set.
+	Tariff *billing.Tariff
+	// Location is the time zone periods align to (default time.Local).
+	Location *time.Location
+	// MaxGap is the longest gap between readings consumption is
+	// interpolated across at a period boundary (0: no limit); a period with
+	// a boundary in a longer gap is incomplete.
+	MaxGap time.Duration
+	// Gaps attributes the consumption across a gap marked on a reading,
+	// billing.GapSpread (default) or billing.GapAtEnd.
+	Gaps string
+	// Anchor lists the daily anchor readings of the period when set.
+	Anchor *anchor.Config
+	// Baseline adds the consumption since the start of the contract when set.
+	Baseline *billing.Baseline
+	// NumberLocale formats the numbers of the text and Markdown renderings;
+	// the JSON keeps plain numbers.
+	NumberLocale numfmt.Locale
+}
+
+// GapAttribution returns Gaps, or its default.
+func (c Config) GapAttribution() string {
+	if c.Gaps == "" {
+		return billing.GapSpread
+	}
+	return c.Gaps
+}
+
+// Gap is a stretch without readings, ending with a reading marked with
+// [genai.GasMeterReadResult.GapBefore].
+type Gap struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Duration string    `json:"duration"`
+}
+
+// TimeZone returns Location or time.Local.
+func (c Config) TimeZone() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// Day is the consumption of one day of a report.
+type Day struct {
+	Date  string        `json:"date"`
+	Usage billing.Usage `json:"usage"`
+	// Incomplete is set if the consumption of part of the day is unknown.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Report is the consumption of a meter in a period.
+type Report struct {
+	MeterID string    `json:"meter_id"`
+	Period  Period    `json:"period"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// Until is To, or the time the report was generated at for a period in
+	// progress; consumption and cost are up to Until.
+	Until    time.Time     `json:"until"`
+	Unit     string        `json:"unit"`
+	Readings int           `json:"readings"`
+	Usage    billing.Usage `json:"usage"`
+	// Incomplete is set if a boundary of the period falls in a gap of more
+	// than [Config.MaxGap] or outside the readings; Usage then leaves out the
+	// consumption of the gap.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Cost is set when a tariff is configured.
+	Cost *billing.Estimate `json:"cost,omitempty"`
+	// MinDay and MaxDay are the complete days of the least and most
+	// consumption.
+	MinDay *Day `json:"min_day,omitempty"`
+	MaxDay *Day `json:"max_day,omitempty"`
+	// Previous is the consumption of the same stretch of the previous
+	// period, and Change the relative change from it (-0.08 is 8% less). They
+	// are unset without readings before the period; Change also when
+	// Previous is zero or either stretch is incomplete.
+	Previous *billing.Usage `json:"previous,omitempty"`
+	Change   *float64       `json:"change,omitempty"`
+	// Issues counts the image issues reported with the readings of the
+	// period, most frequent first.
+	Issues []IssueCount `json:"issues,omitempty"`
+	// Gaps are the gaps ending in the period, and GapAttribution how their
+	// consumption is counted; see [Config.Gaps].
+	Gaps           []Gap  `json:"gaps,omitempty"`
+	GapAttribution string `json:"gap_attribution"`
+	// Exchanges are the exchanges of the meter marked by a reading of the
+	// period; the consumption runs up to the final reading of the old meter
+	// and on from the start of the new one.
+	Exchanges []genai.Exchange `json:"exchanges,omitempty"`
+	// Anchors are the daily anchor readings of the period up to Until, with
+	// [Config.Anchor].
+	Anchors []anchor.Anchor `json:"anchors,omitempty"`
+	// Cumulative is the consumption since the baseline at BaselineAt up to
+	// Until, with [Config.Baseline]; see [billing.Since].
+	Cumulative *billing.Usage `json:"cumulative_since_baseline,omitempty"`
+	BaselineAt time.Time      `json:"baseline_at,omitzero"`
+
+	currency billing.Currency
+	numbers  numfmt.Locale
+}
+
+// Generate returns the report of the period p containing at for meterID
+// from the history in s as of now; a period that has not ended by now is
+// reported up to now. Consumption at the period boundaries is interpolated
+// linearly between the readings on either side, so that the readings of a
+// day need not be taken at midnight, unless they are more than cfg.MaxGap
+// apart.
+func Generate(ctx context.Context, s store.Store, cfg Config, meterID string, p Period, at, now time.Time) (*Report, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	loc := cfg.TimeZone()
+	var tariff billing.Tariff // without one consumption is left uncorrected
+	if cfg.Tariff != nil {
+		tariff = *cfg.Tariff
+	}
+	from, to := p.Bounds(at, loc)
+	prevFrom, _ := p.Bounds(from.Add(-time.Nanosecond), loc)
+	// A period's worth of margin finds the nearest readings past the bounds.
+	margin := to.Sub(from)
+	rs, err := s.ReadingsBetween(ctx, meterID, prevFrom.Add(-margin), to.Add(margin))
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	ps := tariff.Cumulative(cfg.Meter, rs)
+	if cfg.GapAttribution() == billing.GapAtEnd {
+		billing.MarkGaps(ps, rs)
+	}
+
+	until := to
+	if now.Before(to) {
+		until = now
+	}
+	r := &Report{
+		MeterID:        meterID,
+		Period:         p,
+		From:           from,
+		To:             to,
+		Until:          until,
+		Unit:           cfg.Meter.Unit,
+		GapAttribution: cfg.GapAttribution(),
+		currency:       tariff.Currency,
+		numbers:        cfg.NumberLocale,
+	}
+	var complete bool
+	r.Usage, complete = span(ps, from, until, to, cfg.MaxGap)
+	r.Incomplete = !complete
+	var inPeriod []*genai.GasMeterReadResult
+	for _, rd := range rs {
+		if !rd.ReadAt.Before(from) && rd.ReadAt.Before(until) {
+			inPeriod = append(inPeriod, rd)
+		}
+	}
+	r.Readings = len(inPeriod)
+	for _, rd := range inPeriod {
+		if d := rd.Gap(); d > 0 {
+			r.Gaps = append(r.Gaps, Gap{From: rd.ReadAt.Add(-d), To: rd.ReadAt, Duration: rd.GapBefore})
+		}
+		if e := rd.MeterExchanged; e != nil {
+			r.Exchanges = append(r.Exchanges, *e)
+		}
+	}
+	r.Issues = CountIssues(inPeriod, loc)
+	if cfg.Anchor != nil {
+		// The margin of the readings covers the anchors' distance of a day at most.
+		r.Anchors = anchor.FromReadings(*cfg.Anchor, cfg.Meter, from, until, loc, rs)
+	}
+	if b := cfg.Baseline; b != nil && b.At.Before(until) {
+		// The consumption is counted from the configured value of the
+		// baseline, so the history is only needed from then on.
+		since, err := s.ReadingsBetween(ctx, meterID, b.At, until)
+		if err != nil {
+			return nil, fmt.Errorf("load history since the baseline: %w", err)
+		}
+		r.Cumulative, r.BaselineAt = &tariff.SinceBaseline(cfg.Meter, *b, since).Usage, b.At
+	}
+	if cfg.Tariff != nil {
+		e := tariff.Cost(r.Usage, until.Sub(from).Hours()/24)
+		r.Cost = &e
+	}
+	for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+		if len(ps) == 0 || !day.Before(ps[len(ps)-1].At) {
+			break // no readings yet
+		}
+		end := day.AddDate(0, 0, 1)
+		if end.After(until) {
+			end = until
+		}
+		d := Day{Date: day.Format(time.DateOnly)}
+		d.Usage, complete = span(ps, day, end, day.AddDate(0, 0, 1), cfg.MaxGap)
+		if d.Incomplete = !complete; d.Incomplete {
+			continue
+		}
+		if r.MinDay == nil || d.Usage.Raw < r.MinDay.Usage.Raw {
+			r.MinDay = &d
+		}
+		if r.MaxDay == nil || d.Usage.Raw > r.MaxDay.Usage.Raw {
+			r.MaxDay = &d
+		}
+	}
+	if len(ps) > 0 && ps[0].At.Before(from) {
+		prev, complete := billing.Span(ps, prevFrom, prevFrom.Add(until.Sub(from)), cfg.MaxGap)
+		r.Previous = &prev
+		if prev.Raw > 0 && complete && !r.Incomplete {
+			change := r.Usage.Raw/prev.Raw - 1
+			r.Change = &change
+		}
+	}
+	return r, nil
+}
+
+// span is the consumption of ps in [from, until) of the period [from, to).
+// In a period in progress until is now, past the last reading, so the
+// consumption since it is extrapolated as by [billing.UsageAt] and only the
+// start counts towards complete.
+func span(ps []billing.Point, from, until, to time.Time, maxGap time.Duration) (billing.Usage, bool) {
+	if until.Equal(to) {
+		return billing.Span(ps, from, until, maxGap)
+	}
+	a, ok := billing.Edge(ps, from, maxGap, true)
+	u := billing.UsageAt(ps, until).Sub(a)
+	if u.Raw < 0 {
+		u = billing.Usage{}
+	}
+	return u, ok
+}
+
+// InProgress reports whether the period had not ended when r was generated.
+func (r *Report) InProgress() bool { return r.Until.Before(r.To) }
+
+// Title names the period, e.g. "Week of 2025-11-03".
+func (r *Report) Title() string {
+	var t string
+	switch r.Period {
+	case Monthly:
+		t = r.From.Format("January 2006")
+	case Weekly:
+		t = "Week of " + r.From.Format(time.DateOnly)
+	default:
+		t = r.From.Format("Monday, 2006-01-02")
+	}
+	if r.InProgress() {
+		t += " (so far)"
+	}
+	return t
+}
+
+// lead starts the summary, e.g. "This week" or "In the week of 2025-11-03".
+func (r *Report) lead() string {
+	if r.InProgress() {
+		if r.Period == Daily {
+			return "Today"
+		}
+		return "This " + string(r.Period)
+	}
+	switch r.Period {
+	case Monthly:
+		return "In " + r.From.Format("January 2006")
+	case Weekly:
+		return "In the week of " + r.From.Format(time.DateOnly)
+	default:
+		return "On " + r.From.Format(time.DateOnly)
+	}
+}
+
+// Summary is the one-line digest of r.
+func (r *Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s you used %s %s", r.lead(), r.num(r.Usage.Raw, 1), r.Unit)
+	var extra []string
+	if r.Usage.Energy > 0 {
+		extra = append(extra, "≈"+r.num(r.Usage.Energy, 0)+" kWh")
+	}
+	if r.Cost != nil {
+		extra = append(extra, "≈"+r.Cost.Formatted)
+	}
+	if len(extra) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(extra, ", "))
+	}
+	if r.Change != nil {
+		b.WriteString(", " + r.changeText())
+	}
+	b.WriteString(".")
+	return b.String()
+}
+
+// changeText is e.g. "8% less than the week before".
+func (r *Report) changeText() string {
+	pct := math.Round(math.Abs(*r.Change) * 100)
+	than := "the " + string(r.Period) + " before"
+	switch {
+	case pct == 0:
+		return "about the same as " + than
+	case *r.Change < 0:
+		return fmt.Sprintf("%.0f%% less than %s", pct, than)
+	default:
+		return fmt.Sprintf("%.0f%% more than %s", pct, than)
+	}
+}
+
+// incompleteNote explains an incomplete report.
+const incompleteNote = "Incomplete: readings are missing at a boundary of the period; the consumption of the gap is not counted."
+
+// Text renders r as plain text.
+func (r *Report) Text() string {
+	var b strings.Builder
+	b.WriteString(r.Summary() + "\n")
+	if r.MinDay != nil && r.Period != Daily {
+		fmt.Fprintf(&b, "Least on %s: %s %s\n", r.MinDay.Date, r.num(r.MinDay.Usage.Raw, 1), r.Unit)
+		fmt.Fprintf(&b, "Most on %s: %s %s\n", r.MaxDay.Date, r.num(r.MaxDay.Usage.Raw, 1), r.Unit)
+	}
+	if r.Cost != nil {
+		fmt.Fprintf(&b, "Estimated cost: %s (standing %s, usage %s)\n",
+			r.Cost.Formatted, r.currency.Format(r.Cost.Standing), r.currency.Format(r.Cost.Charge))
+	}
+	if r.Cumulative != nil {
+		fmt.Fprintf(&b, "Since the contract start on %s: %s\n", r.baselineDate(), r.cumulativeText())
+	}
+	if r.Incomplete {
+		b.WriteString(incompleteNote + "\n")
+	}
+	if len(r.Issues) > 0 {
+		b.WriteString("Image issues:\n")
+		for _, ic := range r.Issues {
+			fmt.Fprintf(&b, "  %s\n", ic)
+		}
+	}
+	if len(r.Gaps) > 0 {
+		fmt.Fprintf(&b, "Gaps in the readings (%s):\n", r.gapNote())
+		for _, g := range r.Gaps {
+			fmt.Fprintf(&b, "  %s\n", g.text(r.From.Location()))
+		}
+	}
+	for _, e := range r.Exchanges {
+		fmt.Fprintf(&b, "Meter exchanged on %s\n", exchangeText(e, r.From.Location()))
+	}
+	if len(r.Anchors) > 0 {
+		b.WriteString("Daily anchors:\n")
+		for _, a := range r.Anchors {
+			fmt.Fprintf(&b, "  %s\n", anchorText(a))
+		}
+	}
+	return b.String()
+}
+
+// num formats v with decimals digits in the number locale of r.
+func (r *Report) num(v float64, decimals int) string {
+	return r.numbers.Float(v, decimals)
+}
+
+// In returns a copy of r rendering its numbers in locale l.
+func (r *Report) In(l numfmt.Locale) *Report {
+	c := *r
+	c.numbers = l
+	return &c
+}
+
+// baselineDate is the local date of the baseline.
+func (r *Report) baselineDate() string {
+	return r.BaselineAt.In(r.From.Location()).Format(time.DateOnly)
+}
+
+// cumulativeText is e.g. "320.5 m³ (corrected 310.2 m³, ≈3474 kWh)".
+func (r *Report) cumulativeText() string {
+	u := r.Cumulative
+	t := r.num(u.Raw, 1) + " " + r.Unit
+	var extra []string
+	if u.Corrected != u.Raw {
+		extra = append(extra, "corrected "+r.num(u.Corrected, 1)+" "+r.Unit)
+	}
+	if u.Energy > 0 {
+		extra = append(extra, "≈"+r.num(u.Energy, 0)+" kWh")
+	}
+	if len(extra) > 0 {
+		t += " (" + strings.Join(extra, ", ") + ")"
+	}
+	return t
+}
+
+// gapNote tells how the consumption of the gaps was counted.
+func (r *Report) gapNote() string {
+	if r.GapAttribution == billing.GapAtEnd {
+		return "consumption counted at the reading after each"
+	}
+	return "consumption spread evenly over each"
+}
+
+// text is e.g. "2025-11-05 08:00 – 2025-11-07 08:00 (48h0m0s)".
+func (g Gap) text(loc *time.Location) string {
+	const layout = "2006-01-02 15:04"
+	return fmt.Sprintf("%s – %s (%s)", g.From.In(loc).Format(layout), g.To.In(loc).Format(layout), g.Duration)
+}
+
+// exchangeText is e.g. "2025-11-07 09:00: the old meter's final reading
+// 09876.500, the new meter GM-2 from 00000.100".
+func exchangeText(e genai.Exchange, loc *time.Location) string {
+	const layout = "2006-01-02 15:04"
+	final, meter, start := e.Previous, "the new meter", e.Start
+	if final == "" {
+		final = "unknown"
+	}
+	if e.Serial != "" {
+		meter += " " + e.Serial
+	}
+	if start == "" {
+		start = "zero"
+	}
+	return fmt.Sprintf("%s: the old meter's final reading %s, %s from %s", e.At.In(loc).Format(layout), final, meter, start)
+}
+
+// anchorText is e.g. "2025-11-07 00:00: 02924.457 (read at 00:12)" or
+// "2025-11-07 00:00: 02924.451 (interpolated)".
+func anchorText(a anchor.Anchor) string {
+	const layout = "2006-01-02 15:04"
+	how := "interpolated"
+	if !a.Interpolated {
+		how = "read at " + a.ReadAt.In(a.At.Location()).Format("15:04")
+		if a.ReadAt.In(a.At.Location()).Format(time.DateOnly) != a.Date {
+			how = "read at " + a.ReadAt.In(a.At.Location()).Format(layout)
+		}
+	}
+	return fmt.Sprintf("%s: %s (%s)", a.At.Format(layout), a.Read, how)
+}
+
+// Markdown renders r as a Markdown section.
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n%s\n\n", r.Title(), r.Summary())
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Period | %s – %s |\n", r.From.Format(time.DateOnly), r.To.AddDate(0, 0, -1).Format(time.DateOnly))
+	fmt.Fprintf(&b, "| Consumption | %s %s |\n", r.num(r.Usage.Raw, 3), r.Unit)
+	if r.Usage.Corrected != r.Usage.Raw {
+		fmt.Fprintf(&b, "| Corrected | %s %s |\n", r.num(r.Usage.Corrected, 3), r.Unit)
+	}
+	if r.Usage.Energy > 0 {
+		fmt.Fprintf(&b, "| Energy | %s kWh |\n", r.num(r.Usage.Energy, 1))
+	}
+	if r.Cost != nil {
+		fmt.Fprintf(&b, "| Estimated cost | %s |\n", r.Cost.Formatted)
+	}
+	if r.MinDay != nil && r.Period != Daily {
+		fmt.Fprintf(&b, "| Least | %s %s on %s |\n", r.num(r.MinDay.Usage.Raw, 3), r.Unit, r.MinDay.Date)
+		fmt.Fprintf(&b, "| Most | %s %s on %s |\n", r.num(r.MaxDay.Usage.Raw, 3), r.Unit, r.MaxDay.Date)
+	}
+	if r.Previous != nil {
+		fmt.Fprintf(&b, "| Previous %s | %s %s |\n", r.Period, r.num(r.Previous.Raw, 3), r.Unit)
+	}
+	if r.Cumulative != nil {
+		fmt.Fprintf(&b, "| Since %s | %s |\n", r.baselineDate(), r.cumulativeText())
+	}
+	fmt.Fprintf(&b, "| Readings | %d |\n", r.Readings)
+	for _, ic := range r.Issues {
+		fmt.Fprintf(&b, "| Issue | %s |\n", ic)
+	}
+	for _, g := range r.Gaps {
+		fmt.Fprintf(&b, "| Gap | %s, %s |\n", g.text(r.From.Location()), r.gapNote())
+	}
+	for _, e := range r.Exchanges {
+		fmt.Fprintf(&b, "| Meter exchanged | %s |\n", exchangeText(e, r.From.Location()))
+	}
+	for _, a := range r.Anchors {
+		fmt.Fprintf(&b, "| Anchor | %s |\n", anchorText(a))
+	}
+	if r.Incomplete {
+		b.WriteString("\n" + incompleteNote + "\n")
+	}
+	return b.String()
+}
+
+// JSON renders r as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Render renders r in format: "text" (default), "markdown" or "json".
+func (r *Report) Render(format string) (string, error) {
+	switch format {
+	case "", "text":
+		return r.Text(), nil
+	case "markdown", "md":
+		return r.Markdown(), nil
+	case "json":
+		b, err := r.JSON()
+		return string(b) + "\n", err
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Event returns the digest notification of r, with r as its data; notifiers
+// with a number locale of their own get the text in it.
+func (r *Report) Event() notify.Event {
+	return notify.Event{
+		Kind:     "digest",
+		Severity: notify.Info,
+		MeterID:  r.MeterID,
+		Time:     r.Until,
+		Message:  r.Text(),
+		Data:     r,
+		Localize: func(l numfmt.Locale) string { return r.In(l).Text() },
+	}
+}
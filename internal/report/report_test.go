@@ -0,0 +1,253 @@
+package report_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/report"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestBounds(t *testing.T) {
+	t.Parallel()
+
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// 2025-11-09 16:30 UTC is Monday 01:30 in Seoul.
+	at := time.Date(2025, 11, 9, 16, 30, 0, 0, time.UTC)
+	tests := []struct {
+		p        report.Period
+		loc      *time.Location
+		from, to string
+	}{
+		{report.Daily, seoul, "2025-11-10", "2025-11-11"},
+		{report.Weekly, seoul, "2025-11-10", "2025-11-17"},
+		{report.Weekly, time.UTC, "2025-11-03", "2025-11-10"},
+		{report.Monthly, seoul, "2025-11-01", "2025-12-01"},
+	}
+	for _, tt := range tests {
+		from, to := tt.p.Bounds(at, tt.loc)
+		if got := from.Format(time.DateOnly) + " " + to.Format(time.DateOnly); got != tt.from+" "+tt.to {
+			t.Fatalf("%s.Bounds in %s = %s; want %s %s", tt.p, tt.loc, got, tt.from, tt.to)
+		}
+		if from.Location() != tt.loc || from.Hour() != 0 {
+			t.Fatalf("%s.Bounds from = %s; want midnight in %s", tt.p, from, tt.loc)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	seoul, err := time.LoadLocation("Asia/Seoul")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	ctx := context.Background()
+	s := store.NewMemory()
+	// A reading at noon every day: 2 m³ a day until 2025-11-10 noon and
+	// 1 m³ a day after, none on 2025-11-13.
+	var v float64
+	for day := time.Date(2025, 10, 30, 12, 0, 0, 0, seoul); day.Day() != 18; day = day.AddDate(0, 0, 1) {
+		if day.Before(time.Date(2025, 11, 10, 13, 0, 0, 0, seoul)) {
+			v += 2
+		} else {
+			v++
+		}
+		if day.Day() == 13 {
+			continue
+		}
+		r := &genai.GasMeterReadResult{Read: fmt.Sprintf("%05.0f.000", v), ReadAt: day}
+		if day.Day() == 14 {
+			r.GapBefore = "48h0m0s"
+		}
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := report.Config{
+		Meter: genai.DefaultMeter,
+		Tariff: &billing.Tariff{
+			Unit: billing.UnitKWh, UnitPrice: 0.1, CalorificValue: 36, // 10 kWh per m³
+			Currency: billing.Currency{Symbol: "€", Decimals: 2},
+		},
+		Location: seoul,
+	}
+
+	r, err := report.Generate(ctx, s, cfg, "home", report.Weekly, time.Date(2025, 11, 12, 0, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.InProgress() {
+		t.Fatalf("Until = %s; want the end of the week %s", r.Until, r.To)
+	}
+	// Half a day at 2 m³ a day after midnight, then 6.5 days at 1 m³; the
+	// week before is 7 days at 2 m³.
+	if math.Abs(r.Usage.Raw-7.5) > 1e-9 || math.Abs(r.Previous.Raw-14) > 1e-9 || r.Readings != 6 {
+		t.Fatalf("Usage = %+v, previous %+v, readings %d; want 7.5, 14, 6", r.Usage, r.Previous, r.Readings)
+	}
+	if r.MaxDay.Date != "2025-11-10" || math.Abs(r.MaxDay.Usage.Raw-1.5) > 1e-9 || math.Abs(r.MinDay.Usage.Raw-1) > 1e-9 {
+		t.Fatalf("MinDay = %+v, MaxDay = %+v; want 1 m³, 1.5 m³ on 2025-11-10", r.MinDay, r.MaxDay)
+	}
+	want := "In the week of 2025-11-10 you used 7.5 m³ (≈75 kWh, ≈€7.50), 46% less than the week before."
+	if got := r.Summary(); got != want {
+		t.Fatalf("Summary = %q; want %q", got, want)
+	}
+	if md := r.Markdown(); !strings.HasPrefix(md, "## Week of 2025-11-10\n") || !strings.Contains(md, "| Estimated cost | €7.50 |") {
+		t.Fatalf("Markdown = %q", md)
+	}
+	if len(r.Gaps) != 1 || !r.Gaps[0].From.Equal(time.Date(2025, 11, 12, 12, 0, 0, 0, seoul)) || r.GapAttribution != billing.GapSpread ||
+		!strings.Contains(r.Text(), "Gaps in the readings (consumption spread evenly over each):\n  2025-11-12 12:00 – 2025-11-14 12:00 (48h0m0s)\n") {
+		t.Fatalf("Gaps = %+v, text %q", r.Gaps, r.Text())
+	}
+	out, err := r.Render("json")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	var decoded report.Report
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil || decoded.Cost.Formatted != "€7.50" || *decoded.Change >= 0 {
+		t.Fatalf("JSON = %s (%v)", out, err)
+	}
+	// In German the text uses its separators, the JSON keeps plain numbers
+	// and the cost its currency's.
+	de := r.In("de")
+	if got := de.Summary(); !strings.HasPrefix(got, "In the week of 2025-11-10 you used 7,5 m³ (≈75 kWh, ≈€7.50)") ||
+		!strings.Contains(de.Markdown(), "| Consumption | 7,500 m³ |") || r.Markdown() == de.Markdown() {
+		t.Fatalf("Summary in German = %q, Markdown %q", got, de.Markdown())
+	}
+	if out, err := de.Render("json"); err != nil || !strings.Contains(out, `"raw": 7.5`) {
+		t.Fatalf("JSON in German = %s (%v); want plain numbers", out, err)
+	}
+	if e := r.Event(); e.Localize == nil || !strings.Contains(e.Localize("de"), "7,5 m³") || !strings.Contains(e.Message, "7.5 m³") {
+		t.Fatalf("Event = %+v; want German on demand", e)
+	}
+
+	// A week in progress is compared with the same stretch of the week before.
+	r, err = report.Generate(ctx, s, cfg, "home", report.Weekly, time.Date(2025, 11, 13, 0, 0, 0, 0, seoul), time.Date(2025, 11, 13, 0, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want = "This week you used 3.5 m³ (≈35 kWh, ≈€3.50), 42% less than the week before."
+	if got := r.Summary(); got != want || math.Abs(r.Previous.Raw-6) > 1e-9 {
+		t.Fatalf("Summary = %q, previous %+v; want %q, 6 m³", got, r.Previous, want)
+	}
+
+	// With a limit on the gaps interpolated across, the days around the
+	// missing reading of 2025-11-13 are incomplete and left out of the
+	// least and most, but the week is still complete.
+	gapped := cfg
+	gapped.MaxGap = 36 * time.Hour
+	r, err = report.Generate(ctx, s, gapped, "home", report.Weekly, time.Date(2025, 11, 12, 0, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.Incomplete || math.Abs(r.Usage.Raw-7.5) > 1e-9 || r.Change == nil || r.MaxDay.Date != "2025-11-10" || math.Abs(r.MinDay.Usage.Raw-1) > 1e-9 {
+		t.Fatalf("Report = %+v; want a complete 7.5 m³", r)
+	}
+	r, err = report.Generate(ctx, s, gapped, "home", report.Daily, time.Date(2025, 11, 13, 9, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !r.Incomplete || r.Usage.Raw != 0 || !strings.Contains(r.Text(), "Incomplete:") {
+		t.Fatalf("Report = %+v, text %q; want an incomplete day", r, r.Text())
+	}
+
+	// Counted at the end of the gap, its consumption falls on 2025-11-14.
+	atEnd := cfg
+	atEnd.Gaps = billing.GapAtEnd
+	for day, want := range map[int]float64{13: 0, 14: 2.5} {
+		r, err = report.Generate(ctx, s, atEnd, "home", report.Daily, time.Date(2025, 11, day, 9, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if math.Abs(r.Usage.Raw-want) > 1e-9 {
+			t.Fatalf("2025-11-%d usage with gaps at the end = %v; want %v", day, r.Usage.Raw, want)
+		}
+	}
+
+	// The daily anchors at noon are the readings, but for the day without one.
+	anchored := cfg
+	anchored.Anchor = &anchor.Config{At: "12:00"}
+	r, err = report.Generate(ctx, s, anchored, "home", report.Weekly, time.Date(2025, 11, 12, 0, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	var dates []string
+	for _, a := range r.Anchors {
+		dates = append(dates, a.Date)
+	}
+	if strings.Join(dates, " ") != "2025-11-10 2025-11-11 2025-11-12 2025-11-14 2025-11-15 2025-11-16" ||
+		!strings.Contains(r.Text(), "Daily anchors:\n  2025-11-10 12:00: 00024.000 (read at 12:00)\n") {
+		t.Fatalf("Anchors on %q, text %q", dates, r.Text())
+	}
+
+	// A baseline before the first reading counts from its configured value.
+	based := cfg
+	based.Baseline = &billing.Baseline{At: time.Date(2025, 10, 1, 0, 0, 0, 0, seoul), Read: "00000.000"}
+	r, err = report.Generate(ctx, s, based, "home", report.Weekly, time.Date(2025, 11, 12, 0, 0, 0, 0, seoul), time.Date(2025, 11, 18, 9, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.Cumulative == nil || r.Cumulative.Raw != 30 ||
+		!strings.Contains(r.Text(), "Since the contract start on 2025-10-01: 30.0 m³ (≈300 kWh)\n") {
+		t.Fatalf("Cumulative = %+v, text %q; want 30 m³", r.Cumulative, r.Text())
+	}
+
+	// Without history before the period there is nothing to compare with;
+	// October ends half a day after its last reading.
+	r, err = report.Generate(ctx, s, cfg, "home", report.Monthly, time.Date(2025, 10, 31, 0, 0, 0, 0, seoul), time.Date(2025, 11, 18, 0, 0, 0, 0, seoul))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if r.Previous != nil || r.Change != nil || math.Abs(r.Usage.Raw-3) > 1e-9 {
+		t.Fatalf("Report = %+v; want 3 m³ and no previous month", r)
+	}
+}
+
+func TestCountIssues(t *testing.T) {
+	t.Parallel()
+
+	day := time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)
+	reading := func(days, hour, minute int, kind string) *genai.GasMeterReadResult {
+		r := &genai.GasMeterReadResult{Read: "02924.457", ReadAt: day.AddDate(0, 0, days).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)}
+		if kind != "" {
+			r.Issue = &genai.Issue{Kind: kind}
+		}
+		return r
+	}
+	rs := []*genai.GasMeterReadResult{
+		reading(0, 15, 10, genai.IssueGlare),
+		reading(0, 9, 0, ""),
+		reading(1, 16, 40, genai.IssueGlare),
+		reading(2, 15, 55, genai.IssueGlare),
+		reading(2, 6, 0, genai.IssueObstruction),
+		reading(3, 22, 0, genai.IssueObstruction),
+		reading(4, 12, 0, genai.IssueBlur),
+	}
+	stale := reading(5, 12, 0, genai.IssueBlur)
+	stale.Stale = true
+	rs = append(rs, stale)
+
+	var got []string
+	for _, ic := range report.CountIssues(rs, time.UTC) {
+		got = append(got, ic.String())
+	}
+	want := []string{
+		"glare: 3 times, all between 15:00–17:00",
+		"obstruction: 2 times",
+		"blur: once, all between 12:00–13:00",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("CountIssues = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,256 @@
+// Package roi learns where the counter is in a fixed camera's images and
+// crops captures to it, so the model reads a close-up of the digits.
+package roi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// StateKey is the [store.StateStore] key the learned region is saved under.
+const StateKey = "roi"
+
+// DefaultPadding is the padding of [Config] by default.
+const DefaultPadding = 0.15
+
+// Config sets how the region is learned. Zero fields use the defaults noted.
+type Config struct {
+	// Padding grows the learned box by this fraction of its size on every
+	// side before cropping (default [DefaultPadding]).
+	Padding float64
+	// Samples is how many agreeing counter boxes are needed before captures
+	// are cropped (default 5).
+	Samples int
+	// Smoothing is the weight of a new box in the moving average (default 0.3).
+	Smoothing float64
+	// MinIoU is the overlap a box needs with the average to agree with it
+	// (default 0.8). A box that disagrees, e.g. because the camera moved,
+	// starts learning over.
+	MinIoU float64
+	// Freeze keeps the stored region as it is: captures are cropped to it if
+	// it is stable, and new boxes are ignored.
+	Freeze bool
+	// Reset discards the stored region at start.
+	Reset bool
+	// MinSaving is the fraction of its size a capture the padded region
+	// covers whole must lose to be re-encoded; see [genai.Encoder].
+	MinSaving float64
+	// Debug logs whether every crop was re-encoded.
+	Debug bool
+	// Box is a fixed region, e.g. as suggested by the calibrate command:
+	// captures are cropped to it, padded, from the start, and neither the
+	// stored region nor new boxes are used.
+	Box *genai.Box
+}
+
+// State is the learned region of a meter.
+type State struct {
+	Box genai.Box `json:"box"`
+	// Samples is the number of agreeing boxes averaged into Box.
+	Samples   int       `json:"samples"`
+	Stable    bool      `json:"stable"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Learner keeps the region of one meter up to date from the counter boxes
+// the model reports. It is safe for concurrent use.
+type Learner struct {
+	store   store.StateStore
+	meterID string
+	cfg     Config
+	enc     *genai.Encoder
+
+	mu    sync.Mutex
+	state State
+}
+
+// New returns a Learner for meterID saving its state in s, starting from the
+// state saved before unless cfg.Reset is set. A nil s keeps it in memory.
+func New(ctx context.Context, s store.StateStore, meterID string, cfg Config) (*Learner, error) {
+	if cfg.Padding <= 0 {
+		cfg.Padding = DefaultPadding
+	}
+	if cfg.Samples <= 0 {
+		cfg.Samples = 5
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.3
+	}
+	if cfg.MinIoU <= 0 || cfg.MinIoU > 1 {
+		cfg.MinIoU = 0.8
+	}
+	if s == nil {
+		s = store.NewMemory()
+	}
+	l := &Learner{store: s, meterID: meterID, cfg: cfg, enc: &genai.Encoder{MinSaving: cfg.MinSaving, Debug: cfg.Debug}}
+	if cfg.Box != nil {
+		if !cfg.Box.Valid() {
+			return nil, fmt.Errorf("invalid roi box %+v", *cfg.Box)
+		}
+		l.cfg.Freeze = true
+		l.state = State{Box: *cfg.Box, Samples: cfg.Samples, Stable: true}
+		return l, nil
+	}
+	if cfg.Reset {
+		if err := Reset(ctx, s, meterID); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	err := s.LoadState(ctx, meterID, StateKey, &l.state)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("load roi: %w", err)
+	}
+	return l, nil
+}
+
+// Reset forgets the region learned for meterID and saved in s, so that it
+// is learned again, e.g. after the meter was exchanged.
+func Reset(ctx context.Context, s store.StateStore, meterID string) error {
+	if err := s.SaveState(ctx, meterID, StateKey, State{}); err != nil {
+		return fmt.Errorf("reset roi: %w", err)
+	}
+	return nil
+}
+
+// Reset forgets the learned region and learns it again from the next
+// boxes, as [Reset] does; a frozen or configured region is kept.
+func (l *Learner) Reset(ctx context.Context) error {
+	if l.cfg.Freeze {
+		return nil
+	}
+	l.mu.Lock()
+	l.state = State{}
+	l.mu.Unlock()
+	return Reset(ctx, l.store, l.meterID)
+}
+
+// State returns the current state.
+func (l *Learner) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// EncodeStats counts how the crops were encoded.
+func (l *Learner) EncodeStats() genai.EncodeStats {
+	return l.enc.Stats()
+}
+
+// Crop returns the padded region to crop captures to, once it is stable.
+func (l *Learner) Crop() (genai.Box, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.state.Stable {
+		return genai.Box{}, false
+	}
+	return l.state.Box.Pad(l.cfg.Padding), true
+}
+
+// Observe averages b, a counter box in full-frame coordinates, into the
+// region and saves it. A box far off the average starts learning over from
+// it, even once the region is stable.
+func (l *Learner) Observe(ctx context.Context, b genai.Box, now time.Time) error {
+	if l.cfg.Freeze || !b.Valid() {
+		return nil
+	}
+	l.mu.Lock()
+	st := l.state
+	switch {
+	case st.Samples == 0:
+		st = State{Box: b, Samples: 1}
+	case st.Box.IoU(b) < l.cfg.MinIoU:
+		if st.Stable {
+			log.Printf("Counter box %+v off the learned region %+v, learning again", b, st.Box)
+		}
+		st = State{Box: b, Samples: 1}
+	default:
+		a := l.cfg.Smoothing
+		st.Box = genai.Box{
+			XMin: st.Box.XMin + a*(b.XMin-st.Box.XMin),
+			YMin: st.Box.YMin + a*(b.YMin-st.Box.YMin),
+			XMax: st.Box.XMax + a*(b.XMax-st.Box.XMax),
+			YMax: st.Box.YMax + a*(b.YMax-st.Box.YMax),
+		}
+		st.Samples++
+	}
+	st.Stable = st.Samples >= l.cfg.Samples
+	st.UpdatedAt = now
+	l.state = st
+	l.mu.Unlock()
+	if err := l.store.SaveState(ctx, l.meterID, StateKey, st); err != nil {
+		return fmt.Errorf("save roi: %w", err)
+	}
+	return nil
+}
+
+// Read reads the meter image jpg with c, cropped to the region once it is
+// stable. If the cropped read fails, the full frame is read instead, from
+// imageURL if set. The counter box of the result, mapped back to the full
+// frame, is observed; Result.ROI is the crop the reading was made from.
+func (l *Learner) Read(ctx context.Context, c genai.VisionClient, jpg []byte, imageURL string) (*genai.GasMeterReadResult, error) {
+	if crop, ok := l.Crop(); ok {
+		res, err := l.readCrop(ctx, c, jpg, crop)
+		if err == nil {
+			return res, nil
+		}
+		if ctx.Err() != nil || errors.Is(err, genai.ErrCircuitOpen) {
+			return nil, err
+		}
+		log.Printf("Error reading cropped image, reading the full frame: %v", err)
+	}
+
+	var res *genai.GasMeterReadResult
+	var err error
+	if imageURL != "" {
+		res, err = c.ReadGasGaugePicFromURL(ctx, imageURL)
+	} else {
+		res, err = c.ReadGasGaugePic(ctx, bytes.NewReader(jpg))
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.observe(ctx, res)
+	return res, nil
+}
+
+func (l *Learner) readCrop(ctx context.Context, c genai.VisionClient, jpg []byte, crop genai.Box) (*genai.GasMeterReadResult, error) {
+	cropped, err := l.enc.Crop(jpg, crop)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.ReadGasGaugePic(ctx, bytes.NewReader(cropped))
+	if err != nil {
+		return nil, err
+	}
+	if res.CounterBox != nil {
+		b := res.CounterBox.In(crop)
+		res.CounterBox = &b
+	}
+	res.ROI = &crop
+	l.observe(ctx, res)
+	return res, nil
+}
+
+// observe learns from the counter box of res, if any, logging failures:
+// the reading itself is fine.
+func (l *Learner) observe(ctx context.Context, res *genai.GasMeterReadResult) {
+	if res == nil || res.CounterBox == nil {
+		return
+	}
+	at := res.ReadAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if err := l.Observe(ctx, *res.CounterBox, at); err != nil {
+		log.Printf("Error learning counter region: %v", err)
+	}
+}
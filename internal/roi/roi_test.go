@@ -0,0 +1,175 @@
+package roi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var counter = genai.Box{XMin: 0.25, YMin: 0.4, XMax: 0.75, YMax: 0.6}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func near(a, b genai.Box) bool {
+	const eps = 1e-9
+	return math.Abs(a.XMin-b.XMin) < eps && math.Abs(a.YMin-b.YMin) < eps &&
+		math.Abs(a.XMax-b.XMax) < eps && math.Abs(a.YMax-b.YMax) < eps
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	l, err := New(ctx, s, "home", Config{Samples: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	shifted := genai.Box{XMin: 0.26, YMin: 0.41, XMax: 0.76, YMax: 0.61}
+	for i, b := range []genai.Box{counter, shifted, counter} {
+		if _, ok := l.Crop(); ok {
+			t.Fatalf("Crop before %d samples is stable", i)
+		}
+		if err := l.Observe(ctx, b, now); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+	crop, ok := l.Crop()
+	if !ok {
+		t.Fatalf("Crop after 3 samples is not stable: %+v", l.State())
+	}
+	if st := l.State(); st.Box.IoU(counter) < 0.95 || !near(crop, st.Box.Pad(0.15)) {
+		t.Fatalf("state %+v, crop %+v; want about %+v padded", st, crop, counter)
+	}
+
+	// The learned region survives a restart, unless reset.
+	l2, err := New(ctx, s, "home", Config{Samples: 3})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if st := l2.State(); !st.Stable || !near(st.Box, l.State().Box) {
+		t.Fatalf("reloaded state = %+v, want %+v", st, l.State())
+	}
+	if other, _ := New(ctx, s, "cabin", Config{}); other.State().Samples != 0 {
+		t.Fatalf("other meter state = %+v, want none", other.State())
+	}
+
+	// A box far off, e.g. after the camera moved, starts learning over.
+	moved := genai.Box{XMin: 0.1, YMin: 0.1, XMax: 0.4, YMax: 0.3}
+	if err := l2.Observe(ctx, moved, now); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	if st := l2.State(); st.Stable || st.Samples != 1 || st.Box != moved {
+		t.Fatalf("state after move = %+v, want learning from %+v", st, moved)
+	}
+
+	frozen, _ := New(ctx, s, "home", Config{Freeze: true})
+	if err := frozen.Observe(ctx, counter, now); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	if st := frozen.State(); st.Samples != 1 {
+		t.Fatalf("frozen state = %+v, want unchanged", st)
+	}
+	reset, _ := New(ctx, s, "home", Config{Reset: true})
+	if st := reset.State(); st.Samples != 0 {
+		t.Fatalf("state after reset = %+v", st)
+	}
+	if again, _ := New(ctx, s, "home", Config{}); again.State().Samples != 0 {
+		t.Fatalf("reset not saved: %+v", again.State())
+	}
+
+	// A fixed box is cropped to from the start and never learned from.
+	fixed, err := New(ctx, s, "home", Config{Box: &counter})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := fixed.Observe(ctx, moved, now); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	if crop, ok := fixed.Crop(); !ok || !near(crop, counter.Pad(0.15)) {
+		t.Fatalf("fixed crop = %+v, %v; want %+v padded", crop, ok, counter)
+	}
+	if _, err := New(ctx, s, "home", Config{Box: &genai.Box{XMin: 0.5, XMax: 0.4, YMax: 1}}); err == nil {
+		t.Fatal("New with an invalid box succeeded")
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	jpg := testJPEG(t, 200, 100)
+	l, err := New(ctx, nil, "home", Config{Samples: 1, Padding: 0.1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c := genaitest.NewFakeReader()
+
+	// Learning: the full frame is read by URL.
+	c.Push(&genai.GasMeterReadResult{Read: "01234.567", CounterBox: &counter})
+	if _, err := l.Read(ctx, c, jpg, "https://img/1.jpg"); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	crop, ok := l.Crop()
+	if !ok {
+		t.Fatalf("not stable after one sample")
+	}
+
+	// Stable: the crop is read and its box mapped back to the full frame.
+	inCrop := genai.Box{XMin: 0.1, YMin: 0.2, XMax: 0.9, YMax: 0.8}
+	c.Push(&genai.GasMeterReadResult{Read: "01234.568", CounterBox: &inCrop})
+	res, err := l.Read(ctx, c, jpg, "https://img/2.jpg")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	calls := c.Calls()
+	got, err := jpeg.DecodeConfig(bytes.NewReader(calls[1].Image))
+	if err != nil || calls[1].URL != "" {
+		t.Fatalf("second call %+v, decode %v; want a cropped image", calls[1].URL, err)
+	}
+	if w, h := int(math.Ceil(crop.XMax*200))-int(crop.XMin*200), int(math.Ceil(crop.YMax*100))-int(crop.YMin*100); got.Width != w || got.Height != h {
+		t.Fatalf("crop is %dx%d, want %dx%d", got.Width, got.Height, w, h)
+	}
+	if res.ROI == nil || *res.ROI != crop || !near(*res.CounterBox, inCrop.In(crop)) {
+		t.Fatalf("result ROI %+v, box %+v; want %+v, %+v", res.ROI, res.CounterBox, crop, inCrop.In(crop))
+	}
+
+	// A failed cropped read falls back to the full frame.
+	c.PushError(errors.New("unreadable"))
+	c.Push(&genai.GasMeterReadResult{Read: "01234.569"})
+	res, err = l.Read(ctx, c, jpg, "https://img/3.jpg")
+	if err != nil || res.Read != "01234.569" || res.ROI != nil {
+		t.Fatalf("Read = %+v, %v; want the full-frame reading", res, err)
+	}
+	if calls := c.Calls(); len(calls) != 4 || calls[3].URL != "https://img/3.jpg" {
+		t.Fatalf("calls = %d, last %q; want the URL read after the crop", len(calls), calls[len(calls)-1].URL)
+	}
+
+	// With the circuit open there is no point in a second call.
+	c.PushError(genai.ErrCircuitOpen)
+	if _, err := l.Read(ctx, c, jpg, ""); !errors.Is(err, genai.ErrCircuitOpen) {
+		t.Fatalf("Read error = %v, want ErrCircuitOpen", err)
+	}
+	if calls := c.Calls(); len(calls) != 5 {
+		t.Fatalf("calls = %d, want no fallback", len(calls))
+	}
+}
@@ -0,0 +1,132 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a cron-like set of minutes: "minute hour day-of-month month
+// day-of-week", each field "*", a value, a range "a-b" or a list of them,
+// optionally stepped as "*/10" or "0-30/5". Days of the week are 0 (Sunday)
+// to 6, 7 being Sunday too. As in cron, when both days are restricted a
+// minute matches if either does.
+type Window struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64 // bit i set if value i matches
+	domRestricted, dowRestricted  bool
+}
+
+// ParseWindow parses a window such as "0-10 0 * * *" (the first ten minutes
+// of every day).
+func ParseWindow(spec string) (*Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("window %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(fields))
+	}
+	w := &Window{spec: spec}
+	for i, f := range []struct {
+		name     string
+		bits     *uint64
+		min, max int
+	}{
+		{"minute", &w.minute, 0, 59},
+		{"hour", &w.hour, 0, 23},
+		{"day of month", &w.dom, 1, 31},
+		{"month", &w.month, 1, 12},
+		{"day of week", &w.dow, 0, 7},
+	} {
+		bits, err := parseField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %s: %w", spec, f.name, err)
+		}
+		*f.bits = bits
+	}
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+	w.domRestricted, w.dowRestricted = fields[2] != "*", fields[4] != "*"
+	return w, nil
+}
+
+func parseField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step %q", stepStr)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad value %q", b)
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// String returns the window as parsed.
+func (w *Window) String() string { return w.spec }
+
+// Contains reports whether the minute of t is in the window.
+func (w *Window) Contains(t time.Time) bool {
+	return w.minute&(1<<t.Minute()) != 0 && w.hour&(1<<t.Hour()) != 0 && w.month&(1<<t.Month()) != 0 && w.day(t)
+}
+
+func (w *Window) day(t time.Time) bool {
+	dom, dow := w.dom&(1<<t.Day()) != 0, w.dow&(1<<t.Weekday()) != 0
+	if w.domRestricted && w.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next returns the start of the first minute of the window after t, or the
+// zero time if there is none within five years (e.g. "0 0 31 2 *").
+func (w *Window) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		var n time.Time
+		switch y, m, d := t.Date(); {
+		case w.month&(1<<m) == 0:
+			n = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !w.day(t):
+			n = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case w.hour&(1<<t.Hour()) == 0:
+			n = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case w.minute&(1<<t.Minute()) == 0:
+			n = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// When the clocks go back, the next local hour may come earlier.
+		if !n.After(t) {
+			n = t.Add(time.Minute)
+		}
+		t = n
+	}
+	return time.Time{}
+}
@@ -0,0 +1,117 @@
+package route_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/route"
+)
+
+func TestWindow(t *testing.T) {
+	t.Parallel()
+
+	day := func(d, h, m int) time.Time { return time.Date(2025, 11, d, h, m, 0, 0, time.UTC) } // Nov 2 is a Sunday
+	tests := []struct {
+		spec     string
+		in, out  []time.Time
+		from     time.Time
+		wantNext time.Time
+	}{
+		{
+			spec: "0-10 0 * * *",
+			in:   []time.Time{day(7, 0, 0), day(7, 0, 10)},
+			out:  []time.Time{day(7, 0, 11), day(7, 1, 5)},
+			from: day(7, 0, 10), wantNext: day(8, 0, 0),
+		},
+		{
+			spec: "*/15 8-18 * * 1-5",
+			in:   []time.Time{day(7, 8, 45), day(3, 18, 0)},
+			out:  []time.Time{day(7, 8, 44), day(8, 12, 0), day(2, 12, 0)},
+			from: day(7, 18, 45), wantNext: day(10, 8, 0),
+		},
+		{
+			spec: "30 6 * * 7",
+			in:   []time.Time{day(2, 6, 30)},
+			out:  []time.Time{day(3, 6, 30)},
+			from: day(2, 6, 30), wantNext: day(9, 6, 30),
+		},
+		{
+			// Both days restricted: either matches.
+			spec: "0 0 1 * 0",
+			in:   []time.Time{day(1, 0, 0), day(2, 0, 0)},
+			out:  []time.Time{day(3, 0, 0)},
+			from: day(2, 0, 0), wantNext: day(9, 0, 0),
+		},
+		{
+			spec: "0 0 31 2 *",
+			from: day(7, 0, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			w, err := route.ParseWindow(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseWindow: %v", err)
+			}
+			for _, at := range tt.in {
+				if !w.Contains(at) {
+					t.Fatalf("%s not in the window", at)
+				}
+			}
+			for _, at := range tt.out {
+				if w.Contains(at) {
+					t.Fatalf("%s in the window", at)
+				}
+			}
+			if got := w.Next(tt.from); !got.Equal(tt.wantNext) {
+				t.Fatalf("Next(%s) = %s, want %s", tt.from, got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestWindowNextDST(t *testing.T) {
+	t.Parallel()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	w, err := route.ParseWindow("30 2 * * *")
+	if err != nil {
+		t.Fatalf("ParseWindow: %v", err)
+	}
+	// 2:30 does not exist on 2025-03-30; the next is the following day's.
+	from := time.Date(2025, 3, 29, 3, 0, 0, 0, loc)
+	if got, want := w.Next(from), time.Date(2025, 3, 31, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("Next(%s) = %s, want %s", from, got, want)
+	}
+	// When the clocks go back, 2:30 comes twice.
+	first := time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC).In(loc) // 2:30 CEST
+	if got := w.Next(first); !got.Equal(first.Add(time.Hour)) {
+		t.Fatalf("Next(%s) = %s, want the 2:30 CET an hour later", first, got)
+	}
+}
+
+func TestParseWindowErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"0 0 * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	} {
+		if _, err := route.ParseWindow(spec); err == nil {
+			t.Fatalf("ParseWindow(%q) did not fail", spec)
+		}
+	}
+}
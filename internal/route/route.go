@@ -0,0 +1,183 @@
+// Package route chooses the model of a reading cycle: rules matching the
+// time the cycle starts or the tags the daemon's schedule attached to it,
+// such as the daily reading that feeds billing, override the model of the
+// vision client, which reads every other cycle.
+package route
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Rule reads the cycles it matches with Model. A rule with both At and Tags
+// matches the cycles that start in the window and carry one of the tags.
+type Rule struct {
+	Name  string `yaml:"name"`
+	Model string `yaml:"model"`
+	// At is the window the cycle must start in; see [Window].
+	At string `yaml:"at"`
+	// Tags match the cycles tagged with any of them.
+	Tags []string `yaml:"tags"`
+}
+
+// Tag is attached by the schedule to the first cycle starting at or after
+// each minute of the window At: "0 0 * * *" tags one cycle a day, the first
+// after midnight.
+type Tag struct {
+	Name string `yaml:"name"`
+	At   string `yaml:"at"`
+	// Capture asks the first camera for a photo at each minute of the
+	// window, so that the tagged cycle is not left to the camera's own
+	// schedule; the camera must have a trigger.
+	Capture bool `yaml:"capture"`
+}
+
+// Config is the routing of a meter's cycles: the first matching rule wins.
+type Config struct {
+	// Default is the model of the cycles no rule matches; empty for the
+	// model of the vision client.
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+	Tags    []Tag  `yaml:"tags"`
+}
+
+// Enabled reports whether any model or tag is configured.
+func (c Config) Enabled() bool { return c.Default != "" || len(c.Rules) > 0 || len(c.Tags) > 0 }
+
+// Captures reports whether a tag asks for photos.
+func (c Config) Captures() bool {
+	return slices.ContainsFunc(c.Tags, func(t Tag) bool { return t.Capture })
+}
+
+// Validate checks the rules and tags.
+func (c Config) Validate() error {
+	_, err := New(c, time.UTC, time.Now())
+	return err
+}
+
+// Route is how a cycle is read.
+type Route struct {
+	// Tags are those the schedule attached to the cycle.
+	Tags []string
+	// Rule is the name of the matching rule and Model its model; Rule is
+	// empty for a cycle no rule matches, read with the default model, and
+	// Model too if there is none.
+	Rule, Model string
+}
+
+type rule struct {
+	Rule
+	at *Window // nil for any time
+}
+
+type tag struct {
+	Tag
+	at   *Window
+	next time.Time // the minute from which the tag is due, zero for never
+}
+
+// Router routes the cycles of a meter.
+type Router struct {
+	rules []rule
+	def   string
+	loc   *time.Location
+
+	mu   sync.Mutex
+	tags []tag
+}
+
+// New returns the router of c; windows are in loc (time.Local if nil). Tags
+// are due from the minute of now on.
+func New(c Config, loc *time.Location, now time.Time) (*Router, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	r := &Router{def: c.Default, loc: loc}
+	names := map[string]bool{}
+	for i, t := range c.Tags {
+		switch {
+		case t.Name == "" || t.At == "":
+			return nil, fmt.Errorf("tags %d: needs a name and a window (at)", i)
+		case names[t.Name]:
+			return nil, fmt.Errorf("tags %d: duplicate name %q", i, t.Name)
+		}
+		names[t.Name] = true
+		w, err := ParseWindow(t.At)
+		if err != nil {
+			return nil, fmt.Errorf("tags %d (%s): %w", i, t.Name, err)
+		}
+		r.tags = append(r.tags, tag{Tag: t, at: w, next: w.Next(now.In(loc).Add(-time.Minute))})
+	}
+	ruleNames := map[string]bool{}
+	for i, ru := range c.Rules {
+		switch {
+		case ru.Name == "" || ru.Model == "":
+			return nil, fmt.Errorf("rules %d: needs a name and a model", i)
+		case ruleNames[ru.Name]:
+			return nil, fmt.Errorf("rules %d: duplicate name %q", i, ru.Name)
+		case ru.At == "" && len(ru.Tags) == 0:
+			return nil, fmt.Errorf("rules %d (%s): needs a window (at) or tags", i, ru.Name)
+		}
+		ruleNames[ru.Name] = true
+		for _, name := range ru.Tags {
+			if !names[name] {
+				return nil, fmt.Errorf("rules %d (%s): unknown tag %q", i, ru.Name, name)
+			}
+		}
+		rl := rule{Rule: ru}
+		if ru.At != "" {
+			w, err := ParseWindow(ru.At)
+			if err != nil {
+				return nil, fmt.Errorf("rules %d (%s): %w", i, ru.Name, err)
+			}
+			rl.at = w
+		}
+		r.rules = append(r.rules, rl)
+	}
+	return r, nil
+}
+
+// Cycle routes the cycle starting at: it takes the tags due, which are then
+// due again from the next minute of their window, and returns the first
+// rule matching.
+func (r *Router) Cycle(at time.Time) Route {
+	at = at.In(r.loc)
+	rt := Route{Model: r.def}
+	r.mu.Lock()
+	for i := range r.tags {
+		t := &r.tags[i]
+		if !t.next.IsZero() && !at.Before(t.next) {
+			rt.Tags = append(rt.Tags, t.Name)
+			t.next = t.at.Next(at)
+		}
+	}
+	r.mu.Unlock()
+	for _, ru := range r.rules {
+		if ru.at != nil && !ru.at.Contains(at) {
+			continue
+		}
+		if len(ru.Tags) > 0 && !slices.ContainsFunc(ru.Tags, func(name string) bool { return slices.Contains(rt.Tags, name) }) {
+			continue
+		}
+		rt.Rule, rt.Model = ru.Name, ru.Model
+		break
+	}
+	return rt
+}
+
+// NextCapture returns the next minute after t at which a tag asks for a
+// photo, or the zero time if none does.
+func (r *Router) NextCapture(t time.Time) time.Time {
+	var next time.Time
+	for _, tg := range r.tags {
+		if !tg.Capture {
+			continue
+		}
+		if n := tg.at.Next(t.In(r.loc)); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
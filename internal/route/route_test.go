@@ -0,0 +1,96 @@
+package route_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/route"
+)
+
+func TestRouterCycle(t *testing.T) {
+	t.Parallel()
+
+	c := route.Config{
+		Default: "flash-lite",
+		Rules: []route.Rule{
+			{Name: "official", Model: "pro", Tags: []string{"daily"}},
+			{Name: "night", Model: "flash", At: "* 0-5 * * *"},
+		},
+		Tags: []route.Tag{{Name: "daily", At: "0 0 * * *", Capture: true}},
+	}
+	start := time.Date(2025, 11, 6, 23, 50, 0, 0, time.UTC)
+	r, err := route.New(c, time.UTC, start)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, tt := range []struct {
+		at         string
+		tags       []string
+		rule, want string
+	}{
+		{"2025-11-06T23:55:00Z", nil, "", "flash-lite"},
+		// The first cycle after midnight takes the tag, even late.
+		{"2025-11-07T00:07:00Z", []string{"daily"}, "official", "pro"},
+		{"2025-11-07T00:17:00Z", nil, "night", "flash"},
+		{"2025-11-07T12:00:00Z", nil, "", "flash-lite"},
+		{"2025-11-08T00:00:00Z", []string{"daily"}, "official", "pro"},
+		{"2025-11-08T00:00:30Z", nil, "night", "flash"},
+	} {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		got := r.Cycle(at)
+		if !slices.Equal(got.Tags, tt.tags) || got.Rule != tt.rule || got.Model != tt.want {
+			t.Fatalf("Cycle(%s) = %+v, want tags %v, rule %q and model %q", tt.at, got, tt.tags, tt.rule, tt.want)
+		}
+	}
+	if got, want := r.NextCapture(start), time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("NextCapture = %s, want %s", got, want)
+	}
+}
+
+func TestRouterLocation(t *testing.T) {
+	t.Parallel()
+
+	kst := time.FixedZone("KST", 9*60*60)
+	c := route.Config{Tags: []route.Tag{{Name: "daily", At: "0 0 * * *"}}}
+	// Started in the tag's minute, the router lets the first cycle take it.
+	r, err := route.New(c, kst, time.Date(2025, 11, 6, 15, 0, 20, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := r.Cycle(time.Date(2025, 11, 6, 15, 0, 40, 0, time.UTC)); !slices.Equal(got.Tags, []string{"daily"}) || got.Model != "" {
+		t.Fatalf("Cycle at midnight KST = %+v, want the daily tag and no model", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tag := []route.Tag{{Name: "daily", At: "0 0 * * *"}}
+	tests := []struct {
+		name string
+		c    route.Config
+		want string
+	}{
+		{"rule without model", route.Config{Rules: []route.Rule{{Name: "a", At: "* * * * *"}}}, "needs a name and a model"},
+		{"rule without match", route.Config{Rules: []route.Rule{{Name: "a", Model: "m"}}}, "needs a window (at) or tags"},
+		{"duplicate rule", route.Config{Rules: []route.Rule{{Name: "a", Model: "m", At: "* * * * *"}, {Name: "a", Model: "n", At: "* * * * *"}}}, "duplicate name"},
+		{"bad rule window", route.Config{Rules: []route.Rule{{Name: "a", Model: "m", At: "* * *"}}}, "want 5 fields"},
+		{"unknown tag", route.Config{Rules: []route.Rule{{Name: "a", Model: "m", Tags: []string{"weekly"}}}, Tags: tag}, `unknown tag "weekly"`},
+		{"tag without window", route.Config{Tags: []route.Tag{{Name: "daily"}}}, "needs a name and a window"},
+		{"duplicate tag", route.Config{Tags: append(tag, tag...)}, "duplicate name"},
+		{"bad tag window", route.Config{Tags: []route.Tag{{Name: "daily", At: "0 25 * * *"}}}, "hour"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+	if err := (route.Config{Rules: []route.Rule{{Name: "a", Model: "m", Tags: []string{"daily"}}}, Tags: tag}).Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+// Package series turns the reading history into time series for dashboards:
+// the meter value and the consumption, raw or aggregated per hour or day.
+package series
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Aggregations of a [Query].
+const (
+	AggRaw    = "raw"    // every reading
+	AggHourly = "hourly" // per hour
+	AggDaily  = "daily"  // per day
+)
+
+// window is how much history [Walk] loads at a time, so that multi-year
+// ranges are not held in memory at once.
+const window = 30 * 24 * time.Hour
+
+// Query selects a series.
+type Query struct {
+	From, To time.Time
+	Agg      string // one of AggRaw (default), AggHourly and AggDaily
+	// MaxPoints bounds the number of buckets; a longer series is downsampled
+	// to buckets of several hours or days, or of an even share of the range
+	// for raw readings. 0 is no bound.
+	MaxPoints int
+	// Location aligns hours and days (default time.Local).
+	Location *time.Location
+	// Gaps attributes the consumption across a gap in the readings to the
+	// buckets of the gap, billing.GapSpread (default), or to the bucket of
+	// the reading after it, billing.GapAtEnd. Raw readings that are not
+	// downsampled have no buckets in between, so keep it at the end.
+	Gaps string
+}
+
+// Validate checks the range and aggregation.
+func (q Query) Validate() error {
+	switch q.Agg {
+	case "", AggRaw, AggHourly, AggDaily:
+	default:
+		return fmt.Errorf("unknown agg %q, want raw, hourly or daily", q.Agg)
+	}
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("from %s is not before to %s", q.From.Format(time.RFC3339), q.To.Format(time.RFC3339))
+	}
+	if q.MaxPoints < 0 {
+		return fmt.Errorf("negative max points %d", q.MaxPoints)
+	}
+	switch q.Gaps {
+	case "", billing.GapSpread, billing.GapAtEnd:
+	default:
+		return fmt.Errorf("unknown gaps %q, want %s or %s", q.Gaps, billing.GapSpread, billing.GapAtEnd)
+	}
+	return nil
+}
+
+// GapAttribution returns Gaps, or its default.
+func (q Query) GapAttribution() string {
+	if q.Gaps == "" {
+		return billing.GapSpread
+	}
+	return q.Gaps
+}
+
+// Bucket is a point of the series: the meter value at the last reading in
+// [At, At+Interval) and the consumption between the readings in it and the
+// readings before. For raw readings At is the time of the reading.
+type Bucket struct {
+	At    time.Time
+	Value float64
+	// Consumption is the sum of the increases up to readings in the bucket;
+	// it is not set for the first usable reading of the range.
+	Consumption    float64
+	HasConsumption bool
+	// LastAt is the time of the last reading in the bucket; it is zero for a
+	// bucket a gap is spread over.
+	LastAt time.Time
+}
+
+// Point is a [timestamp, value] pair, with the time in Unix milliseconds, as
+// Grafana's JSON datasources take it.
+type Point struct {
+	At    time.Time
+	Value float64
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p Point) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	b = strconv.AppendInt(b, p.At.UnixMilli(), 10)
+	b = append(b, ',')
+	b = strconv.AppendFloat(b, p.Value, 'f', -1, 64)
+	return append(b, ']'), nil
+}
+
+// Interval returns the width of the buckets of q, or 0 for raw readings that
+// are not downsampled. Daily buckets are nominally 24h wide.
+func (q Query) Interval() time.Duration {
+	switch q.Agg {
+	case AggHourly:
+		return time.Duration(q.steps(time.Hour)) * time.Hour
+	case AggDaily:
+		return time.Duration(q.steps(24*time.Hour)) * 24 * time.Hour
+	}
+	if q.MaxPoints == 0 {
+		return 0
+	}
+	return max(ceilDiv(q.To.Sub(q.From), time.Duration(q.MaxPoints)), time.Millisecond)
+}
+
+// steps returns how many steps of the aggregation a bucket spans to keep
+// the range within MaxPoints buckets.
+func (q Query) steps(step time.Duration) int64 {
+	if q.MaxPoints == 0 {
+		return 1
+	}
+	n := ceilDiv(q.To.Sub(q.From), step)
+	return max(int64(ceilDiv(n, time.Duration(q.MaxPoints))), 1)
+}
+
+func ceilDiv(a, b time.Duration) time.Duration {
+	return (a + b - 1) / b
+}
+
+// Walk calls fn with the buckets of q in the history of meterID in s, oldest
+// first, loading the history a window at a time. Consumption follows the
+// rules of [billing.Consumption]: increases allow for rollover, and stale and
+// unparseable readings and decreases (misreads) are skipped, also for the
+// value. Buckets without a usable reading are left out, but for those a gap
+// is spread over: their value is interpolated.
+func Walk(ctx context.Context, s store.Store, m genai.Meter, meterID string, q Query, fn func(Bucket) error) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	bs := q.bucketer()
+	spread := q.GapAttribution() == billing.GapSpread && bs.spans()
+	var (
+		cur      Bucket
+		have     bool
+		prev     float64
+		prevAt   time.Time
+		havePrev bool
+	)
+	for from := q.From; from.Before(q.To); from = from.Add(window) {
+		to := from.Add(window)
+		if to.After(q.To) {
+			to = q.To
+		}
+		rs, err := s.ReadingsBetween(ctx, meterID, from, to)
+		if err != nil {
+			return fmt.Errorf("load history: %w", err)
+		}
+		for _, r := range rs {
+			if !r.Counted() {
+				continue
+			}
+			v, err := genai.ParseRead(m, r.Read)
+			if err != nil {
+				continue
+			}
+			var d float64
+			if havePrev {
+				var ok bool
+				if d, ok = m.DeltaTo(prev, r, v); !ok {
+					continue // keep prev: the lower value is the misread
+				}
+			}
+			at := bs.start(r.ReadAt)
+			if spread && havePrev && r.Gap() > 0 && at.After(cur.At) {
+				// Spread d over the buckets up to this one by their share of
+				// the gap; this one takes the rest.
+				gap := float64(r.ReadAt.Sub(prevAt))
+				given := 0.0
+				for b := cur.At; b.Before(at); b = bs.next(b) {
+					end := bs.next(b)
+					part := d * float64(end.Sub(later(b, prevAt))) / gap
+					given += part
+					if !b.Equal(cur.At) {
+						// No reading in this bucket: its value is the
+						// interpolated one at its end.
+						cur = Bucket{At: b, Value: m.Wrap(prev + given)}
+					}
+					cur.Consumption += part
+					cur.HasConsumption = true
+					if err := fn(cur); err != nil {
+						return err
+					}
+				}
+				cur, have = Bucket{At: at}, true
+				d -= given
+			}
+			if have && !at.Equal(cur.At) {
+				if err := fn(cur); err != nil {
+					return err
+				}
+				have = false
+			}
+			if !have {
+				cur, have = Bucket{At: at}, true
+			}
+			cur.Value, cur.LastAt = v, r.ReadAt
+			if havePrev {
+				cur.Consumption += d
+				cur.HasConsumption = true
+			}
+			prev, prevAt, havePrev = v, r.ReadAt, true
+		}
+	}
+	if have {
+		return fn(cur)
+	}
+	return nil
+}
+
+// bucketer maps reading times to the start of their bucket.
+type bucketer struct {
+	width time.Duration // hourly and raw buckets
+	days  int           // daily buckets
+	first time.Time     // start of the first bucket
+	// last is the start of the latest bucket, from which daily buckets are
+	// found by stepping, as days vary in length.
+	last time.Time
+}
+
+func (q Query) bucketer() *bucketer {
+	b := &bucketer{}
+	loc := q.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	from := q.From.In(loc)
+	switch q.Agg {
+	case AggHourly:
+		b.width = q.Interval()
+		b.first = time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), 0, 0, 0, loc)
+	case AggDaily:
+		b.days = int(q.steps(24 * time.Hour))
+		b.first = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	default:
+		b.width = q.Interval()
+		b.first = q.From
+	}
+	b.last = b.first
+	return b
+}
+
+// spans reports whether buckets span a time range, rather than being the
+// readings themselves.
+func (b *bucketer) spans() bool { return b.days > 0 || b.width > 0 }
+
+// next returns the start of the bucket after the one starting at start.
+func (b *bucketer) next(start time.Time) time.Time {
+	if b.days > 0 {
+		return start.AddDate(0, 0, b.days)
+	}
+	return start.Add(b.width)
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// start returns the start of the bucket of t; t is never before the times
+// it was called with before.
+func (b *bucketer) start(t time.Time) time.Time {
+	switch {
+	case b.days > 0:
+		for next := b.last.AddDate(0, 0, b.days); !t.Before(next); next = b.last.AddDate(0, 0, b.days) {
+			b.last = next
+		}
+		return b.last
+	case b.width > 0:
+		n := t.Sub(b.first) / b.width
+		return b.first.Add(n * b.width)
+	}
+	return t
+}
+
+// ValuePoint returns the meter value of b.
+func (b Bucket) ValuePoint() Point { return Point{At: b.At, Value: b.Value} }
+
+// ConsumptionPoint returns the consumption of b.
+func (b Bucket) ConsumptionPoint() Point { return Point{At: b.At, Value: round(b.Consumption)} }
+
+// round keeps JSON values free of float noise such as 0.30000000000000004,
+// at well below the resolution of any meter.
+func round(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,178 @@
+package series_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/series"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+var meter = genai.Meter{Utility: genai.UtilityGas, IntDigits: 3, FracDigits: 1}
+
+func history(t *testing.T, start time.Time, reads ...string) store.Store {
+	t.Helper()
+	s := store.NewMemory()
+	for i, read := range reads {
+		r := &genai.GasMeterReadResult{Read: read, ReadAt: start.Add(time.Duration(i) * 30 * time.Minute)}
+		if read == "stale" {
+			r.Read, r.Stale = "500.0", true
+		}
+		if err := s.Save(context.Background(), "home", r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	return s
+}
+
+func walk(t *testing.T, s store.Store, q series.Query) (values, consumption []series.Point) {
+	t.Helper()
+	err := series.Walk(context.Background(), s, meter, "home", q, func(b series.Bucket) error {
+		values = append(values, b.ValuePoint())
+		if b.HasConsumption {
+			consumption = append(consumption, b.ConsumptionPoint())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	return values, consumption
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 11, 7, 22, 0, 0, 0, time.UTC)
+	// Every 30 minutes, rolling over after a misread and a stale reading.
+	s := history(t, start, "998.0", "998.5", "123.4", "999.0", "stale", "999.6", "000.4", "001.0")
+	q := series.Query{From: start, To: start.Add(4 * time.Hour), Location: time.UTC}
+	h := func(n int) time.Time { return start.Add(time.Duration(n) * time.Hour) }
+
+	tests := []struct {
+		name                string
+		agg                 string
+		maxPoints           int
+		values, consumption []series.Point
+	}{
+		{"raw", series.AggRaw, 0,
+			[]series.Point{{start, 998}, {h(0).Add(30 * time.Minute), 998.5}, {h(1).Add(30 * time.Minute), 999}, {h(2).Add(30 * time.Minute), 999.6}, {h(3), 0.4}, {h(3).Add(30 * time.Minute), 1}},
+			[]series.Point{{h(0).Add(30 * time.Minute), 0.5}, {h(1).Add(30 * time.Minute), 0.5}, {h(2).Add(30 * time.Minute), 0.6}, {h(3), 0.8}, {h(3).Add(30 * time.Minute), 0.6}}},
+		{"hourly", series.AggHourly, 0,
+			[]series.Point{{h(0), 998.5}, {h(1), 999}, {h(2), 999.6}, {h(3), 1}},
+			[]series.Point{{h(0), 0.5}, {h(1), 0.5}, {h(2), 0.6}, {h(3), 1.4}}},
+		{"hourly downsampled", series.AggHourly, 2,
+			[]series.Point{{h(0), 999}, {h(2), 1}},
+			[]series.Point{{h(0), 1}, {h(2), 2}}},
+		{"raw downsampled", series.AggRaw, 1,
+			[]series.Point{{start, 1}},
+			[]series.Point{{start, 3}}},
+		{"daily", series.AggDaily, 0,
+			[]series.Point{{time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC), 999}, {time.Date(2025, 11, 8, 0, 0, 0, 0, time.UTC), 1}},
+			[]series.Point{{time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC), 1}, {time.Date(2025, 11, 8, 0, 0, 0, 0, time.UTC), 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			q := q
+			q.Agg, q.MaxPoints = tt.agg, tt.maxPoints
+			values, consumption := walk(t, s, q)
+			if got, want := jsonString(t, values), jsonString(t, tt.values); got != want {
+				t.Fatalf("values = %s, want %s", got, want)
+			}
+			if got, want := jsonString(t, consumption), jsonString(t, tt.consumption); got != want {
+				t.Fatalf("consumption = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestWalkLongRange(t *testing.T) {
+	t.Parallel()
+
+	// Readings far apart land in different load windows; the consumption
+	// between them still counts.
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for i, read := range []string{"100.0", "110.0", "125.0"} {
+		r := &genai.GasMeterReadResult{Read: read, ReadAt: start.AddDate(0, 0, 200*i)}
+		if err := s.Save(context.Background(), "home", r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	values, consumption := walk(t, s, series.Query{From: start, To: start.AddDate(2, 0, 0), Agg: series.AggDaily, Location: time.UTC})
+	if len(values) != 3 || len(consumption) != 2 || consumption[0].Value != 10 || consumption[1].Value != 15 {
+		t.Fatalf("values %v, consumption %v", values, consumption)
+	}
+
+	if err := series.Walk(context.Background(), s, meter, "home", series.Query{From: start, To: start, Agg: "weekly"}, nil); err == nil {
+		t.Fatalf("Walk of a bad query succeeded")
+	}
+}
+
+func TestWalkGaps(t *testing.T) {
+	t.Parallel()
+
+	// Four hours without readings between 00:30 and 04:30.
+	start := time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for _, r := range []*genai.GasMeterReadResult{
+		{Read: "100.0", ReadAt: start},
+		{Read: "100.5", ReadAt: start.Add(30 * time.Minute)},
+		{Read: "104.5", ReadAt: start.Add(270 * time.Minute), GapBefore: "4h0m0s"},
+	} {
+		if err := s.Save(context.Background(), "home", r); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	q := series.Query{From: start, To: start.Add(6 * time.Hour), Agg: series.AggHourly, Location: time.UTC}
+	tests := []struct {
+		gaps        string
+		values      []series.Point
+		consumption []series.Point
+	}{
+		{"", // spread
+			[]series.Point{{At: start, Value: 100.5}, {At: start.Add(time.Hour), Value: 102}, {At: start.Add(2 * time.Hour), Value: 103},
+				{At: start.Add(3 * time.Hour), Value: 104}, {At: start.Add(4 * time.Hour), Value: 104.5}},
+			[]series.Point{{At: start, Value: 1}, {At: start.Add(time.Hour), Value: 1}, {At: start.Add(2 * time.Hour), Value: 1},
+				{At: start.Add(3 * time.Hour), Value: 1}, {At: start.Add(4 * time.Hour), Value: 0.5}}},
+		{"end",
+			[]series.Point{{At: start, Value: 100.5}, {At: start.Add(4 * time.Hour), Value: 104.5}},
+			[]series.Point{{At: start, Value: 0.5}, {At: start.Add(4 * time.Hour), Value: 4}}},
+	}
+	for _, tt := range tests {
+		q := q
+		q.Gaps = tt.gaps
+		values, consumption := walk(t, s, q)
+		if got, want := jsonString(t, values), jsonString(t, tt.values); got != want {
+			t.Fatalf("gaps %q: values = %s, want %s", tt.gaps, got, want)
+		}
+		if got, want := jsonString(t, consumption), jsonString(t, tt.consumption); got != want {
+			t.Fatalf("gaps %q: consumption = %s, want %s", tt.gaps, got, want)
+		}
+	}
+	if err := (series.Query{From: start, To: q.To, Gaps: "later"}).Validate(); err == nil {
+		t.Fatalf("Validate of unknown gaps succeeded")
+	}
+}
+
+func TestPointJSON(t *testing.T) {
+	t.Parallel()
+
+	got := jsonString(t, series.Point{At: time.UnixMilli(1762492380123), Value: 2924.457})
+	if want := "[1762492380123,2924.457]"; got != want {
+		t.Fatalf("JSON = %s, want %s", got, want)
+	}
+}
+
+func jsonString(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return string(b)
+}
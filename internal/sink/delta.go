@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// What an [MQTT] sink with a delta publishes for the first reading of a
+// meter after it starts, which has no previous value.
+const (
+	FirstReadingSkip = "skip" // nothing (default)
+	FirstReadingZero = "zero" // a delta of 0 with first_reading set
+)
+
+// MQTTDeltaConfig publishes to {topic}/delta/json, for every reading, the
+// consumption since the previous accepted reading of its meter and the
+// seconds between their messages, for consumers such as heating controllers
+// that want "used since the last message" rather than the counter. The
+// counter rolling over counts as usage, see [genai.Meter.Delta]; repeats,
+// stale readings and readings during maintenance publish 0. QoS and Retain
+// are those of these messages only.
+type MQTTDeltaConfig struct {
+	QoS    byte `yaml:"qos"`
+	Retain bool `yaml:"retain"`
+	// FirstReading is FirstReadingSkip or FirstReadingZero.
+	FirstReading string `yaml:"first_reading"`
+}
+
+// Validate checks c.
+func (c MQTTDeltaConfig) Validate() error {
+	if c.QoS > 2 {
+		return fmt.Errorf("qos %d, want 0, 1 or 2", c.QoS)
+	}
+	switch c.FirstReading {
+	case "", FirstReadingSkip, FirstReadingZero:
+	default:
+		return fmt.Errorf("unknown first_reading %q, want %s or %s", c.FirstReading, FirstReadingSkip, FirstReadingZero)
+	}
+	return nil
+}
+
+// Delta is the message of an [MQTTDeltaConfig].
+type Delta struct {
+	MeterID string `json:"meter_id"`
+	// Delta is the consumption since the previous message, in Unit.
+	Delta float64 `json:"delta"`
+	Unit  string  `json:"unit,omitempty"`
+	// ElapsedSeconds is the time since the previous message.
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	Read           string    `json:"read"`
+	At             time.Time `json:"at"`
+	FirstReading   bool      `json:"first_reading,omitempty"`
+	Stale          bool      `json:"stale,omitempty"`
+}
+
+// deltas keeps the previous reading of each meter in memory; after a
+// restart the first reading is the first again.
+type deltas struct {
+	cfg MQTTDeltaConfig
+	m   genai.Meter
+
+	mu   sync.Mutex
+	prev map[string]deltaPrev
+}
+
+type deltaPrev struct {
+	value float64
+	at    time.Time // of the previous message
+}
+
+func newDeltas(cfg MQTTDeltaConfig, m genai.Meter) *deltas {
+	return &deltas{cfg: cfg, m: m, prev: make(map[string]deltaPrev)}
+}
+
+// next returns the delta of r, a reading of meterID published at now, and
+// whether to publish it. A stale reading repeats the last one, so its
+// message is at now rather than at its ReadAt. A decrease that is not a
+// roll-over, a misread that got through, is 0 and keeps the previous value
+// so that the usage is not counted twice once the meter reads right again.
+func (d *deltas) next(meterID string, r *genai.GasMeterReadResult, now time.Time) (Delta, bool) {
+	out := Delta{MeterID: meterID, Unit: d.m.Unit, Read: r.Read, At: r.ReadAt, Stale: r.Stale}
+	if r.Stale {
+		out.At = now
+	}
+	cur, err := genai.ParseRead(d.m, r.Read)
+	if err != nil {
+		return Delta{}, false // accepted readings parse
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, ok := d.prev[meterID]
+	if !ok {
+		if r.Stale {
+			return Delta{}, false
+		}
+		d.prev[meterID] = deltaPrev{value: cur, at: out.At}
+		out.FirstReading = true
+		return out, d.cfg.FirstReading == FirstReadingZero
+	}
+	next := prev
+	if out.At.After(prev.at) {
+		out.ElapsedSeconds = out.At.Sub(prev.at).Seconds()
+		next.at = out.At
+	}
+	switch {
+	case r.Stale:
+	case r.Maintenance:
+		next.value = cur
+	default:
+		if v, ok := d.m.DeltaTo(prev.value, r, cur); ok {
+			out.Delta = d.round(v)
+			next.value = cur
+		} else if r.MeterExchanged != nil {
+			next.value = cur
+		}
+	}
+	d.prev[meterID] = next
+	return out, true
+}
+
+// round rounds v to the decimals of the meter, dropping the noise of the
+// float subtraction.
+func (d *deltas) round(v float64) float64 {
+	p := math.Pow10(d.m.FracDigits)
+	return math.Round(v*p) / p
+}
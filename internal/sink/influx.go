@@ -0,0 +1,129 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InfluxConfig is where an [Influx] sink writes to.
+type InfluxConfig struct {
+	// URL is the server, e.g. "http://localhost:8086".
+	URL    string `yaml:"url"`
+	Org    string `yaml:"org"`
+	Bucket string `yaml:"bucket"`
+	Token  string `yaml:"token"`
+	// Timeout bounds each write (default 10s).
+	Timeout time.Duration `yaml:"timeout"`
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper `yaml:"-"`
+}
+
+// Validate checks that the server and bucket are set.
+func (c InfluxConfig) Validate() error {
+	if c.URL == "" || c.Bucket == "" {
+		return errors.New("needs url and bucket")
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	return nil
+}
+
+// Influx writes every reading, and the consumption between readings, to an
+// InfluxDB 2 bucket through the HTTP write API, with the points of
+// [Entry.Point] and [Consumption.Point]. Write errors are returned, so that
+// [Buffered] keeps and replays the reading; a replay is one write.
+type Influx struct {
+	cfg    InfluxConfig
+	client *http.Client
+}
+
+// NewInflux returns a sink writing to cfg.Bucket.
+func NewInflux(cfg InfluxConfig) *Influx {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Influx{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport}}
+}
+
+// Publish implements [Sink].
+func (s *Influx) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	return s.PublishBatch(ctx, []Entry{{MeterID: meterID, Reading: r}})
+}
+
+// PublishBatch implements [Batcher].
+func (s *Influx) PublishBatch(ctx context.Context, entries []Entry) error {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		p, err := e.Point()
+		if err != nil {
+			return err
+		}
+		lines[i] = p.LineProtocol()
+	}
+	return s.write(ctx, lines)
+}
+
+// PublishConsumption implements [ConsumptionSink].
+func (s *Influx) PublishConsumption(ctx context.Context, c Consumption) error {
+	return s.write(ctx, []string{c.LineProtocol()})
+}
+
+// PublishCorrection implements [CorrectionSink] with the point of
+// [CorrectionEvent.Point], written next to the original one.
+func (s *Influx) PublishCorrection(ctx context.Context, c CorrectionEvent) error {
+	p, err := c.Point()
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, []string{p.LineProtocol()})
+}
+
+func (s *Influx) write(ctx context.Context, lines []string) (err error) {
+	ctx, span := otel.Tracer(genai.TracerName).Start(ctx, genai.SpanPublish, trace.WithAttributes(
+		attribute.String("db.system", "influxdb"),
+		attribute.String("db.namespace", s.cfg.Bucket),
+		attribute.Int("db.operation.batch.size", len(lines)),
+	), trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { genai.EndSpan(span, err) }()
+
+	q := url.Values{"bucket": {s.cfg.Bucket}, "precision": {"ns"}}
+	if s.cfg.Org != "" {
+		q.Set("org", s.cfg.Org)
+	}
+	u := strings.TrimRight(s.cfg.URL, "/") + "/api/v2/write?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("write to %s: %w", s.cfg.Bucket, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("write to %s: %s: %s", s.cfg.Bucket, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close implements [Sink].
+func (s *Influx) Close() error { return nil }
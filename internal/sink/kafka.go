@@ -0,0 +1,325 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Kafka acknowledgement levels of [KafkaConfig.Acks].
+const (
+	KafkaAcksAll    = "all" // every in-sync replica (default)
+	KafkaAcksLeader = "leader"
+	KafkaAcksNone   = "none"
+)
+
+var kafkaCompressions = []string{"", "none", "gzip", "snappy", "lz4", "zstd"}
+
+// KafkaConfig is what a [KafkaProducer] connects and produces with.
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	// Acks is KafkaAcksAll (default), KafkaAcksLeader or KafkaAcksNone.
+	Acks string `yaml:"acks"`
+	// Compression is none (default), gzip, snappy, lz4 or zstd.
+	Compression string `yaml:"compression"`
+	// BatchSize is the most readings produced in one request (default 100).
+	BatchSize int        `yaml:"batch_size"`
+	SASL      *KafkaSASL `yaml:"sasl"`
+	TLS       *KafkaTLS  `yaml:"tls"`
+	// Dial connects to the brokers, e.g. through a proxy; nil is a
+	// net.Dialer. TLS is layered on its connections.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error) `yaml:"-"`
+}
+
+// KafkaSASL authenticates with the brokers.
+type KafkaSASL struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// KafkaTLS encrypts the broker connections. Without CAFile the system roots
+// are used; CertFile and KeyFile enable client certificates.
+type KafkaTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Validate checks the settings that do not need a broker.
+func (c KafkaConfig) Validate() error {
+	if len(c.Brokers) == 0 || c.Topic == "" {
+		return errors.New("needs brokers and topic")
+	}
+	switch c.Acks {
+	case "", KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone:
+	default:
+		return fmt.Errorf("unknown acks %q", c.Acks)
+	}
+	if !slices.Contains(kafkaCompressions, c.Compression) {
+		return fmt.Errorf("unknown compression %q", c.Compression)
+	}
+	if c.BatchSize < 0 {
+		return errors.New("negative batch_size")
+	}
+	if c.SASL != nil {
+		switch c.SASL.Mechanism {
+		case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		default:
+			return fmt.Errorf("unknown sasl mechanism %q", c.SASL.Mechanism)
+		}
+		if c.SASL.Username == "" {
+			return errors.New("sasl needs a username")
+		}
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls needs both cert_file and key_file")
+	}
+	return nil
+}
+
+// Config returns the tls.Config of t, loading its files.
+func (t *KafkaTLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// KafkaMessage is one record to produce.
+type KafkaMessage struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	Time  time.Time
+	// Headers carry the trace context of the reading (traceparent), so that
+	// consumers can continue its trace.
+	Headers map[string]string
+}
+
+// KafkaProducer is the Kafka client a [Kafka] sink produces with, set up
+// from a [KafkaConfig].
+type KafkaProducer interface {
+	// Produce writes msgs in order and returns once the brokers have
+	// acknowledged all of them as configured by Acks, or the delivery error.
+	Produce(ctx context.Context, msgs []KafkaMessage) error
+	// Close flushes anything the client still buffers and disconnects.
+	Close() error
+}
+
+// kafkaFlushTimeout bounds flushing the producer on Close.
+const kafkaFlushTimeout = 10 * time.Second
+
+// kgoProducer is the [KafkaProducer] of a franz-go client.
+type kgoProducer struct {
+	cl *kgo.Client
+}
+
+// NewKafkaProducer returns a producer connecting to the brokers of cfg with
+// its acks, compression, SASL and TLS. The records of a Produce call are
+// batched by partition; without acks all, at most one request per broker
+// is in flight, so that retries keep the readings of a meter in order.
+func NewKafkaProducer(cfg KafkaConfig) (KafkaProducer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.ProducerBatchCompression(kafkaCodec(cfg.Compression)),
+	}
+	switch cfg.Acks {
+	case KafkaAcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case KafkaAcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	}
+	if s := cfg.SASL; s != nil {
+		switch s.Mechanism {
+		case "PLAIN":
+			opts = append(opts, kgo.SASL(plain.Auth{User: s.Username, Pass: s.Password}.AsMechanism()))
+		case "SCRAM-SHA-256":
+			opts = append(opts, kgo.SASL(scram.Auth{User: s.Username, Pass: s.Password}.AsSha256Mechanism()))
+		case "SCRAM-SHA-512":
+			opts = append(opts, kgo.SASL(scram.Auth{User: s.Username, Pass: s.Password}.AsSha512Mechanism()))
+		}
+	}
+	dial := cfg.Dial
+	if cfg.TLS != nil {
+		tlsCfg, err := cfg.TLS.Config()
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		if dial == nil {
+			opts = append(opts, kgo.DialTLSConfig(tlsCfg))
+		} else {
+			dial = dialTLS(dial, tlsCfg)
+		}
+	}
+	if dial != nil {
+		opts = append(opts, kgo.Dialer(dial))
+	}
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &kgoProducer{cl: cl}, nil
+}
+
+// Produce implements [KafkaProducer]. The delivery report of every record
+// is awaited; the first failure is returned.
+func (p *kgoProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	records := make([]*kgo.Record, len(msgs))
+	for i, m := range msgs {
+		r := &kgo.Record{Topic: m.Topic, Key: m.Key, Value: m.Value, Timestamp: m.Time}
+		for _, k := range slices.Sorted(maps.Keys(m.Headers)) {
+			r.Headers = append(r.Headers, kgo.RecordHeader{Key: k, Value: []byte(m.Headers[k])})
+		}
+		records[i] = r
+	}
+	return p.cl.ProduceSync(ctx, records...).FirstErr()
+}
+
+// Close implements [KafkaProducer].
+func (p *kgoProducer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaFlushTimeout)
+	defer cancel()
+	err := p.cl.Flush(ctx)
+	p.cl.Close()
+	return err
+}
+
+// kafkaCodec returns the codec of a [KafkaConfig.Compression].
+func kafkaCodec(compression string) kgo.CompressionCodec {
+	switch compression {
+	case "gzip":
+		return kgo.GzipCompression()
+	case "snappy":
+		return kgo.SnappyCompression()
+	case "lz4":
+		return kgo.Lz4Compression()
+	case "zstd":
+		return kgo.ZstdCompression()
+	default:
+		return kgo.NoCompression()
+	}
+}
+
+// dialTLS returns dial with a TLS handshake of cfg on its connections.
+func dialTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), cfg *tls.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c := cfg.Clone()
+		if c.ServerName == "" {
+			c.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, c)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
+
+// Kafka produces every reading to a topic, keyed by meter ID so that the
+// readings of a meter stay in order within their partition. The value is the
+// reading's JSON. Delivery errors are returned, so that [Buffered] keeps and
+// replays the reading; a replay is produced in batches.
+type Kafka struct {
+	p         KafkaProducer
+	topic     string
+	batchSize int
+}
+
+// NewKafka returns a sink producing to cfg.Topic with p.
+func NewKafka(p KafkaProducer, cfg KafkaConfig) *Kafka {
+	size := cfg.BatchSize
+	if size <= 0 {
+		size = 100
+	}
+	return &Kafka{p: p, topic: cfg.Topic, batchSize: size}
+}
+
+// Publish implements [Sink].
+func (k *Kafka) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	return k.PublishBatch(ctx, []Entry{{MeterID: meterID, Reading: r}})
+}
+
+// PublishBatch implements [Batcher]. It produces up to the batch size per
+// request; a failed request fails the whole call, and the entries of earlier
+// requests are produced again when the call is retried.
+func (k *Kafka) PublishBatch(ctx context.Context, entries []Entry) (err error) {
+	ctx, span := otel.Tracer(genai.TracerName).Start(ctx, genai.SpanPublish, trace.WithAttributes(
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", k.topic),
+		attribute.Int("messaging.batch.message_count", len(entries)),
+	), trace.WithSpanKind(trace.SpanKindProducer))
+	defer func() { genai.EndSpan(span, err) }()
+
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+	msgs := make([]KafkaMessage, len(entries))
+	for i, e := range entries {
+		value, err := json.Marshal(e.Reading)
+		if err != nil {
+			return fmt.Errorf("marshal reading: %w", err)
+		}
+		msgs[i] = KafkaMessage{Topic: k.topic, Key: []byte(e.MeterID), Value: value, Time: e.Reading.ReadAt}
+		if len(headers) > 0 {
+			msgs[i].Headers = maps.Clone(headers)
+		}
+	}
+	for len(msgs) > 0 {
+		n := min(len(msgs), k.batchSize)
+		if err := k.p.Produce(ctx, msgs[:n]); err != nil {
+			return fmt.Errorf("produce to %s: %w", k.topic, err)
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+// Close implements [Sink]. It flushes the producer, so that nothing handed
+// to Kafka before a clean shutdown is lost.
+func (k *Kafka) Close() error {
+	return k.p.Close()
+}
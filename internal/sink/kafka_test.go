@@ -0,0 +1,235 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/faults"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// fakeProducer records produced batches; inj fails Produce calls.
+type fakeProducer struct {
+	inj     faults.Injector
+	batches [][]sink.KafkaMessage
+	closed  bool
+}
+
+func (p *fakeProducer) Produce(ctx context.Context, msgs []sink.KafkaMessage) error {
+	if err := p.inj.Inject(ctx); err != nil {
+		return err
+	}
+	p.batches = append(p.batches, append([]sink.KafkaMessage(nil), msgs...))
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafka(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p := &fakeProducer{}
+	k := sink.NewKafka(p, sink.KafkaConfig{Brokers: []string{"kafka:9092"}, Topic: "meters", BatchSize: 2})
+	b := sink.NewBuffered(k, 10)
+
+	if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00001.000"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.batches) != 1 || len(p.batches[0]) != 1 {
+		t.Fatalf("batches = %v", p.batches)
+	}
+	m := p.batches[0][0]
+	var got genai.GasMeterReadResult
+	if err := json.Unmarshal(m.Value, &got); err != nil || got.Read != "00001.000" {
+		t.Fatalf("value = %s (%v)", m.Value, err)
+	}
+	if m.Topic != "meters" || string(m.Key) != "home" {
+		t.Fatalf("message = %q key %q, want meters key home", m.Topic, m.Key)
+	}
+
+	// During the outage readings are buffered; the recovery replays them in
+	// batches of BatchSize, in order, before the new reading.
+	p.inj.FailAll(errors.New("kafka: not enough replicas"))
+	for _, read := range []string{"00002.000", "00003.000", "00004.000"} {
+		b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: read})
+	}
+	if b.Pending() != 3 {
+		t.Fatalf("pending = %d, want 3", b.Pending())
+	}
+	p.inj.Reset()
+	if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00005.000"}); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	var reads []string
+	for _, batch := range p.batches[1:] {
+		sizes = append(sizes, len(batch))
+		for _, m := range batch {
+			var r genai.GasMeterReadResult
+			json.Unmarshal(m.Value, &r)
+			reads = append(reads, r.Read)
+		}
+	}
+	if want := []int{2, 1, 1}; !slices.Equal(sizes, want) {
+		t.Fatalf("batch sizes = %v, want %v", sizes, want)
+	}
+	if want := []string{"00002.000", "00003.000", "00004.000", "00005.000"}; !slices.Equal(reads, want) {
+		t.Fatalf("produced = %v, want %v", reads, want)
+	}
+
+	if err := b.Close(); err != nil || !p.closed {
+		t.Fatalf("Close = %v, producer closed = %v", err, p.closed)
+	}
+}
+
+func TestKafkaConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	base := sink.KafkaConfig{Brokers: []string{"kafka:9093"}, Topic: "meters"}
+	tests := []struct {
+		name    string
+		mod     func(*sink.KafkaConfig)
+		wantErr bool
+	}{
+		{"defaults", func(*sink.KafkaConfig) {}, false},
+		{"full", func(c *sink.KafkaConfig) {
+			c.Acks, c.Compression = sink.KafkaAcksLeader, "zstd"
+			c.SASL = &sink.KafkaSASL{Mechanism: "SCRAM-SHA-512", Username: "meter", Password: "secret"}
+			c.TLS = &sink.KafkaTLS{CertFile: "client.pem", KeyFile: "client.key"}
+		}, false},
+		{"no topic", func(c *sink.KafkaConfig) { c.Topic = "" }, true},
+		{"acks", func(c *sink.KafkaConfig) { c.Acks = "1" }, true},
+		{"compression", func(c *sink.KafkaConfig) { c.Compression = "brotli" }, true},
+		{"sasl mechanism", func(c *sink.KafkaConfig) { c.SASL = &sink.KafkaSASL{Mechanism: "GSSAPI", Username: "u"} }, true},
+		{"tls key without cert", func(c *sink.KafkaConfig) { c.TLS = &sink.KafkaTLS{KeyFile: "client.key"} }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := base
+			tt.mod(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestKafkaProducer produces through a broker with SASL and a failed
+// delivery, which the buffer replays in order.
+func TestKafkaProducer(t *testing.T) {
+	t.Parallel()
+
+	cluster, err := kfake.NewCluster(
+		kfake.NumBrokers(1),
+		kfake.SeedTopics(3, "meters"),
+		kfake.EnableSASL(),
+		kfake.Superuser("SCRAM-SHA-256", "meter", "secret"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+
+	var dials atomic.Int32
+	cfg := sink.KafkaConfig{
+		Brokers:     cluster.ListenAddrs(),
+		Topic:       "meters",
+		Acks:        sink.KafkaAcksLeader,
+		Compression: "zstd",
+		SASL:        &sink.KafkaSASL{Mechanism: "SCRAM-SHA-256", Username: "meter", Password: "secret"},
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	p, err := sink.NewKafkaProducer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := sink.NewBuffered(sink.NewKafka(p, cfg), 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00001.000"}); err != nil {
+		t.Fatal(err)
+	}
+	// The broker refuses the next produce request: the delivery error keeps
+	// the reading for the next publish.
+	cluster.ControlKey(int16(kmsg.Produce), func(req kmsg.Request) (kmsg.Response, error, bool) {
+		preq := req.(*kmsg.ProduceRequest)
+		resp := preq.ResponseKind().(*kmsg.ProduceResponse)
+		for _, rt := range preq.Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic, st.TopicID = rt.Topic, rt.TopicID
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.ErrorCode = kerr.TopicAuthorizationFailed.Code
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil, true
+	})
+	b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00002.000"})
+	if b.Pending() != 1 {
+		t.Fatalf("pending = %d, want 1", b.Pending())
+	}
+	if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00003.000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if dials.Load() == 0 {
+		t.Fatal("the brokers were not dialed with Dial")
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.SASL(scram.Auth{User: "meter", Pass: "secret"}.AsSha256Mechanism()),
+		kgo.ConsumeTopics("meters"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	var reads []string
+	for len(reads) < 3 {
+		fetches := consumer.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("consumed %v: %v", reads, err)
+		}
+		fetches.EachRecord(func(r *kgo.Record) {
+			var got genai.GasMeterReadResult
+			if err := json.Unmarshal(r.Value, &got); err != nil {
+				t.Errorf("value %s: %v", r.Value, err)
+			}
+			if string(r.Key) != "home" {
+				t.Errorf("key %q, want home", r.Key)
+			}
+			reads = append(reads, got.Read)
+		})
+	}
+	if want := []string{"00001.000", "00002.000", "00003.000"}; !slices.Equal(reads, want) {
+		t.Fatalf("consumed %v, want %v", reads, want)
+	}
+}
@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/readingpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encodings of an [MQTT] sink. MQTT 3.1.1 messages have no headers, so the
+// encoding is the last level of the topic.
+const (
+	EncodingJSON     = "json"     // the reading's JSON, with the meter ID
+	EncodingProtobuf = "protobuf" // a readingpb.Reading, Consumption or CorrectionEvent
+)
+
+// MQTTConfig is where an [MQTT] sink publishes.
+type MQTTConfig struct {
+	// Topic is the prefix of the topics, e.g. "mqvision/home".
+	Topic string `yaml:"topic"`
+	// Encoding is EncodingJSON (default) or EncodingProtobuf.
+	Encoding string `yaml:"encoding"`
+	// QoS (0, 1 or 2) and Retain apply to the messages of the readings,
+	// consumption and corrections.
+	QoS    byte `yaml:"qos"`
+	Retain bool `yaml:"retain"`
+	// Delta, if set, also publishes the consumption since the previous
+	// reading of each meter; see [MQTTDeltaConfig].
+	Delta *MQTTDeltaConfig `yaml:"delta"`
+}
+
+// Validate checks c.
+func (c MQTTConfig) Validate() error {
+	if c.Topic == "" {
+		return errors.New("needs a topic")
+	}
+	if strings.ContainsAny(c.Topic, "+#") {
+		return fmt.Errorf("topic %q has wildcards", c.Topic)
+	}
+	switch c.Encoding {
+	case "", EncodingJSON, EncodingProtobuf:
+	default:
+		return fmt.Errorf("unknown encoding %q, want %s or %s", c.Encoding, EncodingJSON, EncodingProtobuf)
+	}
+	if c.QoS > 2 {
+		return fmt.Errorf("qos %d, want 0, 1 or 2", c.QoS)
+	}
+	if c.Delta != nil {
+		if err := c.Delta.Validate(); err != nil {
+			return fmt.Errorf("delta: %w", err)
+		}
+	}
+	return nil
+}
+
+// PublishFunc publishes payload to topic of an MQTT broker with qos and the
+// retain flag, such as the PublishWith method of the daemon's MQTT client.
+type PublishFunc func(topic string, qos byte, retain bool, payload []byte) error
+
+// MQTT publishes every reading to {topic}/reading/{encoding}, the
+// consumption since the previous one to {topic}/consumption/{encoding} and
+// corrections to {topic}/correction/{encoding}, so that subscribers pick the
+// events and the encoding they decode. With a delta configured it also
+// publishes to {topic}/delta/json; see [MQTTDeltaConfig].
+type MQTT struct {
+	publish  PublishFunc
+	topic    string
+	encoding string
+	qos      byte
+	retain   bool
+	delta    *deltas
+}
+
+// NewMQTT returns a sink publishing as cfg sets with publish. The deltas
+// are those of counter m.
+func NewMQTT(publish PublishFunc, cfg MQTTConfig, m genai.Meter) *MQTT {
+	enc := cfg.Encoding
+	if enc == "" {
+		enc = EncodingJSON
+	}
+	out := &MQTT{publish: publish, topic: strings.TrimSuffix(cfg.Topic, "/"), encoding: enc, qos: cfg.QoS, retain: cfg.Retain}
+	if cfg.Delta != nil {
+		out.delta = newDeltas(*cfg.Delta, m)
+	}
+	return out
+}
+
+// Publish implements [Sink].
+func (m *MQTT) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	var payload []byte
+	var err error
+	if m.encoding == EncodingProtobuf {
+		payload, err = readingpb.Marshal(meterID, r)
+	} else {
+		payload, err = json.Marshal(struct {
+			MeterID string `json:"meter_id"`
+			*genai.GasMeterReadResult
+		}{meterID, r})
+	}
+	if err != nil {
+		return fmt.Errorf("encode reading: %w", err)
+	}
+	if err := m.publish(m.topic+"/reading/"+m.encoding, m.qos, m.retain, payload); err != nil {
+		return err
+	}
+	if m.delta == nil {
+		return nil
+	}
+	d, ok := m.delta.next(meterID, r, time.Now())
+	if !ok {
+		return nil
+	}
+	if payload, err = json.Marshal(d); err != nil {
+		return fmt.Errorf("encode delta: %w", err)
+	}
+	return m.publish(m.topic+"/delta/"+EncodingJSON, m.delta.cfg.QoS, m.delta.cfg.Retain, payload)
+}
+
+// PublishConsumption implements [ConsumptionSink].
+func (m *MQTT) PublishConsumption(ctx context.Context, c Consumption) error {
+	var payload []byte
+	var err error
+	if m.encoding == EncodingProtobuf {
+		pb := readingpb.ConsumptionToProto(c.MeterID, c.At, c.Usage)
+		pb.Cumulative = readingpb.UsageToProto(c.Cumulative)
+		payload, err = proto.Marshal(pb)
+	} else {
+		payload, err = json.Marshal(c.Point().object())
+	}
+	if err != nil {
+		return fmt.Errorf("encode consumption: %w", err)
+	}
+	return m.publish(m.topic+"/consumption/"+m.encoding, m.qos, m.retain, payload)
+}
+
+// PublishCorrection implements [CorrectionSink]: the correction is published
+// to {topic}/correction/{encoding}, as the JSON of c or a
+// readingpb.CorrectionEvent. The corrected reading is not published again,
+// so that subscribers to the readings do not count it twice.
+func (m *MQTT) PublishCorrection(ctx context.Context, c CorrectionEvent) error {
+	var payload []byte
+	var err error
+	if m.encoding == EncodingProtobuf {
+		payload, err = proto.Marshal(readingpb.CorrectionToProto(c.MeterID, c.By, c.Reading))
+	} else {
+		payload, err = json.Marshal(c)
+	}
+	if err != nil {
+		return fmt.Errorf("encode correction: %w", err)
+	}
+	return m.publish(m.topic+"/correction/"+m.encoding, m.qos, m.retain, payload)
+}
+
+// Close implements [Sink]; the client is left to its owner.
+func (m *MQTT) Close() error { return nil }
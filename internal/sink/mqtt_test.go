@@ -0,0 +1,209 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/readingpb"
+	"github.com/suapapa/mqvision/internal/sink"
+	"google.golang.org/protobuf/proto"
+)
+
+type published struct {
+	topic   string
+	payload []byte
+}
+
+func TestMQTT(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 13, 17, 0, time.UTC)
+	r := &genai.GasMeterReadResult{Read: "02924.457", ReadAt: at}
+	c := sink.Consumption{MeterID: "home", At: at, Usage: billing.Usage{Raw: 1.5, Corrected: 1.45}}
+	fixed := &genai.GasMeterReadResult{ID: "r1", Read: "02924.475", ReadAt: at}
+	fixed.Correct("home", "02924.457", "read in person", at.Add(time.Hour))
+	fix := sink.NewCorrectionEvent("home", "ops", fixed)
+	tests := []struct {
+		encoding string
+		check    func(t *testing.T, reading, consumption, correction []byte)
+	}{
+		{"", func(t *testing.T, reading, consumption, correction []byte) {
+			var got struct {
+				MeterID string `json:"meter_id"`
+				Read    string `json:"read"`
+			}
+			if err := json.Unmarshal(reading, &got); err != nil || got.MeterID != "home" || got.Read != r.Read {
+				t.Fatalf("reading %s (%v)", reading, err)
+			}
+			var usage map[string]any
+			if err := json.Unmarshal(consumption, &usage); err != nil || usage["meter"] != "home" || usage["raw"] != 1.5 {
+				t.Fatalf("consumption %s (%v)", consumption, err)
+			}
+			var got2 sink.CorrectionEvent
+			if err := json.Unmarshal(correction, &got2); err != nil || got2.ReadingID != "r1" || got2.Old != "02924.475" || got2.New != "02924.457" || got2.By != "ops" || got2.Reason != "read in person" {
+				t.Fatalf("correction %s (%v)", correction, err)
+			}
+		}},
+		{sink.EncodingProtobuf, func(t *testing.T, reading, consumption, correction []byte) {
+			meterID, got, err := readingpb.Unmarshal(reading)
+			if err != nil || meterID != "home" || got.Read != r.Read || !got.ReadAt.Equal(at) {
+				t.Fatalf("reading %q, %+v (%v)", meterID, got, err)
+			}
+			var m readingpb.Consumption
+			if err := proto.Unmarshal(consumption, &m); err != nil {
+				t.Fatalf("consumption: %v", err)
+			}
+			if _, _, u, err := readingpb.ConsumptionFromProto(&m); err != nil || u != c.Usage {
+				t.Fatalf("consumption %+v (%v)", u, err)
+			}
+			var e readingpb.CorrectionEvent
+			if err := proto.Unmarshal(correction, &e); err != nil || e.GetOld() != "02924.475" || e.GetNew() != "02924.457" || e.GetBy() != "ops" {
+				t.Fatalf("correction %v (%v)", &e, err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			t.Parallel()
+
+			var msgs []published
+			m := sink.NewMQTT(func(topic string, _ byte, _ bool, payload []byte) error {
+				msgs = append(msgs, published{topic, payload})
+				return nil
+			}, sink.MQTTConfig{Topic: "mqvision/home/", Encoding: tt.encoding}, genai.DefaultMeter)
+			if err := m.Publish(ctx, "home", r); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if err := m.PublishConsumption(ctx, c); err != nil {
+				t.Fatalf("PublishConsumption: %v", err)
+			}
+			if err := m.PublishCorrection(ctx, fix); err != nil {
+				t.Fatalf("PublishCorrection: %v", err)
+			}
+			enc := tt.encoding
+			if enc == "" {
+				enc = sink.EncodingJSON
+			}
+			if len(msgs) != 3 || msgs[0].topic != "mqvision/home/reading/"+enc || msgs[1].topic != "mqvision/home/consumption/"+enc || msgs[2].topic != "mqvision/home/correction/"+enc {
+				t.Fatalf("published to %v", msgs)
+			}
+			tt.check(t, msgs[0].payload, msgs[1].payload, msgs[2].payload)
+		})
+	}
+}
+
+func TestMQTTConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg     sink.MQTTConfig
+		wantErr bool
+	}{
+		{sink.MQTTConfig{Topic: "mqvision/home"}, false},
+		{sink.MQTTConfig{Topic: "mqvision/home", Encoding: sink.EncodingProtobuf}, false},
+		{sink.MQTTConfig{}, true},
+		{sink.MQTTConfig{Topic: "mqvision/#"}, true},
+		{sink.MQTTConfig{Topic: "mqvision/home", Encoding: "cbor"}, true},
+		{sink.MQTTConfig{Topic: "mqvision/home", QoS: 3}, true},
+		{sink.MQTTConfig{Topic: "mqvision/home", Delta: &sink.MQTTDeltaConfig{QoS: 2, FirstReading: sink.FirstReadingZero}}, false},
+		{sink.MQTTConfig{Topic: "mqvision/home", Delta: &sink.MQTTDeltaConfig{FirstReading: "always"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMQTTDelta(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	reading := func(read string, after time.Duration) *genai.GasMeterReadResult {
+		return &genai.GasMeterReadResult{Read: read, ReadAt: at.Add(after)}
+	}
+	type want struct {
+		delta, elapsed float64
+		first          bool
+	}
+	tests := []struct {
+		name  string
+		first string
+		reads []*genai.GasMeterReadResult
+		want  []want
+	}{
+		{"skip first", "", []*genai.GasMeterReadResult{reading("02924.457", 0), reading("02924.557", time.Minute), reading("02924.857", 3*time.Minute)},
+			[]want{{0.1, 60, false}, {0.3, 120, false}}},
+		{"zero first", sink.FirstReadingZero, []*genai.GasMeterReadResult{reading("02924.457", 0), reading("02924.557", time.Minute)},
+			[]want{{0, 0, true}, {0.1, 60, false}}},
+		{"rollover", "", []*genai.GasMeterReadResult{reading("99999.900", 0), reading("00000.100", time.Minute)},
+			[]want{{0.2, 60, false}}},
+		{"duplicate", "", []*genai.GasMeterReadResult{reading("02924.457", 0), reading("02924.557", time.Minute), reading("02924.557", 2*time.Minute)},
+			[]want{{0.1, 60, false}, {0, 60, false}}},
+		// A misread lower is 0, and the next reading counts from the higher.
+		{"decrease", "", []*genai.GasMeterReadResult{reading("02924.457", 0), reading("02914.457", time.Minute), reading("02924.500", 2*time.Minute)},
+			[]want{{0, 60, false}, {0.043, 60, false}}},
+		{"maintenance", "", []*genai.GasMeterReadResult{reading("02924.457", 0), {Read: "00001.000", ReadAt: at.Add(time.Minute), Maintenance: true}, reading("00001.500", 2*time.Minute)},
+			[]want{{0, 60, false}, {0.5, 60, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var deltas []sink.Delta
+			m := sink.NewMQTT(func(topic string, qos byte, retain bool, payload []byte) error {
+				if topic != "mqvision/home/delta/json" {
+					return nil
+				}
+				if qos != 1 || !retain {
+					t.Errorf("delta published with qos %d, retain %t", qos, retain)
+				}
+				var d sink.Delta
+				if err := json.Unmarshal(payload, &d); err != nil {
+					t.Fatalf("delta %s: %v", payload, err)
+				}
+				deltas = append(deltas, d)
+				return nil
+			}, sink.MQTTConfig{Topic: "mqvision/home", Delta: &sink.MQTTDeltaConfig{QoS: 1, Retain: true, FirstReading: tt.first}}, genai.DefaultMeter)
+			for _, r := range tt.reads {
+				if err := m.Publish(ctx, "home", r); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+			}
+			if len(deltas) != len(tt.want) {
+				t.Fatalf("published %+v, want %+v", deltas, tt.want)
+			}
+			for i, w := range tt.want {
+				if d := deltas[i]; d.Delta != w.delta || d.ElapsedSeconds != w.elapsed || d.FirstReading != w.first || d.MeterID != "home" {
+					t.Errorf("delta %d = %+v, want %+v", i, d, w)
+				}
+			}
+		})
+	}
+
+	// A stale reading repeats the last one: 0, not the last delta again.
+	var got []sink.Delta
+	m := sink.NewMQTT(func(topic string, _ byte, _ bool, payload []byte) error {
+		if topic == "mqvision/home/delta/json" {
+			var d sink.Delta
+			json.Unmarshal(payload, &d)
+			got = append(got, d)
+		}
+		return nil
+	}, sink.MQTTConfig{Topic: "mqvision/home", Delta: &sink.MQTTDeltaConfig{}}, genai.DefaultMeter)
+	last := reading("02924.557", time.Minute)
+	for _, r := range []*genai.GasMeterReadResult{reading("02924.457", 0), last, last.AsStale()} {
+		if err := m.Publish(ctx, "home", r); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if len(got) != 2 || got[0].Delta != 0.1 || got[1].Delta != 0 || !got[1].Stale {
+		t.Fatalf("published %+v, want 0.1 then a stale 0", got)
+	}
+}
@@ -0,0 +1,265 @@
+package sink
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Measurements of the points of readings and consumption.
+const (
+	MeasurementReading     = "meter_reading"
+	MeasurementConsumption = "meter_consumption"
+)
+
+// Point is a reading, consumption or correction as a time-series point.
+// Every sink writing to a time-series database builds its points with
+// [Entry.Point], [Consumption.Point] and [CorrectionEvent.Point], so they all
+// select the same tags and fields.
+type Point struct {
+	Measurement string
+	// Tags are the indexed, low-cardinality dimensions, sorted by key.
+	Tags []Tag
+	// Fields are the values in a fixed order; a value is a float64, int64,
+	// bool or string.
+	Fields []Field
+	Time   time.Time
+}
+
+// Tag is an indexed dimension of a [Point].
+type Tag struct{ Key, Value string }
+
+// Field is a value of a [Point].
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Point returns the point of the reading: tagged with the meter, utility and
+// model, with the value as a float and the reading as written.
+func (e Entry) Point() (Point, error) {
+	r := e.Reading
+	value, err := strconv.ParseFloat(r.Read, 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("reading %q: %w", r.Read, err)
+	}
+	p := Point{Measurement: MeasurementReading, Time: r.ReadAt}
+	p.tag("meter", e.MeterID)
+	p.tag("utility", r.Utility)
+	p.tag("model", r.Model)
+	p.field("value", value)
+	p.field("read", r.Read)
+	p.field("ambiguous", r.Ambiguous)
+	p.field("stale", r.Stale)
+	if d, err := time.ParseDuration(r.ItTakes); err == nil {
+		p.field("duration_ms", d.Milliseconds())
+	}
+	if r.Issue != nil {
+		p.field("issue", r.Issue.Kind)
+	}
+	if r.ID != "" {
+		p.field("id", r.ID)
+	}
+	if len(r.Warnings) > 0 {
+		p.field("warnings", strings.Join(r.Warnings, "; "))
+	}
+	if r.Correction != nil {
+		p.field("corrected", true)
+		p.field("original", r.Correction.Original)
+	}
+	if r.Maintenance {
+		p.field("maintenance", true)
+	}
+	if r.MeterExchanged != nil {
+		p.field("meter_exchanged", true)
+	}
+	return p, nil
+}
+
+// LineProtocol returns the reading in InfluxDB line protocol, or "" if its
+// value does not parse.
+func (e Entry) LineProtocol() string {
+	p, err := e.Point()
+	if err != nil {
+		return ""
+	}
+	return p.LineProtocol()
+}
+
+// Consumption is the usage of a meter since its previous reading.
+type Consumption struct {
+	MeterID string
+	At      time.Time // of the reading the usage ends at
+	Usage   billing.Usage
+	// Cumulative is the usage since the baseline of the contract, if one is
+	// configured; see [billing.Since].
+	Cumulative *billing.Usage
+}
+
+// ConsumptionSink is a Sink that also takes the consumption between
+// readings, such as the time-series sinks.
+type ConsumptionSink interface {
+	Sink
+	PublishConsumption(ctx context.Context, c Consumption) error
+}
+
+// CorrectionEvent is a change of a reading sinks were sent before: a manual
+// correction of its value from Old to New, made By an API token or a
+// command. Reading is the reading as corrected, for sinks that replace their
+// copy.
+type CorrectionEvent struct {
+	MeterID   string                    `json:"meter_id"`
+	ReadingID string                    `json:"reading_id"`
+	Old       string                    `json:"old"`
+	New       string                    `json:"new"`
+	Reason    string                    `json:"reason,omitempty"`
+	By        string                    `json:"by,omitempty"`
+	At        time.Time                 `json:"at"`
+	Reading   *genai.GasMeterReadResult `json:"reading"`
+}
+
+// NewCorrectionEvent returns the correction of r, a reading of meterID with
+// its [genai.Correction] set, made by by.
+func NewCorrectionEvent(meterID, by string, r *genai.GasMeterReadResult) CorrectionEvent {
+	c := CorrectionEvent{MeterID: meterID, ReadingID: r.ID, New: r.Read, By: by, Reading: r}
+	if fix := r.Correction; fix != nil {
+		// Corrections stored before Previous was recorded replaced Original.
+		c.Old, c.Reason, c.At = cmp.Or(fix.Previous, fix.Original), fix.Note, fix.At
+	}
+	return c
+}
+
+// CorrectionSink is a Sink that also takes corrections of readings it was
+// given before, so that it can fix its copy or tell its consumers.
+type CorrectionSink interface {
+	Sink
+	PublishCorrection(ctx context.Context, c CorrectionEvent) error
+}
+
+// Point returns the point of the corrected reading for time-series
+// databases that only append, such as InfluxDB: the point of [Entry.Point]
+// with a corrected=true tag instead of the corrected field, and the previous
+// value, the reason and who corrected it as fields. The tag puts it in a
+// series of its own, so the original point is kept next to it at the same
+// time, and a later correction replaces this one; queries prefer the
+// corrected series where it has a point.
+func (c CorrectionEvent) Point() (Point, error) {
+	p, err := Entry{MeterID: c.MeterID, Reading: c.Reading}.Point()
+	if err != nil {
+		return Point{}, err
+	}
+	p.Fields = slices.DeleteFunc(p.Fields, func(f Field) bool { return f.Key == "corrected" })
+	p.tag("corrected", "true")
+	p.field("previous", c.Old)
+	if c.Reason != "" {
+		p.field("reason", c.Reason)
+	}
+	if c.By != "" {
+		p.field("by", c.By)
+	}
+	return p, nil
+}
+
+// Point returns the point of the consumption, tagged with the meter, and
+// with the cumulative fields when Cumulative is set.
+func (c Consumption) Point() Point {
+	p := Point{Measurement: MeasurementConsumption, Time: c.At}
+	p.tag("meter", c.MeterID)
+	p.field("raw", c.Usage.Raw)
+	p.field("corrected", c.Usage.Corrected)
+	if c.Usage.Energy != 0 {
+		p.field("energy_kwh", c.Usage.Energy)
+	}
+	if u := c.Cumulative; u != nil {
+		p.field("cumulative_raw", u.Raw)
+		p.field("cumulative_corrected", u.Corrected)
+		if u.Energy != 0 {
+			p.field("cumulative_energy_kwh", u.Energy)
+		}
+	}
+	return p
+}
+
+// LineProtocol returns the consumption in InfluxDB line protocol.
+func (c Consumption) LineProtocol() string {
+	return c.Point().LineProtocol()
+}
+
+// tag adds a tag unless value is empty, which line protocol cannot carry.
+func (p *Point) tag(key, value string) {
+	if value == "" {
+		return
+	}
+	i, _ := slices.BinarySearchFunc(p.Tags, key, func(t Tag, k string) int { return strings.Compare(t.Key, k) })
+	p.Tags = slices.Insert(p.Tags, i, Tag{key, value})
+}
+
+func (p *Point) field(key string, value any) {
+	p.Fields = append(p.Fields, Field{key, value})
+}
+
+// LineProtocol returns p in InfluxDB line protocol with a nanosecond
+// timestamp, e.g.
+//
+//	meter_reading,meter=home,utility=gas value=1234.567,read="01234.567",ambiguous=false 1762492380000000000
+//
+// Floats are written as floats, integers with the i suffix and strings quoted.
+// Non-finite floats, which line protocol cannot represent, are left out.
+func (p Point) LineProtocol() string {
+	var b strings.Builder
+	b.WriteString(lineEscaper.measurement.Replace(p.Measurement))
+	for _, t := range p.Tags {
+		b.WriteByte(',')
+		b.WriteString(lineEscaper.key.Replace(t.Key))
+		b.WriteByte('=')
+		b.WriteString(lineEscaper.key.Replace(t.Value))
+	}
+	sep := byte(' ')
+	for _, f := range p.Fields {
+		v, ok := lineValue(f.Value)
+		if !ok {
+			continue
+		}
+		b.WriteByte(sep)
+		sep = ','
+		b.WriteString(lineEscaper.key.Replace(f.Key))
+		b.WriteByte('=')
+		b.WriteString(v)
+	}
+	if !p.Time.IsZero() {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+	}
+	return b.String()
+}
+
+var lineEscaper = struct{ measurement, key, str *strings.Replacer }{
+	measurement: strings.NewReplacer(",", `\,`, " ", `\ `),
+	key:         strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `),
+	str:         strings.NewReplacer(`\`, `\\`, `"`, `\"`),
+}
+
+func lineValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return `"` + lineEscaper.str.Replace(v) + `"`, true
+	}
+	return "", false
+}
@@ -0,0 +1,174 @@
+package sink_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+var pointAt = time.Date(2025, 11, 7, 5, 13, 0, 123, time.UTC)
+
+func pointReading() *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{
+		ID:      "abc",
+		Read:    "01234.500",
+		Utility: "gas",
+		ReadAt:  pointAt,
+		ItTakes: "1.5s",
+		Model:   "gpt 4o",
+		Issue:   &genai.Issue{Kind: genai.IssueGlare, Note: `sun "glare"`},
+	}
+}
+
+func TestLineProtocol(t *testing.T) {
+	t.Parallel()
+
+	got := sink.Entry{MeterID: "home, cabin", Reading: pointReading()}.LineProtocol()
+	want := `meter_reading,meter=home\,\ cabin,model=gpt\ 4o,utility=gas ` +
+		`value=1234.5,read="01234.500",ambiguous=false,stale=false,duration_ms=1500i,issue="glare",id="abc" ` +
+		"1762492380000000123"
+	if got != want {
+		t.Fatalf("LineProtocol =\n%s\nwant\n%s", got, want)
+	}
+
+	c := sink.Consumption{MeterID: "home", At: pointAt, Usage: billing.Usage{Raw: 2, Corrected: 2.05}}
+	if got, want := c.LineProtocol(), "meter_consumption,meter=home raw=2,corrected=2.05 1762492380000000123"; got != want {
+		t.Fatalf("LineProtocol = %s, want %s", got, want)
+	}
+	c.Cumulative = &billing.Usage{Raw: 320.5, Corrected: 328.5}
+	if got, want := c.LineProtocol(), "meter_consumption,meter=home raw=2,corrected=2.05,cumulative_raw=320.5,cumulative_corrected=328.5 1762492380000000123"; got != want {
+		t.Fatalf("LineProtocol with a baseline = %s, want %s", got, want)
+	}
+
+	fixed := pointReading()
+	fixed.Issue = nil
+	fixed.Correct("home", "01234.600", "read in person", pointAt.Add(time.Hour))
+	if got, want := (sink.Entry{MeterID: "home", Reading: fixed}).LineProtocol(), `meter_reading,meter=home,model=gpt\ 4o,utility=gas `+
+		`value=1234.6,read="01234.600",ambiguous=false,stale=false,duration_ms=1500i,id="abc",corrected=true,original="01234.500" `+
+		"1762492380000000123"; got != want {
+		t.Fatalf("LineProtocol of a correction =\n%s\nwant\n%s", got, want)
+	}
+	p, err := sink.NewCorrectionEvent("home", "ops", fixed).Point()
+	if got, want := p.LineProtocol(), `meter_reading,corrected=true,meter=home,model=gpt\ 4o,utility=gas `+
+		`value=1234.6,read="01234.600",ambiguous=false,stale=false,duration_ms=1500i,id="abc",original="01234.500",previous="01234.500",reason="read in person",by="ops" `+
+		"1762492380000000123"; err != nil || got != want {
+		t.Fatalf("LineProtocol of a correction event =\n%s (%v)\nwant\n%s", got, err, want)
+	}
+
+	if got := (sink.Entry{MeterID: "home", Reading: &genai.GasMeterReadResult{Read: "0?234.500"}}).LineProtocol(); got != "" {
+		t.Fatalf("LineProtocol of an unparsable reading = %q", got)
+	}
+}
+
+func TestStdout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{sink.FormatInflux, sink.Entry{MeterID: "home", Reading: pointReading()}.LineProtocol()},
+		{sink.FormatKeyValue, `measurement=meter_reading meter=home model="gpt 4o" utility=gas value=1234.5 read=01234.500 ` +
+			`ambiguous=false stale=false duration_ms=1500 issue=glare id=abc time=2025-11-07T05:13:00.000000123Z`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		s, err := sink.NewStdout(&buf, tt.format)
+		if err != nil {
+			t.Fatalf("NewStdout(%s): %v", tt.format, err)
+		}
+		if err := s.Publish(ctx, "home", pointReading()); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		if got := buf.String(); got != tt.want+"\n" {
+			t.Fatalf("%s:\n%s\nwant\n%s", tt.format, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	s, _ := sink.NewStdout(&buf, sink.FormatJSON)
+	if err := s.Publish(ctx, "home", pointReading()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := s.PublishConsumption(ctx, sink.Consumption{MeterID: "home", At: pointAt, Usage: billing.Usage{Raw: 2, Corrected: 2}}); err != nil {
+		t.Fatalf("PublishConsumption: %v", err)
+	}
+	dec := json.NewDecoder(&buf)
+	var reading, usage map[string]any
+	if err := dec.Decode(&reading); err != nil || reading["value"] != 1234.5 || reading["duration_ms"] != 1500.0 || reading["meter"] != "home" {
+		t.Fatalf("json reading = %v (%v); want numbers as numbers", reading, err)
+	}
+	if err := dec.Decode(&usage); err != nil || usage["measurement"] != sink.MeasurementConsumption || usage["raw"] != 2.0 {
+		t.Fatalf("json consumption = %v (%v)", usage, err)
+	}
+
+	if _, err := sink.NewStdout(io.Discard, "csv"); err == nil {
+		t.Fatalf("NewStdout(csv) succeeded")
+	}
+}
+
+func TestInflux(t *testing.T) {
+	t.Parallel()
+
+	var bodies []string
+	var query, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			http.NotFound(w, r)
+			return
+		}
+		query, auth = r.URL.RawQuery, r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), "fail") {
+			http.Error(w, `{"message":"partial write"}`, http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := sink.NewInflux(sink.InfluxConfig{URL: srv.URL + "/", Org: "home", Bucket: "meters", Token: "secret"})
+	r2 := pointReading()
+	r2.Read, r2.ReadAt = "01235.000", pointAt.Add(time.Hour)
+	entries := []sink.Entry{{MeterID: "home", Reading: pointReading()}, {MeterID: "home", Reading: r2}}
+	if err := s.PublishBatch(ctx, entries); err != nil {
+		t.Fatalf("PublishBatch: %v", err)
+	}
+	if auth != "Token secret" || query != "bucket=meters&org=home&precision=ns" {
+		t.Fatalf("auth %q, query %q", auth, query)
+	}
+	if want := entries[0].LineProtocol() + "\n" + entries[1].LineProtocol(); len(bodies) != 1 || bodies[0] != want {
+		t.Fatalf("bodies = %q, want one write of both readings", bodies)
+	}
+
+	fixed := pointReading()
+	fixed.Correct("home", "01234.600", "", pointAt.Add(time.Hour))
+	if err := s.PublishCorrection(ctx, sink.NewCorrectionEvent("home", "ops", fixed)); err != nil {
+		t.Fatalf("PublishCorrection: %v", err)
+	}
+	if len(bodies) != 2 || !strings.HasPrefix(bodies[1], "meter_reading,corrected=true,meter=home,") {
+		t.Fatalf("bodies = %q, want the correction in a series of its own", bodies)
+	}
+
+	fail := pointReading()
+	fail.Model = "fail"
+	if err := s.Publish(ctx, "home", fail); err == nil || !strings.Contains(err.Error(), "partial write") {
+		t.Fatalf("Publish error = %v, want the server's", err)
+	}
+	if err := (sink.InfluxConfig{URL: srv.URL}).Validate(); err == nil {
+		t.Fatalf("Validate without bucket succeeded")
+	}
+}
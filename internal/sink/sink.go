@@ -0,0 +1,149 @@
+// Package sink delivers accepted readings to their consumers.
+package sink
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Sink receives every accepted reading.
+type Sink interface {
+	Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error
+	Close() error
+}
+
+// Func adapts a function to [Sink]; Close is a no-op.
+type Func func(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error
+
+// Publish implements [Sink].
+func (f Func) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	return f(ctx, meterID, r)
+}
+
+// Close implements [Sink].
+func (f Func) Close() error { return nil }
+
+// Entry is a reading of a meter.
+type Entry struct {
+	MeterID string
+	Reading *genai.GasMeterReadResult
+}
+
+// Batcher is a Sink that can deliver several readings in one go, all or
+// none. [Buffered] replays its backlog through it.
+type Batcher interface {
+	Sink
+	PublishBatch(ctx context.Context, entries []Entry) error
+}
+
+// Buffered keeps readings its next sink failed to take and delivers them, in
+// order and as one batch if the sink is a [Batcher], before the next reading
+// once the sink recovers. When more than max
+// readings are waiting the oldest is dropped.
+type Buffered struct {
+	next Sink
+	max  int
+
+	mu      sync.Mutex
+	queue   []Entry
+	dropped int
+}
+
+// NewBuffered wraps next with an offline buffer of up to max readings.
+func NewBuffered(next Sink, max int) *Buffered {
+	return &Buffered{next: next, max: max}
+}
+
+// Publish implements [Sink]. It only fails when ctx is done; delivery
+// errors are logged and the reading is buffered.
+func (b *Buffered) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := b.flush(ctx)
+	if err == nil {
+		err = b.next.Publish(ctx, meterID, r)
+		if err == nil {
+			return nil
+		}
+	}
+	b.queue = append(b.queue, Entry{MeterID: meterID, Reading: r})
+	if len(b.queue) > b.max {
+		b.queue = b.queue[1:]
+		b.dropped++
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	log.Printf("Error publishing reading, %d buffered: %v", len(b.queue), err)
+	return nil
+}
+
+// Flush delivers the buffered readings and returns the first error.
+func (b *Buffered) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush(ctx)
+}
+
+func (b *Buffered) flush(ctx context.Context) error {
+	if bs, ok := b.next.(Batcher); ok && len(b.queue) > 0 {
+		if err := bs.PublishBatch(ctx, b.queue); err != nil {
+			return err
+		}
+		b.queue = nil
+		return nil
+	}
+	for len(b.queue) > 0 {
+		e := b.queue[0]
+		if err := b.next.Publish(ctx, e.MeterID, e.Reading); err != nil {
+			return err
+		}
+		b.queue = b.queue[1:]
+	}
+	return nil
+}
+
+// Pending returns the number of buffered readings.
+func (b *Buffered) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue)
+}
+
+// Dropped returns the number of readings dropped because the buffer was full.
+func (b *Buffered) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// PublishConsumption implements [ConsumptionSink] by passing c on if the next
+// sink takes consumption. It is not buffered: consumption is derived from
+// the readings, which are.
+func (b *Buffered) PublishConsumption(ctx context.Context, c Consumption) error {
+	if cs, ok := b.next.(ConsumptionSink); ok {
+		return cs.PublishConsumption(ctx, c)
+	}
+	return nil
+}
+
+// PublishCorrection implements [CorrectionSink] by passing the correction on
+// if the next sink takes corrections. Like consumption it is not buffered.
+func (b *Buffered) PublishCorrection(ctx context.Context, c CorrectionEvent) error {
+	if cs, ok := b.next.(CorrectionSink); ok {
+		return cs.PublishCorrection(ctx, c)
+	}
+	return nil
+}
+
+// Close implements [Sink]. Readings still buffered are lost.
+func (b *Buffered) Close() error {
+	if n := b.Pending(); n > 0 {
+		log.Printf("Closing sink with %d undelivered readings", n)
+	}
+	return b.next.Close()
+}
@@ -0,0 +1,88 @@
+package sink_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/faults"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+type recordingSink struct {
+	reads []string
+	ids   []string
+}
+
+func (s *recordingSink) Publish(_ context.Context, _ string, r *genai.GasMeterReadResult) error {
+	s.reads = append(s.reads, r.Read)
+	s.ids = append(s.ids, r.ID)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestBufferedDrainsAfterRecovery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rec := &recordingSink{}
+	inj := &faults.Injector{}
+	b := sink.NewBuffered(faults.Sink(rec, inj), 2)
+
+	inj.FailAll(nil)
+	for _, read := range []string{"00001.000", "00002.000", "00003.000"} {
+		if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: read}); err != nil {
+			t.Fatalf("Publish while failing: %v", err)
+		}
+	}
+	if b.Pending() != 2 || b.Dropped() != 1 || len(rec.reads) != 0 {
+		t.Fatalf("pending = %d, dropped = %d, delivered = %v", b.Pending(), b.Dropped(), rec.reads)
+	}
+
+	inj.Reset()
+	if err := b.Publish(ctx, "home", &genai.GasMeterReadResult{Read: "00004.000"}); err != nil {
+		t.Fatalf("Publish after recovery: %v", err)
+	}
+	if want := []string{"00002.000", "00003.000", "00004.000"}; !reflect.DeepEqual(rec.reads, want) {
+		t.Fatalf("delivered = %v, want %v", rec.reads, want)
+	}
+	if b.Pending() != 0 {
+		t.Fatalf("pending = %d after drain", b.Pending())
+	}
+}
+
+func TestBufferedReplayKeepsIDs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 5, 13, 0, 0, time.UTC)
+	var want []string
+	var readings []*genai.GasMeterReadResult
+	for i, read := range []string{"00001.000", "00002.000"} {
+		r := &genai.GasMeterReadResult{Read: read, ReadAt: at.Add(time.Duration(i) * time.Hour)}
+		r.ID = genai.ReadingID("home", r)
+		want = append(want, r.ID)
+		readings = append(readings, r)
+	}
+
+	// The first delivery reaches the consumer but is reported as failed, so
+	// it is replayed: the consumer sees it twice with the same ID.
+	rec := &recordingSink{}
+	inj := &faults.Injector{}
+	b := sink.NewBuffered(sink.Func(func(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+		rec.Publish(ctx, meterID, r)
+		return inj.Inject(ctx)
+	}), 10)
+	inj.FailNext(1, nil)
+	for _, r := range readings {
+		if err := b.Publish(ctx, "home", r); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if got := []string{want[0], want[0], want[1]}; !reflect.DeepEqual(rec.ids, got) {
+		t.Fatalf("delivered IDs = %v, want %v", rec.ids, got)
+	}
+}
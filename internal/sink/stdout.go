@@ -0,0 +1,141 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Formats of a [Stdout] sink.
+const (
+	FormatJSON     = "json"     // one JSON object per line
+	FormatInflux   = "influx"   // InfluxDB line protocol
+	FormatKeyValue = "keyvalue" // logfmt-style key=value pairs
+)
+
+// Formats lists the formats of a [Stdout] sink.
+var Formats = []string{FormatJSON, FormatInflux, FormatKeyValue}
+
+// Stdout writes every reading as a line to a writer, for piping into tools
+// such as the exec input of Telegraf. All formats carry the tags and fields
+// of [Entry.Point].
+type Stdout struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// NewStdout returns a sink writing to w in format, one of [Formats].
+func NewStdout(w io.Writer, format string) (*Stdout, error) {
+	switch format {
+	case FormatJSON, FormatInflux, FormatKeyValue:
+	default:
+		return nil, fmt.Errorf("unknown format %q, want one of %s", format, strings.Join(Formats, ", "))
+	}
+	return &Stdout{w: w, format: format}, nil
+}
+
+// Publish implements [Sink].
+func (s *Stdout) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	p, err := Entry{MeterID: meterID, Reading: r}.Point()
+	if err != nil {
+		return err
+	}
+	return s.write(p)
+}
+
+// PublishConsumption writes the consumption since the previous reading.
+func (s *Stdout) PublishConsumption(ctx context.Context, c Consumption) error {
+	return s.write(c.Point())
+}
+
+// PublishCorrection implements [CorrectionSink] with the point of
+// [CorrectionEvent.Point], as Influx writes it.
+func (s *Stdout) PublishCorrection(ctx context.Context, c CorrectionEvent) error {
+	p, err := c.Point()
+	if err != nil {
+		return err
+	}
+	return s.write(p)
+}
+
+func (s *Stdout) write(p Point) error {
+	var line string
+	switch s.format {
+	case FormatInflux:
+		line = p.LineProtocol()
+	case FormatKeyValue:
+		line = p.keyValue()
+	default:
+		b, err := json.Marshal(p.object())
+		if err != nil {
+			return fmt.Errorf("marshal point: %w", err)
+		}
+		line = string(b)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.w, line+"\n"); err != nil {
+		return fmt.Errorf("write %s: %w", p.Measurement, err)
+	}
+	return nil
+}
+
+// Close implements [Sink]; the writer is left open.
+func (s *Stdout) Close() error { return nil }
+
+// object is p as JSON: the measurement, its time and the tags and fields
+// in one flat object, with numbers as numbers.
+func (p Point) object() map[string]any {
+	obj := make(map[string]any, 2+len(p.Tags)+len(p.Fields))
+	for _, t := range p.Tags {
+		obj[t.Key] = t.Value
+	}
+	for _, f := range p.Fields {
+		obj[f.Key] = f.Value
+	}
+	obj["measurement"] = p.Measurement
+	if !p.Time.IsZero() {
+		obj["time"] = p.Time.Format(time.RFC3339Nano)
+	}
+	return obj
+}
+
+// keyValue is p as key=value pairs: the measurement, tags, fields and time,
+// with strings quoted when needed.
+func (p Point) keyValue() string {
+	kvs := []string{"measurement=" + p.Measurement}
+	for _, t := range p.Tags {
+		kvs = append(kvs, t.Key+"="+logfmtValue(t.Value))
+	}
+	for _, f := range p.Fields {
+		var v string
+		switch f := f.Value.(type) {
+		case string:
+			v = logfmtValue(f)
+		case float64:
+			v = strconv.FormatFloat(f, 'f', -1, 64)
+		default:
+			v = fmt.Sprint(f)
+		}
+		kvs = append(kvs, f.Key+"="+v)
+	}
+	if !p.Time.IsZero() {
+		kvs = append(kvs, "time="+p.Time.Format(time.RFC3339Nano))
+	}
+	return strings.Join(kvs, " ")
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\\\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WebhookConfig is where a [Webhook] sink posts to.
+type WebhookConfig struct {
+	// URL is the prefix of the paths posted to, e.g.
+	// "https://example.com/hooks/gas".
+	URL string `yaml:"url"`
+	// Headers are set on every request, e.g. an Authorization.
+	Headers map[string]string `yaml:"headers"`
+	// Timeout bounds each request (default 10s).
+	Timeout time.Duration `yaml:"timeout"`
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper `yaml:"-"`
+}
+
+// Validate checks that the URL is set and absolute.
+func (c WebhookConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("needs url")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %q is not http or https", c.URL)
+	}
+	return nil
+}
+
+// IdempotencyKeyHeader is the header a [Webhook] sets to the ID of the
+// reading posted, so that receivers can drop the deliveries [Buffered]
+// repeats.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Webhook posts every reading as JSON to {url}/reading, the consumption
+// since the previous one to {url}/consumption and corrections to
+// {url}/correction, the payloads of the JSON encoding of [MQTT]. Requests
+// answered with other than a 2xx status fail, so that [Buffered] keeps and
+// replays the reading.
+type Webhook struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+// NewWebhook returns a sink posting to cfg.URL.
+func NewWebhook(cfg WebhookConfig) *Webhook {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	return &Webhook{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport}}
+}
+
+// Publish implements [Sink].
+func (w *Webhook) Publish(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	return w.post(ctx, "/reading", r.ID, struct {
+		MeterID string `json:"meter_id"`
+		*genai.GasMeterReadResult
+	}{meterID, r})
+}
+
+// PublishConsumption implements [ConsumptionSink].
+func (w *Webhook) PublishConsumption(ctx context.Context, c Consumption) error {
+	return w.post(ctx, "/consumption", "", c.Point().object())
+}
+
+// PublishCorrection implements [CorrectionSink]. The idempotency key is the
+// reading's ID and the time of the correction, as a reading corrected twice
+// is two corrections.
+func (w *Webhook) PublishCorrection(ctx context.Context, c CorrectionEvent) error {
+	return w.post(ctx, "/correction", c.ReadingID+"@"+strconv.FormatInt(c.At.UnixNano(), 10), c)
+}
+
+// post posts v as JSON to path, with key as the idempotency key if set.
+func (w *Webhook) post(ctx context.Context, path, key string, v any) (err error) {
+	ctx, span := otel.Tracer(genai.TracerName).Start(ctx, genai.SpanPublish, trace.WithAttributes(
+		attribute.String("http.request.method", http.MethodPost),
+		attribute.String("url.path", path),
+	), trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { genai.EndSpan(span, err) }()
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", path[1:], err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", path[1:], err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post %s: %s: %s", path[1:], resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close implements [Sink].
+func (w *Webhook) Close() error { return nil }
@@ -0,0 +1,92 @@
+package sink_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/sink"
+)
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		path, key, auth string
+		body            map[string]any
+	}
+	var reqs []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), "fail") {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		var body map[string]any
+		if err := json.Unmarshal(b, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqs = append(reqs, request{r.URL.Path, r.Header.Get(sink.IdempotencyKeyHeader), r.Header.Get("Authorization"), body})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := sink.NewWebhook(sink.WebhookConfig{URL: srv.URL + "/hooks/gas/", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err := s.Publish(ctx, "home", pointReading()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := s.PublishConsumption(ctx, sink.Consumption{MeterID: "home", At: pointAt, Usage: billing.Usage{Raw: 2, Corrected: 2}}); err != nil {
+		t.Fatalf("PublishConsumption: %v", err)
+	}
+	fixed := pointReading()
+	fixed.Correct("home", "01234.600", "read in person", pointAt.Add(time.Hour))
+	if err := s.PublishCorrection(ctx, sink.NewCorrectionEvent("home", "ops", fixed)); err != nil {
+		t.Fatalf("PublishCorrection: %v", err)
+	}
+
+	if len(reqs) != 3 {
+		t.Fatalf("requests = %+v, want 3", reqs)
+	}
+	if r := reqs[0]; r.path != "/hooks/gas/reading" || r.key != "abc" || r.auth != "Bearer secret" || r.body["meter_id"] != "home" || r.body["read"] != "01234.500" {
+		t.Fatalf("reading request = %+v", r)
+	}
+	if r := reqs[1]; r.path != "/hooks/gas/consumption" || r.key != "" || r.body["raw"] != 2.0 {
+		t.Fatalf("consumption request = %+v", r)
+	}
+	if r := reqs[2]; r.path != "/hooks/gas/correction" || !strings.HasPrefix(r.key, "abc@") ||
+		r.body["reading_id"] != "abc" || r.body["old"] != "01234.500" || r.body["new"] != "01234.600" || r.body["reason"] != "read in person" || r.body["by"] != "ops" {
+		t.Fatalf("correction request = %+v", r)
+	}
+
+	fail := pointReading()
+	fail.Model = "fail"
+	if err := s.Publish(ctx, "home", fail); err == nil || !strings.Contains(err.Error(), "maintenance") {
+		t.Fatalf("Publish error = %v, want the server's", err)
+	}
+}
+
+func TestWebhookConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cfg     sink.WebhookConfig
+		wantErr bool
+	}{
+		{sink.WebhookConfig{URL: "https://example.com/hooks/gas"}, false},
+		{sink.WebhookConfig{}, true},
+		{sink.WebhookConfig{URL: "example.com/hooks"}, true},
+		{sink.WebhookConfig{URL: "ftp://example.com"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package store
+
+import "errors"
+
+// FailWrites makes the writes of s, a [*File], fail until the returned
+// function is called.
+func FailWrites(s Store) (restore func()) {
+	f := s.(*File)
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+	f.writeErr = errors.New("injected write failure")
+	return func() {
+		f.mem.mu.Lock()
+		defer f.mem.mu.Unlock()
+		f.writeErr = nil
+	}
+}
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// File is a [Store] persisted as a JSONL file with one reading per line.
+// The whole history is loaded on open and kept in memory; Save appends a
+// line and Prune rewrites the file. The [EventLog] is kept in the same
+// file: Save writes the reading and its event on one line, so that neither
+// is kept without the other, and the other events have lines of their own.
+// Prune and Thin rewrite the file without the events of the readings they
+// delete, starting it with a line of the compaction mark.
+// Meter state is kept in a JSON file next to it, named after the history
+// with ".state" appended.
+type File struct {
+	path string
+	mem  *Memory
+	f    *os.File
+	// writeErr, if set, fails the writes instead of making them, in tests.
+	writeErr error
+}
+
+// fileLine is a line of [File]: a reading with the event that saved it, if
+// it was saved after the last rewrite, or an event on its own.
+type fileLine struct {
+	record
+	// Event is the event of the reading of the line, which is left out of
+	// it.
+	Event *Event `json:"event,omitempty"`
+	// Logged is the event of a line without a reading.
+	Logged *Event `json:"logged,omitempty"`
+	// Compacted is the first line of a file rewritten after events were
+	// compacted away.
+	Compacted *compaction `json:"compacted,omitempty"`
+}
+
+// compaction is the state of the [EventLog] of a [File] kept across the
+// events compacted away.
+type compaction struct {
+	Through uint64 `json:"through"` // the highest Seq compacted away
+	Seq     uint64 `json:"seq"`     // the Seq of the last event logged
+}
+
+// eventLine is the form a [fileLine] with only an event is written in.
+type eventLine struct {
+	Logged Event `json:"logged"`
+}
+
+// compactionLine is the form the compaction mark of a [fileLine] is
+// written in.
+type compactionLine struct {
+	Compacted compaction `json:"compacted"`
+}
+
+var (
+	_ Store       = (*File)(nil)
+	_ StateStore  = (*File)(nil)
+	_ Corrector   = (*File)(nil)
+	_ Thinner     = (*File)(nil)
+	_ Provenancer = (*File)(nil)
+	_ EventLog    = (*File)(nil)
+)
+
+// OpenFile opens the history at path, creating it if needed. A truncated
+// last line, as left by a crash during Save, is dropped; the sequence number
+// of its event is given to the next one, as it was never read back.
+func OpenFile(path string) (*File, error) {
+	s := &File{path: path, mem: NewMemory()}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	s.f = f
+	return s, nil
+}
+
+func (s *File) statePath() string { return s.path + ".state" }
+
+func (s *File) load() error {
+	if b, err := os.ReadFile(s.statePath()); err == nil {
+		if err := json.Unmarshal(b, &s.mem.state); err != nil {
+			return fmt.Errorf("parse store state %s: %w", s.statePath(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("read store state: %w", err)
+	}
+
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read store: %w", err)
+	}
+
+	lines := bytes.Split(b, []byte("\n"))
+	good := 0       // length of the valid prefix of b
+	var prev uint64 // Seq of the previous event
+	for i, line := range lines {
+		if len(line) == 0 {
+			good++
+			continue
+		}
+		var fl fileLine
+		if err := json.Unmarshal(line, &fl); err != nil {
+			if i == len(lines)-1 {
+				// No trailing newline: Save was interrupted.
+				log.Printf("Dropping truncated last line of %s: %v", s.path, err)
+				return os.Truncate(s.path, int64(good))
+			}
+			return fmt.Errorf("parse store %s line %d: %w", s.path, i+1, err)
+		}
+		good += len(line) + 1
+		if c := fl.Compacted; c != nil {
+			s.mem.compacted, s.mem.last = c.Through, c.Seq
+			continue
+		}
+		// The events must be numbered 1, 2, ... but for those compacted
+		// away.
+		e := fl.Logged
+		if fl.Event != nil {
+			e = fl.Event
+			e.Reading = &fl.GasMeterReadResult
+		}
+		if e != nil {
+			if e.Seq <= prev || e.Seq-1 > max(prev, s.mem.compacted) {
+				return fmt.Errorf("parse store %s line %d: event seq %d after %d", s.path, i+1, e.Seq, prev)
+			}
+			prev = e.Seq
+			s.mem.addEvent(*e)
+		}
+		if fl.Logged == nil {
+			s.mem.insert(fl.MeterID, fl.GasMeterReadResult)
+		}
+	}
+	return nil
+}
+
+// Save implements [Store]. The reading and its event are one line.
+func (s *File) Save(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	e := s.mem.nextEvent(EventReadingAccepted, meterID, r)
+	saved := e
+	saved.Reading = nil // the reading of the line
+	line, err := json.Marshal(fileLine{record: record{MeterID: meterID, GasMeterReadResult: *r}, Event: &saved})
+	if err != nil {
+		return fmt.Errorf("marshal reading: %w", err)
+	}
+	if err := s.append(line); err != nil {
+		return fmt.Errorf("write store: %w", err)
+	}
+	s.mem.insert(meterID, cloneResult(r))
+	s.mem.addEvent(e)
+	return nil
+}
+
+// append writes line to the end of the file; the caller holds the lock. A
+// line only partly written is cut off again, so that the next one starts on
+// a line of its own.
+func (s *File) append(line []byte) error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	end, err := s.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		if terr := s.f.Truncate(end); terr != nil {
+			return errors.Join(err, terr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Latest implements [Store].
+func (s *File) Latest(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error) {
+	return s.mem.Latest(ctx, meterID)
+}
+
+// ReadingsBetween implements [Store].
+func (s *File) ReadingsBetween(ctx context.Context, meterID string, from, to time.Time) ([]*genai.GasMeterReadResult, error) {
+	return s.mem.ReadingsBetween(ctx, meterID, from, to)
+}
+
+// Prune implements [Store]. The remaining history and events are written to
+// a temporary file which then replaces the old one, so a crash leaves either
+// version intact.
+func (s *File) Prune(ctx context.Context, before time.Time) (int, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	n := 0
+	for _, rs := range s.mem.meters {
+		for _, r := range rs {
+			if r.ReadAt.Before(before) {
+				n++
+			}
+		}
+	}
+	events, compacted := s.mem.compactEvents(func(e Event) bool { return prunedEvent(e, before) })
+	if n == 0 && len(events) == len(s.mem.events) {
+		return 0, nil
+	}
+	err := s.rewrite(events, compacted, func(_ string, _ int, r genai.GasMeterReadResult) (genai.GasMeterReadResult, bool) {
+		return r, !r.ReadAt.Before(before)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune store: %w", err)
+	}
+	s.mem.events, s.mem.compacted = events, compacted
+	return s.mem.prune(before), nil
+}
+
+// CorrectReading implements [Corrector]. The history is rewritten as by
+// Prune, with the event of the correction.
+func (s *File) CorrectReading(ctx context.Context, meterID, readingID, newValue, note string) (*genai.GasMeterReadResult, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	i := s.mem.find(meterID, readingID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	fixed := cloneResult(&s.mem.meters[meterID][i])
+	fixed.Correct(meterID, newValue, note, time.Now())
+	e := s.mem.nextEvent(EventCorrection, meterID, &fixed)
+	err := s.rewrite(append(slices.Clip(s.mem.events), e), s.mem.compacted, func(id string, j int, r genai.GasMeterReadResult) (genai.GasMeterReadResult, bool) {
+		if id == meterID && j == i {
+			return fixed, true
+		}
+		return r, true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("correct reading: %w", err)
+	}
+	s.mem.meters[meterID][i] = fixed
+	s.mem.addEvent(e)
+	out := cloneResult(&fixed)
+	return &out, nil
+}
+
+// LogEvent implements [EventLog]. An event that could not be written is not
+// logged, and its sequence number goes to the next one.
+func (s *File) LogEvent(ctx context.Context, e Event) (Event, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	e.Seq = s.mem.last + 1
+	line, err := json.Marshal(eventLine{Logged: e})
+	if err != nil {
+		return Event{}, fmt.Errorf("marshal event: %w", err)
+	}
+	if err := s.append(line); err != nil {
+		return Event{}, fmt.Errorf("write store: %w", err)
+	}
+	s.mem.addEvent(e)
+	return cloneEvent(e), nil
+}
+
+// EventsSince implements [EventLog].
+func (s *File) EventsSince(ctx context.Context, seq uint64, limit int) ([]Event, error) {
+	return s.mem.EventsSince(ctx, seq, limit)
+}
+
+// Compacted implements [EventLog].
+func (s *File) Compacted(ctx context.Context) (uint64, error) {
+	return s.mem.Compacted(ctx)
+}
+
+// Provenance implements [Provenancer].
+func (s *File) Provenance(ctx context.Context, meterID, readingID string) ([]*genai.GasMeterReadResult, error) {
+	return s.mem.Provenance(ctx, meterID, readingID)
+}
+
+// Thin implements [Thinner]. The history is rewritten as by Prune.
+func (s *File) Thin(ctx context.Context, meterID string, from, to time.Time, keep func(rs []*genai.GasMeterReadResult) []bool) (int, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	thinned, gone := s.mem.thin(meterID, from, to, keep)
+	if len(gone) == 0 {
+		return 0, nil
+	}
+	events, compacted := s.mem.compactEvents(func(e Event) bool { return thinnedEvent(e, meterID, gone) })
+	// The thinned history is written from memory, and dropped if that fails.
+	old := s.mem.meters[meterID]
+	s.mem.meters[meterID] = thinned
+	err := s.rewrite(events, compacted, func(_ string, _ int, r genai.GasMeterReadResult) (genai.GasMeterReadResult, bool) {
+		return r, true
+	})
+	if err != nil {
+		s.mem.meters[meterID] = old
+		return 0, fmt.Errorf("thin store: %w", err)
+	}
+	s.mem.events, s.mem.compacted = events, compacted
+	return len(gone), nil
+}
+
+// rewrite writes the history, as changed by edit, to a temporary file which
+// then replaces the old one. events, the event log as it is to be with the
+// compaction mark compacted, are written first, on lines of their own. edit
+// is called with every reading and its index in the history of its meter;
+// it returns the reading to write and whether to keep it. The caller holds
+// the lock and updates the memory copy.
+func (s *File) rewrite(events []Event, compacted uint64, edit func(meterID string, i int, r genai.GasMeterReadResult) (genai.GasMeterReadResult, bool)) error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	ids := slices.Sorted(maps.Keys(s.mem.meters))
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	if compacted > 0 {
+		last := s.mem.last
+		if len(events) > 0 {
+			last = max(last, events[len(events)-1].Seq)
+		}
+		if err := enc.Encode(compactionLine{Compacted: compaction{Through: compacted, Seq: last}}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for _, e := range events {
+		if err := enc.Encode(eventLine{Logged: e}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for _, id := range ids {
+		for i, r := range s.mem.meters[id] {
+			r, keep := edit(id, i, r)
+			if !keep {
+				continue
+			}
+			if err := enc.Encode(record{MeterID: id, GasMeterReadResult: r}); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.f.Close()
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen store: %w", err)
+	}
+	s.f = f
+	return nil
+}
+
+// LoadState implements [StateStore].
+func (s *File) LoadState(ctx context.Context, meterID, key string, v any) error {
+	return s.mem.LoadState(ctx, meterID, key, v)
+}
+
+// SaveState implements [StateStore]. The state of all meters is written to
+// a temporary file which then replaces the old one.
+func (s *File) SaveState(ctx context.Context, meterID, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s state: %w", key, err)
+	}
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	prev, had := s.mem.state[meterID][key]
+	s.mem.setState(meterID, key, raw)
+	if err := writeFileAtomic(s.statePath(), s.mem.state); err != nil {
+		if had {
+			s.mem.state[meterID][key] = prev
+		} else {
+			delete(s.mem.state[meterID], key)
+		}
+		return fmt.Errorf("save store state: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes v as JSON to a temporary file and renames it to path.
+func writeFileAtomic(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Close implements [Store].
+func (s *File) Close() error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,151 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+	"github.com/suapapa/mqvision/internal/store/storetest"
+)
+
+func openFile(t *testing.T, dir string) store.Store {
+	s, err := store.OpenFile(filepath.Join(dir, "readings.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	return s
+}
+
+func TestFileConformance(t *testing.T) {
+	storetest.RunConformanceTests(t, openFile, storetest.FailingWrites(store.FailWrites))
+}
+
+func TestFileTruncatedLastLine(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	data := `{"meter_id":"home","read":"00001.000","date":"","read_at":"2025-11-07T05:00:00Z"}` + "\n" +
+		`{"meter_id":"home","read":"00002.0`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer s.Close()
+	got, err := s.Latest(context.Background(), "home")
+	if err != nil || got.Read != "00001.000" {
+		t.Fatalf("Latest = %v, %v", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte("not json\n"+data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenFile(path); err == nil {
+		t.Fatal("OpenFile with a corrupt middle line: want error")
+	}
+}
+
+func TestFileTruncatedEvent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	data := `{"logged":{"seq":1,"type":"gap","meter_id":"home","time":"2025-11-07T05:00:00Z"}}` + "\n" +
+		`{"meter_id":"home","read":"00002.000","date":"","read_at":"2025-11-07T06:00:00Z","event":{"seq":2,"type":"reading_acc`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	// The reading and event cut short by a crash were never read back: the
+	// number of the event is given to the next one.
+	e, err := s.LogEvent(ctx, store.Event{Type: store.EventGap, MeterID: "home"})
+	if err != nil || e.Seq != 2 {
+		t.Fatalf("LogEvent = %+v, %v; want seq 2", e, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = store.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	_, lerr := s.Latest(ctx, "home")
+	es, err := s.EventsSince(ctx, 0, 0)
+	s.Close()
+	if err != nil || len(es) != 2 || es[1].Seq != 2 {
+		t.Fatalf("EventsSince = %+v, %v", es, err)
+	}
+	if !errors.Is(lerr, store.ErrNotFound) {
+		t.Fatalf("Latest = %v, want the truncated reading dropped", lerr)
+	}
+
+	for _, bad := range []string{
+		`{"logged":{"seq":1}}` + "\n" + `{"logged":{"seq":1}}` + "\n",                                                // repeated
+		`{"logged":{"seq":1}}` + "\n" + `{"meter_id":"home","read":"1","event":{"seq":3}}` + "\n",                    // skipped
+		`{"compacted":{"through":3,"seq":5}}` + "\n" + `{"logged":{"seq":4}}` + "\n" + `{"logged":{"seq":6}}` + "\n", // skipped after the mark
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.OpenFile(path); err == nil {
+			t.Fatalf("OpenFile with events %q: want error", bad)
+		}
+	}
+}
+
+func TestFilePruneCompacts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "readings.jsonl")
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	at := time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+	for i := range 1000 {
+		r := &genai.GasMeterReadResult{Read: fmt.Sprintf("%05d.000", i), ReadAt: at.Add(time.Duration(i) * time.Minute), Model: "gpt-4o-mini"}
+		if err := s.Save(ctx, "home", r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := fileSize(t, path)
+	if n, err := s.Prune(ctx, at.Add(999*time.Minute)); err != nil || n != 999 {
+		t.Fatalf("Prune = %d, %v; want 999", n, err)
+	}
+	if size := fileSize(t, path); size > full/100 {
+		t.Fatalf("%d bytes after pruning 999 of 1000 readings, from %d", size, full)
+	}
+	s.Close()
+
+	if s, err = store.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer s.Close()
+	es, err := s.EventsSince(ctx, 0, 0)
+	if err != nil || len(es) != 1 || es[0].Seq != 1000 || es[0].Reading.Read != "00999.000" {
+		t.Fatalf("EventsSince = %+v, %v; want the event of the reading left", es, err)
+	}
+	if c, err := s.Compacted(ctx); err != nil || c != 999 {
+		t.Fatalf("Compacted = %d, %v; want 999", c, err)
+	}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
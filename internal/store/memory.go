@@ -0,0 +1,360 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Memory is a [Store] kept in process memory, for tests and short-lived runs.
+type Memory struct {
+	mu     sync.RWMutex
+	meters map[string][]genai.GasMeterReadResult // sorted by ReadAt
+	state  map[string]map[string]json.RawMessage // meter ID → key → state
+	events []Event                               // by Seq
+	// last is the Seq of the last event logged, compacted the highest of
+	// those compacted away; see [EventLog.Compacted].
+	last, compacted uint64
+}
+
+var (
+	_ Store       = (*Memory)(nil)
+	_ StateStore  = (*Memory)(nil)
+	_ Corrector   = (*Memory)(nil)
+	_ Thinner     = (*Memory)(nil)
+	_ Provenancer = (*Memory)(nil)
+	_ EventLog    = (*Memory)(nil)
+)
+
+// NewMemory returns an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		meters: make(map[string][]genai.GasMeterReadResult),
+		state:  make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// Save implements [Store].
+func (m *Memory) Save(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insert(meterID, cloneResult(r))
+	m.addEvent(m.nextEvent(EventReadingAccepted, meterID, r))
+	return nil
+}
+
+// insert keeps the meter's readings sorted, after any with the same ReadAt.
+func (m *Memory) insert(meterID string, r genai.GasMeterReadResult) {
+	rs := m.meters[meterID]
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].ReadAt.After(r.ReadAt) })
+	rs = append(rs, genai.GasMeterReadResult{})
+	copy(rs[i+1:], rs[i:])
+	rs[i] = r
+	m.meters[meterID] = rs
+}
+
+// Latest implements [Store].
+func (m *Memory) Latest(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rs := m.meters[meterID]
+	if len(rs) == 0 {
+		return nil, ErrNotFound
+	}
+	out := cloneResult(&rs[len(rs)-1])
+	return &out, nil
+}
+
+// ReadingsBetween implements [Store].
+func (m *Memory) ReadingsBetween(ctx context.Context, meterID string, from, to time.Time) ([]*genai.GasMeterReadResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rs := m.meters[meterID]
+	lo := sort.Search(len(rs), func(i int) bool { return !rs[i].ReadAt.Before(from) })
+	var out []*genai.GasMeterReadResult
+	for i := lo; i < len(rs) && rs[i].ReadAt.Before(to); i++ {
+		r := cloneResult(&rs[i])
+		out = append(out, &r)
+	}
+	return out, nil
+}
+
+// Prune implements [Store].
+func (m *Memory) Prune(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events, m.compacted = m.compactEvents(func(e Event) bool { return prunedEvent(e, before) })
+	return m.prune(before), nil
+}
+
+func (m *Memory) prune(before time.Time) int {
+	n := 0
+	for id, rs := range m.meters {
+		i := sort.Search(len(rs), func(i int) bool { return !rs[i].ReadAt.Before(before) })
+		n += i
+		if i == len(rs) {
+			delete(m.meters, id)
+			continue
+		}
+		m.meters[id] = append([]genai.GasMeterReadResult(nil), rs[i:]...)
+	}
+	return n
+}
+
+// CorrectReading implements [Corrector].
+func (m *Memory) CorrectReading(ctx context.Context, meterID, readingID, newValue, note string) (*genai.GasMeterReadResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(meterID, readingID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	r := &m.meters[meterID][i]
+	r.Correct(meterID, newValue, note, time.Now())
+	m.addEvent(m.nextEvent(EventCorrection, meterID, r))
+	out := cloneResult(r)
+	return &out, nil
+}
+
+// Provenance implements [Provenancer]. A reference met twice ends the
+// chain.
+func (m *Memory) Provenance(ctx context.Context, meterID, readingID string) ([]*genai.GasMeterReadResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var chain []*genai.GasMeterReadResult
+	seen := map[string]bool{}
+	for id := readingID; id != "" && !seen[id]; {
+		seen[id] = true
+		i := m.find(meterID, id)
+		if i < 0 {
+			break
+		}
+		r := cloneResult(&m.meters[meterID][i])
+		chain = append(chain, &r)
+		id = r.ReferenceID
+	}
+	if len(chain) == 0 {
+		return nil, ErrNotFound
+	}
+	return chain, nil
+}
+
+// Thin implements [Thinner].
+func (m *Memory) Thin(ctx context.Context, meterID string, from, to time.Time, keep func(rs []*genai.GasMeterReadResult) []bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	thinned, gone := m.thin(meterID, from, to, keep)
+	if len(gone) > 0 {
+		m.meters[meterID] = thinned
+		m.events, m.compacted = m.compactEvents(func(e Event) bool { return thinnedEvent(e, meterID, gone) })
+	}
+	return len(gone), nil
+}
+
+// thin returns the history of meterID thinned out by keep, as by Thin, and
+// the [readingKey]s of the readings it leaves out, without changing the
+// history.
+func (m *Memory) thin(meterID string, from, to time.Time, keep func(rs []*genai.GasMeterReadResult) []bool) ([]genai.GasMeterReadResult, map[string]bool) {
+	rs := m.meters[meterID]
+	lo := sort.Search(len(rs), func(i int) bool { return !rs[i].ReadAt.Before(from) })
+	hi := sort.Search(len(rs), func(i int) bool { return !rs[i].ReadAt.Before(to) })
+	if lo >= hi {
+		return rs, nil
+	}
+	in := make([]*genai.GasMeterReadResult, hi-lo)
+	for i := range in {
+		r := cloneResult(&rs[lo+i])
+		in[i] = &r
+	}
+	kept := keep(in)
+	out := append([]genai.GasMeterReadResult(nil), rs[:lo]...)
+	gone := map[string]bool{}
+	for i, r := range in {
+		if i < len(kept) && kept[i] {
+			out = append(out, *r)
+		} else {
+			gone[readingKey(meterID, &rs[lo+i])] = true
+		}
+	}
+	return append(out, rs[hi:]...), gone
+}
+
+// find returns the index of the reading of meterID with ID readingID, or -1.
+func (m *Memory) find(meterID, readingID string) int {
+	for i := range m.meters[meterID] {
+		if readingKey(meterID, &m.meters[meterID][i]) == readingID {
+			return i
+		}
+	}
+	return -1
+}
+
+// readingKey returns the ID of r, a reading of meterID, or its
+// [genai.ReadingID] if it was saved without one.
+func readingKey(meterID string, r *genai.GasMeterReadResult) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return genai.ReadingID(meterID, r)
+}
+
+// LogEvent implements [EventLog].
+func (m *Memory) LogEvent(ctx context.Context, e Event) (Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.Seq = m.last + 1
+	m.addEvent(e)
+	return cloneEvent(e), nil
+}
+
+// Compacted implements [EventLog].
+func (m *Memory) Compacted(ctx context.Context) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.compacted, nil
+}
+
+// EventsSince implements [EventLog].
+func (m *Memory) EventsSince(ctx context.Context, seq uint64, limit int) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i := sort.Search(len(m.events), func(i int) bool { return m.events[i].Seq > seq })
+	var out []Event
+	for ; i < len(m.events) && (limit <= 0 || len(out) < limit); i++ {
+		out = append(out, cloneEvent(m.events[i]))
+	}
+	return out, nil
+}
+
+// nextEvent returns the next event, of type typ about r, a reading of
+// meterID.
+func (m *Memory) nextEvent(typ, meterID string, r *genai.GasMeterReadResult) Event {
+	out := cloneResult(r)
+	return Event{Seq: m.last + 1, Type: typ, MeterID: meterID, Time: time.Now(), Reading: &out}
+}
+
+// addEvent logs e, the next event, keeping a copy.
+func (m *Memory) addEvent(e Event) {
+	m.events = append(m.events, cloneEvent(e))
+	m.last = max(m.last, e.Seq)
+}
+
+// compactEvents returns the events left when those drop reports true for
+// are compacted away, and the compaction mark then, without changing the
+// log.
+func (m *Memory) compactEvents(drop func(e Event) bool) ([]Event, uint64) {
+	var kept []Event
+	compacted := m.compacted
+	for _, e := range m.events {
+		if drop(e) {
+			compacted = max(compacted, e.Seq)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept, compacted
+}
+
+// prunedEvent reports whether e goes with the readings pruned before
+// before: it is about one of them, or about no reading and happened
+// before.
+func prunedEvent(e Event, before time.Time) bool {
+	if e.Reading != nil {
+		return e.Reading.ReadAt.Before(before)
+	}
+	return e.Time.Before(before)
+}
+
+// thinnedEvent reports whether e is about one of the readings of meterID
+// thinned out, by their [readingKey]s.
+func thinnedEvent(e Event, meterID string, gone map[string]bool) bool {
+	return e.MeterID == meterID && e.Reading != nil && gone[readingKey(meterID, e.Reading)]
+}
+
+// LoadState implements [StateStore].
+func (m *Memory) LoadState(ctx context.Context, meterID, key string, v any) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.loadState(meterID, key, v)
+}
+
+func (m *Memory) loadState(meterID, key string, v any) error {
+	raw, ok := m.state[meterID][key]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("decode %s state: %w", key, err)
+	}
+	return nil
+}
+
+// SaveState implements [StateStore].
+func (m *Memory) SaveState(ctx context.Context, meterID, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode %s state: %w", key, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setState(meterID, key, raw)
+	return nil
+}
+
+func (m *Memory) setState(meterID, key string, raw json.RawMessage) {
+	if m.state[meterID] == nil {
+		m.state[meterID] = make(map[string]json.RawMessage)
+	}
+	m.state[meterID][key] = raw
+}
+
+// Close implements [Store].
+func (m *Memory) Close() error {
+	return nil
+}
+
+// cloneEvent copies e, including its reading.
+func cloneEvent(e Event) Event {
+	if e.Reading != nil {
+		r := cloneResult(e.Reading)
+		e.Reading = &r
+	}
+	e.Data = append(json.RawMessage(nil), e.Data...)
+	return e
+}
+
+// cloneResult copies r, including its slices.
+func cloneResult(r *genai.GasMeterReadResult) genai.GasMeterReadResult {
+	out := *r
+	out.Dials = append([]genai.DialReading(nil), r.Dials...)
+	out.Answers = append([]genai.ModelAnswer(nil), r.Answers...)
+	out.AmbiguousPositions = append([]int(nil), r.AmbiguousPositions...)
+	out.Warnings = append([]string(nil), r.Warnings...)
+	if r.Timing != nil {
+		t := *r.Timing
+		t.TimedOut = append([]string(nil), r.Timing.TimedOut...)
+		out.Timing = &t
+	}
+	if r.Issue != nil {
+		i := *r.Issue
+		out.Issue = &i
+	}
+	if r.CounterBox != nil {
+		b := *r.CounterBox
+		out.CounterBox = &b
+	}
+	if r.ROI != nil {
+		b := *r.ROI
+		out.ROI = &b
+	}
+	if r.Correction != nil {
+		c := *r.Correction
+		out.Correction = &c
+	}
+	return out
+}
@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/store"
+	"github.com/suapapa/mqvision/internal/store/storetest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.RunConformanceTests(t, func(t *testing.T, dir string) store.Store {
+		return store.NewMemory()
+	}, storetest.Ephemeral())
+}
@@ -0,0 +1,124 @@
+// Package store keeps the history of accepted readings per meter.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// ErrNotFound is returned when a meter has no stored reading.
+var ErrNotFound = errors.New("store: no reading")
+
+// Store is the reading history. Readings are ordered by ReadAt; readings with
+// the same ReadAt keep the order they were saved in. Implementations must be
+// safe for concurrent use and must not retain or hand out the caller's
+// *GasMeterReadResult: Save stores a copy and queries return copies.
+// [storetest.RunConformanceTests] checks these rules for every backend.
+type Store interface {
+	// Save appends r to the history of meterID.
+	Save(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error
+	// Latest returns the reading of meterID with the latest ReadAt, or [ErrNotFound].
+	Latest(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error)
+	// ReadingsBetween returns the readings of meterID with from <= ReadAt < to, oldest first.
+	ReadingsBetween(ctx context.Context, meterID string, from, to time.Time) ([]*genai.GasMeterReadResult, error)
+	// Prune deletes the readings of all meters with ReadAt before before and
+	// returns how many were deleted.
+	Prune(ctx context.Context, before time.Time) (int, error)
+	Close() error
+}
+
+// StateStore is implemented by stores that also keep small per-meter state
+// besides the readings, such as the learned counter region of the roi
+// package. State is stored as JSON under a key.
+type StateStore interface {
+	// LoadState decodes the state key of meterID into v, or returns [ErrNotFound].
+	LoadState(ctx context.Context, meterID, key string, v any) error
+	// SaveState replaces the state key of meterID with v.
+	SaveState(ctx context.Context, meterID, key string, v any) error
+}
+
+// Corrector is implemented by stores whose readings can be corrected by hand.
+type Corrector interface {
+	// CorrectReading sets the reading of meterID with ID readingID to
+	// newValue, keeping the value as read in its [genai.Correction] along with
+	// note, and returns the corrected reading, or [ErrNotFound]. newValue is
+	// not checked against the meter's pattern. Readings saved without an ID
+	// are found by their [genai.ReadingID].
+	CorrectReading(ctx context.Context, meterID, readingID, newValue, note string) (*genai.GasMeterReadResult, error)
+}
+
+// Provenancer is implemented by stores that can walk the references of a
+// reading back; see [genai.GasMeterReadResult.ReferenceID].
+type Provenancer interface {
+	// Provenance returns the reading of meterID with ID readingID, or
+	// [ErrNotFound], followed by its reference, the reference's reference
+	// and so on, as far as they are stored. Readings saved without an ID are
+	// found by their [genai.ReadingID].
+	Provenance(ctx context.Context, meterID, readingID string) ([]*genai.GasMeterReadResult, error)
+}
+
+// Thinner is implemented by stores whose history can be thinned out in
+// place, as by package downsample.
+type Thinner interface {
+	// Thin calls keep with the readings of meterID with from <= ReadAt < to,
+	// oldest first, and deletes those it reports false for. Changes keep
+	// makes to the readings kept are stored. It returns how many were
+	// deleted; if none were, nothing is stored.
+	Thin(ctx context.Context, meterID string, from, to time.Time, keep func(rs []*genai.GasMeterReadResult) []bool) (int, error)
+}
+
+// Types of the [Event]s of an [EventLog]; those of package event where it
+// has them.
+const (
+	EventReadingAccepted = "reading_accepted" // Save
+	EventCorrection      = "correction"       // CorrectReading
+	EventGap             = "gap"              // a reading came long after the previous one
+	EventMaintenance     = "maintenance"      // a maintenance began or ended
+)
+
+// Event is an entry of an [EventLog]: something that happened to the
+// history of a meter, numbered in the order it was logged.
+type Event struct {
+	// Seq is the sequence number of the event: 1 for the first one logged,
+	// and one more for every next one.
+	Seq     uint64    `json:"seq"`
+	Type    string    `json:"type"`
+	MeterID string    `json:"meter_id"`
+	Time    time.Time `json:"time"`
+	// Reading is the reading accepted or corrected, or the one after a gap.
+	Reading *genai.GasMeterReadResult `json:"reading,omitempty"`
+	// Data is what else there is to the event, as JSON, such as the
+	// maintenance begun or ended.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// EventLog is implemented by stores that number what happens to their
+// history, for consumers syncing it by sequence number rather than time.
+// Their Save and CorrectReading log an event of their own, kept if and
+// only if the reading or correction is. Sequence numbers are never reused:
+// a crash or a restart neither repeats nor skips one. The log is compacted
+// along with the history: [Store.Prune] deletes the events of the readings
+// it deletes and those without a reading that happened before its time,
+// and [Thinner.Thin] the events of the readings it deletes.
+type EventLog interface {
+	// LogEvent logs e with the next sequence number and returns it so.
+	LogEvent(ctx context.Context, e Event) (Event, error)
+	// EventsSince returns the events with a Seq after seq, oldest first and
+	// at most limit of them; limit <= 0 is no limit.
+	EventsSince(ctx context.Context, seq uint64, limit int) ([]Event, error)
+	// Compacted returns the highest Seq of the events compacted away, 0 if
+	// none were: a consumer that synced up to an earlier one may have
+	// missed events.
+	Compacted(ctx context.Context) (uint64, error)
+}
+
+// record is a reading with the meter it belongs to; it is also the JSONL
+// line format of [File].
+type record struct {
+	MeterID string `json:"meter_id"`
+	genai.GasMeterReadResult
+}
@@ -0,0 +1,730 @@
+// Package storetest is a conformance suite for [store.Store] implementations.
+// A backend's tests only need to call [RunConformanceTests].
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Opener opens the store persisted under dir, a fresh temporary directory
+// per subtest. Opening the same dir again must see the earlier data, unless
+// the store is [Ephemeral].
+type Opener func(t *testing.T, dir string) store.Store
+
+type config struct {
+	ephemeral  bool
+	failWrites func(s store.Store) (restore func())
+}
+
+// Option configures [RunConformanceTests].
+type Option func(*config)
+
+// Ephemeral marks stores that lose their data on Close; the reopen test is skipped.
+func Ephemeral() Option {
+	return func(c *config) {
+		c.ephemeral = true
+	}
+}
+
+// FailingWrites gives fail, which makes the writes of s, a store of the
+// backend, fail until restore is called, for the check that a failed write
+// keeps neither a reading nor its event. Without it, the check is skipped.
+func FailingWrites(fail func(s store.Store) (restore func())) Option {
+	return func(c *config) {
+		c.failWrites = fail
+	}
+}
+
+var base = time.Date(2025, 11, 7, 5, 0, 0, 0, time.UTC)
+
+// at returns a reading taken h hours after base.
+func at(read string, h int) *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{Read: read, ReadAt: base.Add(time.Duration(h) * time.Hour)}
+}
+
+// fullResult sets every field of GasMeterReadResult.
+func fullResult() *genai.GasMeterReadResult {
+	return &genai.GasMeterReadResult{
+		ID:                 "5d0c8e1f2a3b4c5d6e7f8091a2b3c4d5",
+		Read:               "02924.457",
+		RawRead:            "02924,457 m³",
+		Utility:            genai.UtilityGas,
+		Date:               "2025-11-07T15:13:17+09:00",
+		DateParsed:         time.Date(2025, 11, 7, 15, 13, 17, 0, time.FixedZone("KST", 9*60*60)),
+		ReadAt:             time.Date(2025, 11, 7, 15, 13, 17, 123456789, time.FixedZone("KST", 9*60*60)), // base+1h13m
+		ItTakes:            "2.5s",
+		Timing:             &genai.Timing{Read: "1.5s", Guess: "1s", TimedOut: []string{genai.PhaseGenerate}, OutputTokens: 120},
+		Ambiguous:          true,
+		AmbiguousPositions: []int{4},
+		Dials:              []genai.DialReading{{Value: 2.9, Direction: "cw"}, {Value: 9.1, Direction: "ccw"}},
+		Answers:            []genai.ModelAnswer{{Model: "a", Read: "02924.457"}, {Model: "b", Error: "timeout"}},
+		Issue:              &genai.Issue{Kind: genai.IssueGlare, Note: "sun on the glass"},
+		SerialNumber:       "GM2019-4??13",
+		CounterBox:         &genai.Box{XMin: 0.2, YMin: 0.4, XMax: 0.8, YMax: 0.6},
+		ROI:                &genai.Box{XMin: 0.1, YMin: 0.3, XMax: 0.9, YMax: 0.7},
+		Correction:         &genai.Correction{Original: "02924.451", Note: "checked", At: time.Date(2025, 11, 8, 9, 0, 0, 0, time.UTC)},
+		Warnings:           []string{"monotonic: decrease from 02924.500"},
+		UploadedFile:       "gas-meter/home/20251107T061317.123Z",
+		Model:              "gpt-4o-mini",
+		PromptHash:         "3f9a0c1b2d4e",
+		ReaderVersion:      "v1.0.0",
+		Provenance:         genai.ProvenanceGuessed,
+		ReferenceID:        "4c5d6e7f8091a2b3c4d55d0c8e1f2a3b",
+		Route:              "official",
+		Tags:               []string{"daily"},
+	}
+}
+
+// RunConformanceTests checks that the stores returned by open follow the
+// [store.Store] contract.
+func RunConformanceTests(t *testing.T, open Opener, opts ...Option) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ctx := context.Background()
+
+	newStore := func(t *testing.T) store.Store {
+		s := open(t, t.TempDir())
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.Latest(ctx, "home"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Latest on empty store: err = %v, want ErrNotFound", err)
+		}
+		rs, err := s.ReadingsBetween(ctx, "home", base.Add(-time.Hour), base.Add(time.Hour))
+		if err != nil || len(rs) != 0 {
+			t.Fatalf("ReadingsBetween on empty store = %v, %v", rs, err)
+		}
+		if n, err := s.Prune(ctx, base); err != nil || n != 0 {
+			t.Fatalf("Prune on empty store = %d, %v", n, err)
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		want := fullResult()
+		in := fullResult()
+		if err := s.Save(ctx, "home", in); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		// The store must hold a copy.
+		in.Read, in.Dials[0].Value, in.Answers[0].Read = "mutated", 0, "mutated"
+		in.AmbiguousPositions[0], in.Timing.Read, in.Issue.Note = 0, "mutated", "mutated"
+		in.CounterBox.XMin, in.ROI.XMin, in.Correction.Original, in.Warnings[0] = 0, 0, "mutated", "mutated"
+		in.Timing.TimedOut[0] = "mutated"
+
+		got, err := s.Latest(ctx, "home")
+		if err != nil {
+			t.Fatalf("Latest: %v", err)
+		}
+		checkEqual(t, got, want)
+		got.Read = "mutated"
+
+		rs, err := s.ReadingsBetween(ctx, "home", want.ReadAt, want.ReadAt.Add(time.Nanosecond))
+		if err != nil || len(rs) != 1 {
+			t.Fatalf("ReadingsBetween = %v, %v; want the saved reading", rs, err)
+		}
+		checkEqual(t, rs[0], want)
+	})
+
+	t.Run("MeterIsolation", func(t *testing.T) {
+		s := newStore(t)
+		mustSave(t, s, "home", at("00001.000", 0))
+		mustSave(t, s, "cabin", at("00500.000", 1))
+
+		if got, err := s.Latest(ctx, "home"); err != nil || got.Read != "00001.000" {
+			t.Fatalf("Latest(home) = %v, %v", got, err)
+		}
+		if rs, _ := s.ReadingsBetween(ctx, "home", base, base.Add(24*time.Hour)); len(rs) != 1 {
+			t.Fatalf("ReadingsBetween(home) = %d readings, want 1", len(rs))
+		}
+		if _, err := s.Latest(ctx, "garage"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Latest(garage): err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		s := newStore(t)
+		for _, r := range []*genai.GasMeterReadResult{
+			at("00003.000", 3), at("00001.000", 1), at("00004.000", 4),
+			at("00002.000", 2), at("00002.500", 2), at("00000.000", 0),
+		} {
+			mustSave(t, s, "home", r)
+		}
+
+		// [1h, 4h): 4h is excluded; equal ReadAt keeps save order.
+		rs, err := s.ReadingsBetween(ctx, "home", base.Add(time.Hour), base.Add(4*time.Hour))
+		if err != nil {
+			t.Fatalf("ReadingsBetween: %v", err)
+		}
+		checkReads(t, rs, "00001.000", "00002.000", "00002.500", "00003.000")
+
+		if got, err := s.Latest(ctx, "home"); err != nil || got.Read != "00004.000" {
+			t.Fatalf("Latest = %v, %v; want the reading with the latest ReadAt", got, err)
+		}
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		s := newStore(t)
+		for h := 0; h < 5; h++ {
+			mustSave(t, s, "home", at(fmt.Sprintf("0000%d.000", h), h))
+		}
+		mustSave(t, s, "cabin", at("00100.000", 0))
+
+		n, err := s.Prune(ctx, base.Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("Prune: %v", err)
+		}
+		if n != 3 {
+			t.Fatalf("Prune = %d, want 3 (0h and 1h of home, 0h of cabin)", n)
+		}
+		rs, _ := s.ReadingsBetween(ctx, "home", base.Add(-time.Hour), base.Add(24*time.Hour))
+		checkReads(t, rs, "00002.000", "00003.000", "00004.000")
+		if _, err := s.Latest(ctx, "cabin"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Latest(cabin) after Prune: err = %v, want ErrNotFound", err)
+		}
+		if n, _ := s.Prune(ctx, base.Add(2*time.Hour)); n != 0 {
+			t.Fatalf("second Prune = %d, want 0", n)
+		}
+	})
+
+	t.Run("ConcurrentWriters", func(t *testing.T) {
+		s := newStore(t)
+		const writers, each = 8, 25
+		var wg sync.WaitGroup
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range each {
+					r := at(fmt.Sprintf("%05d.%03d", w, i), w*each+i)
+					if err := s.Save(ctx, "home", r); err != nil {
+						t.Errorf("Save: %v", err)
+						return
+					}
+					s.Latest(ctx, "home")
+				}
+			}()
+		}
+		wg.Wait()
+
+		rs, err := s.ReadingsBetween(ctx, "home", base, base.Add(writers*each*time.Hour))
+		if err != nil || len(rs) != writers*each {
+			t.Fatalf("ReadingsBetween = %d readings, %v; want %d", len(rs), err, writers*each)
+		}
+		for i := 1; i < len(rs); i++ {
+			if rs[i].ReadAt.Before(rs[i-1].ReadAt) {
+				t.Fatalf("readings out of order at %d", i)
+			}
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		if cfg.ephemeral {
+			t.Skip("ephemeral store")
+		}
+		dir := t.TempDir()
+		s := open(t, dir)
+		mustSave(t, s, "home", at("00001.000", 1))
+		mustSave(t, s, "home", at("00000.000", 0))
+		mustSave(t, s, "home", fullResult())
+		mustSave(t, s, "cabin", at("00100.000", 2))
+		if _, err := s.Prune(ctx, base.Add(time.Hour)); err != nil {
+			t.Fatalf("Prune: %v", err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		s = open(t, dir)
+		defer s.Close()
+		got, err := s.Latest(ctx, "home")
+		if err != nil {
+			t.Fatalf("Latest after reopen: %v", err)
+		}
+		checkEqual(t, got, fullResult())
+		rs, _ := s.ReadingsBetween(ctx, "home", base.Add(-time.Hour), base.Add(24*time.Hour))
+		checkReads(t, rs, "00001.000", "02924.457")
+		checkEqual(t, rs[0], at("00001.000", 1))
+
+		mustSave(t, s, "cabin", at("00101.000", 3))
+		if rs, _ := s.ReadingsBetween(ctx, "cabin", base, base.Add(24*time.Hour)); len(rs) != 2 {
+			t.Fatalf("cabin after reopen = %d readings, want 2", len(rs))
+		}
+	})
+
+	t.Run("Correct", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(t, dir)
+		c, ok := s.(store.Corrector)
+		if !ok {
+			s.Close()
+			t.Skip("no corrections")
+		}
+		first, second := at("00010.000", 1), at("00011.000", 2)
+		second.ID = "second"
+		mustSave(t, s, "home", first)
+		mustSave(t, s, "home", second)
+		mustSave(t, s, "cabin", at("00010.000", 1))
+		if _, err := c.CorrectReading(ctx, "home", "missing", "00010.100", ""); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("CorrectReading of an unknown ID: err = %v, want ErrNotFound", err)
+		}
+
+		// A reading saved without an ID is found by its ReadingID, which it
+		// keeps once corrected.
+		id := genai.ReadingID("home", first)
+		got, err := c.CorrectReading(ctx, "home", id, "00010.100", "one digit off")
+		if err != nil {
+			t.Fatalf("CorrectReading: %v", err)
+		}
+		if got.Read != "00010.100" || got.ID != id || got.Correction == nil || got.Correction.Original != "00010.000" || got.Correction.Note != "one digit off" || got.Correction.At.IsZero() {
+			t.Fatalf("corrected = %+v, %+v", got, got.Correction)
+		}
+		got.Correction.Original = "mutated"
+		// Correcting again keeps the value as read, and records the one
+		// replaced.
+		if got, err = c.CorrectReading(ctx, "home", id, "00010.200", "again"); err != nil || got.Correction.Original != "00010.000" || got.Correction.Previous != "00010.100" {
+			t.Fatalf("second CorrectReading = %+v, %v", got, err)
+		}
+		if _, err := c.CorrectReading(ctx, "home", "second", "00011.100", ""); err != nil {
+			t.Fatalf("CorrectReading by ID: %v", err)
+		}
+		check := func(s store.Store) {
+			t.Helper()
+			rs, _ := s.ReadingsBetween(ctx, "home", base, base.Add(24*time.Hour))
+			checkReads(t, rs, "00010.200", "00011.100")
+			if rs[0].Correction == nil || rs[0].Correction.Original != "00010.000" {
+				t.Fatalf("stored correction = %+v", rs[0].Correction)
+			}
+			if l, _ := s.Latest(ctx, "cabin"); l.Read != "00010.000" || l.Correction != nil {
+				t.Fatalf("other meter = %+v, want it untouched", l)
+			}
+		}
+		check(s)
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+		mustSave(t, s, "home", at("00012.000", 3)) // still appends after the rewrite
+		if l, _ := s.Latest(ctx, "home"); l.Read != "00012.000" {
+			t.Fatalf("Latest = %s after saving", l.Read)
+		}
+	})
+
+	t.Run("Provenance", func(t *testing.T) {
+		s := newStore(t)
+		p, ok := s.(store.Provenancer)
+		if !ok {
+			t.Skip("no provenance")
+		}
+		// first is saved without an ID and found by its ReadingID.
+		first, second, third, loop := at("00010.000", 1), at("00010.100", 2), at("00010.200", 3), at("00010.300", 4)
+		first.Provenance = genai.ProvenanceConfident
+		second.ID, second.Provenance, second.ReferenceID = "second", genai.ProvenanceGuessed, genai.ReadingID("home", first)
+		third.ID, third.Provenance, third.ReferenceID = "third", genai.ProvenanceGuessed, "second"
+		loop.ID, loop.ReferenceID = "loop", "loop"
+		for _, r := range []*genai.GasMeterReadResult{first, second, third, loop} {
+			mustSave(t, s, "home", r)
+		}
+		mustSave(t, s, "cabin", &genai.GasMeterReadResult{ID: "third", Read: "00001.000", ReadAt: base})
+		chain, err := p.Provenance(ctx, "home", "third")
+		if err != nil {
+			t.Fatalf("Provenance: %v", err)
+		}
+		checkReads(t, chain, "00010.200", "00010.100", "00010.000")
+		if chain[2].Provenance != genai.ProvenanceConfident {
+			t.Fatalf("chain ends with %+v", chain[2])
+		}
+		if chain, err = p.Provenance(ctx, "home", "loop"); err != nil || len(chain) != 1 {
+			t.Fatalf("Provenance of a reading referring to itself = %v, %v", chain, err)
+		}
+		if _, err := p.Provenance(ctx, "home", "missing"); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Provenance of an unknown ID: err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Thin", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(t, dir)
+		th, ok := s.(store.Thinner)
+		if !ok {
+			s.Close()
+			t.Skip("no thinning")
+		}
+		for i, read := range []string{"00010.000", "00010.100", "00010.200", "00010.300", "00010.400"} {
+			mustSave(t, s, "home", at(read, i))
+		}
+		mustSave(t, s, "cabin", at("00020.000", 1))
+		// Every other reading of hours 1 to 3 is kept, the first marked.
+		n, err := th.Thin(ctx, "home", base.Add(time.Hour), base.Add(4*time.Hour), func(rs []*genai.GasMeterReadResult) []bool {
+			checkReads(t, rs, "00010.100", "00010.200", "00010.300")
+			rs[0].Downsampled = "2h0m0s"
+			return []bool{true, false, true}
+		})
+		if err != nil || n != 1 {
+			t.Fatalf("Thin = %d, %v; want 1 deleted", n, err)
+		}
+		// Keeping everything changes nothing.
+		if n, err := th.Thin(ctx, "home", base, base.Add(24*time.Hour), func(rs []*genai.GasMeterReadResult) []bool {
+			rs[0].Downsampled = "mutated"
+			return []bool{true, true, true, true}
+		}); err != nil || n != 0 {
+			t.Fatalf("Thin keeping all = %d, %v", n, err)
+		}
+		check := func(s store.Store) {
+			t.Helper()
+			rs, _ := s.ReadingsBetween(ctx, "home", base, base.Add(24*time.Hour))
+			checkReads(t, rs, "00010.000", "00010.100", "00010.300", "00010.400")
+			if rs[0].Downsampled != "" || rs[1].Downsampled != "2h0m0s" {
+				t.Fatalf("Downsampled = %q, %q; want the change of the kept reading only", rs[0].Downsampled, rs[1].Downsampled)
+			}
+			if l, _ := s.Latest(ctx, "cabin"); l == nil || l.Read != "00020.000" {
+				t.Fatalf("other meter = %+v, want it untouched", l)
+			}
+		}
+		check(s)
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+	})
+
+	t.Run("State", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(t, dir)
+		ss, ok := s.(store.StateStore)
+		if !ok {
+			s.Close()
+			t.Skip("no state")
+		}
+		type state struct{ N int }
+		var got state
+		if err := ss.LoadState(ctx, "home", "roi", &got); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("LoadState of nothing: err = %v, want ErrNotFound", err)
+		}
+		for _, n := range []int{1, 2} {
+			if err := ss.SaveState(ctx, "home", "roi", state{n}); err != nil {
+				t.Fatalf("SaveState: %v", err)
+			}
+		}
+		if err := ss.SaveState(ctx, "cabin", "roi", state{3}); err != nil {
+			t.Fatalf("SaveState: %v", err)
+		}
+		if err := ss.LoadState(ctx, "home", "roi", &got); err != nil || got.N != 2 {
+			t.Fatalf("LoadState = %+v, %v; want the last save", got, err)
+		}
+		if err := ss.LoadState(ctx, "home", "other", &got); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("LoadState of another key: err = %v, want ErrNotFound", err)
+		}
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		if err := s.(store.StateStore).LoadState(ctx, "cabin", "roi", &got); err != nil || got.N != 3 {
+			t.Fatalf("LoadState after reopen = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("EventLog", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(t, dir)
+		if _, ok := s.(store.EventLog); !ok {
+			s.Close()
+			t.Skip("no event log")
+		}
+		if es, err := s.(store.EventLog).EventsSince(ctx, 0, 0); err != nil || len(es) != 0 {
+			t.Fatalf("EventsSince on empty store = %v, %v", es, err)
+		}
+		want := fullResult()
+		mustSave(t, s, "home", fullResult())
+		mustSave(t, s, "cabin", at("00020.000", 0))
+		types := []string{store.EventReadingAccepted, store.EventReadingAccepted}
+		if c, ok := s.(store.Corrector); ok {
+			if _, err := c.CorrectReading(ctx, "cabin", genai.ReadingID("cabin", at("00020.000", 0)), "00021.000", ""); err != nil {
+				t.Fatalf("CorrectReading: %v", err)
+			}
+			types = append(types, store.EventCorrection)
+		}
+		data := []byte(`{"gap":"3h0m0s"}`)
+		e, err := s.(store.EventLog).LogEvent(ctx, store.Event{Type: store.EventGap, MeterID: "home", Time: base, Data: data})
+		if err != nil || e.Seq != uint64(len(types)+1) {
+			t.Fatalf("LogEvent = %+v, %v; want seq %d", e, err, len(types)+1)
+		}
+		types = append(types, store.EventGap)
+
+		// Concurrent events are numbered one after another.
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.(store.EventLog).LogEvent(ctx, store.Event{Type: store.EventMaintenance, MeterID: "home", Time: base}); err != nil {
+					t.Errorf("LogEvent: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		for range 10 {
+			types = append(types, store.EventMaintenance)
+		}
+
+		check := func(s store.Store) {
+			t.Helper()
+			el := s.(store.EventLog)
+			es, err := el.EventsSince(ctx, 0, 0)
+			if err != nil || len(es) != len(types) {
+				t.Fatalf("EventsSince(0) = %d events, %v; want %d", len(es), err, len(types))
+			}
+			for i, e := range es {
+				if e.Seq != uint64(i+1) || e.Type != types[i] {
+					t.Fatalf("event %d = seq %d %s, want seq %d %s", i, e.Seq, e.Type, i+1, types[i])
+				}
+			}
+			if es[0].MeterID != "home" || es[0].Reading == nil || es[0].Time.IsZero() {
+				t.Fatalf("first event = %+v, want the reading of home", es[0])
+			}
+			checkEqual(t, es[0].Reading, want)
+			if g := es[len(types)-11]; string(g.Data) != string(data) || g.Reading != nil {
+				t.Fatalf("gap event = %+v, want its data", g)
+			}
+			es[0].Reading.Read = "mutated"
+			if again, _ := el.EventsSince(ctx, 0, 1); len(again) != 1 || again[0].Reading.Read != want.Read {
+				t.Fatalf("EventsSince(0, 1) = %+v, want one event with the stored reading", again)
+			}
+			if es, err := el.EventsSince(ctx, 2, 1); err != nil || len(es) != 1 || es[0].Seq != 3 {
+				t.Fatalf("EventsSince(2, 1) = %+v, %v; want the event 3", es, err)
+			}
+			if es, err := el.EventsSince(ctx, uint64(len(types)), 10); err != nil || len(es) != 0 {
+				t.Fatalf("EventsSince(last) = %+v, %v; want none", es, err)
+			}
+		}
+		check(s)
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+		// Numbering goes on after a restart.
+		mustSave(t, s, "home", at("00030.000", 0))
+		if es, _ := s.(store.EventLog).EventsSince(ctx, uint64(len(types)), 0); len(es) != 1 || es[0].Seq != uint64(len(types)+1) {
+			t.Fatalf("events after reopen = %+v, want seq %d", es, len(types)+1)
+		}
+	})
+
+	t.Run("EventLogCompaction", func(t *testing.T) {
+		dir := t.TempDir()
+		s := open(t, dir)
+		el, ok := s.(store.EventLog)
+		if !ok {
+			s.Close()
+			t.Skip("no event log")
+		}
+		for h := range 4 {
+			mustSave(t, s, "home", at(fmt.Sprintf("%05d.000", h), h)) // seq 1 to 4
+		}
+		mustSave(t, s, "cabin", at("00010.000", 0)) // 5
+		for _, h := range []int{0, 3} {             // 6, 7
+			if _, err := el.LogEvent(ctx, store.Event{Type: store.EventMaintenance, MeterID: "home", Time: base.Add(time.Duration(h) * time.Hour)}); err != nil {
+				t.Fatalf("LogEvent: %v", err)
+			}
+		}
+		if c, err := el.Compacted(ctx); err != nil || c != 0 {
+			t.Fatalf("Compacted before pruning = %d, %v; want 0", c, err)
+		}
+
+		// The events of the readings pruned go with them, as does the
+		// maintenance logged before.
+		if n, err := s.Prune(ctx, base.Add(time.Hour)); err != nil || n != 2 {
+			t.Fatalf("Prune = %d, %v; want 2", n, err)
+		}
+		want := []uint64{2, 3, 4, 7}
+		compacted := uint64(6)
+		if th, ok := s.(store.Thinner); ok {
+			// Thinning out home's reading at base+2h drops its event.
+			_, err := th.Thin(ctx, "home", base, base.Add(3*time.Hour), func(rs []*genai.GasMeterReadResult) []bool {
+				return []bool{true, false}
+			})
+			if err != nil {
+				t.Fatalf("Thin: %v", err)
+			}
+			want = []uint64{2, 4, 7}
+		}
+		check := func(s store.Store) {
+			t.Helper()
+			el := s.(store.EventLog)
+			es, err := el.EventsSince(ctx, 0, 0)
+			if err != nil {
+				t.Fatalf("EventsSince: %v", err)
+			}
+			var got []uint64
+			for _, e := range es {
+				got = append(got, e.Seq)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("events %v, want %v", got, want)
+			}
+			if c, err := el.Compacted(ctx); err != nil || c != compacted {
+				t.Fatalf("Compacted = %d, %v; want %d", c, err, compacted)
+			}
+		}
+		check(s)
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+		// Numbering goes on after the last event, compacted or not.
+		if _, err := s.Prune(ctx, base.Add(24*time.Hour)); err != nil {
+			t.Fatalf("Prune: %v", err)
+		}
+		want, compacted = nil, 7
+		check(s)
+		s.Close()
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+		if e, err := s.(store.EventLog).LogEvent(ctx, store.Event{Type: store.EventGap, MeterID: "home"}); err != nil || e.Seq != 8 {
+			t.Fatalf("LogEvent after compacting all = %+v, %v; want seq 8", e, err)
+		}
+	})
+
+	t.Run("FailedWrite", func(t *testing.T) {
+		if cfg.failWrites == nil {
+			t.Skip("writes cannot be made to fail")
+		}
+		dir := t.TempDir()
+		s := open(t, dir)
+		first := at("00010.000", 0)
+		mustSave(t, s, "home", first)
+		restore := cfg.failWrites(s)
+		if err := s.Save(ctx, "home", at("00011.000", 1)); err == nil {
+			t.Fatal("Save with failing writes succeeded")
+		}
+		if c, ok := s.(store.Corrector); ok {
+			if _, err := c.CorrectReading(ctx, "home", genai.ReadingID("home", first), "00010.500", ""); err == nil {
+				t.Fatal("CorrectReading with failing writes succeeded")
+			}
+		}
+		if el, ok := s.(store.EventLog); ok {
+			if _, err := el.LogEvent(ctx, store.Event{Type: store.EventGap, MeterID: "home", Time: base}); err == nil {
+				t.Fatal("LogEvent with failing writes succeeded")
+			}
+		}
+		restore()
+		mustSave(t, s, "home", at("00012.000", 2))
+
+		// Neither the failed readings nor their events were kept, and the
+		// sequence numbers of the events go on without a gap.
+		check := func(s store.Store) {
+			t.Helper()
+			rs, err := s.ReadingsBetween(ctx, "home", base, base.Add(24*time.Hour))
+			if err != nil {
+				t.Fatalf("ReadingsBetween: %v", err)
+			}
+			checkReads(t, rs, "00010.000", "00012.000")
+			if rs[0].Correction != nil {
+				t.Fatalf("first reading = %+v, want it uncorrected", rs[0])
+			}
+			el, ok := s.(store.EventLog)
+			if !ok {
+				return
+			}
+			es, err := el.EventsSince(ctx, 0, 0)
+			if err != nil || len(es) != 2 {
+				t.Fatalf("EventsSince(0) = %+v, %v; want the events of the two saved readings", es, err)
+			}
+			for i, e := range es {
+				if e.Seq != uint64(i+1) || e.Type != store.EventReadingAccepted || e.Reading.Read != rs[i].Read {
+					t.Fatalf("event %d = %+v, want seq %d accepting %s", i, e, i+1, rs[i].Read)
+				}
+			}
+		}
+		check(s)
+		if cfg.ephemeral {
+			s.Close()
+			return
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		s = open(t, dir)
+		defer s.Close()
+		check(s)
+	})
+}
+
+func mustSave(t *testing.T, s store.Store, meterID string, r *genai.GasMeterReadResult) {
+	t.Helper()
+	if err := s.Save(context.Background(), meterID, r); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func checkReads(t *testing.T, rs []*genai.GasMeterReadResult, want ...string) {
+	t.Helper()
+	got := make([]string, len(rs))
+	for i, r := range rs {
+		got[i] = r.Read
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("reads = %v, want %v", got, want)
+	}
+}
+
+// checkEqual compares field by field; ReadAt and DateParsed must denote the
+// same instant but may come back in another location.
+func checkEqual(t *testing.T, got, want *genai.GasMeterReadResult) {
+	t.Helper()
+	if !got.ReadAt.Equal(want.ReadAt) {
+		t.Fatalf("ReadAt = %v, want %v", got.ReadAt, want.ReadAt)
+	}
+	if !got.DateParsed.Equal(want.DateParsed) {
+		t.Fatalf("DateParsed = %v, want %v", got.DateParsed, want.DateParsed)
+	}
+	g, w := *got, *want
+	g.ReadAt, w.ReadAt = time.Time{}, time.Time{}
+	g.DateParsed, w.DateParsed = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("reading = %+v, want %+v", g, w)
+	}
+}
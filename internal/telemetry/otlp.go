@@ -0,0 +1,275 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// OTLPConfig configures an [OTLPExporter].
+type OTLPConfig struct {
+	// Endpoint is the full URL spans are posted to, e.g.
+	// "http://localhost:4318/v1/traces".
+	Endpoint string
+	// Headers are added to each request, e.g. an authorization header.
+	Headers map[string]string
+	// Timeout bounds each export (default 10s).
+	Timeout time.Duration
+	// Transport sends the requests, e.g. through a proxy; nil is
+	// [http.DefaultTransport].
+	Transport http.RoundTripper
+}
+
+// OTLPExporter exports spans to an OTLP/HTTP endpoint in the JSON encoding.
+type OTLPExporter struct {
+	cfg    OTLPConfig
+	client *http.Client
+}
+
+// NewOTLPExporter returns an exporter posting to cfg.Endpoint.
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &OTLPExporter{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport}}
+}
+
+// ExportSpans posts spans as one export request.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("export spans: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Shutdown does nothing; each export is a request of its own.
+func (e *OTLPExporter) Shutdown(context.Context) error { return nil }
+
+// ConsoleExporter writes each batch of spans as a line of OTLP JSON, for
+// debugging without a collector.
+type ConsoleExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewConsoleExporter returns an exporter writing to w.
+func NewConsoleExporter(w io.Writer) *ConsoleExporter {
+	return &ConsoleExporter{w: w}
+}
+
+// ExportSpans writes spans to the writer.
+func (e *ConsoleExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.NewEncoder(e.w).Encode(encodeSpans(spans))
+}
+
+// Shutdown does nothing.
+func (e *ConsoleExporter) Shutdown(context.Context) error { return nil }
+
+// The OTLP/JSON export request, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding: IDs are
+// hex, 64-bit integers decimal strings.
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string         `json:"stringValue,omitempty"`
+		BoolValue   *bool           `json:"boolValue,omitempty"`
+		IntValue    *string         `json:"intValue,omitempty"`
+		DoubleValue *float64        `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	}
+	otlpArrayValue struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+)
+
+// encodeSpans groups spans by resource and instrumentation scope, keeping
+// their order.
+func encodeSpans(spans []sdktrace.ReadOnlySpan) otlpTraces {
+	type scopeKey struct {
+		res           attribute.Distinct
+		name, version string
+	}
+	var out otlpTraces
+	resources := make(map[attribute.Distinct]int)
+	scopes := make(map[scopeKey]int)
+	for _, s := range spans {
+		var res attribute.Distinct
+		var attrs []attribute.KeyValue
+		if r := s.Resource(); r != nil {
+			res, attrs = r.Equivalent(), r.Attributes()
+		}
+		ri, ok := resources[res]
+		if !ok {
+			ri = len(out.ResourceSpans)
+			resources[res] = ri
+			out.ResourceSpans = append(out.ResourceSpans, otlpResourceSpans{Resource: otlpResource{Attributes: encodeAttrs(attrs)}})
+		}
+		rs := &out.ResourceSpans[ri]
+		sc := s.InstrumentationScope()
+		key := scopeKey{res, sc.Name, sc.Version}
+		si, ok := scopes[key]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[key] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{Scope: otlpScope{Name: sc.Name, Version: sc.Version}})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, encodeSpan(s))
+	}
+	return out
+}
+
+func encodeSpan(s sdktrace.ReadOnlySpan) otlpSpan {
+	sc := s.SpanContext()
+	span := otlpSpan{
+		TraceID:           sc.TraceID().String(),
+		SpanID:            sc.SpanID().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()), // same numbering as OTLP
+		StartTimeUnixNano: unixNano(s.StartTime()),
+		EndTimeUnixNano:   unixNano(s.EndTime()),
+		Attributes:        encodeAttrs(s.Attributes()),
+	}
+	if p := s.Parent(); p.SpanID().IsValid() {
+		span.ParentSpanID = p.SpanID().String()
+	}
+	for _, ev := range s.Events() {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: unixNano(ev.Time),
+			Name:         ev.Name,
+			Attributes:   encodeAttrs(ev.Attributes),
+		})
+	}
+	switch st := s.Status(); st.Code {
+	case codes.Ok:
+		span.Status.Code = 1
+	case codes.Error:
+		span.Status = otlpStatus{Code: 2, Message: st.Description}
+	}
+	return span
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttrs(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, len(attrs))
+	for i, kv := range attrs {
+		kvs[i] = otlpKeyValue{Key: string(kv.Key), Value: encodeValue(kv.Value)}
+	}
+	return kvs
+}
+
+func encodeValue(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return encodeArray(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return encodeArray(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return encodeArray(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return encodeArray(v.AsStringSlice(), attribute.StringValue)
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func encodeArray[T any](vs []T, value func(T) attribute.Value) otlpAnyValue {
+	arr := &otlpArrayValue{Values: make([]otlpAnyValue, len(vs))}
+	for i, v := range vs {
+		arr.Values[i] = encodeValue(value(v))
+	}
+	return otlpAnyValue{ArrayValue: arr}
+}
@@ -0,0 +1,140 @@
+// Package telemetry sets up OpenTelemetry tracing from the standard OTEL_*
+// environment variables.
+//
+// Tracing is off unless OTEL_TRACES_EXPORTER is "otlp" or "console", or an
+// OTLP endpoint is set with OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT; OTEL_SDK_DISABLED=true turns it off
+// regardless. Spans are exported with OTLP over HTTP in its JSON encoding,
+// which collectors accept on the same port as protobuf (4318).
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultEndpoint is the OTLP/HTTP endpoint used when only
+// OTEL_TRACES_EXPORTER=otlp is set.
+const DefaultEndpoint = "http://localhost:4318"
+
+// defaultTimeout bounds an export unless OTEL_EXPORTER_OTLP_TIMEOUT is set.
+const defaultTimeout = 10 * time.Second
+
+// Setup installs the global tracer provider and propagator as configured by
+// the environment and returns a shutdown that flushes the pending spans. It
+// returns a nil provider when tracing is off, leaving the no-op global in
+// place; shutdown is then a no-op too. The OTLP exporter sends through
+// transport; nil is [http.DefaultTransport].
+func Setup(ctx context.Context, service, version string, transport http.RoundTripper) (*sdktrace.TracerProvider, func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	exp, err := exporterFromEnv(os.Getenv, transport)
+	if err != nil || exp == nil {
+		return nil, noop, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", service),
+			attribute.String("service.version", version),
+		),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+	)
+	if err != nil {
+		return nil, noop, fmt.Errorf("create resource: %w", err)
+	}
+	// The sampler follows OTEL_TRACES_SAMPLER, parent-based always-on by default.
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp, tp.Shutdown, nil
+}
+
+// exporterFromEnv returns the exporter the environment asks for, or nil when
+// tracing is off.
+func exporterFromEnv(getenv func(string) string, transport http.RoundTripper) (sdktrace.SpanExporter, error) {
+	if b, _ := strconv.ParseBool(getenv("OTEL_SDK_DISABLED")); b {
+		return nil, nil
+	}
+	switch exporter := strings.TrimSpace(getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case "none":
+		return nil, nil
+	case "console":
+		return NewConsoleExporter(os.Stderr), nil
+	case "":
+		if getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+			return nil, nil
+		}
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (want otlp, console or none)", exporter)
+	}
+	cfg, err := otlpConfigFromEnv(getenv)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Transport = transport
+	return NewOTLPExporter(cfg), nil
+}
+
+// otlpConfigFromEnv reads the OTLP exporter settings, preferring the
+// trace-specific variables.
+func otlpConfigFromEnv(getenv func(string) string) (OTLPConfig, error) {
+	lookup := func(name string) string {
+		if v := getenv("OTEL_EXPORTER_OTLP_TRACES_" + name); v != "" {
+			return v
+		}
+		return getenv("OTEL_EXPORTER_OTLP_" + name)
+	}
+	cfg := OTLPConfig{Timeout: defaultTimeout}
+	// The signal-specific endpoint is used as is; the generic one is a base.
+	if cfg.Endpoint = getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); cfg.Endpoint == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			base = DefaultEndpoint
+		}
+		cfg.Endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return cfg, fmt.Errorf("parse OTLP endpoint: %w", err)
+	}
+	switch p := lookup("PROTOCOL"); p {
+	case "", "http/json":
+	default:
+		return cfg, fmt.Errorf("unsupported OTLP protocol %q (only http/json)", p)
+	}
+	if h := lookup("HEADERS"); h != "" {
+		cfg.Headers = make(map[string]string)
+		for _, kv := range strings.Split(h, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return cfg, fmt.Errorf("parse OTLP header %q: want key=value", kv)
+			}
+			v, err := url.PathUnescape(strings.TrimSpace(v))
+			if err != nil {
+				return cfg, fmt.Errorf("parse OTLP header %q: %w", kv, err)
+			}
+			cfg.Headers[strings.TrimSpace(k)] = v
+		}
+	}
+	if t := lookup("TIMEOUT"); t != "" {
+		ms, err := strconv.Atoi(t)
+		if err != nil || ms <= 0 {
+			return cfg, fmt.Errorf("parse OTLP timeout %q: want milliseconds", t)
+		}
+		cfg.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	return cfg, nil
+}
@@ -0,0 +1,171 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	t.Parallel()
+	var got otlpTraces
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(OTLPConfig{Endpoint: srv.URL + "/v1/traces", Headers: map[string]string{"Authorization": "Bearer x"}})
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "image")
+	_, child := tp.Tracer("test").Start(ctx, "read")
+	child.SetAttributes(attribute.String("meter.read", "01234.567"), attribute.Int("image.size_bytes", 2048))
+	child.RecordError(errors.New("boom"))
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	parent.End() // after shutdown: not exported
+
+	if auth != "Bearer x" {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("got %+v, want one resource and scope", got)
+	}
+	ss := got.ResourceSpans[0].ScopeSpans[0]
+	if ss.Scope.Name != "test" || len(ss.Spans) != 1 {
+		t.Fatalf("scope spans = %+v", ss)
+	}
+	s := ss.Spans[0]
+	if s.Name != "read" || s.ParentSpanID != parent.SpanContext().SpanID().String() || s.TraceID != parent.SpanContext().TraceID().String() {
+		t.Fatalf("span = %+v, want read under %s", s, parent.SpanContext().SpanID())
+	}
+	if s.Status.Code != 2 || s.Status.Message != "boom" || len(s.Events) != 1 || s.Events[0].Name != "exception" {
+		t.Fatalf("span status %+v, events %+v; want the error", s.Status, s.Events)
+	}
+	attrs := make(map[string]otlpAnyValue)
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if v := attrs["meter.read"].StringValue; v == nil || *v != "01234.567" {
+		t.Fatalf("meter.read = %v", v)
+	}
+	if v := attrs["image.size_bytes"].IntValue; v == nil || *v != "2048" {
+		t.Fatalf("image.size_bytes = %v", v)
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(OTLPConfig{Endpoint: srv.URL})
+	tp := sdktrace.NewTracerProvider()
+	_, span := tp.Tracer("test").Start(context.Background(), "read")
+	span.End()
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	if !ok {
+		t.Fatalf("span %T is not read-only", span)
+	}
+	if err := exp.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{ro}); err == nil {
+		t.Fatalf("ExportSpans succeeded, want the 503")
+	}
+}
+
+func TestExporterFromEnv(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string // exporter type, "" for none
+		otlp    OTLPConfig
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "disabled", env: map[string]string{"OTEL_SDK_DISABLED": "true", "OTEL_TRACES_EXPORTER": "otlp"}},
+		{name: "none", env: map[string]string{"OTEL_TRACES_EXPORTER": "none", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://c:4318"}},
+		{name: "console", env: map[string]string{"OTEL_TRACES_EXPORTER": "console"}, want: "console"},
+		{
+			name: "otlp default endpoint",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "otlp"},
+			want: "otlp", otlp: OTLPConfig{Endpoint: "http://localhost:4318/v1/traces", Timeout: defaultTimeout},
+		},
+		{
+			name: "endpoint enables",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "api-key=a%20b, x-team=meters",
+				"OTEL_EXPORTER_OTLP_TIMEOUT":  "500",
+			},
+			want: "otlp",
+			otlp: OTLPConfig{
+				Endpoint: "http://collector:4318/v1/traces",
+				Headers:  map[string]string{"api-key": "a b", "x-team": "meters"},
+				Timeout:  500 * time.Millisecond,
+			},
+		},
+		{
+			name: "traces endpoint as is",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://ignored:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4318/custom",
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "http/json",
+			},
+			want: "otlp", otlp: OTLPConfig{Endpoint: "http://collector:4318/custom", Timeout: defaultTimeout},
+		},
+		{name: "grpc", env: map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, wantErr: true},
+		{name: "zipkin", env: map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"}, wantErr: true},
+		{name: "bad header", env: map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_HEADERS": "api-key"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			exp, err := exporterFromEnv(func(k string) string { return tt.env[k] }, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exporterFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch e := exp.(type) {
+			case nil:
+				if tt.want != "" {
+					t.Fatalf("exporterFromEnv() = nil, want %s", tt.want)
+				}
+			case *ConsoleExporter:
+				if tt.want != "console" {
+					t.Fatalf("exporterFromEnv() = console, want %q", tt.want)
+				}
+			case *OTLPExporter:
+				if tt.want != "otlp" {
+					t.Fatalf("exporterFromEnv() = otlp, want %q", tt.want)
+				}
+				if e.cfg.Endpoint != tt.otlp.Endpoint || e.cfg.Timeout != tt.otlp.Timeout || len(e.cfg.Headers) != len(tt.otlp.Headers) {
+					t.Fatalf("config = %+v, want %+v", e.cfg, tt.otlp)
+				}
+				for k, v := range tt.otlp.Headers {
+					if e.cfg.Headers[k] != v {
+						t.Fatalf("header %s = %q, want %q", k, e.cfg.Headers[k], v)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package total keeps the value of a meter fit for a Home Assistant sensor of
+// the total_increasing state class. Home Assistant takes any decrease of such
+// a sensor for a reset of the meter and counts the whole new value as
+// consumption, so a single misread accepted with a warning, or a reading
+// corrected downward, would wreck the energy dashboard: the value only ever
+// increases, but for the counter rolling over.
+package total
+
+import (
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// StateClass is the Home Assistant state class of the values of an
+// [Increasing].
+const StateClass = "total_increasing"
+
+// Reasons of a [HeldBack] reading.
+const (
+	ReasonDecrease    = "decrease"     // below the value, but not a rollover
+	ReasonOutOfOrder  = "out_of_order" // taken before the reading of the value
+	ReasonCorrection  = "correction"   // the latest reading corrected downward
+	ReasonMaintenance = "maintenance"  // taken while the meter was in maintenance
+)
+
+// HeldBack is a reading below the published value that was not published.
+type HeldBack struct {
+	Read   float64   `json:"read"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+}
+
+// State is the value to publish.
+type State struct {
+	Value float64
+	// LastReset is when the counter last rolled over past all nines, the
+	// only decrease of Value; zero if it has not.
+	LastReset time.Time
+	// HeldBack is the last reading below Value, until a reading reaches it.
+	HeldBack *HeldBack
+}
+
+// Increasing keeps the value of a meter non-decreasing. It is safe for
+// concurrent use.
+type Increasing struct {
+	m genai.Meter
+
+	mu   sync.Mutex
+	s    State
+	at   time.Time // of the reading of s.Value
+	have bool
+}
+
+// New returns an Increasing of the readings of m.
+func New(m genai.Meter) *Increasing {
+	return &Increasing{m: m}
+}
+
+// Seed starts the value at the reading v taken at at, such as the last one
+// published before a restart, without publishing it.
+func (c *Increasing) Seed(v float64, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.s, c.at, c.have = State{Value: v}, at, true
+}
+
+// State returns the current state, e.g. to publish again; ok is false
+// before the first reading.
+func (c *Increasing) State() (s State, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.s, c.have
+}
+
+// Reading returns the state after the accepted reading v taken at at. A
+// reading below the value is held back, unless it is the counter rolling
+// over, which resets the value at at.
+func (c *Increasing) Reading(v float64, at time.Time) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case !c.have || v >= c.s.Value && !at.Before(c.at):
+		c.set(v, at)
+	case at.Before(c.at):
+		c.s.HeldBack = &HeldBack{Read: v, At: at, Reason: ReasonOutOfOrder}
+	default:
+		if _, ok := c.m.Delta(c.s.Value, v); ok {
+			c.set(v, at)
+			c.s.LastReset = at
+			break
+		}
+		c.s.HeldBack = &HeldBack{Read: v, At: at, Reason: ReasonDecrease}
+	}
+	return c.s
+}
+
+// Correction returns the state after the latest reading, taken at at, was
+// corrected to v. A correction downward is held back: the value stays until
+// the readings reach it again, so the consumption in between is counted
+// then.
+func (c *Increasing) Correction(v float64, at time.Time) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.have || v >= c.s.Value {
+		c.set(v, at)
+		return c.s
+	}
+	c.s.HeldBack = &HeldBack{Read: v, At: at, Reason: ReasonCorrection}
+	return c.s
+}
+
+// Maintenance returns the state after the reading v taken at at while the
+// meter was in maintenance: it is held back, whatever its value.
+func (c *Increasing) Maintenance(v float64, at time.Time) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.s.HeldBack = &HeldBack{Read: v, At: at, Reason: ReasonMaintenance}
+	return c.s
+}
+
+// Exchange returns the state after v, the first reading of a new meter,
+// taken at at: the value restarts at v with a reset at at, as after a
+// rollover, so that Home Assistant counts v in full.
+func (c *Increasing) Exchange(v float64, at time.Time) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(v, at)
+	c.s.LastReset = at
+	return c.s
+}
+
+func (c *Increasing) set(v float64, at time.Time) {
+	c.s.Value, c.s.HeldBack = v, nil
+	c.at, c.have = at, true
+}
@@ -0,0 +1,119 @@
+package total_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/total"
+)
+
+func TestIncreasing(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time { return at.Add(time.Duration(n) * time.Hour) }
+	type step struct {
+		correction bool
+		read       float64
+		at         time.Time
+		want       float64
+		held       string // the reason of the reading held back, if any
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"increasing", []step{
+			{false, 2924.0, hour(0), 2924.0, ""},
+			{false, 2924.5, hour(1), 2924.5, ""},
+			{false, 2924.5, hour(2), 2924.5, ""},
+		}},
+		// 2924.8 is rejected by the validators and never published; 2930.0
+		// is a misread accepted with a warning and corrected to 2925.0.
+		{"rejected, then corrected downward", []step{
+			{false, 2924.0, hour(0), 2924.0, ""},
+			{false, 2930.0, hour(2), 2930.0, ""},
+			{true, 2925.0, hour(2), 2930.0, total.ReasonCorrection},
+			{false, 2926.0, hour(3), 2930.0, total.ReasonDecrease},
+			{false, 2931.0, hour(4), 2931.0, ""},
+		}},
+		{"corrected upward", []step{
+			{false, 2924.0, hour(0), 2924.0, ""},
+			{true, 2925.0, hour(0), 2925.0, ""},
+		}},
+		{"out of order", []step{
+			{false, 2924.0, hour(1), 2924.0, ""},
+			{false, 2923.5, hour(0), 2924.0, total.ReasonOutOfOrder},
+			{false, 2924.5, hour(2), 2924.5, ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := total.New(genai.DefaultMeter)
+			published := -1.0
+			for i, s := range tt.steps {
+				st := c.Reading
+				if s.correction {
+					st = c.Correction
+				}
+				got := st(s.read, s.at)
+				if got.Value < published {
+					t.Fatalf("step %d: published %.3f after %.3f", i, got.Value, published)
+				}
+				published = got.Value
+				held := ""
+				if got.HeldBack != nil {
+					held = got.HeldBack.Reason
+					if got.HeldBack.Read != s.read {
+						t.Fatalf("step %d: held back %+v, want %.3f", i, got.HeldBack, s.read)
+					}
+				}
+				if got.Value != s.want || held != s.held || !got.LastReset.IsZero() {
+					t.Fatalf("step %d: state %+v, want %.3f held back for %q", i, got, s.want, s.held)
+				}
+			}
+		})
+	}
+}
+
+func TestIncreasingRollover(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	c := total.New(genai.DefaultMeter)
+	c.Seed(99999.5, at)
+	got := c.Reading(0.25, at.Add(time.Hour))
+	if got.Value != 0.25 || !got.LastReset.Equal(at.Add(time.Hour)) || got.HeldBack != nil {
+		t.Fatalf("state %+v, want a reset to 0.25", got)
+	}
+	if got := c.Reading(1.0, at.Add(2*time.Hour)); got.Value != 1.0 || !got.LastReset.Equal(at.Add(time.Hour)) {
+		t.Fatalf("state %+v, want 1.0 since the reset", got)
+	}
+	if got, ok := c.State(); !ok || got.Value != 1.0 {
+		t.Fatalf("State = %+v, %t", got, ok)
+	}
+	if _, ok := total.New(genai.DefaultMeter).State(); ok {
+		t.Fatalf("State of a new Increasing is ok")
+	}
+}
+
+func TestIncreasingExchange(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	c := total.New(genai.DefaultMeter)
+	c.Seed(9876.5, at)
+	if got := c.Maintenance(0.1, at.Add(time.Hour)); got.Value != 9876.5 || got.HeldBack == nil || got.HeldBack.Reason != total.ReasonMaintenance {
+		t.Fatalf("state %+v, want the reading during maintenance held back", got)
+	}
+	got := c.Exchange(0.4, at.Add(2*time.Hour))
+	if got.Value != 0.4 || !got.LastReset.Equal(at.Add(2*time.Hour)) || got.HeldBack != nil {
+		t.Fatalf("state %+v, want a reset to the new meter's 0.4", got)
+	}
+	if got := c.Reading(0.6, at.Add(3*time.Hour)); got.Value != 0.6 {
+		t.Fatalf("state %+v, want 0.6 on the new meter", got)
+	}
+}
@@ -0,0 +1,221 @@
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// Names of the built-in validators.
+const (
+	Format    = "format"    // the reading matches the meter's pattern
+	Monotonic = "monotonic" // the reading is not below the previous one, but for rollover
+	MaxDelta  = "max_delta" // the increase is at most Max, or Max per Per
+	Serial    = "serial"    // the serial number matches the meter's; see [genai.CheckSerial]
+	Quality   = "quality"   // the model reported no image issue of the Issues kinds
+	DateSkew  = "date_skew" // the date on the photo is within MaxSkew of the capture time; warns unless Fatal
+	// MinConfidence: the model's confidence in every digit is at least
+	// Threshold; readings without confidences pass. See [LowConfidenceError].
+	MinConfidence = "min_confidence"
+	// CrossCheck: the reading agrees with that of the secondary recognizer,
+	// if any; warns unless Fatal. See [genai.CrossCheck].
+	CrossCheck = "cross_check"
+)
+
+// DefaultMaxSkew is the default [Config.MaxSkew]: an overlay shows minutes,
+// and the reading is timed once the image has arrived.
+const DefaultMaxSkew = 15 * time.Minute
+
+func init() {
+	Register(Format, func(m genai.Meter, _ Config) (Validator, error) {
+		return Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+			_, err := genai.ParseRead(m, cur.Read)
+			return err
+		}), nil
+	})
+	Register(Monotonic, func(m genai.Meter, _ Config) (Validator, error) {
+		return Func(func(_ context.Context, prev, cur *genai.GasMeterReadResult) error {
+			p, c, ok := values(m, prev, cur)
+			if !ok {
+				return nil
+			}
+			if _, ok := m.DeltaTo(p, cur, c); !ok {
+				return fmt.Errorf("decrease from %s", prev.Read)
+			}
+			return nil
+		}), nil
+	})
+	Register(MaxDelta, newMaxDelta)
+	Register(DateSkew, newDateSkew)
+	Register(MinConfidence, newMinConfidence)
+	Register(CrossCheck, func(_ genai.Meter, _ Config) (Validator, error) {
+		return Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+			if agree, ok := cur.CrossCheck.Agrees(cur.Read); ok && !agree {
+				return fmt.Errorf("the %s recognizer read %s", cur.CrossCheck.Recognizer, cur.CrossCheck.Read)
+			}
+			return nil
+		}), nil
+	})
+	Register(Serial, func(m genai.Meter, _ Config) (Validator, error) {
+		if m.Serial == "" {
+			return nil, errors.New("needs meter.serial")
+		}
+		return Func(func(ctx context.Context, _, cur *genai.GasMeterReadResult) error {
+			m := m
+			if s, ok := ctx.Value(serialKey{}).(string); ok {
+				m.Serial = s
+			}
+			return genai.CheckSerial(m, cur.SerialNumber)
+		}), nil
+	})
+	Register(Quality, func(_ genai.Meter, c Config) (Validator, error) {
+		for _, kind := range c.Issues {
+			if kind == genai.IssueNone || !slices.Contains(genai.IssueKinds, kind) {
+				return nil, fmt.Errorf("unknown issue %q, want one of %s", kind, strings.Join(genai.IssueKinds[1:], ", "))
+			}
+		}
+		return Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+			if cur.Issue == nil || len(c.Issues) > 0 && !slices.Contains(c.Issues, cur.Issue.Kind) {
+				return nil
+			}
+			return fmt.Errorf("image issue: %s", cur.Issue)
+		}), nil
+	})
+}
+
+// newMaxDelta bounds the increase over the previous reading to c.Max or, with
+// c.Per, to c.Max for every c.Per between the readings. Decreases are left to
+// monotonic.
+func newMaxDelta(m genai.Meter, c Config) (Validator, error) {
+	if c.Max <= 0 || c.Per < 0 {
+		return nil, errors.New("needs a positive max and no negative per")
+	}
+	return Func(func(_ context.Context, prev, cur *genai.GasMeterReadResult) error {
+		p, v, ok := values(m, prev, cur)
+		if !ok {
+			return nil
+		}
+		d, ok := m.DeltaTo(p, cur, v)
+		if !ok {
+			return nil
+		}
+		limit := c.Max
+		if c.Per > 0 {
+			limit = c.Max * float64(cur.ReadAt.Sub(prev.ReadAt)) / float64(c.Per)
+		}
+		if d > limit {
+			return fmt.Errorf("increase of %.3f over %.3f", d, limit)
+		}
+		return nil
+	}), nil
+}
+
+// ErrLowConfidence is matched (via errors.Is) by [*LowConfidenceError].
+var ErrLowConfidence = errors.New("low confidence")
+
+// LowConfidenceError is the error of min_confidence: Confidence, the lowest
+// of a reading, is that of Digit at Position, its index into the reading,
+// and below Threshold. Position is -1 if the reading has fewer digits than
+// confidences.
+type LowConfidenceError struct {
+	Position   int
+	Digit      string
+	Confidence float64
+	Threshold  float64
+}
+
+func (e *LowConfidenceError) Error() string {
+	return fmt.Sprintf("%v: %.2f in digit %q at position %d, below %.2f", ErrLowConfidence, e.Confidence, e.Digit, e.Position, e.Threshold)
+}
+
+func (e *LowConfidenceError) Is(target error) bool { return target == ErrLowConfidence }
+
+// newMinConfidence rejects readings the model is less than c.Threshold
+// confident of in some digit, per [genai.GasMeterReadResult.Confidences].
+func newMinConfidence(_ genai.Meter, c Config) (Validator, error) {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		return nil, errors.New("needs a threshold above 0 and at most 1")
+	}
+	return Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+		if len(cur.Confidences) == 0 {
+			return nil // not reported by the model
+		}
+		worst := slices.Index(cur.Confidences, slices.Min(cur.Confidences))
+		if cur.Confidences[worst] >= c.Threshold {
+			return nil
+		}
+		e := &LowConfidenceError{Position: -1, Confidence: cur.Confidences[worst], Threshold: c.Threshold}
+		n := 0
+		for i, r := range cur.Read {
+			if !unicode.IsDigit(r) && r != '?' {
+				continue
+			}
+			if n == worst {
+				e.Position, e.Digit = i, string(r)
+				break
+			}
+			n++
+		}
+		return e
+	}), nil
+}
+
+// newDateSkew checks the date the model read off the photo, in
+// [genai.GasMeterReadResult.DateParsed], against the capture time ReadAt.
+// The skew is the smaller of the difference in time and that of the wall
+// clocks in the meter's time zone, so that neither a date with another UTC
+// offset nor one in the repeated hour of a DST change is taken for a wrong
+// camera clock. A failing reading gets the skew in DateSkew.
+func newDateSkew(_ genai.Meter, c Config) (Validator, error) {
+	if c.MaxSkew < 0 {
+		return nil, errors.New("max_skew must not be negative")
+	}
+	limit := c.MaxSkew
+	if limit == 0 {
+		limit = DefaultMaxSkew
+	}
+	return Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+		if cur.DateParsed.IsZero() || cur.ReadAt.IsZero() {
+			return nil // no date recognized on the photo
+		}
+		date, captured := cur.DateParsed, cur.ReadAt.In(cur.DateParsed.Location())
+		skew := date.Sub(captured)
+		if wall := wallClock(date).Sub(wallClock(captured)); wall.Abs() < skew.Abs() {
+			skew = wall
+		}
+		if skew.Abs() <= limit {
+			return nil
+		}
+		cur.DateSkew = skew.Round(time.Second).String()
+		dir := "ahead of"
+		if skew < 0 {
+			dir = "behind"
+		}
+		return fmt.Errorf("photo date %s is %s %s the capture at %s",
+			date.Format(time.RFC3339), skew.Abs().Round(time.Second), dir, captured.Format(time.RFC3339))
+	}), nil
+}
+
+// wallClock returns the date and clock of t as if in UTC.
+func wallClock(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	return time.Date(y, mo, d, h, mi, s, t.Nanosecond(), time.UTC)
+}
+
+// values parses the readings; ok is false without a previous reading or if
+// either does not parse, which format reports.
+func values(m genai.Meter, prev, cur *genai.GasMeterReadResult) (p, c float64, ok bool) {
+	if prev == nil {
+		return 0, 0, false
+	}
+	p, err1 := genai.ParseRead(m, prev.Read)
+	c, err2 := genai.ParseRead(m, cur.Read)
+	return p, c, err1 == nil && err2 == nil
+}
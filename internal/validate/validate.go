@@ -0,0 +1,273 @@
+// Package validate decides whether a reading is accepted: a pipeline of
+// validators, configured per meter, checks it against the reading accepted
+// before it. A failing validator rejects the reading or, configured to warn,
+// only flags it in [genai.GasMeterReadResult.Warnings].
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// ErrRejected is returned by [Pipeline.Run] for a rejected reading.
+var ErrRejected = errors.New("reading rejected")
+
+// Rejection is the error of a reading rejected by the validator named
+// Validator; it matches [ErrRejected] and Err.
+type Rejection struct {
+	Validator string
+	Err       error
+}
+
+func (r *Rejection) Error() string {
+	return ErrRejected.Error() + ": " + r.Validator + ": " + r.Err.Error()
+}
+
+func (r *Rejection) Unwrap() []error { return []error{ErrRejected, r.Err} }
+
+// Validator checks the reading cur against prev, the reading accepted before
+// it, or nil for the first reading of the meter.
+type Validator interface {
+	Validate(ctx context.Context, prev, cur *genai.GasMeterReadResult) error
+}
+
+// Func adapts a function to [Validator].
+type Func func(ctx context.Context, prev, cur *genai.GasMeterReadResult) error
+
+// Validate implements [Validator].
+func (f Func) Validate(ctx context.Context, prev, cur *genai.GasMeterReadResult) error {
+	return f(ctx, prev, cur)
+}
+
+// Config is a step of a pipeline as listed in the config file. The settings
+// besides Name and Warn are those of the built-in validators; each uses the
+// ones it documents.
+type Config struct {
+	Name string `yaml:"name"`
+	// Warn flags failing readings instead of rejecting them.
+	Warn bool `yaml:"warn"`
+	// Max and Per bound the increase for max_delta.
+	Max float64       `yaml:"max"`
+	Per time.Duration `yaml:"per"`
+	// Issues are the image issue kinds quality fails on (default: all).
+	Issues []string `yaml:"issues"`
+	// MaxSkew bounds how far the date on the photo may be from the capture
+	// time for date_skew (default DefaultMaxSkew).
+	MaxSkew time.Duration `yaml:"max_skew"`
+	// Fatal makes a validator that only warns by default, date_skew, reject.
+	Fatal bool `yaml:"fatal"`
+	// Threshold is the lowest digit confidence min_confidence accepts,
+	// above 0 and at most 1.
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Factory builds the validator of a step for meter m.
+type Factory func(m genai.Meter, c Config) (Validator, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a validator available under name; it panics if name is
+// taken, like the built-ins registered by this package.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("validate: Register called twice for " + name)
+	}
+	factories[name] = f
+}
+
+// Names returns the registered validators, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// warnsByDefault are the validators that warn unless configured Fatal.
+var warnsByDefault = map[string]bool{DateSkew: true, CrossCheck: true}
+
+// Default is the pipeline of a meter without configured validators: the
+// reading must match the meter's pattern.
+var Default = []Config{{Name: Format}}
+
+// step is a validator of a pipeline.
+type step struct {
+	name string
+	warn bool
+	v    Validator
+}
+
+// Pipeline runs validators in order.
+type Pipeline struct {
+	steps []step
+
+	mu       sync.Mutex // guards the counters
+	rejected map[string]int64
+	warned   map[string]int64
+}
+
+// Stats counts the readings each validator of a [Pipeline] rejected or
+// only flagged, by name.
+type Stats struct {
+	Rejected map[string]int64 `json:"rejected"`
+	Warned   map[string]int64 `json:"warned"`
+}
+
+// beforeValues are the validators judging a reading against the previous
+// one, which min_confidence must come before: they would take a misread
+// digit for a change of the value.
+var beforeValues = []string{Monotonic, MaxDelta}
+
+// New builds the pipeline of cfgs for meter m; no cfgs is [Default].
+func New(m genai.Meter, cfgs []Config) (*Pipeline, error) {
+	if len(cfgs) == 0 {
+		cfgs = Default
+	}
+	p := &Pipeline{rejected: map[string]int64{}, warned: map[string]int64{}}
+	for i, c := range cfgs {
+		mu.RLock()
+		f, ok := factories[c.Name]
+		mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("validator %d: unknown %q, want one of %s", i, c.Name, strings.Join(Names(), ", "))
+		}
+		if c.Warn && c.Name == Format {
+			return nil, fmt.Errorf("validator %d: %s cannot just warn: a reading that does not parse has no value to publish", i, Format)
+		}
+		if c.Name == MinConfidence && slices.ContainsFunc(p.steps, func(s step) bool { return slices.Contains(beforeValues, s.name) }) {
+			return nil, fmt.Errorf("validator %d: %s must come before %s", i, MinConfidence, strings.Join(beforeValues, " and "))
+		}
+		if c.Fatal && (c.Warn || !warnsByDefault[c.Name]) {
+			return nil, fmt.Errorf("validator %d (%s): fatal is only for validators that warn by default, without warn", i, c.Name)
+		}
+		v, err := f(m, c)
+		if err != nil {
+			return nil, fmt.Errorf("validator %d (%s): %w", i, c.Name, err)
+		}
+		warn := c.Warn || warnsByDefault[c.Name] && !c.Fatal
+		p.steps = append(p.steps, step{name: c.Name, warn: warn, v: v})
+	}
+	return p, nil
+}
+
+// Has reports whether the pipeline runs the validator name.
+func (p *Pipeline) Has(name string) bool {
+	return slices.ContainsFunc(p.steps, func(s step) bool { return s.name == name })
+}
+
+// Stats returns a snapshot of the counters of p.
+func (p *Pipeline) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{Rejected: maps.Clone(p.rejected), Warned: maps.Clone(p.warned)}
+}
+
+// count records the outcome of a run: the validators that warned, and err.
+func (p *Pipeline) count(warned []string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range warned {
+		p.warned[name]++
+	}
+	var rej *Rejection
+	if errors.As(err, &rej) {
+		p.rejected[rej.Validator]++
+	}
+}
+
+// Exchange are the validators a meter exchange fails, which only warn
+// during maintenance; see [WarnOnly].
+var Exchange = []string{Monotonic, MaxDelta, Serial}
+
+type warnOnlyKey struct{}
+
+// WarnOnly returns ctx making [Pipeline.Run] only warn for the validators
+// names, as for [Exchange] while the meter is in maintenance.
+func WarnOnly(ctx context.Context, names ...string) context.Context {
+	return context.WithValue(ctx, warnOnlyKey{}, names)
+}
+
+type serialKey struct{}
+
+// WithSerial returns ctx in which the [Serial] validator expects serial of
+// the readings rather than the serial number of the meter, as after the
+// meter was exchanged while running.
+func WithSerial(ctx context.Context, serial string) context.Context {
+	return context.WithValue(ctx, serialKey{}, serial)
+}
+
+// SelfVerify names the warning of a reading the model changed on
+// re-examination; see [genai.WithSelfVerify].
+const SelfVerify = "self_verify"
+
+// Run validates cur against prev. The first failing validator that does not
+// warn rejects cur with a [Rejection], wrapping its error; the failures of
+// the warning ones before it are appended to cur.Warnings.
+//
+// When the model changed its answer on re-examination, both answers are
+// validated, the re-examined one first, and the first that passes becomes
+// cur.Read with a [SelfVerify] warning naming both; if neither passes, cur
+// is rejected for the re-examined one.
+func (p *Pipeline) Run(ctx context.Context, prev, cur *genai.GasMeterReadResult) error {
+	if !cur.VerifyDisagrees() {
+		warned, err := p.run(ctx, prev, cur)
+		p.count(warned, err)
+		return err
+	}
+	var rejected error
+	var rejectedWarned []string
+	for _, read := range []string{cur.VerifiedRead, cur.FirstRead} {
+		c := *cur
+		c.Read = read
+		c.Warnings = slices.Clone(cur.Warnings)
+		warned, err := p.run(ctx, prev, &c)
+		if err != nil {
+			if rejected == nil {
+				rejected, rejectedWarned = err, warned
+			}
+			continue
+		}
+		p.count(warned, nil)
+		c.Warnings = append(c.Warnings, fmt.Sprintf("%s: read %s, then %s on re-examination; accepted %s",
+			SelfVerify, cur.FirstRead, cur.VerifiedRead, read))
+		*cur = c
+		return nil
+	}
+	p.count(rejectedWarned, rejected)
+	return rejected
+}
+
+// run validates cur against prev, returning the validators that warned.
+func (p *Pipeline) run(ctx context.Context, prev, cur *genai.GasMeterReadResult) (warned []string, err error) {
+	warnOnly, _ := ctx.Value(warnOnlyKey{}).([]string)
+	for _, s := range p.steps {
+		err := s.v.Validate(ctx, prev, cur)
+		if err == nil {
+			continue
+		}
+		if !s.warn && !slices.Contains(warnOnly, s.name) {
+			return warned, &Rejection{Validator: s.name, Err: err}
+		}
+		cur.Warnings = append(cur.Warnings, s.name+": "+err.Error())
+		warned = append(warned, s.name)
+	}
+	return warned, nil
+}
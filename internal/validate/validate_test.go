@@ -0,0 +1,326 @@
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/validate"
+)
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := genai.DefaultMeter
+	m.Serial = "GM2019-44113"
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	prev := &genai.GasMeterReadResult{Read: "02924.000", ReadAt: at}
+	reading := func(read string, after time.Duration) *genai.GasMeterReadResult {
+		return &genai.GasMeterReadResult{Read: read, ReadAt: at.Add(after), SerialNumber: "GM2019-44113"}
+	}
+
+	tests := []struct {
+		name     string
+		cfgs     []validate.Config
+		prev     *genai.GasMeterReadResult
+		cur      *genai.GasMeterReadResult
+		rejected string // the rejecting validator, if any
+		warnings []string
+	}{
+		{"default accepts", nil, prev, reading("02924.500", time.Hour), "", nil},
+		{"default rejects garbage", nil, prev, reading("2924.5", time.Hour), validate.Format, nil},
+		{"decrease rejected", []validate.Config{{Name: validate.Format}, {Name: validate.Monotonic}}, prev, reading("02923.000", time.Hour), validate.Monotonic, nil},
+		{"rollover accepted", []validate.Config{{Name: validate.Monotonic}}, &genai.GasMeterReadResult{Read: "99999.000", ReadAt: at}, reading("00000.500", time.Hour), "", nil},
+		{"first reading", []validate.Config{{Name: validate.Monotonic}, {Name: validate.MaxDelta, Max: 1}}, nil, reading("02923.000", time.Hour), "", nil},
+		{"exchanged meter", []validate.Config{{Name: validate.Monotonic}, {Name: validate.MaxDelta, Max: 1}}, prev,
+			&genai.GasMeterReadResult{Read: "00000.500", ReadAt: at.Add(time.Hour), MeterExchanged: &genai.Exchange{Previous: "02924.000"}}, "", nil},
+		{"decrease warned", []validate.Config{{Name: validate.Monotonic, Warn: true}}, prev, reading("02923.000", time.Hour), "",
+			[]string{"monotonic: decrease from 02924.000"}},
+		{"max delta", []validate.Config{{Name: validate.MaxDelta, Max: 5}}, prev, reading("02930.000", time.Hour), validate.MaxDelta, nil},
+		{"max delta per hour", []validate.Config{{Name: validate.MaxDelta, Max: 2, Per: time.Hour}}, prev, reading("02930.000", 4*time.Hour), "", nil},
+		{"max delta per hour exceeded", []validate.Config{{Name: validate.MaxDelta, Max: 2, Per: time.Hour, Warn: true}}, prev, reading("02930.000", 2*time.Hour), "",
+			[]string{"max_delta: increase of 6.000 over 4.000"}},
+		{"wrong serial", []validate.Config{{Name: validate.Serial}}, prev, &genai.GasMeterReadResult{Read: "02924.500", SerialNumber: "XX1111-00000"}, validate.Serial, nil},
+		{"quality of other kinds", []validate.Config{{Name: validate.Quality, Issues: []string{genai.IssueObstruction}}}, prev,
+			&genai.GasMeterReadResult{Read: "02924.500", Issue: &genai.Issue{Kind: genai.IssueGlare}}, "", nil},
+		{"warnings before a rejection are kept", []validate.Config{{Name: validate.Quality, Warn: true}, {Name: validate.MaxDelta, Max: 1}}, prev,
+			&genai.GasMeterReadResult{Read: "02930.000", ReadAt: at.Add(time.Hour), Issue: &genai.Issue{Kind: genai.IssueBlur}}, validate.MaxDelta,
+			[]string{"quality: image issue: blur"}},
+		{"confidence absent", []validate.Config{{Name: validate.MinConfidence, Threshold: 0.7}}, prev, reading("02924.500", time.Hour), "", nil},
+		{"confidence low", []validate.Config{{Name: validate.MinConfidence, Threshold: 0.7}, {Name: validate.Monotonic}}, prev,
+			&genai.GasMeterReadResult{Read: "02923.000", Confidences: []float64{0.99, 0.99, 0.99, 0.99, 0.5, 0.9, 0.9, 0.9}}, validate.MinConfidence, nil},
+		{"confidence low warned", []validate.Config{{Name: validate.MinConfidence, Threshold: 0.7, Warn: true}}, prev,
+			&genai.GasMeterReadResult{Read: "02924.500", Confidences: []float64{0.99, 0.99, 0.99, 0.99, 0.99, 0.6, 0.9, 0.9}}, "",
+			[]string{`min_confidence: low confidence: 0.60 in digit "5" at position 6, below 0.70`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := validate.New(m, tt.cfgs)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			err = p.Run(ctx, tt.prev, tt.cur)
+			switch {
+			case tt.rejected == "" && err != nil:
+				t.Fatalf("Run: %v", err)
+			case tt.rejected != "" && (!errors.Is(err, validate.ErrRejected) || !strings.Contains(err.Error(), tt.rejected+":")):
+				t.Fatalf("Run = %v, want rejected by %s", err, tt.rejected)
+			}
+			var rej *validate.Rejection
+			if tt.rejected != "" && (!errors.As(err, &rej) || rej.Validator != tt.rejected) {
+				t.Fatalf("Run = %#v, want a Rejection by %s", err, tt.rejected)
+			}
+			if !slices.Equal(tt.cur.Warnings, tt.warnings) {
+				t.Fatalf("Warnings = %q, want %q", tt.cur.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestWarnOnly(t *testing.T) {
+	t.Parallel()
+
+	m := genai.DefaultMeter
+	m.Serial = "GM2019-44113"
+	p, err := validate.New(m, []validate.Config{{Name: validate.Format}, {Name: validate.Monotonic}, {Name: validate.Serial}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	prev := &genai.GasMeterReadResult{Read: "02924.000"}
+	cur := &genai.GasMeterReadResult{Read: "00000.100", SerialNumber: "XX1111-00000"}
+	ctx := validate.WarnOnly(context.Background(), validate.Exchange...)
+	if err := p.Run(ctx, prev, cur); err != nil || len(cur.Warnings) != 2 {
+		t.Fatalf("Run = %v with warnings %q, want the monotonic and serial warnings", err, cur.Warnings)
+	}
+	if err := p.Run(ctx, prev, &genai.GasMeterReadResult{Read: "1"}); !errors.Is(err, validate.ErrRejected) {
+		t.Fatalf("Run of garbage = %v, want a format rejection", err)
+	}
+
+	// After an exchange the serial number of the new meter is expected.
+	cur = &genai.GasMeterReadResult{Read: "00000.200", SerialNumber: "XX1111-00000"}
+	if err := p.Run(validate.WithSerial(context.Background(), "XX1111-00000"), &genai.GasMeterReadResult{Read: "00000.100"}, cur); err != nil || len(cur.Warnings) != 0 {
+		t.Fatalf("Run with the exchanged serial = %v with warnings %q", err, cur.Warnings)
+	}
+}
+
+func TestSelfVerify(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	prev := &genai.GasMeterReadResult{Read: "02924.000"}
+	p, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.Format}, {Name: validate.Monotonic}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	verified := func(first, second string) *genai.GasMeterReadResult {
+		r := &genai.GasMeterReadResult{Read: first}
+		r.SetVerified(second)
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		cur      *genai.GasMeterReadResult
+		read     string // accepted, empty if rejected
+		warnings []string
+	}{
+		{"agreement", verified("02924.500", "02924.500"), "02924.500", nil},
+		{"correction accepted", verified("02924.800", "02924.600"), "02924.600",
+			[]string{"self_verify: read 02924.800, then 02924.600 on re-examination; accepted 02924.600"}},
+		{"first kept", verified("02924.800", "02921.800"), "02924.800",
+			[]string{"self_verify: read 02924.800, then 02921.800 on re-examination; accepted 02924.800"}},
+		{"both rejected", verified("02923.000", "02922.000"), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := p.Run(ctx, prev, tt.cur)
+			if tt.read == "" {
+				if !strings.Contains(fmt.Sprint(err), "decrease from 02924.000") || !tt.cur.VerifyDisagrees() {
+					t.Fatalf("Run = %v, want a rejection", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if tt.cur.Read != tt.read || !slices.Equal(tt.cur.Warnings, tt.warnings) {
+				t.Fatalf("Read %q, Warnings %q; want %q, %q", tt.cur.Read, tt.cur.Warnings, tt.read, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestDateSkew(t *testing.T) {
+	t.Parallel()
+
+	seoul, err1 := time.LoadLocation("Asia/Seoul")
+	newYork, err2 := time.LoadLocation("America/New_York")
+	if err1 != nil || err2 != nil {
+		t.Skipf("no tzdata: %v %v", err1, err2)
+	}
+	ctx := context.Background()
+	captured := time.Date(2025, 11, 7, 5, 13, 17, 0, seoul)
+	// The second 01:30 of 2025-11-02 in New York, after clocks fell back.
+	fallBack := time.Date(2025, 11, 2, 6, 30, 20, 0, time.UTC)
+	tests := []struct {
+		name     string
+		cfg      validate.Config
+		date     time.Time // DateParsed, in the meter's time zone
+		readAt   time.Time
+		rejected bool
+		warning  string
+		skew     string
+	}{
+		{"within the limit", validate.Config{}, captured.Add(-10 * time.Minute), captured, false, "", ""},
+		{"capture time in UTC", validate.Config{}, captured.Truncate(time.Minute), captured.UTC(), false, "", ""},
+		{"date with another offset", validate.Config{}, captured.In(time.FixedZone("PST", -8*3600)).In(seoul), captured, false, "", ""},
+		{"repeated hour of a DST change", validate.Config{},
+			time.Date(2025, 11, 2, 1, 30, 0, 0, newYork), fallBack, false, "", ""},
+		{"camera clock behind", validate.Config{}, captured.Add(-2 * time.Hour), captured.UTC(), false,
+			"date_skew: photo date 2025-11-07T03:13:17+09:00 is 2h0m0s behind the capture at 2025-11-07T05:13:17+09:00", "-2h0m0s"},
+		{"camera not moved to DST", validate.Config{},
+			time.Date(2025, 11, 2, 3, 30, 0, 0, newYork), fallBack.Add(time.Hour), false,
+			"date_skew: photo date 2025-11-02T03:30:00-05:00 is 59m40s ahead of the capture at 2025-11-02T02:30:20-05:00", "59m40s"},
+		{"tighter limit", validate.Config{MaxSkew: time.Minute}, captured.Add(2 * time.Minute), captured, false,
+			"date_skew: photo date 2025-11-07T05:15:17+09:00 is 2m0s ahead of the capture at 2025-11-07T05:13:17+09:00", "2m0s"},
+		{"fatal", validate.Config{Fatal: true}, captured.AddDate(0, 0, -1), captured, true, "", "-24h0m0s"},
+		{"no date on the photo", validate.Config{Fatal: true}, time.Time{}, captured, false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tt.cfg
+			cfg.Name = validate.DateSkew
+			p, err := validate.New(genai.DefaultMeter, []validate.Config{cfg})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			cur := &genai.GasMeterReadResult{Read: "02924.500", DateParsed: tt.date, ReadAt: tt.readAt}
+			err = p.Run(ctx, nil, cur)
+			if rejected := errors.Is(err, validate.ErrRejected); rejected != tt.rejected {
+				t.Fatalf("Run = %v, want rejected %t", err, tt.rejected)
+			}
+			var want []string
+			if tt.warning != "" {
+				want = []string{tt.warning}
+			}
+			if !slices.Equal(cur.Warnings, want) || cur.DateSkew != tt.skew {
+				t.Fatalf("Warnings = %q, DateSkew %q; want %q, %q", cur.Warnings, cur.DateSkew, want, tt.skew)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	for _, cfgs := range [][]validate.Config{
+		{{Name: "spellcheck"}},
+		{{Name: validate.Format, Warn: true}},
+		{{Name: validate.MaxDelta}},
+		{{Name: validate.Serial}}, // the meter has no serial
+		{{Name: validate.Quality, Issues: []string{"spider"}}},
+		{{Name: validate.DateSkew, MaxSkew: -time.Minute}},
+		{{Name: validate.DateSkew, Warn: true, Fatal: true}},
+		{{Name: validate.Monotonic, Fatal: true}},
+		{{Name: validate.MinConfidence}},
+		{{Name: validate.MinConfidence, Threshold: 1.5}},
+		{{Name: validate.MaxDelta, Max: 1}, {Name: validate.MinConfidence, Threshold: 0.7}},
+	} {
+		if _, err := validate.New(genai.DefaultMeter, cfgs); err == nil {
+			t.Fatalf("New(%+v) succeeded", cfgs)
+		}
+	}
+
+	validate.Register("even", func(genai.Meter, validate.Config) (validate.Validator, error) {
+		return validate.Func(func(_ context.Context, _, cur *genai.GasMeterReadResult) error {
+			if strings.HasSuffix(cur.Read, "1") {
+				return errors.New("odd")
+			}
+			return nil
+		}), nil
+	})
+	p, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: "even"}})
+	if err != nil || !p.Has("even") || p.Has(validate.Format) {
+		t.Fatalf("New with a registered validator: %v", err)
+	}
+	if err := p.Run(context.Background(), nil, &genai.GasMeterReadResult{Read: "00001.001"}); !errors.Is(err, validate.ErrRejected) {
+		t.Fatalf("Run = %v, want rejected", err)
+	}
+}
+
+func TestMinConfidence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.MinConfidence, Threshold: 0.7}, {Name: validate.Monotonic}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	prev := &genai.GasMeterReadResult{Read: "02924.000"}
+	err = p.Run(ctx, prev, &genai.GasMeterReadResult{Read: "02924.457", Confidences: []float64{0.9, 0.9, 0.9, 0.9, 0.9, 0.8, 0.42, 0.9}})
+	var low *validate.LowConfidenceError
+	if !errors.Is(err, validate.ErrLowConfidence) || !errors.As(err, &low) {
+		t.Fatalf("Run = %v, want a LowConfidenceError", err)
+	}
+	if low.Position != 7 || low.Digit != "5" || low.Confidence != 0.42 || low.Threshold != 0.7 {
+		t.Fatalf("error = %+v, want digit 5 at 7", low)
+	}
+	if err := p.Run(ctx, prev, &genai.GasMeterReadResult{Read: "02923.000"}); err == nil {
+		t.Fatalf("Run of a decrease succeeded")
+	}
+	if err := p.Run(ctx, prev, &genai.GasMeterReadResult{Read: "02924.500", Confidences: []float64{0.9}}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := validate.Stats{Rejected: map[string]int64{validate.MinConfidence: 1, validate.Monotonic: 1}, Warned: map[string]int64{}}
+	if got := p.Stats(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestCrossCheck(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	check := &genai.CrossCheck{Recognizer: "seven_segment", Read: "02924451", Confidence: 0.9}
+	warns, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.CrossCheck}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	cur := &genai.GasMeterReadResult{Read: "02924.457", CrossCheck: check}
+	if err := warns.Run(ctx, nil, cur); err != nil || !slices.Equal(cur.Warnings, []string{"cross_check: the seven_segment recognizer read 02924451"}) {
+		t.Fatalf("Run = %v, Warnings %q", err, cur.Warnings)
+	}
+	for _, cur := range []*genai.GasMeterReadResult{
+		{Read: "02924.451", CrossCheck: check},
+		{Read: "02924.457", CrossCheck: &genai.CrossCheck{Error: "no digits found"}},
+		{Read: "02924.457"},
+	} {
+		if err := warns.Run(ctx, nil, cur); err != nil || len(cur.Warnings) > 0 {
+			t.Fatalf("Run(%+v) = %v, Warnings %q", cur, err, cur.Warnings)
+		}
+	}
+
+	// Fatal, the model's reading among two that agrees with the recognizer
+	// is accepted.
+	rejects, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.CrossCheck, Fatal: true}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := rejects.Run(ctx, nil, &genai.GasMeterReadResult{Read: "02924.457", CrossCheck: check}); !errors.Is(err, validate.ErrRejected) {
+		t.Fatalf("fatal Run = %v, want a rejection", err)
+	}
+	cur = &genai.GasMeterReadResult{Read: "02924.451", CrossCheck: check}
+	cur.SetVerified("02924.457")
+	if err := rejects.Run(ctx, nil, cur); err != nil || cur.Read != "02924.451" {
+		t.Fatalf("fatal Run of verified readings = %v, Read %s", err, cur.Read)
+	}
+}
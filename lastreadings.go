@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// ReadingChange is a change of the last reading of a meter, as watched with
+// [LastReadings.Watch].
+type ReadingChange struct {
+	MeterID string
+	Reading *genai.GasMeterReadResult
+	// Corrected is set for a correction through the API, unset for a new
+	// accepted reading.
+	Corrected bool
+	// Exchanged is set for the marker of a meter exchange, the start of the
+	// new meter.
+	Exchanged bool
+}
+
+// LastReadings owns the last accepted reading of every meter: the one the
+// next reading is checked against and counted from. The daemon accepts
+// readings and the API corrects them through it, under one lock, so that
+// either sees what the other wrote as soon as its call returns: a
+// correction of the latest reading cannot race a reading saved after it,
+// and an API call answered after an accepted reading finds it.
+type LastReadings struct {
+	Store store.Store // nil: the readings are kept in memory only
+
+	mu       sync.Mutex
+	last     map[string]*genai.GasMeterReadResult
+	watchers []func(ReadingChange)
+}
+
+// Watch calls fn with every change from now on, in order and with l locked:
+// fn must not block or call l.
+func (l *LastReadings) Watch(fn func(ReadingChange)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watchers = append(l.watchers, fn)
+}
+
+// Get returns the last reading of meterID, loading it from the store the
+// first time; [store.ErrNotFound] if there is none.
+func (l *LastReadings) Get(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.get(ctx, meterID)
+}
+
+// get is Get with l locked.
+func (l *LastReadings) get(ctx context.Context, meterID string) (*genai.GasMeterReadResult, error) {
+	if r, ok := l.last[meterID]; ok {
+		return r, nil
+	}
+	if l.Store == nil {
+		return nil, store.ErrNotFound
+	}
+	r, err := l.Store.Latest(ctx, meterID)
+	if err != nil {
+		return nil, err
+	}
+	l.set(meterID, r)
+	return r, nil
+}
+
+func (l *LastReadings) set(meterID string, r *genai.GasMeterReadResult) {
+	if l.last == nil {
+		l.last = make(map[string]*genai.GasMeterReadResult)
+	}
+	l.last[meterID] = r
+}
+
+// Seed sets the last reading of meterID to r, one that is not stored, such
+// as the seed of the client, unless the store has one.
+func (l *LastReadings) Seed(ctx context.Context, meterID string, r *genai.GasMeterReadResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.get(ctx, meterID); errors.Is(err, store.ErrNotFound) {
+		l.set(meterID, r)
+	}
+}
+
+// Accept saves r, a new reading of meterID, and makes it the last. It is
+// the last even if it could not be saved, with the error returned, as the
+// sinks are sent it all the same.
+func (l *LastReadings) Accept(ctx context.Context, meterID string, r *genai.GasMeterReadResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var err error
+	if l.Store != nil {
+		err = l.Store.Save(ctx, meterID, r)
+	}
+	l.set(meterID, r)
+	l.notify(ReadingChange{MeterID: meterID, Reading: r})
+	return err
+}
+
+// Correct corrects the stored reading of meterID with ID readingID to req,
+// checked against m, and makes it the last if it is the latest stored.
+func (l *LastReadings) Correct(ctx context.Context, m genai.Meter, meterID, readingID string, req correctionRequest) (r *genai.GasMeterReadResult, latest bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, latest, err = correctReading(ctx, l.Store, m, meterID, readingID, req); err != nil {
+		return nil, false, err
+	}
+	if latest {
+		l.set(meterID, r)
+		l.notify(ReadingChange{MeterID: meterID, Reading: r, Corrected: true})
+	}
+	return r, latest, nil
+}
+
+// Exchange saves the readings of e, an exchange of meterID laid out as m
+// checked against its last reading, and makes the marker the last. It
+// returns the exchange with the ID of the marker and the readings saved.
+func (l *LastReadings) Exchange(ctx context.Context, m genai.Meter, meterID string, e maintenance.Exchange) (maintenance.Exchange, []*genai.GasMeterReadResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev, err := l.get(ctx, meterID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return e, nil, err
+	}
+	if err := e.Validate(m, prev); err != nil {
+		return e, nil, fmt.Errorf("%w: %v", errBadExchange, err)
+	}
+	rs := e.Readings(m, meterID)
+	marker := rs[len(rs)-1]
+	e.Marker = marker.ID
+	if l.Store != nil {
+		for _, r := range rs {
+			if err := l.Store.Save(ctx, meterID, r); err != nil {
+				return e, nil, fmt.Errorf("save exchange: %w", err)
+			}
+		}
+	}
+	l.set(meterID, marker)
+	l.notify(ReadingChange{MeterID: meterID, Reading: marker, Exchanged: true})
+	return e, rs, nil
+}
+
+func (l *LastReadings) notify(c ReadingChange) {
+	for _, fn := range l.watchers {
+		fn(c)
+	}
+}
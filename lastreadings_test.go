@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestLastReadingsSeed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l := &LastReadings{}
+	if _, err := l.Get(ctx, "home"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get before any reading: %v", err)
+	}
+	l.Seed(ctx, "home", &genai.GasMeterReadResult{Read: "01234.000"})
+	if r, err := l.Get(ctx, "home"); err != nil || r.Read != "01234.000" {
+		t.Fatalf("Get after Seed = %v, %v", r, err)
+	}
+
+	s := store.NewMemory()
+	if err := s.Save(ctx, "home", &genai.GasMeterReadResult{ID: "a", Read: "01240.000"}); err != nil {
+		t.Fatal(err)
+	}
+	l = &LastReadings{Store: s}
+	l.Seed(ctx, "home", &genai.GasMeterReadResult{Read: "01234.000"})
+	if r, err := l.Get(ctx, "home"); err != nil || r.ID != "a" {
+		t.Fatalf("Get = %v, %v; want the stored reading over the seed", r, err)
+	}
+}
+
+// TestLastReadingsConcurrent accepts readings while others correct them
+// through the API and query the last one, as the daemon and API clients do;
+// run it with -race.
+func TestLastReadingsConcurrent(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	l := &LastReadings{Store: s}
+	var mu sync.Mutex
+	var changes []ReadingChange
+	l.Watch(func(c ReadingChange) {
+		mu.Lock()
+		changes = append(changes, c)
+		mu.Unlock()
+	})
+
+	h := &Corrections{Last: l, Meter: meter}
+	auth, err := NewAuth([]APIToken{{Name: "ops", Hash: hashToken("secret"), Scopes: []string{scopeAdmin}}}, nil)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.POST("/v1/meters/:id/readings/:reading_id/correction", auth.Require(scopeAdmin), h.Handler)
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { // the daemon
+		defer wg.Done()
+		for i := range n {
+			r := &genai.GasMeterReadResult{ID: fmt.Sprint(i), Read: fmt.Sprintf("%05d.000", 1000+i), ReadAt: at.Add(time.Duration(i) * time.Minute)}
+			if err := l.Accept(ctx, "home", r); err != nil {
+				t.Errorf("Accept: %v", err)
+				return
+			}
+			// Read your own writes.
+			if got, err := l.Get(ctx, "home"); err != nil || got.ID != r.ID {
+				t.Errorf("Get after accepting %s = %v, %v", r.ID, got, err)
+				return
+			}
+		}
+	}()
+	go func() { // corrections of the last reading through the API
+		defer wg.Done()
+		for range n {
+			last, err := l.Get(ctx, "home")
+			if err != nil {
+				continue
+			}
+			req := httptest.NewRequest(http.MethodPost, "/v1/meters/home/readings/"+last.ID+"/correction", strings.NewReader(`{"read":"`+last.Read+`"}`))
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("correcting %s: status %d: %s", last.ID, w.Code, w.Body)
+				return
+			}
+		}
+	}()
+	go func() { // queries
+		defer wg.Done()
+		var prev *genai.GasMeterReadResult
+		for range n {
+			r, err := l.Get(ctx, "home")
+			if err != nil {
+				continue
+			}
+			if prev != nil && r.ReadAt.Before(prev.ReadAt) {
+				t.Errorf("the last reading went back from %s to %s", prev.ID, r.ID)
+				return
+			}
+			prev = r
+		}
+	}()
+	wg.Wait()
+
+	got, err := l.Get(ctx, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.Latest(ctx, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != fmt.Sprint(n-1) || got.ID != want.ID || got.Read != want.Read {
+		t.Fatalf("last reading %s (%s), stored %s (%s)", got.ID, got.Read, want.ID, want.Read)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	accepted := 0
+	for i, c := range changes {
+		if !c.Corrected {
+			accepted++
+		}
+		if i > 0 && c.Reading.ReadAt.Before(changes[i-1].Reading.ReadAt) {
+			t.Fatalf("change %d of %s after %s", i, c.Reading.ID, changes[i-1].Reading.ID)
+		}
+	}
+	if accepted != n {
+		t.Fatalf("%d changes of accepted readings, want %d", accepted, n)
+	}
+}
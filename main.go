@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"io"
@@ -10,17 +14,40 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/anchor"
+	"github.com/suapapa/mqvision/internal/anomaly"
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/audit"
+	"github.com/suapapa/mqvision/internal/billing"
 	"github.com/suapapa/mqvision/internal/concierge"
+	"github.com/suapapa/mqvision/internal/downsample"
+	"github.com/suapapa/mqvision/internal/event"
 	"github.com/suapapa/mqvision/internal/genai"
 	"github.com/suapapa/mqvision/internal/genai/openaicompat"
+	"github.com/suapapa/mqvision/internal/imagefmt"
+	"github.com/suapapa/mqvision/internal/maintenance"
 	"github.com/suapapa/mqvision/internal/mqttdump"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/numfmt"
+	"github.com/suapapa/mqvision/internal/ocr"
+	"github.com/suapapa/mqvision/internal/redact"
+	"github.com/suapapa/mqvision/internal/report"
+	"github.com/suapapa/mqvision/internal/roi"
+	"github.com/suapapa/mqvision/internal/route"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/store"
+	"github.com/suapapa/mqvision/internal/telemetry"
+	"github.com/suapapa/mqvision/internal/total"
+	"github.com/suapapa/mqvision/internal/validate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	// "github.com/suapapa/mqvision/internal/genai/googleai"
 )
 
@@ -28,12 +55,27 @@ var (
 	flagSingleShot = ""
 	flagPort       = "8080"
 	flagConfigFile = "config.yaml"
+	flagDebug      = false
+	flagShareSafe  = false
 
 	config *Config
 
 	sensorServer    *SensorServer
 	genaiClient     genai.VisionClient
 	conciergeClient *concierge.Client
+	archiver        archive.Archiver // nil unless archive is set
+	imageDecoder    *imagefmt.Decoder
+	breaker         *genai.Breaker
+	notifiedTrips   atomic.Int64 // breaker trips notified by checkTrips
+	lastReadingAt   atomic.Int64 // unix nanoseconds of the last accepted reading
+	history         store.Store
+	lastReadings    *LastReadings  // the last reading of the meter, backed by history
+	learner         *roi.Learner   // nil unless roi.learn is set
+	recognizer      ocr.Recognizer // nil unless cross_check is set
+	cameras         *sources       // the image sources of the meter
+	routes          *route.Router  // nil unless meter.routing is set
+	cycles          Cycles
+	events          = &event.Dispatcher{} // the sinks and notifiers
 
 	chLuggage chan *Luggage
 
@@ -41,7 +83,7 @@ var (
 	appCtx context.Context
 )
 
-func newVisionClient(ctx context.Context, c *Config) (genai.VisionClient, error) {
+func newVisionClient(ctx context.Context, c *Config, opts ...genai.Option) (genai.VisionClient, error) {
 	base := strings.TrimSpace(c.OpenAICompat.BaseURL)
 	key := strings.TrimSpace(c.OpenAICompat.APIKey)
 	if base == "" || key == "" {
@@ -54,7 +96,8 @@ func newVisionClient(ctx context.Context, c *Config) (genai.VisionClient, error)
 		c.OpenAICompat.Model,
 		c.SystemPrompt,
 		c.Prompt,
-	), nil
+		visionOptions(c, opts)...,
+	)
 	// if strings.TrimSpace(c.Gemini.APIKey) == "" {
 	// 	return nil, fmt.Errorf("configure openai_compat (base_url + api_key) or gemini (api_key)")
 	// }
@@ -64,16 +107,146 @@ func newVisionClient(ctx context.Context, c *Config) (genai.VisionClient, error)
 	// 	c.Gemini.Model,
 	// 	c.SystemPrompt,
 	// 	c.Prompt,
+	// 	opts...,
 	// )
 }
 
+// visionOptions returns the genai options of c followed by opts.
+func visionOptions(c *Config, opts []genai.Option) []genai.Option {
+	return append(append(c.GenAIOptions(), genai.WithDebug(flagDebug)), opts...)
+}
+
 type Luggage struct {
 	*genai.GasMeterReadResult
 	SrcImageURL string `json:"src_image_url"`
+	// ArchiveKey is the key of the image in the archive, if any.
+	ArchiveKey string `json:"archive_key,omitempty"`
+	// Consumption since the previous reading, raw and corrected; set when a
+	// tariff or a baseline is configured.
+	Consumption *billing.Usage `json:"consumption,omitempty"`
+	// Cumulative is the consumption since meter.baseline, if configured.
+	Cumulative *billing.Usage `json:"cumulative_since_baseline,omitempty"`
+	// Image is the meter image, attached to the alerts about the reading.
+	Image []byte `json:"-"`
+
+	span trace.Span // the image span, ended once the reading is published
+	// done, if set, is sent the outcome of the reading: nil once published,
+	// or why not. retrying is set if its cycle re-captures on a rejection,
+	// from source or the next one.
+	done     chan<- error
+	retrying bool
+	source   *imageSource
 }
 
+// logEvents are the events logged unless subscriptions.log says otherwise.
+var logEvents = []event.Type{
+	event.ReadingWarning, event.ReadingRejected, event.ReadFailed,
+	event.DaemonStarted, event.AnomalyDetected, event.Digest, event.Gap, event.SourceDown, event.SelfTestFailed,
+}
+
+// tracer records the daemon's spans of the reading pipeline.
+var tracer = otel.Tracer(genai.TracerName)
+
 func main() {
 	var err error
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		if err := runCompare(os.Args[2:]); err != nil {
+			log.Fatalf("Error comparing: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		if err := runStats(os.Args[2:]); err != nil {
+			log.Fatalf("Error printing stats: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "correct" {
+		if err := runCorrect(os.Args[2:]); err != nil {
+			log.Fatalf("Error correcting reading: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "calibrate" {
+		if err := runCalibrate(os.Args[2:]); err != nil {
+			log.Fatalf("Error calibrating: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "prompt" {
+		if err := runPrompt(os.Args[2:]); err != nil {
+			log.Fatalf("Error testing prompt: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(os.Args[2:]); err != nil {
+			log.Fatalf("Error generating token: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatalf("Error replaying images: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("Error exporting: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := runReport(os.Args[2:]); err != nil {
+			log.Fatalf("Error printing report: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "anchors" {
+		if err := runAnchors(os.Args[2:]); err != nil {
+			log.Fatalf("Error listing anchors: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "maintenance" {
+		if err := runMaintenance(os.Args[2:]); err != nil {
+			log.Fatalf("Error with maintenance: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "meter" {
+		if err := runMeter(os.Args[2:]); err != nil {
+			log.Fatalf("Error with meter: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "downsample" {
+		if err := runDownsample(os.Args[2:]); err != nil {
+			log.Fatalf("Error downsampling: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := runBundle(os.Args[2:]); err != nil {
+			log.Fatalf("Error bundling: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "provenance" {
+		if err := runProvenance(os.Args[2:]); err != nil {
+			log.Fatalf("Error printing provenance: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			log.Fatalf("Error running bench: %v", err)
+		}
+		return
+	}
+
+	started := time.Now()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	appCtx = ctx
@@ -81,25 +254,320 @@ func main() {
 	flag.StringVar(&flagPort, "p", "8080", "Port to listen on")
 	flag.StringVar(&flagSingleShot, "i", "", "Single run on a image file (testing purpose)")
 	flag.StringVar(&flagConfigFile, "c", "config.yaml", "Config file to use")
+	flag.BoolVar(&flagDebug, "v", false, "Verbose debug logging (rendered prompts)")
+	flag.BoolVar(&flagShareSafe, "share-safe", false, "Redact every redactable field in the logs and payloads, for sharing them")
 	flag.Parse()
 
+	log.Printf("mqvision %s", genai.Version)
+
 	config, err = LoadConfig(flagConfigFile)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
+	// Spans are exported through the network settings of the config.
+	tp, shutdownTracing, err := telemetry.Setup(ctx, "mqvision", genai.Version, config.transport)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
+	if tp != nil {
+		log.Println("Tracing enabled")
+	}
+	redactor, err := config.Redactor(flagShareSafe)
+	if err != nil {
+		log.Fatalf("Error creating redactor: %v", err)
+	}
+	log.SetOutput(redactor.Writer(os.Stderr))
+	// redacted returns s redacted if the config says so for the receiver
+	// name.
+	redacted := func(name string, s sink.Sink) sink.Sink {
+		if config.Redacts(name) || flagShareSafe {
+			return redact.Sink(redactor, s)
+		}
+		return s
+	}
+
+	var genaiOpts []genai.Option
+	if config.Audit.Path != "" {
+		var auditOpts []audit.Option
+		if config.Audit.MaxSizeMB > 0 {
+			auditOpts = append(auditOpts, audit.WithMaxSize(int64(config.Audit.MaxSizeMB)<<20))
+		}
+		if config.Audit.MaxBackups > 0 {
+			auditOpts = append(auditOpts, audit.WithMaxBackups(config.Audit.MaxBackups))
+		}
+		if config.Audit.OmitPrompts {
+			auditOpts = append(auditOpts, audit.WithoutPrompts())
+		}
+		auditLogger, err := audit.NewLogger(config.Audit.Path, auditOpts...)
+		if err != nil {
+			log.Fatalf("Error creating audit logger: %v", err)
+		}
+		defer auditLogger.Close()
+		genaiOpts = append(genaiOpts, genai.WithAuditor(redact.Auditor(redactor, auditLogger)))
+		log.Printf("Audit log enabled: %s", config.Audit.Path)
+	}
+
+	// The log keeps what it logged before subscriptions: all but the
+	// routine readings.
+	events.AddNotifier(notify.Log(), config.Subscription(subscribeLog).OrEvents(logEvents))
+	// Deliver in the background: retries must not hold up readings, and an
+	// unreachable server is only logged.
+	// Messages are localized outside of the redaction, which drops the
+	// renderings of events.
+	addNotifier := func(key string, locale numfmt.Locale, n notify.Notifier) {
+		if config.Redacts(key) || flagShareSafe {
+			n = redact.Notifier(redactor, n)
+		}
+		n = notify.Localized(cmp.Or(locale, config.Numbers()), n)
+		events.AddNotifier(notify.Func(func(_ context.Context, e notify.Event) error {
+			go func() {
+				if err := n.Notify(appCtx, e); err != nil {
+					log.Printf("Error notifying %s of %s event: %v", key, e.Kind, err)
+				}
+			}()
+			return nil
+		}), config.Subscription(key))
+	}
+	if config.Email != nil {
+		email, err := notify.NewEmail(*config.Email)
+		if err != nil {
+			log.Fatalf("Error creating email notifier: %v", err)
+		}
+		addNotifier(subscribeEmail, "", email)
+		log.Printf("Email notifications enabled: %s", config.Email.Host)
+	}
+	for _, nc := range config.Notifiers {
+		n, err := notify.New(nc)
+		if err != nil {
+			log.Fatalf("Error creating notifier: %v", err)
+		}
+		locale, _ := numfmt.Parse(nc.NumberLocale) // checked by New
+		addNotifier(nc.Key(), locale, n)
+		log.Printf("Notifier %s enabled (%s)", nc.Key(), nc.Name)
+	}
+
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+
+	mqttClient, err := mqttdump.NewClient(config.MQTT.Host, "")
+	if err != nil {
+		log.Fatalf("Error creating MQTT client: %v", err)
+	}
+	defer mqttClient.Stop()
+
+	if config.Sinks.Stdout != "" {
+		s, err := sink.NewStdout(os.Stdout, config.Sinks.Stdout)
+		if err != nil {
+			log.Fatalf("Error creating stdout sink: %v", err)
+		}
+		events.AddSink(redacted(subscribeStdout, s), config.Subscription(subscribeStdout))
+		gin.DefaultWriter = os.Stderr // keep stdout to the readings
+		log.Printf("Writing readings to stdout as %s", config.Sinks.Stdout)
+	}
+	size := config.Sinks.Buffer
+	if size == 0 {
+		size = 1000
+	}
+	if config.Sinks.Influx != nil {
+		events.AddSink(redacted(subscribeInflux, sink.NewBuffered(sink.NewInflux(*config.Sinks.Influx), size)), config.Subscription(subscribeInflux))
+		log.Printf("Writing readings to InfluxDB: %s/%s", config.Sinks.Influx.URL, config.Sinks.Influx.Bucket)
+	}
+	if config.Sinks.MQTT != nil {
+		events.AddSink(redacted(subscribeMQTT, sink.NewMQTT(mqttClient.PublishWith, *config.Sinks.MQTT, meter)), config.Subscription(subscribeMQTT))
+		log.Printf("Publishing readings to MQTT: %s/reading/%s", config.Sinks.MQTT.Topic, cmp.Or(config.Sinks.MQTT.Encoding, sink.EncodingJSON))
+		if config.Sinks.MQTT.Delta != nil {
+			log.Printf("Publishing consumption deltas to MQTT: %s/delta/json", config.Sinks.MQTT.Topic)
+		}
+	}
+	if config.Sinks.Webhook != nil {
+		events.AddSink(redacted(subscribeWebhook, sink.NewBuffered(sink.NewWebhook(*config.Sinks.Webhook), size)), config.Subscription(subscribeWebhook))
+		log.Printf("Posting readings to webhook: %s/reading", strings.TrimSuffix(config.Sinks.Webhook.URL, "/"))
+	}
+	if config.Sinks.Kafka != nil {
+		p, err := sink.NewKafkaProducer(*config.Sinks.Kafka)
+		if err != nil {
+			log.Fatalf("Error creating Kafka producer: %v", err)
+		}
+		events.AddSink(redacted(subscribeKafka, sink.NewBuffered(sink.NewKafka(p, *config.Sinks.Kafka), size)), config.Subscription(subscribeKafka))
+		log.Printf("Producing readings to Kafka: %s", config.Sinks.Kafka.Topic)
+	}
+	defer events.Close()
+
+	if config.Store.Path != "" {
+		fs, err := store.OpenFile(config.Store.Path)
+		if err != nil {
+			log.Fatalf("Error opening store: %v", err)
+		}
+		defer fs.Close()
+		history = fs
+		log.Printf("Reading history enabled: %s", config.Store.Path)
+		genaiOpts = append(genaiOpts, genai.WithSeedStore(history))
+	}
+	maint := maintenance.NewKeeper(history, meter)
+	if err := useExchangedSerial(ctx, config, maint); err != nil {
+		log.Fatalf("Error loading the last meter exchange: %v", err)
+	} else if config.Meter.Serial != meter.Serial {
+		log.Printf("Expecting serial number %s of the meter exchanged for %s", config.Meter.Serial, meter.Serial)
+		meter.Serial = config.Meter.Serial
+	}
+	if config.Archive != nil {
+		if archiver, err = archive.New(ctx, *config.Archive); err != nil {
+			log.Fatalf("Error opening image archive: %v", err)
+		}
+		log.Printf("Archiving images to the %s archive", config.Archive.Backend)
+	}
+	if imageDecoder, err = config.ImageDecoder(); err != nil {
+		log.Fatalf("Error setting up transcoding: %v", err)
+	}
 
-	genaiClient, err = newVisionClient(ctx, config)
+	validators, err := config.Validators()
+	if err != nil {
+		log.Fatalf("Error creating validators: %v", err)
+	}
+	if config.API.Expvar != "" {
+		expvar.Publish(config.API.Expvar+"_validators", expvar.Func(func() any { return validators.Stats() }))
+	}
+	if validators.Has(validate.Serial) {
+		// The pipeline decides, and may only warn.
+		genaiOpts = append(genaiOpts, genai.WithSerialCheck(false))
+	}
+
+	genaiClient, err = newVisionClient(ctx, config, genaiOpts...)
 	if err != nil {
 		log.Fatalf("Error creating vision client: %v", err)
 	}
+	var seed genai.Seed
+	seeder, _ := genaiClient.(genai.Seeder)
+	if seeder != nil {
+		seed = seeder.Seed()
+	}
+	if seed.Source == genai.SeedNone {
+		log.Println("No previous reading; the first reading is not checked against one")
+	} else {
+		log.Printf("Previous reading %s (%s) from %s", seed.Read, seed.At.Format(time.RFC3339), seed.Source)
+	}
+	if sr, ok := genaiClient.(genai.StatsReporter); ok && config.API.Expvar != "" {
+		expvar.Publish(config.API.Expvar, expvar.Func(func() any { return sr.Stats() }))
+	}
+	flows, _ := genaiClient.(genai.FlowDetector)
+	reconfigurer, _ := genaiClient.(genai.Reconfigurer)
+	if reconfigurer != nil {
+		go reloadOnHangup(ctx, reconfigurer, maint, genaiOpts)
+	}
+	if breaker = config.GenAIBreaker(); breaker != nil {
+		genaiClient = genai.Chain(genaiClient, genai.BreakerMiddleware(breaker))
+	}
+	defer genaiClient.Close()
 
 	log.Println("Creating concierge client")
-	conciergeClient = concierge.NewClient(config.Concierge.Addr, config.Concierge.Token)
+	conciergeClient = concierge.NewClient(config.Concierge.Addr, config.Concierge.Token, config.transport)
+
+	if cfg, ok := config.ROIConfig(); ok {
+		ss, _ := history.(store.StateStore) // nil: the region is relearned after restarts
+		cfg.Debug = flagDebug
+		if learner, err = roi.New(ctx, ss, meter.ID, cfg); err != nil {
+			log.Fatalf("Error loading counter region: %v", err)
+		}
+		if config.API.Expvar != "" {
+			expvar.Publish(config.API.Expvar+"_encode", expvar.Func(func() any { return learner.EncodeStats() }))
+		}
+		if st := learner.State(); st.Stable {
+			log.Printf("Cropping captures to the learned counter region %+v", st.Box)
+		} else {
+			log.Printf("Learning the counter region (%d samples so far)", st.Samples)
+		}
+	}
+
+	if config.CrossCheck != nil {
+		if recognizer, err = ocr.New(meter, *config.CrossCheck); err != nil {
+			log.Fatalf("Error creating cross-check recognizer: %v", err)
+		}
+		log.Printf("Cross-checking readings with the %s recognizer", config.CrossCheck.Name())
+	}
 
 	log.Println("Creating sensor server")
-	sensorServer = &SensorServer{}
+	sensorServer = &SensorServer{Unit: meter.Unit, DeviceClass: meter.DeviceClass(), MeterID: meter.ID}
 
+	var analyzer *anomaly.Analyzer
+	if cfg, ok := config.AnomalyConfig(); ok {
+		analyzer = anomaly.New(history, meter, cfg)
+	}
+	var leaks *anomaly.LeakDetector
+	if cfg, ok, _ := config.LeakConfig(); ok { // checked by Validate
+		leaks = anomaly.NewLeakDetector(history, meter, cfg)
+	}
+	var leakChecked time.Time // end of the last idle window checked
+	digest := report.Period(config.Digest.Period)
+	reportCfg := config.ReportConfig()
+	var anchors *anchor.Keeper
+	if config.Anchor != nil && history != nil { // a store.path, checked by Validate
+		if anchors, err = anchor.NewKeeper(history, *config.Anchor, meter, reportCfg.TimeZone()); err != nil {
+			log.Fatalf("Error keeping daily anchors: %v", err)
+		}
+		log.Printf("Keeping daily anchors at %s", cmp.Or(config.Anchor.At, "00:00"))
+	}
+	if config.Downsample != nil && history != nil { // a store.path, checked by Validate
+		j, err := downsample.New(history, *config.Downsample, meter, reportCfg.TimeZone(), config.Anchor)
+		if err != nil {
+			log.Fatalf("Error downsampling history: %v", err)
+		}
+		go downsampleEvery(ctx, j, meter.ID, config.Downsample.Interval())
+	}
+	if s, err := maint.Configure(ctx, meter.ID, config.Meter.Maintenance, time.Now()); err != nil {
+		log.Fatalf("Error configuring maintenance: %v", err)
+	} else if s != nil && s.Active(time.Now()) {
+		log.Printf("Meter %s in maintenance until %s: %s", meter.ID, maintenanceUntil(*s), s.Note)
+	}
+	events.Suppress = suppressAlerts(maint, genai.RealClock)
+	var since *billing.Since
+	if b := config.Meter.Baseline; b != nil {
+		since = sinceBaseline(ctx, history, meter, config.Tariff, meter.ID, *b)
+		log.Printf("Counting the consumption since %s: %.3f %s", b.At.Format(time.DateOnly), since.Usage.Raw, meter.Unit)
+	}
+	var tariff billing.Tariff // without one consumption is left uncorrected
+	if config.Tariff != nil {
+		tariff = *config.Tariff
+	}
+	// Only periods ending while running are digested, not one per restart.
+	digestSent, _ := digest.Bounds(time.Now(), reportCfg.TimeZone())
+	var prevRead float64 // last published non-stale value
+	havePrev := false
+	var prevResult *genai.GasMeterReadResult // its reading, for the validators
+	lastReadings = &LastReadings{Store: history}
+	if seed.Source != genai.SeedNone {
+		prevRead, _ = genai.ParseRead(meter, seed.Read) // checked by ResolveSeed
+		havePrev = true
+		prevResult = &genai.GasMeterReadResult{Read: seed.Read, ReadAt: seed.At}
+		lastReadings.Seed(ctx, meter.ID, prevResult)
+	}
+	if seeder != nil {
+		// The client reads the next from a correction, or the start of an
+		// exchanged meter, before the API answers.
+		lastReadings.Watch(func(c ReadingChange) {
+			if !c.Corrected && !c.Exchanged {
+				return
+			}
+			if err := seeder.SeedReading(c.Reading); err != nil {
+				log.Printf("Error seeding corrected reading: %v", err)
+			}
+		})
+	}
+	// The sensor never publishes below the previous reading, which Home
+	// Assistant may have from before a restart.
+	sensorTotal := total.New(meter)
+	if havePrev {
+		sensorTotal.Seed(prevRead, seed.At)
+	}
 	chLuggage = make(chan *Luggage, 10)
+	chCorrections := make(chan correction, 10)
+	chExchanges := make(chan meterExchange, 1)
+	var exchangedSerial string // expected since an exchange through the API
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func(ctx context.Context) {
@@ -108,6 +576,80 @@ func main() {
 			select {
 			case <-ctx.Done():
 				return
+			case fix := <-chCorrections:
+				// Consumption is derived from the history, so reports and checks
+				// see the correction; sinks are sent what it changes.
+				cons := correctedConsumption(ctx, history, meter, config.Tariff, meter.ID, fix.r)
+				if since != nil {
+					// A correction changes the consumption since the baseline
+					// of the readings after it, but not what sinks were sent.
+					since = sinceBaseline(ctx, history, meter, config.Tariff, meter.ID, since.Baseline)
+				}
+				publishCorrection(ctx, events, meter.ID, fix, cons)
+				recomputeAnchors(ctx, anchors, meter.ID, fix.r.ReadAt)
+				if !fix.latest {
+					fix.applied()
+					continue
+				}
+				read, _ := genai.ParseRead(meter, fix.r.Read) // checked by correctReading
+				l := &Luggage{GasMeterReadResult: fix.r}
+				if prev := sensorServer.Latest(); prev != nil {
+					l.SrcImageURL = prev.SrcImageURL
+				}
+				for _, c := range cons {
+					if c.At.Equal(fix.r.ReadAt) {
+						l.Consumption = &c.Usage
+					}
+				}
+				if since != nil {
+					u := since.Usage
+					l.Cumulative = &u
+				}
+				st := sensorTotal.Correction(read, fix.r.ReadAt)
+				sensorServer.SetTotal(st, l)
+				if st.HeldBack != nil {
+					log.Printf("Holding the sensor value at %.3f above the correction %s", st.Value, fix.r.Read)
+				} else {
+					log.Printf("Updated sensor value to the correction: %s", fix.r.Read)
+				}
+				fix.applied()
+			case x := <-chExchanges:
+				// The history has the readings of the exchange; the sinks and
+				// the sensor are sent them, and the new meter is counted on
+				// from its start.
+				if since != nil {
+					since = sinceBaseline(ctx, history, meter, config.Tariff, meter.ID, since.Baseline)
+				}
+				recomputeAnchors(ctx, anchors, meter.ID, x.Exchange.At)
+				if s := x.Exchange.Serial; s != "" && meter.Serial != "" {
+					exchangedSerial = s
+					if reconfigurer != nil {
+						if err := reloadVision(ctx, reconfigurer, maint, genaiOpts); err != nil {
+							log.Printf("Error reconfiguring for serial number %s: %v", s, err)
+						}
+					}
+				}
+				var st total.State
+				for _, r := range x.Readings {
+					read, _ := genai.ParseRead(meter, r.Read) // checked by Exchange.Validate
+					l := &Luggage{GasMeterReadResult: r}
+					if config.Tariff != nil && havePrev {
+						if d, ok := meter.DeltaTo(prevRead, r, read); ok {
+							u := tariff.Correct(r.ReadAt, d)
+							l.Consumption = &u
+						}
+					}
+					if r.MeterExchanged != nil {
+						st = sensorTotal.Exchange(read, r.ReadAt)
+					} else {
+						st = sensorTotal.Reading(read, r.ReadAt)
+					}
+					sensorServer.SetTotal(st, l)
+					publish(ctx, meter.ID, l)
+					prevRead, havePrev, prevResult = read, true, r
+				}
+				log.Printf("Updated sensor value to the start of the exchanged meter: %s", prevResult.Read)
+				x.applied()
 			case readResult, ok := <-chLuggage:
 				if !ok {
 					return
@@ -120,23 +662,182 @@ func main() {
 				// os.Stdout.Write(jsonBytes)
 				// os.Stdout.WriteString("\n")
 
-				read, err := strconv.ParseFloat(readResult.Read, 64)
+				ctx := ctx
+				if readResult.span != nil {
+					ctx = trace.ContextWithSpan(ctx, readResult.span)
+				}
+				read, err := genai.ParseRead(meter, readResult.Read)
 				if err != nil {
 					log.Printf("Error parsing read value: %v", err)
+					endImageSpan(readResult, err)
 					continue
 				}
 
-				sensorServer.SetValue(read, readResult)
+				// A correction through the API may have moved the last reading.
+				if r, err := lastReadings.Get(ctx, meter.ID); err == nil && r != prevResult {
+					prevRead, _ = genai.ParseRead(meter, r.Read)
+					havePrev, prevResult = true, r
+				}
+
+				if readResult.Stale {
+					_, span := tracer.Start(ctx, genai.SpanPublish, trace.WithAttributes(genai.AttrMeterID.String(meter.ID)))
+					if st, ok := sensorTotal.State(); ok {
+						sensorServer.SetTotal(st, readResult)
+					} else {
+						sensorServer.SetValue(read, readResult)
+					}
+					span.End()
+					endImageSpan(readResult, nil)
+					log.Printf("Republished stale sensor value: %s (since %s)", readResult.Read, readResult.StaleSince)
+					continue
+				}
+
+				if err := maint.Mark(ctx, meter.ID, prevResult, readResult.GasMeterReadResult); err != nil {
+					log.Printf("Error checking maintenance: %v", err)
+				}
+				vctx := ctx
+				if readResult.Maintenance {
+					// The exchanged meter reads lower, or with another serial.
+					vctx = validate.WarnOnly(ctx, validate.Exchange...)
+				}
+				if exchangedSerial != "" {
+					vctx = validate.WithSerial(vctx, exchangedSerial)
+				}
+				if err := validators.Run(vctx, prevResult, readResult.GasMeterReadResult); err != nil {
+					rejectReading(ctx, seeder, prevResult, readResult, err)
+					endImageSpan(readResult, err)
+					continue
+				}
+				readResult.ID = genai.ReadingID(meter.ID, readResult.GasMeterReadResult)
+				if err := maint.Accepted(ctx, meter.ID, prevResult, readResult.GasMeterReadResult); err != nil {
+					log.Printf("Error saving maintenance: %v", err)
+				}
+				if e := readResult.MeterExchanged; e != nil {
+					log.Printf("Meter %s exchanged: the old one's final reading %s, the new one's first %s", meter.ID, cmp.Or(e.Previous, "unknown"), readResult.Read)
+				}
+				if gap := gapBefore(prevResult, readResult.GasMeterReadResult, config.Gaps.Threshold); gap > 0 {
+					readResult.GapBefore = gap.String()
+					notifyGap(ctx, meter.ID, prevResult, readResult.GasMeterReadResult)
+				}
+				sctx, span := tracer.Start(ctx, genai.SpanStore, trace.WithAttributes(genai.AttrMeterID.String(meter.ID)))
+				err = lastReadings.Accept(sctx, meter.ID, readResult.GasMeterReadResult)
+				if err != nil {
+					log.Printf("Error saving reading: %v", err)
+				}
+				genai.EndSpan(span, err)
+				if history != nil && err == nil {
+					recomputeAnchors(ctx, anchors, meter.ID, readResult.ReadAt)
+					if readResult.GapBefore != "" {
+						logGap(ctx, history, meter.ID, prevResult, readResult.GasMeterReadResult)
+					}
+				}
+				if readResult.Ambiguous {
+					notifyAmbiguous(ctx, meter.ID, readResult.GasMeterReadResult, readResult.Image)
+				}
+				if analyzer != nil {
+					checkAnomaly(ctx, analyzer, meter.ID, readResult.GasMeterReadResult, readResult.Image)
+				}
+				if leaks != nil {
+					// Check once per day, with the first reading after the idle window.
+					if _, end := leaks.Window(readResult.ReadAt); !readResult.ReadAt.Before(end) && end.After(leakChecked) {
+						leakChecked = end
+						checkLeak(ctx, leaks, meter.ID, readResult.ReadAt, readResult.Image)
+					}
+				}
+
+				if digest != "" {
+					// Digest once per period, with its first reading after the end.
+					if from, _ := digest.Bounds(readResult.ReadAt, reportCfg.TimeZone()); from.After(digestSent) {
+						digestSent = from
+						sendDigest(ctx, reportCfg, meter.ID, digest, from.Add(-time.Nanosecond), readResult.ReadAt)
+					}
+				}
+
+				if (config.Tariff != nil || since != nil) && havePrev && readResult.Counted() {
+					if d, ok := meter.DeltaTo(prevRead, readResult.GasMeterReadResult, read); ok {
+						u := tariff.Correct(readResult.ReadAt, d)
+						readResult.Consumption = &u
+					}
+				}
+				if since != nil {
+					u := since.Add(readResult.GasMeterReadResult)
+					readResult.Cumulative = &u
+				}
+				prevRead, havePrev, prevResult = read, true, readResult.GasMeterReadResult
+
+				_, span = tracer.Start(ctx, genai.SpanPublish, trace.WithAttributes(
+					genai.AttrMeterID.String(meter.ID),
+					genai.AttrRead.String(readResult.Read),
+				))
+				var st total.State
+				switch {
+				case readResult.Maintenance:
+					st = sensorTotal.Maintenance(read, readResult.ReadAt)
+				case readResult.MeterExchanged != nil:
+					st = sensorTotal.Exchange(read, readResult.ReadAt)
+				default:
+					st = sensorTotal.Reading(read, readResult.ReadAt)
+				}
+				if st.HeldBack != nil {
+					log.Printf("Holding the sensor value at %.3f above %s (%s)", st.Value, readResult.Read, st.HeldBack.Reason)
+				}
+				sensorServer.SetTotal(st, readResult)
+				publish(trace.ContextWithSpan(ctx, span), meter.ID, readResult)
+				span.End()
+				endImageSpan(readResult, nil)
+				lastReadingAt.Store(time.Now().UnixNano())
 				log.Printf("Updated sensor value: %s (%.3f)", readResult.Read, read)
 			}
 		}
 	}(ctx)
 
-	mqttClient, err := mqttdump.NewClient(config.MQTT.Host, config.MQTT.Topic)
+	cameras, err = newSources(config.SourceConfigs(), mqttClient.Publish, config.RecaptureAttempts(), config.Recapture.Delay,
+		config.Failover.Silence, config.Failover.AlertAfter, genai.RealClock)
 	if err != nil {
-		log.Fatalf("Error creating MQTT client: %v", err)
+		log.Fatalf("Error opening image sources: %v", err)
+	}
+	cameras.onDown = func(src *imageSource, since time.Time) { notifySourceDown(appCtx, meter.ID, src, since) }
+	for _, src := range cameras.list {
+		if src.camera != nil {
+			log.Printf("Capturing with the camera of %s every %s (exposure %s)", src.name, src.interval, src.camera.Exposure())
+			go captureEvery(ctx, src)
+		} else {
+			mqttClient.Handle(src.topic, sourceHandler(src))
+		}
+		if src.trigger != nil && src.recapture.Enabled() {
+			log.Printf("Re-capturing rejected readings up to %d times through %s", src.recapture.Attempts, src.trigger.Topic)
+		}
+	}
+	if len(config.Sources) > 0 {
+		log.Printf("Reading images from %d sources, %s first", len(cameras.list), cameras.Primary().name)
+		go cameras.Watch(ctx, time.Minute)
+	}
+	if leaks != nil && config.Leak.Flow.Enabled {
+		switch p := cameras.Primary(); {
+		case flows == nil:
+			log.Printf("Not checking the flow indicator: the vision client cannot compare photos")
+		case p.capture == nil:
+			log.Printf("Not checking the flow indicator: %s cannot capture on request", p.name)
+		default:
+			interval, every := config.FlowCheck()
+			log.Printf("Checking the flow indicator with %s every %s during the leak window", p.name, every)
+			go checkFlowEvery(ctx, flows, leaks, p, meter.ID, interval, every)
+		}
+	}
+	if rc := config.Meter.Routing; rc.Enabled() {
+		if routes, err = route.New(rc, config.ReportConfig().TimeZone(), time.Now()); err != nil {
+			log.Fatalf("Error creating routing: %v", err)
+		}
+		log.Printf("Routing reading cycles with %d rules and %d tags", len(rc.Rules), len(rc.Tags))
+		if p := cameras.Primary(); rc.Captures() && p.trigger != nil {
+			go scheduleCaptures(ctx, func() error { return p.trigger.Publish(p.trigger.Topic, p.trigger.Payload) })
+		} else if rc.Captures() && p.camera != nil {
+			go scheduleCaptures(ctx, func() error { return captureFrom(ctx, p) })
+		}
+	}
+	if config.API.Expvar != "" {
+		expvar.Publish(config.API.Expvar+"_sources", expvar.Func(func() any { return cameras.Health() }))
 	}
-	defer mqttClient.Stop()
 
 	wg.Add(1)
 	go func() {
@@ -181,8 +882,7 @@ func main() {
 		} else {
 			log.Println("Running MQTT client")
 
-			hdlr := mqttReadGaugeSubHandler
-			if err := mqttClient.Run(hdlr); err != nil {
+			if err := mqttClient.Run(nil); err != nil {
 				log.Fatalf("Error running MQTT client: %v", err)
 			}
 
@@ -198,14 +898,93 @@ func main() {
 	log.Printf("Starting Gin server on port %s", flagPort)
 	router := gin.New()
 	router.Use(gin.Recovery())
+	router.Use(cors(config.API.CORSOrigins))
 	// router.Use(gin.Logger())
-	router.GET("/sensor", sensorServer.GetValueHandler)
+	auth, err := NewAuth(config.API.Tokens, genai.RealClock)
+	if err != nil {
+		log.Fatalf("Error loading API tokens: %v", err)
+	}
+	readScope := auth.Require(scopeRead)
+	router.GET("/sensor", readScope, sensorServer.GetValueHandler)
+	router.GET("/v1/meters/:id/stream", readScope, sensorServer.StreamHandler)
+	seriesServer := &Series{Store: history, Meter: meter, MaxPoints: config.API.MaxPoints, Location: config.ReportConfig().TimeZone(), Gaps: config.Gaps.Attribution}
+	router.GET("/v1/meters/:id/series", readScope, seriesServer.Handler)
+	anchorsServer := &Anchors{Keeper: anchors, Meter: meter, Location: reportCfg.TimeZone()}
+	router.GET("/v1/meters/:id/anchors", readScope, anchorsServer.Handler)
+	images, _ := archiver.(archive.Store)
+	dashboard := &Dashboard{Sensor: sensorServer, Store: history, Images: images, Meter: meter, Baseline: config.Meter.Baseline, NumberLocale: config.Numbers()}
+	router.GET("/", readScope, dashboard.Handler)
+	router.GET("/v1/meters/:id/photo", readScope, dashboard.PhotoHandler)
+	var selfTest *SelfTest
+	if config.SelfTest.Image != "" {
+		if selfTest, err = newSelfTest(ctx, config, meter); err != nil {
+			log.Fatalf("Error setting up the self-test: %v", err)
+		}
+		defer selfTest.Client.Close()
+		at, _ := config.SelfTestAt() // checked by Validate
+		go selfTestDaily(ctx, selfTest, at, reportCfg.TimeZone())
+		log.Printf("Self-testing with %s every day at %s", config.SelfTest.Image, cmp.Or(config.SelfTest.At, "04:00"))
+	}
+	health := &Health{Version: genai.Version, Started: started, Checks: readinessChecks(mqttClient, started), Cycles: &cycles, SelfTest: selfTest}
+	router.GET("/healthz", health.HealthzHandler)
+	router.GET("/readyz", health.ReadyzHandler)
+	corrections := &Corrections{Last: lastReadings, Meter: meter, OnCorrect: func(ctx context.Context, fix correction) {
+		// Answer once the sensor and its stream show the correction.
+		fix.done = make(chan struct{})
+		select {
+		case chCorrections <- fix:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-fix.done:
+		case <-ctx.Done():
+		}
+	}}
+	router.POST("/v1/meters/:id/readings/:reading_id/correction", auth.Require(scopeAdmin), corrections.Handler)
+	eventLog, _ := history.(store.EventLog)
+	eventFeed := &EventFeed{Log: eventLog}
+	router.GET("/v1/events", readScope, eventFeed.Handler)
+	maintenanceServer := &Maintenance{Keeper: maint, Meter: meter}
+	router.GET("/v1/meters/:id/maintenance", readScope, maintenanceServer.Handler)
+	router.POST("/v1/meters/:id/maintenance", auth.Require(scopeAdmin), maintenanceServer.Handler)
+	router.DELETE("/v1/meters/:id/maintenance", auth.Require(scopeAdmin), maintenanceServer.Handler)
+	exchangeServer := &MeterExchange{Last: lastReadings, Keeper: maint, Meter: meter, Learner: learner, OnExchange: func(ctx context.Context, x meterExchange) {
+		// Answer once the sensor and the sinks have the new meter.
+		x.done = make(chan struct{})
+		select {
+		case chExchanges <- x:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-x.done:
+		case <-ctx.Done():
+		}
+	}}
+	router.GET("/v1/meters/:id/exchange", readScope, exchangeServer.Handler)
+	router.POST("/v1/meters/:id/exchange", auth.Require(scopeAdmin), exchangeServer.Handler)
+	pool := newClientPool(config)
+	defer pool.Close()
+	go evictIdleEvery(ctx, pool, time.Minute)
+	readClient, err := newReadClient(ctx, config, genaiOpts)
+	if err != nil {
+		log.Fatalf("Error creating the vision client of /v1/read: %v", err)
+	}
+	defer readClient.Close()
+	readImage := &ReadImage{Client: readClient, Pool: pool, Model: config.OpenAICompat.Model}
+	router.POST("/v1/read", auth.Require(scopeSubmit), readImage.Handler)
+	if config.API.Expvar != "" {
+		expvar.Publish(config.API.Expvar+"_pool", expvar.Func(func() any { return pool.Stats() }))
+		router.GET("/debug/vars", readScope, gin.WrapH(expvar.Handler()))
+	}
 
 	// Create HTTP server with graceful shutdown support
 	srv := &http.Server{
 		Addr:    ":" + flagPort,
 		Handler: router,
 	}
+	srv.RegisterOnShutdown(sensorServer.CloseStreams)
 
 	// Start server in a goroutine
 	go func() {
@@ -215,6 +994,20 @@ func main() {
 	}()
 
 	log.Println("Server started. Press Ctrl+C to stop.")
+	startMsg := fmt.Sprintf("mqvision %s started without a previous reading", genai.Version)
+	if seed.Source != genai.SeedNone {
+		startMsg = fmt.Sprintf("mqvision %s started, previous reading %s", genai.Version, seed.Read)
+	}
+	err = events.Dispatch(ctx, event.Event{Type: event.DaemonStarted, Event: notify.Event{
+		Kind:     "started",
+		Severity: notify.Info,
+		MeterID:  meter.ID,
+		Time:     started,
+		Message:  startMsg,
+	}})
+	if err != nil {
+		log.Printf("Error notifying start: %v", err)
+	}
 
 	// Wait for interrupt signal
 	<-sigChan
@@ -260,44 +1053,627 @@ func main() {
 	log.Println("Server stopped")
 }
 
-func mqttReadGaugeSubHandler() io.WriteCloser {
-	pr, pw := io.Pipe()
+// sourceHandler returns the MQTT handler of the images of src.
+func sourceHandler(src *imageSource) mqttdump.SubHandler {
+	return func() io.WriteCloser {
+		if src.trigger != nil {
+			if w := src.trigger.Pending(); w != nil {
+				return w // the image a running cycle asked for
+			}
+		}
+		if !cameras.Wanted(src) {
+			log.Printf("Skipping image from %s: a source before it works", src.name)
+			return discardCloser{}
+		}
+		meterID := config.Meter.ID
+		done, ok := cycles.Start(meterID)
+		if !ok {
+			log.Printf("Skipping image: the previous reading of %s is still running (%d skipped)", meterID, cycles.Skipped()[meterID])
+			return discardCloser{}
+		}
+		var rt route.Route
+		if routes != nil {
+			rt = routes.Cycle(time.Now())
+		}
+		pr, pw := io.Pipe()
 
-	go func() {
-		defer pr.Close()
+		go func() {
+			defer pr.Close()
+			defer done()
 
-		imgBytes, err := io.ReadAll(pr)
-		if err != nil {
-			log.Printf("Error reading MQTT image stream: %v", err)
-			return
+			// Re-captures after a rejection, from the same source or the
+			// next ones, are part of the same cycle.
+			var img io.Reader = pr
+			cur := src
+			for attempt := 1; ; attempt++ {
+				err := readCycle(meterID, cur, img, attempt, rt)
+				cameras.Record(cur, err)
+				var ok bool
+				if img, ok = cur.recapture.Next(appCtx, attempt, err); ok {
+					continue
+				}
+				if cur, img, ok = cameras.Failover(appCtx, cur, err); !ok {
+					return
+				}
+				attempt = 0
+			}
+		}()
+
+		return pw
+	}
+}
+
+// readCycle reads the image r of src through the whole pipeline and returns
+// why it was not published, if it was not. attempt counts the captures of
+// the cycle from src, which is read as rt routes it.
+func readCycle(meterID string, src *imageSource, r io.Reader, attempt int, rt route.Route) error {
+	// The image span covers the whole pipeline; it is ended by the consumer
+	// once the reading is published, or here if it fails.
+	ctx, span := tracer.Start(appCtx, genai.SpanImage, trace.WithAttributes(genai.AttrMeterID.String(meterID)))
+	if rt.Model != "" {
+		if attempt == 1 {
+			log.Printf("Reading the cycle (tags %v) with %s (rule %q)", rt.Tags, rt.Model, rt.Rule)
 		}
+		ctx = genai.ContextWithModel(ctx, rt.Model)
+	}
+	l, err := readGaugeImage(ctx, r)
+	if !errors.Is(err, errCapture) {
+		cameras.Received(src)
+	}
+	if err != nil {
+		genai.EndSpan(span, err)
+		return err
+	}
+	l.span = span
+	l.Route, l.Tags = rt.Rule, rt.Tags
+	outcome := make(chan error, 1)
+	l.done = outcome
+	l.source, l.Source = src, src.name
+	l.retrying = src.recapture.Enabled() && attempt <= src.recapture.Attempts || cameras.CanFailover(src)
+	chLuggage <- l
+	select {
+	case err := <-outcome:
+		return err
+	case <-appCtx.Done():
+		return appCtx.Err()
+	}
+}
 
-		srcImgStoredURL, err := conciergeClient.PostImage(bytes.NewReader(imgBytes), "image/jpeg")
-		if err != nil {
-			log.Printf("Error posting image to concierge: %v", err)
+// scheduleCaptures captures, or asks the camera to, at each minute a routing
+// tag asks for a photo, so that the cycle the tag is due for starts on time.
+func scheduleCaptures(ctx context.Context, capture func() error) {
+	for {
+		next := routes.NextCapture(time.Now())
+		if next.IsZero() {
 			return
 		}
-		log.Printf("Posted image to concierge: %s", srcImgStoredURL)
-
-		readResult, err := genaiClient.ReadGasGaugePicFromURL(appCtx, srcImgStoredURL)
-		if err != nil {
-			log.Printf("Error reading gauge image from URL: %v", err)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
 			return
 		}
-		if readResult == nil {
-			log.Printf("Read result is nil")
+		log.Printf("Requesting the scheduled capture of %s", next.Format(time.RFC3339))
+		if err := capture(); err != nil {
+			log.Printf("Error requesting scheduled capture: %v", err)
+		}
+	}
+}
+
+// captureEvery captures with the camera of src every interval, while src is
+// wanted, until ctx is done.
+func captureEvery(ctx context.Context, src *imageSource) {
+	ticker := time.NewTicker(src.interval)
+	defer ticker.Stop()
+	for {
+		if cameras.Wanted(src) {
+			if err := captureFrom(ctx, src); err != nil && ctx.Err() == nil {
+				log.Printf("Error capturing from %s: %v", src.name, err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
 			return
 		}
-		log.Printf("Read result: %+v", readResult)
+	}
+}
+
+// captureFrom captures with the camera of src and reads the image as one
+// published by an MQTT camera.
+func captureFrom(ctx context.Context, src *imageSource) error {
+	img, err := src.camera.Capture(ctx)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", errCapture, err)
+		cameras.Record(src, err)
+		return err
+	}
+	w := sourceHandler(src)()
+	if _, err := w.Write(img); err != nil {
+		w.Close()
+		return fmt.Errorf("read capture: %w", err)
+	}
+	return w.Close()
+}
+
+// readImage reads img, posted at url, with the model and, in parallel, with
+// the cross-check recognizer, if any. With cross_check.verify, an image the
+// two disagree on is read again, self-verified.
+func readImage(ctx context.Context, img []byte, url string) (*genai.GasMeterReadResult, error) {
+	if recognizer == nil {
+		return readModel(ctx, img, url)
+	}
+	checked := make(chan *genai.CrossCheck, 1)
+	go func() {
+		_, span := tracer.Start(ctx, genai.SpanCrossCheck)
+		cc := ocr.Check(recognizer, *config.CrossCheck, img)
+		span.SetAttributes(genai.AttrRead.String(cc.Read))
+		span.End()
+		checked <- cc
+	}()
+	r, err := readModel(ctx, img, url)
+	if err != nil {
+		return nil, err
+	}
+	cc := <-checked
+	r.SetCrossCheck(cc)
+	agree, ok := cc.Agrees(r.Read)
+	switch {
+	case !ok:
+		log.Printf("Not cross-checked: %s", cc.Error)
+		return r, nil
+	case agree || !config.CrossCheck.Verify || r.VerifiedRead != "":
+		return r, nil
+	}
+	log.Printf("The %s recognizer read %s, the model %s; reading again with self-verification", cc.Recognizer, cc.Read, r.Read)
+	v, err := readModel(genai.ContextWithSelfVerify(ctx), img, url)
+	if err != nil {
+		log.Printf("Error reading again with self-verification: %v", err)
+		return r, nil
+	}
+	v.SetCrossCheck(cc)
+	return v, nil
+}
+
+// readModel reads img, posted at url, within the learned region if any.
+func readModel(ctx context.Context, img []byte, url string) (*genai.GasMeterReadResult, error) {
+	if learner != nil {
+		return learner.Read(ctx, genaiClient, img, url)
+	}
+	return genaiClient.ReadGasGaugePicFromURL(ctx, url)
+}
+
+// readEnhanced makes the second attempt at img, of which the model read
+// nothing with first: enhanced with config.Enhance, posted again and read
+// through genaiClient like the first. If nothing is read again it returns
+// first.
+func readEnhanced(ctx context.Context, img []byte, first error) (*genai.GasMeterReadResult, error) {
+	enhanced, err := config.Enhance.Apply(img)
+	if err != nil {
+		log.Printf("Error enhancing unreadable image: %v", err)
+		return nil, first
+	}
+	u, err := conciergeClient.PostImage(bytes.NewReader(enhanced), "image/jpeg")
+	if err != nil {
+		log.Printf("Error posting enhanced image to concierge: %v", err)
+		return nil, first
+	}
+	log.Printf("Nothing read (%v); reading the image again enhanced with %s: %s", first, config.Enhance.Preset, u)
+	r, err := readImage(ctx, enhanced, u)
+	if errors.Is(err, genai.ErrEmptyReading) {
+		log.Printf("Nothing read from the enhanced image either: %v", err)
+		return nil, first
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Enhanced = true
+	return r, nil
+}
+
+// readGaugeImage receives a meter image from r, archives it and reads it.
+// It logs why it fails; the error is for the image span.
+func readGaugeImage(ctx context.Context, r io.Reader) (*Luggage, error) {
+	_, span := tracer.Start(ctx, genai.SpanCapture)
+	imgBytes, err := io.ReadAll(genai.LimitImage(r, int64(config.MaxImageKB)<<10))
+	if err == nil && len(imgBytes) == 0 {
+		err = errors.New("empty image")
+	}
+	if err == nil {
+		imgBytes, err = imageDecoder.JPEG(imgBytes)
+	}
+	span.SetAttributes(genai.AttrImageSize.Int(len(imgBytes)))
+	genai.EndSpan(span, err)
+	if err != nil {
+		log.Printf("Error reading MQTT image stream: %v", err)
+		return nil, fmt.Errorf("%w: %w", errCapture, err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(genai.AttrImageSize.Int(len(imgBytes)))
+	archiveKey := archiveImage(config.Meter.ID, imgBytes)
+
+	_, span = tracer.Start(ctx, genai.SpanArchive)
+	srcImgStoredURL, err := conciergeClient.PostImage(bytes.NewReader(imgBytes), "image/jpeg")
+	genai.EndSpan(span, err)
+	if err != nil {
+		log.Printf("Error posting image to concierge: %v", err)
+		return nil, err
+	}
+	log.Printf("Posted image to concierge: %s", srcImgStoredURL)
+
+	readResult, err := readImage(ctx, imgBytes, srcImgStoredURL)
+	if errors.Is(err, genai.ErrEmptyReading) && config.Enhance.Enabled() {
+		readResult, err = readEnhanced(ctx, imgBytes, err)
+	}
+	if err != nil && config.StaleFallback && (errors.Is(err, genai.ErrCircuitOpen) || breaker.State() == genai.BreakerOpen) {
+		publishStale(srcImgStoredURL)
+	}
+	if err != nil {
+		checkTrips(err)
+	}
+	if errors.Is(err, genai.ErrWrongMeter) {
+		notifyWrongMeter(ctx, event.ReadFailed, err, imgBytes)
+	}
+	if errors.Is(err, genai.ErrCircuitOpen) {
+		// The image is archived; skip reading until the API recovers.
+		log.Printf("Skipping reading: %v", err)
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Error reading gauge image from URL: %v", err)
+		return nil, err
+	}
+	if readResult == nil {
+		log.Printf("Read result is nil")
+		return nil, errors.New("read result is nil")
+	}
+	log.Printf("Read result: %+v", readResult)
+
+	return &Luggage{
+		GasMeterReadResult: readResult,
+		SrcImageURL:        srcImgStoredURL,
+		ArchiveKey:         archiveKey,
+		Image:              imgBytes,
+	}, nil
+}
 
-		l := &Luggage{
-			GasMeterReadResult: readResult,
-			SrcImageURL:        srcImgStoredURL,
+// decodeImage makes JPEG of img, an image a command was given, with the
+// transcoder of c, for the commands that do not run the daemon.
+func decodeImage(c *Config, img []byte) ([]byte, error) {
+	d, err := c.ImageDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("set up transcoding: %w", err)
+	}
+	if img, err = d.JPEG(img); err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	return img, nil
+}
+
+// archiveImage stores img of meterID in the archive, if any, returning its
+// key. The upload runs in the background: a failure is logged and never
+// fails the reading.
+func archiveImage(meterID string, img []byte) string {
+	if archiver == nil {
+		return ""
+	}
+	key := archiver.Key(meterID, time.Now())
+	go func() {
+		if err := archiver.Archive(appCtx, key, img); err != nil {
+			log.Printf("Error archiving image: %v", err)
 		}
-		chLuggage <- l
 	}()
+	return key
+}
+
+// publish dispatches the accepted reading l, with its consumption: as
+// [event.ReadingWarning] if validators configured to warn failed on it,
+// telling why. Errors are logged: a sink that needs to catch up buffers the
+// reading.
+func publish(ctx context.Context, meterID string, l *Luggage) {
+	r := l.GasMeterReadResult
+	e := event.Event{Type: event.ReadingAccepted, Event: notify.Event{
+		Kind:     "reading",
+		Severity: notify.Info,
+		MeterID:  meterID,
+		Time:     r.ReadAt,
+		Message:  "Reading " + r.Read + " accepted",
+		Data:     r,
+	}, Reading: r}
+	if len(r.Warnings) > 0 {
+		e.Type, e.Kind, e.Severity = event.ReadingWarning, "validation", notify.Warning
+		e.Message = "Reading " + r.Read + " published with warnings: " + strings.Join(r.Warnings, "; ")
+		e.Image = l.Image
+	}
+	if l.Consumption != nil {
+		e.Consumption = []sink.Consumption{{MeterID: meterID, At: r.ReadAt, Usage: *l.Consumption, Cumulative: l.Cumulative}}
+	}
+	if err := events.Dispatch(ctx, e); err != nil {
+		log.Printf("Error publishing reading: %v", err)
+	}
+}
+
+// endImageSpan ends the image span of l, if any, with err, and tells the
+// cycle of l the outcome.
+func endImageSpan(l *Luggage, err error) {
+	if l.span != nil {
+		genai.EndSpan(l.span, err)
+	}
+	if l.done != nil {
+		l.done <- err
+	}
+}
+
+// notifyWrongMeter raises the rejection of a reading whose serial number does
+// not match the configured one: the camera most likely looks at another meter.
+// typ tells whether the client or the validators rejected it.
+func notifyWrongMeter(ctx context.Context, typ event.Type, readErr error, img []byte) {
+	err := events.Dispatch(ctx, event.Event{Type: typ, Event: notify.Event{
+		Kind:     "wrong_meter",
+		Severity: notify.Critical,
+		MeterID:  config.Meter.ID,
+		Time:     time.Now(),
+		Message:  fmt.Sprintf("Reading rejected, the camera may be looking at another meter: %v", readErr),
+		Image:    img,
+	}})
+	if err != nil {
+		log.Printf("Error notifying wrong meter: %v", err)
+	}
+}
+
+// rejectReading logs and notifies the rejection of l by the validators, and
+// moves the client back to prev, the reading before it, so that the next
+// reading is not checked against the rejected one.
+func rejectReading(ctx context.Context, seeder genai.Seeder, prev *genai.GasMeterReadResult, l *Luggage, err error) {
+	log.Printf("Rejected reading %s: %v", l.Read, err)
+	if seeder != nil && prev != nil {
+		if err := seeder.SeedReading(prev); err != nil {
+			log.Printf("Error restoring previous reading: %v", err)
+		}
+	}
+	if errors.Is(err, genai.ErrWrongMeter) {
+		notifyWrongMeter(ctx, event.ReadingRejected, err, l.Image)
+		return
+	}
+	if l.retrying && l.source.recapture.Retryable(err) {
+		return // not final: the cycle re-captures
+	}
+	nerr := events.Dispatch(ctx, event.Event{Type: event.ReadingRejected, Event: notify.Event{
+		Kind:     "rejected",
+		Severity: notify.Warning,
+		MeterID:  config.Meter.ID,
+		Time:     l.ReadAt,
+		Message:  fmt.Sprintf("Reading %s rejected: %v", l.Read, err),
+		Data:     l.GasMeterReadResult,
+		Image:    l.Image,
+	}})
+	if nerr != nil {
+		log.Printf("Error notifying rejected reading: %v", nerr)
+	}
+}
+
+// notifyAmbiguous notifies about a reading with guessed digits, naming the
+// image issue the model reported with it, if any: an issue points at the
+// camera, none at the prompt.
+func notifyAmbiguous(ctx context.Context, meterID string, r *genai.GasMeterReadResult, img []byte) {
+	msg := "Ambiguous reading " + r.Read + ", digits guessed"
+	if r.Issue != nil {
+		msg += "; image issue: " + r.Issue.String()
+	} else {
+		msg += "; no image issue reported"
+	}
+	err := events.Dispatch(ctx, event.Event{Type: event.ReadingWarning, Event: notify.Event{
+		Kind:     "ambiguous",
+		Severity: notify.Info,
+		MeterID:  meterID,
+		Time:     r.ReadAt,
+		Message:  msg,
+		Data:     r,
+		Image:    img,
+	}})
+	if err != nil {
+		log.Printf("Error notifying ambiguous reading: %v", err)
+	}
+}
+
+// gapBefore returns the time from prev, the previous stored reading, to cur
+// if it exceeds threshold, and 0 otherwise or without a threshold.
+func gapBefore(prev, cur *genai.GasMeterReadResult, threshold time.Duration) time.Duration {
+	if threshold <= 0 || prev == nil || prev.ReadAt.IsZero() || cur.ReadAt.IsZero() {
+		return 0
+	}
+	if d := cur.ReadAt.Sub(prev.ReadAt); d > threshold {
+		return d
+	}
+	return 0
+}
+
+// notifyGap notifies that no reading was stored between prev and r.
+func notifyGap(ctx context.Context, meterID string, prev, r *genai.GasMeterReadResult) {
+	err := events.Dispatch(ctx, event.Event{Type: event.Gap, Event: notify.Event{
+		Kind:     "gap",
+		Severity: notify.Warning,
+		MeterID:  meterID,
+		Time:     r.ReadAt,
+		Message: fmt.Sprintf("No readings for %s since %s (%s to %s); the consumption in between is %s",
+			r.GapBefore, prev.ReadAt.Format(time.RFC3339), prev.Read, r.Read, gapAttributionText(config.Gaps.Attribution)),
+		Data: map[string]any{"from": prev.ReadAt, "to": r.ReadAt, "gap_before": r.GapBefore},
+	}})
+	if err != nil {
+		log.Printf("Error notifying gap: %v", err)
+	}
+}
+
+// logGap logs the gap between prev and r, which is stored, to the event log
+// of s, if it has one.
+func logGap(ctx context.Context, s store.Store, meterID string, prev, r *genai.GasMeterReadResult) {
+	el, ok := s.(store.EventLog)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(map[string]any{"from": prev.ReadAt, "to": r.ReadAt, "gap_before": r.GapBefore})
+	if err == nil {
+		_, err = el.LogEvent(ctx, store.Event{Type: store.EventGap, MeterID: meterID, Time: time.Now(), Reading: r, Data: data})
+	}
+	if err != nil {
+		log.Printf("Error logging gap: %v", err)
+	}
+}
+
+// gapAttributionText describes an attribution of gaps.attribution.
+func gapAttributionText(attribution string) string {
+	if attribution == billing.GapAtEnd {
+		return "counted at the reading after the gap"
+	}
+	return "spread evenly over the gap"
+}
+
+// notifySourceDown notifies that src, the primary camera, has been failing
+// since its last accepted image at since.
+func notifySourceDown(ctx context.Context, meterID string, src *imageSource, since time.Time) {
+	h := cameras.Health()[src.name]
+	err := events.Dispatch(ctx, event.Event{Type: event.SourceDown, Event: notify.Event{
+		Kind:     "source_down",
+		Severity: notify.Warning,
+		MeterID:  meterID,
+		Time:     time.Now(),
+		Message: fmt.Sprintf("Primary camera %s has been failing since %s (%d failures, last image %s); reading from the next source",
+			src.name, since.Format(time.RFC3339), h.Failures, formatTime(h.LastImage)),
+		Data: map[string]any{"source": src.name, "since": since, "health": h},
+	}})
+	if err != nil {
+		log.Printf("Error notifying failing source: %v", err)
+	}
+}
+
+// formatTime formats t in RFC 3339, or "never" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// checkAnomaly notifies about r if its consumption is unusually high.
+func checkAnomaly(ctx context.Context, a *anomaly.Analyzer, meterID string, r *genai.GasMeterReadResult, img []byte) {
+	an, err := a.Check(ctx, meterID, r)
+	if err != nil {
+		log.Printf("Error checking consumption: %v", err)
+		return
+	}
+	if an == nil {
+		return
+	}
+	err = events.Dispatch(ctx, event.Event{Type: event.AnomalyDetected, Event: notify.Event{
+		Kind:     "anomaly",
+		Severity: notify.Warning,
+		MeterID:  meterID,
+		Time:     r.ReadAt,
+		Message:  "Unusually high consumption: " + an.String(),
+		Data:     an,
+		Localize: func(l numfmt.Locale) string { return "Unusually high consumption: " + an.Format(l) },
+		Image:    img,
+	}})
+	if err != nil {
+		log.Printf("Error notifying anomaly: %v", err)
+	}
+}
+
+// checkLeak notifies about flow during the idle window on consecutive nights.
+func checkLeak(ctx context.Context, d *anomaly.LeakDetector, meterID string, now time.Time, img []byte) {
+	leak, err := d.Check(ctx, meterID, now)
+	if err != nil {
+		log.Printf("Error checking overnight flow: %v", err)
+		return
+	}
+	if leak == nil {
+		return
+	}
+	err = events.Dispatch(ctx, event.Event{Type: event.AnomalyDetected, Event: notify.Event{
+		Kind:     "leak",
+		Severity: notify.Critical,
+		MeterID:  meterID,
+		Time:     now,
+		Message:  "Possible gas leak: " + leak.String(),
+		Data:     leak,
+		Localize: func(l numfmt.Locale) string { return "Possible gas leak: " + leak.Format(l) },
+		Image:    img,
+	}})
+	if err != nil {
+		log.Printf("Error notifying leak: %v", err)
+	}
+}
+
+// readinessChecks returns the configured checks of /readyz.
+func readinessChecks(mqttClient *mqttdump.Client, started time.Time) []ReadyCheck {
+	var checks []ReadyCheck
+	for _, name := range config.ReadyChecks() {
+		switch name {
+		case checkStore:
+			checks = append(checks, storeCheck(history, config.Meter.ID))
+		case checkReading:
+			last := func() time.Time {
+				if ns := lastReadingAt.Load(); ns != 0 {
+					return time.Unix(0, ns)
+				}
+				return time.Time{}
+			}
+			checks = append(checks, readingCheck(last, config.MaxReadingAge(), started, genai.RealClock))
+		case checkBreaker:
+			checks = append(checks, breakerCheck(breaker))
+		case checkMQTT:
+			checks = append(checks, mqttCheck(mqttClient.Connected))
+		}
+	}
+	return checks
+}
 
-	return pw
+// checkTrips notifies once each time repeated failed readings open the breaker.
+func checkTrips(err error) {
+	trips := breaker.Trips()
+	if seen := notifiedTrips.Load(); trips <= seen || !notifiedTrips.CompareAndSwap(seen, trips) {
+		return
+	}
+	err = events.Dispatch(appCtx, event.Event{Type: event.ReadFailed, Event: notify.Event{
+		Kind:     "failures",
+		Severity: notify.Critical,
+		MeterID:  config.Meter.ID,
+		Time:     time.Now(),
+		Message:  fmt.Sprintf("Readings keep failing, API calls are paused (trip %d): %v", trips, err),
+	}})
+	if err != nil {
+		log.Printf("Error notifying failures: %v", err)
+	}
+}
+
+// sendDigest notifies the report of the period p containing at.
+func sendDigest(ctx context.Context, cfg report.Config, meterID string, p report.Period, at, now time.Time) {
+	r, err := report.Generate(ctx, history, cfg, meterID, p, at, now)
+	if err != nil {
+		log.Printf("Error generating digest: %v", err)
+		return
+	}
+	if err := events.Dispatch(ctx, event.Event{Type: event.Digest, Event: r.Event()}); err != nil {
+		log.Printf("Error notifying digest: %v", err)
+	}
+}
+
+// publishStale republishes the last accepted reading flagged as stale so the
+// sensor stays available while the vision API is down. It never touches the
+// store or the client's previous reading.
+func publishStale(srcImgStoredURL string) {
+	last, err := lastReadings.Get(appCtx, config.Meter.ID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Error loading last reading: %v", err)
+		}
+		return
+	}
+
+	chLuggage <- &Luggage{
+		GasMeterReadResult: last.AsStale(),
+		SrcImageURL:        srcImgStoredURL,
+	}
 }
 
 // func mqttFileDumpSubHandler() io.WriteCloser {
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// maintenanceRequest is the body of beginning maintenance: until when, or
+// for how long, and why. Start is the value the new meter starts at.
+type maintenanceRequest struct {
+	Until time.Time `json:"until,omitzero"`
+	For   string    `json:"for,omitempty"` // e.g. "48h"
+	Note  string    `json:"note,omitempty"`
+	Start string    `json:"start,omitempty"`
+}
+
+// state returns the maintenance req asks for from now on.
+func (req maintenanceRequest) state(now time.Time) (maintenance.State, error) {
+	s := maintenance.State{Since: now, Until: req.Until, Note: req.Note, Start: req.Start}
+	if req.For != "" {
+		d, err := time.ParseDuration(req.For)
+		if err != nil || d <= 0 {
+			return s, fmt.Errorf("for: want a positive duration, got %q", req.For)
+		}
+		s.Until = now.Add(d)
+	}
+	return s, nil
+}
+
+// maintenanceStatus is the maintenance of a meter as served.
+type maintenanceStatus struct {
+	Meter  string `json:"meter"`
+	Active bool   `json:"active"`
+	maintenance.Record
+}
+
+// Maintenance serves the maintenance mode of the meter at
+// /v1/meters/:id/maintenance: GET answers the [maintenanceStatus], POST
+// begins maintenance as the JSON [maintenanceRequest] asks and DELETE ends
+// it, with the start of the new meter in the query. The routes of POST and
+// DELETE need the admin scope, see [Auth].
+type Maintenance struct {
+	Keeper *maintenance.Keeper
+	Meter  genai.Meter
+	Clock  genai.Clock // default genai.RealClock
+}
+
+func (h *Maintenance) now() time.Time {
+	if h.Clock == nil {
+		return genai.RealClock.Now()
+	}
+	return h.Clock.Now()
+}
+
+// Handler implements the endpoint.
+func (h *Maintenance) Handler(c *gin.Context) {
+	if id := c.Param("id"); id != h.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	ctx, now := c.Request.Context(), h.now()
+	var err error
+	switch c.Request.Method {
+	case http.MethodPost:
+		var req maintenanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		s, err := req.state(now)
+		if err == nil {
+			s, err = h.Keeper.Begin(ctx, h.Meter.ID, s)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Meter %s in maintenance until %s with token %s: %s", h.Meter.ID, maintenanceUntil(s), tokenName(c), s.Note)
+	case http.MethodDelete:
+		_, err = h.Keeper.End(ctx, h.Meter.ID, now, c.Query("start"))
+		switch {
+		case errors.Is(err, maintenance.ErrNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Ended the maintenance of meter %s with token %s", h.Meter.ID, tokenName(c))
+	}
+	status, err := loadMaintenance(ctx, h.Keeper, h.Meter.ID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func loadMaintenance(ctx context.Context, k *maintenance.Keeper, meterID string, now time.Time) (maintenanceStatus, error) {
+	rec, err := k.Load(ctx, meterID)
+	if err != nil {
+		return maintenanceStatus{}, err
+	}
+	return maintenanceStatus{Meter: meterID, Active: rec.Current != nil && rec.Current.Active(now), Record: rec}, nil
+}
+
+// maintenanceUntil formats when s ends by itself.
+func maintenanceUntil(s maintenance.State) string {
+	if s.Until.IsZero() {
+		return "ended"
+	}
+	return s.Until.Format(time.RFC3339)
+}
+
+// suppressAlerts returns the [event.Dispatcher.Suppress] holding back the
+// alerts of meters k has in maintenance by clock, logging them instead.
+func suppressAlerts(k *maintenance.Keeper, clock genai.Clock) func(e event.Event) bool {
+	return func(e event.Event) bool {
+		active, err := k.Active(context.Background(), e.MeterID, clock.Now())
+		if err != nil {
+			log.Printf("Error loading maintenance: %v", err)
+		}
+		if active {
+			log.Printf("Not notifying %s of meter %s in maintenance: %s", e.Kind, e.MeterID, e.Message)
+		}
+		return active
+	}
+}
+
+// runMaintenance implements the `maintenance` subcommand: it begins or ends
+// the maintenance of a meter, or shows it, through the API of a running
+// daemon if -addr is set and in the store file otherwise.
+func runMaintenance(args []string) error {
+	fs := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	begin := fs.Bool("begin", false, "Begin maintenance")
+	end := fs.Bool("end", false, "End maintenance: the next reading is of the new meter")
+	forFlag := fs.String("for", "", "With -begin, end maintenance after this long, e.g. 48h (default: when ended)")
+	untilFlag := fs.String("until", "", "With -begin, end maintenance at this RFC 3339 time")
+	note := fs.String("note", "", "With -begin, why the meter is in maintenance")
+	start := fs.String("start", "", "The value the new meter starts at (default: zero)")
+	addr := fs.String("addr", "", "Address of the running daemon, e.g. http://localhost:8080")
+	token := fs.String("token", os.Getenv("MQVISION_TOKEN"), "Admin API token for -addr (default: $MQVISION_TOKEN)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s maintenance [-begin | -end] [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *begin && *end {
+		fs.Usage()
+		return errors.New("maintenance: -begin or -end, not both")
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	req := maintenanceRequest{For: *forFlag, Note: *note, Start: *start}
+	if *untilFlag != "" {
+		if req.Until, err = time.Parse(time.RFC3339, *untilFlag); err != nil {
+			return fmt.Errorf("until: %w", err)
+		}
+	}
+	method := http.MethodGet
+	switch {
+	case *begin:
+		method = http.MethodPost
+	case *end:
+		method = http.MethodDelete
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var status maintenanceStatus
+	if *addr != "" {
+		status, err = requestMaintenance(ctx, *addr, *token, *meterID, method, req)
+	} else {
+		status, err = maintainStoreFile(ctx, config, *meterID, method, req)
+	}
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(out))
+	return err
+}
+
+// maintainStoreFile applies method to the maintenance saved in the store
+// file. It must not run while the daemon has the file open, as
+// [correctStoreFile].
+func maintainStoreFile(ctx context.Context, config *Config, meterID, method string, req maintenanceRequest) (maintenanceStatus, error) {
+	if config.Store.Path == "" {
+		return maintenanceStatus{}, fmt.Errorf("maintenance: needs store.path or -addr")
+	}
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return maintenanceStatus{}, fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	m := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	k, now := maintenance.NewKeeper(s, m), time.Now()
+	switch method {
+	case http.MethodPost:
+		st, err := req.state(now)
+		if err == nil {
+			_, err = k.Begin(ctx, meterID, st)
+		}
+		if err != nil {
+			return maintenanceStatus{}, fmt.Errorf("begin maintenance: %w", err)
+		}
+	case http.MethodDelete:
+		if _, err := k.End(ctx, meterID, now, req.Start); err != nil {
+			return maintenanceStatus{}, fmt.Errorf("end maintenance: %w", err)
+		}
+	}
+	return loadMaintenance(ctx, k, meterID, now)
+}
+
+// requestMaintenance applies method to the maintenance through the API of
+// the daemon at addr.
+func requestMaintenance(ctx context.Context, addr, token, meterID, method string, req maintenanceRequest) (maintenanceStatus, error) {
+	u := strings.TrimRight(addr, "/") + "/v1/meters/" + url.PathEscape(meterID) + "/maintenance"
+	var body io.Reader
+	switch method {
+	case http.MethodPost:
+		b, err := json.Marshal(req)
+		if err != nil {
+			return maintenanceStatus{}, err
+		}
+		body = bytes.NewReader(b)
+	case http.MethodDelete:
+		if req.Start != "" {
+			u += "?start=" + url.QueryEscape(req.Start)
+		}
+	}
+	hreq, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return maintenanceStatus{}, fmt.Errorf("create request: %w", err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	hreq.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		return maintenanceStatus{}, fmt.Errorf("request maintenance: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return maintenanceStatus{}, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return maintenanceStatus{}, fmt.Errorf("request maintenance: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	var status maintenanceStatus
+	if err := json.Unmarshal(b, &status); err != nil {
+		return maintenanceStatus{}, fmt.Errorf("decode response: %w", err)
+	}
+	return status, nil
+}
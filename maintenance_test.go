@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/maintenance"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	clock := genaitest.NewClock(at)
+	k := maintenance.NewKeeper(store.NewMemory(), meter)
+	h := &Maintenance{Keeper: k, Meter: meter, Clock: clock}
+	auth, err := NewAuth([]APIToken{{Name: "ops", Hash: hashToken("secret"), Scopes: []string{scopeAdmin}}}, nil)
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.GET("/v1/meters/:id/maintenance", auth.Require(scopeRead), h.Handler)
+	router.POST("/v1/meters/:id/maintenance", auth.Require(scopeAdmin), h.Handler)
+	router.DELETE("/v1/meters/:id/maintenance", auth.Require(scopeAdmin), h.Handler)
+	do := func(method, path, token, body string) (*httptest.ResponseRecorder, maintenanceStatus) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var status maintenanceStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w, status
+	}
+
+	tests := []struct {
+		name, method, path, token, body string
+		code                            int
+	}{
+		{"no token", http.MethodPost, "/v1/meters/home/maintenance", "", `{"for":"48h"}`, http.StatusUnauthorized},
+		{"unknown meter", http.MethodPost, "/v1/meters/cabin/maintenance", "secret", `{"for":"48h"}`, http.StatusNotFound},
+		{"bad duration", http.MethodPost, "/v1/meters/home/maintenance", "secret", `{"for":"-1h"}`, http.StatusBadRequest},
+		{"bad start", http.MethodPost, "/v1/meters/home/maintenance", "secret", `{"start":"1"}`, http.StatusBadRequest},
+		{"not in maintenance", http.MethodDelete, "/v1/meters/home/maintenance", "secret", "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if w, _ := do(tt.method, tt.path, tt.token, tt.body); w.Code != tt.code {
+			t.Fatalf("%s: status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body)
+		}
+	}
+
+	w, status := do(http.MethodPost, "/v1/meters/home/maintenance", "secret", `{"for":"48h","note":"meter exchange"}`)
+	if w.Code != http.StatusOK || !status.Active || status.Current == nil || !status.Current.Until.Equal(at.Add(48*time.Hour)) || status.Current.Note != "meter exchange" {
+		t.Fatalf("begin: status %d, %+v", w.Code, status)
+	}
+	suppress := suppressAlerts(k, clock)
+	if !suppress(event.Event{Type: event.Gap, Event: notify.Event{MeterID: "home"}}) || suppress(event.Event{Type: event.Gap, Event: notify.Event{MeterID: "cabin"}}) {
+		t.Fatal("want the alerts of the meter in maintenance suppressed, and only those")
+	}
+
+	clock.Advance(time.Hour)
+	w, status = do(http.MethodDelete, "/v1/meters/home/maintenance?start=00000.100", "secret", "")
+	if w.Code != http.StatusOK || status.Active || status.Current == nil || status.Current.Start != "00000.100" || !status.Current.Ended.Equal(at.Add(time.Hour)) {
+		t.Fatalf("end: status %d, %+v", w.Code, status)
+	}
+	if w, status := do(http.MethodGet, "/v1/meters/home/maintenance", "secret", ""); w.Code != http.StatusOK || status.Meter != "home" || status.Active {
+		t.Fatalf("get: status %d, %+v", w.Code, status)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/twmb/franz-go/pkg/kfake"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/concierge"
+	"github.com/suapapa/mqvision/internal/egress"
+	"github.com/suapapa/mqvision/internal/egress/egresstest"
+	"github.com/suapapa/mqvision/internal/export"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+	"github.com/suapapa/mqvision/internal/pushgateway"
+	"github.com/suapapa/mqvision/internal/sink"
+	"github.com/suapapa/mqvision/internal/telemetry"
+)
+
+// TestNetworkTransport has every outbound client reach a server of a
+// private CA, trusted only through network.ca_file, through network.proxy.
+func TestNetworkTransport(t *testing.T) {
+	// The S3 archive takes its credentials from the environment.
+	dir := t.TempDir()
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID": "minioadmin", "AWS_SECRET_ACCESS_KEY": "minioadmin", "AWS_SESSION_TOKEN": "",
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "credentials"), "AWS_CONFIG_FILE": filepath.Join(dir, "config"),
+		"AWS_PROFILE": "", "AWS_CA_BUNDLE": "", "AWS_EC2_METADATA_DISABLED": "true",
+	} {
+		t.Setenv(k, v)
+	}
+
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/luggage"):
+			w.Write([]byte(`{"key":"k"}`))
+		case r.URL.Path == "/api/websocket":
+			serveHomeAssistant(w, r)
+		}
+	}))
+	defer srv.Close()
+	ca := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	serverTLS := &tls.Config{Certificates: srv.TLS.Certificates}
+	mails := make(chan string, 1)
+	smtpAddr := serveSMTP(t, serverTLS, mails)
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "meters"), kfake.TLS(serverTLS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	proxy := egresstest.NewProxy()
+	defer proxy.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	smtpHost, smtpPort, _ := net.SplitHostPort(smtpAddr)
+	port, _ := net.LookupPort("tcp", smtpPort)
+	c := &Config{Network: egress.Config{CAFile: ca, Proxy: proxy.URL}}
+	c.Sinks.Influx = &sink.InfluxConfig{URL: srv.URL, Bucket: "gas"}
+	c.Sinks.Webhook = &sink.WebhookConfig{URL: srv.URL + "/hooks"}
+	c.Sinks.Kafka = &sink.KafkaConfig{Brokers: cluster.ListenAddrs(), Topic: "meters", TLS: &sink.KafkaTLS{CAFile: ca}}
+	c.Pushgateway = &pushgateway.Config{URL: srv.URL, Instance: "pi"}
+	c.Export.HomeAssistant = &export.HomeAssistantConfig{URL: srv.URL, Token: "t"}
+	c.Archive = &archive.Config{Backend: archive.BackendS3, Bucket: "meter", Endpoint: srv.URL, PathStyle: true}
+	c.Email = &notify.EmailConfig{Host: smtpHost, Port: port, TLS: notify.EmailTLS, From: "meter@example.com", To: []string{"me@example.com"}}
+	if err := sink.NewWebhook(*c.Sinks.Webhook).Publish(ctx, "home", &genai.GasMeterReadResult{Read: "01234.000"}); err == nil {
+		t.Fatal("the webhook trusted the private CA before network was applied")
+	}
+	if err := c.useNetwork(); err != nil {
+		t.Fatalf("useNetwork: %v", err)
+	}
+
+	r := &genai.GasMeterReadResult{ID: "a", Read: "01234.000", ReadAt: time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)}
+	if err := sink.NewWebhook(*c.Sinks.Webhook).Publish(ctx, "home", r); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	if err := sink.NewInflux(*c.Sinks.Influx).Publish(ctx, "home", r); err != nil {
+		t.Fatalf("influx: %v", err)
+	}
+	if err := pushgateway.New(*c.Pushgateway).Delete(ctx); err != nil {
+		t.Fatalf("pushgateway: %v", err)
+	}
+	if _, err := concierge.NewClient(srv.URL, "t", c.transport).PostImage(strings.NewReader("jpeg"), "image/jpeg"); err != nil {
+		t.Fatalf("concierge: %v", err)
+	}
+	o := genai.NewOptions(c.GenAIOptions()...)
+	resp, err := o.Client().Get(srv.URL + "/v1/models")
+	if err != nil {
+		t.Fatalf("vision client: %v", err)
+	}
+	resp.Body.Close()
+	rows := []export.Row{{Start: r.ReadAt.Truncate(time.Hour), State: 1234, Sum: 0}}
+	if err := export.NewHomeAssistant(*c.Export.HomeAssistant, o.Meter).Export(ctx, rows); err != nil {
+		t.Fatalf("homeassistant: %v", err)
+	}
+	store, err := archive.New(ctx, *c.Archive)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if err := store.Archive(ctx, store.Key("home", r.ReadAt), []byte("jpeg")); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	spans := []sdktrace.ReadOnlySpan{tracetest.SpanStub{Name: "read"}.Snapshot()}
+	if err := telemetry.NewOTLPExporter(telemetry.OTLPConfig{Endpoint: srv.URL + "/v1/traces", Transport: c.transport}).ExportSpans(ctx, spans); err != nil {
+		t.Fatalf("otlp: %v", err)
+	}
+	mu.Lock()
+	if len(paths) != 8 {
+		t.Errorf("requests to %q, want one of each client", paths)
+	}
+	mu.Unlock()
+
+	email, err := notify.NewEmail(*c.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := email.Notify(ctx, notify.Event{Kind: "anomaly", Severity: notify.Critical, MeterID: "home", Time: r.ReadAt, Message: "leak"}); err != nil {
+		t.Fatalf("email: %v", err)
+	}
+	if mail := <-mails; !strings.Contains(mail, "leak") {
+		t.Errorf("mail without the event:\n%s", mail)
+	}
+
+	p, err := sink.NewKafkaProducer(*c.Sinks.Kafka)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.NewKafka(p, *c.Sinks.Kafka).Publish(ctx, "home", r); err != nil {
+		t.Fatalf("kafka: %v", err)
+	}
+	p.Close()
+
+	tunnels := proxy.Tunnels()
+	for _, addr := range append([]string{srv.Listener.Addr().String(), smtpAddr}, cluster.ListenAddrs()...) {
+		if !slices.Contains(tunnels, addr) {
+			t.Errorf("no tunnel to %s through the proxy: %q", addr, tunnels)
+		}
+	}
+}
+
+// serveHomeAssistant answers the statistics imports of a Home Assistant
+// websocket.
+func serveHomeAssistant(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	ctx := r.Context()
+	var msg map[string]any
+	if wsjson.Write(ctx, conn, map[string]any{"type": "auth_required"}) != nil || wsjson.Read(ctx, conn, &msg) != nil {
+		return
+	}
+	if wsjson.Write(ctx, conn, map[string]any{"type": "auth_ok"}) != nil {
+		return
+	}
+	for {
+		msg = nil
+		if wsjson.Read(ctx, conn, &msg) != nil {
+			return
+		}
+		if wsjson.Write(ctx, conn, map[string]any{"id": msg["id"], "type": "result", "success": true}) != nil {
+			return
+		}
+	}
+}
+
+// serveSMTP accepts SMTP sessions over implicit TLS and sends the data of
+// their mails to mails. It returns the address listened on.
+func serveSMTP(t *testing.T, cfg *tls.Config, mails chan<- string) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tp := textproto.NewConn(conn)
+				tp.PrintfLine("220 mail.example.com ESMTP")
+				for {
+					line, err := tp.ReadLine()
+					if err != nil {
+						return
+					}
+					switch verb, _, _ := strings.Cut(strings.ToUpper(line), " "); verb {
+					case "DATA":
+						tp.PrintfLine("354 go ahead")
+						data, err := tp.ReadDotBytes()
+						if err != nil {
+							return
+						}
+						mails <- string(data)
+						tp.PrintfLine("250 queued")
+					case "QUIT":
+						tp.PrintfLine("221 bye")
+						return
+					default:
+						tp.PrintfLine("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+)
+
+// callRecorder is a [genai.Auditor] keeping the calls of a reading, with
+// the raw model output.
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []genai.AuditEntry
+}
+
+func (r *callRecorder) Audit(e genai.AuditEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, e)
+}
+
+// take returns the calls recorded since the last take.
+func (r *callRecorder) take() []genai.AuditEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+// promptRun is one reading of `prompt test`.
+type promptRun struct {
+	Name   string
+	Result *genai.GasMeterReadResult // nil if Err
+	Err    error
+	Calls  []genai.AuditEntry
+	Took   time.Duration
+}
+
+// usage adds up the token usage of the calls of r.
+func (r *promptRun) usage() genai.Usage {
+	var u genai.Usage
+	for _, c := range r.Calls {
+		u.InputTokens += c.Usage.InputTokens
+		u.OutputTokens += c.Usage.OutputTokens
+		u.CachedTokens += c.Usage.CachedTokens
+	}
+	return u
+}
+
+// runPrompt implements the `prompt test` subcommand: it reads one image
+// with the given prompt files and prints the raw model output, the parsed
+// result, its timing and the token usage. With -diff it also reads the
+// image with the configured prompts and prints both side by side. The
+// clients are stateless and the store is not opened, so the daemon's
+// previous reading and history are left alone.
+func runPrompt(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("usage: %s prompt test [flags]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("prompt test", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	image := fs.String("image", "", "Image to read")
+	system := fs.String("system", "", "System prompt file (default: config)")
+	user := fs.String("user", "", "Image prompt file (default: config)")
+	model := fs.String("model", "", "Model (default: config)")
+	diff := fs.Bool("diff", false, "Also read with the configured prompts and model and compare")
+	interval := fs.Duration("interval", time.Second, "Minimum interval between API calls")
+	shareSafe := fs.Bool("share-safe", false, "Redact the serial number, meter ID and image source in the output, for sharing it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s prompt test -image photo.jpg [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if *image == "" {
+		fs.Usage()
+		return fmt.Errorf("prompt test: needs -image")
+	}
+
+	base, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	jpg, err := os.ReadFile(*image)
+	if err != nil {
+		return fmt.Errorf("read image: %w", err)
+	}
+	if jpg, err = decodeImage(base, jpg); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	limiter := genai.NewLimiter(*interval)
+	rec := &callRecorder{}
+	var runs []promptRun
+	if *diff {
+		configured, err := newCompareVariant(ctx, *base, "", "", "", limiter, genai.WithAuditor(rec))
+		if err != nil {
+			return fmt.Errorf("configured prompts: %w", err)
+		}
+		defer configured.Close()
+		runs = append(runs, readWithPrompt(ctx, "configured", configured, rec, jpg))
+	}
+	candidate, err := newCompareVariant(ctx, *base, *system, *user, *model, limiter, genai.WithAuditor(rec))
+	if err != nil {
+		return err
+	}
+	defer candidate.Close()
+	runs = append(runs, readWithPrompt(ctx, "candidate", candidate, rec, jpg))
+	var out io.Writer = os.Stdout
+	if *shareSafe {
+		if out, err = shareSafeOutput(base, out, runs); err != nil {
+			return err
+		}
+	}
+	return writePromptRuns(out, runs)
+}
+
+// readWithPrompt reads jpg with c, a client auditing to rec.
+func readWithPrompt(ctx context.Context, name string, c genai.VisionClient, rec *callRecorder, jpg []byte) promptRun {
+	start := time.Now()
+	res, err := c.ReadGasGaugePic(ctx, bytes.NewReader(jpg))
+	return promptRun{Name: name, Result: res, Err: err, Calls: rec.take(), Took: time.Since(start)}
+}
+
+// writePromptRuns prints every run in full and, for more than one, a side
+// by side summary of them.
+func writePromptRuns(w io.Writer, runs []promptRun) error {
+	for _, r := range runs {
+		fmt.Fprintf(w, "== %s ==\n", r.Name)
+		for _, c := range r.Calls {
+			fmt.Fprintf(w, "Raw output of the %s call (%s, %s):\n", c.Call, c.Model, c.Latency)
+			if c.Error != "" {
+				fmt.Fprintf(w, "  error: %s\n", c.Error)
+			}
+			fmt.Fprintln(w, indent(c.Response))
+		}
+		if r.Err != nil {
+			fmt.Fprintf(w, "Error: %v\n", r.Err)
+		} else {
+			b, err := json.MarshalIndent(r.Result, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Result:\n%s\n", indent(string(b)))
+		}
+		fmt.Fprintf(w, "Timing: %s\n", promptTiming(r))
+		fmt.Fprintf(w, "Tokens: %s\n\n", promptTokens(r.usage()))
+	}
+	if len(runs) < 2 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := []struct {
+		name  string
+		value func(r promptRun) string
+	}{
+		{"", func(r promptRun) string { return r.Name }},
+		{"error", func(r promptRun) string {
+			if r.Err == nil {
+				return "-"
+			}
+			return genai.Truncate(r.Err.Error(), 60)
+		}},
+		{"read", resultField(func(res *genai.GasMeterReadResult) string { return res.Read })},
+		{"date", resultField(func(res *genai.GasMeterReadResult) string { return res.Date })},
+		{"issue", resultField(func(res *genai.GasMeterReadResult) string {
+			if res.Issue == nil {
+				return "-"
+			}
+			return res.Issue.String()
+		})},
+		{"ambiguous", resultField(func(res *genai.GasMeterReadResult) string { return strconv.FormatBool(res.Ambiguous) })},
+		{"model", resultField(func(res *genai.GasMeterReadResult) string { return res.Model })},
+		{"timing", promptTiming},
+		{"tokens", func(r promptRun) string { return promptTokens(r.usage()) }},
+	}
+	for _, row := range rows {
+		cells := []string{row.name}
+		for _, r := range runs {
+			cells = append(cells, row.value(r))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// resultField returns the cell of a run with f of its result, or "-" if it
+// failed.
+func resultField(f func(*genai.GasMeterReadResult) string) func(promptRun) string {
+	return func(r promptRun) string {
+		if r.Err != nil {
+			return "-"
+		}
+		return f(r.Result)
+	}
+}
+
+// promptTiming formats the phases of the reading of r, as reported by the
+// client, and the time the whole reading took.
+func promptTiming(r promptRun) string {
+	var parts []string
+	if r.Result != nil && r.Result.Timing != nil {
+		t := r.Result.Timing
+		for _, p := range []struct{ name, d string }{{"upload", t.Upload}, {"read", t.Read}, {"guess", t.Guess}, {"verify", t.Verify}} {
+			if p.d != "" {
+				parts = append(parts, p.name+"="+p.d)
+			}
+		}
+		if t.UploadAttempts > 1 {
+			parts = append(parts, fmt.Sprintf("upload_attempts=%d (%d bytes again)", t.UploadAttempts, t.UploadRetriedBytes))
+		}
+	}
+	return strings.Join(append(parts, "total="+r.Took.Round(time.Millisecond).String()), " ")
+}
+
+func promptTokens(u genai.Usage) string {
+	return fmt.Sprintf("%d in (%d cached), %d out", u.InputTokens, u.CachedTokens, u.OutputTokens)
+}
+
+// indent indents every line of s by two spaces.
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestPromptRuns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	rec := &callRecorder{}
+	client := genaitest.NewFakeReader(&genai.GasMeterReadResult{Read: "02924.457", Date: "2025-11-07 06:00", Timing: &genai.Timing{Read: "1.2s"}})
+	client.PushError(errors.New("model overloaded"))
+
+	// The fake does not audit: record its calls as a client would.
+	rec.Audit(genai.AuditEntry{Call: genai.CallRead, Model: "gpt-4o-mini", Response: `{"read":"02924.457"}`, Latency: "1.2s",
+		Usage: genai.Usage{InputTokens: 800, OutputTokens: 30}})
+	configured := readWithPrompt(ctx, "configured", client, rec, []byte("jpeg"))
+	candidate := readWithPrompt(ctx, "candidate", client, rec, []byte("jpeg"))
+	if len(configured.Calls) != 1 || configured.Result == nil || len(candidate.Calls) != 0 || candidate.Err == nil {
+		t.Fatalf("runs = %+v, %+v", configured, candidate)
+	}
+
+	var out bytes.Buffer
+	if err := writePromptRuns(&out, []promptRun{configured, candidate}); err != nil {
+		t.Fatalf("writePromptRuns: %v", err)
+	}
+	for _, want := range []string{
+		"== configured ==\nRaw output of the read call (gpt-4o-mini, 1.2s):\n  {\"read\":\"02924.457\"}\nResult:\n  {\n",
+		"Tokens: 800 in (0 cached), 30 out\n",
+		"== candidate ==\nError: model overloaded\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	table := map[string]string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if f := strings.Fields(line); len(f) > 0 {
+			table[f[0]] = strings.Join(f[1:], " ")
+		}
+	}
+	for row, want := range map[string]string{
+		"error":  "- model overloaded",
+		"read":   "02924.457 -",
+		"tokens": "800 in (0 cached), 30 out 0 in (0 cached), 0 out",
+	} {
+		if table[row] != want {
+			t.Fatalf("row %s = %q, want %q:\n%s", row, table[row], want, out.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// runProvenance implements the `provenance` subcommand: it prints a stored
+// reading and the readings it was read against, back as far as they are
+// stored, so that a guess resting on another guess shows.
+func runProvenance(args []string) error {
+	fs := flag.NewFlagSet("provenance", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s provenance [flags] READING-ID\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("provenance: needs a reading ID")
+	}
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("provenance: needs store.path")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	chain, err := s.Provenance(context.Background(), *meterID, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("reading %s of meter %s: %w", fs.Arg(0), *meterID, err)
+	}
+	printProvenance(os.Stdout, *meterID, chain)
+	return nil
+}
+
+// printProvenance prints chain, a reading of meterID followed by its
+// references, one per line, and why it ends early.
+func printProvenance(w io.Writer, meterID string, chain []*genai.GasMeterReadResult) {
+	for i, r := range chain {
+		fmt.Fprintf(w, "%d. %s %s %s %s\n", i, cmp.Or(r.ID, genai.ReadingID(meterID, r)), r.ReadAt.Local().Format(time.RFC3339), r.Read, cmp.Or(r.Provenance, "unknown"))
+	}
+	last := chain[len(chain)-1]
+	if last.ReferenceID != "" {
+		fmt.Fprintf(w, "Reference %s is not stored, or refers back to a reading above\n", last.ReferenceID)
+	}
+	for _, r := range chain {
+		if genai.Trusted(r.Provenance) {
+			return
+		}
+	}
+	fmt.Fprintln(w, "WARNING no trusted reading in the chain: every value above rests on a guess")
+}
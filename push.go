@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/pushgateway"
+)
+
+// pushMetrics pushes the metrics of run, and the counters of stats if set,
+// to cfg, or with cleanup deletes the group earlier runs pushed. Failures are
+// only logged: they do not change the outcome of the command.
+func pushMetrics(cfg *pushgateway.Config, stats genai.StatsReporter, run pushgateway.Run, cleanup bool) {
+	if cfg == nil {
+		if cleanup {
+			log.Printf("Not cleaning up metrics: no pushgateway configured")
+		}
+		return
+	}
+	c := pushgateway.New(*cfg)
+	if cleanup {
+		if err := c.Delete(context.Background()); err != nil {
+			log.Printf("Error deleting metrics from pushgateway: %v", err)
+			return
+		}
+		log.Printf("Deleted metrics group %s", cfg.GroupURL())
+		return
+	}
+	var fams []pushgateway.Family
+	if stats != nil {
+		fams = pushgateway.StatsFamilies(stats.Stats())
+	}
+	if err := c.Push(context.Background(), append(fams, run.Families()...)); err != nil {
+		log.Printf("Error pushing metrics to pushgateway: %v", err)
+		return
+	}
+	log.Printf("Pushed metrics to %s", cfg.GroupURL())
+}
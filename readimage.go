@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/openaicompat"
+)
+
+// Headers of POST /v1/read selecting the tenant's own API key and model.
+const (
+	headerVisionKey   = "X-Vision-Key"
+	headerVisionModel = "X-Vision-Model"
+)
+
+// maxReadImage bounds the image of POST /v1/read.
+const maxReadImage = 10 << 20
+
+// ReadImage serves POST /v1/read: it reads the meter image in the request
+// body and answers the result, without publishing or storing it. A request
+// with the X-Vision-Key header is read by a client of that API key and of
+// the model in X-Vision-Model (default Model) from Pool, so several
+// households can share the server with their own keys; the others by
+// Client, which must be stateless (see newReadClient). The key is never
+// logged or answered.
+type ReadImage struct {
+	Client genai.VisionClient
+	Pool   *genai.ClientPool // no pool: the key header is refused
+	Model  string
+}
+
+// Handler implements the endpoint.
+func (h *ReadImage) Handler(c *gin.Context) {
+	client := h.Client
+	if key := strings.TrimSpace(c.GetHeader(headerVisionKey)); key != "" {
+		if h.Pool == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": headerVisionKey + " is not supported"})
+			return
+		}
+		model := cmp.Or(strings.TrimSpace(c.GetHeader(headerVisionModel)), h.Model)
+		pooled, err := h.Pool.Get(c.Request.Context(), key, model)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("creating the client of model %q failed", model)})
+			return
+		}
+		defer pooled.Close()
+		client = pooled
+	} else if c.GetHeader(headerVisionModel) != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": headerVisionModel + " needs " + headerVisionKey})
+		return
+	}
+	if client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no vision client"})
+		return
+	}
+	if c.Request.ContentLength == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "empty image"})
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxReadImage)
+	res, err := client.ReadGasGaugePic(c.Request.Context(), body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// newReadClient returns the client of POST /v1/read without a key: set up
+// as the daemon's own client, with opts, but stateless, so that the images
+// posted are neither read against the meter's previous reading nor become
+// it.
+func newReadClient(ctx context.Context, c *Config, opts []genai.Option) (genai.VisionClient, error) {
+	return newVisionClient(ctx, c, append(slices.Clip(opts), genai.WithStateless())...)
+}
+
+// newClientPool returns the pool of the clients of POST /v1/read for the
+// API keys of its requests, set up as the daemon's own client but without
+// its history.
+func newClientPool(c *Config) *genai.ClientPool {
+	newClient := func(_ context.Context, apiKey, model string) (genai.VisionClient, error) {
+		return openaicompat.NewClient(c.OpenAICompat.BaseURL, apiKey, model, c.SystemPrompt, c.Prompt, visionOptions(c, nil)...)
+	}
+	return genai.NewClientPool(newClient, cmp.Or(c.API.Pool.MaxSize, 16), cmp.Or(c.API.Pool.Idle, 15*time.Minute))
+}
+
+// evictIdleEvery closes the idle clients of p every interval until ctx is
+// done, so the keys of households that stopped reading are not kept.
+func evictIdleEvery(ctx context.Context, p *genai.ClientPool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if n := p.EvictIdle(); n > 0 {
+				log.Printf("Closed %d idle pooled clients", n)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
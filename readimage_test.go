@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestReadImage(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	own := genaitest.NewFakeReader(&genai.GasMeterReadResult{Read: "01234.000", Model: "own"})
+	var (
+		mu      sync.Mutex
+		created []string
+	)
+	pool := genai.NewClientPool(func(_ context.Context, apiKey, model string) (genai.VisionClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		created = append(created, apiKey+"/"+model)
+		f := genaitest.NewFakeReader()
+		f.Generate = func(int) (*genai.GasMeterReadResult, error) {
+			return &genai.GasMeterReadResult{Read: "00042.000", Model: model}, nil
+		}
+		return f, nil
+	}, 0, 0)
+	defer pool.Close()
+	auth, err := NewAuth([]APIToken{
+		{Name: "camera", Hash: hashToken("mqv_camera"), Scopes: []string{scopeSubmit}},
+		{Name: "phone", Hash: hashToken("mqv_phone"), Scopes: []string{scopeRead}},
+	}, genaitest.NewClock(time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("NewAuth: %v", err)
+	}
+	router := gin.New()
+	router.POST("/v1/read", auth.Require(scopeSubmit), (&ReadImage{Client: own, Pool: pool, Model: "default-model"}).Handler)
+	router.POST("/nopool/read", (&ReadImage{Client: own}).Handler)
+	post := func(path, token string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("jpeg"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	model := func(w *httptest.ResponseRecorder) string {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var res genai.GasMeterReadResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return res.Model
+	}
+
+	if got := model(post("/v1/read", "mqv_camera", nil)); got != "own" {
+		t.Errorf("without a key: read by %q, want the daemon's client", got)
+	}
+	if got := model(post("/v1/read", "mqv_camera", map[string]string{headerVisionKey: "key-a"})); got != "default-model" {
+		t.Errorf("with a key: read by %q, want default-model", got)
+	}
+	if got := model(post("/v1/read", "mqv_camera", map[string]string{headerVisionKey: "key-a"})); got != "default-model" {
+		t.Errorf("again: read by %q, want default-model", got)
+	}
+	if got := model(post("/v1/read", "mqv_camera", map[string]string{headerVisionKey: "key-b", headerVisionModel: "other"})); got != "other" {
+		t.Errorf("with a model: read by %q, want other", got)
+	}
+	mu.Lock()
+	if want := []string{"key-a/default-model", "key-b/other"}; strings.Join(created, ",") != strings.Join(want, ",") {
+		t.Errorf("created %v, want %v", created, want)
+	}
+	mu.Unlock()
+	if s := pool.Stats(); s.Size != 2 || s.InUse != 0 || s.Creates != 2 {
+		t.Errorf("stats %+v, want 2 idle clients created once", s)
+	}
+	if n := len(own.Calls()); n != 1 {
+		t.Errorf("daemon's client read %d images, want 1", n)
+	}
+
+	for _, tt := range []struct {
+		name, path, token string
+		header            map[string]string
+		code              int
+	}{
+		{"read scope", "/v1/read", "mqv_phone", nil, http.StatusForbidden},
+		{"model without key", "/v1/read", "mqv_camera", map[string]string{headerVisionModel: "other"}, http.StatusBadRequest},
+		{"key without pool", "/nopool/read", "", map[string]string{headerVisionKey: "key-a"}, http.StatusNotImplemented},
+	} {
+		if w := post(tt.path, tt.token, tt.header); w.Code != tt.code {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body)
+		} else if strings.Contains(w.Body.String(), "key-a") {
+			t.Errorf("%s: answer shows the key: %s", tt.name, w.Body)
+		}
+	}
+}
+
+func TestReadImageStateless(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	var (
+		mu      sync.Mutex
+		read    = "02924.457"
+		prompts []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		prompts = append(prompts, string(body))
+		content := `{"read":"` + read + `","confidences":[1,1,1,1,1,1,1,1],"date":"","issue":{"kind":"none","note":""}}`
+		json.NewEncoder(w).Encode(map[string]any{"choices": []any{
+			map[string]any{"message": map[string]string{"content": content}, "finish_reason": "stop"},
+		}})
+	}))
+	defer srv.Close()
+	c := &Config{Prompt: "Read the meter.{{if .PrevRead}} prev={{.PrevRead}}{{end}}"}
+	c.OpenAICompat.BaseURL, c.OpenAICompat.APIKey, c.OpenAICompat.Model = srv.URL, "key", "model"
+
+	ctx := context.Background()
+	daemon, err := newVisionClient(ctx, c)
+	if err != nil {
+		t.Fatalf("daemon client: %v", err)
+	}
+	defer daemon.Close()
+	readClient, err := newReadClient(ctx, c, nil)
+	if err != nil {
+		t.Fatalf("read client: %v", err)
+	}
+	defer readClient.Close()
+	if _, err := daemon.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("daemon read: %v", err)
+	}
+
+	// A posted image is read without the meter's previous reading and does
+	// not become it.
+	mu.Lock()
+	read = "09999.999"
+	mu.Unlock()
+	router := gin.New()
+	router.POST("/v1/read", (&ReadImage{Client: readClient}).Handler)
+	for range 2 {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/read", strings.NewReader("jpeg")))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "09999.999") {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		mu.Lock()
+		if last := prompts[len(prompts)-1]; strings.Contains(last, "prev=") {
+			t.Errorf("posted image read with a previous reading: %s", last)
+		}
+		mu.Unlock()
+	}
+	mu.Lock()
+	read = "02924.460"
+	mu.Unlock()
+
+	if _, err := daemon.ReadGasGaugePic(ctx, strings.NewReader("jpeg")); err != nil {
+		t.Fatalf("daemon read: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if last := prompts[len(prompts)-1]; !strings.Contains(last, "prev=02924.457") {
+		t.Errorf("daemon read after a posted image without its own previous reading: %s", last)
+	}
+}
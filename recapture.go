@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/validate"
+)
+
+// ErrRecaptureUnsupported is returned by image sources that cannot take a
+// photo on request.
+var ErrRecaptureUnsupported = errors.New("image source cannot re-capture")
+
+// ImageSource is where the meter images come from.
+type ImageSource interface {
+	// Recapture asks for a fresh image now and returns it, or
+	// ErrRecaptureUnsupported.
+	Recapture(ctx context.Context) ([]byte, error)
+}
+
+// noRecapture is a source taking no requests, such as an image file or a
+// camera publishing on its own schedule.
+type noRecapture struct{}
+
+func (noRecapture) Recapture(context.Context) ([]byte, error) { return nil, ErrRecaptureUnsupported }
+
+// mqttSource triggers the camera by publishing Payload to Topic and takes
+// the next image it publishes, within Timeout, as the capture.
+type mqttSource struct {
+	Publish func(topic string, payload []byte) error
+	Topic   string
+	Payload []byte
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	pending chan []byte // the Recapture waiting for an image, if any
+}
+
+// Recapture implements [ImageSource].
+func (s *mqttSource) Recapture(ctx context.Context) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	s.mu.Lock()
+	if s.pending != nil {
+		s.mu.Unlock()
+		return nil, errors.New("re-capture already waiting")
+	}
+	s.pending = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.pending = nil
+		s.mu.Unlock()
+	}()
+
+	if err := s.Publish(s.Topic, s.Payload); err != nil {
+		return nil, fmt.Errorf("request capture: %w", err)
+	}
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+	select {
+	case img := <-ch:
+		return img, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no capture within %s", s.Timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Pending returns the writer taking the next image for a waiting
+// Recapture, or nil if none waits.
+func (s *mqttSource) Pending() io.WriteCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return nil
+	}
+	return &captureWriter{images: s.pending}
+}
+
+// recapture retries rejected readings of a cycle with fresh captures.
+type recapture struct {
+	Source   ImageSource
+	Attempts int // re-captures per cycle; 0 never re-captures
+	Delay    time.Duration
+
+	unsupported sync.Once
+}
+
+// Retryable reports whether a fresh photo may fix the reading that failed
+// with err: an unreadable, unresolved or low-confidence value, a reported
+// image issue or a disagreement with the cross-check.
+func (r *recapture) Retryable(err error) bool {
+	var rej *validate.Rejection
+	if errors.As(err, &rej) {
+		return rej.Validator == validate.Format || rej.Validator == validate.Quality ||
+			rej.Validator == validate.MinConfidence || rej.Validator == validate.CrossCheck
+	}
+	return errors.Is(err, genai.ErrEmptyReading) || errors.Is(err, genai.ErrInvalidModelOutput)
+}
+
+// Next returns a fresh capture after a failed attempt-th reading of a cycle,
+// counting from 1; ok is false when the cycle is over.
+func (r *recapture) Next(ctx context.Context, attempt int, err error) (img io.Reader, ok bool) {
+	if r == nil || attempt > r.Attempts || !r.Retryable(err) {
+		return nil, false
+	}
+	select {
+	case <-time.After(r.Delay):
+	case <-ctx.Done():
+		return nil, false
+	}
+	b, rerr := r.Source.Recapture(ctx)
+	if errors.Is(rerr, ErrRecaptureUnsupported) {
+		r.unsupported.Do(func() { log.Printf("Not re-capturing: %v; waiting for the next image instead", rerr) })
+		return nil, false
+	}
+	if rerr != nil {
+		log.Printf("Error re-capturing after %v: %v", err, rerr)
+		return nil, false
+	}
+	log.Printf("Re-captured after %v (attempt %d of %d)", err, attempt, r.Attempts)
+	return bytes.NewReader(b), true
+}
+
+// Enabled reports whether r may re-capture.
+func (r *recapture) Enabled() bool {
+	return r != nil && r.Attempts > 0
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/openaicompat"
+	"github.com/suapapa/mqvision/internal/validate"
+)
+
+func TestMQTTSourceRecapture(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var src *mqttSource
+	src = &mqttSource{Topic: "cam/capture", Payload: []byte("capture"), Timeout: time.Second,
+		Publish: func(topic string, payload []byte) error {
+			if topic != "cam/capture" || string(payload) != "capture" {
+				return fmt.Errorf("published %q to %s", payload, topic)
+			}
+			// The camera answers on the image topic.
+			go func() {
+				w := src.Pending()
+				io.WriteString(w, "jpeg")
+				w.Close()
+			}()
+			return nil
+		}}
+	img, err := src.Recapture(ctx)
+	if err != nil || string(img) != "jpeg" {
+		t.Fatalf("Recapture = %q, %v", img, err)
+	}
+	if src.Pending() != nil {
+		t.Fatalf("Pending after the capture arrived")
+	}
+
+	src.Publish = func(string, []byte) error { return nil } // the camera is off
+	src.Timeout = time.Millisecond
+	if _, err := src.Recapture(ctx); err == nil {
+		t.Fatalf("Recapture without an answer succeeded")
+	}
+}
+
+func TestRecaptureNext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	unreadable := &validate.Rejection{Validator: validate.Format, Err: errors.New("reading 0292?.457")}
+	tooFar := &validate.Rejection{Validator: validate.MaxDelta, Err: errors.New("increase of 6.000 over 4.000")}
+	tests := []struct {
+		err error
+		ok  bool
+	}{
+		{unreadable, true},
+		{&validate.Rejection{Validator: validate.Quality, Err: errors.New("image issue: glare")}, true},
+		{&validate.Rejection{Validator: validate.MinConfidence, Err: validate.ErrLowConfidence}, true},
+		{fmt.Errorf("read: %w", genai.ErrEmptyReading), true},
+		{tooFar, false},
+		{genai.ErrWrongMeter, false},
+		{context.DeadlineExceeded, false},
+	}
+	fresh := &fakeSource{img: []byte("jpeg")}
+	r := &recapture{Source: fresh, Attempts: 2}
+	for _, tt := range tests {
+		if got := r.Retryable(tt.err); got != tt.ok {
+			t.Fatalf("Retryable(%v) = %t, want %t", tt.err, got, tt.ok)
+		}
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		img, ok := r.Next(ctx, attempt, unreadable)
+		if ok != (attempt <= 2) || ok && img == nil {
+			t.Fatalf("Next after attempt %d = %v, %t", attempt, img, ok)
+		}
+	}
+	if fresh.calls != 2 {
+		t.Fatalf("%d re-captures, want 2", fresh.calls)
+	}
+
+	for _, r := range []*recapture{
+		nil,
+		{Source: noRecapture{}, Attempts: 2},
+		{Source: &fakeSource{err: errors.New("broker down")}, Attempts: 2},
+	} {
+		if _, ok := r.Next(ctx, 1, unreadable); ok {
+			t.Fatalf("Next with %+v re-captured", r)
+		}
+	}
+}
+
+// TestRecaptureLowConfidence reads an answer the model is unsure of through
+// a client: the min_confidence validator rejects it and the cycle reads a
+// fresh capture.
+func TestRecaptureLowConfidence(t *testing.T) {
+	t.Parallel()
+
+	answers := []string{
+		`{"read":"02924.457","confidences":[1,1,1,1,0.4,1,1,1],"date":"","issue":{"kind":"glare","note":"reflection over the fifth digit"}}`,
+		`{"read":"02924.457","confidences":[1,1,1,1,0.9,1,1,1],"date":"","issue":{"kind":"none","note":""}}`,
+	}
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := answers[min(calls, len(answers)-1)]
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{"choices": []any{
+			map[string]any{"message": map[string]string{"content": content}, "finish_reason": "stop"},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	c, err := openaicompat.NewClient(srv.URL, "key", "model", "", "", genai.WithStateless())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	p, err := validate.New(genai.DefaultMeter, []validate.Config{{Name: validate.Format}, {Name: validate.MinConfidence, Threshold: 0.8}})
+	if err != nil {
+		t.Fatalf("validate.New: %v", err)
+	}
+
+	ctx := context.Background()
+	src := &fakeSource{img: []byte("jpeg")}
+	r := &recapture{Source: src, Attempts: 2}
+	var errs []error
+	var img io.Reader = bytes.NewReader([]byte("jpeg"))
+	for attempt := 1; ; attempt++ {
+		res, err := c.ReadGasGaugePic(ctx, img)
+		if err != nil {
+			t.Fatalf("attempt %d: ReadGasGaugePic: %v", attempt, err)
+		}
+		err = p.Run(ctx, nil, res)
+		errs = append(errs, err)
+		var ok bool
+		if img, ok = r.Next(ctx, attempt, err); !ok {
+			break
+		}
+	}
+	var rej *validate.Rejection
+	if len(errs) != 2 || !errors.As(errs[0], &rej) || rej.Validator != validate.MinConfidence || errs[1] != nil {
+		t.Fatalf("outcomes %v, want a min_confidence rejection, then the reading accepted", errs)
+	}
+	var low *validate.LowConfidenceError
+	if !errors.As(errs[0], &low) || low.Position != 4 || low.Confidence != 0.4 {
+		t.Fatalf("rejection %v, want the fifth digit at 0.40", errs[0])
+	}
+	if src.calls != 1 || calls != 2 {
+		t.Fatalf("%d re-captures and %d model calls, want 1 and 2", src.calls, calls)
+	}
+}
+
+type fakeSource struct {
+	img   []byte
+	err   error
+	calls int
+}
+
+func (s *fakeSource) Recapture(context.Context) ([]byte, error) {
+	s.calls++
+	return s.img, s.err
+}
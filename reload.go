@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/maintenance"
+)
+
+// reloadOnHangup reconfigures r with [reloadVision] on every SIGHUP.
+// Readings already running finish with the configuration they started
+// with; see [genai.Reconfigurer]. The other settings still need a restart.
+func reloadOnHangup(ctx context.Context, r genai.Reconfigurer, k *maintenance.Keeper, opts []genai.Option) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+		case <-ctx.Done():
+			return
+		}
+		if err := reloadVision(ctx, r, k, opts); err != nil {
+			log.Printf("Error reloading %s, keeping the config %s: %v", flagConfigFile, r.Snapshot().Hash, err)
+			continue
+		}
+		log.Printf("Reloaded the vision config from %s: model %s, config %s", flagConfigFile, r.Snapshot().Model, r.Snapshot().Hash)
+	}
+}
+
+// reloadVision reconfigures r with the model, prompts and genai options of
+// the config file, expecting the serial number of the last exchange of the
+// meter recorded in k, followed by opts.
+func reloadVision(ctx context.Context, r genai.Reconfigurer, k *maintenance.Keeper, opts []genai.Option) error {
+	c, err := LoadConfig(flagConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := useExchangedSerial(ctx, c, k); err != nil {
+		return err
+	}
+	return r.Reconfigure(c.OpenAICompat.Model, c.SystemPrompt, c.Prompt, visionOptions(c, opts)...)
+}
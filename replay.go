@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/pushgateway"
+)
+
+// runReplay implements the `replay` subcommand: it reads the archived images
+// of a period again, e.g. to try a new model or prompt on past captures. It
+// prints the reading of every image; nothing is stored or published.
+func runReplay(args []string) error {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	fromFlag := fs.String("from", today.Format(time.DateOnly), "First day of the period (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Day after the period (YYYY-MM-DD, default: now)")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	cleanup := fs.Bool("cleanup", false, "Delete the metrics group from pushgateway instead of pushing")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Archive == nil {
+		return fmt.Errorf("replay: needs archive")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	start := time.Now()
+	run := pushgateway.Run{MeterID: *meterID}
+	var stats genai.StatsReporter
+	defer func() {
+		run.Duration = time.Since(start)
+		pushMetrics(config.Pushgateway, stats, run, *cleanup)
+	}()
+	from, err := time.ParseInLocation(time.DateOnly, *fromFlag, time.Local)
+	if err != nil {
+		return fmt.Errorf("parse -from: %w", err)
+	}
+	to := now
+	if *toFlag != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, *toFlag, time.Local); err != nil {
+			return fmt.Errorf("parse -to: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return fmt.Errorf("empty period %s - %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	images, err := archive.New(ctx, *config.Archive)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	client, err := newVisionClient(ctx, config, genai.WithStateless())
+	if err != nil {
+		return fmt.Errorf("create vision client: %w", err)
+	}
+	defer client.Close()
+	stats, _ = client.(genai.StatsReporter)
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	client = genai.Chain(client, genai.Around(func(ctx context.Context, next func(context.Context) (*genai.GasMeterReadResult, error)) (*genai.GasMeterReadResult, error) {
+		r, err := next(ctx)
+		if err == nil && r != nil {
+			if v, err := genai.ParseRead(meter, r.Read); err == nil {
+				run.Reading, run.HasReading = v, true
+			}
+		}
+		return r, err
+	}))
+
+	n, failed, err := replay(ctx, os.Stdout, images, client, *meterID, from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Replayed %d images, %d failed\n", n, failed)
+	run.Success = failed == 0
+	return nil
+}
+
+// replay reads the images of meterID archived in [from, to) with client,
+// writing a line per image to w: its time, key and reading or error. It
+// returns how many images it read and how many of them failed.
+func replay(ctx context.Context, w io.Writer, images archive.Store, client genai.VisionClient, meterID string, from, to time.Time) (n, failed int, err error) {
+	list, err := images.List(ctx, meterID, from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, img := range list {
+		if err := ctx.Err(); err != nil {
+			return n, failed, err
+		}
+		n++
+		jpg, err := images.Fetch(ctx, img.Key)
+		var r *genai.GasMeterReadResult
+		if err == nil {
+			r, err = client.ReadGasGaugePic(ctx, bytes.NewReader(jpg))
+		}
+		if err == nil && r == nil {
+			err = errors.New("read result is nil")
+		}
+		at := img.At.Local().Format(time.RFC3339)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\t%s\terror: %v\n", at, img.Key, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", at, img.Key, r.Read)
+	}
+	return n, failed, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/archive"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	images, err := archive.New(ctx, archive.Config{Backend: archive.BackendDir, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err := images.Archive(ctx, images.Key("home", at.Add(time.Duration(i)*time.Hour)), []byte{byte(i)}); err != nil {
+			t.Fatalf("Archive: %v", err)
+		}
+	}
+
+	client := genaitest.NewFakeReader(&genai.GasMeterReadResult{Read: "02924.500"})
+	client.PushError(errors.New("model overloaded"))
+	var out bytes.Buffer
+	n, failed, err := replay(ctx, &out, images, client, "home", at, at.Add(2*time.Hour))
+	if err != nil || n != 2 || failed != 1 {
+		t.Fatalf("replay = %d, %d, %v, want 2 images, 1 failed", n, failed, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "\thome/2025/11/20251107T060000.000Z.jpg\t02924.500") ||
+		!strings.HasSuffix(lines[1], "\terror: model overloaded") {
+		t.Fatalf("output =\n%s", out.String())
+	}
+	if calls := client.Calls(); len(calls) != 2 || !bytes.Equal(calls[1].Image, []byte{1}) {
+		t.Fatalf("calls = %+v", calls)
+	}
+}
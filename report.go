@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/report"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// runReport implements the `report` subcommand: it prints the consumption
+// report of the day, week or month containing a date.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	period := fs.String("period", "week", "Period of the report: day, week or month")
+	atFlag := fs.String("at", "", "A day of the period (YYYY-MM-DD, default: today)")
+	format := fs.String("format", "text", "Output format: text, markdown or json")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s report [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("report: needs store.path")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	cfg := config.ReportConfig()
+	loc := cfg.TimeZone()
+	now := time.Now()
+	at := now
+	if *atFlag != "" {
+		if at, err = time.ParseInLocation(time.DateOnly, *atFlag, loc); err != nil {
+			return fmt.Errorf("parse -at: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	r, err := report.Generate(ctx, s, cfg, *meterID, report.Period(*period), at, now)
+	if err != nil {
+		return fmt.Errorf("generate report: %w", err)
+	}
+	out, err := r.Render(*format)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Print(out)
+	return err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/event"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/notify"
+)
+
+// Waits of a [SelfTest] for a reading of its meter that is running.
+const (
+	selfTestRetry = time.Minute
+	selfTestTries = 10
+)
+
+// SelfTestResult is the outcome of a self-test, as on /healthz.
+type SelfTestResult struct {
+	// Time is when the test ran, or was skipped.
+	Time time.Time `json:"time"`
+	OK   bool      `json:"ok"`
+	// Skipped says why the test did not run, e.g. while the breaker is open.
+	Skipped  string `json:"skipped,omitempty"`
+	Expected string `json:"expected"`
+	Read     string `json:"read,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Took     string `json:"took,omitempty"`
+}
+
+// SelfTest reads a reference image of a known reading, to check the API
+// key, the model and the prompts whatever the camera sends. Client is its
+// own, stateless, so that what it reads never reaches the store, the sinks
+// or the previous reading of the daemon's client.
+type SelfTest struct {
+	Client   genai.VisionClient
+	Image    []byte
+	Expected string
+	Meter    genai.Meter
+	// Breaker, if set, skips the test while it is open: the calls to the API
+	// are paused, and the failures notified already.
+	Breaker *genai.Breaker
+	// Cycles, if set, runs the test as a reading cycle of the meter, waiting
+	// selfTestRetry while one runs, so that the test never adds a call to
+	// those of a reading. A capture arriving during the test is skipped.
+	Cycles *Cycles
+	Clock  genai.Clock
+
+	mu   sync.Mutex
+	last *SelfTestResult
+}
+
+// newSelfTest returns the self-test of c for m. Its client is configured as
+// that of the readings, but stateless and without retries or fallback
+// models: a test costs one reading call, and a failure shows rather than
+// being retried away.
+func newSelfTest(ctx context.Context, c *Config, m genai.Meter) (*SelfTest, error) {
+	img, err := os.ReadFile(c.SelfTest.Image)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if img, err = decodeImage(c, img); err != nil {
+		return nil, err
+	}
+	client, err := newVisionClient(ctx, c, genai.WithStateless(), genai.WithRetries(0))
+	if err != nil {
+		return nil, fmt.Errorf("create vision client: %w", err)
+	}
+	return &SelfTest{Client: client, Image: img, Expected: c.SelfTest.Read, Meter: m, Breaker: breaker, Cycles: &cycles}, nil
+}
+
+// Run runs the test once, or skips it, and records its outcome.
+func (st *SelfTest) Run(ctx context.Context) SelfTestResult {
+	res := st.run(ctx)
+	st.mu.Lock()
+	st.last = &res
+	st.mu.Unlock()
+	return res
+}
+
+func (st *SelfTest) run(ctx context.Context) SelfTestResult {
+	c := st.clock()
+	res := SelfTestResult{Time: c.Now(), Expected: st.Expected}
+	if st.Breaker.State() == genai.BreakerOpen {
+		res.Skipped = "circuit breaker open"
+		return res
+	}
+	if st.Cycles != nil {
+		done, err := st.startCycle(ctx)
+		if err != nil {
+			res.Skipped = err.Error()
+			return res
+		}
+		defer done()
+	}
+
+	start := c.Now()
+	r, err := st.Client.ReadGasGaugePic(ctx, bytes.NewReader(st.Image))
+	res.Time, res.Took = start, genai.Since(c, start).Round(time.Millisecond).String()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Read = r.Read
+	if err := st.check(r.Read); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.OK = true
+	return res
+}
+
+// check returns why read is not the expected reading.
+func (st *SelfTest) check(read string) error {
+	got, err := genai.ParseRead(st.Meter, read)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", read, err)
+	}
+	want, err := genai.ParseRead(st.Meter, st.Expected)
+	if err != nil {
+		return fmt.Errorf("expected %s: %w", st.Expected, err)
+	}
+	if got != want {
+		return fmt.Errorf("read %s, expected %s", read, st.Expected)
+	}
+	return nil
+}
+
+// startCycle starts a reading cycle of the meter, waiting for the one
+// running if any.
+func (st *SelfTest) startCycle(ctx context.Context) (func(), error) {
+	for i := 0; ; i++ {
+		if done, ok := st.Cycles.TryStart(st.Meter.ID); ok {
+			return done, nil
+		}
+		if i == selfTestTries-1 {
+			return nil, fmt.Errorf("a reading of %s kept running", st.Meter.ID)
+		}
+		select {
+		case <-st.clock().After(selfTestRetry):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Last returns the outcome of the last test, or nil before the first.
+func (st *SelfTest) Last() *SelfTestResult {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.last
+}
+
+func (st *SelfTest) clock() genai.Clock {
+	if st.Clock == nil {
+		return genai.RealClock
+	}
+	return st.Clock
+}
+
+// selfTestDaily runs st every day at the time of day at in loc and
+// notifies the tests that fail.
+func selfTestDaily(ctx context.Context, st *SelfTest, at time.Duration, loc *time.Location) {
+	for {
+		now := time.Now().In(loc)
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).Add(at)
+		if !next.After(now) {
+			next = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc).Add(at)
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return
+		}
+		res := st.Run(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case res.Skipped != "":
+			log.Printf("Skipped the self-test: %s", res.Skipped)
+		case res.OK:
+			log.Printf("Self-test passed: read %s in %s", res.Read, res.Took)
+		default:
+			notifySelfTest(ctx, st.Meter.ID, res)
+		}
+	}
+}
+
+// notifySelfTest notifies res, a failed self-test of meterID.
+func notifySelfTest(ctx context.Context, meterID string, res SelfTestResult) {
+	err := events.Dispatch(ctx, event.Event{Type: event.SelfTestFailed, Event: notify.Event{
+		Kind:     "self_test",
+		Severity: notify.Critical,
+		MeterID:  meterID,
+		Time:     res.Time,
+		Message:  fmt.Sprintf("Self-test with the reference image failed: %s", res.Error),
+		Data:     map[string]any{"self_test": res},
+	}})
+	if err != nil {
+		log.Printf("Error notifying the self-test: %v", err)
+	}
+}
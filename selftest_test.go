@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+)
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	reader := genaitest.NewFakeReader(&genai.GasMeterReadResult{Read: "02924.457"}, &genai.GasMeterReadResult{Read: "02924.451"})
+	reader.PushError(errors.New("api key not valid"))
+	st := &SelfTest{Client: reader, Image: []byte("jpeg"), Expected: "02924.457", Meter: genai.DefaultMeter}
+	if st.Last() != nil {
+		t.Fatal("Last before the first test")
+	}
+
+	tests := []struct {
+		ok      bool
+		wantErr string
+	}{
+		{true, ""},
+		{false, "read 02924.451, expected 02924.457"},
+		{false, "api key not valid"},
+	}
+	for _, tt := range tests {
+		res := st.Run(context.Background())
+		if res.OK != tt.ok || !strings.Contains(res.Error, tt.wantErr) || res.Skipped != "" {
+			t.Errorf("Run = %+v, want ok %t, error %q", res, tt.ok, tt.wantErr)
+		}
+		if last := st.Last(); last == nil || *last != res {
+			t.Errorf("Last = %+v, want %+v", last, res)
+		}
+	}
+	if n := len(reader.Calls()); n != 3 {
+		t.Fatalf("%d calls, want 3", n)
+	}
+
+	// An open breaker skips the test without calling the API.
+	st.Breaker = genai.NewBreaker(1, time.Hour)
+	st.Breaker.Done(errors.New("down"))
+	if res := st.Run(context.Background()); res.Skipped == "" || res.OK {
+		t.Fatalf("Run with the breaker open = %+v", res)
+	}
+	if n := len(reader.Calls()); n != 3 {
+		t.Fatalf("%d calls with the breaker open, want 3", n)
+	}
+}
+
+func TestSelfTestWaitsForReading(t *testing.T) {
+	t.Parallel()
+
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 4, 0, 0, 0, time.UTC))
+	reader := genaitest.NewFakeReader(&genai.GasMeterReadResult{Read: "02924.457"})
+	var cycles Cycles
+	st := &SelfTest{Client: reader, Image: []byte("jpeg"), Expected: "02924.457", Meter: genai.DefaultMeter, Cycles: &cycles, Clock: clock}
+
+	done, _ := cycles.Start(genai.DefaultMeter.ID)
+	results := make(chan SelfTestResult)
+	go func() { results <- st.Run(context.Background()) }()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// A capture now is skipped; the test waiting is not.
+	if _, ok := cycles.Start(genai.DefaultMeter.ID); ok {
+		t.Fatal("a cycle started beside the reading")
+	}
+	done()
+	clock.Advance(selfTestRetry)
+	if res := <-results; !res.OK {
+		t.Fatalf("Run = %+v", res)
+	}
+	if skipped := cycles.Skipped()[genai.DefaultMeter.ID]; skipped != 1 {
+		t.Fatalf("%d cycles skipped, want the capture only", skipped)
+	}
+	if _, ok := cycles.Start(genai.DefaultMeter.ID); !ok {
+		t.Fatal("the test did not end its cycle")
+	}
+}
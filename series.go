@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/series"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// Series defaults: the points of a series, and the range without from.
+const (
+	seriesMaxPoints = 5000
+	seriesRange     = 24 * time.Hour
+)
+
+// seriesFlush is how many points are written between flushes of a series.
+const seriesFlush = 1000
+
+// Series serves GET /v1/meters/:id/series for JSON datasources such as
+// Grafana's Infinity: the meter value and the consumption between from and
+// to as [timestamp, value] pairs, with the time in Unix milliseconds,
+//
+//	{"meter":"home","agg":"hourly","interval_ms":3600000,"gaps":"spread","values":[[1762473600000,2924.457],...],"consumption":[[1762473600000,0.12],...]}
+//
+// agg is raw (default), hourly or daily, and max_points lowers MaxPoints.
+// The response is streamed, with the history loaded a month at a time, so
+// that multi-year ranges need not fit in memory.
+type Series struct {
+	Store store.Store // no store: series fail
+	Meter genai.Meter
+	// MaxPoints bounds the points of a series, which is downsampled to fit
+	// (default 5000).
+	MaxPoints int
+	// Location aligns hours and days (default time.Local).
+	Location *time.Location
+	// Gaps is how the consumption across gaps is attributed, given in the
+	// response; see [series.Query].
+	Gaps  string
+	Clock genai.Clock // default genai.RealClock
+}
+
+// Handler implements the endpoint.
+func (h *Series) Handler(c *gin.Context) {
+	if id := c.Param("id"); id != h.Meter.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	if h.Store == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "series need store.path"})
+		return
+	}
+	q, err := h.query(c)
+	if err == nil {
+		err = q.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Agg == "" {
+		q.Agg = series.AggRaw
+	}
+
+	// The values and the consumption are two walks of the history, so that
+	// neither is held in memory. A store error once the response has started
+	// can only cut it short, leaving invalid JSON the client will notice.
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := bufio.NewWriter(c.Writer)
+	fmt.Fprintf(w, `{"meter":%s,"agg":%s,"interval_ms":%d,"gaps":%s`, jsonString(h.Meter.ID), jsonString(q.Agg), q.Interval().Milliseconds(), jsonString(q.GapAttribution()))
+	for _, s := range []struct {
+		key   string
+		point func(series.Bucket) (series.Point, bool)
+	}{
+		{"values", func(b series.Bucket) (series.Point, bool) { return b.ValuePoint(), true }},
+		{"consumption", func(b series.Bucket) (series.Point, bool) { return b.ConsumptionPoint(), b.HasConsumption }},
+	} {
+		fmt.Fprintf(w, `,%q:[`, s.key)
+		n := 0
+		err := series.Walk(c.Request.Context(), h.Store, h.Meter, h.Meter.ID, q, func(b series.Bucket) error {
+			p, ok := s.point(b)
+			if !ok {
+				return nil
+			}
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			pj, _ := p.MarshalJSON()
+			if _, err := w.Write(pj); err != nil {
+				return err
+			}
+			if n++; n%seriesFlush == 0 {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error serving %s series: %v", s.key, err)
+			w.Flush()
+			return
+		}
+		w.WriteByte(']')
+	}
+	w.WriteString("}\n")
+	w.Flush()
+}
+
+// query parses the query parameters of c.
+func (h *Series) query(c *gin.Context) (series.Query, error) {
+	clock := h.Clock
+	if clock == nil {
+		clock = genai.RealClock
+	}
+	q := series.Query{Agg: c.Query("agg"), MaxPoints: h.MaxPoints, Location: h.Location, Gaps: h.Gaps}
+	if q.MaxPoints <= 0 {
+		q.MaxPoints = seriesMaxPoints
+	}
+	if q.Location == nil {
+		q.Location = time.Local
+	}
+	if s := c.Query("max_points"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("max_points %q is not a positive number", s)
+		}
+		q.MaxPoints = min(n, q.MaxPoints)
+	}
+	var err error
+	q.To = clock.Now()
+	if s := c.Query("to"); s != "" {
+		if q.To, err = parseSeriesTime(s, q.Location); err != nil {
+			return q, fmt.Errorf("to: %w", err)
+		}
+	}
+	q.From = q.To.Add(-seriesRange)
+	if s := c.Query("from"); s != "" {
+		if q.From, err = parseSeriesTime(s, q.Location); err != nil {
+			return q, fmt.Errorf("from: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// parseSeriesTime parses a time as Grafana sends it, in Unix milliseconds
+// (${__from}), or as RFC 3339 or a date in loc.
+func parseSeriesTime(s string, loc *time.Location) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not Unix milliseconds, RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+func jsonString(s string) []byte {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+// cors allows browsers on origins, or on any origin with "*", to call the
+// API, answering preflight requests itself. Without origins it does nothing.
+func cors(origins []string) gin.HandlerFunc {
+	anyOrigin := slices.Contains(origins, "*")
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || !anyOrigin && !slices.Contains(origins, origin) {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			h.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+func TestSeries(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	start := time.Date(2025, 11, 7, 0, 0, 0, 0, time.UTC)
+	meter := genai.DefaultMeter
+	meter.ID = "home"
+	s := store.NewMemory()
+	for i, read := range []string{"01234.000", "01234.500", "01235.250", "01236.000"} {
+		if err := s.Save(ctx, "home", &genai.GasMeterReadResult{Read: read, ReadAt: start.Add(time.Duration(i) * 40 * time.Minute)}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	h := &Series{Store: s, Meter: meter, MaxPoints: 100, Location: time.UTC, Clock: genaitest.NewClock(start.Add(3 * time.Hour))}
+	router := gin.New()
+	router.Use(cors([]string{"https://grafana.example"}))
+	router.GET("/v1/meters/:id/series", h.Handler)
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/v1/meters/home/series?agg=hourly", "Origin", "https://grafana.example")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	want := `{"meter":"home","agg":"hourly","interval_ms":3600000,"gaps":"spread",` +
+		`"values":[[1762473600000,1234.5],[1762477200000,1235.25],[1762480800000,1236]],` +
+		`"consumption":[[1762473600000,0.5],[1762477200000,0.75],[1762480800000,0.75]]}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Fatalf("body =\n%s\nwant\n%s", got, want)
+	}
+	if !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("body is not JSON")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://grafana.example" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+
+	var raw struct {
+		Values      [][2]float64 `json:"values"`
+		Consumption [][2]float64 `json:"consumption"`
+	}
+	from, to := start.UnixMilli(), start.Add(2*time.Hour).UnixMilli()
+	w = get("/v1/meters/home/series?from=" + strconv.FormatInt(from, 10) + "&to=" + strconv.FormatInt(to, 10) + "&max_points=2")
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil || len(raw.Values) != 2 || raw.Values[1][1] != 1235.25 {
+		t.Fatalf("downsampled raw series = %+v (%v): %s", raw, err, w.Body)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("Access-Control-Allow-Origin without Origin = %q", got)
+	}
+
+	for _, path := range []string{
+		"/v1/meters/home/series?agg=weekly",
+		"/v1/meters/home/series?from=yesterday",
+		"/v1/meters/home/series?from=2025-11-08&to=2025-11-07",
+		"/v1/meters/home/series?max_points=0",
+	} {
+		if w := get(path); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400", path, w.Code)
+		}
+	}
+	if w := get("/v1/meters/cabin/series"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown meter: status %d, want 404", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/v1/meters/home/series", nil)
+	req.Header.Set("Origin", "https://grafana.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	pre := httptest.NewRecorder()
+	router.ServeHTTP(pre, req)
+	if pre.Code != http.StatusNoContent || pre.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("preflight: status %d, headers %v", pre.Code, pre.Header())
+	}
+	if w := get("/v1/meters/home/series", "Origin", "https://evil.example"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("other origin allowed")
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/total"
+)
+
+// Stream settings: every subscriber buffers streamBuffer readings; one that
+// falls further behind is disconnected and gets the latest reading when it
+// reconnects.
+const (
+	streamBuffer    = 8
+	streamKeepAlive = 30 * time.Second
 )
 
 type SensorServer struct {
@@ -13,15 +25,99 @@ type SensorServer struct {
 	UpdatedAt time.Time `json:"updated_at"` // lastest updated at
 	Metadata  any       `json:"metadata"`   // lastest metadata
 
+	// Unit, DeviceClass and StateClass describe the meter for Home Assistant.
+	Unit        string `json:"unit_of_measurement"`
+	DeviceClass string `json:"device_class"`
+	StateClass  string `json:"state_class,omitempty"`
+	// LastReset and HeldBack are those of the [total.State] of Value; see
+	// [SensorServer.SetTotal].
+	LastReset time.Time       `json:"last_reset,omitzero"`
+	HeldBack  *total.HeldBack `json:"held_back,omitempty"`
+
+	// MeterID is the id the stream is served under.
+	MeterID string `json:"-"`
+	// KeepAlive is the interval of stream keepalive comments (default 30s).
+	KeepAlive time.Duration `json:"-"`
+
 	sync.RWMutex
+	subs map[chan []byte]struct{}
 }
 
 func (s *SensorServer) SetValue(value float64, metadata any) {
 	s.Lock()
 	defer s.Unlock()
+	s.set(value, metadata)
+}
+
+// SetTotal sets the value of st, kept non-decreasing for a Home Assistant
+// sensor of the [total.StateClass], with its reset and held back reading.
+func (s *SensorServer) SetTotal(st total.State, metadata any) {
+	s.Lock()
+	defer s.Unlock()
+	s.StateClass, s.LastReset, s.HeldBack = total.StateClass, st.LastReset, st.HeldBack
+	s.set(st.Value, metadata)
+}
+
+// set sets the value and sends it to the stream subscribers; s is locked.
+func (s *SensorServer) set(value float64, metadata any) {
 	s.Value = value
 	s.Metadata = metadata
 	s.UpdatedAt = time.Now()
+
+	if len(s.subs) == 0 {
+		return
+	}
+	msg, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("Error marshalling sensor value for the stream: %v", err)
+		return
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- msg:
+		default:
+			log.Println("Dropping a stream subscriber that fell behind")
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel of the JSON of every value set from now on,
+// preceded by the current value if there is one.
+func (s *SensorServer) subscribe() (chan []byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	ch := make(chan []byte, streamBuffer)
+	if !s.UpdatedAt.IsZero() {
+		msg, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		ch <- msg
+	}
+	if s.subs == nil {
+		s.subs = make(map[chan []byte]struct{})
+	}
+	s.subs[ch] = struct{}{}
+	return ch, nil
+}
+
+func (s *SensorServer) unsubscribe(ch chan []byte) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// Latest returns the last published luggage, or nil if there is none yet.
+func (s *SensorServer) Latest() *Luggage {
+	s.RLock()
+	defer s.RUnlock()
+	l, _ := s.Metadata.(*Luggage)
+	return l
 }
 
 func (s *SensorServer) GetValueHandler(c *gin.Context) {
@@ -37,3 +133,61 @@ func (s *SensorServer) GetValueHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, s)
 }
+
+// CloseStreams ends every stream, e.g. on shutdown; subscribers may reconnect.
+func (s *SensorServer) CloseStreams() {
+	s.Lock()
+	defer s.Unlock()
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// StreamHandler serves GET /v1/meters/:id/stream as server-sent events: a
+// "reading" event with the /sensor JSON for the latest value on connect and
+// for every value set afterwards, and a keepalive comment in between.
+func (s *SensorServer) StreamHandler(c *gin.Context) {
+	if id := c.Param("id"); id != s.MeterID {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown meter %q", id)})
+		return
+	}
+	ch, err := s.subscribe()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer s.unsubscribe(ch)
+
+	keepAlive := s.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = streamKeepAlive
+	}
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: reading\ndata: %s\n\n", msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
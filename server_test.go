@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/total"
+)
+
+// nextEvent returns the data of the next "reading" event, skipping comments.
+func nextEvent(t *testing.T, sc *bufio.Scanner) map[string]any {
+	t.Helper()
+	for sc.Scan() {
+		line := sc.Text()
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var v map[string]any
+			if err := json.Unmarshal([]byte(data), &v); err != nil {
+				t.Fatalf("decode event %q: %v", data, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("stream ended: %v", sc.Err())
+	return nil
+}
+
+func TestStreamHandler(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	s := &SensorServer{MeterID: "home", Unit: "m³", KeepAlive: 10 * time.Millisecond}
+	router := gin.New()
+	router.GET("/v1/meters/:id/stream", s.StreamHandler)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/v1/meters/cellar/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown meter: status %d", resp.StatusCode)
+	}
+
+	s.SetValue(2924.457, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var streams []*bufio.Scanner
+	for range 2 {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/meters/home/stream", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		sc := bufio.NewScanner(resp.Body)
+		if v := nextEvent(t, sc); v["value"] != 2924.457 {
+			t.Fatalf("first event = %v, want the latest value", v)
+		}
+		streams = append(streams, sc)
+	}
+
+	s.SetValue(2924.7, nil)
+	for i, sc := range streams {
+		if v := nextEvent(t, sc); v["value"] != 2924.7 || v["unit_of_measurement"] != "m³" {
+			t.Fatalf("subscriber %d: event = %v", i, v)
+		}
+	}
+	// Keepalives arrive between readings.
+	for sc := streams[0]; sc.Text() != ": ping"; {
+		if !sc.Scan() || strings.HasPrefix(sc.Text(), "data: ") {
+			t.Fatalf("line = %q, want a keepalive", sc.Text())
+		}
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.RLock()
+		n := len(s.subs)
+		s.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers left after disconnecting", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetValueTotal(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+	s := &SensorServer{Unit: "m³", DeviceClass: "gas"}
+	c := total.New(genai.DefaultMeter)
+	at := time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)
+	s.SetTotal(c.Reading(2930, at), nil)
+	s.SetTotal(c.Correction(2925, at), nil)
+
+	router := gin.New()
+	router.GET("/sensor", s.GetValueHandler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sensor", nil))
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	held, _ := got["held_back"].(map[string]any)
+	if got["value"] != 2930.0 || got["state_class"] != total.StateClass || held["read"] != 2925.0 || held["reason"] != total.ReasonCorrection {
+		t.Fatalf("sensor = %s", w.Body)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+)
+
+// shareSafeOutput returns w, and redirects the log, redacting every
+// redactable field for the -share-safe flag of a command, after learning
+// the values of those in results, which are about to be printed.
+func shareSafeOutput(c *Config, w io.Writer, results ...any) (io.Writer, error) {
+	r, err := c.Redactor(true)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		r.Learn(res)
+	}
+	log.SetOutput(r.Writer(os.Stderr))
+	return r.Writer(w), nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/picam"
+)
+
+// Failover defaults: how long a source may go without an image, and how long
+// the primary may fail before it is notified.
+const (
+	defaultSourceSilence    = time.Hour
+	defaultSourceAlertAfter = time.Hour
+)
+
+// defaultCameraInterval is how often a camera attached to the host captures.
+const defaultCameraInterval = 10 * time.Minute
+
+// errCapture marks the failure to receive an image from a source.
+var errCapture = errors.New("capture failed")
+
+// SourceConfig is a camera of the meter as listed in the config file.
+type SourceConfig struct {
+	Name string `yaml:"name"`
+	// Topic is where the camera publishes its images.
+	Topic string `yaml:"topic"`
+	// Trigger, if set, is where Payload (default "capture") asks the camera
+	// for a photo, which is waited for within Timeout (default 30s).
+	Trigger string        `yaml:"trigger"`
+	Payload string        `yaml:"payload"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Camera is instead a Raspberry Pi camera attached to the host, which
+	// captures every Interval (default 10m) and on request.
+	Camera   *picam.Config `yaml:"camera"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// imageSource is a camera of the meter and its health.
+type imageSource struct {
+	name  string
+	topic string
+	// trigger is nil for a camera that only publishes on its own schedule.
+	trigger *mqttSource
+	// camera, captured every interval, is the camera attached to the host.
+	camera   *picam.Camera
+	interval time.Duration
+	// capture takes a photo on request through trigger or camera; nil if
+	// neither.
+	capture   ImageSource
+	recapture *recapture
+
+	mu                           sync.Mutex
+	captures, failures, accepted int64
+	lastImage, lastOK            time.Time
+	lastFailure                  time.Time
+	alerted                      bool // the outage since lastOK was notified
+}
+
+// SourceHealth is the health of a source as published to /debug/vars.
+type SourceHealth struct {
+	Captures     int64     `json:"captures"`
+	Failures     int64     `json:"failures"`
+	Accepted     int64     `json:"accepted"`
+	LastImage    time.Time `json:"last_image,omitzero"`
+	LastAccepted time.Time `json:"last_accepted,omitzero"`
+	LastFailure  time.Time `json:"last_failure,omitzero"`
+	Failing      bool      `json:"failing"`
+}
+
+// sources are the cameras of a meter in order of preference: a reading
+// cycle that fails on one for a reason a fresh photo may fix falls through
+// to the next that can take one on request. A source is failing when its
+// last capture failed or it sent no image for Silence; the images a later
+// source publishes on its own are only read while all before it fail.
+type sources struct {
+	list       []*imageSource
+	silence    time.Duration
+	alertAfter time.Duration
+	clock      genai.Clock
+	started    time.Time
+	// onDown is called once the primary has been failing for alertAfter,
+	// with the time of its last accepted capture (or the start).
+	onDown func(s *imageSource, since time.Time)
+}
+
+// newSources returns the sources of cfgs, triggered through publish. A
+// failed reading is re-captured from the same source attempts times, delay
+// after the failure, before falling through to the next. The cameras
+// attached to the host are opened, with their LEDs.
+func newSources(cfgs []SourceConfig, publish func(topic string, payload []byte) error, attempts int, delay, silence, alertAfter time.Duration, clock genai.Clock) (*sources, error) {
+	if clock == nil {
+		clock = genai.RealClock
+	}
+	if silence <= 0 {
+		silence = defaultSourceSilence
+	}
+	if alertAfter <= 0 {
+		alertAfter = defaultSourceAlertAfter
+	}
+	s := &sources{silence: silence, alertAfter: alertAfter, clock: clock, started: clock.Now()}
+	for _, c := range cfgs {
+		src := &imageSource{name: c.Name, topic: c.Topic, recapture: &recapture{Source: noRecapture{}, Attempts: attempts, Delay: delay}}
+		switch {
+		case c.Trigger != "":
+			src.trigger = &mqttSource{Publish: publish, Topic: c.Trigger, Payload: []byte(c.payload()), Timeout: c.timeout()}
+			src.capture = src.trigger
+		case c.Camera != nil:
+			cam, err := picam.New(*c.Camera, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("source %s: %w", c.Name, err)
+			}
+			src.camera, src.interval = cam, c.interval()
+			src.capture = cameraCapture{cam}
+		}
+		if src.capture != nil {
+			src.recapture.Source = src.capture
+		}
+		s.list = append(s.list, src)
+	}
+	return s, nil
+}
+
+func (c SourceConfig) payload() string {
+	if c.Payload != "" {
+		return c.Payload
+	}
+	return "capture"
+}
+
+func (c SourceConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultCameraInterval
+}
+
+func (c SourceConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 30 * time.Second
+}
+
+// cameraCapture takes a photo on request with a camera attached to the
+// host.
+type cameraCapture struct{ cam *picam.Camera }
+
+func (c cameraCapture) Recapture(ctx context.Context) ([]byte, error) { return c.cam.Capture(ctx) }
+
+// Primary returns the preferred source.
+func (s *sources) Primary() *imageSource { return s.list[0] }
+
+// Wanted reports whether an image src published on its own is read: the
+// primary's always are, a later source's only while all before it fail.
+func (s *sources) Wanted(src *imageSource) bool {
+	now := s.clock.Now()
+	for _, before := range s.list {
+		if before == src {
+			return true
+		}
+		if !s.failing(before, now) {
+			return false
+		}
+	}
+	return false
+}
+
+// failing reports whether src is failing at now.
+func (s *sources) failing(src *imageSource, now time.Time) bool {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.lastFailure.After(src.lastOK) || now.Sub(later(src.lastImage, s.started)) > s.silence
+}
+
+// Failover returns a capture of the first source after from that takes one
+// on request, once a reading of from failed with err; ok is false if a fresh
+// photo would not fix err or no later source captures.
+func (s *sources) Failover(ctx context.Context, from *imageSource, err error) (*imageSource, io.Reader, bool) {
+	if !from.recapture.Retryable(err) && !errors.Is(err, errCapture) {
+		return nil, nil, false
+	}
+	i := 0
+	for i < len(s.list) && s.list[i] != from {
+		i++
+	}
+	for _, next := range s.list[min(i+1, len(s.list)):] {
+		if next.capture == nil {
+			continue
+		}
+		img, cerr := next.capture.Recapture(ctx)
+		if cerr != nil {
+			log.Printf("Error capturing from %s to fail over from %s: %v", next.name, from.name, cerr)
+			s.Record(next, fmt.Errorf("%w: %w", errCapture, cerr))
+			continue
+		}
+		log.Printf("Failing over from %s to %s after %v", from.name, next.name, err)
+		return next, bytes.NewReader(img), true
+	}
+	return nil, nil, false
+}
+
+// CanFailover reports whether a source after src can take a photo on
+// request.
+func (s *sources) CanFailover(src *imageSource) bool {
+	after := false
+	for _, next := range s.list {
+		if after && next.capture != nil {
+			return true
+		}
+		after = after || next == src
+	}
+	return false
+}
+
+// Received counts an image received from src.
+func (s *sources) Received(src *imageSource) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.captures++
+	src.lastImage = s.clock.Now()
+}
+
+// Record counts the outcome err of a reading of an image of src: nil for
+// an accepted reading, or a failure if the image is to blame. Other errors,
+// such as a rejected increase or the vision API being down, count neither.
+func (s *sources) Record(src *imageSource, err error) {
+	now := s.clock.Now()
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	switch {
+	case err == nil:
+		src.accepted++
+		src.lastOK = now
+		if src.alerted {
+			log.Printf("Image source %s recovered", src.name)
+		}
+		src.alerted = false
+	case errors.Is(err, errCapture) || errors.Is(err, genai.ErrWrongMeter) || src.recapture.Retryable(err):
+		src.failures++
+		src.lastFailure = now
+	}
+}
+
+// Check notifies through onDown if the primary has been failing for
+// alertAfter, once per outage.
+func (s *sources) Check() {
+	now := s.clock.Now()
+	p := s.Primary()
+	if !s.failing(p, now) {
+		return
+	}
+	p.mu.Lock()
+	since := later(p.lastOK, s.started)
+	down := !p.alerted && now.Sub(since) >= s.alertAfter
+	if down {
+		p.alerted = true
+	}
+	p.mu.Unlock()
+	if down && s.onDown != nil {
+		s.onDown(p, since)
+	}
+}
+
+// Watch calls Check every interval until ctx is done.
+func (s *sources) Watch(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-s.clock.After(interval):
+			s.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Health returns the health of every source by name.
+func (s *sources) Health() map[string]SourceHealth {
+	now := s.clock.Now()
+	h := make(map[string]SourceHealth, len(s.list))
+	for _, src := range s.list {
+		failing := s.failing(src, now)
+		src.mu.Lock()
+		h[src.name] = SourceHealth{
+			Captures:     src.captures,
+			Failures:     src.failures,
+			Accepted:     src.accepted,
+			LastImage:    src.lastImage,
+			LastAccepted: src.lastOK,
+			LastFailure:  src.lastFailure,
+			Failing:      failing,
+		}
+		src.mu.Unlock()
+	}
+	return h
+}
+
+// later returns the later of a and b.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
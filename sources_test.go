@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/genai/genaitest"
+	"github.com/suapapa/mqvision/internal/validate"
+)
+
+func TestSourcesFailover(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var s *sources
+	publish := func(topic string, _ []byte) error {
+		switch topic {
+		case "cam2/capture":
+			return errors.New("broker down")
+		case "cam3/capture":
+			go func() {
+				w := s.list[2].trigger.Pending()
+				io.WriteString(w, "jpeg from cam3")
+				w.Close()
+			}()
+			return nil
+		}
+		return fmt.Errorf("published to %s", topic)
+	}
+	s, err := newSources([]SourceConfig{
+		{Name: "primary", Topic: "cam1/image"},
+		{Name: "broken", Topic: "cam2/image", Trigger: "cam2/capture", Timeout: time.Second},
+		{Name: "backup", Topic: "cam3/image", Trigger: "cam3/capture", Timeout: time.Second},
+	}, publish, 0, 0, 0, 0, genaitest.NewClock(time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("newSources: %v", err)
+	}
+	primary, broken, backup := s.list[0], s.list[1], s.list[2]
+
+	if !s.CanFailover(primary) || !s.CanFailover(broken) || s.CanFailover(backup) {
+		t.Fatalf("CanFailover: %t %t %t; want true true false", s.CanFailover(primary), s.CanFailover(broken), s.CanFailover(backup))
+	}
+	tooFar := &validate.Rejection{Validator: validate.MaxDelta, Err: errors.New("increase of 6.000 over 4.000")}
+	if _, _, ok := s.Failover(ctx, primary, tooFar); ok {
+		t.Fatalf("Failover for a rejected increase")
+	}
+	unreadable := &validate.Rejection{Validator: validate.Format, Err: errors.New("reading 0292?.457")}
+	next, img, ok := s.Failover(ctx, primary, unreadable)
+	if !ok || next != backup {
+		t.Fatalf("Failover = %v, %t; want the backup", next, ok)
+	}
+	if b, _ := io.ReadAll(img); string(b) != "jpeg from cam3" {
+		t.Fatalf("Failover image = %q", b)
+	}
+	if h := s.Health()["broken"]; h.Failures != 1 || !h.Failing {
+		t.Fatalf("broken health = %+v; want a failure", h)
+	}
+	if _, _, ok := s.Failover(ctx, backup, fmt.Errorf("%w: empty image", errCapture)); ok {
+		t.Fatalf("Failover from the last source")
+	}
+}
+
+func TestSourcesHealth(t *testing.T) {
+	t.Parallel()
+
+	clock := genaitest.NewClock(time.Date(2025, 11, 7, 6, 0, 0, 0, time.UTC))
+	s, err := newSources([]SourceConfig{
+		{Name: "primary", Topic: "cam1/image"},
+		{Name: "backup", Topic: "cam2/image"},
+	}, nil, 0, 0, 30*time.Minute, time.Hour, clock)
+	if err != nil {
+		t.Fatalf("newSources: %v", err)
+	}
+	primary, backup := s.list[0], s.list[1]
+	var downs []time.Time
+	s.onDown = func(src *imageSource, since time.Time) {
+		if src != primary {
+			t.Fatalf("onDown(%s)", src.name)
+		}
+		downs = append(downs, since)
+	}
+
+	if !s.Wanted(primary) || s.Wanted(backup) {
+		t.Fatalf("at the start the backup is wanted, or the primary is not")
+	}
+	s.Received(primary)
+	s.Record(primary, nil)
+	clock.Advance(10 * time.Minute)
+	s.Received(primary)
+	s.Record(primary, &validate.Rejection{Validator: validate.Quality, Err: errors.New("image issue: glare")})
+	if !s.Wanted(backup) {
+		t.Fatalf("backup not wanted after the primary failed")
+	}
+	s.Check()
+	if len(downs) != 0 {
+		t.Fatalf("notified after 10 minutes")
+	}
+
+	// Silent for an hour after its last accepted image: notified once.
+	clock.Advance(50 * time.Minute)
+	s.Check()
+	s.Check()
+	if len(downs) != 1 || !downs[0].Equal(clock.Now().Add(-time.Hour)) {
+		t.Fatalf("notified %v; want once, since an hour ago", downs)
+	}
+	h := s.Health()["primary"]
+	if h.Captures != 2 || h.Accepted != 1 || h.Failures != 1 || !h.Failing {
+		t.Fatalf("primary health = %+v", h)
+	}
+
+	// Recovered, it is preferred again, and a new outage is notified again.
+	s.Received(primary)
+	s.Record(primary, nil)
+	if s.Wanted(backup) {
+		t.Fatalf("backup wanted after the primary recovered")
+	}
+	s.Record(primary, fmt.Errorf("read: %w", genai.ErrWrongMeter))
+	clock.Advance(2 * time.Hour)
+	s.Check()
+	if len(downs) != 2 {
+		t.Fatalf("second outage notified %d times in all, want 2", len(downs))
+	}
+
+	// Errors that are not the camera's count neither way.
+	s.Record(backup, context.DeadlineExceeded)
+	if h := s.Health()["backup"]; h.Failures != 0 || h.Accepted != 0 {
+		t.Fatalf("backup health = %+v", h)
+	}
+}
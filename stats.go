@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/suapapa/mqvision/internal/billing"
+	"github.com/suapapa/mqvision/internal/genai"
+	"github.com/suapapa/mqvision/internal/store"
+)
+
+// runStats implements the `stats` subcommand: it prints the daily consumption
+// recorded in the store for a period, interpolated at midnight between the
+// readings on either side, and, if a tariff is configured, the estimated
+// cost of the period.
+func runStats(args []string) error {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	configFile := fs.String("c", "config.yaml", "Config file to use")
+	fromFlag := fs.String("from", monthStart.Format(time.DateOnly), "First day of the period (YYYY-MM-DD)")
+	toFlag := fs.String("to", "", "Day after the period (YYYY-MM-DD, default: now)")
+	meterID := fs.String("meter", "", "Meter ID (default: config)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s stats [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if config.Store.Path == "" {
+		return fmt.Errorf("stats: needs store.path")
+	}
+	if *meterID == "" {
+		*meterID = config.Meter.ID
+	}
+	from, err := time.ParseInLocation(time.DateOnly, *fromFlag, time.Local)
+	if err != nil {
+		return fmt.Errorf("parse -from: %w", err)
+	}
+	to := now
+	if *toFlag != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, *toFlag, time.Local); err != nil {
+			return fmt.Errorf("parse -to: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return fmt.Errorf("empty period %s - %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := store.OpenFile(config.Store.Path)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close()
+	// A period's worth of margin finds the readings past the bounds to
+	// interpolate between.
+	margin := to.Sub(from)
+	rs, err := s.ReadingsBetween(ctx, *meterID, from.Add(-margin), to.Add(margin))
+	if err != nil {
+		return fmt.Errorf("load history: %w", err)
+	}
+
+	meter := genai.NewOptions(genai.WithMeter(config.GenAIMeter())).Meter
+	var tariff billing.Tariff // without one consumption is left uncorrected
+	if config.Tariff != nil {
+		tariff = *config.Tariff
+	}
+	maxGap := config.Report.MaxGap
+	ps := tariff.Cumulative(meter, rs)
+	if config.Gaps.Attribution == billing.GapAtEnd {
+		billing.MarkGaps(ps, rs)
+	}
+	// A period up to now is counted up to its last reading, which is as far
+	// as the consumption is known.
+	until := to
+	if *toFlag == "" && len(ps) > 0 && ps[len(ps)-1].At.Before(to) {
+		until = ps[len(ps)-1].At
+	}
+	num := config.Numbers().Float
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "date\treadings\tconsumption (%s)\tcorrected (%s)\t\n", meter.Unit, meter.Unit)
+	incomplete := false
+	count := 0
+	for _, day := range statsDays(rs, from, until) {
+		u, complete := billing.Span(ps, day.from, day.to, maxGap)
+		mark := ""
+		if !complete {
+			mark, incomplete = "*", true
+		}
+		count += day.count
+		fmt.Fprintf(w, "%s%s\t%d\t%s\t%s\t\n", day.from.Format(time.DateOnly), mark, day.count, num(u.Raw, 3), num(u.Corrected, 3))
+	}
+	total, complete := billing.Span(ps, from, until, maxGap)
+	mark := ""
+	if !complete {
+		mark, incomplete = "*", true
+	}
+	fmt.Fprintf(w, "total%s\t%d\t%s\t%s\t\n", mark, count, num(total.Raw, 3), num(total.Corrected, 3))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if incomplete {
+		fmt.Println("* incomplete: readings are missing at midnight; the consumption of the gap is not counted")
+	}
+	for _, r := range rs {
+		if r.Gap() > 0 && !r.ReadAt.Before(from) && r.ReadAt.Before(until) {
+			fmt.Printf("Gaps in the readings: the consumption of a gap is %s\n", gapAttributionText(config.Gaps.Attribution))
+			break
+		}
+	}
+
+	if config.Tariff == nil {
+		return nil
+	}
+	e := tariff.Cost(total, to.Sub(from).Hours()/24)
+	cur := tariff.Currency
+	unit := e.Unit
+	if tariff.Unit == "" {
+		unit = meter.Unit // priced as metered
+	}
+	fmt.Printf("\nEstimate for %s days (%s %s billed):\n", num(e.Days, 1), num(e.Billed, 3), unit)
+	fmt.Printf("  standing charge  %s\n", cur.Format(e.Standing))
+	fmt.Printf("  usage            %s\n", cur.Format(e.Charge))
+	fmt.Printf("  total            %s\n", e.Formatted)
+	return nil
+}
+
+// statsDay is a local day of the stats period, or its first or last part,
+// with the number of readings taken in it. Consumption is interpolated at
+// its bounds, so it counts for the day it flowed in.
+type statsDay struct {
+	from, to time.Time
+	count    int
+}
+
+// statsDays splits [from, to) into local days, from the day of the first
+// reading of rs to that of the last.
+func statsDays(rs []*genai.GasMeterReadResult, from, to time.Time) []statsDay {
+	var days []statsDay
+	if len(rs) == 0 {
+		return nil
+	}
+	first, last := rs[0].ReadAt, rs[len(rs)-1].ReadAt
+	for day := from; day.Before(to); {
+		y, m, d := day.Date()
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+		if end.After(to) {
+			end = to
+		}
+		if end.After(first) && !day.After(last) {
+			sd := statsDay{from: day, to: end}
+			for _, r := range rs {
+				if !r.ReadAt.Before(day) && r.ReadAt.Before(end) {
+					sd.count++
+				}
+			}
+			days = append(days, sd)
+		}
+		day = end
+	}
+	return days
+}